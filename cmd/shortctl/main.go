@@ -0,0 +1,149 @@
+// Command shortctl is a command-line client of a running URL shortener server.
+//
+// Usage:
+//
+//	shortctl [flags] shorten <url>...
+//	shortctl [flags] expand <short>...
+//	shortctl [flags] list
+//	shortctl [flags] delete <short>...
+//
+// The server address, JWT token and API key are read from the -server, -token
+// and -api-key flags, or from the SHORTCTL_SERVER, SHORTCTL_TOKEN and
+// SHORTCTL_API_KEY environment variables. Without a token or API key the
+// server issues a new user, whose token is printed to stderr so that it can be
+// passed to later invocations.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/atinyakov/go-url-shortener/pkg/client"
+)
+
+// defaultServer is the server address used when none is configured.
+const defaultServer = "http://localhost:8080"
+
+// errUsage is returned for invalid command lines, after the usage is printed.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Getenv, os.Stdout, os.Stderr)
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "shortctl:", err)
+		os.Exit(1)
+	}
+}
+
+// run executes the command line args, reading defaults from getenv and writing
+// results to stdout and diagnostics to stderr.
+func run(ctx context.Context, args []string, getenv func(string) string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("shortctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	server := flags.String("server", envOr(getenv, "SHORTCTL_SERVER", defaultServer), "address of the shortener server")
+	token := flags.String("token", getenv("SHORTCTL_TOKEN"), "JWT token of the user to act as")
+	apiKey := flags.String("api-key", getenv("SHORTCTL_API_KEY"), "API key of the user to act as")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout of the whole command")
+	retries := flags.Int("retries", 2, "number of retries of requests failing with transient errors")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: shortctl [flags] shorten <url>... | expand <short>... | list | delete <short>...")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	cmd, operands := flags.Arg(0), flags.Args()[min(1, flags.NArg()):]
+
+	wantOperands := cmd != "list"
+	if (cmd != "shorten" && cmd != "expand" && cmd != "list" && cmd != "delete") || wantOperands == (len(operands) == 0) {
+		flags.Usage()
+		return errUsage
+	}
+
+	c, err := client.New(*server, client.Options{Token: *token, APIKey: *apiKey, MaxRetries: *retries, Gzip: true})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	switch cmd {
+	case "shorten":
+		err = shorten(ctx, c, operands, stdout)
+	case "expand":
+		err = expand(ctx, c, operands, stdout)
+	case "list":
+		err = list(ctx, c, stdout)
+	case "delete":
+		err = c.Delete(ctx, operands...)
+	}
+
+	// Tell the user the token of the user the server created for them
+	if *token == "" && *apiKey == "" && c.Token() != "" {
+		fmt.Fprintf(stderr, "New user token, pass it with -token or SHORTCTL_TOKEN: %s\n", c.Token())
+	}
+	return err
+}
+
+// shorten prints the short URL of every URL, including the existing short
+// URLs of URLs that were shortened before.
+func shorten(ctx context.Context, c *client.Client, urls []string, stdout io.Writer) error {
+	for _, u := range urls {
+		short, err := c.Shorten(ctx, u)
+		if err != nil && !errors.Is(err, client.ErrConflict) {
+			return fmt.Errorf("shorten %s: %w", u, err)
+		}
+		fmt.Fprintln(stdout, short)
+	}
+	return nil
+}
+
+// expand prints the original URL of every short URL.
+func expand(ctx context.Context, c *client.Client, shorts []string, stdout io.Writer) error {
+	for _, short := range shorts {
+		original, err := c.Expand(ctx, short)
+		if err != nil {
+			return fmt.Errorf("expand %s: %w", short, err)
+		}
+		fmt.Fprintln(stdout, original)
+	}
+	return nil
+}
+
+// list prints the short URLs of the user with their original URLs, separated by a tab.
+func list(ctx context.Context, c *client.Client, stdout io.Writer) error {
+	urls, err := c.ListMyURLs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, u := range urls {
+		fmt.Fprintf(stdout, "%s\t%s\n", u.ShortURL, u.OriginalURL)
+	}
+	return nil
+}
+
+// envOr returns the environment variable name, or def if it is empty.
+func envOr(getenv func(string) string, name, def string) string {
+	if v := getenv(name); v != "" {
+		return v
+	}
+	return def
+}
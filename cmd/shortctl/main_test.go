@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/shorten", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "token", Value: "issued-token", Path: "/"})
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"result":"http://short/abc"}`))
+	})
+	mux.HandleFunc("GET /abc", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("GET /api/v1/user/urls", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("token"); err != nil || c.Value != "saved-token" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`[{"short_url":"http://short/abc","original_url":"https://example.com"}]`))
+	})
+	mux.HandleFunc("DELETE /api/v1/user/urls", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRun(t *testing.T) {
+	srv := newTestServer(t)
+	env := map[string]string{"SHORTCTL_SERVER": srv.URL}
+
+	runCmd := func(args ...string) (string, string, error) {
+		var stdout, stderr bytes.Buffer
+		err := run(context.Background(), args, func(name string) string { return env[name] }, &stdout, &stderr)
+		return stdout.String(), stderr.String(), err
+	}
+
+	stdout, stderr, err := runCmd("shorten", "https://example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "http://short/abc\n", stdout)
+	assert.Contains(t, stderr, "issued-token")
+
+	stdout, _, err = runCmd("expand", "http://short/abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com\n", stdout)
+
+	// The token is read from the environment, flags take precedence
+	env["SHORTCTL_TOKEN"] = "saved-token"
+	stdout, stderr, err = runCmd("list")
+	require.NoError(t, err)
+	assert.Equal(t, "http://short/abc\thttps://example.com\n", stdout)
+	assert.Empty(t, stderr)
+
+	stdout, _, err = runCmd("-token", "other", "list")
+	require.NoError(t, err)
+	assert.Empty(t, stdout)
+
+	_, _, err = runCmd("delete", "abc")
+	assert.NoError(t, err)
+
+	_, _, err = runCmd("expand", "missing")
+	assert.ErrorContains(t, err, "expand missing")
+}
+
+func TestRun_Usage(t *testing.T) {
+	for _, args := range [][]string{{}, {"unknown"}, {"shorten"}, {"list", "extra"}, {"-unknown-flag", "list"}} {
+		var stderr bytes.Buffer
+		err := run(context.Background(), args, func(string) string { return "" }, &bytes.Buffer{}, &stderr)
+		assert.ErrorIs(t, err, errUsage, args)
+		assert.Contains(t, stderr.String(), "Usage", args)
+	}
+}
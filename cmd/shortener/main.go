@@ -3,23 +3,36 @@ package main
 import (
 	"cmp"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
+	"github.com/atinyakov/go-url-shortener/internal/app/handler"
 	"github.com/atinyakov/go-url-shortener/internal/app/server"
 	"github.com/atinyakov/go-url-shortener/internal/app/service"
 	"github.com/atinyakov/go-url-shortener/internal/config"
+	"github.com/atinyakov/go-url-shortener/internal/errreport"
+	"github.com/atinyakov/go-url-shortener/internal/events"
+	"github.com/atinyakov/go-url-shortener/internal/listener"
 	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/preview"
 	"github.com/atinyakov/go-url-shortener/internal/repository"
 	"github.com/atinyakov/go-url-shortener/internal/storage"
-
-	_ "net/http/pprof"
+	"github.com/atinyakov/go-url-shortener/internal/threat"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
 )
 
 var buildVersion string
@@ -39,32 +52,115 @@ func main() {
 	fmt.Printf("Build commit: %s\n", cmp.Or(buildCommit, "N/A"))
 
 	var s service.Storage
+	var keyStorage service.APIKeyStorage
+	var userStorage service.UserStorage
+	var auditStorage service.AuditStorage
+	var clickStorage service.ClickStorage
+	var campaignStorage service.CampaignStorage
+	var deleteJournal worker.Journal
+	health := service.NewHealth()
 
 	log := logger.New()
 	defer func() {
-		_ = log.Log.Sync()
+		_ = log.Close()
 	}()
 
-	err := log.Init("Info")
+	err := log.InitWithOptions(logger.Options{
+		Level:       options.LogLevel,
+		Format:      options.LogFormat,
+		Sampling:    options.LogSampling,
+		OutputPaths: options.LogOutputPaths,
+		File:        options.LogFile,
+		Rotation: logger.Rotation{
+			MaxSizeMB:  options.LogMaxSizeMB,
+			MaxAge:     options.LogMaxAge.Duration,
+			MaxBackups: options.LogMaxBackups,
+		},
+	})
 	zapLogger := log.Log
 	if err != nil {
 		panic(err)
 	}
 
-	if options.EnablePprof {
-		go func() {
-			zapLogger.Info("Starting pprof server", zap.String("addr", "localhost:6060"))
-			if err := http.ListenAndServe("localhost:6060", nil); err != nil {
-				zapLogger.Error("pprof server error", zap.Error(err))
-			}
+	// Options that are safe to change at runtime are reloaded on SIGHUP
+	reloader := config.NewReloader(options)
+	reloader.Subscribe(func(o config.Options) {
+		if err := log.SetLevel(o.LogLevel); err != nil {
+			zapLogger.Error("cannot change log level", zap.Error(err))
+		}
+	})
+
+	// Report logged errors, including recovered panics and worker failures
+	if options.SentryDSN != "" {
+		reporter, err := errreport.New(errreport.Options{
+			DSN:         options.SentryDSN,
+			Environment: options.SentryEnvironment,
+			Release:     buildVersion,
+		})
+		if err != nil {
+			zapLogger.Fatal("invalid Sentry DSN", zap.Error(err))
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = reporter.Close(ctx)
 		}()
+		zapLogger = errreport.WrapLogger(zapLogger, reporter)
 	}
 
+	// The pprof endpoints can be switched on and off by reloading the configuration
+	profiler := server.NewProfiler(options.EnablePprof)
+	var pprofOnce sync.Once
+	startPprof := func() {
+		if options.PprofAddr == "" {
+			return
+		}
+		pprofOnce.Do(func() {
+			go func() {
+				zapLogger.Info("Starting pprof server", zap.String("addr", options.PprofAddr))
+				if err := http.ListenAndServe(options.PprofAddr, profiler); err != nil {
+					zapLogger.Error("pprof server error", zap.Error(err))
+				}
+			}()
+		})
+	}
+	if options.EnablePprof {
+		startPprof()
+	}
+	reloader.Subscribe(func(o config.Options) {
+		profiler.SetEnabled(o.EnablePprof)
+		if o.EnablePprof {
+			startPprof()
+		}
+	})
+
 	if dbName != "" {
 		zapLogger.Info("using db", zap.String("dbName", dbName))
 		db := repository.InitDB(dbName, zapLogger)
 		defer db.Close()
-		s = repository.CreateURLRepository(db, zapLogger)
+		repo := repository.CreateURLRepository(db, zapLogger)
+		defer repo.Close()
+		repo.SetResilience(worker.RetryPolicy{
+			MaxRetries: options.DatabaseMaxRetries,
+			Backoff:    options.DatabaseRetryBackoff.Duration,
+		}, repository.NewBreaker(options.DatabaseBreakerThreshold, options.DatabaseBreakerCooldown.Duration))
+		if options.DatabaseReplicaDSN != "" {
+			replica := repository.OpenReplica(options.DatabaseReplicaDSN, zapLogger)
+			defer replica.Close()
+			repo.SetReplica(replica)
+		}
+		repo.SetSlowQueryThreshold(options.SlowQueryThreshold.Duration)
+		reloader.Subscribe(func(o config.Options) {
+			repo.SetSlowQueryThreshold(o.SlowQueryThreshold.Duration)
+		})
+		s = repo
+		keyStorage = repo
+		userStorage = repo
+		auditStorage = repo
+		clickStorage = repo
+		campaignStorage = repo
+		deleteJournal = repo
+		health.AddCheck("migrations", repo.SchemaReady)
 		zapLogger.Info("Database connected and table ready.")
 	} else if filePath != "" {
 		zapLogger.Info("using file", zap.String("filePath", filePath))
@@ -72,6 +168,17 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
+
+		journalPath := options.DeleteJournalPath
+		if journalPath == "" {
+			journalPath = filePath + ".deletes"
+		}
+		journal, err := storage.NewFileDeleteJournal(journalPath)
+		if err != nil {
+			zapLogger.Fatal("cannot open delete journal", zap.Error(err))
+		}
+		defer journal.Close()
+		deleteJournal = journal
 	} else {
 		zapLogger.Info("using in memory storage")
 		s, err = storage.CreateMemoryStorage()
@@ -80,7 +187,46 @@ func main() {
 		}
 	}
 
-	resolver, err := service.NewURLResolver(8, s)
+	// Changes made through the cached storage, such as deletions, invalidate
+	// the records they affect, so it must be used by everything writing them.
+	if options.LinkCacheSize > 0 {
+		cache, err := service.NewLinkCache(options.LinkCacheSize, options.LinkCacheTTL.Duration)
+		if err != nil {
+			zapLogger.Fatal("invalid link cache configuration", zap.Error(err))
+		}
+		s = service.NewCachedStorage(s, cache)
+		zapLogger.Info("caching URL records", zap.Int("size", options.LinkCacheSize), zap.Duration("ttl", options.LinkCacheTTL.Duration))
+	}
+
+	// API keys, user accounts and the audit log are persisted only with the database backend.
+	if keyStorage == nil {
+		keyStorage = storage.NewMemoryAPIKeyStorage()
+	}
+	if userStorage == nil {
+		userStorage = storage.NewMemoryUserStorage()
+	}
+	if auditStorage == nil {
+		auditStorage = storage.NewMemoryAuditStorage()
+	}
+	users := service.NewUsers(userStorage)
+
+	if options.OTLPEndpoint != "" {
+		tracer := tracing.NewOTLPTracer(options.OTLPEndpoint, options.TracingServiceName)
+		tracing.SetTracer(tracer)
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tracer.Shutdown(ctx); err != nil {
+				zapLogger.Error("cannot export remaining traces", zap.Error(err))
+			}
+		}()
+		zapLogger.Info("exporting traces", zap.String("endpoint", options.OTLPEndpoint))
+	}
+
+	resolver, err := service.NewURLResolverWithOptions(options.ShortURLLength, s, service.ResolverOptions{
+		Alphabet:        options.ShortURLAlphabet,
+		CaseInsensitive: options.CaseInsensitiveShortURLs,
+	})
 	if err != nil {
 		panic(err)
 	}
@@ -88,43 +234,243 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	defer stop()
 
-	URLService, shutdown := service.NewURL(ctx, s, resolver, zapLogger, resultHostname)
+	deleteOpts := worker.Options{
+		BatchSize:     options.DeleteBatchSize,
+		FlushInterval: options.DeleteFlushInterval.Duration,
+		Workers:       options.DeleteWorkers,
+		MaxRetries:    options.DeleteMaxRetries,
+		RetryBackoff:  options.DeleteRetryBackoff.Duration,
+		Journal:       deleteJournal,
+	}
+	if deleteOpts.MaxRetries == 0 {
+		// Zero means "use the default" for the worker
+		deleteOpts.MaxRetries = -1
+	}
+	if options.DeleteDeadLetterPath != "" {
+		deleteOpts.DeadLetter = storage.NewFileDeadLetterStorage(options.DeleteDeadLetterPath)
+	}
+
+	URLService, shutdown := service.NewURLWithOptions(ctx, s, resolver, zapLogger, resultHostname, deleteOpts)
 	defer shutdown()
+	URLService.SetURLQuota(options.MaxURLsPerUser)
+	reloader.Subscribe(func(o config.Options) {
+		URLService.SetURLQuota(o.MaxURLsPerUser)
+	})
+	URLService.SetAbuseLimits(options.AbuseURLsPerMinute, options.AbuseBanDuration.Duration)
+	reloader.Subscribe(func(o config.Options) {
+		URLService.SetAbuseLimits(o.AbuseURLsPerMinute, o.AbuseBanDuration.Duration)
+	})
+	URLService.SetLinkTTL(options.LinkTTL.Duration)
+	URLService.SetStrictBatches(options.StrictBatches)
+
+	// Without a database, clicks are counted and campaigns kept in memory
+	if clickStorage != nil {
+		URLService.SetClickStorage(clickStorage)
+	}
+	if campaignStorage != nil {
+		URLService.SetCampaignStorage(campaignStorage)
+	}
+	URLService.SetClickFlushInterval(options.ClickFlushInterval.Duration)
+
+	tenants, err := newTenants(options)
+	if err != nil {
+		zapLogger.Fatal("invalid tenant configuration", zap.Error(err))
+	}
+	URLService.SetTenants(tenants)
+
+	// The blocklist file is read again on every reload, even if the options did not change
+	blockedDomains, err := blocklistDomains(options)
+	if err != nil {
+		zapLogger.Fatal("cannot read blocklist", zap.Error(err))
+	}
+	blocklist := service.NewBlocklist(blockedDomains)
+	URLService.SetBlocklist(blocklist)
+	reloader.OnReload(func(o config.Options) {
+		domains, err := blocklistDomains(&o)
+		if err != nil {
+			zapLogger.Error("cannot reload blocklist", zap.Error(err))
+			return
+		}
+		blocklist.Set(domains)
+	})
+
+	allowlist := service.NewAllowlist(options.AllowedDomains)
+	URLService.SetAllowlist(allowlist)
+	reloader.Subscribe(func(o config.Options) {
+		allowlist.Set(o.AllowedDomains)
+	})
+
+	checker, err := newThreatChecker(options)
+	if err != nil {
+		zapLogger.Fatal("cannot read threat feed", zap.Error(err))
+	}
+	if checker != nil {
+		scanner := worker.NewThreatScanner(zapLogger, checker, s, worker.ScanOptions{})
+		if err := URLService.SetThreatScanner(scanner); err != nil {
+			zapLogger.Fatal("cannot start threat scanner", zap.Error(err))
+		}
+	}
+
+	if options.FetchTitles {
+		fetcher := worker.NewTitleFetcher(zapLogger, preview.NewFetcher(), s, worker.TitleOptions{})
+		if err := URLService.SetTitleFetcher(fetcher); err != nil {
+			zapLogger.Fatal("cannot start title fetcher", zap.Error(err))
+		}
+	}
+
+	sweeper := worker.NewExpirationSweeper(zapLogger, s, worker.SweepOptions{
+		Interval:  options.SweepInterval.Duration,
+		BatchSize: options.SweepBatchSize,
+	})
+	if err := URLService.Jobs().Register("expiration_sweeper", sweeper); err != nil {
+		zapLogger.Fatal("cannot start expiration sweeper", zap.Error(err))
+	}
+	if err := URLService.SetStatsCache(options.StatsRefresh.Duration); err != nil {
+		zapLogger.Fatal("cannot start stats cache", zap.Error(err))
+	}
+	health.AddCheck("storage", URLService.CheckStorage)
+	health.AddCheck("delete_worker", URLService.CheckWorker)
+
+	if options.EventBusURL != "" {
+		publisher, err := events.New(options.EventBusURL)
+		if err != nil {
+			zapLogger.Fatal("cannot connect to event bus", zap.Error(err))
+		}
+		defer publisher.Close()
+		URLService.SetEventPublisher(publisher, options.EventSubjectPrefix)
+		zapLogger.Info("publishing URL events", zap.String("prefix", options.EventSubjectPrefix))
+	}
+
+	auth, err := newAuth(options, URLService, zapLogger)
+	if err != nil {
+		zapLogger.Fatal("invalid JWT key configuration", zap.Error(err))
+	}
+	auth.SetUsers(users)
+
+	// Admin tokens are minted from the command line only; they need a configured
+	// key, since a random one would not be known to the running servers.
+	if options.IssueAdminToken != "" {
+		if options.JWTSecret == "" && options.JWTKeyFile == "" {
+			zapLogger.Fatal("issuing an admin token requires a configured JWT key")
+		}
+		token, err := auth.BuildAdminJWTString(options.IssueAdminToken)
+		if err != nil {
+			zapLogger.Fatal("cannot issue admin token", zap.Error(err))
+		}
+		fmt.Println(token)
+		return
+	}
+
+	go reloader.Watch(ctx, func(changed bool, err error) {
+		switch {
+		case err != nil:
+			zapLogger.Error("cannot reload configuration", zap.Error(err))
+		case changed:
+			zapLogger.Info("configuration reloaded")
+		default:
+			zapLogger.Info("configuration unchanged")
+		}
+	})
+
+	// Internal endpoints are available to the trusted subnets, which can be changed by reloading
+	trusted, err := middleware.NewTrustedSubnets(options.TrustedSubnets)
+	if err != nil {
+		zapLogger.Fatal("invalid trusted subnets", zap.Error(err))
+	}
+	reloader.Subscribe(func(o config.Options) {
+		if err := trusted.Set(o.TrustedSubnets); err != nil {
+			zapLogger.Error("cannot change trusted subnets", zap.Error(err))
+		}
+	})
 
-	router := server.Init(resultHostname, zapLogger, true, URLService)
+	// Client IPs are taken from forwarding headers only when sent by a trusted proxy
+	proxies, err := middleware.NewTrustedSubnets(options.TrustedProxies)
+	if err != nil {
+		zapLogger.Fatal("invalid trusted proxies", zap.Error(err))
+	}
+	reloader.Subscribe(func(o config.Options) {
+		if err := proxies.Set(o.TrustedProxies); err != nil {
+			zapLogger.Error("cannot change trusted proxies", zap.Error(err))
+		}
+	})
+
+	// Client IPs are anonymized before they reach the logs and the audit log, if configured
+	hashKey := options.IPHashKey
+	if options.IPAnonymization == middleware.AnonymizeHash && hashKey == "" {
+		zapLogger.Warn("IP hash key is not configured, generating a random one")
+		if hashKey, err = service.GenerateSecret(); err != nil {
+			zapLogger.Fatal("cannot generate IP hash key", zap.Error(err))
+		}
+	}
+	anonymizer, err := middleware.NewIPAnonymizer(options.IPAnonymization, []byte(hashKey))
+	if err != nil {
+		zapLogger.Fatal("invalid IP anonymization", zap.Error(err))
+	}
+
+	var routerProfiler http.Handler
+	if options.PprofOnRouter {
+		routerProfiler = profiler
+	}
+	pages, err := handler.LoadPages(options.TemplateDir)
+	if err != nil {
+		zapLogger.Fatal("cannot load page templates", zap.Error(err))
+	}
+
+	// The rate limit can be changed, or switched on and off, by reloading
+	limiter := middleware.NewRateLimiter(options.RateLimit, options.RateLimitWindow.Duration)
+	reloader.Subscribe(func(o config.Options) {
+		limiter.Set(o.RateLimit, o.RateLimitWindow.Duration)
+	})
+
+	router := server.Init(options, zapLogger, true, URLService, auth, service.NewAPIKeys(keyStorage), users, service.NewAudit(auditStorage, zapLogger), health, routerProfiler, trusted, limiter, pages)
+	root := middleware.WithClientIP(proxies)(middleware.WithIPAnonymization(anonymizer)(router))
 
 	var srv *http.Server
+	addr := hostname
 
 	if useTLS {
-		manager := &autocert.Manager{
-			Cache:      autocert.DirCache("cache-dir"),
-			Prompt:     autocert.AcceptTOS,
-			HostPolicy: autocert.HostWhitelist("mysite.ru", "www.mysite.ru"),
+		tlsConfig, tlsAddr, err := newTLSConfig(options)
+		if err != nil {
+			zapLogger.Fatal("invalid TLS configuration", zap.Error(err))
 		}
+		addr = tlsAddr
 		srv = &http.Server{
-			Addr:      ":443",
-			Handler:   router,
-			TLSConfig: manager.TLSConfig(),
-		}
-		go func() {
-			zapLogger.Info("Server is running with TLS", zap.String("hostname", hostname))
-			if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
-				zapLogger.Fatal("Server error", zap.Error(err))
-			}
-		}()
+			Handler:   root,
+			TLSConfig: tlsConfig,
+		}
 	} else {
+		if options.EnableH2C {
+			root = h2c.NewHandler(root, &http2.Server{})
+		}
 		srv = &http.Server{
-			Addr:    hostname,
-			Handler: router,
+			Handler: root,
 		}
-		go func() {
-			zapLogger.Info("Server is running", zap.String("hostname", hostname))
-			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				zapLogger.Fatal("Server error", zap.Error(err))
-			}
-		}()
 	}
 
+	ln, err := listener.Listen(listener.Options{
+		Addr:              addr,
+		UnixSocket:        options.UnixSocket,
+		SystemdActivation: options.SystemdActivation,
+		SocketName:        options.SystemdSocketName,
+	})
+	if err != nil {
+		zapLogger.Fatal("cannot listen", zap.Error(err))
+	}
+
+	go func() {
+		zapLogger.Info("Server is running", zap.String("hostname", hostname),
+			zap.Stringer("addr", ln.Addr()), zap.Bool("tls", useTLS))
+		var err error
+		if useTLS {
+			err = srv.ServeTLS(ln, "", "")
+		} else {
+			err = srv.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			zapLogger.Fatal("Server error", zap.Error(err))
+		}
+	}()
+
 	// Ожидаем сигнал завершения
 	<-ctx.Done()
 	zapLogger.Info("Shutdown signal received")
@@ -139,3 +485,141 @@ func main() {
 		zapLogger.Info("Server shutdown gracefully")
 	}
 }
+
+// blocklistDomains returns the blocked domains listed in the options and in
+// the blocklist file.
+func blocklistDomains(options *config.Options) ([]string, error) {
+	domains := append([]string(nil), options.BlockedDomains...)
+	if options.BlocklistFile != "" {
+		fromFile, err := service.LoadBlocklistFile(options.BlocklistFile)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, fromFile...)
+	}
+	return domains, nil
+}
+
+// newTenants returns the tenants of the configured domains, in front of the
+// default tenant made of the base URL, redirect code and brand options.
+func newTenants(options *config.Options) (*service.Tenants, error) {
+	fallback := service.Tenant{
+		BaseURL:      options.ResultHostname,
+		RedirectCode: options.RedirectCode,
+		Branding:     service.Branding{Name: options.BrandName, LogoURL: options.BrandLogoURL, Color: options.BrandColor},
+	}
+
+	tenants := make([]service.Tenant, 0, len(options.Tenants))
+	for host, t := range options.Tenants {
+		tenants = append(tenants, service.Tenant{
+			Host:         host,
+			BaseURL:      t.BaseURL,
+			RedirectCode: t.RedirectCode,
+			Branding:     service.Branding{Name: t.BrandName, LogoURL: t.BrandLogoURL, Color: t.BrandColor},
+		})
+	}
+	return service.NewTenants(fallback, tenants)
+}
+
+// newThreatChecker returns the checker created short URLs are scanned with:
+// the local threat feed and Safe Browsing, whichever are configured, or nil
+// if neither is.
+func newThreatChecker(options *config.Options) (threat.Checker, error) {
+	var chain threat.Chain
+	if options.ThreatFeedFile != "" {
+		feed, err := threat.LoadFeed(options.ThreatFeedFile)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, feed)
+	}
+	if options.SafeBrowsingAPIKey != "" {
+		chain = append(chain, threat.NewSafeBrowsing(options.SafeBrowsingAPIKey, ""))
+	}
+
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	return chain, nil
+}
+
+// newTLSConfig returns the TLS configuration of the HTTPS server and the
+// TCP address it listens on. Certificates given with TLSCertFile and TLSKeyFile are
+// served on the configured address; without them certificates for
+// AutocertDomains are obtained with autocert, which requires the server to
+// listen on port 443.
+func newTLSConfig(options *config.Options) (*tls.Config, string, error) {
+	if options.TLSCertFile != "" || options.TLSKeyFile != "" {
+		if options.TLSCertFile == "" || options.TLSKeyFile == "" {
+			return nil, "", errors.New("both TLS certificate and key files must be set")
+		}
+		cert, err := tls.LoadX509KeyPair(options.TLSCertFile, options.TLSKeyFile)
+		if err != nil {
+			return nil, "", err
+		}
+		return &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}, options.Port, nil
+	}
+
+	if len(options.AutocertDomains) == 0 {
+		return nil, "", errors.New("autocert requires at least one domain, or TLS certificate and key files")
+	}
+	manager := &autocert.Manager{
+		Cache:      autocert.DirCache(options.AutocertCacheDir),
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(options.AutocertDomains...),
+	}
+	if options.ACMEDirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: options.ACMEDirectoryURL}
+	}
+	return manager.TLSConfig(), ":443", nil
+}
+
+// newAuth builds the JWT key set from the options. The private key file takes
+// precedence over the HMAC secret; the configured secrets and public keys stay
+// accepted for verification, so running sessions survive a switch of keys.
+// Without any signing key tokens are signed with a random secret and do not
+// survive a restart: browsers sending them get a new session.
+func newAuth(options *config.Options, sv service.URLServiceIface, log *zap.Logger) (*service.Auth, error) {
+	var keys []service.SigningKey
+
+	if options.JWTKeyFile != "" {
+		key, err := service.LoadSigningKey(options.JWTKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	secrets := options.JWTPreviousSecrets
+	if options.JWTSecret != "" {
+		secrets = append([]string{options.JWTSecret}, secrets...)
+	} else if len(keys) == 0 {
+		log.Warn("JWT secret is not configured, generating a random one; sessions end on restart")
+		secret, err := service.GenerateSecret()
+		if err != nil {
+			return nil, err
+		}
+		secrets = append([]string{secret}, secrets...)
+	}
+
+	for _, secret := range secrets {
+		key, err := service.HMACKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	for _, path := range options.JWTPublicKeyFiles {
+		key, err := service.LoadVerificationKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return service.NewAuthWithKeys(sv, keys)
+}
@@ -3,25 +3,23 @@ module github.com/atinyakov/go-url-shortener
 go 1.23.6
 
 require (
+	github.com/BurntSushi/toml v1.4.1-0.20240526193622-a339e1f7089c
 	github.com/go-chi/chi/v5 v5.2.0
 	github.com/stretchr/testify v1.10.0
 	go.uber.org/mock v0.5.1
+	golang.org/x/crypto v0.38.0
 	golang.org/x/tools v0.33.0
 	honnef.co/go/tools v0.6.1
 )
 
 require (
-	github.com/BurntSushi/toml v1.4.1-0.20240526193622-a339e1f7089c // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
 	github.com/rogpeppe/go-internal v1.13.1 // indirect
 	go.uber.org/multierr v1.10.0 // indirect
-	golang.org/x/crypto v0.38.0 // indirect
 	golang.org/x/exp/typeparams v0.0.0-20231108232855-2478ac86f678 // indirect
 	golang.org/x/mod v0.24.0 // indirect
-	golang.org/x/net v0.40.0 // indirect
-	golang.org/x/sync v0.14.0 // indirect
 	golang.org/x/text v0.25.0 // indirect
 )
 
@@ -34,5 +32,7 @@ require (
 	github.com/jackc/pgx/v5 v5.7.2
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	go.uber.org/zap v1.27.0
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/net v0.40.0
+	golang.org/x/sync v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,285 @@
+// Package handler provides HTTP handlers of the admin API.
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// AdminHandler handles HTTP requests of the admin API. Access control is
+// left to the router, which mounts these handlers behind middleware.RequireAdmin.
+type AdminHandler struct {
+	service service.URLServiceIface // The service for URL-related operations.
+	audit   service.AuditIface      // The audit log of mutating operations.
+	logger  *zap.Logger             // Logger for logging events.
+}
+
+// NewAdmin creates a new instance of AdminHandler with the provided URL service, audit log and logger.
+func NewAdmin(s service.URLServiceIface, a service.AuditIface, l *zap.Logger) *AdminHandler {
+	return &AdminHandler{
+		service: s,
+		audit:   a,
+		logger:  l,
+	}
+}
+
+// URLsByUserID handles GET requests listing the URLs of any user, given by the
+// userID path parameter. It accepts the same limit, offset, sort and q query
+// parameters as the user listing and returns 204 No Content if the user has no URLs.
+func (h *AdminHandler) URLsByUserID(res http.ResponseWriter, req *http.Request) {
+	// Extract the user ID from the path.
+	userID := chi.URLParam(req, "userID")
+	if userID == "" {
+		http.Error(res, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	// Parse pagination, ordering and filtering parameters.
+	opts, err := parseListOptions(req)
+	if err != nil {
+		http.Error(res, "Invalid limit, offset or sort parameter", http.StatusBadRequest)
+		return
+	}
+
+	// Retrieve the URLs of the user from the service.
+	urls, err := h.service.GetURLByUserID(req.Context(), userID, opts)
+	if err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot list user urls", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	// If no URLs are found, return a 204 No Content status.
+	if len(*urls) == 0 {
+		res.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, *urls)
+}
+
+// DeleteURLs handles DELETE requests removing a list of short URLs regardless
+// of their owner. Like the user deletion, it returns 202 Accepted once the
+// records are queued for deletion.
+func (h *AdminHandler) DeleteURLs(res http.ResponseWriter, req *http.Request) {
+	// Parse the incoming JSON request body.
+	var request []string
+	if err := decodeJSONBody(res, req, &request); err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(res, mr.msg, mr.status)
+			return
+		}
+		logger.FromContext(req.Context(), h.logger).Error(err.Error())
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// Queue the records for deletion.
+	if err := h.service.ForceDeleteURLRecords(req.Context(), request); err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot delete urls", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusAccepted)
+}
+
+// parseStatsOptions builds service.StatsOptions from the from and to query
+// parameters, days formatted as YYYY-MM-DD; to defaults to today.
+func parseStatsOptions(req *http.Request) (service.StatsOptions, error) {
+	query := req.URL.Query()
+	var opts service.StatsOptions
+
+	from, to := query.Get("from"), query.Get("to")
+	if from == "" {
+		if to != "" {
+			return opts, errors.New("to requires from")
+		}
+		return opts, nil
+	}
+
+	var err error
+	if opts.From, err = time.Parse(time.DateOnly, from); err != nil {
+		return opts, err
+	}
+	opts.To = time.Now().UTC()
+	if to != "" {
+		if opts.To, err = time.Parse(time.DateOnly, to); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// Stats handles GET requests for the global statistics of the service. With
+// the from query parameter, and optionally to, the response includes the
+// number of URLs created and deleted on each day from from until to, both
+// included, for at most 366 days.
+func (h *AdminHandler) Stats(res http.ResponseWriter, req *http.Request) {
+	opts, err := parseStatsOptions(req)
+	if err != nil {
+		http.Error(res, "Invalid from or to parameter", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.service.GetStats(req.Context(), opts)
+	if errors.Is(err, service.ErrInvalidStatsRange) {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot get stats", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, stats)
+}
+
+// Defaults and bounds of the window and limit query parameters of Top.
+const (
+	defaultTopWindow = 24 * time.Hour
+	defaultTopLimit  = 10
+	maxTopLimit      = 100
+)
+
+// Top handles GET requests for the most clicked short URLs in a time window,
+// given by the window query parameter as a Go duration such as "24h" (the
+// default) and counted per hour. The limit query parameter sets the number of
+// short URLs returned, 10 by default and at most 100. It returns 400 Bad
+// Request for windows that are not positive or longer than the clicks are kept.
+func (h *AdminHandler) Top(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	window := defaultTopWindow
+	if v := query.Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > storage.ClickRetention {
+			http.Error(res, "Invalid window parameter", http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+
+	limit := defaultTopLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxTopLimit {
+			http.Error(res, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	top, err := h.service.TopURLs(req.Context(), window, limit)
+	if err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot get top urls", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, top)
+}
+
+// parseAuditFilter builds storage.AuditFilter from the user_id, since, until
+// and limit query parameters; times are expected in RFC 3339 format.
+func parseAuditFilter(req *http.Request) (storage.AuditFilter, error) {
+	query := req.URL.Query()
+	f := storage.AuditFilter{UserID: query.Get("user_id")}
+
+	var err error
+	if v := query.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, err
+		}
+	}
+
+	if v := query.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, err
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+			return f, errors.New("invalid limit")
+		}
+	}
+
+	return f, nil
+}
+
+// Audit handles GET requests querying the audit log, newest events first.
+// Events can be filtered by user_id and by the since and until timestamps,
+// and limited in number with limit.
+func (h *AdminHandler) Audit(res http.ResponseWriter, req *http.Request) {
+	// Parse the filter from the query parameters.
+	f, err := parseAuditFilter(req)
+	if err != nil {
+		http.Error(res, "Invalid user_id, since, until or limit parameter", http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.audit.Query(req.Context(), f)
+	if err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot query audit log", zap.Error(err))
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// Convert the events to their HTTP representation.
+	resp := make([]models.AuditEvent, 0, len(events))
+	for _, e := range events {
+		resp = append(resp, models.AuditEvent{
+			ID:         e.ID,
+			Time:       e.Time,
+			UserID:     e.UserID,
+			Action:     e.Action,
+			Targets:    e.Targets,
+			Status:     e.Status,
+			RemoteAddr: e.RemoteAddr,
+			RequestID:  e.RequestID,
+		})
+	}
+
+	writeJSON(res, http.StatusOK, resp)
+}
+
+// Bans handles GET requests listing the current bans of users and client IPs
+// creating URLs too fast, the ones ending first first.
+func (h *AdminHandler) Bans(res http.ResponseWriter, req *http.Request) {
+	writeJSON(res, http.StatusOK, h.service.Bans(req.Context()))
+}
+
+// LiftBan handles DELETE requests ending the ban of a user or a client IP,
+// given by the kind ("user" or "ip") and subject path parameters, right away.
+// It returns 204 No Content once lifted, 400 Bad Request for unknown kinds
+// and 404 Not Found if the subject is not banned.
+func (h *AdminHandler) LiftBan(res http.ResponseWriter, req *http.Request) {
+	err := h.service.LiftBan(req.Context(), chi.URLParam(req, "kind"), chi.URLParam(req, "subject"))
+	switch {
+	case errors.Is(err, service.ErrInvalidBanKind):
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(res, "ban not found", http.StatusNotFound)
+		return
+	case err != nil:
+		logger.FromContext(req.Context(), h.logger).Error("cannot lift ban", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,289 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/handler"
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// withURLParam sets a chi path parameter on the request.
+func withURLParam(req *http.Request, key, value string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add(key, value)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestAdminURLsByUserID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewAdmin(mockService, nil, testLogger())
+
+	t.Run("lists URLs of the user", func(t *testing.T) {
+		mockService.EXPECT().
+			GetURLByUserID(gomock.Any(), "user-1", storage.ListOptions{Limit: 10}).
+			Return(&[]models.ByIDRequest{{OriginalURL: "https://example.com", ShortURL: "http://localhost:8080/abc"}}, nil)
+
+		req := withURLParam(httptest.NewRequest(http.MethodGet, "/api/admin/users/user-1/urls?limit=10", nil), "userID", "user-1")
+		rec := httptest.NewRecorder()
+		h.URLsByUserID(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `[{"original_url":"https://example.com","short_url":"http://localhost:8080/abc"}]`, rec.Body.String())
+	})
+
+	t.Run("user without URLs", func(t *testing.T) {
+		mockService.EXPECT().
+			GetURLByUserID(gomock.Any(), "user-2", storage.ListOptions{}).
+			Return(&[]models.ByIDRequest{}, nil)
+
+		req := withURLParam(httptest.NewRequest(http.MethodGet, "/api/admin/users/user-2/urls", nil), "userID", "user-2")
+		rec := httptest.NewRecorder()
+		h.URLsByUserID(rec, req)
+
+		require.Equal(t, http.StatusNoContent, rec.Code)
+	})
+
+	t.Run("invalid pagination", func(t *testing.T) {
+		req := withURLParam(httptest.NewRequest(http.MethodGet, "/api/admin/users/user-1/urls?limit=x", nil), "userID", "user-1")
+		rec := httptest.NewRecorder()
+		h.URLsByUserID(rec, req)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestAdminDeleteURLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewAdmin(mockService, nil, testLogger())
+
+	t.Run("queues deletion", func(t *testing.T) {
+		mockService.EXPECT().ForceDeleteURLRecords(gomock.Any(), []string{"abc", "def"}).Return(nil)
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/admin/urls", bytes.NewBufferString(`["abc","def"]`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.DeleteURLs(rec, req)
+
+		require.Equal(t, http.StatusAccepted, rec.Code)
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/api/admin/urls", bytes.NewBufferString(`{`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.DeleteURLs(rec, req)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestAdminStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewAdmin(mockService, nil, testLogger())
+
+	t.Run("returns stats", func(t *testing.T) {
+		mockService.EXPECT().GetStats(gomock.Any(), service.StatsOptions{}).Return(&models.Stats{URLs: 3, Users: 2, ComputedAt: time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC), CacheAge: 30, Jobs: map[string]models.JobMetrics{
+			"delete": {Runs: 4, Retries: 2, Failures: 1, DeadLettered: 1},
+		}}, nil)
+
+		rec := httptest.NewRecorder()
+		h.Stats(rec, httptest.NewRequest(http.MethodGet, "/api/admin/stats", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"urls":3,"users":2,"computed_at":"2024-05-02T12:00:00Z","cache_age_seconds":30,"jobs":{"delete":{"runs":4,"retries":2,"failures":1,"dead_lettered":1}}}`, rec.Body.String())
+	})
+
+	t.Run("returns daily changes", func(t *testing.T) {
+		opts := service.StatsOptions{From: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)}
+		mockService.EXPECT().GetStats(gomock.Any(), opts).Return(&models.Stats{URLs: 3, Users: 2, Daily: []models.DailyStats{
+			{Date: "2024-05-01", Created: 2},
+			{Date: "2024-05-02", Created: 1, Deleted: 1},
+		}}, nil)
+
+		rec := httptest.NewRecorder()
+		h.Stats(rec, httptest.NewRequest(http.MethodGet, "/api/admin/stats?from=2024-05-01&to=2024-05-02", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"urls":3,"users":2,"computed_at":"0001-01-01T00:00:00Z","cache_age_seconds":0,"daily":[{"date":"2024-05-01","created":2,"deleted":0},{"date":"2024-05-02","created":1,"deleted":1}]}`, rec.Body.String())
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		mockService.EXPECT().GetStats(gomock.Any(), gomock.Any()).Return(nil, service.ErrInvalidStatsRange)
+
+		rec := httptest.NewRecorder()
+		h.Stats(rec, httptest.NewRequest(http.MethodGet, "/api/admin/stats?from=2024-05-02&to=2024-05-01", nil))
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	for _, query := range []string{"from=yesterday", "to=2024-05-01", "from=2024-05-01&to=05/02/2024"} {
+		t.Run("invalid "+query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.Stats(rec, httptest.NewRequest(http.MethodGet, "/api/admin/stats?"+query, nil))
+
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+
+	t.Run("service error", func(t *testing.T) {
+		mockService.EXPECT().GetStats(gomock.Any(), service.StatsOptions{}).Return(nil, errors.New("db down"))
+
+		rec := httptest.NewRecorder()
+		h.Stats(rec, httptest.NewRequest(http.MethodGet, "/api/admin/stats", nil))
+
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestAdminTop(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewAdmin(mockService, nil, testLogger())
+
+	t.Run("defaults to the last day", func(t *testing.T) {
+		mockService.EXPECT().TopURLs(gomock.Any(), 24*time.Hour, 10).
+			Return([]models.TopURL{{ShortURL: "http://localhost:8080/abc", OriginalURL: "https://example.com", Clicks: 5}}, nil)
+
+		rec := httptest.NewRecorder()
+		h.Top(rec, httptest.NewRequest(http.MethodGet, "/api/internal/top", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `[{"short_url":"http://localhost:8080/abc","original_url":"https://example.com","clicks":5}]`, rec.Body.String())
+	})
+
+	t.Run("window and limit", func(t *testing.T) {
+		mockService.EXPECT().TopURLs(gomock.Any(), 2*time.Hour, 3).Return([]models.TopURL{}, nil)
+
+		rec := httptest.NewRecorder()
+		h.Top(rec, httptest.NewRequest(http.MethodGet, "/api/internal/top?window=2h&limit=3", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `[]`, rec.Body.String())
+	})
+
+	for _, query := range []string{"window=day", "window=-1h", "window=1000h", "limit=0", "limit=101"} {
+		t.Run("invalid "+query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.Top(rec, httptest.NewRequest(http.MethodGet, "/api/internal/top?"+query, nil))
+
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
+
+func TestAdminAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAudit := mocks.NewMockAuditIface(ctrl)
+	h := handler.NewAdmin(nil, mockAudit, testLogger())
+
+	t.Run("queries the audit log", func(t *testing.T) {
+		since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		mockAudit.EXPECT().
+			Query(gomock.Any(), storage.AuditFilter{UserID: "user-1", Since: since, Limit: 5}).
+			Return([]storage.AuditEvent{{
+				ID:         1,
+				Time:       since,
+				UserID:     "user-1",
+				Action:     "POST /api/v1/shorten",
+				Targets:    []string{"abc"},
+				Status:     http.StatusCreated,
+				RemoteAddr: "192.0.2.1",
+				RequestID:  "req-1",
+			}}, nil)
+
+		rec := httptest.NewRecorder()
+		h.Audit(rec, httptest.NewRequest(http.MethodGet, "/api/admin/audit?user_id=user-1&since=2024-01-01T00:00:00Z&limit=5", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `[{"id":1,"time":"2024-01-01T00:00:00Z","user_id":"user-1","action":"POST /api/v1/shorten",
+			"targets":["abc"],"status":201,"remote_addr":"192.0.2.1","request_id":"req-1"}]`, rec.Body.String())
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.Audit(rec, httptest.NewRequest(http.MethodGet, "/api/admin/audit?since=yesterday", nil))
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestAdminBans(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewAdmin(mockService, nil, testLogger())
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockService.EXPECT().Bans(gomock.Any()).Return([]models.Ban{
+		{Kind: service.BanKindIP, Subject: "192.0.2.1", Since: since, Until: since.Add(time.Hour)},
+	})
+
+	rec := httptest.NewRecorder()
+	h.Bans(rec, httptest.NewRequest(http.MethodGet, "/api/admin/bans", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `[{"kind":"ip","subject":"192.0.2.1","since":"2024-01-01T00:00:00Z","until":"2024-01-01T01:00:00Z"}]`, rec.Body.String())
+}
+
+func TestAdminLiftBan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewAdmin(mockService, nil, testLogger())
+
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode int
+	}{
+		{name: "lifted", expectedCode: http.StatusNoContent},
+		{name: "not banned", err: storage.ErrNotFound, expectedCode: http.StatusNotFound},
+		{name: "unknown kind", err: service.ErrInvalidBanKind, expectedCode: http.StatusBadRequest},
+		{name: "service failure", err: errors.New("boom"), expectedCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService.EXPECT().LiftBan(gomock.Any(), service.BanKindUser, "user-1").Return(tt.err)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("kind", service.BanKindUser)
+			rctx.URLParams.Add("subject", "user-1")
+			req := httptest.NewRequest(http.MethodDelete, "/api/admin/bans/user/user-1", nil)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rec := httptest.NewRecorder()
+			h.LiftBan(rec, req)
+
+			require.Equal(t, tt.expectedCode, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Package handler provides HTTP handlers for issuing API keys.
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+)
+
+// APIKeyHandler handles HTTP requests for managing API keys.
+type APIKeyHandler struct {
+	keys   service.APIKeyIface // Service for issuing API keys.
+	logger *zap.Logger         // Logger for logging events.
+}
+
+// NewAPIKey creates a new APIKeyHandler instance with the provided API key service and logger.
+func NewAPIKey(k service.APIKeyIface, l *zap.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		keys:   k,
+		logger: l,
+	}
+}
+
+// Issue handles POST requests for issuing a new API key to the current user.
+// The key is returned once in the response body; machine clients then send it
+// in the "Authorization: ApiKey <key>" header to act as this user.
+func (h *APIKeyHandler) Issue(res http.ResponseWriter, req *http.Request) {
+	// Extract the user ID from the request context.
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	// Issue a new key for the user.
+	key, err := h.keys.Issue(req.Context(), userID)
+	if err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot issue api key", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	// Marshal the response.
+	resp, err := json.Marshal(models.APIKeyResponse{Key: key})
+	if err != nil {
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// Set response headers and write the response.
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusCreated)
+	res.Write(resp)
+}
@@ -0,0 +1,59 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/handler"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+)
+
+func TestIssueAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockKeys := mocks.NewMockAPIKeyIface(ctrl)
+	h := handler.NewAPIKey(mockKeys, testLogger())
+
+	newRequest := func(userID string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/user/keys", nil)
+		if userID != "" {
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, userID))
+		}
+		return req
+	}
+
+	t.Run("issues a key", func(t *testing.T) {
+		mockKeys.EXPECT().Issue(gomock.Any(), "user-1").Return("the-key", nil)
+
+		rec := httptest.NewRecorder()
+		h.Issue(rec, newRequest("user-1"))
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+		require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		require.JSONEq(t, `{"key":"the-key"}`, rec.Body.String())
+	})
+
+	t.Run("unauthorized without user", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.Issue(rec, newRequest(""))
+
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("storage error", func(t *testing.T) {
+		mockKeys.EXPECT().Issue(gomock.Any(), "user-1").Return("", errors.New("db down"))
+
+		rec := httptest.NewRecorder()
+		h.Issue(rec, newRequest("user-1"))
+
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
@@ -0,0 +1,186 @@
+// Package handler provides HTTP handlers for campaigns, named groups of short
+// URLs whose clicks are reported together.
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// CampaignsHandler handles HTTP requests creating campaigns, moving short URLs
+// to them and reporting their clicks.
+type CampaignsHandler struct {
+	service service.URLServiceIface // Service for handling URL operations.
+	logger  *zap.Logger             // Logger for logging events.
+}
+
+// NewCampaigns creates a new CampaignsHandler instance with the provided URL service and logger.
+func NewCampaigns(s service.URLServiceIface, l *zap.Logger) *CampaignsHandler {
+	return &CampaignsHandler{
+		service: s,
+		logger:  l,
+	}
+}
+
+// toCampaignResponse converts a stored campaign to its HTTP representation.
+func toCampaignResponse(c storage.Campaign) models.CampaignResponse {
+	return models.CampaignResponse{ID: c.ID, Name: c.Name, CreatedAt: c.CreatedAt}
+}
+
+// Create handles POST requests creating a campaign of the current user. It
+// returns the campaign in a 201 Created response, 400 Bad Request if the name
+// is not allowed and 409 Conflict if the user has a campaign with that name.
+func (h *CampaignsHandler) Create(res http.ResponseWriter, req *http.Request) {
+	// Extract the user ID from the request context.
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse the incoming JSON request body.
+	var request models.CreateCampaignRequest
+	if err := decodeJSONBody(res, req, &request); err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(res, mr.msg, mr.status)
+			return
+		}
+		logger.FromContext(req.Context(), h.logger).Error(err.Error())
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	c, err := h.service.CreateCampaign(req.Context(), userID, request.Name)
+	switch {
+	case errors.Is(err, service.ErrInvalidCampaignName):
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, storage.ErrConflict):
+		http.Error(res, "A campaign with this name exists", http.StatusConflict)
+		return
+	case err != nil:
+		logger.FromContext(req.Context(), h.logger).Error("cannot create campaign", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusCreated, toCampaignResponse(*c))
+}
+
+// List handles GET requests returning the campaigns of the current user,
+// oldest first.
+func (h *CampaignsHandler) List(res http.ResponseWriter, req *http.Request) {
+	// Extract the user ID from the request context.
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	campaigns, err := h.service.GetCampaigns(req.Context(), userID)
+	if err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot list campaigns", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	response := make([]models.CampaignResponse, 0, len(campaigns))
+	for _, c := range campaigns {
+		response = append(response, toCampaignResponse(c))
+	}
+	writeJSON(res, http.StatusOK, response)
+}
+
+// Stats handles GET requests for the clicks of the short URLs of a campaign of
+// the current user in a time window, given by the window query parameter as a
+// Go duration such as "24h" and as long as clicks are kept by default. It
+// returns 400 Bad Request for windows that are not positive or longer than the
+// clicks are kept, and 404 Not Found if the user has no such campaign.
+func (h *CampaignsHandler) Stats(res http.ResponseWriter, req *http.Request) {
+	// Extract the user ID from the request context.
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	window := storage.ClickRetention
+	if v := req.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > storage.ClickRetention {
+			http.Error(res, "Invalid window parameter", http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+
+	stats, err := h.service.CampaignStats(req.Context(), userID, chi.URLParam(req, "id"), window)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(res, "Campaign not found", http.StatusNotFound)
+		return
+	case err != nil:
+		logger.FromContext(req.Context(), h.logger).Error("cannot get campaign stats", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, stats)
+}
+
+// Assign handles PUT requests moving a short URL of the current user to one
+// of their campaigns; an empty campaign ID removes it from its campaign. It
+// returns 204 No Content on success, 400 Bad Request if the user has no such
+// campaign, 404 Not Found if the user does not own the short URL and 410 Gone
+// if it was deleted.
+func (h *CampaignsHandler) Assign(res http.ResponseWriter, req *http.Request) {
+	// Extract the user ID from the request context.
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse the incoming JSON request body.
+	var request models.SetCampaignRequest
+	if err := decodeJSONBody(res, req, &request); err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(res, mr.msg, mr.status)
+			return
+		}
+		logger.FromContext(req.Context(), h.logger).Error(err.Error())
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	err := h.service.SetURLCampaign(req.Context(), chi.URLParam(req, "short"), userID, request.CampaignID)
+	switch {
+	case errors.Is(err, service.ErrUnknownCampaign):
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(res, "URL not found", http.StatusNotFound)
+		return
+	case errors.Is(err, storage.ErrDeleted):
+		http.Error(res, "URL is gone", http.StatusGone)
+		return
+	case err != nil:
+		logger.FromContext(req.Context(), h.logger).Error("cannot set URL campaign", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
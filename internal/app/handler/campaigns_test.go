@@ -0,0 +1,144 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/handler"
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// campaignRequest builds a request of the current user with the given route
+// parameter.
+func campaignRequest(method, target, body, userID, param, value string) *http.Request {
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rctx := chi.NewRouteContext()
+	if param != "" {
+		rctx.URLParams.Add(param, value)
+	}
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	if userID != "" {
+		ctx = context.WithValue(ctx, middleware.UserIDKey, userID)
+	}
+	return req.WithContext(ctx)
+}
+
+func TestCampaignsHandler_Create(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewCampaigns(mockService, testLogger())
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mockService.EXPECT().CreateCampaign(gomock.Any(), "user-1", "Launch").
+		Return(&storage.Campaign{ID: "c1", UserID: "user-1", Name: "Launch", CreatedAt: createdAt}, nil)
+	rec := httptest.NewRecorder()
+	h.Create(rec, campaignRequest(http.MethodPost, "/api/v1/user/campaigns", `{"name":"Launch"}`, "user-1", "", ""))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var c models.CampaignResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &c))
+	assert.Equal(t, models.CampaignResponse{ID: "c1", Name: "Launch", CreatedAt: createdAt}, c)
+
+	for err, want := range map[error]int{
+		service.ErrInvalidCampaignName: http.StatusBadRequest,
+		storage.ErrConflict:            http.StatusConflict,
+	} {
+		mockService.EXPECT().CreateCampaign(gomock.Any(), "user-1", "Launch").Return(nil, err)
+		rec := httptest.NewRecorder()
+		h.Create(rec, campaignRequest(http.MethodPost, "/api/v1/user/campaigns", `{"name":"Launch"}`, "user-1", "", ""))
+		assert.Equal(t, want, rec.Code, err.Error())
+	}
+
+	rec = httptest.NewRecorder()
+	h.Create(rec, campaignRequest(http.MethodPost, "/api/v1/user/campaigns", `{"name":"Launch"}`, "", "", ""))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestCampaignsHandler_List(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewCampaigns(mockService, testLogger())
+
+	mockService.EXPECT().GetCampaigns(gomock.Any(), "user-1").Return([]storage.Campaign{}, nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, campaignRequest(http.MethodGet, "/api/v1/user/campaigns", "", "user-1", "", ""))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+}
+
+func TestCampaignsHandler_Stats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewCampaigns(mockService, testLogger())
+
+	stats := &models.CampaignStats{ID: "c1", Name: "Launch", URLs: 2, Clicks: 3, Top: []models.TopURL{}}
+	mockService.EXPECT().CampaignStats(gomock.Any(), "user-1", "c1", storage.ClickRetention).Return(stats, nil)
+	rec := httptest.NewRecorder()
+	h.Stats(rec, campaignRequest(http.MethodGet, "/api/v1/user/campaigns/c1/stats", "", "user-1", "id", "c1"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"id":"c1","name":"Launch","urls":2,"clicks":3,"top":[]}`, rec.Body.String())
+
+	mockService.EXPECT().CampaignStats(gomock.Any(), "user-1", "c2", 24*time.Hour).Return(nil, storage.ErrNotFound)
+	rec = httptest.NewRecorder()
+	h.Stats(rec, campaignRequest(http.MethodGet, "/api/v1/user/campaigns/c2/stats?window=24h", "", "user-1", "id", "c2"))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	for _, window := range []string{"soon", "-1h", "1000h"} {
+		rec = httptest.NewRecorder()
+		h.Stats(rec, campaignRequest(http.MethodGet, "/api/v1/user/campaigns/c1/stats?window="+window, "", "user-1", "id", "c1"))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, window)
+	}
+}
+
+func TestCampaignsHandler_Assign(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewCampaigns(mockService, testLogger())
+
+	tests := []struct {
+		name   string
+		userID string
+		err    error
+		want   int
+	}{
+		{name: "assigned", userID: "user-1", want: http.StatusNoContent},
+		{name: "unknown campaign", userID: "user-1", err: service.ErrUnknownCampaign, want: http.StatusBadRequest},
+		{name: "not owner", userID: "user-1", err: storage.ErrNotFound, want: http.StatusNotFound},
+		{name: "deleted", userID: "user-1", err: storage.ErrDeleted, want: http.StatusGone},
+		{name: "unauthenticated", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.userID != "" {
+				mockService.EXPECT().SetURLCampaign(gomock.Any(), "abc", tt.userID, "c1").Return(tt.err)
+			}
+
+			rec := httptest.NewRecorder()
+			h.Assign(rec, campaignRequest(http.MethodPut, "/api/v1/user/urls/abc/campaign", `{"campaign_id":"c1"}`, tt.userID, "short", "abc"))
+			require.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
@@ -5,11 +5,12 @@ import (
 	"context"
 	"errors"
 	"net/http"
-	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
 	"github.com/atinyakov/go-url-shortener/internal/middleware"
 	"github.com/atinyakov/go-url-shortener/internal/storage"
 )
@@ -37,9 +38,8 @@ var СallDeleteURLRecords = func(service service.URLServiceIface, ctx context.Co
 // It reads a list of shortened URLs from the request body and deletes them asynchronously.
 // A 202 Accepted status is returned if the URLs are queued for deletion, or an error is returned if there are issues with the request.
 func (h *DeleteHandler) DeleteBatch(res http.ResponseWriter, req *http.Request) {
-	// Create a context with timeout.
-	ctx, cancel := context.WithTimeout(req.Context(), 3*time.Second)
-	defer cancel()
+	// The request deadline is set by the timeout middleware.
+	ctx := req.Context()
 
 	// Extract the user ID from the request context.
 	val := req.Context().Value(middleware.UserIDKey)
@@ -59,7 +59,7 @@ func (h *DeleteHandler) DeleteBatch(res http.ResponseWriter, req *http.Request)
 			http.Error(res, mr.msg, mr.status)
 			return
 		}
-		h.logger.Error(err.Error())
+		logger.FromContext(req.Context(), h.logger).Error(err.Error())
 		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -70,6 +70,9 @@ func (h *DeleteHandler) DeleteBatch(res http.ResponseWriter, req *http.Request)
 		toDelete = append(toDelete, storage.URLRecord{Short: url, UserID: userID})
 	}
 
+	// Report the URLs to the audit log before the deletion leaves the request.
+	audit.AddTargets(ctx, request...)
+
 	// Perform the deletion asynchronously.
 	СallDeleteURLRecords(h.service, ctx, toDelete)
 
@@ -0,0 +1,63 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/handler"
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestDetailsHandler_Set(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewDetails(mockService, testLogger())
+
+	request := func(userID, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/user/urls/abc/details", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("short", "abc")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		if userID != "" {
+			ctx = context.WithValue(ctx, middleware.UserIDKey, userID)
+		}
+		return req.WithContext(ctx)
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		err    error
+		want   int
+	}{
+		{name: "set", userID: "user-1", want: http.StatusNoContent},
+		{name: "not owner", userID: "user-1", err: storage.ErrNotFound, want: http.StatusNotFound},
+		{name: "deleted", userID: "user-1", err: storage.ErrDeleted, want: http.StatusGone},
+		{name: "too long", userID: "user-1", err: service.ErrInvalidDetails, want: http.StatusBadRequest},
+		{name: "unauthenticated", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.userID != "" {
+				mockService.EXPECT().SetURLDetails(gomock.Any(), "abc", tt.userID, "Docs", "Team handbook").Return(tt.err)
+			}
+
+			rec := httptest.NewRecorder()
+			h.Set(rec, request(tt.userID, `{"title":"Docs","description":"Team handbook"}`))
+			require.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
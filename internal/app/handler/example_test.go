@@ -31,12 +31,13 @@ func setupMockService(t *testing.T) (*mocks.MockURLServiceIface, service.URLServ
 	zapLogger := logger.New().Log
 	urlService, _ := service.NewURL(context.Background(), mockStorage, resolver, zapLogger, "http://localhost:8080")
 	mockService := mocks.NewMockURLServiceIface(ctrl)
+	mockService.EXPECT().Tenant(gomock.Any()).Return(service.Tenant{BaseURL: "http://localhost:8080", RedirectCode: service.DefaultRedirectCode}).AnyTimes()
 	return mockService, urlService
 }
 
 func TestPlainBody(t *testing.T) {
 	mockService, _ := setupMockService(t)
-	h := handler.NewPost("http://localhost:8080", mockService, logger.New().Log)
+	h := handler.NewPost(mockService, logger.New().Log)
 
 	reqBody := "https://example.com"
 	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(reqBody))
@@ -53,7 +54,7 @@ func TestPlainBody(t *testing.T) {
 
 func TestHandlePostJSON(t *testing.T) {
 	mockService, _ := setupMockService(t)
-	h := handler.NewPost("http://localhost:8080", mockService, logger.New().Log)
+	h := handler.NewPost(mockService, logger.New().Log)
 
 	payload := models.Request{URL: "https://example.com"}
 	data, _ := json.Marshal(payload)
@@ -82,6 +83,7 @@ func setupMockGetService(t *testing.T) (*mocks.MockURLServiceIface, service.URLS
 
 	urlService, _ := service.NewURL(context.Background(), mockStorage, resolver, log, "http://localhost:8080")
 	mockService := mocks.NewMockURLServiceIface(ctrl)
+	mockService.EXPECT().Tenant(gomock.Any()).Return(service.Tenant{BaseURL: "http://localhost:8080", RedirectCode: service.DefaultRedirectCode}).AnyTimes()
 
 	return mockService, urlService
 }
@@ -92,6 +94,7 @@ func TestByShort(t *testing.T) {
 
 	r := &storage.URLRecord{Original: "http://example.com", Short: "abc123", IsDeleted: false}
 	mockService.EXPECT().GetURLByShort(gomock.Any(), "abc123").Return(r, nil)
+	mockService.EXPECT().RecordRedirect(gomock.Any(), r)
 
 	req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
 	req = muxRequestWithParam(req, "url", "abc123")
@@ -125,7 +128,7 @@ func TestURLsByUserID(t *testing.T) {
 	h := handler.NewGet(mockService, zap.NewNop())
 
 	urls := []models.ByIDRequest{{ShortURL: "short", OriginalURL: "original"}}
-	mockService.EXPECT().GetURLByUserID(gomock.Any(), "test-user").Return(&urls, nil)
+	mockService.EXPECT().GetURLByUserID(gomock.Any(), "test-user", storage.ListOptions{}).Return(&urls, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
 	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "test-user"))
@@ -2,66 +2,236 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 
 	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
 	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
 )
 
+// maxExpandBatch is the largest number of short URLs expanded by one request.
+const maxExpandBatch = 1000
+
 // GetHandler handles GET requests related to URL resolution and user-specific URLs.
 type GetHandler struct {
 	service service.URLServiceIface // Service for handling URL operations.
 	logger  *zap.Logger             // Logger for logging events.
+	pages   *Pages                  // Pages shown instead of a redirect.
 }
 
-// NewGet creates a new GetHandler instance with provided URL service and logger.
+// NewGet creates a new GetHandler instance with provided URL service and logger,
+// showing the built-in pages instead of a redirect.
 func NewGet(s service.URLServiceIface, l *zap.Logger) *GetHandler {
+	return NewGetWithPages(s, l, DefaultPages())
+}
+
+// NewGetWithPages is like NewGet but shows the given pages instead of a redirect.
+func NewGetWithPages(s service.URLServiceIface, l *zap.Logger, p *Pages) *GetHandler {
 	return &GetHandler{
 		service: s,
 		logger:  l,
+		pages:   p,
 	}
 }
 
 // ByShort handles GET requests for URL resolution using a shortened URL.
-// It returns a 302 redirect to the original URL if found, or a 404 error if not found.
+// It redirects to the original URL with the redirect code of the tenant of
+// the request, 307 by default, if found, or a 404 page if not
+// found or its activation window has not started yet. Deleted and expired URLs
+// get 410 Gone, as do URLs flagged as threats, with a warning page instead of
+// a redirect. Pages are rendered as HTML, or as JSON for clients preferring it.
+//
+// Password protected URLs redirect only once the password is given in the
+// X-Link-Password header or submitted with the password form, which is shown
+// with 401 Unauthorized when the password is missing and 403 Forbidden when it
+// is wrong. The form is posted to the same path and answered with 303 See Other.
+// Clients sending too many wrong passwords get 429 Too Many Requests for a minute.
 func (h *GetHandler) ByShort(res http.ResponseWriter, req *http.Request) {
-	// Create a context with timeout.
-	ctx, cancel := context.WithTimeout(req.Context(), 3*time.Second)
-	defer cancel()
+	// The request deadline is set by the timeout middleware.
+	ctx := req.Context()
 
 	// Extract the shortened URL from the request parameters.
 	shortURL := chi.URLParam(req, "url")
-	h.logger.Info("Got URL from request params:", zap.String("shortURL", shortURL))
+	logger.FromContext(req.Context(), h.logger).Info("Got URL from request params:", zap.String("shortURL", shortURL))
+	tenant := h.service.Tenant(ctx)
+	data := PageData{ShortURL: shortURL, Brand: tenant.Branding}
 
 	// Resolve the original URL using the service.
 	r, err := h.service.GetURLByShort(ctx, shortURL)
+	if errors.Is(err, service.ErrNotYetActive) {
+		data.NotYetActive = true
+		h.pages.Render(res, req, http.StatusNotFound, PageNotFound, "URL is not active yet", data)
+		return
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		h.pages.Render(res, req, http.StatusNotFound, PageNotFound, "URL not found", data)
+		return
+	}
+	if errors.Is(err, storage.ErrDeleted) {
+		h.pages.Render(res, req, http.StatusGone, PageGone, "URL is gone", data)
+		return
+	}
 	if err != nil {
-		http.Error(res, "URL not found", http.StatusNotFound)
+		logger.FromContext(ctx, h.logger).Error("cannot resolve short URL", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	// Do not redirect to URLs flagged as malicious.
+	if r.Flagged != "" {
+		data.URL, data.Threat = r.Original, r.Flagged
+		h.pages.Render(res, req, http.StatusGone, PageFlagged, "URL was flagged as a threat", data)
 		return
 	}
 
 	// Check if the URL is marked as deleted.
 	if r.IsDeleted {
-		res.WriteHeader(http.StatusGone)
+		h.pages.Render(res, req, http.StatusGone, PageGone, "URL is gone", data)
+		return
+	}
+
+	// Ask for the password of protected URLs until the right one is given.
+	if r.PasswordHash != "" {
+		password := req.Header.Get(PasswordHeader)
+		if req.Method == http.MethodPost {
+			password = req.PostFormValue("password")
+		}
+		if password == "" {
+			h.pages.Render(res, req, http.StatusUnauthorized, PagePassword, "password required", data)
+			return
+		}
+		switch err := h.service.UnlockURL(ctx, r, password); {
+		case errors.Is(err, service.ErrTooManyPasswordAttempts):
+			data.TooManyAttempts = true
+			res.Header().Set("Retry-After", "60")
+			h.pages.Render(res, req, http.StatusTooManyRequests, PagePassword, "too many wrong passwords", data)
+			return
+		case err != nil:
+			data.WrongPassword = true
+			h.pages.Render(res, req, http.StatusForbidden, PagePassword, "wrong password", data)
+			return
+		}
 	}
 
-	// Set the Location header to the original URL and send a temporary redirect response.
+	// Only the redirects let through count as clicks
+	h.service.RecordRedirect(ctx, r)
+
+	// Set the Location header to the original URL and redirect with the code of
+	// the tenant; the password form gets a See Other, so the browser follows it with a GET.
 	res.Header().Set("Location", r.Original)
-	res.WriteHeader(http.StatusTemporaryRedirect)
+	if req.Method == http.MethodPost {
+		res.WriteHeader(http.StatusSeeOther)
+		return
+	}
+	res.WriteHeader(tenant.RedirectCode)
+}
+
+// Metadata handles GET requests for the metadata of a short URL, such as its
+// original URL, creation and expiration times and whether it was deleted,
+// without following it. The original URL of a password protected URL is shown
+// to its owner only. It returns 404 Not Found for unknown short URLs and for
+// short URLs of other users that are private or not active yet.
+func (h *GetHandler) Metadata(res http.ResponseWriter, req *http.Request) {
+	short := chi.URLParam(req, "short")
+	userID, _ := req.Context().Value(middleware.UserIDKey).(string)
+
+	m, err := h.service.GetURLMetadata(req.Context(), short, userID)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		writeJSON(res, http.StatusNotFound, models.LinkErrorResponse{Error: "URL not found", ShortURL: short})
+		return
+	case err != nil:
+		logger.FromContext(req.Context(), h.logger).Error("cannot get URL metadata", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	res.Header().Set("Cache-Control", "no-store")
+	writeJSON(res, http.StatusOK, m)
+}
+
+// Stats handles GET requests for the number of clicks of a short URL in the
+// last window query parameter, a duration of at most ClickRetention which is
+// also the default. Like Metadata, it can be shared with anyone unless the
+// owner made the short URL private. It returns 400 Bad Request for invalid
+// windows and 404 Not Found for unknown short URLs and for short URLs of
+// other users that are private or not active yet.
+func (h *GetHandler) Stats(res http.ResponseWriter, req *http.Request) {
+	short := chi.URLParam(req, "short")
+	userID, _ := req.Context().Value(middleware.UserIDKey).(string)
+
+	window := storage.ClickRetention
+	if v := req.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > storage.ClickRetention {
+			http.Error(res, "Invalid window parameter", http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+
+	stats, err := h.service.GetURLStats(req.Context(), short, userID, window)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		writeJSON(res, http.StatusNotFound, models.LinkErrorResponse{Error: "URL not found", ShortURL: short})
+		return
+	case err != nil:
+		logger.FromContext(req.Context(), h.logger).Error("cannot get URL stats", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	res.Header().Set("Cache-Control", "no-store")
+	writeJSON(res, http.StatusOK, stats)
+}
+
+// ExpandBatch handles POST requests resolving a JSON array of short URL
+// identifiers to their original URLs without following them, so that clients
+// do not have to follow every redirect. The results are returned in the order
+// of the request; short URLs that would not redirect get an error instead of
+// the original URL. It returns 400 Bad Request for more than 1000 short URLs.
+func (h *GetHandler) ExpandBatch(res http.ResponseWriter, req *http.Request) {
+	var shorts []string
+	if err := decodeJSONBody(res, req, &shorts); err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(res, mr.msg, mr.status)
+			return
+		}
+		logger.FromContext(req.Context(), h.logger).Error("cannot decode request body", zap.Error(err))
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if len(shorts) > maxExpandBatch {
+		http.Error(res, "at most 1000 short URLs can be expanded at once", http.StatusBadRequest)
+		return
+	}
+
+	expanded, err := h.service.ExpandURLs(req.Context(), shorts)
+	if err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot expand URLs", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	res.Header().Set("Cache-Control", "no-store")
+	writeJSON(res, http.StatusOK, expanded)
 }
 
 // PingDB handles GET requests for checking the health of the database connection.
 // It returns a 200 status if the database is reachable, or 500 if there is an error.
 func (h *GetHandler) PingDB(res http.ResponseWriter, req *http.Request) {
-	// Create a context with timeout.
-	ctx, cancel := context.WithTimeout(req.Context(), 3*time.Second)
-	defer cancel()
+	// The request deadline is set by the timeout middleware.
+	ctx := req.Context()
 
 	// Check the database connection status.
 	if err := h.service.PingContext(ctx); err != nil {
@@ -72,12 +242,52 @@ func (h *GetHandler) PingDB(res http.ResponseWriter, req *http.Request) {
 	res.WriteHeader(http.StatusOK)
 }
 
-// URLsByUserID handles GET requests for retrieving all URLs associated with a specific user.
+// parseListOptions builds storage.ListOptions from the limit, offset, sort, q, tag,
+// campaign and pinned_first query parameters of the request.
+func parseListOptions(req *http.Request) (storage.ListOptions, error) {
+	query := req.URL.Query()
+	opts := storage.ListOptions{
+		Sort:     query.Get("sort"),
+		Query:    query.Get("q"),
+		Tag:      query.Get("tag"),
+		Campaign: query.Get("campaign"),
+	}
+
+	var err error
+	if v := query.Get("limit"); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil {
+			return opts, storage.ErrInvalidListOptions
+		}
+	}
+
+	if v := query.Get("offset"); v != "" {
+		if opts.Offset, err = strconv.Atoi(v); err != nil {
+			return opts, storage.ErrInvalidListOptions
+		}
+	}
+
+	if v := query.Get("pinned_first"); v != "" {
+		if opts.PinnedFirst, err = strconv.ParseBool(v); err != nil {
+			return opts, storage.ErrInvalidListOptions
+		}
+	}
+
+	return opts, opts.Validate()
+}
+
+// URLsByUserID handles GET requests for retrieving the URLs associated with a specific user.
+// Responses carry an ETag, and a request whose If-None-Match header matches the
+// current listing receives 304 Not Modified without a body. The listing can be
+// paginated with the limit and offset query parameters, ordered with sort
+// (original_url or short_url, prefixed with "-" for descending order), with
+// pinned URLs first if pinned_first is true, and filtered with q,
+// whitespace-separated terms that must all occur in the original URL, title or
+// description, with tag, a tag the URLs must have, and with campaign, the ID of
+// their campaign.
 // It returns a list of URLs in JSON format or a 204 No Content status if no URLs are found.
 func (h *GetHandler) URLsByUserID(res http.ResponseWriter, req *http.Request) {
-	// Create a context with timeout.
-	ctx, cancel := context.WithTimeout(req.Context(), 3*time.Second)
-	defer cancel()
+	// The request deadline is set by the timeout middleware.
+	ctx := req.Context()
 
 	// Extract user ID from request context.
 	val := req.Context().Value(middleware.UserIDKey)
@@ -87,8 +297,15 @@ func (h *GetHandler) URLsByUserID(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Parse pagination, ordering and filtering parameters.
+	opts, err := parseListOptions(req)
+	if err != nil {
+		http.Error(res, "Invalid limit, offset or sort parameter", http.StatusBadRequest)
+		return
+	}
+
 	// Retrieve the URLs associated with the user from the service.
-	urls, err := h.service.GetURLByUserID(ctx, userID)
+	urls, err := h.service.GetURLByUserID(ctx, userID, opts)
 	if err != nil {
 		http.Error(res, "URL not found", http.StatusNotFound)
 		return
@@ -104,7 +321,18 @@ func (h *GetHandler) URLsByUserID(res http.ResponseWriter, req *http.Request) {
 	response, err := json.Marshal(*urls)
 	if err != nil {
 		res.WriteHeader(http.StatusInternalServerError)
+		return
 	}
+
+	// Let polling clients revalidate the listing instead of downloading it again.
+	etag := computeETag(response)
+	res.Header().Set("ETag", etag)
+	res.Header().Set("Cache-Control", "private, no-cache")
+	if etagMatches(req.Header.Get("If-None-Match"), etag) {
+		res.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	res.Header().Set("Content-Type", "application/json")
 	res.WriteHeader(http.StatusOK)
 
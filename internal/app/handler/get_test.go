@@ -3,15 +3,19 @@ package handler
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	"go.uber.org/zap"
 
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
 	"github.com/atinyakov/go-url-shortener/internal/middleware"
 	"github.com/atinyakov/go-url-shortener/internal/mocks"
 	"github.com/atinyakov/go-url-shortener/internal/models"
@@ -19,6 +23,7 @@ import (
 )
 
 func createTestHandler(mockService *mocks.MockURLServiceIface) *GetHandler {
+	mockService.EXPECT().Tenant(gomock.Any()).Return(service.Tenant{BaseURL: "http://localhost:8080", RedirectCode: service.DefaultRedirectCode}).AnyTimes()
 	logger, _ := zap.NewDevelopment()
 	return NewGet(mockService, logger)
 }
@@ -36,6 +41,7 @@ func TestByShort(t *testing.T) {
 		mockReturn   *storage.URLRecord
 		mockErr      error
 		expectedCode int
+		retryAfter   string
 	}{
 		{
 			name:         "Valid URL",
@@ -48,9 +54,24 @@ func TestByShort(t *testing.T) {
 			name:         "URL not found",
 			shortURL:     "unknown",
 			mockReturn:   nil,
-			mockErr:      errors.New("not found"),
+			mockErr:      storage.ErrNotFound,
 			expectedCode: http.StatusNotFound,
 		},
+		{
+			name:         "Storage error",
+			shortURL:     "broken",
+			mockReturn:   nil,
+			mockErr:      errors.New("db down"),
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:         "Storage unavailable",
+			shortURL:     "down",
+			mockReturn:   nil,
+			mockErr:      fmt.Errorf("lookup: %w", &storage.UnavailableError{RetryAfter: 2500 * time.Millisecond}),
+			expectedCode: http.StatusServiceUnavailable,
+			retryAfter:   "3",
+		},
 		{
 			name:         "Deleted URL",
 			shortURL:     "deleted",
@@ -58,11 +79,28 @@ func TestByShort(t *testing.T) {
 			mockErr:      nil,
 			expectedCode: http.StatusGone,
 		},
+		{
+			name:         "Not active yet",
+			shortURL:     "upcoming",
+			mockReturn:   nil,
+			mockErr:      service.ErrNotYetActive,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Flagged URL",
+			shortURL:     "flagged",
+			mockReturn:   &storage.URLRecord{Original: "https://evil.example", Flagged: "MALWARE"},
+			mockErr:      nil,
+			expectedCode: http.StatusGone,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockService.EXPECT().GetURLByShort(gomock.Any(), tt.shortURL).Return(tt.mockReturn, tt.mockErr)
+			if tt.expectedCode == http.StatusTemporaryRedirect {
+				mockService.EXPECT().RecordRedirect(gomock.Any(), tt.mockReturn)
+			}
 
 			req := httptest.NewRequest(http.MethodGet, "/"+tt.shortURL, nil)
 			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Context{
@@ -80,8 +118,154 @@ func TestByShort(t *testing.T) {
 			defer resp.Body.Close()
 
 			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+			assert.Equal(t, tt.retryAfter, resp.Header.Get("Retry-After"))
+			if tt.mockReturn != nil && tt.mockReturn.Flagged != "" {
+				assert.Empty(t, resp.Header.Get("Location"))
+				assert.Contains(t, w.Body.String(), "MALWARE")
+			}
+		})
+	}
+}
+
+func TestByShort_Tenant(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	handler := NewGet(mockService, zap.NewNop())
+
+	tenant := service.Tenant{
+		BaseURL:      "https://go.example.com",
+		RedirectCode: http.StatusMovedPermanently,
+		Branding:     service.Branding{Name: "Example Links", LogoURL: "https://go.example.com/logo.png"},
+	}
+	mockService.EXPECT().Tenant(gomock.Any()).Return(tenant).AnyTimes()
+	mockService.EXPECT().GetURLByShort(gomock.Any(), "abc").Return(&storage.URLRecord{Original: "https://example.com"}, nil)
+	mockService.EXPECT().RecordRedirect(gomock.Any(), &storage.URLRecord{Original: "https://example.com"})
+	mockService.EXPECT().GetURLByShort(gomock.Any(), "gone").Return(&storage.URLRecord{Original: "https://example.com", IsDeleted: true}, nil)
+
+	serve := func(short string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/"+short, nil)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Context{
+			URLParams: chi.RouteParams{Keys: []string{"url"}, Values: []string{short}},
+		}))
+		w := httptest.NewRecorder()
+		handler.ByShort(w, req)
+		return w
+	}
+
+	// Redirects use the code of the tenant
+	w := serve("abc")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Location"))
+
+	// Pages show the branding of the tenant
+	w = serve("gone")
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), "<strong>Example Links</strong>")
+	assert.Contains(t, w.Body.String(), `<img src="https://go.example.com/logo.png"`)
+}
+
+func TestMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	handler := createTestHandler(mockService)
+
+	meta := &models.URLMetadata{ShortURL: "http://localhost:8080/abc", OriginalURL: "https://example.com", Owned: true}
+	mockService.EXPECT().GetURLMetadata(gomock.Any(), "abc", "user-1").Return(meta, nil)
+	mockService.EXPECT().GetURLMetadata(gomock.Any(), "missing", "user-1").Return(nil, storage.ErrNotFound)
+
+	serve := func(short string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/urls/"+short, nil)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Context{
+			URLParams: chi.RouteParams{Keys: []string{"short"}, Values: []string{short}},
 		})
+		ctx = context.WithValue(ctx, middleware.UserIDKey, "user-1")
+		w := httptest.NewRecorder()
+		handler.Metadata(w, req.WithContext(ctx))
+		return w
 	}
+
+	// The metadata is returned without a redirect
+	w := serve("abc")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.JSONEq(t, `{"short_url":"http://localhost:8080/abc","original_url":"https://example.com","is_deleted":false,"password_protected":false,"owned":true}`, w.Body.String())
+
+	w = serve("missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"URL not found","short_url":"missing"}`, w.Body.String())
+}
+
+func TestStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	handler := createTestHandler(mockService)
+
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	stats := &models.URLStats{ShortURL: "http://localhost:8080/abc", Clicks: 3, Since: since}
+	mockService.EXPECT().GetURLStats(gomock.Any(), "abc", "", storage.ClickRetention).Return(stats, nil)
+	mockService.EXPECT().GetURLStats(gomock.Any(), "abc", "", time.Hour).Return(stats, nil)
+	mockService.EXPECT().GetURLStats(gomock.Any(), "private", "", storage.ClickRetention).Return(nil, storage.ErrNotFound)
+
+	serve := func(short, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/urls/"+short+"/stats"+query, nil)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Context{
+			URLParams: chi.RouteParams{Keys: []string{"short"}, Values: []string{short}},
+		})
+		w := httptest.NewRecorder()
+		handler.Stats(w, req.WithContext(ctx))
+		return w
+	}
+
+	// Public stats are served without a user
+	w := serve("abc", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"short_url":"http://localhost:8080/abc","clicks":3,"since":"2024-05-01T12:00:00Z"}`, w.Body.String())
+	assert.Equal(t, http.StatusOK, serve("abc", "?window=1h").Code)
+
+	w = serve("private", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"URL not found","short_url":"private"}`, w.Body.String())
+
+	for _, window := range []string{"soon", "-1h", "800h"} {
+		assert.Equal(t, http.StatusBadRequest, serve("abc", "?window="+window).Code, window)
+	}
+}
+
+func TestExpandBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	handler := createTestHandler(mockService)
+
+	mockService.EXPECT().ExpandURLs(gomock.Any(), []string{"abc", "missing"}).Return([]models.ExpandBatchResponse{
+		{ShortURL: "abc", OriginalURL: "https://example.com"},
+		{ShortURL: "missing", Error: service.ExpandNotFound},
+	}, nil)
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/expand/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ExpandBatch(w, req)
+		return w
+	}
+
+	w := serve(`["abc", "missing"]`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"short_url":"abc","original_url":"https://example.com"},{"short_url":"missing","error":"URL not found"}]`, w.Body.String())
+
+	w = serve(`{"short": "abc"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(`[` + strings.Repeat(`"abc",`, maxExpandBatch) + `"abc"]`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
 func TestPingDB(t *testing.T) {
@@ -132,7 +316,7 @@ func TestURLsByUserID(t *testing.T) {
 		urls := &[]models.ByIDRequest{
 			{OriginalURL: "https://example.com", ShortURL: "abc123"},
 		}
-		mockService.EXPECT().GetURLByUserID(gomock.Any(), userID).Return(urls, nil)
+		mockService.EXPECT().GetURLByUserID(gomock.Any(), userID, storage.ListOptions{}).Return(urls, nil)
 
 		ctx := context.WithValue(context.Background(), middleware.UserIDKey, userID)
 		req := httptest.NewRequest(http.MethodGet, "/user-urls", nil).WithContext(ctx)
@@ -146,7 +330,7 @@ func TestURLsByUserID(t *testing.T) {
 		userID := "user123"
 
 		urls := &[]models.ByIDRequest{}
-		mockService.EXPECT().GetURLByUserID(gomock.Any(), userID).Return(urls, nil)
+		mockService.EXPECT().GetURLByUserID(gomock.Any(), userID, storage.ListOptions{}).Return(urls, nil)
 
 		ctx := context.WithValue(context.Background(), middleware.UserIDKey, userID)
 		req := httptest.NewRequest(http.MethodGet, "/user-urls", nil).WithContext(ctx)
@@ -156,6 +340,65 @@ func TestURLsByUserID(t *testing.T) {
 		assert.Equal(t, http.StatusNoContent, w.Code)
 	})
 
+	t.Run("Pagination parameters", func(t *testing.T) {
+		userID := "user123"
+		urls := &[]models.ByIDRequest{
+			{OriginalURL: "https://example.com", ShortURL: "abc123"},
+		}
+		opts := storage.ListOptions{Limit: 5, Offset: 10, Sort: "-original_url", Query: "example", PinnedFirst: true}
+		mockService.EXPECT().GetURLByUserID(gomock.Any(), userID, opts).Return(urls, nil)
+
+		ctx := context.WithValue(context.Background(), middleware.UserIDKey, userID)
+		req := httptest.NewRequest(http.MethodGet, "/user-urls?limit=5&offset=10&sort=-original_url&q=example&pinned_first=true", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		handler.URLsByUserID(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Invalid pagination parameters", func(t *testing.T) {
+		for _, query := range []string{"limit=abc", "offset=-1", "sort=user_id", "pinned_first=maybe"} {
+			ctx := context.WithValue(context.Background(), middleware.UserIDKey, "user123")
+			req := httptest.NewRequest(http.MethodGet, "/user-urls?"+query, nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+
+			handler.URLsByUserID(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+	t.Run("Conditional request", func(t *testing.T) {
+		userID := "user123"
+		urls := &[]models.ByIDRequest{
+			{OriginalURL: "https://example.com", ShortURL: "abc123"},
+		}
+		mockService.EXPECT().GetURLByUserID(gomock.Any(), userID, storage.ListOptions{}).Return(urls, nil).Times(3)
+
+		ctx := context.WithValue(context.Background(), middleware.UserIDKey, userID)
+		req := httptest.NewRequest(http.MethodGet, "/user-urls", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		handler.URLsByUserID(w, req)
+		etag := w.Header().Get("ETag")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, etag)
+
+		req = httptest.NewRequest(http.MethodGet, "/user-urls", nil).WithContext(ctx)
+		req.Header.Set("If-None-Match", `"stale", W/`+etag)
+		w = httptest.NewRecorder()
+
+		handler.URLsByUserID(w, req)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+
+		req = httptest.NewRequest(http.MethodGet, "/user-urls", nil).WithContext(ctx)
+		req.Header.Set("If-None-Match", `"stale"`)
+		w = httptest.NewRecorder()
+
+		handler.URLsByUserID(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
 	t.Run("Unauthorized", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/user-urls", nil)
 		w := httptest.NewRecorder()
@@ -166,7 +409,7 @@ func TestURLsByUserID(t *testing.T) {
 
 	t.Run("Service error", func(t *testing.T) {
 		userID := "user123"
-		mockService.EXPECT().GetURLByUserID(gomock.Any(), userID).Return(nil, errors.New("fail"))
+		mockService.EXPECT().GetURLByUserID(gomock.Any(), userID, storage.ListOptions{}).Return(nil, errors.New("fail"))
 
 		ctx := context.WithValue(context.Background(), middleware.UserIDKey, userID)
 		req := httptest.NewRequest(http.MethodGet, "/user-urls", nil).WithContext(ctx)
@@ -23,7 +23,7 @@ func BenchmarkPostPlainBody(b *testing.B) {
 
 	var URLService, _ = service.NewURL(context.Background(), mockStorage, resolver, zapLogger, "http://localhost:8080")
 	// Инициализация обработчика
-	postHandler := NewPost("http://localhost", URLService, zapLogger)
+	postHandler := NewPost(URLService, zapLogger)
 
 	// Создаём запрос
 	body := []byte("https://example.com")
@@ -51,7 +51,7 @@ func BenchmarkPostJSON(b *testing.B) {
 	var URLService, _ = service.NewURL(context.Background(), mockStorage, resolver, zapLogger, "http://localhost:8080")
 
 	// Инициализация обработчика
-	postHandler := NewPost("http://localhost", URLService, zapLogger)
+	postHandler := NewPost(URLService, zapLogger)
 
 	// Создаём запрос с JSON телом
 	reqBody := models.Request{URL: "https://example.com"}
@@ -0,0 +1,45 @@
+// Package handler provides HTTP handlers for liveness and readiness probes.
+package handler
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+)
+
+// HealthHandler handles liveness and readiness probes.
+type HealthHandler struct {
+	health service.HealthIface // Readiness checks of the dependencies.
+	logger *zap.Logger         // Logger for logging events.
+}
+
+// NewHealth creates a new HealthHandler instance with the provided readiness checks and logger.
+func NewHealth(h service.HealthIface, l *zap.Logger) *HealthHandler {
+	return &HealthHandler{
+		health: h,
+		logger: l,
+	}
+}
+
+// Live handles liveness probes. It always returns 200, since answering proves
+// that the process is running and able to serve HTTP.
+func (h *HealthHandler) Live(res http.ResponseWriter, req *http.Request) {
+	writeJSON(res, http.StatusOK, models.HealthResponse{Status: service.StatusOK})
+}
+
+// Ready handles readiness probes. It returns 200 with the status of every
+// dependency when all of them are healthy, or 503 otherwise.
+func (h *HealthHandler) Ready(res http.ResponseWriter, req *http.Request) {
+	status, ok := h.health.Ready(req.Context())
+	if !ok {
+		logger.FromContext(req.Context(), h.logger).Warn("readiness check failed", zap.Any("checks", status.Checks))
+		writeJSON(res, http.StatusServiceUnavailable, status)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, status)
+}
@@ -0,0 +1,56 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/handler"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+)
+
+func TestHealthHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockHealth := mocks.NewMockHealthIface(ctrl)
+	h := handler.NewHealth(mockHealth, testLogger())
+
+	t.Run("live", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.Live(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		mockHealth.EXPECT().Ready(gomock.Any()).Return(&models.HealthResponse{
+			Status: "ok",
+			Checks: map[string]models.DependencyStatus{"storage": {Status: "ok"}},
+		}, true)
+
+		rec := httptest.NewRecorder()
+		h.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"status":"ok","checks":{"storage":{"status":"ok"}}}`, rec.Body.String())
+	})
+
+	t.Run("not ready", func(t *testing.T) {
+		mockHealth.EXPECT().Ready(gomock.Any()).Return(&models.HealthResponse{
+			Status: "fail",
+			Checks: map[string]models.DependencyStatus{"storage": {Status: "fail", Error: "connection refused"}},
+		}, false)
+
+		rec := httptest.NewRecorder()
+		h.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		require.JSONEq(t, `{"status":"fail","checks":{"storage":{"status":"fail","error":"connection refused"}}}`, rec.Body.String())
+	})
+}
@@ -6,12 +6,21 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
 )
 
 // malformedRequest represents an error with a malformed HTTP request.
@@ -90,3 +99,96 @@ func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) err
 
 	return nil
 }
+
+// computeETag returns a strong entity tag derived from the response body.
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header value matches etag.
+// Weak validators are compared by their opaque tag, as required for GET requests.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeQuotaError reports an exceeded URL quota with 403 Forbidden and the quota details.
+func writeQuotaError(res http.ResponseWriter, qe *service.QuotaError) {
+	writeJSON(res, http.StatusForbidden, models.QuotaExceededResponse{
+		Error:     "url quota exceeded",
+		Limit:     qe.Limit,
+		Used:      qe.Used,
+		Requested: qe.Requested,
+	})
+}
+
+// writeBannedError reports a creator banned for creating URLs too fast with 429
+// Too Many Requests, and Retry-After with the seconds until the ban ends.
+func writeBannedError(res http.ResponseWriter, be *service.BannedError) {
+	seconds := int(math.Ceil(time.Until(be.Until).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	res.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeJSON(res, http.StatusTooManyRequests, models.CreationBannedResponse{
+		Error: "url creation is banned",
+		Kind:  be.Kind,
+		Until: be.Until,
+	})
+}
+
+// writeBlockedDomainError reports a URL pointing to a blocked domain with 422 Unprocessable Entity.
+func writeBlockedDomainError(res http.ResponseWriter, be *service.BlockedDomainError) {
+	writeJSON(res, http.StatusUnprocessableEntity, models.RejectedURLResponse{
+		Error:  "domain is blocked",
+		URL:    be.URL,
+		Domain: be.Domain,
+	})
+}
+
+// writeDomainNotAllowedError reports a URL outside the allowlist with 422 Unprocessable Entity.
+func writeDomainNotAllowedError(res http.ResponseWriter, ne *service.DomainNotAllowedError) {
+	writeJSON(res, http.StatusUnprocessableEntity, models.RejectedURLResponse{
+		Error:  "domain is not allowed",
+		URL:    ne.URL,
+		Domain: ne.Domain,
+	})
+}
+
+// writeServerError reports an error of the service that the client cannot fix:
+// with 503 Service Unavailable and a Retry-After header while the storage is
+// unavailable, with 500 Internal Server Error otherwise.
+func writeServerError(res http.ResponseWriter, err error) {
+	var ue *storage.UnavailableError
+	if errors.As(err, &ue) {
+		// Retry-After is in whole seconds, rounded up so clients do not retry too early
+		res.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(max(ue.RetryAfter, time.Second).Seconds()))))
+		http.Error(res, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// writeJSON marshals v and writes it with the given status code.
+func writeJSON(res http.ResponseWriter, status int, v any) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	res.Write(resp)
+}
@@ -0,0 +1,129 @@
+// Package handler provides the pages shown instead of a redirect, such as the
+// page of an unknown short URL, which operators can brand with their own templates.
+package handler
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+)
+
+// Names of the page templates. A template directory may override any of them
+// with a file of the same name and may add further templates, such as a shared
+// layout, in other *.html files. The built-in pages share the "head" and
+// "header" templates of brand.html, showing the branding of the tenant.
+const (
+	PageNotFound = "not_found.html" // Unknown short URL, or one that is not active yet
+	PageGone     = "gone.html"      // Deleted or expired short URL
+	PageFlagged  = "flagged.html"   // Short URL disabled because it points to a threat
+	PagePassword = "password.html"  // Password form of a protected short URL
+)
+
+// defaultPages are the built-in page templates.
+//
+//go:embed pages/*.html
+var defaultPages embed.FS
+
+// PageData is passed to the page templates.
+type PageData struct {
+	ShortURL        string // Short URL identifier of the request
+	URL             string // Original URL, set on the flagged page only
+	Threat          string // Threat the URL was flagged for
+	NotYetActive    bool   // Whether the not found page is shown for a URL that is not active yet
+	WrongPassword   bool   // Whether the password form is shown again after a wrong password
+	TooManyAttempts bool   // Whether the password form is shown after too many wrong passwords
+
+	Brand service.Branding // Branding of the tenant of the request
+}
+
+// Pages renders the pages shown instead of a redirect, as HTML or, to clients
+// that prefer it, as JSON.
+type Pages struct {
+	templates *template.Template
+}
+
+// DefaultPages returns the built-in pages.
+func DefaultPages() *Pages {
+	return &Pages{templates: template.Must(template.ParseFS(defaultPages, "pages/*.html"))}
+}
+
+// LoadPages returns the built-in pages overridden by the *.html templates of
+// dir; an empty dir returns the built-in pages.
+func LoadPages(dir string) (*Pages, error) {
+	p := DefaultPages()
+	if dir == "" {
+		return p, nil
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return p, nil
+	}
+	if _, err := p.templates.ParseFiles(files...); err != nil {
+		return nil, fmt.Errorf("parse page templates: %w", err)
+	}
+	return p, nil
+}
+
+// Render writes the page with the given status. Clients preferring JSON in
+// their Accept header get a models.LinkErrorResponse with message instead.
+func (p *Pages) Render(res http.ResponseWriter, req *http.Request, status int, name string, message string, data PageData) {
+	// Pages are about the current state of the short URL, which can change at any time
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Add("Vary", "Accept")
+
+	if prefersJSON(req.Header.Get("Accept")) {
+		writeJSON(res, status, models.LinkErrorResponse{Error: message, ShortURL: data.ShortURL, Threat: data.Threat})
+		return
+	}
+
+	// Render into a buffer first, so a broken template still gets the right status
+	var body bytes.Buffer
+	if err := p.templates.ExecuteTemplate(&body, name, data); err != nil {
+		http.Error(res, message, status)
+		return
+	}
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.WriteHeader(status)
+	_, _ = body.WriteTo(res)
+}
+
+// prefersJSON reports whether an Accept header ranks application/json above
+// text/html. Wildcards are ignored, so HTML is served unless JSON is asked for.
+func prefersJSON(accept string) bool {
+	jsonQ, htmlQ := 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		case "text/html":
+			htmlQ = max(htmlQ, q)
+		}
+	}
+	return jsonQ > htmlQ
+}
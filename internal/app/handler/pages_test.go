@@ -0,0 +1,72 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/handler"
+)
+
+func TestPages_Render(t *testing.T) {
+	pages := handler.DefaultPages()
+
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+	}{
+		{name: "no accept header", accept: "", contentType: "text/html; charset=utf-8"},
+		{name: "browser", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", contentType: "text/html; charset=utf-8"},
+		{name: "wildcard", accept: "*/*", contentType: "text/html; charset=utf-8"},
+		{name: "json client", accept: "application/json, text/plain, */*", contentType: "application/json"},
+		{name: "html preferred over json", accept: "application/json;q=0.5, text/html", contentType: "text/html; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			pages.Render(rec, req, http.StatusNotFound, handler.PageNotFound, "URL not found", handler.PageData{ShortURL: "abc"})
+
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+			if tt.contentType == "application/json" {
+				assert.JSONEq(t, `{"error":"URL not found","short_url":"abc"}`, rec.Body.String())
+			} else {
+				assert.Contains(t, rec.Body.String(), "<code>abc</code>")
+			}
+		})
+	}
+}
+
+func TestLoadPages(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "layout.html"), []byte(`{{define "footer"}}<footer>ACME links</footer>{{end}}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, handler.PageGone), []byte(`<h1>{{.ShortURL}} is gone</h1>{{template "footer"}}`), 0600))
+
+	pages, err := handler.LoadPages(dir)
+	require.NoError(t, err)
+
+	render := func(name string) string {
+		rec := httptest.NewRecorder()
+		pages.Render(rec, httptest.NewRequest(http.MethodGet, "/abc", nil), http.StatusGone, name, "URL is gone", handler.PageData{ShortURL: "abc"})
+		return rec.Body.String()
+	}
+
+	// Overridden pages use the operator's templates, the others stay built in
+	assert.Equal(t, `<h1>abc is gone</h1><footer>ACME links</footer>`, render(handler.PageGone))
+	assert.Contains(t, render(handler.PageNotFound), "Link not found")
+
+	_, err = handler.LoadPages(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, handler.PageNotFound), []byte(`{{.Broken`), 0600))
+	_, err = handler.LoadPages(dir)
+	assert.Error(t, err)
+}
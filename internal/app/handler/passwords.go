@@ -0,0 +1,79 @@
+// Package handler provides HTTP handlers for protecting short URLs with passwords.
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// PasswordHeader carries the password of a protected short URL, for clients
+// that cannot submit the password form.
+const PasswordHeader = "X-Link-Password"
+
+// PasswordHandler handles HTTP requests setting the passwords of short URLs.
+type PasswordHandler struct {
+	service service.URLServiceIface // Service for handling URL operations.
+	logger  *zap.Logger             // Logger for logging events.
+}
+
+// NewPassword creates a new PasswordHandler instance with the provided URL service and logger.
+func NewPassword(s service.URLServiceIface, l *zap.Logger) *PasswordHandler {
+	return &PasswordHandler{
+		service: s,
+		logger:  l,
+	}
+}
+
+// Set handles PUT requests protecting a short URL of the current user with a
+// password; an empty password removes the protection. It returns 204 No Content
+// on success, 404 Not Found if the user does not own the short URL and 410 Gone
+// if it was deleted.
+func (h *PasswordHandler) Set(res http.ResponseWriter, req *http.Request) {
+	// Extract the user ID from the request context.
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse the incoming JSON request body.
+	var request models.SetPasswordRequest
+	if err := decodeJSONBody(res, req, &request); err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(res, mr.msg, mr.status)
+			return
+		}
+		logger.FromContext(req.Context(), h.logger).Error(err.Error())
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	err := h.service.SetURLPassword(req.Context(), chi.URLParam(req, "short"), userID, request.Password)
+	switch {
+	case errors.Is(err, service.ErrInvalidPassword):
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(res, "URL not found", http.StatusNotFound)
+		return
+	case errors.Is(err, storage.ErrDeleted):
+		http.Error(res, "URL is gone", http.StatusGone)
+		return
+	case err != nil:
+		logger.FromContext(req.Context(), h.logger).Error("cannot set URL password", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
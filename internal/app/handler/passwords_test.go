@@ -0,0 +1,157 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/handler"
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestPasswordHandler_Set(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewPassword(mockService, testLogger())
+
+	request := func(userID, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/user/urls/abc/password", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("short", "abc")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		if userID != "" {
+			ctx = context.WithValue(ctx, middleware.UserIDKey, userID)
+		}
+		return req.WithContext(ctx)
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		err    error
+		want   int
+	}{
+		{name: "set", userID: "user-1", want: http.StatusNoContent},
+		{name: "not owner", userID: "user-1", err: storage.ErrNotFound, want: http.StatusNotFound},
+		{name: "deleted", userID: "user-1", err: storage.ErrDeleted, want: http.StatusGone},
+		{name: "too long", userID: "user-1", err: service.ErrInvalidPassword, want: http.StatusBadRequest},
+		{name: "unauthenticated", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.userID != "" {
+				mockService.EXPECT().SetURLPassword(gomock.Any(), "abc", tt.userID, "s3cret").Return(tt.err)
+			}
+
+			rec := httptest.NewRecorder()
+			h.Set(rec, request(tt.userID, `{"password":"s3cret"}`))
+			require.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestByShort_PasswordProtected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	record := &storage.URLRecord{Short: "abc", Original: "https://example.com", PasswordHash: string(hash)}
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	mockService.EXPECT().GetURLByShort(gomock.Any(), "abc").Return(record, nil).AnyTimes()
+	mockService.EXPECT().Tenant(gomock.Any()).Return(service.Tenant{BaseURL: "http://localhost:8080", RedirectCode: service.DefaultRedirectCode}).AnyTimes()
+	clicks := 0
+	mockService.EXPECT().RecordRedirect(gomock.Any(), record).Do(func(context.Context, *storage.URLRecord) { clicks++ }).AnyTimes()
+	mockService.EXPECT().UnlockURL(gomock.Any(), record, gomock.Any()).DoAndReturn(func(_ context.Context, r *storage.URLRecord, password string) error {
+		if !service.CheckURLPassword(r, password) {
+			return service.ErrWrongPassword
+		}
+		return nil
+	}).AnyTimes()
+	h := handler.NewGet(mockService, testLogger())
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("url", "abc")
+		rec := httptest.NewRecorder()
+		h.ByShort(rec, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+		return rec
+	}
+	form := func(password string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/abc", strings.NewReader(url.Values{"password": {password}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	// Without a password the form is shown
+	rec := serve(httptest.NewRequest(http.MethodGet, "/abc", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Contains(t, rec.Body.String(), `<form method="post">`)
+	require.Empty(t, rec.Header().Get("Location"))
+
+	// The password can be sent in a header; wrong ones are not counted as clicks
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.Header.Set(handler.PasswordHeader, "wrong")
+	require.Equal(t, http.StatusForbidden, serve(req).Code)
+	require.Zero(t, clicks)
+
+	req = httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.Header.Set(handler.PasswordHeader, "s3cret")
+	rec = serve(req)
+	require.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+	require.Equal(t, "https://example.com", rec.Header().Get("Location"))
+	require.Equal(t, 1, clicks)
+
+	// Or submitted with the form
+	rec = serve(form("wrong"))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "incorrect")
+	require.Equal(t, 1, clicks)
+
+	rec = serve(form("s3cret"))
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	require.Equal(t, "https://example.com", rec.Header().Get("Location"))
+	require.Equal(t, 2, clicks)
+}
+
+func TestByShort_PasswordAttemptsLimited(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	record := &storage.URLRecord{Short: "abc", Original: "https://example.com", PasswordHash: "hash"}
+
+	// No click is recorded for refused attempts
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	mockService.EXPECT().GetURLByShort(gomock.Any(), "abc").Return(record, nil)
+	mockService.EXPECT().Tenant(gomock.Any()).Return(service.Tenant{BaseURL: "http://localhost:8080", RedirectCode: service.DefaultRedirectCode})
+	mockService.EXPECT().UnlockURL(gomock.Any(), record, "s3cret").Return(service.ErrTooManyPasswordAttempts)
+	h := handler.NewGet(mockService, testLogger())
+
+	req := httptest.NewRequest(http.MethodPost, "/abc", strings.NewReader(url.Values{"password": {"s3cret"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("url", "abc")
+	rec := httptest.NewRecorder()
+	h.ByShort(rec, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "60", rec.Header().Get("Retry-After"))
+	require.Contains(t, rec.Body.String(), "Too many incorrect passwords")
+	require.Empty(t, rec.Header().Get("Location"))
+}
@@ -0,0 +1,69 @@
+// Package handler provides HTTP handlers for pinning short URLs to the top of
+// the listing of their owner.
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// PinsHandler handles HTTP requests pinning and unpinning short URLs.
+type PinsHandler struct {
+	service service.URLServiceIface // Service for handling URL operations.
+	logger  *zap.Logger             // Logger for logging events.
+}
+
+// NewPins creates a new PinsHandler instance with the provided URL service and logger.
+func NewPins(s service.URLServiceIface, l *zap.Logger) *PinsHandler {
+	return &PinsHandler{
+		service: s,
+		logger:  l,
+	}
+}
+
+// Pin handles PUT requests pinning a short URL of the current user. It returns
+// 204 No Content on success, 404 Not Found if the user does not own the short
+// URL and 410 Gone if it was deleted.
+func (h *PinsHandler) Pin(res http.ResponseWriter, req *http.Request) {
+	h.set(res, req, true)
+}
+
+// Unpin handles DELETE requests unpinning a short URL of the current user,
+// with the responses of Pin.
+func (h *PinsHandler) Unpin(res http.ResponseWriter, req *http.Request) {
+	h.set(res, req, false)
+}
+
+// set pins or unpins the short URL of the request.
+func (h *PinsHandler) set(res http.ResponseWriter, req *http.Request, pinned bool) {
+	// Extract the user ID from the request context.
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.service.SetURLPinned(req.Context(), chi.URLParam(req, "short"), userID, pinned)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(res, "URL not found", http.StatusNotFound)
+		return
+	case errors.Is(err, storage.ErrDeleted):
+		http.Error(res, "URL is gone", http.StatusGone)
+		return
+	case err != nil:
+		logger.FromContext(req.Context(), h.logger).Error("cannot pin URL", zap.Error(err), zap.Bool("pinned", pinned))
+		writeServerError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,65 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/handler"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestPinsHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewPins(mockService, testLogger())
+
+	request := func(method, userID string) *http.Request {
+		req := httptest.NewRequest(method, "/api/v1/user/urls/abc/pin", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("short", "abc")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		if userID != "" {
+			ctx = context.WithValue(ctx, middleware.UserIDKey, userID)
+		}
+		return req.WithContext(ctx)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		userID string
+		err    error
+		want   int
+	}{
+		{name: "pin", method: http.MethodPut, userID: "user-1", want: http.StatusNoContent},
+		{name: "unpin", method: http.MethodDelete, userID: "user-1", want: http.StatusNoContent},
+		{name: "not owner", method: http.MethodPut, userID: "user-1", err: storage.ErrNotFound, want: http.StatusNotFound},
+		{name: "deleted", method: http.MethodDelete, userID: "user-1", err: storage.ErrDeleted, want: http.StatusGone},
+		{name: "unauthenticated", method: http.MethodPut, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handle := h.Pin
+			if tt.method == http.MethodDelete {
+				handle = h.Unpin
+			}
+			if tt.userID != "" {
+				mockService.EXPECT().SetURLPinned(gomock.Any(), "abc", tt.userID, tt.method == http.MethodPut).Return(tt.err)
+			}
+
+			rec := httptest.NewRecorder()
+			handle(rec, request(tt.method, tt.userID))
+			require.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
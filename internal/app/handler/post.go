@@ -2,33 +2,30 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"io"
-	"log"
 	"net/http"
-	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
 	"github.com/atinyakov/go-url-shortener/internal/middleware"
 	"github.com/atinyakov/go-url-shortener/internal/models"
-	"github.com/atinyakov/go-url-shortener/internal/repository"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
 )
 
 // PostHandler handles POST requests for URL shortening.
 type PostHandler struct {
-	baseURL    string                  // Base URL for the service.
 	urlService service.URLServiceIface // Service for handling URL operations.
 	logger     *zap.Logger             // Logger for logging events.
 }
 
-// NewPost creates a new PostHandler instance with provided base URL, URL service, and logger.
-func NewPost(baseURL string, s service.URLServiceIface, l *zap.Logger) *PostHandler {
+// NewPost creates a new PostHandler instance with provided URL service and logger.
+// Short URLs are returned with the base URL of the tenant of the request.
+func NewPost(s service.URLServiceIface, l *zap.Logger) *PostHandler {
 	return &PostHandler{
-		baseURL:    baseURL,
 		urlService: s,
 		logger:     l,
 	}
@@ -37,17 +34,6 @@ func NewPost(baseURL string, s service.URLServiceIface, l *zap.Logger) *PostHand
 // PlainBody handles POST requests for URL shortening when the body contains a plain URL string.
 // The URL will be shortened and returned in the response body.
 func (h *PostHandler) PlainBody(res http.ResponseWriter, req *http.Request) {
-	// Create a context with timeout.
-	ctx, cancel := context.WithTimeout(req.Context(), 3*time.Second)
-	defer cancel()
-
-	// Extract user ID from request context.
-	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
-	if !ok {
-		http.Error(res, "User ID not found", http.StatusInternalServerError)
-		return
-	}
-
 	// Read the request body.
 	body, err := io.ReadAll(req.Body)
 	defer req.Body.Close()
@@ -56,30 +42,83 @@ func (h *PostHandler) PlainBody(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	h.shortenPlain(res, req, string(body))
+}
+
+// Query handles GET requests shortening the URL of the url query parameter,
+// for integrations that can only follow links, such as bookmarklets and shell
+// one-liners. The short URL is returned as plain text, with the responses of
+// PlainBody; a missing url parameter gets 400 Bad Request. The route only
+// accepts API keys, and the responses must not be cached since every request
+// may create a URL.
+func (h *PostHandler) Query(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Cache-Control", "no-store")
+
+	originalURL := req.URL.Query().Get("url")
+	if originalURL == "" {
+		http.Error(res, "url query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	h.shortenPlain(res, req, originalURL)
+}
+
+// shortenPlain shortens originalURL for the user of the request and writes
+// the short URL as plain text.
+func (h *PostHandler) shortenPlain(res http.ResponseWriter, req *http.Request, originalURL string) {
+	// The request deadline is set by the timeout middleware.
+	ctx := req.Context()
+
+	// Extract user ID from request context.
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(res, "User ID not found", http.StatusInternalServerError)
+		return
+	}
+
 	// Call the service to create a new shortened URL.
-	originalURL := string(body)
 	r, err := h.urlService.CreateURLRecord(ctx, originalURL, userID)
 
 	// Handle different errors and responses.
 	if err != nil {
-		if errors.Is(err, repository.ErrConflict) {
-			h.logger.Info("URL already exists", zap.String("originalURL", originalURL))
+		var bne *service.BannedError
+		if errors.As(err, &bne) {
+			writeBannedError(res, bne)
+			return
+		}
+		var qe *service.QuotaError
+		if errors.As(err, &qe) {
+			writeQuotaError(res, qe)
+			return
+		}
+		var be *service.BlockedDomainError
+		if errors.As(err, &be) {
+			writeBlockedDomainError(res, be)
+			return
+		}
+		var ne *service.DomainNotAllowedError
+		if errors.As(err, &ne) {
+			writeDomainNotAllowedError(res, ne)
+			return
+		}
+		if errors.Is(err, storage.ErrConflict) {
+			logger.FromContext(req.Context(), h.logger).Info("URL already exists", zap.String("originalURL", originalURL))
 			res.WriteHeader(http.StatusConflict)
-			_, resErr := res.Write([]byte(h.baseURL + "/" + r.Short))
+			_, resErr := res.Write([]byte(h.urlService.Tenant(req.Context()).ShortURL(r.Short)))
 			if resErr != nil {
 				res.WriteHeader(http.StatusInternalServerError)
 			}
 			return
 		}
-		h.logger.Info("unable to insert row:", zap.String("error", err.Error()))
-		res.WriteHeader(http.StatusInternalServerError)
+		logger.FromContext(req.Context(), h.logger).Info("unable to insert row:", zap.String("error", err.Error()))
+		writeServerError(res, err)
 		return
 	}
 
 	// Return the shortened URL in the response.
 	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	res.WriteHeader(http.StatusCreated)
-	_, resErr := res.Write([]byte(h.baseURL + "/" + r.Short))
+	_, resErr := res.Write([]byte(h.urlService.Tenant(req.Context()).ShortURL(r.Short)))
 	if resErr != nil {
 		res.WriteHeader(http.StatusInternalServerError)
 	}
@@ -88,9 +127,8 @@ func (h *PostHandler) PlainBody(res http.ResponseWriter, req *http.Request) {
 // HandlePostJSON handles POST requests for URL shortening when the body contains JSON data.
 // The request expects a JSON body with a URL to shorten, and the response will contain the shortened URL in JSON format.
 func (h *PostHandler) HandlePostJSON(res http.ResponseWriter, req *http.Request) {
-	// Create a context with timeout.
-	ctx, cancel := context.WithTimeout(req.Context(), 3*time.Second)
-	defer cancel()
+	// The request deadline is set by the timeout middleware.
+	ctx := req.Context()
 
 	// Extract user ID from request context.
 	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
@@ -108,20 +146,57 @@ func (h *PostHandler) HandlePostJSON(res http.ResponseWriter, req *http.Request)
 			http.Error(res, mr.msg, mr.status)
 			return
 		}
-		log.Print(err.Error())
+		logger.FromContext(req.Context(), h.logger).Error("cannot decode request body", zap.Error(err))
 		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
-	// Create a new shortened URL using the URL service.
-	r, err := h.urlService.CreateURLRecord(ctx, request.URL, userID)
+	// Create a new shortened URL using the URL service, limited to the
+	// activation window and with the tags, title and description of the request
+	// if it has them.
+	var r *storage.URLRecord
+	if request.NotBefore != nil || request.NotAfter != nil || len(request.Tags) > 0 || request.Title != "" || request.Description != "" {
+		r, err = h.urlService.CreateURLRecordWithOptions(ctx, request.URL, userID, service.CreateOptions{
+			NotBefore:   request.NotBefore,
+			NotAfter:    request.NotAfter,
+			Tags:        request.Tags,
+			Title:       request.Title,
+			Description: request.Description,
+		})
+	} else {
+		r, err = h.urlService.CreateURLRecord(ctx, request.URL, userID)
+	}
 
 	// Handle errors and send appropriate responses.
+	if errors.Is(err, service.ErrInvalidWindow) || errors.Is(err, service.ErrInvalidTags) || errors.Is(err, service.ErrInvalidDetails) {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
 	res.Header().Set("Content-Type", "application/json")
 	if err != nil {
-		if errors.Is(err, repository.ErrConflict) {
-			h.logger.Info("URL already exists", zap.String("originalURL", request.URL))
-			response, _ := json.Marshal(models.Response{Result: h.baseURL + "/" + r.Short})
+		var bne *service.BannedError
+		if errors.As(err, &bne) {
+			writeBannedError(res, bne)
+			return
+		}
+		var qe *service.QuotaError
+		if errors.As(err, &qe) {
+			writeQuotaError(res, qe)
+			return
+		}
+		var be *service.BlockedDomainError
+		if errors.As(err, &be) {
+			writeBlockedDomainError(res, be)
+			return
+		}
+		var ne *service.DomainNotAllowedError
+		if errors.As(err, &ne) {
+			writeDomainNotAllowedError(res, ne)
+			return
+		}
+		if errors.Is(err, storage.ErrConflict) {
+			logger.FromContext(req.Context(), h.logger).Info("URL already exists", zap.String("originalURL", request.URL))
+			response, _ := json.Marshal(models.Response{Result: h.urlService.Tenant(req.Context()).ShortURL(r.Short)})
 			res.WriteHeader(http.StatusConflict)
 			_, writeErr := res.Write(response)
 			if writeErr != nil {
@@ -130,14 +205,14 @@ func (h *PostHandler) HandlePostJSON(res http.ResponseWriter, req *http.Request)
 			}
 			return
 		}
-		h.logger.Info("unable to insert row:", zap.String("error", err.Error()))
-		res.WriteHeader(http.StatusInternalServerError)
+		logger.FromContext(req.Context(), h.logger).Info("unable to insert row:", zap.String("error", err.Error()))
+		writeServerError(res, err)
 		return
 	}
 
 	// Return the shortened URL in JSON format.
 	res.WriteHeader(http.StatusCreated)
-	response, _ := json.Marshal(models.Response{Result: h.baseURL + "/" + r.Short})
+	response, _ := json.Marshal(models.Response{Result: h.urlService.Tenant(req.Context()).ShortURL(r.Short)})
 	_, writeErr := res.Write(response)
 	if writeErr != nil {
 		res.WriteHeader(http.StatusInternalServerError)
@@ -145,11 +220,11 @@ func (h *PostHandler) HandlePostJSON(res http.ResponseWriter, req *http.Request)
 }
 
 // HandleBatch handles POST requests for batch URL shortening.
-// The request expects a JSON body with a list of URLs to shorten, and the response will contain a JSON array with shortened URLs.
+// The request expects a JSON body with a list of URLs to shorten, and the response will contain a JSON array with shortened URLs
+// and the status of each of them.
 func (h *PostHandler) HandleBatch(res http.ResponseWriter, req *http.Request) {
-	// Create a context with timeout.
-	ctx, cancel := context.WithTimeout(req.Context(), 3*time.Second)
-	defer cancel()
+	// The request deadline is set by the timeout middleware.
+	ctx := req.Context()
 
 	// Extract user ID from request context.
 	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
@@ -167,33 +242,65 @@ func (h *PostHandler) HandleBatch(res http.ResponseWriter, req *http.Request) {
 			http.Error(res, mr.msg, mr.status)
 			return
 		}
-		log.Print(err.Error())
+		logger.FromContext(req.Context(), h.logger).Error("cannot decode request body", zap.Error(err))
 		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
 	// Call the service to create a batch of shortened URLs.
 	batchUrls, err := h.urlService.CreateURLRecords(ctx, urlsR, userID)
-	if errors.Is(err, repository.ErrConflict) {
-		h.logger.Info(err.Error())
+	if errors.Is(err, service.ErrInvalidWindow) || errors.Is(err, service.ErrInvalidTags) || errors.Is(err, service.ErrInvalidDetails) {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var bne *service.BannedError
+	if errors.As(err, &bne) {
+		writeBannedError(res, bne)
+		return
+	}
+	var qe *service.QuotaError
+	if errors.As(err, &qe) {
+		writeQuotaError(res, qe)
+		return
+	}
+	var be *service.BlockedDomainError
+	if errors.As(err, &be) {
+		writeBlockedDomainError(res, be)
+		return
+	}
+	var ne *service.DomainNotAllowedError
+	if errors.As(err, &ne) {
+		writeDomainNotAllowedError(res, ne)
+		return
+	}
+
+	if errors.Is(err, storage.ErrConflict) {
+		logger.FromContext(req.Context(), h.logger).Info(err.Error())
 		res.WriteHeader(http.StatusConflict)
 		return
 	}
 
 	if err != nil {
-		h.logger.Info(err.Error())
-		res.WriteHeader(http.StatusInternalServerError)
+		logger.FromContext(req.Context(), h.logger).Info(err.Error())
+		writeServerError(res, err)
 		return
 	}
 
-	// Return the list of shortened URLs in JSON format.
+	// Return the list of shortened URLs in JSON format, with 200 if none of them was created.
 	response, err := json.Marshal(&batchUrls)
 	if err != nil {
 		res.WriteHeader(http.StatusInternalServerError)
 	}
 
+	status := http.StatusOK
+	for _, b := range *batchUrls {
+		if b.Status == models.BatchStatusCreated {
+			status = http.StatusCreated
+			break
+		}
+	}
 	res.Header().Set("Content-Type", "application/json")
-	res.WriteHeader(http.StatusCreated)
+	res.WriteHeader(status)
 	_, writeErr := res.Write(response)
 	if writeErr != nil {
 		res.WriteHeader(http.StatusInternalServerError)
@@ -5,11 +5,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	"go.uber.org/zap"
 
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
 	"github.com/atinyakov/go-url-shortener/internal/middleware"
 	"github.com/atinyakov/go-url-shortener/internal/mocks"
 	"github.com/atinyakov/go-url-shortener/internal/storage"
@@ -23,12 +25,12 @@ func newTestPostHandler(t *testing.T) *PostHandler {
 
 	// Mock the service
 	mockService := mocks.NewMockURLServiceIface(ctrl)
+	mockService.EXPECT().Tenant(gomock.Any()).Return(service.Tenant{BaseURL: "http://localhost:8080"}).AnyTimes()
 
 	// Create PostHandler
 	return &PostHandler{
 		urlService: mockService,
 		logger:     logger,
-		baseURL:    "http://localhost:8080",
 	}
 }
 func TestPlainBody(t *testing.T) {
@@ -54,6 +56,34 @@ func TestPlainBody(t *testing.T) {
 			expectedCode:            http.StatusCreated,
 			expectedBody:            "http://localhost:8080/abc123",
 		},
+		{
+			name:            "Quota exceeded",
+			body:            "https://example.com",
+			mockCreateError: &service.QuotaError{Limit: 2, Used: 2, Requested: 1},
+			expectedCode:    http.StatusForbidden,
+			expectedBody:    `{"error":"url quota exceeded","limit":2,"used":2,"requested":1}`,
+		},
+		{
+			name:            "Creation banned",
+			body:            "https://example.com",
+			mockCreateError: &service.BannedError{Kind: service.BanKindUser, Subject: "test-user-id", Until: time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)},
+			expectedCode:    http.StatusTooManyRequests,
+			expectedBody:    `{"error":"url creation is banned","kind":"user","until":"2100-01-01T00:00:00Z"}`,
+		},
+		{
+			name:            "Blocked domain",
+			body:            "https://example.com",
+			mockCreateError: &service.BlockedDomainError{URL: "https://example.com", Domain: "example.com"},
+			expectedCode:    http.StatusUnprocessableEntity,
+			expectedBody:    `{"error":"domain is blocked","url":"https://example.com","domain":"example.com"}`,
+		},
+		{
+			name:            "Domain not allowed",
+			body:            "https://example.com",
+			mockCreateError: &service.DomainNotAllowedError{URL: "https://example.com", Domain: "example.com"},
+			expectedCode:    http.StatusUnprocessableEntity,
+			expectedBody:    `{"error":"domain is not allowed","url":"https://example.com","domain":"example.com"}`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -80,6 +110,70 @@ func TestPlainBody(t *testing.T) {
 	}
 }
 
+func TestQuery(t *testing.T) {
+	handler := newTestPostHandler(t)
+
+	tests := []struct {
+		name            string
+		query           string
+		url             string
+		mockResponse    *storage.URLRecord
+		mockCreateError error
+		expectedCode    int
+		expectedBody    string
+	}{
+		{
+			name:         "Valid URL",
+			query:        "?url=https%3A%2F%2Fexample.com%2Fa%3Fb%3Dc",
+			url:          "https://example.com/a?b=c",
+			mockResponse: &storage.URLRecord{Short: "abc123"},
+			expectedCode: http.StatusCreated,
+			expectedBody: "http://localhost:8080/abc123",
+		},
+		{
+			name:            "Already shortened",
+			query:           "?url=https://example.com",
+			url:             "https://example.com",
+			mockResponse:    &storage.URLRecord{Short: "abc123"},
+			mockCreateError: storage.ErrConflict,
+			expectedCode:    http.StatusConflict,
+			expectedBody:    "http://localhost:8080/abc123",
+		},
+		{
+			name:            "Quota exceeded",
+			query:           "?url=https://example.com",
+			url:             "https://example.com",
+			mockCreateError: &service.QuotaError{Limit: 2, Used: 2, Requested: 1},
+			expectedCode:    http.StatusForbidden,
+			expectedBody:    `{"error":"url quota exceeded","limit":2,"used":2,"requested":1}`,
+		},
+		{
+			name:         "Missing URL",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "url query parameter is required\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.url != "" {
+				handler.urlService.(*mocks.MockURLServiceIface).EXPECT().
+					CreateURLRecord(gomock.Any(), tt.url, "test-user-id").
+					Return(tt.mockResponse, tt.mockCreateError).
+					Times(1)
+			}
+
+			req := middleware.InjectUserID(httptest.NewRequest(http.MethodGet, "/api/shorten"+tt.query, nil), "test-user-id")
+			rr := httptest.NewRecorder()
+			handler.Query(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+			assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+		})
+	}
+}
+
 func TestHandlePostJSON(t *testing.T) {
 	handler := newTestPostHandler(t)
 
@@ -99,6 +193,20 @@ func TestHandlePostJSON(t *testing.T) {
 			expectedCode: http.StatusCreated,
 			expectedBody: `{"result":"http://localhost:8080/abc123"}`,
 		},
+		{
+			name:         "Quota exceeded",
+			body:         `{"url":"https://example.com"}`,
+			mockError:    &service.QuotaError{Limit: 2, Used: 2, Requested: 1},
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"error":"url quota exceeded","limit":2,"used":2,"requested":1}`,
+		},
+		{
+			name:         "Blocked domain",
+			body:         `{"url":"https://example.com"}`,
+			mockError:    &service.BlockedDomainError{URL: "https://example.com", Domain: "example.com"},
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: `{"error":"domain is blocked","url":"https://example.com","domain":"example.com"}`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -126,3 +234,87 @@ func TestHandlePostJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlePostJSON_Banned(t *testing.T) {
+	handler := newTestPostHandler(t)
+	until := time.Now().Add(90 * time.Second).UTC()
+
+	handler.urlService.(*mocks.MockURLServiceIface).EXPECT().
+		CreateURLRecord(gomock.Any(), "https://example.com", "test-user-id").
+		Return(nil, &service.BannedError{Kind: service.BanKindIP, Subject: "192.0.2.1", Until: until})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://example.com"}`))
+	req = middleware.InjectUserID(req, "test-user-id")
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.HandlePostJSON(rr, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "90", rr.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"url creation is banned","kind":"ip","until":"`+until.Format(time.RFC3339Nano)+`"}`, rr.Body.String())
+}
+
+func TestHandlePostJSON_Tags(t *testing.T) {
+	handler := newTestPostHandler(t)
+
+	tests := []struct {
+		name         string
+		mockError    error
+		expectedCode int
+	}{
+		{name: "Tagged", expectedCode: http.StatusCreated},
+		{name: "Invalid tags", mockError: service.ErrInvalidTags, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler.urlService.(*mocks.MockURLServiceIface).EXPECT().
+				CreateURLRecordWithOptions(gomock.Any(), "https://example.com", "test-user-id", service.CreateOptions{Tags: []string{"work", "docs"}}).
+				Return(&storage.URLRecord{Short: "abc123"}, tt.mockError)
+
+			body := `{"url":"https://example.com","tags":["work","docs"]}`
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(body))
+			req = middleware.InjectUserID(req, "test-user-id")
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			handler.HandlePostJSON(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+		})
+	}
+}
+
+func TestHandlePostJSON_Scheduled(t *testing.T) {
+	handler := newTestPostHandler(t)
+	notBefore := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.Add(24 * time.Hour)
+
+	tests := []struct {
+		name         string
+		mockError    error
+		expectedCode int
+	}{
+		{name: "Scheduled", expectedCode: http.StatusCreated},
+		{name: "Empty window", mockError: service.ErrInvalidWindow, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler.urlService.(*mocks.MockURLServiceIface).EXPECT().
+				CreateURLRecordWithOptions(gomock.Any(), "https://example.com", "test-user-id", service.CreateOptions{NotBefore: &notBefore, NotAfter: &notAfter}).
+				Return(&storage.URLRecord{Short: "abc123"}, tt.mockError)
+
+			body := `{"url":"https://example.com","not_before":"2030-01-01T00:00:00Z","not_after":"2030-01-02T00:00:00Z"}`
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(body))
+			req = middleware.InjectUserID(req, "test-user-id")
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			handler.HandlePostJSON(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Package handler provides the HTTP handler capturing profiles of the running
+// process on demand, for environments where the pprof listener is not reachable.
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+)
+
+const (
+	// defaultProfileSeconds is how long a CPU profile runs if the request
+	// does not set the duration.
+	defaultProfileSeconds = 10
+	// maxProfileSeconds bounds the duration of a CPU profile.
+	maxProfileSeconds = 120
+)
+
+// profileTypes lists the profiles that can be captured besides "cpu".
+var profileTypes = map[string]bool{
+	"heap":      true,
+	"allocs":    true,
+	"goroutine": true,
+	"block":     true,
+	"mutex":     true,
+}
+
+// ProfileHandler captures profiles of the running process and either stores
+// them in a directory or sends them back.
+type ProfileHandler struct {
+	dir    string      // Directory profiles are stored in; empty sends them back
+	logger *zap.Logger // Logger for logging events.
+}
+
+// NewProfile creates a ProfileHandler storing profiles in dir, or sending
+// them back in the response if dir is empty.
+func NewProfile(dir string, l *zap.Logger) *ProfileHandler {
+	return &ProfileHandler{dir: dir, logger: l}
+}
+
+// Capture handles POST requests capturing a profile of the given type query
+// parameter, "cpu" by default. A CPU profile runs for the given seconds, 10 by
+// default and at most 120; the other types are snapshots taken right away. The
+// profile is stored in the profile directory, and described by a StoredProfile
+// in a 201 Created response, or sent back in the gzipped pprof format if no
+// directory is configured. It returns 409 Conflict while another CPU profile
+// runs, and nothing if the client disconnects before the CPU profile ends.
+func (h *ProfileHandler) Capture(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	kind := query.Get("type")
+	if kind == "" {
+		kind = "cpu"
+	}
+	if kind != "cpu" && !profileTypes[kind] {
+		http.Error(res, fmt.Sprintf("unknown profile type %q", kind), http.StatusBadRequest)
+		return
+	}
+
+	seconds := defaultProfileSeconds
+	if v := query.Get("seconds"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxProfileSeconds {
+			http.Error(res, fmt.Sprintf("seconds must be between 1 and %d", maxProfileSeconds), http.StatusBadRequest)
+			return
+		}
+		seconds = n
+	}
+
+	var buf bytes.Buffer
+	if kind == "cpu" {
+		if err := pprof.StartCPUProfile(&buf); err != nil {
+			http.Error(res, "a CPU profile is already running", http.StatusConflict)
+			return
+		}
+		timer := time.NewTimer(time.Duration(seconds) * time.Second)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			pprof.StopCPUProfile()
+			return
+		case <-timer.C:
+		}
+		pprof.StopCPUProfile()
+	} else if err := pprof.Lookup(kind).WriteTo(&buf, 0); err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot capture profile", zap.Error(err), zap.String("type", kind))
+		writeServerError(res, err)
+		return
+	}
+
+	name := fmt.Sprintf("%s-%s.pprof", kind, time.Now().UTC().Format("20060102T150405.000"))
+	if h.dir == "" {
+		res.Header().Set("Content-Type", "application/octet-stream")
+		res.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		res.WriteHeader(http.StatusOK)
+		res.Write(buf.Bytes())
+		return
+	}
+
+	path := filepath.Join(h.dir, name)
+	if err := writeProfile(h.dir, path, buf.Bytes()); err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot store profile", zap.Error(err), zap.String("path", path))
+		writeServerError(res, err)
+		return
+	}
+	logger.FromContext(req.Context(), h.logger).Info("stored profile", zap.String("path", path), zap.String("type", kind))
+
+	writeJSON(res, http.StatusCreated, models.StoredProfile{Type: kind, Path: path, Bytes: buf.Len()})
+}
+
+// writeProfile writes data to path through a temporary file in dir, so that
+// the profile never appears truncated.
+func writeProfile(dir, path string, data []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".profile-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,70 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/handler"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+)
+
+func TestProfileCapture_Store(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+	h := handler.NewProfile(dir, testLogger())
+
+	rec := httptest.NewRecorder()
+	h.Capture(rec, httptest.NewRequest(http.MethodPost, "/api/internal/profiles?type=heap", nil))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var stored models.StoredProfile
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stored))
+	assert.Equal(t, "heap", stored.Type)
+	assert.Equal(t, dir, filepath.Dir(stored.Path))
+	data, err := os.ReadFile(stored.Path)
+	require.NoError(t, err)
+	assert.Len(t, data, stored.Bytes)
+
+	// Only the profile is left in the directory
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestProfileCapture_Stream(t *testing.T) {
+	h := handler.NewProfile("", testLogger())
+
+	rec := httptest.NewRecorder()
+	h.Capture(rec, httptest.NewRequest(http.MethodPost, "/api/internal/profiles?type=cpu&seconds=1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/octet-stream", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "cpu-")
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x1f, 0x8b}, body[:2], "profiles are gzipped")
+}
+
+func TestProfileCapture_Errors(t *testing.T) {
+	h := handler.NewProfile("", testLogger())
+
+	for _, query := range []string{"type=threadcreate", "seconds=0", "seconds=121", "seconds=soon"} {
+		rec := httptest.NewRecorder()
+		h.Capture(rec, httptest.NewRequest(http.MethodPost, "/api/internal/profiles?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+
+	// Only one CPU profile runs at a time
+	require.NoError(t, pprof.StartCPUProfile(io.Discard))
+	defer pprof.StopCPUProfile()
+	rec := httptest.NewRecorder()
+	h.Capture(rec, httptest.NewRequest(http.MethodPost, "/api/internal/profiles?seconds=1", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
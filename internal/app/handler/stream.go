@@ -0,0 +1,145 @@
+// Package handler provides the HTTP handlers streaming Server-Sent Events: the
+// clicks of a short URL to its owner, and the live stats of the service to
+// internal dashboards.
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// clickStreamHeartbeat is how often a comment is sent on an idle click
+// stream, so proxies do not close it.
+var clickStreamHeartbeat = 15 * time.Second
+
+const (
+	// defaultStatsInterval is how often live stats are sent if the request
+	// does not set the interval.
+	defaultStatsInterval = 5 * time.Second
+	// minStatsInterval and maxStatsInterval bound the interval of live stats.
+	minStatsInterval = time.Second
+	maxStatsInterval = time.Minute
+)
+
+// startEventStream sends the headers of an event stream and returns the
+// controller flushing its events.
+func startEventStream(res http.ResponseWriter) (*http.ResponseController, error) {
+	rc := http.NewResponseController(res)
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("X-Accel-Buffering", "no") // Keeps nginx from buffering the stream
+	res.WriteHeader(http.StatusOK)
+	return rc, rc.Flush()
+}
+
+// writeEvent writes an event of the given name whose data is v encoded as JSON.
+func writeEvent(res http.ResponseWriter, name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(res, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
+
+// ClickStream handles GET requests streaming the clicks of a short URL of the
+// current user as Server-Sent Events: each redirect is sent as a "click"
+// event whose data is a ClickEvent, until the client disconnects. It returns
+// 404 Not Found for short URLs the user does not own and 429 Too Many
+// Requests if the short URL already has the maximum number of streams.
+func (h *GetHandler) ClickStream(res http.ResponseWriter, req *http.Request) {
+	short := chi.URLParam(req, "short")
+	userID, _ := req.Context().Value(middleware.UserIDKey).(string)
+
+	events, stop, err := h.service.StreamClicks(req.Context(), short, userID)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		writeJSON(res, http.StatusNotFound, models.LinkErrorResponse{Error: "URL not found", ShortURL: short})
+		return
+	case errors.Is(err, service.ErrTooManyStreams):
+		http.Error(res, err.Error(), http.StatusTooManyRequests)
+		return
+	case err != nil:
+		logger.FromContext(req.Context(), h.logger).Error("cannot stream clicks", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+	defer stop()
+
+	rc, err := startEventStream(res)
+	if err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot flush click stream", zap.Error(err))
+		return
+	}
+
+	heartbeat := time.NewTicker(clickStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case ev := <-events:
+			if err := writeEvent(res, "click", ev); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// StatsStream handles GET requests streaming the live stats of the service as
+// Server-Sent Events: a "stats" event whose data is a LiveStats is sent right
+// away and then every interval, until the client disconnects. The interval
+// query parameter is a duration between 1s and 1m, 5s by default; other
+// values return 400 Bad Request.
+func (h *AdminHandler) StatsStream(res http.ResponseWriter, req *http.Request) {
+	interval := defaultStatsInterval
+	if v := req.URL.Query().Get("interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < minStatsInterval || d > maxStatsInterval {
+			http.Error(res, "interval must be a duration between "+minStatsInterval.String()+" and "+maxStatsInterval.String(), http.StatusBadRequest)
+			return
+		}
+		interval = d
+	}
+
+	rc, err := startEventStream(res)
+	if err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot flush stats stream", zap.Error(err))
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := writeEvent(res, "stats", h.service.LiveStats(req.Context())); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		select {
+		case <-req.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestClickStream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	handler := createTestHandler(mockService)
+
+	events := make(chan models.ClickEvent, 1)
+	stopped := make(chan struct{})
+	mockService.EXPECT().StreamClicks(gomock.Any(), "abc", "user-1").
+		Return((<-chan models.ClickEvent)(events), func() { close(stopped) }, nil)
+	mockService.EXPECT().StreamClicks(gomock.Any(), "missing", "user-1").Return(nil, nil, storage.ErrNotFound)
+	mockService.EXPECT().StreamClicks(gomock.Any(), "busy", "user-1").Return(nil, nil, service.ErrTooManyStreams)
+
+	r := chi.NewRouter()
+	r.Get("/api/v1/urls/{short}/stream", func(w http.ResponseWriter, req *http.Request) {
+		handler.ClickStream(w, req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user-1")))
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/api/v1/urls/missing/stream")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res, err = http.Get(srv.URL + "/api/v1/urls/busy/stream")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+
+	// Clicks are pushed as they happen
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/urls/abc/stream", nil)
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	events <- models.ClickEvent{ShortURL: "http://localhost:8080/abc", Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	lines := bufio.NewScanner(res.Body)
+	require.True(t, lines.Scan())
+	assert.Equal(t, "event: click", lines.Text())
+	require.True(t, lines.Scan())
+	assert.Equal(t, `data: {"short_url":"http://localhost:8080/abc","time":"2024-05-01T12:00:00Z"}`, lines.Text())
+
+	// The subscription ends with the request
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the click stream was not stopped")
+	}
+}
+
+func TestStatsStream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	handler := NewAdmin(mockService, nil, zap.NewNop())
+
+	stats := models.LiveStats{Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), CreatedPerMinute: 2, RedirectsPerMinute: 5, DeleteQueueDepth: 1}
+	mockService.EXPECT().LiveStats(gomock.Any()).Return(stats).MinTimes(2)
+
+	srv := httptest.NewServer(http.HandlerFunc(handler.StatsStream))
+	defer srv.Close()
+
+	for _, interval := range []string{"soon", "10ms", "2m"} {
+		res, err := http.Get(srv.URL + "?interval=" + interval)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, interval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?interval=1s", nil)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	// The stats are sent right away, then every interval
+	lines := bufio.NewScanner(res.Body)
+	for range 2 {
+		require.True(t, lines.Scan())
+		assert.Equal(t, "event: stats", lines.Text())
+		require.True(t, lines.Scan())
+		assert.Equal(t, `data: {"time":"2024-05-01T12:00:00Z","created_per_minute":2,"redirects_per_minute":5,"delete_queue_depth":1}`, lines.Text())
+		require.True(t, lines.Scan())
+		assert.Empty(t, lines.Text())
+	}
+}
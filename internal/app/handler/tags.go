@@ -0,0 +1,78 @@
+// Package handler provides HTTP handlers for setting the tags of short URLs.
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// TagsHandler handles HTTP requests setting the tags of short URLs.
+type TagsHandler struct {
+	service service.URLServiceIface // Service for handling URL operations.
+	logger  *zap.Logger             // Logger for logging events.
+}
+
+// NewTags creates a new TagsHandler instance with the provided URL service and logger.
+func NewTags(s service.URLServiceIface, l *zap.Logger) *TagsHandler {
+	return &TagsHandler{
+		service: s,
+		logger:  l,
+	}
+}
+
+// Set handles PUT requests replacing the tags of a short URL of the current
+// user; an empty list removes them. It returns the stored tags, normalized to
+// lower case, 400 Bad Request if they are not allowed, 404 Not Found if the
+// user does not own the short URL and 410 Gone if it was deleted.
+func (h *TagsHandler) Set(res http.ResponseWriter, req *http.Request) {
+	// Extract the user ID from the request context.
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse the incoming JSON request body.
+	var request models.SetTagsRequest
+	if err := decodeJSONBody(res, req, &request); err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(res, mr.msg, mr.status)
+			return
+		}
+		logger.FromContext(req.Context(), h.logger).Error(err.Error())
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	tags, err := h.service.SetURLTags(req.Context(), chi.URLParam(req, "short"), userID, request.Tags)
+	switch {
+	case errors.Is(err, service.ErrInvalidTags):
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(res, "URL not found", http.StatusNotFound)
+		return
+	case errors.Is(err, storage.ErrDeleted):
+		http.Error(res, "URL is gone", http.StatusGone)
+		return
+	case err != nil:
+		logger.FromContext(req.Context(), h.logger).Error("cannot set URL tags", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	if tags == nil {
+		tags = []string{}
+	}
+	writeJSON(res, http.StatusOK, models.SetTagsRequest{Tags: tags})
+}
@@ -0,0 +1,69 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/handler"
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestTagsHandler_Set(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockURLServiceIface(ctrl)
+	h := handler.NewTags(mockService, testLogger())
+
+	request := func(userID, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/user/urls/abc/tags", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("short", "abc")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		if userID != "" {
+			ctx = context.WithValue(ctx, middleware.UserIDKey, userID)
+		}
+		return req.WithContext(ctx)
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		stored []string
+		err    error
+		want   int
+		body   string
+	}{
+		{name: "set", userID: "user-1", stored: []string{"work", "docs"}, want: http.StatusOK, body: `{"tags":["work","docs"]}`},
+		{name: "removed", userID: "user-1", want: http.StatusOK, body: `{"tags":[]}`},
+		{name: "not owner", userID: "user-1", err: storage.ErrNotFound, want: http.StatusNotFound},
+		{name: "deleted", userID: "user-1", err: storage.ErrDeleted, want: http.StatusGone},
+		{name: "invalid", userID: "user-1", err: service.ErrInvalidTags, want: http.StatusBadRequest},
+		{name: "unauthenticated", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.userID != "" {
+				mockService.EXPECT().SetURLTags(gomock.Any(), "abc", tt.userID, []string{"Work", "docs"}).Return(tt.stored, tt.err)
+			}
+
+			rec := httptest.NewRecorder()
+			h.Set(rec, request(tt.userID, `{"tags":["Work","docs"]}`))
+			require.Equal(t, tt.want, rec.Code)
+			if tt.body != "" {
+				require.JSONEq(t, tt.body, rec.Body.String())
+			}
+		})
+	}
+}
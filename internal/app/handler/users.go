@@ -0,0 +1,90 @@
+// Package handler provides HTTP handlers for the account of the current user.
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// UserHandler handles HTTP requests for user accounts.
+type UserHandler struct {
+	users  service.UsersIface // Service for user accounts.
+	logger *zap.Logger        // Logger for logging events.
+}
+
+// NewUser creates a new UserHandler instance with the provided users service and logger.
+func NewUser(u service.UsersIface, l *zap.Logger) *UserHandler {
+	return &UserHandler{
+		users:  u,
+		logger: l,
+	}
+}
+
+// toUserResponse converts a stored account to its HTTP representation.
+func toUserResponse(u *storage.User) models.UserResponse {
+	return models.UserResponse{ID: u.ID, DisplayName: u.DisplayName, CreatedAt: u.CreatedAt}
+}
+
+// Get handles GET requests returning the account of the current user.
+func (h *UserHandler) Get(res http.ResponseWriter, req *http.Request) {
+	// Extract the user ID from the request context.
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.users.Get(req.Context(), userID)
+	if err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot get user", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, toUserResponse(user))
+}
+
+// Update handles PUT requests changing the display name of the current user.
+// It returns the updated account, or 400 Bad Request for an invalid display name.
+func (h *UserHandler) Update(res http.ResponseWriter, req *http.Request) {
+	// Extract the user ID from the request context.
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse the incoming JSON request body.
+	var request models.UpdateUserRequest
+	if err := decodeJSONBody(res, req, &request); err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(res, mr.msg, mr.status)
+			return
+		}
+		logger.FromContext(req.Context(), h.logger).Error(err.Error())
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.users.SetDisplayName(req.Context(), userID, request.DisplayName)
+	if errors.Is(err, service.ErrInvalidDisplayName) {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.FromContext(req.Context(), h.logger).Error("cannot update user", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, toUserResponse(user))
+}
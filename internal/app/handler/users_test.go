@@ -0,0 +1,75 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/handler"
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestUserHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUsers := mocks.NewMockUsersIface(ctrl)
+	h := handler.NewUser(mockUsers, testLogger())
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	withUser := func(req *http.Request) *http.Request {
+		return req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user-1"))
+	}
+
+	t.Run("get account", func(t *testing.T) {
+		mockUsers.EXPECT().Get(gomock.Any(), "user-1").
+			Return(&storage.User{ID: "user-1", DisplayName: "Alice", CreatedAt: createdAt}, nil)
+
+		rec := httptest.NewRecorder()
+		h.Get(rec, withUser(httptest.NewRequest(http.MethodGet, "/api/v1/user", nil)))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"id":"user-1","display_name":"Alice","created_at":"2024-01-02T03:04:05Z"}`, rec.Body.String())
+	})
+
+	t.Run("update display name", func(t *testing.T) {
+		mockUsers.EXPECT().SetDisplayName(gomock.Any(), "user-1", "Bob").
+			Return(&storage.User{ID: "user-1", DisplayName: "Bob", CreatedAt: createdAt}, nil)
+
+		req := withUser(httptest.NewRequest(http.MethodPut, "/api/v1/user", bytes.NewBufferString(`{"display_name":"Bob"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.Update(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"id":"user-1","display_name":"Bob","created_at":"2024-01-02T03:04:05Z"}`, rec.Body.String())
+	})
+
+	t.Run("invalid display name", func(t *testing.T) {
+		mockUsers.EXPECT().SetDisplayName(gomock.Any(), "user-1", gomock.Any()).
+			Return(nil, service.ErrInvalidDisplayName)
+
+		req := withUser(httptest.NewRequest(http.MethodPut, "/api/v1/user", bytes.NewBufferString(`{"display_name":"x"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.Update(rec, req)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.Get(rec, httptest.NewRequest(http.MethodGet, "/api/v1/user", nil))
+
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
@@ -0,0 +1,77 @@
+// Package handler provides HTTP handlers for making the metadata and stats
+// of short URLs public or private.
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// VisibilityHandler handles HTTP requests changing the visibility of short URLs.
+type VisibilityHandler struct {
+	service service.URLServiceIface // Service for handling URL operations.
+	logger  *zap.Logger             // Logger for logging events.
+}
+
+// NewVisibility creates a new VisibilityHandler instance with the provided URL service and logger.
+func NewVisibility(s service.URLServiceIface, l *zap.Logger) *VisibilityHandler {
+	return &VisibilityHandler{
+		service: s,
+		logger:  l,
+	}
+}
+
+// Set handles PUT requests making the metadata and stats of a short URL of the
+// current user public, viewable by anyone knowing the short URL, or private,
+// shown to the user only. It returns 204 No Content on success, 400 Bad
+// Request for unknown visibilities, 404 Not Found if the user does not own
+// the short URL and 410 Gone if it was deleted.
+func (h *VisibilityHandler) Set(res http.ResponseWriter, req *http.Request) {
+	// Extract the user ID from the request context.
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse the incoming JSON request body.
+	var request models.SetVisibilityRequest
+	if err := decodeJSONBody(res, req, &request); err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(res, mr.msg, mr.status)
+			return
+		}
+		logger.FromContext(req.Context(), h.logger).Error(err.Error())
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	err := h.service.SetURLVisibility(req.Context(), chi.URLParam(req, "short"), userID, request.Visibility)
+	switch {
+	case errors.Is(err, service.ErrInvalidVisibility):
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(res, "URL not found", http.StatusNotFound)
+		return
+	case errors.Is(err, storage.ErrDeleted):
+		http.Error(res, "URL is gone", http.StatusGone)
+		return
+	case err != nil:
+		logger.FromContext(req.Context(), h.logger).Error("cannot set URL visibility", zap.Error(err))
+		writeServerError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,12 @@
+#!/bin/sh
+# Fetches the Swagger UI assets of the given swagger-ui-dist release into
+# swagger-ui/, to be embedded into the binary. Run through go generate.
+set -eu
+
+version="$1"
+tmp="$(mktemp -d)"
+trap 'rm -rf "$tmp"' EXIT
+
+curl -fsSL "https://registry.npmjs.org/swagger-ui-dist/-/swagger-ui-dist-${version}.tgz" -o "$tmp/dist.tgz"
+tar -xzf "$tmp/dist.tgz" -C "$tmp" package/swagger-ui.css package/swagger-ui-bundle.js
+cp "$tmp/package/swagger-ui.css" "$tmp/package/swagger-ui-bundle.js" swagger-ui/
@@ -0,0 +1,103 @@
+// Package openapi serves the OpenAPI 3 description of the HTTP API together
+// with a Swagger UI page. Both documents, and the Swagger UI assets once
+// fetched, are embedded into the binary; no script is loaded from elsewhere.
+package openapi
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+)
+
+//go:generate sh fetch-swagger-ui.sh 5.17.14
+
+// SwaggerUIVersion is the swagger-ui-dist release whose assets are embedded; keep
+// it in sync with the go:generate directive fetching its assets.
+const SwaggerUIVersion = "5.17.14"
+
+// Spec is the OpenAPI 3 document describing every HTTP endpoint.
+// Its component schemas mirror the request and response models in internal/models.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// swaggerPage is the template of the Swagger UI page rendering Spec.
+//
+//go:embed swagger.html
+var swaggerPage string
+
+// swaggerAssets holds the Swagger UI assets fetched by go generate.
+//
+//go:embed swagger-ui
+var swaggerAssets embed.FS
+
+// assetTypes are the content types of the Swagger UI assets served by ServeAsset.
+var assetTypes = map[string]string{
+	"swagger-ui.css":       "text/css; charset=utf-8",
+	"swagger-ui-bundle.js": "text/javascript; charset=utf-8",
+}
+
+// swaggerUI is the Swagger UI page, or nil if its assets were not fetched.
+var swaggerUI = renderUI(swaggerAssets)
+
+// renderUI renders the Swagger UI page loading the assets embedded in assets,
+// or returns nil if they are missing. The page never falls back to a CDN, so
+// that the docs do not run scripts the binary was not built with.
+func renderUI(assets fs.FS) []byte {
+	if _, err := fs.Stat(assets, "swagger-ui/swagger-ui-bundle.js"); err != nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	template.Must(template.New("swagger").Parse(swaggerPage)).Execute(&buf, struct{ Assets string }{Assets: "/api/docs/assets"})
+	return buf.Bytes()
+}
+
+// ServeSpec writes the OpenAPI document as JSON.
+func ServeSpec(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	_, _ = res.Write(Spec)
+}
+
+// ServeUI writes the Swagger UI page that loads the document from /api/openapi.json,
+// or 503 Service Unavailable if the binary was built without the Swagger UI assets.
+func ServeUI(res http.ResponseWriter, req *http.Request) {
+	serveUI(res, swaggerUI)
+}
+
+// serveUI writes page, the rendered Swagger UI page, or 503 if it is nil.
+func serveUI(res http.ResponseWriter, page []byte) {
+	if page == nil {
+		http.Error(res, "Swagger UI assets are not embedded, run go generate ./internal/app/openapi", http.StatusServiceUnavailable)
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.WriteHeader(http.StatusOK)
+	_, _ = res.Write(page)
+}
+
+// ServeAsset writes the embedded Swagger UI asset named by the last element of
+// the request path, or 404 Not Found if it is unknown or was not fetched.
+func ServeAsset(res http.ResponseWriter, req *http.Request) {
+	name := path.Base(req.URL.Path)
+	contentType, ok := assetTypes[name]
+	if !ok {
+		http.NotFound(res, req)
+		return
+	}
+	data, err := swaggerAssets.ReadFile("swagger-ui/" + name)
+	if err != nil {
+		http.NotFound(res, req)
+		return
+	}
+
+	res.Header().Set("Content-Type", contentType)
+	res.Header().Set("Cache-Control", "public, max-age=86400")
+	res.WriteHeader(http.StatusOK)
+	_, _ = res.Write(data)
+}
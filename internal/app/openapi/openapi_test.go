@@ -0,0 +1,134 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+)
+
+type document struct {
+	Paths      map[string]map[string]any `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]any `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+// jsonFields returns the JSON field names of a struct type.
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestSpecMatchesModels(t *testing.T) {
+	var doc document
+	require.NoError(t, json.Unmarshal(Spec, &doc))
+
+	schemas := map[string]any{
+		"Request":                models.Request{},
+		"Response":               models.Response{},
+		"BatchRequest":           models.BatchRequest{},
+		"BatchResponse":          models.BatchResponse{},
+		"ByIDRequest":            models.ByIDRequest{},
+		"Stats":                  models.Stats{},
+		"QuotaExceededResponse":  models.QuotaExceededResponse{},
+		"CreationBannedResponse": models.CreationBannedResponse{},
+		"Ban":                    models.Ban{},
+		"APIKeyResponse":         models.APIKeyResponse{},
+		"UserResponse":           models.UserResponse{},
+		"UpdateUserRequest":      models.UpdateUserRequest{},
+		"AuditEvent":             models.AuditEvent{},
+		"HealthResponse":         models.HealthResponse{},
+		"DependencyStatus":       models.DependencyStatus{},
+		"LinkErrorResponse":      models.LinkErrorResponse{},
+		"URLMetadata":            models.URLMetadata{},
+		"ExpandBatchResponse":    models.ExpandBatchResponse{},
+		"TopURL":                 models.TopURL{},
+		"ClickEvent":             models.ClickEvent{},
+		"LiveStats":              models.LiveStats{},
+		"StoredProfile":          models.StoredProfile{},
+		"DailyStats":             models.DailyStats{},
+		"SetTagsRequest":         models.SetTagsRequest{},
+		"SetDetailsRequest":      models.SetDetailsRequest{},
+		"CreateCampaignRequest":  models.CreateCampaignRequest{},
+		"CampaignResponse":       models.CampaignResponse{},
+		"SetCampaignRequest":     models.SetCampaignRequest{},
+		"CampaignStats":          models.CampaignStats{},
+		"SetVisibilityRequest":   models.SetVisibilityRequest{},
+		"URLStats":               models.URLStats{},
+	}
+
+	for name, model := range schemas {
+		schema, ok := doc.Components.Schemas[name]
+		require.True(t, ok, "schema %s is missing", name)
+
+		var props []string
+		for p := range schema.Properties {
+			props = append(props, p)
+		}
+		sort.Strings(props)
+
+		assert.Equal(t, jsonFields(reflect.TypeOf(model)), props, "schema %s is out of sync with models", name)
+	}
+}
+
+func TestServeSpec(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ServeSpec(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.True(t, json.Valid(rec.Body.Bytes()))
+}
+
+func TestServeUI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	serveUI(rec, renderUI(fstest.MapFS{"swagger-ui/swagger-ui-bundle.js": {Data: []byte("bundle")}}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "/api/openapi.json")
+}
+
+func TestRenderUI(t *testing.T) {
+	// Embedded assets are served from the API origin
+	page := renderUI(fstest.MapFS{"swagger-ui/swagger-ui-bundle.js": {Data: []byte("bundle")}})
+	assert.Contains(t, string(page), `src="/api/docs/assets/swagger-ui-bundle.js"`)
+	assert.Contains(t, string(page), `href="/api/docs/assets/swagger-ui.css"`)
+	assert.NotContains(t, string(page), "https://")
+
+	// Without them there is no page, rather than one loading a third-party script
+	page = renderUI(fstest.MapFS{})
+	assert.Nil(t, page)
+
+	rec := httptest.NewRecorder()
+	serveUI(rec, page)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "go generate")
+}
+
+func TestServeAsset(t *testing.T) {
+	for _, name := range []string{"README.md", "openapi.json", "..%2fopenapi.go"} {
+		rec := httptest.NewRecorder()
+		ServeAsset(rec, httptest.NewRequest(http.MethodGet, "/api/docs/assets/"+name, nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code, name)
+	}
+}
@@ -0,0 +1,45 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/openapi"
+	"github.com/atinyakov/go-url-shortener/internal/app/server"
+	"github.com/atinyakov/go-url-shortener/internal/config"
+)
+
+func TestSpecCoversRoutes(t *testing.T) {
+	var doc struct {
+		Paths map[string]map[string]any `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(openapi.Spec, &doc))
+
+	router := server.Init(&config.Options{ResultHostname: "http://localhost:8080"}, zap.NewNop(), true, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	err := chi.Walk(router, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		if route == "/" && method == http.MethodGet {
+			return nil // placeholder that only reports a missing short URL
+		}
+		if _, ok := doc.Paths["/api/v1"+strings.TrimPrefix(route, "/api")]; ok && !strings.HasPrefix(route, "/api/v1") {
+			route = "/api/v1" + strings.TrimPrefix(route, "/api") // legacy alias of a v1 endpoint
+		}
+
+		operations, ok := doc.Paths[route]
+		if assert.True(t, ok, "route %s is not documented", route) {
+			assert.Contains(t, operations, strings.ToLower(method), "%s %s is not documented", method, route)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
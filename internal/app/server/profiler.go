@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"sync/atomic"
+)
+
+// Profiler serves the net/http/pprof handlers under /debug/pprof/. It can be
+// switched off at runtime, after which it answers 404 Not Found, so that the
+// profiling endpoints are only exposed while they are needed.
+type Profiler struct {
+	enabled atomic.Bool
+	mux     *http.ServeMux
+}
+
+// NewProfiler returns a Profiler, initially enabled or disabled.
+func NewProfiler(enabled bool) *Profiler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	p := &Profiler{mux: mux}
+	p.enabled.Store(enabled)
+	return p
+}
+
+// SetEnabled switches the profiling endpoints on or off.
+func (p *Profiler) SetEnabled(enabled bool) {
+	p.enabled.Store(enabled)
+}
+
+// Enabled reports whether the profiling endpoints are served.
+func (p *Profiler) Enabled() bool {
+	return p.enabled.Load()
+}
+
+// ServeHTTP serves the profiling endpoints if the profiler is enabled.
+func (p *Profiler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.Enabled() {
+		http.NotFound(w, r)
+		return
+	}
+	p.mux.ServeHTTP(w, r)
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/config"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+)
+
+func TestProfiler_SetEnabled(t *testing.T) {
+	p := NewProfiler(false)
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	p.SetEnabled(true)
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine")
+}
+
+func TestProfiler_OnRouter(t *testing.T) {
+	trusted, err := middleware.NewTrustedSubnets([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	cfg := &config.Options{ResultHostname: "http://localhost:8080"}
+	router := newTestRouterWith(t, cfg, NewProfiler(true), trusted)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		wantStatus int
+	}{
+		{name: "trusted client", remoteAddr: "10.1.2.3:5000", wantStatus: http.StatusOK},
+		{name: "other client", remoteAddr: "192.168.1.1:5000", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/debug/pprof/cmdline", nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
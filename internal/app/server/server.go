@@ -12,7 +12,10 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/atinyakov/go-url-shortener/internal/app/handler"
+	"github.com/atinyakov/go-url-shortener/internal/app/openapi"
 	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/app/webui"
+	"github.com/atinyakov/go-url-shortener/internal/config"
 	"github.com/atinyakov/go-url-shortener/internal/middleware"
 )
 
@@ -20,33 +23,92 @@ import (
 // routes and middlewares applied. The router is set up to handle different
 // HTTP methods for URL shortening operations, including GET, POST, and DELETE.
 //
-// The router also includes middleware for logging, JWT authentication,
+// The router also includes middleware for request IDs, tracing, CORS, logging, panic recovery, API key and JWT authentication, auditing,
 // and optional gzip compression for both request and response handling.
+// The liveness and readiness probes are served without any of them.
 //
 // Parameters:
-//   - baseURL: The base URL for the shortened links.
+//   - cfg: The application options (base URL for the shortened links, CORS policy).
 //   - logger: A logger instance (typically used for logging requests and errors).
 //   - withGzip: A flag indicating whether gzip compression should be enabled.
 //   - sv: The service layer that handles URL shortening operations (implementing service.URLServiceIface).
+//   - auth: The JWT authentication service used for cookie-based sessions.
+//   - keys: The service issuing and resolving API keys for server-to-server clients.
+//   - users: The service managing user accounts.
+//   - audit: The audit log recording mutating requests.
+//   - health: The readiness checks of the dependencies.
+//   - profiler: The pprof endpoints served to the trusted subnets, or nil to not serve them.
+//   - trusted: The subnets internal endpoints are available to; nil closes them.
+//   - limiter: The rate limiter of the API, which can be changed at runtime; nil applies the fixed limit of cfg.
+//   - pages: The pages shown instead of a redirect, such as for unknown short URLs; nil selects the built-in ones.
 //
 // Returns:
 //   - A chi router instance configured with the defined routes and middlewares.
-func Init(baseURL string, logger *zap.Logger, withGzip bool, sv service.URLServiceIface) *chi.Mux {
+func Init(cfg *config.Options, logger *zap.Logger, withGzip bool, sv service.URLServiceIface, auth service.AuthIface, keys service.APIKeyIface, users service.UsersIface, audit service.AuditIface, health service.HealthIface, profiler http.Handler, trusted *middleware.TrustedSubnets, limiter *middleware.RateLimiter, pages *handler.Pages) *chi.Mux {
+	if pages == nil {
+		pages = handler.DefaultPages()
+	}
 
 	// Create handler instances for different HTTP actions
-	get := handler.NewGet(sv, logger)
+	get := handler.NewGetWithPages(sv, logger, pages)
 	delete := handler.NewDelete(sv, logger)
-	post := handler.NewPost(baseURL, sv, logger)
+	post := handler.NewPost(sv, logger)
+	apiKey := handler.NewAPIKey(keys, logger)
+	admin := handler.NewAdmin(sv, audit, logger)
+	password := handler.NewPassword(sv, logger)
+	tags := handler.NewTags(sv, logger)
+	details := handler.NewDetails(sv, logger)
+	pins := handler.NewPins(sv, logger)
+	campaigns := handler.NewCampaigns(sv, logger)
+	visibility := handler.NewVisibility(sv, logger)
+	user := handler.NewUser(users, logger)
+	probes := handler.NewHealth(health, logger)
+	profiles := handler.NewProfile(cfg.ProfileDir, logger)
 
 	// Create a new router
-	r := chi.NewRouter()
+	root := chi.NewRouter()
+
+	// Serve the probes before any middleware, so that they never authenticate,
+	// log or touch the storage, and keep answering when the database is slow
+	root.Get("/healthz", probes.Live) // Liveness probe: the process is serving HTTP
+	root.Get("/readyz", probes.Ready) // Readiness probe: storage, delete worker and schema
+
+	// Every other route goes through the middleware below
+	r := root.With()
+
+	// Assign every request an ID used to correlate its log entries
+	r.Use(middleware.WithRequestID)
+
+	// Trace every request, continuing the trace of the caller if it sent one
+	r.Use(middleware.WithTracing)
+
+	// Resolve the tenant, and with it the base URL of short URLs, from the Host header
+	r.Use(middleware.WithTenantHost)
+
+	// Pass the client IP to the service, which bans the clients creating URLs too fast
+	r.Use(middleware.WithCreatorIP)
+
+	// Answer CORS preflight requests before authentication, if cross-origin access is configured
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		r.Use(middleware.WithCORS(middleware.CORSOptions{
+			AllowedOrigins:   cfg.CORSAllowedOrigins,
+			AllowedMethods:   cfg.CORSAllowedMethods,
+			AllowedHeaders:   cfg.CORSAllowedHeaders,
+			AllowCredentials: cfg.CORSAllowCredentials,
+		}))
+	}
 
 	// Set allowed content types for incoming requests
-	r.Use(chiMiddleware.AllowContentType("text/plain", "application/json", "text/html", "application/x-gzip"))
+	r.Use(chiMiddleware.AllowContentType("text/plain", "application/json", "text/html", "application/x-gzip", "application/x-www-form-urlencoded"))
 
-	// Use middleware for logging, JWT authentication, and optional gzip support
+	// Use middleware for logging, panic recovery, API key and JWT authentication, and optional gzip support
 	r.Use(middleware.WithRequestLogging(logger))
-	r.Use(middleware.WithJWT(service.NewAuth(sv)))
+	r.Use(middleware.WithRecovery(logger))
+	r.Use(middleware.WithAPIKey(keys))
+	r.Use(middleware.WithJWT(auth))
+
+	// Record mutating requests into the audit log once the user is known
+	r.Use(middleware.WithAudit(audit))
 
 	// Enable gzip compression middleware if specified
 	if withGzip {
@@ -54,17 +116,106 @@ func Init(baseURL string, logger *zap.Logger, withGzip bool, sv service.URLServi
 		r.Use(middleware.WithGZIPGet)
 	}
 
+	// Per-route request budgets; shortening, batches and listings have different costs
+	defaultTimeout := middleware.WithTimeout(cfg.RequestTimeout.Duration)
+	shortenTimeout := middleware.WithTimeout(cfg.ShortenTimeout.Duration)
+	batchTimeout := middleware.WithTimeout(cfg.BatchTimeout.Duration)
+	userURLsTimeout := middleware.WithTimeout(cfg.UserURLsTimeout.Duration)
+
+	// Clients over the rate limit are turned away before the handlers run
+	if limiter == nil {
+		limiter = middleware.NewRateLimiter(cfg.RateLimit, cfg.RateLimitWindow.Duration)
+	}
+	rateLimit := middleware.WithRateLimit(limiter)
+
+	// Shortening from a GET request creates a URL, so it is audited like the POST requests
+	readAudit := middleware.WithReadAudit(audit)
+
+	// Shortening requests retried with the same Idempotency-Key get the response of the first attempt
+	idempotent := func(next http.Handler) http.Handler { return next }
+	if cfg.IdempotencyKeyTTL.Duration > 0 {
+		idempotent = middleware.WithIdempotency(middleware.NewIdempotencyStore(cfg.IdempotencyKeyTTL.Duration))
+	}
+
 	// Define route handlers
-	r.Post("/", post.PlainBody)                    // Handles POST requests for URL shortening
-	r.Get("/{url}", get.ByShort)                   // Retrieves the original URL by shortened URL
-	r.Get("/ping", get.PingDB)                     // Ping the database to check if it's accessible
-	r.Get("/api/user/urls", get.URLsByUserID)      // Retrieve all URLs by the current user ID
-	r.Delete("/api/user/urls", delete.DeleteBatch) // Delete a batch of URLs for the current user
-
-	// Define routes for API-based URL shortening
-	r.Route("/api/shorten", func(r chi.Router) {
-		r.Post("/", post.HandlePostJSON)   // Handles POST requests with JSON payload
-		r.Post("/batch", post.HandleBatch) // Handles batch URL shortening requests
+	r.With(rateLimit, shortenTimeout, idempotent).Post("/", post.PlainBody) // Handles POST requests for URL shortening
+	r.With(defaultTimeout).Get("/{url}", get.ByShort)                       // Retrieves the original URL by shortened URL
+	r.With(rateLimit, defaultTimeout).Post("/{url}", get.ByShort)           // Submits the password form of a protected URL
+	r.With(defaultTimeout).Get("/ping", get.PingDB)                         // Ping the database to check if it's accessible
+	r.Get("/ui", webui.Serve)                                               // Web UI for shortening and browsing links
+
+	// Define routes of the JSON API, version 1
+	apiV1 := func(r chi.Router) {
+		// Every route of the API counts towards the rate limit of the client
+		r = r.With(rateLimit)
+
+		r.With(userURLsTimeout).Get("/user/urls", get.URLsByUserID)                 // Retrieve all URLs by the current user ID
+		r.With(defaultTimeout).Delete("/user/urls", delete.DeleteBatch)             // Delete a batch of URLs for the current user
+		r.With(defaultTimeout).Put("/user/urls/{short}/password", password.Set)     // Protect a URL of the current user with a password
+		r.With(defaultTimeout).Put("/user/urls/{short}/tags", tags.Set)             // Replace the tags of a URL of the current user
+		r.With(defaultTimeout).Put("/user/urls/{short}/details", details.Set)       // Set the title and description of a URL of the current user
+		r.With(defaultTimeout).Put("/user/urls/{short}/pin", pins.Pin)              // Pin a URL of the current user to the top of their listing
+		r.With(defaultTimeout).Delete("/user/urls/{short}/pin", pins.Unpin)         // Unpin a URL of the current user
+		r.With(defaultTimeout).Put("/user/urls/{short}/campaign", campaigns.Assign) // Move a URL of the current user to one of their campaigns
+		r.With(defaultTimeout).Put("/user/urls/{short}/visibility", visibility.Set) // Make the metadata and stats of a URL of the current user public or private
+		r.With(defaultTimeout).Post("/user/campaigns", campaigns.Create)            // Create a campaign of the current user
+		r.With(defaultTimeout).Get("/user/campaigns", campaigns.List)               // List the campaigns of the current user
+		r.With(defaultTimeout).Get("/user/campaigns/{id}/stats", campaigns.Stats)   // Clicks of the URLs of a campaign of the current user
+		r.With(defaultTimeout).Post("/user/keys", apiKey.Issue)                     // Issue an API key for the current user
+		r.With(defaultTimeout).Get("/user", user.Get)                               // Retrieve the account of the current user
+		r.With(defaultTimeout).Put("/user", user.Update)                            // Change the display name of the current user
+		r.With(defaultTimeout).Get("/urls/{short}", get.Metadata)                   // Metadata of a short URL, without following it
+		r.With(defaultTimeout).Get("/urls/{short}/stats", get.Stats)                // Clicks of a short URL, unless its owner made it private
+		r.Get("/urls/{short}/stream", get.ClickStream)                              // Stream the clicks of a URL of the current user, without a timeout
+		r.With(batchTimeout).Post("/expand/batch", get.ExpandBatch)                 // Resolve many short URLs to their original URLs
+
+		// Define routes for API-based URL shortening
+		r.Route("/shorten", func(r chi.Router) {
+			r.With(shortenTimeout, idempotent).Post("/", post.HandlePostJSON)                // Handles POST requests with JSON payload
+			r.With(middleware.RequireAPIKey, shortenTimeout, readAudit).Get("/", post.Query) // Shortens the url query parameter for API key clients, answering in plain text
+			r.With(batchTimeout, idempotent).Post("/batch", post.HandleBatch)                // Handles batch URL shortening requests
+		})
+
+		// Define routes of the admin API, available only to tokens with the admin claim
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(middleware.RequireAdmin)
+			r.With(userURLsTimeout).Get("/users/{userID}/urls", admin.URLsByUserID) // Retrieve all URLs of any user
+			r.With(defaultTimeout).Delete("/urls", admin.DeleteURLs)                // Delete URLs regardless of their owner
+			r.With(defaultTimeout).Get("/stats", admin.Stats)                       // Global statistics of the service
+			r.With(userURLsTimeout).Get("/audit", admin.Audit)                      // Query the audit log of mutating operations
+			r.With(defaultTimeout).Get("/bans", admin.Bans)                         // Users and client IPs banned for creating URLs too fast
+			r.With(defaultTimeout).Delete("/bans/{kind}/{subject}", admin.LiftBan)  // Lift the ban of a user or a client IP
+		})
+	}
+
+	// Serve the profiling endpoints only to clients from the trusted subnets
+	if profiler != nil {
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.WithSubnet(trusted))
+			r.Mount("/debug/pprof", profiler)
+		})
+	}
+
+	// Mount the versioned API under /api/v1 and keep the unversioned /api paths
+	// as aliases of v1, so future versions can be served side by side.
+	r.Route("/api", func(r chi.Router) {
+		r.Route("/v1", apiV1)
+		apiV1(r)
+
+		// Define routes for the internal network, available only to the trusted subnets
+		r.Route("/internal", func(r chi.Router) {
+			r.Use(middleware.WithSubnet(trusted))
+			r.With(defaultTimeout).Get("/stats", admin.Stats) // Global statistics of the service
+			r.With(defaultTimeout).Get("/top", admin.Top)     // Most clicked short URLs in a time window
+			r.Get("/stats/stream", admin.StatsStream)         // Live rolling totals, streamed until the client disconnects
+
+			// Capture a profile of the process, without a timeout since CPU profiles run for a while
+			r.With(middleware.RequireAdmin).Post("/profiles", profiles.Capture)
+		})
+
+		r.Get("/openapi.json", openapi.ServeSpec)        // OpenAPI document describing the HTTP API
+		r.Get("/docs", openapi.ServeUI)                  // Swagger UI for the OpenAPI document
+		r.Get("/docs/assets/{name}", openapi.ServeAsset) // Embedded scripts and styles of the Swagger UI
 	})
 
 	// Default route if no shortened URL is provided
@@ -82,5 +233,5 @@ func Init(baseURL string, logger *zap.Logger, withGzip bool, sv service.URLServi
 		http.Error(w, "Route not found", http.StatusNotFound)
 	})
 
-	return r
+	return root
 }
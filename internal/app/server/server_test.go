@@ -0,0 +1,295 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/config"
+	"github.com/atinyakov/go-url-shortener/internal/middleware"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+const testSecret = "test-secret-that-is-at-least-32-bytes"
+
+func newTestRouter(t *testing.T) http.Handler {
+	return newTestRouterWith(t, &config.Options{ResultHostname: "http://localhost:8080"}, nil, nil)
+}
+
+func newTestRouterWith(t *testing.T, cfg *config.Options, profiler http.Handler, trusted *middleware.TrustedSubnets) http.Handler {
+	s, _ := storage.CreateMemoryStorage()
+	resolver, _ := service.NewURLResolver(8, s)
+	sv, shutdown := service.NewURL(context.Background(), s, resolver, zap.NewNop(), "http://localhost:8080")
+	t.Cleanup(shutdown)
+
+	users := service.NewUsers(storage.NewMemoryUserStorage())
+	auth, err := service.NewAuth(sv, testSecret)
+	require.NoError(t, err)
+	auth.SetUsers(users)
+
+	health := service.NewHealth()
+	health.AddCheck("storage", sv.CheckStorage)
+	health.AddCheck("delete_worker", sv.CheckWorker)
+
+	return Init(cfg, zap.NewNop(), false, sv, auth, service.NewAPIKeys(storage.NewMemoryAPIKeyStorage()), users, service.NewAudit(storage.NewMemoryAuditStorage(), zap.NewNop()), health, profiler, trusted, nil, nil)
+}
+
+func TestVersionedAPI(t *testing.T) {
+	router := newTestRouter(t)
+
+	for _, path := range []string{"/api/v1/shorten", "/api/shorten"} {
+		t.Run(path, func(t *testing.T) {
+			body := bytes.NewBufferString(`{"url":"https://example.com` + path + `"}`)
+			req := httptest.NewRequest(http.MethodPost, path, body)
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+			require.Contains(t, rec.Body.String(), "http://localhost:8080/")
+		})
+	}
+}
+
+func TestQuickShorten(t *testing.T) {
+	router := newTestRouter(t)
+
+	// Issue an API key for a new user.
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/user/keys", nil))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	cookies := rec.Result().Cookies()
+	require.NotEmpty(t, cookies)
+	var issued models.APIKeyResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &issued))
+
+	serve := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/shorten"+query, nil)
+		req.Header.Set("Authorization", middleware.APIKeyScheme+" "+issued.Key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The token cookie alone does not shorten, so other sites cannot through a link
+	req := httptest.NewRequest(http.MethodGet, "/api/shorten?url="+url.QueryEscape("https://example.com/csrf"), nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = serve("?url=" + url.QueryEscape("https://example.com/quick?a=1&b=2"))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	short := rec.Body.String()
+	require.Contains(t, short, "http://localhost:8080/")
+
+	// The URL gets the responses of the POST path
+	rec = serve("?url=" + url.QueryEscape("https://example.com/quick?a=1&b=2"))
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Equal(t, short, rec.Body.String())
+
+	require.Equal(t, http.StatusBadRequest, serve("").Code)
+}
+
+func TestRateLimit(t *testing.T) {
+	cfg := &config.Options{ResultHostname: "http://localhost:8080", RateLimit: 1, RateLimitWindow: config.Duration{Duration: time.Minute}}
+	router := newTestRouterWith(t, cfg, nil, nil)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// The API shares one budget across its versioned and unversioned paths
+	rec := serve("/api/v1/user/urls")
+	require.NotEqual(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "0", rec.Header().Get(middleware.RateLimitRemainingHeader))
+	rec = serve("/api/user/urls")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// Probes are not limited
+	rec = serve("/healthz")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get(middleware.RateLimitLimitHeader))
+
+	// Without a limit, responses carry no rate limit headers
+	rec = httptest.NewRecorder()
+	newTestRouter(t).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/user/urls", nil))
+	require.Empty(t, rec.Header().Get(middleware.RateLimitLimitHeader))
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	router := newTestRouter(t)
+
+	// Shorten a URL as a cookie-authenticated user.
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/shorten", bytes.NewBufferString(`{"url":"https://example.com/key"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	cookies := rec.Result().Cookies()
+	require.NotEmpty(t, cookies)
+
+	// Issue an API key for the same user.
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/user/keys", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var issued models.APIKeyResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &issued))
+
+	// The key alone authenticates as the user, without a cookie.
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/user/urls", nil)
+	req.Header.Set("Authorization", "ApiKey "+issued.Key)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "https://example.com/key")
+	require.Empty(t, rec.Result().Cookies())
+
+	// An unknown key is rejected.
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/user/urls", nil)
+	req.Header.Set("Authorization", "ApiKey unknown")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAdminAPI(t *testing.T) {
+	router := newTestRouter(t)
+
+	auth, err := service.NewAuth(nil, testSecret)
+	require.NoError(t, err)
+	adminToken, err := auth.BuildAdminJWTString("admin-id")
+	require.NoError(t, err)
+
+	// A regular user is forbidden
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/stats", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	// An admin can read stats under both the versioned path and its alias
+	for _, path := range []string{"/api/v1/admin/stats", "/api/admin/stats"} {
+		req = httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: "token", Value: adminToken})
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var stats models.Stats
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+		require.Equal(t, 0, stats.URLs)
+		require.Equal(t, 0, stats.Users)
+	}
+}
+
+func TestHealthProbes(t *testing.T) {
+	router := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+
+	// The delete worker starts in the background
+	require.Eventually(t, func() bool {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec.Code == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.JSONEq(t, `{"status":"ok","checks":{"storage":{"status":"ok"},"delete_worker":{"status":"ok"}}}`, rec.Body.String())
+}
+
+func TestHealthProbes_NoMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Neither the service nor the authentication may be called by the probes.
+	sv := mocks.NewMockURLServiceIface(ctrl)
+	auth := mocks.NewMockAuthIface(ctrl)
+	auth.EXPECT().BuildJWTString().Return("", "", errors.New("storage is down")).AnyTimes()
+
+	health := service.NewHealth()
+	health.AddCheck("storage", func(ctx context.Context) error { return errors.New("storage is down") })
+	router := Init(&config.Options{ResultHostname: "http://localhost:8080"}, zap.NewNop(), false, sv, auth, mocks.NewMockAPIKeyIface(ctrl), mocks.NewMockUsersIface(ctrl), mocks.NewMockAuditIface(ctrl), health, nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Result().Cookies())
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Empty(t, rec.Result().Cookies())
+
+	// Other routes still authenticate
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/user/urls", nil))
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+func TestInternalStats(t *testing.T) {
+	trusted, err := middleware.NewTrustedSubnets([]string{"10.0.0.0/8", "fd00::/8"})
+	require.NoError(t, err)
+	router := newTestRouterWith(t, &config.Options{ResultHostname: "http://localhost:8080"}, nil, trusted)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		wantStatus int
+	}{
+		{name: "ipv4 trusted client", remoteAddr: "10.1.2.3:5000", wantStatus: http.StatusOK},
+		{name: "ipv6 trusted client", remoteAddr: "[fd00::1]:5000", wantStatus: http.StatusOK},
+		{name: "other client", remoteAddr: "192.168.1.1:5000", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestInternalProfiles_RequiresAdmin(t *testing.T) {
+	trusted, err := middleware.NewTrustedSubnets([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	router := newTestRouterWith(t, &config.Options{ResultHostname: "http://localhost:8080"}, nil, trusted)
+
+	// A trusted client still needs an admin token
+	req := httptest.NewRequest(http.MethodPost, "/api/internal/profiles?type=heap", nil)
+	req.RemoteAddr = "10.1.2.3:5000"
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+}
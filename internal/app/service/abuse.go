@@ -0,0 +1,234 @@
+// Package service provides abuse detection: the URLs created by every user and
+// client IP are counted over the last minute, and creators going past the
+// threshold are banned from creating URLs for a while. Counts and bans are
+// kept in memory, per instance.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+)
+
+// Kinds of banned creators.
+const (
+	// BanKindUser bans a user ID.
+	BanKindUser = "user"
+	// BanKindIP bans a client IP address.
+	BanKindIP = "ip"
+)
+
+// DefaultAbuseBanDuration is how long creators going past the threshold are
+// banned if no duration is set.
+const DefaultAbuseBanDuration = time.Hour
+
+// ErrInvalidBanKind is returned for ban kinds other than BanKindUser and BanKindIP.
+var ErrInvalidBanKind = errors.New(`ban kind must be "user" or "ip"`)
+
+// BannedError is returned when the user or the client IP creating URLs is banned.
+type BannedError struct {
+	Kind    string    // BanKindUser or BanKindIP
+	Subject string    // Banned user ID or client IP
+	Until   time.Time // When the ban ends
+}
+
+// Error implements the error interface. The client IP is left out, as logged
+// IPs may have to be anonymized.
+func (e *BannedError) Error() string {
+	if e.Kind == BanKindIP {
+		return "client IP is banned from creating URLs until " + e.Until.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s %s is banned from creating URLs until %s", e.Kind, e.Subject, e.Until.Format(time.RFC3339))
+}
+
+// clientIPKey is the context key of the client IP.
+type clientIPKey struct{}
+
+// WithClientIP returns a copy of ctx carrying the IP address of the client of
+// the request, whose creation velocity is tracked along with the one of the user.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// banKey identifies a creator.
+type banKey struct {
+	kind    string
+	subject string
+}
+
+// abuseGuard counts the URLs created by every creator in the last minute and
+// holds the bans of those who went past the threshold. Its zero value is
+// disabled, and it is safe for concurrent use.
+type abuseGuard struct {
+	mu        sync.Mutex
+	threshold int           // URLs per minute a creator may create, 0 disables the guard
+	banFor    time.Duration // How long creators going past the threshold are banned
+	counts    map[banKey]*rateCounter
+	bans      map[banKey]models.Ban
+	lastSweep time.Time // When ended bans and idle counters were last removed
+}
+
+// check counts n URLs created at now by the user and the client IP, if not
+// empty. It returns a BannedError if one of them is banned, or goes past the
+// threshold and gets banned.
+func (g *abuseGuard) check(userID, ip string, n int, now time.Time) ([]models.Ban, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.threshold <= 0 {
+		return nil, nil
+	}
+	g.sweep(now)
+
+	keys := make([]banKey, 0, 2)
+	if userID != "" {
+		keys = append(keys, banKey{kind: BanKindUser, subject: userID})
+	}
+	if ip != "" {
+		keys = append(keys, banKey{kind: BanKindIP, subject: ip})
+	}
+	for _, k := range keys {
+		if b, ok := g.bans[k]; ok && now.Before(b.Until) {
+			return nil, &BannedError{Kind: k.kind, Subject: k.subject, Until: b.Until}
+		}
+	}
+
+	var err error
+	var banned []models.Ban
+	for _, k := range keys {
+		c, ok := g.counts[k]
+		if !ok {
+			c = &rateCounter{}
+			g.counts[k] = c
+		}
+		c.add(now, n)
+		if c.total(now) <= g.threshold {
+			continue
+		}
+
+		b := models.Ban{Kind: k.kind, Subject: k.subject, Since: now.UTC(), Until: now.Add(g.banFor).UTC()}
+		g.bans[k] = b
+		delete(g.counts, k)
+		banned = append(banned, b)
+		if err == nil {
+			err = &BannedError{Kind: b.Kind, Subject: b.Subject, Until: b.Until}
+		}
+	}
+	return banned, err
+}
+
+// sweep removes the ended bans and the counters without creations in the last
+// minute, once a minute. It must be called with mu held.
+func (g *abuseGuard) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < time.Minute {
+		return
+	}
+	g.lastSweep = now
+
+	for k, b := range g.bans {
+		if !now.Before(b.Until) {
+			delete(g.bans, k)
+		}
+	}
+	for k, c := range g.counts {
+		if c.total(now) == 0 {
+			delete(g.counts, k)
+		}
+	}
+}
+
+// SetAbuseLimits bans users and client IPs creating more than perMinute URLs in
+// a minute from creating URLs for banFor; 0 disables the detection, and a
+// non-positive banFor falls back to DefaultAbuseBanDuration. Lowering the
+// threshold keeps the current bans. It is safe to call while the service
+// handles requests.
+func (s *URLService) SetAbuseLimits(perMinute int, banFor time.Duration) {
+	if banFor <= 0 {
+		banFor = DefaultAbuseBanDuration
+	}
+
+	s.abuse.mu.Lock()
+	defer s.abuse.mu.Unlock()
+
+	s.abuse.threshold, s.abuse.banFor = perMinute, banFor
+	if s.abuse.counts == nil {
+		s.abuse.counts = make(map[banKey]*rateCounter)
+		s.abuse.bans = make(map[banKey]models.Ban)
+	}
+}
+
+// checkAbuse counts n URLs created by the user and the client IP of ctx and
+// returns a BannedError if one of them is, or gets, banned.
+func (s *URLService) checkAbuse(ctx context.Context, userID string, n int) error {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	banned, err := s.abuse.check(userID, ip, n, time.Now())
+	for _, b := range banned {
+		// The client IP is left out, as logged IPs may have to be anonymized
+		s.logger.Warn("Banned URL creator going past the velocity threshold",
+			zap.String("kind", b.Kind), zap.String("user_id", userID), zap.Time("until", b.Until))
+	}
+	return err
+}
+
+// Bans returns the current bans of URL creators, the ones ending first first.
+func (s *URLService) Bans(ctx context.Context) []models.Ban {
+	now := time.Now()
+
+	s.abuse.mu.Lock()
+	defer s.abuse.mu.Unlock()
+
+	res := make([]models.Ban, 0, len(s.abuse.bans))
+	for _, b := range s.abuse.bans {
+		if now.Before(b.Until) {
+			res = append(res, b)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].Until.Equal(res[j].Until) {
+			return res[i].Until.Before(res[j].Until)
+		}
+		return res[i].Kind+res[i].Subject < res[j].Kind+res[j].Subject
+	})
+	return res
+}
+
+// LiftBan ends the ban of the user or client IP, depending on kind, right
+// away, auditing the user ID or only the kind for client IPs. It returns ErrInvalidBanKind for unknown kinds and storage.ErrNotFound
+// if the subject is not banned.
+func (s *URLService) LiftBan(ctx context.Context, kind string, subject string) error {
+	ctx, span := tracing.Start(ctx, "URLService.LiftBan", tracing.KindInternal)
+	defer span.End()
+
+	if kind != BanKindUser && kind != BanKindIP {
+		return ErrInvalidBanKind
+	}
+
+	s.abuse.mu.Lock()
+	defer s.abuse.mu.Unlock()
+
+	k := banKey{kind: kind, subject: subject}
+	b, ok := s.abuse.bans[k]
+	if !ok || !time.Now().Before(b.Until) {
+		return storage.ErrNotFound
+	}
+	delete(s.abuse.bans, k)
+	delete(s.abuse.counts, k)
+
+	// Like in logs, the client IP is left out of the audit log
+	target := kind + ":" + subject
+	if kind == BanKindIP {
+		target = kind
+	}
+	audit.AddTargets(ctx, target)
+	return nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLService_AbuseBans(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+
+	service, _ := NewURL(context.Background(), mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+	service.SetAbuseLimits(2, time.Hour)
+	ctx := WithClientIP(context.Background(), "192.0.2.1")
+
+	_, err := service.CreateURLRecord(ctx, "http://1.example.com", "user-id")
+	require.NoError(t, err)
+
+	// A batch going past the threshold bans both the user and the IP
+	_, err = service.CreateURLRecords(ctx, []models.BatchRequest{
+		{CorrelationID: "a", OriginalURL: "http://2.example.com"},
+		{CorrelationID: "b", OriginalURL: "http://3.example.com"},
+	}, "user-id")
+	var be *BannedError
+	require.True(t, errors.As(err, &be))
+	assert.Equal(t, BanKindUser, be.Kind)
+	assert.Equal(t, "user-id", be.Subject)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), be.Until, time.Minute)
+
+	bans := service.Bans(context.Background())
+	require.Len(t, bans, 2)
+	assert.ElementsMatch(t, []string{"ip:192.0.2.1", "user:user-id"}, []string{bans[0].Kind + ":" + bans[0].Subject, bans[1].Kind + ":" + bans[1].Subject})
+
+	// Banned creators are rejected, whatever else they send
+	_, err = service.CreateURLRecord(WithClientIP(context.Background(), "192.0.2.2"), "http://4.example.com", "user-id")
+	require.True(t, errors.As(err, &be))
+	assert.Equal(t, BanKindUser, be.Kind)
+	_, err = service.CreateURLRecord(ctx, "http://4.example.com", "other-user-id")
+	require.True(t, errors.As(err, &be))
+	assert.Equal(t, BanKindIP, be.Kind)
+	assert.NotContains(t, be.Error(), "192.0.2.1")
+
+	// Lifted bans let the creator back in, and are audited without the client IP
+	auditCtx := audit.NewContext(context.Background())
+	require.NoError(t, service.LiftBan(auditCtx, BanKindUser, "user-id"))
+	require.NoError(t, service.LiftBan(auditCtx, BanKindIP, "192.0.2.1"))
+	assert.Equal(t, []string{"user:user-id", "ip"}, audit.TargetsFromContext(auditCtx))
+	assert.ErrorIs(t, service.LiftBan(context.Background(), BanKindUser, "user-id"), storage.ErrNotFound)
+	assert.ErrorIs(t, service.LiftBan(context.Background(), "host", "user-id"), ErrInvalidBanKind)
+	assert.Empty(t, service.Bans(context.Background()))
+	_, err = service.CreateURLRecord(ctx, "http://4.example.com", "user-id")
+	require.NoError(t, err)
+}
+
+func TestURLService_NoAbuseBans(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+
+	service, _ := NewURL(context.Background(), mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	for _, long := range []string{"http://1.example.com", "http://2.example.com", "http://3.example.com"} {
+		_, err := service.CreateURLRecord(context.Background(), long, "user-id")
+		require.NoError(t, err)
+	}
+	assert.Empty(t, service.Bans(context.Background()))
+
+	// Disabling the detection lets banned creators in again
+	service.SetAbuseLimits(1, time.Hour)
+	_, err := service.CreateURLRecord(context.Background(), "http://4.example.com", "user-id")
+	require.NoError(t, err)
+	_, err = service.CreateURLRecord(context.Background(), "http://5.example.com", "user-id")
+	require.Error(t, err)
+	service.SetAbuseLimits(0, 0)
+	_, err = service.CreateURLRecord(context.Background(), "http://6.example.com", "user-id")
+	require.NoError(t, err)
+}
+
+func TestAbuseGuard(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	g := abuseGuard{
+		threshold: 3,
+		banFor:    10 * time.Minute,
+		counts:    make(map[banKey]*rateCounter),
+		bans:      make(map[banKey]models.Ban),
+	}
+
+	// Creations spread over more than a minute stay under the threshold
+	for i := 0; i < 6; i++ {
+		banned, err := g.check("user-id", "", 1, now.Add(time.Duration(i)*25*time.Second))
+		require.NoError(t, err)
+		assert.Empty(t, banned)
+	}
+
+	now = now.Add(5 * time.Minute)
+	_, err := g.check("user-id", "", 3, now)
+	require.NoError(t, err)
+	banned, err := g.check("user-id", "", 1, now.Add(time.Second))
+	require.Error(t, err)
+	assert.Equal(t, []models.Ban{{Kind: BanKindUser, Subject: "user-id", Since: now.Add(time.Second), Until: now.Add(10*time.Minute + time.Second)}}, banned)
+
+	// The ban ends, and is swept with the idle counters
+	_, err = g.check("user-id", "", 1, now.Add(9*time.Minute))
+	require.Error(t, err)
+	_, err = g.check("other-user-id", "", 1, now.Add(11*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, g.bans)
+	assert.Len(t, g.counts, 1)
+}
@@ -0,0 +1,111 @@
+// Package service provides an allowlist restricting the destination domains
+// that may be shortened, for internal deployments.
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+)
+
+// DomainNotAllowedError is returned when the allowlist is enabled and a URL
+// points to a domain it does not match.
+type DomainNotAllowedError struct {
+	URL    string // URL that was rejected
+	Domain string // Host name of the URL
+}
+
+// Error implements the error interface.
+func (e *DomainNotAllowedError) Error() string {
+	return fmt.Sprintf("domain %q is not allowed", e.Domain)
+}
+
+// Allowlist is a set of domain patterns: "example.com" matches that host only,
+// "*.example.com" matches any of its subdomains but not example.com itself.
+// It can be replaced while URLs are checked against it.
+type Allowlist struct {
+	patterns atomic.Pointer[[]string]
+}
+
+// NewAllowlist returns an Allowlist of the given patterns.
+func NewAllowlist(patterns []string) *Allowlist {
+	a := &Allowlist{}
+	a.Set(patterns)
+	return a
+}
+
+// Set replaces the patterns. An empty list disables the allowlist.
+func (a *Allowlist) Set(patterns []string) {
+	normalized := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if p = normalizeDomain(p); p != "" {
+			normalized = append(normalized, p)
+		}
+	}
+	a.patterns.Store(&normalized)
+}
+
+// Enabled reports whether the allowlist has patterns; without them every
+// domain is allowed.
+func (a *Allowlist) Enabled() bool {
+	if a == nil {
+		return false
+	}
+	patterns := a.patterns.Load()
+	return patterns != nil && len(*patterns) > 0
+}
+
+// Allowed reports whether host matches one of the patterns.
+func (a *Allowlist) Allowed(host string) bool {
+	host = normalizeDomain(host)
+	if a == nil || host == "" {
+		return false
+	}
+	patterns := a.patterns.Load()
+	if patterns == nil {
+		return false
+	}
+
+	for _, p := range *patterns {
+		if suffix, ok := strings.CutPrefix(p, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == p {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAllowlist makes the service shorten only URLs whose host matches a, if
+// a has patterns; nil disables the check. The allowlist may be updated while
+// the service runs.
+func (s *URLService) SetAllowlist(a *Allowlist) {
+	s.allowlist = a
+}
+
+// checkAllowlist returns a DomainNotAllowedError for the first URL whose host
+// does not match the allowlist, logging the offending domain.
+func (s *URLService) checkAllowlist(ctx context.Context, longs ...string) error {
+	if !s.allowlist.Enabled() {
+		return nil
+	}
+
+	for _, long := range longs {
+		host := urlHost(long)
+		if !s.allowlist.Allowed(host) {
+			logger.FromContext(ctx, s.logger).Warn("URL rejected by the allowlist",
+				zap.String("domain", host), zap.String("url", long))
+			return &DomainNotAllowedError{URL: long, Domain: host}
+		}
+	}
+	return nil
+}
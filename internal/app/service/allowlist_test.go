@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestAllowlist_Allowed(t *testing.T) {
+	a := NewAllowlist([]string{"Intranet.example", "*.corp.example"})
+	require.True(t, a.Enabled())
+
+	tests := map[string]bool{
+		"intranet.example":      true,
+		"INTRANET.example.":     true,
+		"www.intranet.example":  false,
+		"wiki.corp.example":     true,
+		"a.b.corp.example":      true,
+		"corp.example":          false,
+		"notcorp.example":       false,
+		"wiki.corp.example.com": false,
+		"":                      false,
+	}
+	for host, want := range tests {
+		assert.Equal(t, want, a.Allowed(host), host)
+	}
+
+	a.Set(nil)
+	assert.False(t, a.Enabled())
+}
+
+func TestURLService_Allowlist(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	core, logs := observer.New(zap.WarnLevel)
+
+	service, _ := NewURL(context.Background(), mockStorage, mockResolver, zap.New(core), "http://baseurl")
+	service.SetAllowlist(NewAllowlist([]string{"*.corp.example"}))
+
+	_, err := service.CreateURLRecord(context.Background(), "https://wiki.corp.example/page", "user-id")
+	require.NoError(t, err)
+
+	_, err = service.CreateURLRecord(context.Background(), "https://elsewhere.example/page", "user-id")
+	var ne *DomainNotAllowedError
+	require.True(t, errors.As(err, &ne))
+	assert.Equal(t, "elsewhere.example", ne.Domain)
+
+	batch, err := service.CreateURLRecords(context.Background(), []models.BatchRequest{
+		{CorrelationID: "a", OriginalURL: "https://docs.corp.example"},
+		{CorrelationID: "b", OriginalURL: "https://other.example"},
+	}, "user-id")
+	require.NoError(t, err)
+	assert.Equal(t, models.BatchStatusCreated, (*batch)[0].Status)
+	assert.Equal(t, models.BatchStatusInvalid, (*batch)[1].Status)
+	assert.Contains(t, (*batch)[1].Error, "other.example")
+
+	// Rejections are logged with the offending domain
+	rejected := logs.FilterMessage("URL rejected by the allowlist").All()
+	require.Len(t, rejected, 2)
+	assert.Equal(t, "elsewhere.example", rejected[0].ContextMap()["domain"])
+
+	// An empty allowlist allows any domain
+	service.SetAllowlist(NewAllowlist(nil))
+	_, err = service.CreateURLRecord(context.Background(), "https://elsewhere.example/page", "user-id")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,74 @@
+package service_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestAPIKeysIssueAndResolve(t *testing.T) {
+	keys := service.NewAPIKeys(storage.NewMemoryAPIKeyStorage())
+	ctx := context.Background()
+
+	key, err := keys.Issue(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, key, 64)
+
+	other, err := keys.Issue(ctx, "user-1")
+	require.NoError(t, err)
+	require.NotEqual(t, key, other)
+
+	userID, err := keys.Resolve(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, "user-1", userID)
+
+	_, err = keys.Resolve(ctx, "unknown")
+	require.ErrorIs(t, err, service.ErrInvalidAPIKey)
+
+	_, err = keys.Resolve(ctx, "")
+	require.ErrorIs(t, err, service.ErrInvalidAPIKey)
+}
+
+func TestAPIKeysStoresOnlyHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockAPIKeyStorage(ctrl)
+
+	var saved string
+	mockStorage.EXPECT().
+		SaveAPIKey(gomock.Any(), gomock.Any(), "user-1").
+		DoAndReturn(func(_ context.Context, hash string, _ string) error {
+			saved = hash
+			return nil
+		})
+
+	key, err := service.NewAPIKeys(mockStorage).Issue(context.Background(), "user-1")
+	require.NoError(t, err)
+
+	sum := sha256.Sum256([]byte(key))
+	require.Equal(t, hex.EncodeToString(sum[:]), saved)
+	require.NotEqual(t, key, saved)
+}
+
+func TestAPIKeysIssueStorageError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockAPIKeyStorage(ctrl)
+	mockStorage.EXPECT().
+		SaveAPIKey(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(errors.New("db down"))
+
+	_, err := service.NewAPIKeys(mockStorage).Issue(context.Background(), "user-1")
+	require.Error(t, err)
+}
@@ -0,0 +1,46 @@
+// Package service provides the audit log of mutating operations, used for
+// abuse investigations and compliance.
+package service
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// Audit records mutating operations into an append-only audit store.
+type Audit struct {
+	storage AuditStorage // Storage backend for audit events.
+	logger  *zap.Logger  // Logger for reporting events that could not be stored.
+}
+
+// NewAudit creates a new Audit instance with the given storage backend and logger.
+func NewAudit(s AuditStorage, l *zap.Logger) *Audit {
+	return &Audit{
+		storage: s,
+		logger:  l,
+	}
+}
+
+// Record appends an event to the audit log. The response has already been sent
+// when events are recorded, so failures are logged together with the event
+// instead of being returned.
+func (a *Audit) Record(ctx context.Context, e storage.AuditEvent) {
+	if err := a.storage.AppendAudit(ctx, e); err != nil {
+		logger.FromContext(ctx, a.logger).Error("cannot append audit event",
+			zap.Error(err),
+			zap.String("user_id", e.UserID),
+			zap.String("action", e.Action),
+			zap.Strings("targets", e.Targets),
+			zap.String("remote_addr", e.RemoteAddr),
+		)
+	}
+}
+
+// Query returns the audit events matching the filter, newest first.
+func (a *Audit) Query(ctx context.Context, f storage.AuditFilter) ([]storage.AuditEvent, error) {
+	return a.storage.FindAudit(ctx, f)
+}
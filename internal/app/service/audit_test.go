@@ -0,0 +1,38 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestAuditRecordAndQuery(t *testing.T) {
+	a := service.NewAudit(storage.NewMemoryAuditStorage(), zap.NewNop())
+	ctx := context.Background()
+
+	a.Record(ctx, storage.AuditEvent{UserID: "user-1", Action: "DELETE /api/v1/user/urls", Targets: []string{"abc"}})
+
+	events, err := a.Query(ctx, storage.AuditFilter{UserID: "user-1"})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, []string{"abc"}, events[0].Targets)
+}
+
+func TestAuditRecordStorageError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockAuditStorage(ctrl)
+	mockStorage.EXPECT().AppendAudit(gomock.Any(), gomock.Any()).Return(errors.New("db down"))
+
+	// Failures are logged, not propagated
+	service.NewAudit(mockStorage, zap.NewNop()).Record(context.Background(), storage.AuditEvent{})
+}
@@ -5,17 +5,24 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
 )
 
 // AuthIface defines the interface for JWT authentication used in middleware.
 type AuthIface interface {
 	BuildJWTString() (string, string, error)
 	ParseClaims(c *http.Cookie) (*Claims, error)
+	ParseRawJWT(tokenString string) (*Claims, error)
 }
 
 // Claims represents the claims that are included in the JWT token.
@@ -26,26 +33,75 @@ type Claims struct {
 	jwt.RegisteredClaims
 	// UserID is a custom claim for storing the user ID.
 	UserID string `json:"user_id"`
+	// Admin grants access to the admin API.
+	Admin bool `json:"admin,omitempty"`
 }
 
 // TokenExp defines the expiration time of the JWT token (1 year).
 const TokenExp = time.Hour * 24 * 365 // 1 year
 
-// secretKey is used for signing JWT tokens. It should be kept private.
-const secretKey = "supersecretkey"
+// MinSecretLength is the minimum length in bytes of a key used for signing JWT tokens.
+const MinSecretLength = 32
+
+// ErrWeakSecret is returned by NewAuth when a signing key is shorter than MinSecretLength.
+var ErrWeakSecret = fmt.Errorf("jwt secret must be at least %d bytes long", MinSecretLength)
 
 // Auth provides methods for building and parsing JWT tokens,
 // as well as handling user authentication.
 type Auth struct {
 	// s is the URL service interface, used for interacting with the storage backend.
 	s URLServiceIface
+	// keys holds the keys accepted for verification; the first one is used for signing.
+	keys []SigningKey
+	// users registers the accounts of newly issued user IDs; nil disables accounts.
+	users UsersIface
 }
 
 // NewAuth creates a new Auth instance, initializing it with the given URLServiceIface.
-func NewAuth(s URLServiceIface) *Auth {
+// Tokens are signed with secret; tokens signed with any of the previous secrets
+// are still accepted, which allows rotating the key without logging users out.
+// It returns ErrWeakSecret if any of the keys is too short.
+func NewAuth(s URLServiceIface, secret string, previous ...string) (*Auth, error) {
+	keys := make([]SigningKey, 0, len(previous)+1)
+	for _, k := range append([]string{secret}, previous...) {
+		key, err := HMACKey(k)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return NewAuthWithKeys(s, keys)
+}
+
+// NewAuthWithKeys creates a new Auth instance using the given key set.
+// Tokens are signed with the first key, which must have a private part;
+// all keys of the set are accepted for verification.
+func NewAuthWithKeys(s URLServiceIface, keys []SigningKey) (*Auth, error) {
+	if len(keys) == 0 || keys[0].Private == nil {
+		return nil, ErrNoSigningKey
+	}
+
 	return &Auth{
-		s: s,
+		s:    s,
+		keys: keys,
+	}, nil
+}
+
+// SetUsers makes the Auth register an account for every newly issued user ID.
+// It must be called before the Auth starts issuing tokens.
+func (a *Auth) SetUsers(u UsersIface) {
+	a.users = u
+}
+
+// GenerateSecret returns a random key suitable for NewAuth. It is meant for
+// running without a configured key; tokens signed with it do not survive a restart.
+func GenerateSecret() (string, error) {
+	buf := make([]byte, MinSecretLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
 	}
+	return hex.EncodeToString(buf), nil
 }
 
 // BuildJWTString generates a new JWT token for the user. It creates a unique user ID
@@ -60,22 +116,26 @@ func (a Auth) BuildJWTString() (string, string, error) {
 	// Generate a unique user ID and ensure it does not already exist in the system
 	for {
 		tempID := uuid.New().String() // Generate a temporary UUID
-		if res, _ := a.s.GetURLByUserID(ctx, tempID); len(*res) == 0 {
+		if res, _ := a.s.GetURLByUserID(ctx, tempID, storage.ListOptions{Limit: 1}); len(*res) == 0 {
 			userID = tempID // Use the ID if it's unique
 			break
 		}
 	}
 
+	// Create the account of the new user
+	if a.users != nil {
+		if err := a.users.Register(ctx, userID); err != nil {
+			return "", "", err
+		}
+	}
+
 	// Create a new JWT token with the generated user ID and set the expiration date
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+	tokenString, err := a.sign(Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExp)), // Set expiration
 		},
 		UserID: userID, // Set the custom UserID claim
 	})
-
-	// Sign the token and return the string representation of the token
-	tokenString, err := token.SignedString([]byte(secretKey))
 	if err != nil {
 		return "", "", err
 	}
@@ -83,20 +143,67 @@ func (a Auth) BuildJWTString() (string, string, error) {
 	return tokenString, userID, nil // Return the JWT token and the user ID
 }
 
+// BuildAdminJWTString generates a JWT token with the admin claim for the given user ID.
+// Admin tokens are not issued over HTTP; they are minted by operators at startup.
+func (a Auth) BuildAdminJWTString(userID string) (string, error) {
+	return a.sign(Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExp)), // Set expiration
+		},
+		UserID: userID,
+		Admin:  true,
+	})
+}
+
+// sign signs the claims with the current key of the key set.
+func (a Auth) sign(claims Claims) (string, error) {
+	signingKey := a.keys[0]
+	token := jwt.NewWithClaims(signingKey.Method, claims)
+
+	// Name the signing key so verifiers can pick it from their key set
+	if signingKey.ID != "" {
+		token.Header["kid"] = signingKey.ID
+	}
+
+	// Sign the token and return the string representation of the token
+	return token.SignedString(signingKey.Private)
+}
+
 // ParseClaims parses the JWT token from the provided HTTP cookie and returns
-// the claims embedded within the token.
+// the claims embedded within the token. The token may be signed with the
+// current key or with any of the previous keys.
 func (a Auth) ParseClaims(c *http.Cookie) (*Claims, error) {
-	// Parse the JWT token from the cookie value
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(c.Value, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil // Provide the signing key for verification
-	})
+	return a.ParseRawJWT(c.Value)
+}
 
-	// If there's an error or the token is invalid, return an error
-	if err != nil || !token.Valid {
-		return nil, err
+// ParseRawJWT parses and verifies a JWT token string and returns its claims.
+// Tokens with a "kid" header are verified only with the key of that ID;
+// tokens without one are tried against every key of their algorithm.
+func (a Auth) ParseRawJWT(tokenString string) (*Claims, error) {
+	err := errors.New("invalid token")
+
+	// Try every accepted key, starting with the current one
+	for _, key := range a.keys {
+		claims := &Claims{}
+		var token *jwt.Token
+		token, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			// Only accept the algorithm of the key to prevent algorithm substitution
+			if token.Method.Alg() != key.Method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			// Skip keys other than the one named by the token
+			if kid, ok := token.Header["kid"].(string); ok && kid != key.ID {
+				return nil, fmt.Errorf("token is signed with another key: %s", kid)
+			}
+			return key.Public, nil // Provide the verification key
+		})
+
+		// Return the parsed claims of the first key that verifies the token
+		if err == nil && token.Valid {
+			return claims, nil
+		}
 	}
 
-	// Return the parsed claims
-	return claims, nil
+	// None of the keys verified the token
+	return nil, err
 }
@@ -1,6 +1,7 @@
 package service_test
 
 import (
+	"context"
 	"net/http"
 	"testing"
 	"time"
@@ -15,6 +16,11 @@ import (
 	"github.com/atinyakov/go-url-shortener/internal/models"
 )
 
+const (
+	testSecret     = "test-secret-that-is-at-least-32-bytes"
+	previousSecret = "previous-secret-at-least-32-bytes-long"
+)
+
 func TestBuildJWTString(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -23,10 +29,11 @@ func TestBuildJWTString(t *testing.T) {
 
 	// Mock GetURLByUserID to return empty list to simulate unique user ID
 	mockURLService.EXPECT().
-		GetURLByUserID(gomock.Any(), gomock.Any()).
+		GetURLByUserID(gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(&[]models.ByIDRequest{}, nil)
 
-	auth := service.NewAuth(mockURLService)
+	auth, err := service.NewAuth(mockURLService, testSecret)
+	require.NoError(t, err)
 
 	tokenStr, userID, err := auth.BuildJWTString()
 
@@ -36,7 +43,7 @@ func TestBuildJWTString(t *testing.T) {
 
 	// Decode token to verify claims
 	token, err := jwt.ParseWithClaims(tokenStr, &service.Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte("supersecretkey"), nil
+		return []byte(testSecret), nil
 	})
 	require.NoError(t, err)
 	require.True(t, token.Valid)
@@ -48,7 +55,8 @@ func TestBuildJWTString(t *testing.T) {
 }
 
 func TestParseClaims(t *testing.T) {
-	auth := service.NewAuth(nil) // no need for URLServiceIface
+	auth, err := service.NewAuth(nil, testSecret, previousSecret) // no need for URLServiceIface
+	require.NoError(t, err)
 
 	t.Run("valid token", func(t *testing.T) {
 		// Create a test token
@@ -60,7 +68,7 @@ func TestParseClaims(t *testing.T) {
 			UserID: userID,
 		})
 
-		signedToken, err := token.SignedString([]byte("supersecretkey"))
+		signedToken, err := token.SignedString([]byte(testSecret))
 		require.NoError(t, err)
 
 		cookie := &http.Cookie{
@@ -73,6 +81,36 @@ func TestParseClaims(t *testing.T) {
 		require.Equal(t, userID, claims.UserID)
 	})
 
+	t.Run("token signed with a previous key", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, service.Claims{UserID: "old-user-id"})
+		signedToken, err := token.SignedString([]byte(previousSecret))
+		require.NoError(t, err)
+
+		claims, err := auth.ParseClaims(&http.Cookie{Name: "token", Value: signedToken})
+		require.NoError(t, err)
+		require.Equal(t, "old-user-id", claims.UserID)
+	})
+
+	t.Run("token signed with an unknown key", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, service.Claims{UserID: "user-id"})
+		signedToken, err := token.SignedString([]byte("some-other-secret-of-32-bytes-or-more"))
+		require.NoError(t, err)
+
+		claims, err := auth.ParseClaims(&http.Cookie{Name: "token", Value: signedToken})
+		require.Error(t, err)
+		require.Nil(t, claims)
+	})
+
+	t.Run("unsigned token", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodNone, service.Claims{UserID: "user-id"})
+		signedToken, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+		require.NoError(t, err)
+
+		claims, err := auth.ParseClaims(&http.Cookie{Name: "token", Value: signedToken})
+		require.Error(t, err)
+		require.Nil(t, claims)
+	})
+
 	t.Run("invalid token", func(t *testing.T) {
 		cookie := &http.Cookie{
 			Name:  "token",
@@ -84,3 +122,69 @@ func TestParseClaims(t *testing.T) {
 		require.Nil(t, claims)
 	})
 }
+
+func TestNewAuthValidatesSecrets(t *testing.T) {
+	_, err := service.NewAuth(nil, "")
+	require.ErrorIs(t, err, service.ErrWeakSecret)
+
+	_, err = service.NewAuth(nil, "short")
+	require.ErrorIs(t, err, service.ErrWeakSecret)
+
+	_, err = service.NewAuth(nil, testSecret, "short")
+	require.ErrorIs(t, err, service.ErrWeakSecret)
+
+	_, err = service.NewAuth(nil, testSecret, previousSecret)
+	require.NoError(t, err)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	first, err := service.GenerateSecret()
+	require.NoError(t, err)
+	second, err := service.GenerateSecret()
+	require.NoError(t, err)
+
+	require.NotEqual(t, first, second)
+	_, err = service.NewAuth(nil, first)
+	require.NoError(t, err)
+}
+
+func TestBuildAdminJWTString(t *testing.T) {
+	auth, err := service.NewAuth(nil, testSecret)
+	require.NoError(t, err)
+
+	tokenStr, err := auth.BuildAdminJWTString("admin-id")
+	require.NoError(t, err)
+
+	claims, err := auth.ParseRawJWT(tokenStr)
+	require.NoError(t, err)
+	require.Equal(t, "admin-id", claims.UserID)
+	require.True(t, claims.Admin)
+}
+
+func TestBuildJWTStringRegistersUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockURLService := mocks.NewMockURLServiceIface(ctrl)
+	mockURLService.EXPECT().
+		GetURLByUserID(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&[]models.ByIDRequest{}, nil)
+
+	mockUsers := mocks.NewMockUsersIface(ctrl)
+
+	auth, err := service.NewAuth(mockURLService, testSecret)
+	require.NoError(t, err)
+	auth.SetUsers(mockUsers)
+
+	var registered string
+	mockUsers.EXPECT().
+		Register(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, id string) error {
+			registered = id
+			return nil
+		})
+
+	_, userID, err := auth.BuildJWTString()
+	require.NoError(t, err)
+	require.Equal(t, userID, registered)
+}
@@ -0,0 +1,162 @@
+// Package service provides per-item results of batch shortening: one URL of
+// a batch that may not be shortened, or was shortened before, does not fail
+// the whole batch, so that large imports go through with a status for each URL.
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// errShortTaken is the error of a batch URL whose short URL belongs to another URL.
+var errShortTaken = errors.New("short URL is already taken")
+
+// SetStrictBatches makes CreateURLRecords reject a whole batch if one of its
+// URLs cannot be created, writing the others in a single call of WriteAll,
+// rather than returning a status for each URL. It must be called before the
+// service starts handling requests.
+func (s *URLService) SetStrictBatches(strict bool) {
+	s.strictBatches = strict
+}
+
+// firstOccurrences returns, for each URL of the batch, the index of the first
+// URL of the batch with the same original URL.
+func firstOccurrences(rs []models.BatchRequest) []int {
+	firsts := make([]int, len(rs))
+	seen := make(map[string]int, len(rs))
+	for i, url := range rs {
+		first, ok := seen[url.OriginalURL]
+		if !ok {
+			first = i
+			seen[url.OriginalURL] = i
+		}
+		firsts[i] = first
+	}
+	return firsts
+}
+
+// createEach creates the URLs of the batch one by one, returning the status of
+// each of them in the order of rs: BatchStatusInvalid with the error for URLs
+// that may not be shortened or whose activation window, tags, title or
+// description are invalid, BatchStatusExists with the stored short URL for
+// URLs shortened before, and BatchStatusCreated for the others. A URL
+// repeated in the batch is handled once, at its first occurrence, and its
+// other occurrences get the same result. Only the distinct valid URLs not
+// stored yet count towards the quota and the creation velocity. If the
+// storage fails, the URLs created before stay created and the error is
+// returned; the batch can then be sent again.
+func (s *URLService) createEach(ctx context.Context, rs []models.BatchRequest, userID string) (*[]models.BatchResponse, error) {
+	results := make([]models.BatchResponse, len(rs))
+	tags := make([][]string, len(rs))
+	titles, descriptions := make([]string, len(rs)), make([]string, len(rs))
+	firsts := firstOccurrences(rs)
+	valid := make([]int, 0, len(rs))
+	for i, url := range rs {
+		results[i].CorrelationID = url.CorrelationID
+		if firsts[i] != i {
+			continue
+		}
+		err := checkWindow(url.NotBefore, url.NotAfter)
+		if err == nil {
+			tags[i], err = normalizeTags(url.Tags)
+		}
+		if err == nil {
+			titles[i], descriptions[i], err = normalizeDetails(url.Title, url.Description)
+		}
+		if err == nil {
+			err = s.checkBlocklist(ctx, url.OriginalURL)
+		}
+		if err == nil {
+			err = s.checkAllowlist(ctx, url.OriginalURL)
+		}
+		if err != nil {
+			results[i].Status, results[i].Error = models.BatchStatusInvalid, err.Error()
+			continue
+		}
+		valid = append(valid, i)
+	}
+
+	// Repeated URLs get the result of their first occurrence
+	defer func() {
+		for i, first := range firsts {
+			if first != i {
+				results[i].ShortURL, results[i].Status, results[i].Error = results[first].ShortURL, results[first].Status, results[first].Error
+			}
+		}
+	}()
+
+	if len(valid) == 0 {
+		return &results, nil
+	}
+
+	// Only the URLs not stored yet count towards the quota and the creation
+	// velocity, so that importing a list again does not use them up
+	shorts := make([]string, len(rs))
+	fresh := 0
+	for _, i := range valid {
+		shorts[i] = s.resolver.LongToShort(rs[i].OriginalURL)
+		_, err := s.repository.FindByShort(ctx, shorts[i])
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			fresh++
+		case err != nil:
+			return &results, err
+		}
+	}
+	if fresh > 0 {
+		if err := s.checkAbuse(ctx, userID, fresh); err != nil {
+			return &results, err
+		}
+		if err := s.checkQuota(ctx, userID, fresh); err != nil {
+			return &results, err
+		}
+	}
+
+	tenant := s.Tenant(ctx)
+	expiresAt := s.expiresAt()
+	createdAt := time.Now().UTC()
+	created := make([]storage.URLRecord, 0, len(valid))
+	for _, i := range valid {
+		url := rs[i]
+		record := storage.URLRecord{
+			Original:    url.OriginalURL,
+			ID:          url.CorrelationID,
+			Short:       shorts[i],
+			UserID:      userID,
+			ExpiresAt:   expiresAt,
+			NotBefore:   url.NotBefore,
+			NotAfter:    url.NotAfter,
+			CreatedAt:   &createdAt,
+			Tags:        tags[i],
+			Title:       titles[i],
+			Description: descriptions[i],
+		}
+
+		stored, err := s.repository.Write(ctx, record)
+		switch {
+		case err == nil:
+			results[i].ShortURL, results[i].Status = tenant.ShortURL(record.Short), models.BatchStatusCreated
+			created = append(created, record)
+			s.created.add(time.Now(), 1)
+			audit.AddTargets(ctx, record.Short)
+			s.publish(ctx, EventCreated, record)
+		case errors.Is(err, storage.ErrConflict) && stored != nil:
+			results[i].ShortURL, results[i].Status = tenant.ShortURL(stored.Short), models.BatchStatusExists
+		case errors.Is(err, storage.ErrConflict):
+			results[i].Status, results[i].Error = models.BatchStatusInvalid, errShortTaken.Error()
+		default:
+			s.scanThreats(ctx, created...)
+			s.fetchTitles(ctx, created...)
+			return &results, err
+		}
+	}
+	s.scanThreats(ctx, created...)
+	s.fetchTitles(ctx, created...)
+
+	return &results, nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLService_CreateURLRecords_PerItem(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	resolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, resolver, zap.NewNop(), "http://baseurl")
+	service.SetBlocklist(NewBlocklist([]string{"evil.example"}))
+
+	existing, err := service.CreateURLRecord(ctx, "https://old.example", "other-user")
+	require.NoError(t, err)
+
+	now := time.Now()
+	batch, err := service.CreateURLRecords(ctx, []models.BatchRequest{
+		{CorrelationID: "a", OriginalURL: "https://new.example"},
+		{CorrelationID: "b", OriginalURL: "https://old.example"},
+		{CorrelationID: "c", OriginalURL: "https://evil.example"},
+		{CorrelationID: "d", OriginalURL: "https://window.example", NotBefore: &now, NotAfter: &now},
+	}, "user-id")
+	require.NoError(t, err)
+	require.Len(t, *batch, 4)
+
+	// One result per URL, in the order of the request
+	results := *batch
+	assert.Equal(t, models.BatchResponse{CorrelationID: "a", ShortURL: "http://baseurl/" + resolver.LongToShort("https://new.example"), Status: models.BatchStatusCreated}, results[0])
+	assert.Equal(t, models.BatchResponse{CorrelationID: "b", ShortURL: "http://baseurl/" + existing.Short, Status: models.BatchStatusExists}, results[1])
+	assert.Equal(t, "c", results[2].CorrelationID)
+	assert.Equal(t, models.BatchStatusInvalid, results[2].Status)
+	assert.Empty(t, results[2].ShortURL)
+	assert.NotEmpty(t, results[2].Error)
+	assert.Equal(t, models.BatchResponse{CorrelationID: "d", Status: models.BatchStatusInvalid, Error: ErrInvalidWindow.Error()}, results[3])
+
+	stored, err := mockStorage.FindByUserID(ctx, "user-id", storage.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, *stored, 1)
+	assert.Equal(t, "https://new.example", (*stored)[0].Original)
+}
+
+func TestURLService_CreateURLRecords_QuotaCountsValidURLs(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	resolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, resolver, zap.NewNop(), "http://baseurl")
+	service.SetBlocklist(NewBlocklist([]string{"evil.example"}))
+	service.SetURLQuota(1)
+
+	batch, err := service.CreateURLRecords(ctx, []models.BatchRequest{
+		{CorrelationID: "a", OriginalURL: "https://a.example"},
+		{CorrelationID: "b", OriginalURL: "https://evil.example"},
+	}, "user-id")
+	require.NoError(t, err)
+	assert.Equal(t, models.BatchStatusCreated, (*batch)[0].Status)
+
+	var qe *QuotaError
+	_, err = service.CreateURLRecords(ctx, []models.BatchRequest{{CorrelationID: "c", OriginalURL: "https://c.example"}}, "user-id")
+	assert.ErrorAs(t, err, &qe)
+}
+
+func TestURLService_CreateURLRecords_Strict(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	resolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, resolver, zap.NewNop(), "http://baseurl")
+	service.SetStrictBatches(true)
+
+	_, err := service.CreateURLRecord(ctx, "https://old.example", "user-id")
+	require.NoError(t, err)
+
+	// One existing URL fails the whole batch
+	_, err = service.CreateURLRecords(ctx, []models.BatchRequest{
+		{CorrelationID: "a", OriginalURL: "https://new.example"},
+		{CorrelationID: "b", OriginalURL: "https://old.example"},
+	}, "user-id")
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	count, err := mockStorage.CountByUserID(ctx, "user-id")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	batch, err := service.CreateURLRecords(ctx, []models.BatchRequest{{CorrelationID: "a", OriginalURL: "https://new.example"}}, "user-id")
+	require.NoError(t, err)
+	assert.Equal(t, models.BatchStatusCreated, (*batch)[0].Status)
+}
+
+func TestURLService_CreateURLRecords_Deduplicates(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict=%t", strict), func(t *testing.T) {
+			ctx := context.Background()
+			mockStorage, _ := storage.CreateMemoryStorage()
+			resolver, _ := NewURLResolver(8, mockStorage)
+			service, _ := NewURL(ctx, mockStorage, resolver, zap.NewNop(), "http://baseurl")
+			service.SetStrictBatches(strict)
+			service.SetURLQuota(2)
+
+			batch, err := service.CreateURLRecords(ctx, []models.BatchRequest{
+				{CorrelationID: "a", OriginalURL: "https://a.example"},
+				{CorrelationID: "b", OriginalURL: "https://b.example"},
+				{CorrelationID: "c", OriginalURL: "https://a.example"},
+			}, "user-id")
+			require.NoError(t, err)
+
+			// All correlation IDs of a repeated URL map to its single short URL
+			results := *batch
+			require.Len(t, results, 3)
+			assert.Equal(t, []string{"a", "b", "c"}, []string{results[0].CorrelationID, results[1].CorrelationID, results[2].CorrelationID})
+			assert.Equal(t, results[0].ShortURL, results[2].ShortURL)
+			assert.NotEqual(t, results[0].ShortURL, results[1].ShortURL)
+			for _, r := range results {
+				assert.Equal(t, models.BatchStatusCreated, r.Status)
+			}
+
+			count, err := mockStorage.CountByUserID(ctx, "user-id")
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+		})
+	}
+}
+
+func TestURLService_CreateURLRecords_ReimportIsFree(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	resolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, resolver, zap.NewNop(), "http://baseurl")
+	service.SetURLQuota(2)
+	service.SetAbuseLimits(3, time.Hour)
+
+	batch := []models.BatchRequest{
+		{CorrelationID: "a", OriginalURL: "https://a.example"},
+		{CorrelationID: "b", OriginalURL: "https://b.example"},
+	}
+	_, err := service.CreateURLRecords(ctx, batch, "user-id")
+	require.NoError(t, err)
+
+	// URLs stored before use up neither the quota nor the velocity threshold
+	for range 2 {
+		results, err := service.CreateURLRecords(ctx, batch, "user-id")
+		require.NoError(t, err)
+		for _, r := range *results {
+			assert.Equal(t, models.BatchStatusExists, r.Status)
+		}
+	}
+	assert.Empty(t, service.Bans(ctx))
+
+	// New URLs still count
+	_, err = service.CreateURLRecords(ctx, []models.BatchRequest{{CorrelationID: "c", OriginalURL: "https://c.example"}}, "user-id")
+	var qe *QuotaError
+	require.ErrorAs(t, err, &qe)
+	assert.Equal(t, 1, qe.Requested)
+}
@@ -0,0 +1,125 @@
+// Package service provides a blocklist of destination domains that may not be
+// shortened, such as known phishing or spam domains.
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// BlockedDomainError is returned when a URL points to a blocked domain.
+type BlockedDomainError struct {
+	URL    string // URL that was rejected
+	Domain string // Blocklist entry the URL matched
+}
+
+// Error implements the error interface.
+func (e *BlockedDomainError) Error() string {
+	return fmt.Sprintf("domain %s is blocked", e.Domain)
+}
+
+// Blocklist is a set of blocked domains; an entry blocks the domain itself and
+// all its subdomains. It can be replaced while URLs are checked against it,
+// for example on a configuration reload.
+type Blocklist struct {
+	domains atomic.Pointer[map[string]struct{}]
+}
+
+// NewBlocklist returns a Blocklist of the given domains.
+func NewBlocklist(domains []string) *Blocklist {
+	b := &Blocklist{}
+	b.Set(domains)
+	return b
+}
+
+// Set replaces the blocked domains.
+func (b *Blocklist) Set(domains []string) {
+	set := make(map[string]struct{}, len(domains))
+	for _, d := range domains {
+		if d = normalizeDomain(d); d != "" {
+			set[d] = struct{}{}
+		}
+	}
+	b.domains.Store(&set)
+}
+
+// Match returns the blocklist entry matching host, if any.
+func (b *Blocklist) Match(host string) (string, bool) {
+	if b == nil {
+		return "", false
+	}
+	domains := b.domains.Load()
+	if domains == nil || len(*domains) == 0 {
+		return "", false
+	}
+
+	// Try the host, then every parent domain
+	for d := normalizeDomain(host); d != ""; {
+		if _, ok := (*domains)[d]; ok {
+			return d, true
+		}
+		i := strings.IndexByte(d, '.')
+		if i < 0 {
+			break
+		}
+		d = d[i+1:]
+	}
+	return "", false
+}
+
+// LoadBlocklistFile reads blocked domains from a file with one domain per
+// line. Empty lines and lines starting with # are skipped.
+func LoadBlocklistFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var domains []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	return domains, scanner.Err()
+}
+
+// normalizeDomain lower-cases d and strips a trailing dot.
+func normalizeDomain(d string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d)), ".")
+}
+
+// urlHost returns the host name of the URL, without the port.
+func urlHost(long string) string {
+	u, err := url.Parse(strings.TrimSpace(long))
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// SetBlocklist makes the service reject URLs pointing to domains of b; nil
+// disables the check. The blocklist may be updated while the service runs.
+func (s *URLService) SetBlocklist(b *Blocklist) {
+	s.blocklist = b
+}
+
+// checkBlocklist returns a BlockedDomainError for the first URL pointing to a
+// blocked domain.
+func (s *URLService) checkBlocklist(ctx context.Context, longs ...string) error {
+	for _, long := range longs {
+		if domain, ok := s.blocklist.Match(urlHost(long)); ok {
+			return &BlockedDomainError{URL: long, Domain: domain}
+		}
+	}
+	return nil
+}
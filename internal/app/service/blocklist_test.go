@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestBlocklist_Match(t *testing.T) {
+	b := NewBlocklist([]string{"Evil.example.", "phish.test", ""})
+
+	tests := []struct {
+		host  string
+		match string
+		ok    bool
+	}{
+		{host: "evil.example", match: "evil.example", ok: true},
+		{host: "WWW.evil.example", match: "evil.example", ok: true},
+		{host: "a.b.phish.test", match: "phish.test", ok: true},
+		{host: "notevil.example", ok: false},
+		{host: "example", ok: false},
+		{host: "", ok: false},
+	}
+	for _, tt := range tests {
+		match, ok := b.Match(tt.host)
+		assert.Equal(t, tt.ok, ok, tt.host)
+		assert.Equal(t, tt.match, match, tt.host)
+	}
+
+	b.Set([]string{"other.test"})
+	_, ok := b.Match("evil.example")
+	assert.False(t, ok)
+
+	var none *Blocklist
+	_, ok = none.Match("evil.example")
+	assert.False(t, ok)
+}
+
+func TestLoadBlocklistFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# phishing\nevil.example\n\n  phish.test  \n"), 0600))
+
+	domains, err := LoadBlocklistFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"evil.example", "phish.test"}, domains)
+
+	_, err = LoadBlocklistFile(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
+
+func TestURLService_Blocklist(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+
+	service, _ := NewURL(context.Background(), mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+	service.SetBlocklist(NewBlocklist([]string{"evil.example"}))
+
+	_, err := service.CreateURLRecord(context.Background(), "https://login.evil.example:8443/path", "user-id")
+	var be *BlockedDomainError
+	require.True(t, errors.As(err, &be))
+	assert.Equal(t, "evil.example", be.Domain)
+
+	// Only the blocked URL of a batch is rejected
+	batch, err := service.CreateURLRecords(context.Background(), []models.BatchRequest{
+		{CorrelationID: "a", OriginalURL: "https://good.example"},
+		{CorrelationID: "b", OriginalURL: "https://evil.example"},
+	}, "user-id")
+	require.NoError(t, err)
+	assert.Equal(t, models.BatchStatusCreated, (*batch)[0].Status)
+	assert.Equal(t, models.BatchStatusInvalid, (*batch)[1].Status)
+	count, err := mockStorage.CountByUserID(context.Background(), "user-id")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// With strict batches it is rejected as a whole
+	service.SetStrictBatches(true)
+	_, err = service.CreateURLRecords(context.Background(), []models.BatchRequest{
+		{CorrelationID: "c", OriginalURL: "https://fine.example"},
+		{CorrelationID: "d", OriginalURL: "https://evil.example"},
+	}, "user-id")
+	require.True(t, errors.As(err, &be))
+	count, err = mockStorage.CountByUserID(context.Background(), "user-id")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	_, err = service.CreateURLRecord(context.Background(), "https://fine.example", "user-id")
+	assert.NoError(t, err)
+}
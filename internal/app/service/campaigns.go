@@ -0,0 +1,164 @@
+// Package service provides campaigns: named groups of short URLs of a user,
+// whose clicks are reported together.
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+)
+
+// maxCampaignNameLen is the length of the longest campaign name, in characters.
+const maxCampaignNameLen = 64
+
+var (
+	// ErrInvalidCampaignName is returned for campaign names that are empty, too
+	// long or contain control characters.
+	ErrInvalidCampaignName = errors.New("campaign names must have 1 to 64 characters and no control characters")
+
+	// ErrUnknownCampaign is returned when a URL is moved to a campaign that
+	// does not exist or belongs to another user.
+	ErrUnknownCampaign = errors.New("unknown campaign")
+)
+
+// SetCampaignStorage replaces the store of campaigns, which is in memory by
+// default. It must be called before the service starts handling requests.
+func (s *URLService) SetCampaignStorage(c CampaignStorage) {
+	s.campaigns = c
+}
+
+// CreateCampaign creates a campaign of the user with the given name, trimmed.
+// It returns ErrInvalidCampaignName if the name is not allowed and
+// storage.ErrConflict if the user already has a campaign with that name.
+func (s *URLService) CreateCampaign(ctx context.Context, userID string, name string) (*storage.Campaign, error) {
+	ctx, span := tracing.Start(ctx, "URLService.CreateCampaign", tracing.KindInternal)
+	defer span.End()
+
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxCampaignNameLen || strings.ContainsFunc(name, isControl) {
+		return nil, ErrInvalidCampaignName
+	}
+
+	c := storage.Campaign{ID: uuid.New().String(), UserID: userID, Name: name, CreatedAt: time.Now().UTC()}
+	if err := s.campaigns.CreateCampaign(ctx, c); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	audit.AddTargets(ctx, c.ID)
+	return &c, nil
+}
+
+// GetCampaigns returns the campaigns of the user, oldest first.
+func (s *URLService) GetCampaigns(ctx context.Context, userID string) ([]storage.Campaign, error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetCampaigns", tracing.KindInternal)
+	defer span.End()
+
+	campaigns, err := s.campaigns.FindCampaignsByUserID(ctx, userID)
+	span.RecordError(err)
+	return campaigns, err
+}
+
+// findCampaign returns the campaign with the given ID if it belongs to the
+// user, or storage.ErrNotFound.
+func (s *URLService) findCampaign(ctx context.Context, userID string, id string) (*storage.Campaign, error) {
+	c, err := s.campaigns.FindCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Campaigns of other users are not disclosed
+	if c.UserID != userID {
+		return nil, storage.ErrNotFound
+	}
+	return c, nil
+}
+
+// SetURLCampaign moves the short URL of the user to their campaign with the
+// given ID, out of the campaign it was in; an empty ID removes it from its
+// campaign. It returns ErrUnknownCampaign if the user has no such campaign,
+// storage.ErrNotFound if the user does not own the short URL and
+// storage.ErrDeleted if it was deleted.
+func (s *URLService) SetURLCampaign(ctx context.Context, short string, userID string, campaignID string) error {
+	ctx, span := tracing.Start(ctx, "URLService.SetURLCampaign", tracing.KindInternal)
+	defer span.End()
+	short = s.resolver.Normalize(short)
+
+	if campaignID != "" {
+		_, err := s.findCampaign(ctx, userID, campaignID)
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrUnknownCampaign
+		}
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	err := s.repository.SetCampaign(ctx, short, userID, campaignID)
+	span.RecordError(err)
+	if err == nil {
+		audit.AddTargets(ctx, short)
+	}
+	return err
+}
+
+// CampaignStats returns the number of short URLs in the campaign of the user
+// and their clicks in the last window, in total and per short URL, most
+// clicked first. The clicks buffered by this instance are flushed first. It
+// returns storage.ErrNotFound if the user has no such campaign.
+func (s *URLService) CampaignStats(ctx context.Context, userID string, campaignID string, window time.Duration) (*models.CampaignStats, error) {
+	ctx, span := tracing.Start(ctx, "URLService.CampaignStats", tracing.KindInternal)
+	defer span.End()
+
+	c, err := s.findCampaign(ctx, userID, campaignID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	records, err := s.repository.FindByUserID(ctx, userID, storage.ListOptions{Campaign: c.ID})
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	stats := &models.CampaignStats{ID: c.ID, Name: c.Name, Top: []models.TopURL{}}
+	if records == nil || len(*records) == 0 {
+		return stats, nil
+	}
+
+	if err := s.clickFlusher.Flush(ctx); err != nil {
+		// The totals are only missing the latest clicks
+		logger.FromContext(ctx, s.logger).Warn("cannot flush clicks", zap.Error(err))
+	}
+
+	originals := make(map[string]string, len(*records))
+	shorts := make([]string, 0, len(*records))
+	for _, r := range *records {
+		originals[r.Short] = r.Original
+		shorts = append(shorts, r.Short)
+	}
+	stats.URLs = len(shorts)
+
+	counts, err := s.clicks.ClicksOf(ctx, shorts, time.Now().Add(-window))
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	tenant := s.Tenant(ctx)
+	for _, count := range counts {
+		stats.Clicks += count.Clicks
+		stats.Top = append(stats.Top, models.TopURL{ShortURL: tenant.ShortURL(count.Short), OriginalURL: originals[count.Short], Clicks: count.Clicks})
+	}
+	return stats, nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLService_CreateCampaign(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	c, err := service.CreateCampaign(ctx, "owner", "  Spring sale ")
+	require.NoError(t, err)
+	assert.Equal(t, "Spring sale", c.Name)
+	assert.NotEmpty(t, c.ID)
+
+	_, err = service.CreateCampaign(ctx, "owner", "Spring sale")
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	_, err = service.CreateCampaign(ctx, "other", "Spring sale")
+	assert.NoError(t, err)
+
+	for _, name := range []string{" ", strings.Repeat("x", maxCampaignNameLen+1), "tab\tname"} {
+		_, err = service.CreateCampaign(ctx, "owner", name)
+		assert.ErrorIs(t, err, ErrInvalidCampaignName, name)
+	}
+
+	campaigns, err := service.GetCampaigns(ctx, "owner")
+	require.NoError(t, err)
+	assert.Equal(t, []storage.Campaign{*c}, campaigns)
+}
+
+func TestURLService_CampaignStats(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	c, err := service.CreateCampaign(ctx, "owner", "Launch")
+	require.NoError(t, err)
+	foreign, err := service.CreateCampaign(ctx, "other", "Launch")
+	require.NoError(t, err)
+
+	a, err := service.CreateURLRecord(ctx, "https://example.com/a", "owner")
+	require.NoError(t, err)
+	b, err := service.CreateURLRecord(ctx, "https://example.com/b", "owner")
+	require.NoError(t, err)
+	outside, err := service.CreateURLRecord(ctx, "https://example.com/c", "owner")
+	require.NoError(t, err)
+
+	require.NoError(t, service.SetURLCampaign(ctx, a.Short, "owner", c.ID))
+	require.NoError(t, service.SetURLCampaign(ctx, b.Short, "owner", c.ID))
+	assert.ErrorIs(t, service.SetURLCampaign(ctx, outside.Short, "owner", foreign.ID), ErrUnknownCampaign)
+	assert.ErrorIs(t, service.SetURLCampaign(ctx, outside.Short, "owner", "missing"), ErrUnknownCampaign)
+	assert.ErrorIs(t, service.SetURLCampaign(ctx, outside.Short, "other", foreign.ID), storage.ErrNotFound)
+
+	// Empty campaigns have no clicks
+	empty, err := service.CreateCampaign(ctx, "owner", "Empty")
+	require.NoError(t, err)
+	stats, err := service.CampaignStats(ctx, "owner", empty.ID, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, &models.CampaignStats{ID: empty.ID, Name: "Empty", Top: []models.TopURL{}}, stats)
+
+	// Redirects of the URLs of the campaign add up, the others are left out
+	for _, short := range []string{b.Short, b.Short, a.Short, outside.Short} {
+		redirect(t, ctx, service, short)
+	}
+
+	stats, err = service.CampaignStats(ctx, "owner", c.ID, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, &models.CampaignStats{
+		ID:     c.ID,
+		Name:   "Launch",
+		URLs:   2,
+		Clicks: 3,
+		Top: []models.TopURL{
+			{ShortURL: "http://baseurl/" + b.Short, OriginalURL: "https://example.com/b", Clicks: 2},
+			{ShortURL: "http://baseurl/" + a.Short, OriginalURL: "https://example.com/a", Clicks: 1},
+		},
+	}, stats)
+
+	// The campaign is listed with its URLs, and leaving it removes it from the stats
+	urls, err := service.GetURLByUserID(ctx, "owner", storage.ListOptions{Campaign: c.ID})
+	require.NoError(t, err)
+	require.Len(t, *urls, 2)
+	assert.Equal(t, c.ID, (*urls)[0].CampaignID)
+
+	require.NoError(t, service.SetURLCampaign(ctx, a.Short, "owner", ""))
+	stats, err = service.CampaignStats(ctx, "owner", c.ID, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.URLs)
+	assert.Equal(t, 2, stats.Clicks)
+
+	// Campaigns of other users are not found
+	_, err = service.CampaignStats(ctx, "owner", foreign.ID, time.Hour)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
@@ -0,0 +1,107 @@
+// Package service provides real-time click streams: the owner of a short URL
+// can subscribe to its clicks and gets every redirect pushed as it happens.
+// Streams are fed by the redirects served by this instance.
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+)
+
+const (
+	// clickStreamBuffer is the number of clicks buffered for a stream; clicks
+	// are dropped for streams that fall further behind, rather than holding
+	// up redirects.
+	clickStreamBuffer = 64
+	// maxClickStreamsPerURL bounds the number of streams of one short URL.
+	maxClickStreamsPerURL = 16
+)
+
+// ErrTooManyStreams is returned by StreamClicks when the short URL already has
+// the maximum number of click streams.
+var ErrTooManyStreams = errors.New("too many click streams for the short URL")
+
+// clickSubscriber is a stream of the clicks of a short URL.
+type clickSubscriber struct {
+	shortURL string // Full short URL sent in the events
+	events   chan models.ClickEvent
+}
+
+// clickHub delivers the clicks of short URLs to their streams. Its zero value
+// is ready to use, and it is safe for concurrent use.
+type clickHub struct {
+	mu   sync.RWMutex
+	subs map[string]map[*clickSubscriber]struct{} // Streams by short URL identifier
+}
+
+// subscribe returns a stream of the clicks of short, whose events carry
+// shortURL, and the function ending it.
+func (h *clickHub) subscribe(short, shortURL string) (<-chan models.ClickEvent, func(), error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.subs[short]) >= maxClickStreamsPerURL {
+		return nil, nil, ErrTooManyStreams
+	}
+	if h.subs == nil {
+		h.subs = make(map[string]map[*clickSubscriber]struct{})
+	}
+	if h.subs[short] == nil {
+		h.subs[short] = make(map[*clickSubscriber]struct{})
+	}
+	sub := &clickSubscriber{shortURL: shortURL, events: make(chan models.ClickEvent, clickStreamBuffer)}
+	h.subs[short][sub] = struct{}{}
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			delete(h.subs[short], sub)
+			if len(h.subs[short]) == 0 {
+				delete(h.subs, short)
+			}
+		})
+	}
+	return sub.events, stop, nil
+}
+
+// publish sends a click of short at the given time to its streams, skipping
+// the streams whose buffer is full.
+func (h *clickHub) publish(short string, at time.Time) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subs[short] {
+		select {
+		case sub.events <- models.ClickEvent{ShortURL: sub.shortURL, Time: at.UTC()}:
+		default:
+		}
+	}
+}
+
+// StreamClicks subscribes the user to the clicks of the short URL, which are
+// sent on the returned channel until the returned function is called. It
+// returns storage.ErrNotFound if the user does not own the short URL, and
+// ErrTooManyStreams if it already has the maximum number of streams.
+func (s *URLService) StreamClicks(ctx context.Context, short string, userID string) (<-chan models.ClickEvent, func(), error) {
+	ctx, span := tracing.Start(ctx, "URLService.StreamClicks", tracing.KindInternal)
+	defer span.End()
+
+	r, err := s.repository.FindByShort(ctx, s.resolver.Normalize(short))
+	if err != nil {
+		span.RecordError(err)
+		return nil, nil, err
+	}
+	if r == nil || userID == "" || r.UserID != userID {
+		return nil, nil, storage.ErrNotFound
+	}
+	return s.clickStreams.subscribe(r.Short, s.Tenant(ctx).ShortURL(r.Short))
+}
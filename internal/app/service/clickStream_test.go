@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLService_StreamClicks(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	resolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, resolver, zap.NewNop(), "http://baseurl")
+
+	created, err := service.CreateURLRecord(ctx, "https://example.com", "user-1")
+	require.NoError(t, err)
+
+	// Only the owner may stream the clicks
+	_, _, err = service.StreamClicks(ctx, created.Short, "user-2")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, _, err = service.StreamClicks(ctx, "missing", "user-1")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	events, stop, err := service.StreamClicks(ctx, created.Short, "user-1")
+	require.NoError(t, err)
+
+	redirect(t, ctx, service, created.Short)
+	select {
+	case ev := <-events:
+		assert.Equal(t, "http://baseurl/"+created.Short, ev.ShortURL)
+		assert.WithinDuration(t, time.Now(), ev.Time, time.Minute)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no click event")
+	}
+
+	// No events are sent once the stream is stopped
+	stop()
+	stop()
+	redirect(t, ctx, service, created.Short)
+	assert.Empty(t, events)
+}
+
+func TestClickHub_Limits(t *testing.T) {
+	var h clickHub
+	stops := make([]func(), 0, maxClickStreamsPerURL)
+	for i := 0; i < maxClickStreamsPerURL; i++ {
+		_, stop, err := h.subscribe("abc", "http://baseurl/abc")
+		require.NoError(t, err)
+		stops = append(stops, stop)
+	}
+	_, _, err := h.subscribe("abc", "http://baseurl/abc")
+	assert.ErrorIs(t, err, ErrTooManyStreams)
+
+	// Streams of other short URLs are not limited by it
+	_, _, err = h.subscribe("def", "http://baseurl/def")
+	require.NoError(t, err)
+
+	stops[0]()
+	events, _, err := h.subscribe("abc", "http://baseurl/abc")
+	require.NoError(t, err)
+
+	// Clicks of streams that fall behind are dropped rather than blocking
+	for i := 0; i < clickStreamBuffer+10; i++ {
+		h.publish("abc", time.Now())
+	}
+	assert.Len(t, events, clickStreamBuffer)
+}
@@ -0,0 +1,113 @@
+// Package service provides click analytics: clicks of short URLs are counted
+// on redirects, so the most clicked short URLs of a time window can be listed.
+// Clicks are buffered in memory and written to the click store in batches.
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+)
+
+// SetClickStorage replaces the store clicks are counted in, which is in memory
+// by default. It must be called before the service starts handling requests.
+func (s *URLService) SetClickStorage(c ClickStorage) {
+	s.clicks = c
+}
+
+// SetClickFlushInterval sets how often the clicks buffered by the service are
+// written to the click store; non-positive values fall back to the default.
+func (s *URLService) SetClickFlushInterval(d time.Duration) {
+	s.clickFlusher.SetInterval(d)
+}
+
+// RecordRedirect counts a click of the short URL of r and publishes a click
+// event. The redirect handler calls it once it actually redirects, so lookups
+// ending in a password prompt or any other refusal are not counted.
+func (s *URLService) RecordRedirect(ctx context.Context, r *storage.URLRecord) {
+	s.recordClick(ctx, r.Short)
+	s.publish(ctx, EventClicked, *r)
+}
+
+// recordClick counts a click of the short URL. Like events, clicks are best
+// effort: they are buffered and flushed in the background, so the redirect
+// never waits for the click store, and flush failures are only logged.
+func (s *URLService) recordClick(ctx context.Context, short string) {
+	now := time.Now()
+	s.clickStreams.publish(short, now)
+	s.redirects.add(now, 1)
+	s.clickFlusher.Add(short, now)
+}
+
+// TopURLs returns up to limit short URLs with the most clicks in the last
+// window, most clicked first. The clicks buffered by this instance are flushed
+// first. Short URLs that no longer exist are skipped.
+func (s *URLService) TopURLs(ctx context.Context, window time.Duration, limit int) ([]models.TopURL, error) {
+	ctx, span := tracing.Start(ctx, "URLService.TopURLs", tracing.KindInternal)
+	defer span.End()
+
+	if err := s.clickFlusher.Flush(ctx); err != nil {
+		// The totals are only missing the latest clicks
+		logger.FromContext(ctx, s.logger).Warn("cannot flush clicks", zap.Error(err))
+	}
+
+	counts, err := s.clicks.TopClicked(ctx, time.Now().Add(-window), limit)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	tenant := s.Tenant(ctx)
+	res := make([]models.TopURL, 0, len(counts))
+	for _, c := range counts {
+		r, err := s.repository.FindByShort(ctx, c.Short)
+		if err != nil || r == nil {
+			continue
+		}
+		res = append(res, models.TopURL{ShortURL: tenant.ShortURL(r.Short), OriginalURL: r.Original, Clicks: c.Clicks})
+	}
+	return res, nil
+}
+
+// GetURLStats returns the clicks of the short URL in the last window as seen
+// by the user. The clicks buffered by this instance are flushed first. Like
+// GetURLMetadata, it returns storage.ErrNotFound if there is no such short
+// URL, or if it is private or not active yet and the user does not own it.
+func (s *URLService) GetURLStats(ctx context.Context, short string, userID string, window time.Duration) (*models.URLStats, error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetURLStats", tracing.KindInternal)
+	defer span.End()
+
+	r, err := s.repository.FindByShort(ctx, s.resolver.Normalize(short))
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	now := time.Now()
+	if (userID == "" || r.UserID != userID) && (r.Private || r.NotYetActive(now)) {
+		return nil, storage.ErrNotFound
+	}
+
+	if err := s.clickFlusher.Flush(ctx); err != nil {
+		// The totals are only missing the latest clicks
+		logger.FromContext(ctx, s.logger).Warn("cannot flush clicks", zap.Error(err))
+	}
+
+	since := now.Add(-window)
+	counts, err := s.clicks.ClicksOf(ctx, []string{r.Short}, since)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	stats := &models.URLStats{ShortURL: s.Tenant(ctx).ShortURL(r.Short), Since: since.UTC()}
+	for _, c := range counts {
+		stats.Clicks += c.Clicks
+	}
+	return stats, nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLService_TopURLs(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+	clicks := storage.NewMemoryClickStorage()
+	service.SetClickStorage(clicks)
+
+	a, err := service.CreateURLRecord(ctx, "https://example.com/a", "owner")
+	require.NoError(t, err)
+	b, err := service.CreateURLRecord(ctx, "https://example.com/b", "owner")
+	require.NoError(t, err)
+
+	// Redirects count clicks
+	for i := 0; i < 2; i++ {
+		redirect(t, ctx, service, b.Short)
+	}
+	redirect(t, ctx, service, a.Short)
+
+	// Clicks of short URLs that no longer exist are skipped
+	require.NoError(t, clicks.RecordClick(ctx, "gone", time.Now()))
+	require.NoError(t, clicks.RecordClick(ctx, "gone", time.Now()))
+	require.NoError(t, clicks.RecordClick(ctx, "gone", time.Now()))
+
+	top, err := service.TopURLs(ctx, time.Hour, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []models.TopURL{
+		{ShortURL: "http://baseurl/" + b.Short, OriginalURL: "https://example.com/b", Clicks: 2},
+		{ShortURL: "http://baseurl/" + a.Short, OriginalURL: "https://example.com/a", Clicks: 1},
+	}, top)
+}
+
+func TestURLService_GetURLStats(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+	clicks := storage.NewMemoryClickStorage()
+	service.SetClickStorage(clicks)
+
+	r, err := service.CreateURLRecord(ctx, "https://example.com/a", "owner")
+	require.NoError(t, err)
+	require.NoError(t, clicks.RecordClick(ctx, r.Short, time.Now().Add(-3*time.Hour)))
+
+	// Buffered clicks are counted too
+	redirect(t, ctx, service, r.Short)
+
+	stats, err := service.GetURLStats(ctx, r.Short, "", 24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "http://baseurl/"+r.Short, stats.ShortURL)
+	assert.Equal(t, 2, stats.Clicks)
+	stats, err = service.GetURLStats(ctx, r.Short, "other", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Clicks)
+
+	// The stats of private short URLs are shown to their owner only
+	require.NoError(t, service.SetURLVisibility(ctx, r.Short, "owner", models.VisibilityPrivate))
+	_, err = service.GetURLStats(ctx, r.Short, "other", time.Hour)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	stats, err = service.GetURLStats(ctx, r.Short, "owner", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Clicks)
+
+	_, err = service.GetURLStats(ctx, "missing", "owner", time.Hour)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+// redirect resolves the short URL and records the redirect, as the redirect
+// handler does once it lets the request through.
+func redirect(t *testing.T, ctx context.Context, s *URLService, short string) {
+	t.Helper()
+	r, err := s.GetURLByShort(ctx, short)
+	require.NoError(t, err)
+	s.RecordRedirect(ctx, r)
+}
@@ -0,0 +1,95 @@
+// Package service provides titles and descriptions of short URLs, which make
+// long listings of URLs easier to browse. URLs created without a title get
+// the title of their page, fetched in the background, if a title fetcher is
+// set.
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+// titleFetcherJobName is the name the title fetcher is registered under.
+const titleFetcherJobName = "title_fetcher"
+
+const (
+	// maxTitleLen is the length of the longest title, in characters.
+	maxTitleLen = 200
+	// maxDescriptionLen is the length of the longest description, in characters.
+	maxDescriptionLen = 1000
+)
+
+// ErrInvalidDetails is returned when the title or description of a short URL
+// is too long.
+var ErrInvalidDetails = errors.New("title must be at most 200 characters and description at most 1000")
+
+// normalizeDetails returns the title and description trimmed, or
+// ErrInvalidDetails if one of them is too long.
+func normalizeDetails(title, description string) (string, string, error) {
+	title, description = strings.TrimSpace(title), strings.TrimSpace(description)
+	if utf8.RuneCountInString(title) > maxTitleLen || utf8.RuneCountInString(description) > maxDescriptionLen {
+		return "", "", ErrInvalidDetails
+	}
+	return title, description, nil
+}
+
+// SetTitleFetcher registers f with the background jobs and makes the service
+// queue every URL created without a title to it, so that it gets the title of
+// its page. It must be called before the service starts handling requests.
+func (s *URLService) SetTitleFetcher(f *worker.TitleFetcher) error {
+	if err := s.jobs.Register(titleFetcherJobName, f); err != nil {
+		return err
+	}
+	s.titles = f
+	return nil
+}
+
+// fetchTitles queues the created records without a title to the title
+// fetcher. Fetching is best effort: records that cannot be queued are logged
+// and stay without a title.
+func (s *URLService) fetchTitles(ctx context.Context, records ...storage.URLRecord) {
+	if s.titles == nil {
+		return
+	}
+	for _, r := range records {
+		if r.Title != "" {
+			continue
+		}
+		if err := s.titles.Submit(r); err != nil {
+			logger.FromContext(ctx, s.logger).Warn("cannot queue URL for title fetch",
+				zap.Error(err), zap.String("short_url", r.Short))
+		}
+	}
+}
+
+// SetURLDetails sets the title and description of the short URL of the user;
+// empty strings remove them. A removed title is not fetched again. It returns
+// ErrInvalidDetails if they are too long, storage.ErrNotFound if the user
+// does not own the short URL and storage.ErrDeleted if it was deleted.
+func (s *URLService) SetURLDetails(ctx context.Context, short string, userID string, title string, description string) error {
+	ctx, span := tracing.Start(ctx, "URLService.SetURLDetails", tracing.KindInternal)
+	defer span.End()
+	short = s.resolver.Normalize(short)
+
+	title, description, err := normalizeDetails(title, description)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.SetDetails(ctx, short, userID, title, description)
+	span.RecordError(err)
+	if err == nil {
+		audit.AddTargets(ctx, short)
+	}
+	return err
+}
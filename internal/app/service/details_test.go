@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+// pageTitles is a worker.TitleSource over a fixed map of URLs to titles.
+type pageTitles map[string]string
+
+func (p pageTitles) Title(_ context.Context, url string) (string, error) {
+	return p[url], nil
+}
+
+func TestURLService_SetURLDetails(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	created, err := service.CreateURLRecordWithOptions(ctx, "https://example.com", "owner", CreateOptions{Title: " Example ", Description: "Landing page"})
+	require.NoError(t, err)
+	_, err = service.CreateURLRecordWithOptions(ctx, "https://example.org", "owner", CreateOptions{Title: strings.Repeat("x", maxTitleLen+1)})
+	assert.ErrorIs(t, err, ErrInvalidDetails)
+
+	urls, err := service.GetURLByUserID(ctx, "owner", storage.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, *urls, 1)
+	assert.Equal(t, "Example", (*urls)[0].Title)
+	assert.Equal(t, "Landing page", (*urls)[0].Description)
+
+	require.NoError(t, service.SetURLDetails(ctx, created.Short, "owner", "Renamed", ""))
+	m, err := service.GetURLMetadata(ctx, created.Short, "other")
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", m.Title)
+	assert.Empty(t, m.Description)
+
+	// Other users cannot change them, and they are hidden with the URL of protected links
+	assert.ErrorIs(t, service.SetURLDetails(ctx, created.Short, "other", "", ""), storage.ErrNotFound)
+	assert.ErrorIs(t, service.SetURLDetails(ctx, created.Short, "owner", "", strings.Repeat("x", maxDescriptionLen+1)), ErrInvalidDetails)
+	require.NoError(t, service.SetURLPassword(ctx, created.Short, "owner", "s3cret"))
+	m, err = service.GetURLMetadata(ctx, created.Short, "other")
+	require.NoError(t, err)
+	assert.Empty(t, m.Title)
+}
+
+func TestURLService_TitleFetcher(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+
+	service, shutdown := NewURL(ctx, mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+	titles := pageTitles{"https://a.example": "Page A", "https://b.example": "Page B", "https://c.example": "Page C"}
+	fetcher := worker.NewTitleFetcher(zap.NewNop(), titles, mockStorage, worker.TitleOptions{})
+	require.NoError(t, service.SetTitleFetcher(fetcher))
+
+	untitled, err := service.CreateURLRecord(ctx, "https://a.example", "user-id")
+	require.NoError(t, err)
+	titled, err := service.CreateURLRecordWithOptions(ctx, "https://b.example", "user-id", CreateOptions{Title: "Mine"})
+	require.NoError(t, err)
+	_, err = service.CreateURLRecords(ctx, []models.BatchRequest{
+		{CorrelationID: "c", OriginalURL: "https://c.example"},
+	}, "user-id")
+	require.NoError(t, err)
+
+	// Only the records without a title are fetched
+	require.Eventually(t, func() bool { return fetcher.Metrics().Runs == 2 }, time.Second, time.Millisecond)
+	shutdown()
+
+	r, err := service.GetURLByShort(ctx, untitled.Short)
+	require.NoError(t, err)
+	assert.Equal(t, "Page A", r.Title)
+	r, err = service.GetURLByShort(ctx, titled.Short)
+	require.NoError(t, err)
+	assert.Equal(t, "Mine", r.Title)
+	r, err = service.GetURLByShort(ctx, mockResolver.LongToShort("https://c.example"))
+	require.NoError(t, err)
+	assert.Equal(t, "Page C", r.Title)
+}
@@ -0,0 +1,68 @@
+// Package service provides publishing of URL lifecycle events to a message
+// broker, so downstream analytics systems can consume the stream.
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// Event types, used as the last element of the subject.
+const (
+	EventCreated = "created"
+	EventDeleted = "deleted"
+	EventClicked = "clicked"
+)
+
+// eventPublishTimeout bounds publishing, so a slow broker does not hold up requests.
+const eventPublishTimeout = time.Second
+
+// SetEventPublisher makes the service publish creation, deletion and click
+// events to p, on subjects of the form "<prefix>.<type>". A nil publisher
+// disables events. It must be called before the service starts handling requests.
+func (s *URLService) SetEventPublisher(p EventPublisher, prefix string) {
+	s.events = p
+	s.eventPrefix = prefix
+}
+
+// publish sends an event about the record. Events are best effort: failures are
+// logged and never fail the operation that caused them.
+func (s *URLService) publish(ctx context.Context, eventType string, r storage.URLRecord) {
+	if s.events == nil {
+		return
+	}
+
+	payload, err := json.Marshal(models.URLEvent{
+		Type:        eventType,
+		ShortURL:    r.Short,
+		OriginalURL: r.Original,
+		UserID:      r.UserID,
+		Time:        time.Now().UTC(),
+	})
+	if err != nil {
+		return
+	}
+
+	subject := eventType
+	if s.eventPrefix != "" {
+		subject = s.eventPrefix + "." + eventType
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), eventPublishTimeout)
+	defer cancel()
+
+	if err := s.events.Publish(ctx, subject, payload); err != nil {
+		logger.FromContext(ctx, s.logger).Warn("cannot publish event",
+			zap.Error(err),
+			zap.String("subject", subject),
+			zap.String("short_url", r.Short),
+		)
+	}
+}
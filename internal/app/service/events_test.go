@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+type recordingPublisher struct {
+	mu       sync.Mutex
+	subjects []string
+	events   []models.URLEvent
+	err      error
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, subject string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var e models.URLEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return err
+	}
+	p.subjects = append(p.subjects, subject)
+	p.events = append(p.events, e)
+	return p.err
+}
+
+func TestURLService_Events(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+
+	service, _ := NewURL(context.Background(), mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+	publisher := &recordingPublisher{}
+	service.SetEventPublisher(publisher, "shortener.urls")
+
+	r, err := service.CreateURLRecord(context.Background(), "http://1.example.com", "user-id")
+	require.NoError(t, err)
+
+	_, err = service.CreateURLRecords(context.Background(), []models.BatchRequest{
+		{CorrelationID: "a", OriginalURL: "http://2.example.com"},
+	}, "user-id")
+	require.NoError(t, err)
+
+	redirect(t, context.Background(), service, r.Short)
+
+	service.DeleteURLRecords(context.Background(), []storage.URLRecord{{Short: r.Short, UserID: "user-id"}})
+
+	assert.Equal(t, []string{
+		"shortener.urls.created",
+		"shortener.urls.created",
+		"shortener.urls.clicked",
+		"shortener.urls.deleted",
+	}, publisher.subjects)
+
+	clicked := publisher.events[2]
+	assert.Equal(t, EventClicked, clicked.Type)
+	assert.Equal(t, r.Short, clicked.ShortURL)
+	assert.Equal(t, "http://1.example.com", clicked.OriginalURL)
+	assert.False(t, clicked.Time.IsZero())
+	assert.Equal(t, "http://2.example.com", publisher.events[1].OriginalURL)
+	assert.Equal(t, "user-id", publisher.events[3].UserID)
+}
+
+func TestURLService_EventsAreBestEffort(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+
+	service, _ := NewURL(context.Background(), mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+	service.SetEventPublisher(&recordingPublisher{err: errors.New("broker down")}, "")
+
+	// Publishing failures do not fail the operation
+	_, err := service.CreateURLRecord(context.Background(), "http://1.example.com", "user-id")
+	require.NoError(t, err)
+}
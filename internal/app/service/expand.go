@@ -0,0 +1,62 @@
+// Package service provides batch expansion of short URLs, which resolves many
+// short URLs to their original URLs at once without following them.
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+)
+
+// Reasons short URLs of a batch could not be expanded, matching the messages of
+// the pages shown instead of a redirect.
+const (
+	ExpandNotFound         = "URL not found"
+	ExpandNotYetActive     = "URL is not active yet"
+	ExpandGone             = "URL is gone"
+	ExpandFlagged          = "URL was flagged as a threat"
+	ExpandPasswordRequired = "password required"
+)
+
+// ExpandURLs resolves the short URLs to their original URLs, in the order of
+// shorts. Short URLs that would not redirect get the reason instead of the
+// original URL; the original URLs of password protected URLs are not revealed.
+// Unlike GetURLByShort, no click events are published.
+func (s *URLService) ExpandURLs(ctx context.Context, shorts []string) ([]models.ExpandBatchResponse, error) {
+	ctx, span := tracing.Start(ctx, "URLService.ExpandURLs", tracing.KindInternal)
+	defer span.End()
+
+	now := time.Now()
+	result := make([]models.ExpandBatchResponse, 0, len(shorts))
+	for _, short := range shorts {
+		e := models.ExpandBatchResponse{ShortURL: short}
+
+		r, err := s.repository.FindByShort(ctx, s.resolver.Normalize(short))
+		switch {
+		case ctx.Err() != nil:
+			span.RecordError(ctx.Err())
+			return nil, ctx.Err()
+		case errors.Is(err, storage.ErrNotFound):
+			e.Error = ExpandNotFound
+		case err != nil:
+			span.RecordError(err)
+			return nil, err
+		case r.NotYetActive(now):
+			e.Error = ExpandNotYetActive
+		case r.Flagged != "":
+			e.Error = ExpandFlagged
+		case r.IsDeleted || r.Expired(now) || r.NoLongerActive(now):
+			e.Error = ExpandGone
+		case r.PasswordHash != "":
+			e.Error = ExpandPasswordRequired
+		default:
+			e.OriginalURL = r.Original
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
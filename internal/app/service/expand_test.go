@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLService_ExpandURLs(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	active, err := service.CreateURLRecord(ctx, "https://example.com", "owner")
+	require.NoError(t, err)
+	protected, err := service.CreateURLRecord(ctx, "https://example.com/secret", "owner")
+	require.NoError(t, err)
+	require.NoError(t, service.SetURLPassword(ctx, protected.Short, "owner", "s3cret"))
+	flagged, err := service.CreateURLRecord(ctx, "https://malware.example.com", "owner")
+	require.NoError(t, err)
+	require.NoError(t, mockStorage.FlagURL(ctx, flagged.Short, "MALWARE"))
+	notBefore := time.Now().Add(time.Hour)
+	scheduled, err := service.CreateScheduledURLRecord(ctx, "https://example.com/later", "owner", &notBefore, nil)
+	require.NoError(t, err)
+
+	result, err := service.ExpandURLs(ctx, []string{active.Short, "unknown", protected.Short, flagged.Short, scheduled.Short})
+	require.NoError(t, err)
+	assert.Equal(t, []models.ExpandBatchResponse{
+		{ShortURL: active.Short, OriginalURL: "https://example.com"},
+		{ShortURL: "unknown", Error: ExpandNotFound},
+		{ShortURL: protected.Short, Error: ExpandPasswordRequired},
+		{ShortURL: flagged.Short, Error: ExpandFlagged},
+		{ShortURL: scheduled.Short, Error: ExpandNotYetActive},
+	}, result)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = service.ExpandURLs(canceled, []string{active.Short})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,52 @@
+// Package service provides expiration of short URLs a fixed time after they
+// were created and activation windows limiting when they redirect.
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// SetLinkTTL makes URLs created from now on expire ttl after their creation;
+// 0 disables expiration. It must be called before the service starts handling requests.
+func (s *URLService) SetLinkTTL(ttl time.Duration) {
+	s.linkTTL = ttl
+}
+
+// expiresAt returns the expiration time of a URL created now, nil if URLs do not expire.
+func (s *URLService) expiresAt() *time.Time {
+	if s.linkTTL <= 0 {
+		return nil
+	}
+	t := time.Now().Add(s.linkTTL).UTC()
+	return &t
+}
+
+// ErrInvalidWindow is returned when creating a URL whose activation window ends
+// before it starts.
+var ErrInvalidWindow = errors.New("not_after must be after not_before")
+
+// ErrNotYetActive is returned when resolving a URL before its activation window starts.
+var ErrNotYetActive = errors.New("short URL is not active yet")
+
+// checkWindow returns ErrInvalidWindow if notAfter is not after notBefore.
+func checkWindow(notBefore, notAfter *time.Time) error {
+	if notBefore != nil && notAfter != nil && !notAfter.After(*notBefore) {
+		return ErrInvalidWindow
+	}
+	return nil
+}
+
+// hideExpired marks an expired record, or one whose activation window has
+// ended, as deleted, so that it is not resolved; expired records are deleted
+// by the sweeper later.
+func hideExpired(r *storage.URLRecord) {
+	if r == nil {
+		return
+	}
+	if now := time.Now(); r.Expired(now) || r.NoLongerActive(now) {
+		r.IsDeleted = true
+	}
+}
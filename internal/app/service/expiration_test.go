@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLService_LinkTTL(t *testing.T) {
+	memStorage, _ := storage.CreateMemoryStorage()
+	resolver, _ := NewURLResolver(8, memStorage)
+
+	service, _ := NewURL(context.Background(), memStorage, resolver, zap.NewNop(), "http://baseurl")
+
+	// Without a TTL URLs never expire
+	r, err := service.CreateURLRecord(context.Background(), "http://a.example.com", "user-id")
+	require.NoError(t, err)
+	assert.Nil(t, r.ExpiresAt)
+
+	service.SetLinkTTL(time.Hour)
+	r, err = service.CreateURLRecord(context.Background(), "http://b.example.com", "user-id")
+	require.NoError(t, err)
+	require.NotNil(t, r.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *r.ExpiresAt, time.Minute)
+
+	_, err = service.CreateURLRecords(context.Background(), []models.BatchRequest{
+		{CorrelationID: "c", OriginalURL: "http://c.example.com"},
+	}, "user-id")
+	require.NoError(t, err)
+
+	expired, err := memStorage.FindExpired(context.Background(), time.Now().Add(2*time.Hour), 0)
+	require.NoError(t, err)
+	assert.Len(t, expired, 2)
+}
+
+func TestURLService_GetURLByShortHidesExpired(t *testing.T) {
+	fileStorage, err := storage.NewFileStorage(t.TempDir()+"/records.json", zap.NewNop())
+	require.NoError(t, err)
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, fileStorage.WriteAll(context.Background(), []storage.URLRecord{
+		{Original: "http://a.example.com", Short: "aaa", UserID: "user-id", ExpiresAt: &past},
+		{Original: "http://b.example.com", Short: "bbb", UserID: "user-id"},
+	}))
+	resolver, _ := NewURLResolver(8, fileStorage)
+
+	service, _ := NewURL(context.Background(), fileStorage, resolver, zap.NewNop(), "http://baseurl")
+
+	// Expired URLs are gone before the sweeper deletes them
+	r, err := service.GetURLByShort(context.Background(), "aaa")
+	require.NoError(t, err)
+	assert.True(t, r.IsDeleted)
+
+	r, err = service.GetURLByShort(context.Background(), "bbb")
+	require.NoError(t, err)
+	assert.False(t, r.IsDeleted)
+}
+
+func TestURLService_ActivationWindow(t *testing.T) {
+	memStorage, _ := storage.CreateMemoryStorage()
+	resolver, _ := NewURLResolver(8, memStorage)
+
+	service, _ := NewURL(context.Background(), memStorage, resolver, zap.NewNop(), "http://baseurl")
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	upcoming, err := service.CreateScheduledURLRecord(context.Background(), "http://a.example.com", "user-id", &future, nil)
+	require.NoError(t, err)
+	running, err := service.CreateScheduledURLRecord(context.Background(), "http://b.example.com", "user-id", &past, &future)
+	require.NoError(t, err)
+	_, err = service.CreateURLRecords(context.Background(), []models.BatchRequest{
+		{CorrelationID: "c", OriginalURL: "http://c.example.com", NotAfter: &past},
+	}, "user-id")
+	require.NoError(t, err)
+
+	_, err = service.GetURLByShort(context.Background(), upcoming.Short)
+	assert.ErrorIs(t, err, ErrNotYetActive)
+
+	r, err := service.GetURLByShort(context.Background(), running.Short)
+	require.NoError(t, err)
+	assert.False(t, r.IsDeleted)
+
+	// Ended windows look like deleted URLs
+	r, err = service.GetURLByShort(context.Background(), resolver.LongToShort("http://c.example.com"))
+	require.NoError(t, err)
+	assert.True(t, r.IsDeleted)
+
+	_, err = service.CreateScheduledURLRecord(context.Background(), "http://d.example.com", "user-id", &future, &past)
+	assert.ErrorIs(t, err, ErrInvalidWindow)
+	batch, err := service.CreateURLRecords(context.Background(), []models.BatchRequest{
+		{CorrelationID: "e", OriginalURL: "http://e.example.com", NotBefore: &future, NotAfter: &future},
+	}, "user-id")
+	require.NoError(t, err)
+	assert.Equal(t, []models.BatchResponse{{CorrelationID: "e", Status: models.BatchStatusInvalid, Error: ErrInvalidWindow.Error()}}, *batch)
+}
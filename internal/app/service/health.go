@@ -0,0 +1,72 @@
+// Package service provides readiness checks of the dependencies the service
+// needs to handle requests, used by orchestrator probes.
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+)
+
+// Health statuses.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// healthCheckTimeout bounds every readiness check, so a hanging dependency
+// fails the probe instead of blocking it.
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck returns an error if a dependency is unhealthy.
+type HealthCheck func(ctx context.Context) error
+
+// Health runs named readiness checks.
+type Health struct {
+	checks map[string]HealthCheck
+}
+
+// NewHealth creates a Health without checks.
+func NewHealth() *Health {
+	return &Health{checks: make(map[string]HealthCheck)}
+}
+
+// AddCheck registers a readiness check under the given name. It must be
+// called before the service starts handling requests.
+func (h *Health) AddCheck(name string, check HealthCheck) {
+	h.checks[name] = check
+}
+
+// Ready runs all checks concurrently and reports whether all of them passed.
+func (h *Health) Ready(ctx context.Context) (*models.HealthResponse, bool) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	res := &models.HealthResponse{Status: StatusOK, Checks: make(map[string]models.DependencyStatus, len(h.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+
+			status := models.DependencyStatus{Status: StatusOK}
+			if err := check(ctx); err != nil {
+				status = models.DependencyStatus{Status: StatusFail, Error: err.Error()}
+			}
+
+			mu.Lock()
+			res.Checks[name] = status
+			if status.Status != StatusOK {
+				res.Status = StatusFail
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	return res, res.Status == StatusOK
+}
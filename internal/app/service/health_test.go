@@ -0,0 +1,43 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+)
+
+func TestHealthReady(t *testing.T) {
+	h := service.NewHealth()
+	h.AddCheck("storage", func(ctx context.Context) error { return nil })
+
+	res, ok := h.Ready(context.Background())
+	assert.True(t, ok)
+	assert.Equal(t, &models.HealthResponse{
+		Status: service.StatusOK,
+		Checks: map[string]models.DependencyStatus{"storage": {Status: service.StatusOK}},
+	}, res)
+
+	h.AddCheck("delete_worker", func(ctx context.Context) error { return service.ErrWorkerStopped })
+
+	res, ok = h.Ready(context.Background())
+	assert.False(t, ok)
+	assert.Equal(t, service.StatusFail, res.Status)
+	assert.Equal(t, models.DependencyStatus{Status: service.StatusFail, Error: service.ErrWorkerStopped.Error()}, res.Checks["delete_worker"])
+	assert.Equal(t, models.DependencyStatus{Status: service.StatusOK}, res.Checks["storage"])
+}
+
+func TestHealthReadyTimeout(t *testing.T) {
+	h := service.NewHealth()
+	h.AddCheck("storage", func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("ping timed out")
+	})
+
+	_, ok := h.Ready(context.Background())
+	assert.False(t, ok)
+}
@@ -4,6 +4,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/atinyakov/go-url-shortener/internal/models"
 	"github.com/atinyakov/go-url-shortener/internal/storage"
@@ -27,14 +28,58 @@ type Storage interface {
 	// FindByShort retrieves a URL record by its shortened URL.
 	FindByShort(context.Context, string) (*storage.URLRecord, error)
 
-	// FindByUserID retrieves all URL records associated with a given user ID.
-	FindByUserID(context.Context, string) (*[]storage.URLRecord, error)
+	// FindByUserID retrieves URL records associated with a given user ID,
+	// filtered, ordered and paginated according to the list options.
+	FindByUserID(context.Context, string, storage.ListOptions) (*[]storage.URLRecord, error)
 
 	// PingContext checks the connectivity to the storage backend.
 	PingContext(context.Context) error
 
 	// FindByID retrieves a URL record by its ID.
 	FindByID(context.Context, string) (storage.URLRecord, error)
+
+	// FindExpired retrieves up to limit active records that expired at or before
+	// the given time; limit 0 means no limit.
+	FindExpired(context.Context, time.Time, int) ([]storage.URLRecord, error)
+
+	// CountByUserID returns the number of active URL records owned by a user.
+	CountByUserID(context.Context, string) (int, error)
+
+	// GetStats returns the number of stored URLs and of users owning them.
+	GetStats(context.Context) (urls int, users int, err error)
+
+	// GetDailyStats returns the number of URL records created and deleted on
+	// each day from from until to, in UTC, oldest first; days without changes
+	// may be omitted.
+	GetDailyStats(ctx context.Context, from, to time.Time) ([]storage.DailyStats, error)
+
+	// FlagURL disables a URL record whose original URL was found to be a
+	// threat, recording the threat type.
+	FlagURL(ctx context.Context, short string, reason string) error
+
+	// SetPassword sets the password hash of a URL record owned by the user;
+	// an empty hash removes the protection.
+	SetPassword(ctx context.Context, short string, userID string, hash string) error
+
+	// SetTags replaces the tags of a URL record owned by the user.
+	SetTags(ctx context.Context, short string, userID string, tags []string) error
+
+	// SetPinned pins or unpins a URL record owned by the user.
+	SetPinned(ctx context.Context, short string, userID string, pinned bool) error
+
+	// SetPrivate makes the metadata and stats of a URL record owned by the
+	// user visible to them only, or to anyone.
+	SetPrivate(ctx context.Context, short string, userID string, private bool) error
+
+	// SetCampaign moves a URL record owned by the user to a campaign; an
+	// empty ID removes it from its campaign.
+	SetCampaign(ctx context.Context, short string, userID string, campaignID string) error
+
+	// SetDetails sets the title and description of a URL record owned by the user.
+	SetDetails(ctx context.Context, short string, userID string, title string, description string) error
+
+	// SetDefaultTitle sets the title of a URL record that has none.
+	SetDefaultTitle(ctx context.Context, short string, title string) error
 }
 
 // URLServiceIface is an interface that defines the URL service's core functionality.
@@ -43,6 +88,14 @@ type URLServiceIface interface {
 	// CreateURLRecord creates a new URL record based on a long URL and user ID.
 	CreateURLRecord(ctx context.Context, long string, userID string) (*storage.URLRecord, error)
 
+	// CreateScheduledURLRecord is like CreateURLRecord, but the short URL only
+	// redirects between notBefore and notAfter; nil bounds do not restrict it.
+	CreateScheduledURLRecord(ctx context.Context, long string, userID string, notBefore, notAfter *time.Time) (*storage.URLRecord, error)
+
+	// CreateURLRecordWithOptions is like CreateURLRecord, with the activation
+	// window, tags, title and description of opts.
+	CreateURLRecordWithOptions(ctx context.Context, long string, userID string, opts CreateOptions) (*storage.URLRecord, error)
+
 	// CreateURLRecords creates multiple URL records in batch, based on a list of requests and user ID.
 	CreateURLRecords(ctx context.Context, rs []models.BatchRequest, userID string) (*[]models.BatchResponse, error)
 
@@ -52,9 +105,192 @@ type URLServiceIface interface {
 	// GetURLByShort retrieves a URL record by its shortened URL.
 	GetURLByShort(ctx context.Context, short string) (*storage.URLRecord, error)
 
-	// GetURLByUserID retrieves all URL records associated with a given user ID.
-	GetURLByUserID(ctx context.Context, id string) (*[]models.ByIDRequest, error)
+	// RecordRedirect counts a click of the short URL of r, once the request
+	// is redirected to its original URL.
+	RecordRedirect(ctx context.Context, r *storage.URLRecord)
+
+	// UnlockURL checks the password of a protected URL record for the client
+	// of the request, refusing clients sending too many wrong passwords.
+	UnlockURL(ctx context.Context, r *storage.URLRecord, password string) error
+
+	// GetURLMetadata returns the metadata of a short URL as seen by the user,
+	// without resolving it.
+	GetURLMetadata(ctx context.Context, short string, userID string) (*models.URLMetadata, error)
+
+	// ExpandURLs resolves many short URLs to their original URLs at once,
+	// without following them.
+	ExpandURLs(ctx context.Context, shorts []string) ([]models.ExpandBatchResponse, error)
+
+	// LiveStats returns the rolling totals of this instance, such as the URLs
+	// created and the redirects served in the last minute.
+	LiveStats(ctx context.Context) models.LiveStats
+
+	// Bans returns the current bans of users and client IPs creating URLs too fast.
+	Bans(ctx context.Context) []models.Ban
+
+	// LiftBan ends the ban of a user or a client IP, depending on kind, right away.
+	LiftBan(ctx context.Context, kind string, subject string) error
+
+	// StreamClicks subscribes the owner of a short URL to its clicks; the
+	// returned function ends the subscription.
+	StreamClicks(ctx context.Context, short string, userID string) (<-chan models.ClickEvent, func(), error)
+
+	// GetURLByUserID retrieves URL records associated with a given user ID,
+	// filtered, ordered and paginated according to opts.
+	GetURLByUserID(ctx context.Context, id string, opts storage.ListOptions) (*[]models.ByIDRequest, error)
 
 	// PingContext checks the health of the URL service.
 	PingContext(ctx context.Context) error
+
+	// ForceDeleteURLRecords deletes URL records by their short URLs regardless of their owner.
+	ForceDeleteURLRecords(ctx context.Context, shorts []string) error
+
+	// GetStats returns global statistics of the service, with the daily
+	// changes of the range of opts, if it has one.
+	GetStats(ctx context.Context, opts StatsOptions) (*models.Stats, error)
+
+	// TopURLs returns up to limit short URLs with the most clicks in the last window.
+	TopURLs(ctx context.Context, window time.Duration, limit int) ([]models.TopURL, error)
+
+	// SetURLPassword protects a URL record owned by the user with a password;
+	// an empty password removes the protection.
+	SetURLPassword(ctx context.Context, short string, userID string, password string) error
+
+	// SetURLTags replaces the tags of a URL record owned by the user and
+	// returns the stored tags.
+	SetURLTags(ctx context.Context, short string, userID string, tags []string) ([]string, error)
+
+	// SetURLPinned pins a URL record owned by the user to the top of their
+	// listing or unpins it.
+	SetURLPinned(ctx context.Context, short string, userID string, pinned bool) error
+
+	// SetURLVisibility makes the metadata and stats of a URL record owned by
+	// the user public or private.
+	SetURLVisibility(ctx context.Context, short string, userID string, visibility string) error
+
+	// GetURLStats returns the clicks of a short URL in the last window, as
+	// seen by the user.
+	GetURLStats(ctx context.Context, short string, userID string, window time.Duration) (*models.URLStats, error)
+
+	// CreateCampaign creates a named campaign of the user to group URLs in.
+	CreateCampaign(ctx context.Context, userID string, name string) (*storage.Campaign, error)
+
+	// GetCampaigns returns the campaigns of the user, oldest first.
+	GetCampaigns(ctx context.Context, userID string) ([]storage.Campaign, error)
+
+	// SetURLCampaign moves a URL record owned by the user to one of their
+	// campaigns; an empty ID removes it from its campaign.
+	SetURLCampaign(ctx context.Context, short string, userID string, campaignID string) error
+
+	// CampaignStats returns the clicks of the URLs of a campaign of the user
+	// in the last window.
+	CampaignStats(ctx context.Context, userID string, campaignID string, window time.Duration) (*models.CampaignStats, error)
+
+	// SetURLDetails sets the title and description of a URL record owned by
+	// the user; empty strings remove them.
+	SetURLDetails(ctx context.Context, short string, userID string, title string, description string) error
+
+	// Tenant returns the tenant of the request, which sets the base URL of
+	// short URLs, the redirect code and the branding of the pages.
+	Tenant(ctx context.Context) Tenant
+}
+
+// UserStorage persists user accounts.
+type UserStorage interface {
+	// CreateUser stores a new user; creating an existing user is a no-op.
+	CreateUser(ctx context.Context, u storage.User) error
+
+	// FindUser retrieves a user by ID.
+	FindUser(ctx context.Context, id string) (*storage.User, error)
+
+	// UpdateDisplayName sets the display name of a user, creating the user if needed.
+	UpdateDisplayName(ctx context.Context, id string, name string) error
+}
+
+// UsersIface defines operations on user accounts, used by authentication and handlers.
+type UsersIface interface {
+	// Register creates the account of a newly issued user ID.
+	Register(ctx context.Context, id string) error
+
+	// Get returns the account of a user, creating it if the user predates accounts.
+	Get(ctx context.Context, id string) (*storage.User, error)
+
+	// SetDisplayName changes the display name of a user.
+	SetDisplayName(ctx context.Context, id string, name string) (*storage.User, error)
+}
+
+// AuditStorage persists the append-only audit log.
+type AuditStorage interface {
+	// AppendAudit appends an event to the audit log.
+	AppendAudit(ctx context.Context, e storage.AuditEvent) error
+
+	// FindAudit returns the events matching the filter, newest first.
+	FindAudit(ctx context.Context, f storage.AuditFilter) ([]storage.AuditEvent, error)
+}
+
+// AuditIface defines recording and querying the audit log, used by middleware and handlers.
+type AuditIface interface {
+	// Record appends an event to the audit log.
+	Record(ctx context.Context, e storage.AuditEvent)
+
+	// Query returns the events matching the filter, newest first.
+	Query(ctx context.Context, f storage.AuditFilter) ([]storage.AuditEvent, error)
+}
+
+// APIKeyStorage persists API keys issued to users for machine-to-machine access.
+// Only hashes of the keys are stored.
+type APIKeyStorage interface {
+	// SaveAPIKey stores the hash of a new API key for the given user ID.
+	SaveAPIKey(ctx context.Context, hash string, userID string) error
+
+	// FindUserByAPIKey returns the ID of the user owning the API key with the given hash.
+	FindUserByAPIKey(ctx context.Context, hash string) (string, error)
+}
+
+// APIKeyIface defines issuing and resolving API keys, used by handlers and middleware.
+type APIKeyIface interface {
+	// Issue creates a new API key for the user and returns it in plain text.
+	Issue(ctx context.Context, userID string) (string, error)
+
+	// Resolve returns the ID of the user owning the API key.
+	Resolve(ctx context.Context, key string) (string, error)
+}
+
+// ClickStorage counts the clicks of short URLs per storage.ClickBucket.
+type ClickStorage interface {
+	// RecordClicks adds the clicks to the counts of their short URLs and buckets.
+	RecordClicks(ctx context.Context, clicks []storage.BucketClicks) error
+
+	// TopClicked returns up to limit short URLs with the most clicks since the
+	// given time, most clicked first; limit 0 means no limit.
+	TopClicked(ctx context.Context, since time.Time, limit int) ([]storage.ClickCount, error)
+
+	// ClicksOf returns the clicks of the given short URLs since the given
+	// time, most clicked first, leaving out the short URLs without clicks.
+	ClicksOf(ctx context.Context, shorts []string, since time.Time) ([]storage.ClickCount, error)
+}
+
+// CampaignStorage persists the campaigns URL records are grouped in.
+type CampaignStorage interface {
+	// CreateCampaign stores a new campaign; names are unique per user.
+	CreateCampaign(ctx context.Context, c storage.Campaign) error
+
+	// FindCampaign retrieves a campaign by ID.
+	FindCampaign(ctx context.Context, id string) (*storage.Campaign, error)
+
+	// FindCampaignsByUserID returns the campaigns of a user, oldest first.
+	FindCampaignsByUserID(ctx context.Context, userID string) ([]storage.Campaign, error)
+}
+
+// EventPublisher delivers serialized events to a message broker subject.
+// Implementations must be safe for concurrent use.
+type EventPublisher interface {
+	// Publish sends payload to the given subject.
+	Publish(ctx context.Context, subject string, payload []byte) error
+}
+
+// HealthIface reports whether the service is ready to handle requests.
+type HealthIface interface {
+	// Ready runs the readiness checks and reports whether all of them passed.
+	Ready(ctx context.Context) (*models.HealthResponse, bool)
 }
@@ -0,0 +1,104 @@
+// Package service provides the key set used for signing and verifying JWT
+// tokens. Besides HMAC secrets it supports RS256 and EdDSA keys, so tokens
+// can be verified by other services holding only the public key.
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrNoSigningKey is returned by NewAuthWithKeys when the first key of the set cannot sign tokens.
+var ErrNoSigningKey = errors.New("jwt key set has no signing key")
+
+// SigningKey is a key of the JWT key set. Keys without a private part are
+// only accepted for verification, e.g. public keys of a rotated-out key pair.
+type SigningKey struct {
+	ID      string            // Key ID written to and matched against the "kid" header
+	Method  jwt.SigningMethod // Signing algorithm of the key
+	Private any               // Key used for signing; nil for verification-only keys
+	Public  any               // Key used for verification
+}
+
+// HMACKey returns an HS256 key for the given secret. HMAC keys carry no key ID,
+// so tokens signed with them are verified by trying every HMAC key of the set.
+func HMACKey(secret string) (SigningKey, error) {
+	if len(secret) < MinSecretLength {
+		return SigningKey{}, ErrWeakSecret
+	}
+	return SigningKey{
+		Method:  jwt.SigningMethodHS256,
+		Private: []byte(secret),
+		Public:  []byte(secret),
+	}, nil
+}
+
+// LoadSigningKey reads a PEM-encoded RSA or Ed25519 private key from path.
+// RSA keys sign with RS256 and Ed25519 keys with EdDSA.
+func LoadSigningKey(path string) (SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	if private, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return newAsymmetricKey(jwt.SigningMethodRS256, private, &private.PublicKey)
+	}
+
+	if private, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		edPrivate := private.(ed25519.PrivateKey)
+		return newAsymmetricKey(jwt.SigningMethodEdDSA, edPrivate, edPrivate.Public())
+	}
+
+	return SigningKey{}, fmt.Errorf("%s: unsupported private key, expected RSA or Ed25519 in PEM format", path)
+}
+
+// LoadVerificationKey reads a PEM-encoded RSA or Ed25519 public key from path.
+// The key is accepted for verification only.
+func LoadVerificationKey(path string) (SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	if public, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return newAsymmetricKey(jwt.SigningMethodRS256, nil, public)
+	}
+
+	if public, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return newAsymmetricKey(jwt.SigningMethodEdDSA, nil, public)
+	}
+
+	return SigningKey{}, fmt.Errorf("%s: unsupported public key, expected RSA or Ed25519 in PEM format", path)
+}
+
+// newAsymmetricKey builds a key whose ID is derived from the public key,
+// so the same key pair gets the same ID on every instance of the service.
+func newAsymmetricKey(method jwt.SigningMethod, private any, public any) (SigningKey, error) {
+	switch public.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported public key type %T", public)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	sum := sha256.Sum256(der)
+
+	return SigningKey{
+		ID:      hex.EncodeToString(sum[:8]),
+		Method:  method,
+		Private: private,
+		Public:  public,
+	}, nil
+}
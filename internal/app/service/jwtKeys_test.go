@@ -0,0 +1,190 @@
+package service_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+)
+
+// writeKeyPair writes the PEM-encoded private and public parts of key to a temporary directory.
+func writeKeyPair(t *testing.T, private any, public any) (privatePath string, publicPath string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+
+	privatePath = filepath.Join(dir, "private.pem")
+	publicPath = filepath.Join(dir, "public.pem")
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600))
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o644))
+	return privatePath, publicPath
+}
+
+func newURLServiceMock(t *testing.T) *mocks.MockURLServiceIface {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockURLServiceIface(ctrl)
+	m.EXPECT().
+		GetURLByUserID(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&[]models.ByIDRequest{}, nil).
+		AnyTimes()
+	return m
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		private any
+		public  any
+		alg     string
+	}{
+		{name: "RS256", private: rsaKey, public: &rsaKey.PublicKey, alg: "RS256"},
+		{name: "EdDSA", private: edPrivate, public: edPublic, alg: "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			privatePath, publicPath := writeKeyPair(t, tt.private, tt.public)
+
+			signing, err := service.LoadSigningKey(privatePath)
+			require.NoError(t, err)
+			verification, err := service.LoadVerificationKey(publicPath)
+			require.NoError(t, err)
+			require.Equal(t, signing.ID, verification.ID)
+			require.Nil(t, verification.Private)
+
+			auth, err := service.NewAuthWithKeys(newURLServiceMock(t), []service.SigningKey{signing})
+			require.NoError(t, err)
+
+			tokenString, userID, err := auth.BuildJWTString()
+			require.NoError(t, err)
+
+			// The token names its key and algorithm
+			token, _, err := jwt.NewParser().ParseUnverified(tokenString, &service.Claims{})
+			require.NoError(t, err)
+			require.Equal(t, tt.alg, token.Header["alg"])
+			require.Equal(t, signing.ID, token.Header["kid"])
+
+			claims, err := auth.ParseRawJWT(tokenString)
+			require.NoError(t, err)
+			require.Equal(t, userID, claims.UserID)
+
+			// Another service holding only the public key can verify the token
+			verifier, err := service.NewAuthWithKeys(nil, []service.SigningKey{mustHMACKey(t), verification})
+			require.NoError(t, err)
+			claims, err = verifier.ParseRawJWT(tokenString)
+			require.NoError(t, err)
+			require.Equal(t, userID, claims.UserID)
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newPublic, newPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	oldPrivatePath, oldPublicPath := writeKeyPair(t, oldKey, &oldKey.PublicKey)
+	newPrivatePath, _ := writeKeyPair(t, newPrivate, newPublic)
+
+	oldSigning, err := service.LoadSigningKey(oldPrivatePath)
+	require.NoError(t, err)
+	oldAuth, err := service.NewAuthWithKeys(newURLServiceMock(t), []service.SigningKey{oldSigning})
+	require.NoError(t, err)
+	oldToken, oldUserID, err := oldAuth.BuildJWTString()
+	require.NoError(t, err)
+
+	// After rotation the old public key is still accepted
+	newSigning, err := service.LoadSigningKey(newPrivatePath)
+	require.NoError(t, err)
+	oldVerification, err := service.LoadVerificationKey(oldPublicPath)
+	require.NoError(t, err)
+	auth, err := service.NewAuthWithKeys(newURLServiceMock(t), []service.SigningKey{newSigning, oldVerification})
+	require.NoError(t, err)
+
+	claims, err := auth.ParseRawJWT(oldToken)
+	require.NoError(t, err)
+	require.Equal(t, oldUserID, claims.UserID)
+
+	// Once the old key is dropped its tokens are rejected
+	dropped, err := service.NewAuthWithKeys(nil, []service.SigningKey{newSigning})
+	require.NoError(t, err)
+	_, err = dropped.ParseRawJWT(oldToken)
+	require.Error(t, err)
+}
+
+func TestParseRawJWTRejectsAlgorithmSubstitution(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, publicPath := writeKeyPair(t, rsaKey, &rsaKey.PublicKey)
+
+	verification, err := service.LoadVerificationKey(publicPath)
+	require.NoError(t, err)
+	auth, err := service.NewAuthWithKeys(nil, []service.SigningKey{mustHMACKey(t), verification})
+	require.NoError(t, err)
+
+	// A token signed with HS256 using the public key as the secret must not verify
+	publicPEM, err := os.ReadFile(publicPath)
+	require.NoError(t, err)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, service.Claims{UserID: "forged"})
+	token.Header["kid"] = verification.ID
+	forged, err := token.SignedString(publicPEM)
+	require.NoError(t, err)
+
+	_, err = auth.ParseRawJWT(forged)
+	require.Error(t, err)
+}
+
+func TestNewAuthWithKeysRequiresSigningKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, publicPath := writeKeyPair(t, rsaKey, &rsaKey.PublicKey)
+	verification, err := service.LoadVerificationKey(publicPath)
+	require.NoError(t, err)
+
+	_, err = service.NewAuthWithKeys(nil, nil)
+	require.ErrorIs(t, err, service.ErrNoSigningKey)
+
+	_, err = service.NewAuthWithKeys(nil, []service.SigningKey{verification})
+	require.ErrorIs(t, err, service.ErrNoSigningKey)
+}
+
+func TestLoadKeyErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "garbage.pem")
+	require.NoError(t, os.WriteFile(path, []byte("not a key"), 0o600))
+
+	_, err := service.LoadSigningKey(path)
+	require.Error(t, err)
+	_, err = service.LoadVerificationKey(path)
+	require.Error(t, err)
+	_, err = service.LoadSigningKey(filepath.Join(t.TempDir(), "missing.pem"))
+	require.Error(t, err)
+}
+
+func mustHMACKey(t *testing.T) service.SigningKey {
+	t.Helper()
+	key, err := service.HMACKey(testSecret)
+	require.NoError(t, err)
+	return key
+}
@@ -0,0 +1,240 @@
+// Package service provides a cache of URL records in front of the storage, so
+// that redirects of popular short URLs do not query the storage every time.
+// Changes made through the cached storage, including the deletions of the
+// background delete worker, invalidate the cached records they affect.
+package service
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// ErrInvalidLinkCache is returned by NewLinkCache for a size or TTL that is not positive.
+var ErrInvalidLinkCache = errors.New("link cache size and ttl must be positive")
+
+// LinkCache is a least recently used cache of URL records by short URL, whose
+// entries expire after a TTL. It is safe for concurrent use.
+type LinkCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // Maps short URL to its element of order
+	order   *list.List               // Entries, most recently used first
+	gen     uint64                   // Incremented by every invalidation
+}
+
+// linkCacheEntry is a cached record with the time it expires at.
+type linkCacheEntry struct {
+	record  storage.URLRecord
+	expires time.Time
+}
+
+// NewLinkCache returns a cache of up to size records, each kept for at most ttl.
+func NewLinkCache(size int, ttl time.Duration) (*LinkCache, error) {
+	if size <= 0 || ttl <= 0 {
+		return nil, ErrInvalidLinkCache
+	}
+	return &LinkCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}, nil
+}
+
+// Get returns a copy of the cached record of the short URL, if it has one
+// that has not expired.
+func (c *LinkCache) Get(short string) (storage.URLRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[short]
+	if !ok {
+		return storage.URLRecord{}, false
+	}
+	entry := el.Value.(*linkCacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(el)
+		return storage.URLRecord{}, false
+	}
+	c.order.MoveToFront(el)
+	return entry.record, true
+}
+
+// Generation returns a value that changes with every invalidation, to be
+// passed to Add once the record was read from the storage.
+func (c *LinkCache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.gen
+}
+
+// Add caches a copy of r, evicting the least recently used record if the
+// cache is full, unless the cache was invalidated since gen was returned by
+// Generation: the record may then have been read before a change that the
+// invalidation was for, and caching it would hide that change.
+func (c *LinkCache) Add(r storage.URLRecord, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+	entry := &linkCacheEntry{record: r, expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[r.Short]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[r.Short] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Invalidate removes the cached records of the short URLs.
+func (c *LinkCache) Invalidate(shorts ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for _, short := range shorts {
+		if el, ok := c.entries[short]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+// Len returns the number of cached records, including expired ones not evicted yet.
+func (c *LinkCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// remove removes the element from the cache. The caller must hold the lock.
+func (c *LinkCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*linkCacheEntry).record.Short)
+}
+
+// CachedStorage is a Storage looking up records by short URL in a LinkCache
+// first. Deleting, flagging and protecting records through it invalidates
+// their cached copies once the storage applied the change, so that deleted
+// and flagged short URLs stop redirecting as soon as the delete worker or
+// threat scanner processed them, rather than when the cached copy expires.
+type CachedStorage struct {
+	Storage
+	cache *LinkCache
+}
+
+// NewCachedStorage returns s with lookups by short URL cached in cache.
+func NewCachedStorage(s Storage, cache *LinkCache) *CachedStorage {
+	return &CachedStorage{Storage: s, cache: cache}
+}
+
+// Cache returns the cache of the storage.
+func (c *CachedStorage) Cache() *LinkCache {
+	return c.cache
+}
+
+// FindByShort returns the cached record of the short URL, looking it up in the
+// storage and caching it on a miss. Unknown short URLs are not cached.
+func (c *CachedStorage) FindByShort(ctx context.Context, short string) (*storage.URLRecord, error) {
+	if r, ok := c.cache.Get(short); ok {
+		return &r, nil
+	}
+
+	gen := c.cache.Generation()
+	r, err := c.Storage.FindByShort(ctx, short)
+	if err != nil || r == nil {
+		return r, err
+	}
+	c.cache.Add(*r, gen)
+	return r, nil
+}
+
+// DeleteBatch deletes the records in the storage and invalidates their cached
+// copies, even if the storage failed, since it may have deleted some of them.
+func (c *CachedStorage) DeleteBatch(ctx context.Context, rs []storage.URLRecord) error {
+	err := c.Storage.DeleteBatch(ctx, rs)
+	shorts := make([]string, 0, len(rs))
+	for _, r := range rs {
+		shorts = append(shorts, r.Short)
+	}
+	c.cache.Invalidate(shorts...)
+	return err
+}
+
+// FlagURL flags the record in the storage and invalidates its cached copy.
+func (c *CachedStorage) FlagURL(ctx context.Context, short string, reason string) error {
+	err := c.Storage.FlagURL(ctx, short, reason)
+	c.cache.Invalidate(short)
+	return err
+}
+
+// SetPassword sets the password hash of the record in the storage and
+// invalidates its cached copy.
+func (c *CachedStorage) SetPassword(ctx context.Context, short string, userID string, hash string) error {
+	err := c.Storage.SetPassword(ctx, short, userID, hash)
+	c.cache.Invalidate(short)
+	return err
+}
+
+// SetTags sets the tags of the record in the storage and invalidates its
+// cached copy.
+func (c *CachedStorage) SetTags(ctx context.Context, short string, userID string, tags []string) error {
+	err := c.Storage.SetTags(ctx, short, userID, tags)
+	c.cache.Invalidate(short)
+	return err
+}
+
+// SetPinned pins or unpins the record in the storage and invalidates its cached
+// copy.
+func (c *CachedStorage) SetPinned(ctx context.Context, short string, userID string, pinned bool) error {
+	err := c.Storage.SetPinned(ctx, short, userID, pinned)
+	c.cache.Invalidate(short)
+	return err
+}
+
+// SetPrivate changes the visibility of the record in the storage and
+// invalidates its cached copy.
+func (c *CachedStorage) SetPrivate(ctx context.Context, short string, userID string, private bool) error {
+	err := c.Storage.SetPrivate(ctx, short, userID, private)
+	c.cache.Invalidate(short)
+	return err
+}
+
+// SetCampaign moves the record to a campaign in the storage and invalidates
+// its cached copy.
+func (c *CachedStorage) SetCampaign(ctx context.Context, short string, userID string, campaignID string) error {
+	err := c.Storage.SetCampaign(ctx, short, userID, campaignID)
+	c.cache.Invalidate(short)
+	return err
+}
+
+// SetDetails sets the title and description of the record in the storage and
+// invalidates its cached copy.
+func (c *CachedStorage) SetDetails(ctx context.Context, short string, userID string, title string, description string) error {
+	err := c.Storage.SetDetails(ctx, short, userID, title, description)
+	c.cache.Invalidate(short)
+	return err
+}
+
+// SetDefaultTitle sets the title of the record in the storage if it has none
+// and invalidates its cached copy.
+func (c *CachedStorage) SetDefaultTitle(ctx context.Context, short string, title string) error {
+	err := c.Storage.SetDefaultTitle(ctx, short, title)
+	c.cache.Invalidate(short)
+	return err
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+// countingStorage counts the lookups by short URL.
+type countingStorage struct {
+	*storage.MemoryStorage
+	lookups int
+}
+
+func (s *countingStorage) FindByShort(ctx context.Context, short string) (*storage.URLRecord, error) {
+	s.lookups++
+	return s.MemoryStorage.FindByShort(ctx, short)
+}
+
+func TestNewLinkCache_Invalid(t *testing.T) {
+	_, err := NewLinkCache(0, time.Minute)
+	assert.ErrorIs(t, err, ErrInvalidLinkCache)
+	_, err = NewLinkCache(10, 0)
+	assert.ErrorIs(t, err, ErrInvalidLinkCache)
+}
+
+func TestLinkCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c, err := NewLinkCache(2, time.Minute)
+	require.NoError(t, err)
+
+	c.Add(storage.URLRecord{Short: "a"}, c.Generation())
+	c.Add(storage.URLRecord{Short: "b"}, c.Generation())
+	_, ok := c.Get("a")
+	require.True(t, ok)
+	c.Add(storage.URLRecord{Short: "c"}, c.Generation())
+
+	assert.Equal(t, 2, c.Len())
+	_, ok = c.Get("b")
+	assert.False(t, ok, "b was used least recently")
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+}
+
+func TestLinkCache_Expires(t *testing.T) {
+	c, err := NewLinkCache(10, time.Minute)
+	require.NoError(t, err)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Add(storage.URLRecord{Short: "a"}, c.Generation())
+	now = now.Add(59 * time.Second)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Zero(t, c.Len(), "expired records are evicted when found")
+}
+
+func TestLinkCache_AddAfterInvalidation(t *testing.T) {
+	c, err := NewLinkCache(10, time.Minute)
+	require.NoError(t, err)
+
+	// A record read before an invalidation may be stale, so it is not cached
+	gen := c.Generation()
+	c.Invalidate("a")
+	c.Add(storage.URLRecord{Short: "a"}, gen)
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestCachedStorage(t *testing.T) {
+	ctx := context.Background()
+	mem, _ := storage.CreateMemoryStorage()
+	backend := &countingStorage{MemoryStorage: mem}
+	_, err := mem.Write(ctx, storage.URLRecord{Short: "abc", Original: "https://example.com", UserID: "u1"})
+	require.NoError(t, err)
+
+	cache, err := NewLinkCache(10, time.Minute)
+	require.NoError(t, err)
+	s := NewCachedStorage(backend, cache)
+
+	// Lookups are served from the cache after the first one
+	for i := 0; i < 3; i++ {
+		r, err := s.FindByShort(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", r.Original)
+	}
+	assert.Equal(t, 1, backend.lookups)
+
+	// Unknown short URLs are not cached
+	for i := 0; i < 2; i++ {
+		_, err = s.FindByShort(ctx, "missing")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	}
+	assert.Equal(t, 3, backend.lookups)
+
+	// Flagging invalidates the cached record
+	require.NoError(t, s.FlagURL(ctx, "abc", "malware"))
+	r, err := s.FindByShort(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "malware", r.Flagged)
+	assert.Equal(t, 4, backend.lookups)
+
+	// So does deleting
+	require.NoError(t, s.DeleteBatch(ctx, []storage.URLRecord{{Short: "abc", UserID: "u1"}}))
+	r, err = s.FindByShort(ctx, "abc")
+	require.NoError(t, err)
+	assert.True(t, r.IsDeleted)
+	assert.Equal(t, 5, backend.lookups)
+}
+
+func TestURLService_DeleteInvalidatesCachedLinks(t *testing.T) {
+	ctx := context.Background()
+	mem, _ := storage.CreateMemoryStorage()
+	cache, err := NewLinkCache(10, time.Hour)
+	require.NoError(t, err)
+	s := NewCachedStorage(mem, cache)
+	resolver, _ := NewURLResolver(8, s)
+	service, shutdown := NewURLWithOptions(ctx, s, resolver, zap.NewNop(), "http://baseurl", worker.Options{FlushInterval: 10 * time.Millisecond})
+	defer shutdown()
+
+	created, err := service.CreateURLRecord(ctx, "https://example.com", "u1")
+	require.NoError(t, err)
+	r, err := service.GetURLByShort(ctx, created.Short)
+	require.NoError(t, err)
+	require.False(t, r.IsDeleted)
+
+	// The delete worker deletes through the cached storage, which invalidates the record
+	service.DeleteURLRecords(ctx, []storage.URLRecord{{Short: created.Short, UserID: "u1"}})
+	require.Eventually(t, func() bool {
+		r, err := service.GetURLByShort(ctx, created.Short)
+		return err == nil && r.IsDeleted
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,67 @@
+// Package service provides the rolling totals of the live stats stream: the
+// URLs created and the redirects served by this instance in the last minute,
+// and the depth of the delete queue, for ops dashboards.
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+)
+
+// rateBuckets is the number of one-second buckets of a rateCounter, which is
+// the length of its window in seconds.
+const rateBuckets = 60
+
+// rateCounter counts events over the last minute in one-second buckets. Its
+// zero value is ready to use, and it is safe for concurrent use.
+type rateCounter struct {
+	mu      sync.Mutex
+	counts  [rateBuckets]int
+	seconds [rateBuckets]int64 // Unix second each bucket counts
+}
+
+// add counts n events at now.
+func (c *rateCounter) add(now time.Time, n int) {
+	sec := now.Unix()
+	i := sec % rateBuckets
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.seconds[i] != sec {
+		c.seconds[i], c.counts[i] = sec, 0
+	}
+	c.counts[i] += n
+}
+
+// total returns the number of events in the minute up to now.
+func (c *rateCounter) total(now time.Time) int {
+	sec := now.Unix()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	for i := range c.counts {
+		if sec-c.seconds[i] < rateBuckets {
+			total += c.counts[i]
+		}
+	}
+	return total
+}
+
+// LiveStats returns the number of URLs created and of redirects served by this
+// instance in the last minute, and the number of deletions queued in the
+// delete worker.
+func (s *URLService) LiveStats(ctx context.Context) models.LiveStats {
+	now := time.Now()
+	return models.LiveStats{
+		Time:               now.UTC(),
+		CreatedPerMinute:   s.created.total(now),
+		RedirectsPerMinute: s.redirects.total(now),
+		DeleteQueueDepth:   s.deleter.Queued(),
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestRateCounter(t *testing.T) {
+	var c rateCounter
+	start := time.Unix(1_700_000_000, 0)
+
+	c.add(start, 2)
+	c.add(start.Add(30*time.Second), 3)
+	assert.Equal(t, 5, c.total(start.Add(30*time.Second)))
+
+	// Events leave the window a minute after they were counted
+	assert.Equal(t, 3, c.total(start.Add(time.Minute)))
+	assert.Equal(t, 0, c.total(start.Add(90*time.Second)))
+
+	// A bucket reused a minute later starts over
+	c.add(start.Add(time.Minute), 1)
+	assert.Equal(t, 4, c.total(start.Add(time.Minute)))
+}
+
+func TestURLService_LiveStats(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	resolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, resolver, zap.NewNop(), "http://baseurl")
+
+	created, err := service.CreateURLRecord(ctx, "https://example.com", "user-1")
+	require.NoError(t, err)
+	_, err = service.CreateURLRecords(ctx, []models.BatchRequest{
+		{CorrelationID: "a", OriginalURL: "https://a.example"},
+		{CorrelationID: "b", OriginalURL: "https://example.com"},
+	}, "user-1")
+	require.NoError(t, err)
+	for range 3 {
+		redirect(t, ctx, service, created.Short)
+	}
+
+	// URLs shortened before are not counted as created
+	stats := service.LiveStats(ctx)
+	assert.Equal(t, 2, stats.CreatedPerMinute)
+	assert.Equal(t, 3, stats.RedirectsPerMinute)
+	assert.Equal(t, 0, stats.DeleteQueueDepth)
+	assert.WithinDuration(t, time.Now(), stats.Time, time.Minute)
+}
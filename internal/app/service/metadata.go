@@ -0,0 +1,58 @@
+// Package service provides the metadata of short URLs, which describes a
+// short URL without following it.
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+)
+
+// GetURLMetadata returns the metadata of the short URL as seen by the user,
+// without resolving it, so no click event is published. The original URL of a
+// password protected URL is shown to its owner only. It returns
+// storage.ErrNotFound if there is no such short URL, or if it is private or
+// its activation window has not started yet and the user does not own it.
+func (s *URLService) GetURLMetadata(ctx context.Context, short string, userID string) (*models.URLMetadata, error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetURLMetadata", tracing.KindInternal)
+	defer span.End()
+
+	r, err := s.repository.FindByShort(ctx, s.resolver.Normalize(short))
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	owned := userID != "" && r.UserID == userID
+	if !owned && (r.Private || r.NotYetActive(time.Now())) {
+		return nil, storage.ErrNotFound
+	}
+	hideExpired(r)
+
+	m := &models.URLMetadata{
+		ShortURL:          s.Tenant(ctx).ShortURL(r.Short),
+		OriginalURL:       r.Original,
+		CreatedAt:         r.CreatedAt,
+		ExpiresAt:         r.ExpiresAt,
+		NotBefore:         r.NotBefore,
+		NotAfter:          r.NotAfter,
+		IsDeleted:         r.IsDeleted,
+		Threat:            r.Flagged,
+		PasswordProtected: r.PasswordHash != "",
+		Owned:             owned,
+	}
+	if owned {
+		m.Tags, m.Pinned, m.CampaignID = r.Tags, r.Pinned, r.Campaign
+		m.Visibility = visibility(r)
+	}
+	if !m.PasswordProtected || owned {
+		m.Title, m.Description = r.Title, r.Description
+	}
+	if m.PasswordProtected && !owned {
+		m.OriginalURL = ""
+	}
+	return m, nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLService_GetURLMetadata(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	created, err := service.CreateURLRecord(ctx, "https://example.com", "owner")
+	require.NoError(t, err)
+
+	m, err := service.GetURLMetadata(ctx, created.Short, "")
+	require.NoError(t, err)
+	assert.Equal(t, "http://baseurl/"+created.Short, m.ShortURL)
+	assert.Equal(t, "https://example.com", m.OriginalURL)
+	require.NotNil(t, m.CreatedAt)
+	assert.WithinDuration(t, time.Now(), *m.CreatedAt, time.Minute)
+	assert.False(t, m.Owned)
+	assert.False(t, m.IsDeleted)
+
+	// The original URL of protected URLs is shown to their owner only
+	require.NoError(t, service.SetURLPassword(ctx, created.Short, "owner", "s3cret"))
+	m, err = service.GetURLMetadata(ctx, created.Short, "other")
+	require.NoError(t, err)
+	assert.True(t, m.PasswordProtected)
+	assert.Empty(t, m.OriginalURL)
+	m, err = service.GetURLMetadata(ctx, created.Short, "owner")
+	require.NoError(t, err)
+	assert.True(t, m.Owned)
+	assert.Equal(t, "https://example.com", m.OriginalURL)
+
+	// URLs that are not active yet are hidden from other users
+	notBefore := time.Now().Add(time.Hour)
+	scheduled, err := service.CreateScheduledURLRecord(ctx, "https://example.com/later", "owner", &notBefore, nil)
+	require.NoError(t, err)
+	_, err = service.GetURLMetadata(ctx, scheduled.Short, "other")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	m, err = service.GetURLMetadata(ctx, scheduled.Short, "owner")
+	require.NoError(t, err)
+	assert.True(t, notBefore.Equal(*m.NotBefore))
+
+	_, err = service.GetURLMetadata(ctx, "unknown", "owner")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
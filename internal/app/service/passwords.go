@@ -0,0 +1,139 @@
+// Package service provides password protection of short URLs, which then
+// redirect only after the password has been given. Client IPs sending too
+// many wrong passwords are refused further attempts for a while, so that
+// passwords cannot be guessed and bcrypt does not burn the CPU for them.
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+)
+
+// maxURLPasswordLen is the longest password bcrypt can hash.
+const maxURLPasswordLen = 72
+
+// MaxPasswordFailures is how many wrong passwords of protected short URLs a
+// client IP may send in a minute before its attempts are refused.
+const MaxPasswordFailures = 10
+
+// ErrInvalidPassword is returned when a URL password is too long to be hashed.
+var ErrInvalidPassword = errors.New("password must be at most 72 bytes long")
+
+// ErrWrongPassword is returned by UnlockURL when the password does not unlock the URL.
+var ErrWrongPassword = errors.New("wrong password")
+
+// ErrTooManyPasswordAttempts is returned by UnlockURL when the client IP sent
+// MaxPasswordFailures wrong passwords in the last minute.
+var ErrTooManyPasswordAttempts = errors.New("too many wrong passwords, retry in a minute")
+
+// SetURLPassword protects the short URL of the user with password, which is
+// stored as a bcrypt hash; an empty password removes the protection. It
+// returns storage.ErrNotFound if the user does not own the short URL and
+// storage.ErrDeleted if it was deleted.
+func (s *URLService) SetURLPassword(ctx context.Context, short string, userID string, password string) error {
+	ctx, span := tracing.Start(ctx, "URLService.SetURLPassword", tracing.KindInternal)
+	defer span.End()
+	short = s.resolver.Normalize(short)
+
+	if len(password) > maxURLPasswordLen {
+		return ErrInvalidPassword
+	}
+
+	var hash string
+	if password != "" {
+		b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		hash = string(b)
+	}
+
+	err := s.repository.SetPassword(ctx, short, userID, hash)
+	span.RecordError(err)
+	if err == nil {
+		audit.AddTargets(ctx, short)
+	}
+	return err
+}
+
+// CheckURLPassword reports whether password unlocks the record. Records
+// without a password are unlocked by any password.
+func CheckURLPassword(r *storage.URLRecord, password string) bool {
+	if r.PasswordHash == "" {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(r.PasswordHash), []byte(password)) == nil
+}
+
+// UnlockURL checks password against the record for the client IP of ctx. It
+// returns ErrWrongPassword if the password does not unlock the record, and
+// ErrTooManyPasswordAttempts, without checking the password, if the client IP
+// sent too many wrong passwords in the last minute.
+func (s *URLService) UnlockURL(ctx context.Context, r *storage.URLRecord, password string) error {
+	if r.PasswordHash == "" {
+		return nil
+	}
+
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	now := time.Now()
+	if s.passwords.blocked(ip, now) {
+		return ErrTooManyPasswordAttempts
+	}
+	if !CheckURLPassword(r, password) {
+		s.passwords.fail(ip, now)
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// passwordGuard counts the wrong passwords sent by every client IP in the last
+// minute. Its zero value is ready to use, and it is safe for concurrent use.
+type passwordGuard struct {
+	mu        sync.Mutex
+	failures  map[string]*rateCounter
+	lastSweep time.Time // When the idle counters were last removed
+}
+
+// blocked reports whether the client IP sent MaxPasswordFailures wrong
+// passwords in the minute up to now.
+func (g *passwordGuard) blocked(ip string, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c, ok := g.failures[ip]
+	return ok && c.total(now) >= MaxPasswordFailures
+}
+
+// fail counts a wrong password sent by the client IP at now, and removes the
+// counters without failures in the last minute, once a minute.
+func (g *passwordGuard) fail(ip string, now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.failures == nil {
+		g.failures = make(map[string]*rateCounter)
+	}
+	if now.Sub(g.lastSweep) >= time.Minute {
+		g.lastSweep = now
+		for k, c := range g.failures {
+			if c.total(now) == 0 {
+				delete(g.failures, k)
+			}
+		}
+	}
+
+	c, ok := g.failures[ip]
+	if !ok {
+		c = &rateCounter{}
+		g.failures[ip] = c
+	}
+	c.add(now, 1)
+}
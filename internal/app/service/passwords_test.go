@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLService_SetURLPassword(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(context.Background(), mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	created, err := service.CreateURLRecord(context.Background(), "https://example.com", "owner")
+	require.NoError(t, err)
+
+	require.NoError(t, service.SetURLPassword(context.Background(), created.Short, "owner", "s3cret"))
+	r, err := service.GetURLByShort(context.Background(), created.Short)
+	require.NoError(t, err)
+	assert.NotEqual(t, "s3cret", r.PasswordHash)
+	assert.True(t, CheckURLPassword(r, "s3cret"))
+	assert.False(t, CheckURLPassword(r, "wrong"))
+
+	// Other users cannot change the password
+	assert.ErrorIs(t, service.SetURLPassword(context.Background(), created.Short, "other", ""), storage.ErrNotFound)
+	assert.ErrorIs(t, service.SetURLPassword(context.Background(), created.Short, "owner", strings.Repeat("x", 73)), ErrInvalidPassword)
+
+	// An empty password removes the protection
+	require.NoError(t, service.SetURLPassword(context.Background(), created.Short, "owner", ""))
+	r, err = service.GetURLByShort(context.Background(), created.Short)
+	require.NoError(t, err)
+	assert.Empty(t, r.PasswordHash)
+	assert.True(t, CheckURLPassword(r, ""))
+}
+
+func TestURLService_UnlockURL(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(context.Background(), mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	created, err := service.CreateURLRecord(context.Background(), "https://example.com", "owner")
+	require.NoError(t, err)
+	require.NoError(t, service.SetURLPassword(context.Background(), created.Short, "owner", "s3cret"))
+	r, err := service.GetURLByShort(context.Background(), created.Short)
+	require.NoError(t, err)
+
+	ctx := WithClientIP(context.Background(), "192.0.2.1")
+	require.NoError(t, service.UnlockURL(ctx, r, "s3cret"))
+
+	// Past the limit of wrong passwords, even the right one is refused
+	for i := 0; i < MaxPasswordFailures; i++ {
+		assert.ErrorIs(t, service.UnlockURL(ctx, r, "wrong"), ErrWrongPassword)
+	}
+	assert.ErrorIs(t, service.UnlockURL(ctx, r, "s3cret"), ErrTooManyPasswordAttempts)
+
+	// Other clients are not refused
+	assert.NoError(t, service.UnlockURL(WithClientIP(context.Background(), "192.0.2.2"), r, "s3cret"))
+
+	// URLs without a password are unlocked by any password
+	assert.NoError(t, service.UnlockURL(ctx, &storage.URLRecord{Short: "open"}, ""))
+}
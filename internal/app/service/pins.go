@@ -0,0 +1,27 @@
+// Package service provides pinned short URLs, which their owner keeps at the
+// top of the listing of their URLs.
+package service
+
+import (
+	"context"
+
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+)
+
+// SetURLPinned pins the short URL of the user, so that listings ordering
+// pinned URLs first show it at the top, or unpins it. It returns
+// storage.ErrNotFound if the user does not own the short URL and
+// storage.ErrDeleted if it was deleted.
+func (s *URLService) SetURLPinned(ctx context.Context, short string, userID string, pinned bool) error {
+	ctx, span := tracing.Start(ctx, "URLService.SetURLPinned", tracing.KindInternal)
+	defer span.End()
+	short = s.resolver.Normalize(short)
+
+	err := s.repository.SetPinned(ctx, short, userID, pinned)
+	span.RecordError(err)
+	if err == nil {
+		audit.AddTargets(ctx, short)
+	}
+	return err
+}
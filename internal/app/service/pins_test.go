@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLService_SetURLPinned(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	_, err := service.CreateURLRecord(ctx, "https://example.com/a", "owner")
+	require.NoError(t, err)
+	last, err := service.CreateURLRecord(ctx, "https://example.com/b", "owner")
+	require.NoError(t, err)
+
+	require.NoError(t, service.SetURLPinned(ctx, last.Short, "owner", true))
+	assert.ErrorIs(t, service.SetURLPinned(ctx, last.Short, "other", false), storage.ErrNotFound)
+
+	// Pinned URLs come first, the others keep the requested order
+	urls, err := service.GetURLByUserID(ctx, "owner", storage.ListOptions{PinnedFirst: true, Sort: storage.SortByOriginal})
+	require.NoError(t, err)
+	require.Len(t, *urls, 2)
+	assert.Equal(t, "https://example.com/b", (*urls)[0].OriginalURL)
+	assert.True(t, (*urls)[0].Pinned)
+	assert.False(t, (*urls)[1].Pinned)
+
+	// Only the owner sees the pin in the metadata
+	m, err := service.GetURLMetadata(ctx, last.Short, "owner")
+	require.NoError(t, err)
+	assert.True(t, m.Pinned)
+	m, err = service.GetURLMetadata(ctx, last.Short, "other")
+	require.NoError(t, err)
+	assert.False(t, m.Pinned)
+
+	require.NoError(t, service.SetURLPinned(ctx, last.Short, "owner", false))
+	urls, err = service.GetURLByUserID(ctx, "owner", storage.ListOptions{PinnedFirst: true, Sort: storage.SortByOriginal})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/a", (*urls)[0].OriginalURL)
+}
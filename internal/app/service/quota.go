@@ -0,0 +1,43 @@
+// Package service provides per-user quotas on the number of active short URLs.
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// QuotaError is returned when creating URLs would exceed the quota of the user.
+type QuotaError struct {
+	Limit     int // Maximum number of active URLs a user may own
+	Used      int // Number of active URLs the user already owns
+	Requested int // Number of URLs the rejected request tried to create
+}
+
+// Error implements the error interface.
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("url quota exceeded: %d of %d used, %d requested", e.Used, e.Limit, e.Requested)
+}
+
+// SetURLQuota limits how many active URLs a user may own; 0 disables the limit.
+// It is safe to call while the service handles requests.
+func (s *URLService) SetURLQuota(limit int) {
+	s.quota.Store(int64(limit))
+}
+
+// checkQuota returns a QuotaError if creating n more URLs would exceed the quota of the user.
+func (s *URLService) checkQuota(ctx context.Context, userID string, n int) error {
+	limit := int(s.quota.Load())
+	if limit <= 0 {
+		return nil
+	}
+
+	used, err := s.repository.CountByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if used+n > limit {
+		return &QuotaError{Limit: limit, Used: used, Requested: n}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLService_Quota(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+
+	service, _ := NewURL(context.Background(), mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+	service.SetURLQuota(2)
+
+	_, err := service.CreateURLRecord(context.Background(), "http://1.example.com", "user-id")
+	require.NoError(t, err)
+
+	// A batch that does not fit is rejected as a whole
+	_, err = service.CreateURLRecords(context.Background(), []models.BatchRequest{
+		{CorrelationID: "a", OriginalURL: "http://2.example.com"},
+		{CorrelationID: "b", OriginalURL: "http://3.example.com"},
+	}, "user-id")
+	var qe *QuotaError
+	require.True(t, errors.As(err, &qe))
+	assert.Equal(t, QuotaError{Limit: 2, Used: 1, Requested: 2}, *qe)
+
+	_, err = service.CreateURLRecord(context.Background(), "http://2.example.com", "user-id")
+	require.NoError(t, err)
+
+	_, err = service.CreateURLRecord(context.Background(), "http://3.example.com", "user-id")
+	require.True(t, errors.As(err, &qe))
+	assert.Equal(t, QuotaError{Limit: 2, Used: 2, Requested: 1}, *qe)
+
+	// Other users have their own quota
+	_, err = service.CreateURLRecord(context.Background(), "http://3.example.com", "other-user-id")
+	require.NoError(t, err)
+}
+
+func TestURLService_NoQuota(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+
+	service, _ := NewURL(context.Background(), mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	for _, long := range []string{"http://1.example.com", "http://2.example.com", "http://3.example.com"} {
+		_, err := service.CreateURLRecord(context.Background(), long, "user-id")
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package service provides the global statistics of the service, optionally
+// with the daily changes of a range of days for dashboards. Counting every URL
+// is expensive on large storages, so the totals can be cached and recomputed
+// in the background.
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+// statsCacheJobName is the name the job refreshing the cached totals is registered under.
+const statsCacheJobName = "stats_cache"
+
+// statsSnapshot is a set of totals computed at some point in time.
+type statsSnapshot struct {
+	urls, users int
+	computedAt  time.Time
+}
+
+// MaxStatsDays is the longest range of days GetStats returns daily changes for.
+const MaxStatsDays = 366
+
+// ErrInvalidStatsRange is returned by GetStats for ranges ending before they
+// start or longer than MaxStatsDays.
+var ErrInvalidStatsRange = errors.New("invalid stats range: from must not be after to, and the range must not exceed 366 days")
+
+// StatsOptions selects the days GetStats returns daily changes for.
+// The zero value selects none.
+type StatsOptions struct {
+	From time.Time // First day of the range, in UTC; zero for no daily changes
+	To   time.Time // Last day of the range, in UTC, included
+}
+
+// days returns the days of the range, oldest first.
+func (o StatsOptions) days() ([]time.Time, error) {
+	from, to := storage.Day(o.From), storage.Day(o.To)
+	if to.Before(from) || to.Sub(from) >= MaxStatsDays*24*time.Hour {
+		return nil, ErrInvalidStatsRange
+	}
+
+	days := make([]time.Time, 0, int(to.Sub(from)/(24*time.Hour))+1)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// GetStats returns the number of shortened URLs and of users in the service,
+// with the time they were computed at, which is in the past if they come from
+// the cache enabled by SetStatsCache, and the counters of the background jobs
+// of this instance. If opts has a range, the number of URLs created and deleted on each of its
+// days is included too, with days without changes reported as zero. It
+// returns ErrInvalidStatsRange if the range is invalid.
+func (s *URLService) GetStats(ctx context.Context, opts StatsOptions) (*models.Stats, error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetStats", tracing.KindInternal)
+	defer span.End()
+
+	var days []time.Time
+	if !opts.From.IsZero() {
+		var err error
+		if days, err = opts.days(); err != nil {
+			return nil, err
+		}
+	}
+
+	totals, err := s.statsTotals(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	stats := &models.Stats{
+		URLs:       totals.urls,
+		Users:      totals.users,
+		ComputedAt: totals.computedAt,
+		CacheAge:   int(time.Since(totals.computedAt) / time.Second),
+		Jobs:       make(map[string]models.JobMetrics),
+	}
+	for name, m := range s.jobs.Metrics() {
+		stats.Jobs[name] = models.JobMetrics{Runs: m.Runs, Retries: m.Retries, Failures: m.Failures, DeadLettered: m.DeadLettered}
+	}
+	if days == nil {
+		return stats, nil
+	}
+
+	changes, err := s.repository.GetDailyStats(ctx, days[0], days[len(days)-1].AddDate(0, 0, 1))
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	byDay := make(map[time.Time]storage.DailyStats, len(changes))
+	for _, c := range changes {
+		byDay[storage.Day(c.Day)] = c
+	}
+
+	stats.Daily = make([]models.DailyStats, 0, len(days))
+	for _, day := range days {
+		c := byDay[day]
+		stats.Daily = append(stats.Daily, models.DailyStats{Date: day.Format(time.DateOnly), Created: c.Created, Deleted: c.Deleted})
+	}
+	return stats, nil
+}
+
+// SetStatsCache makes GetStats return totals cached for up to refresh,
+// recomputed in the background every refresh; 0 computes them on every call.
+// Totals that could not be refreshed for twice as long are computed again on
+// the next call. It must be called before the service starts handling requests.
+func (s *URLService) SetStatsCache(refresh time.Duration) error {
+	if refresh <= 0 {
+		return nil
+	}
+	job := worker.NewPeriodicJob(s.logger, statsCacheJobName, refresh, worker.RetryPolicy{}, func(ctx context.Context) error {
+		_, err := s.refreshStats(ctx)
+		return err
+	})
+	if err := s.jobs.Register(statsCacheJobName, job); err != nil {
+		return err
+	}
+	s.statsRefresh = refresh
+	return nil
+}
+
+// statsTotals returns the cached totals, computing them if the cache is
+// disabled, empty or stale.
+func (s *URLService) statsTotals(ctx context.Context) (*statsSnapshot, error) {
+	if s.statsRefresh > 0 {
+		if cached := s.stats.Load(); cached != nil && time.Since(cached.computedAt) < 2*s.statsRefresh {
+			return cached, nil
+		}
+	}
+	return s.refreshStats(ctx)
+}
+
+// refreshStats computes the totals and caches them.
+func (s *URLService) refreshStats(ctx context.Context) (*statsSnapshot, error) {
+	urls, users, err := s.repository.GetStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &statsSnapshot{urls: urls, users: users, computedAt: time.Now().UTC()}
+	s.stats.Store(snapshot)
+	return snapshot, nil
+}
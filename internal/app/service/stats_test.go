@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLService_GetStatsDaily(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	today := storage.Day(time.Now())
+	twoDaysAgo := today.AddDate(0, 0, -2)
+	require.NoError(t, mockStorage.WriteAll(ctx, []storage.URLRecord{
+		{Original: "http://a.example.com", Short: "aaa", UserID: "user-1", CreatedAt: &twoDaysAgo},
+		{Original: "http://b.example.com", Short: "bbb", UserID: "user-2", CreatedAt: &today},
+	}))
+
+	// Days without changes are reported too
+	stats, err := service.GetStats(ctx, StatsOptions{From: twoDaysAgo, To: today})
+	require.NoError(t, err)
+	assert.Equal(t, []models.DailyStats{
+		{Date: twoDaysAgo.Format(time.DateOnly), Created: 1},
+		{Date: today.AddDate(0, 0, -1).Format(time.DateOnly)},
+		{Date: today.Format(time.DateOnly), Created: 1},
+	}, stats.Daily)
+	assert.Equal(t, 2, stats.URLs)
+
+	// Without a range, only the totals are returned
+	stats, err = service.GetStats(ctx, StatsOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, stats.Daily)
+	assert.Contains(t, stats.Jobs, deleteJobName)
+
+	_, err = service.GetStats(ctx, StatsOptions{From: today, To: twoDaysAgo})
+	assert.ErrorIs(t, err, ErrInvalidStatsRange)
+	_, err = service.GetStats(ctx, StatsOptions{From: today.AddDate(0, 0, -MaxStatsDays), To: today})
+	assert.ErrorIs(t, err, ErrInvalidStatsRange)
+	_, err = service.GetStats(ctx, StatsOptions{From: today.AddDate(0, 0, -MaxStatsDays+1), To: today})
+	assert.NoError(t, err)
+}
+
+func TestURLService_GetStatsCache(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	// Without the cache, every call counts the URLs
+	require.NoError(t, mockStorage.WriteAll(ctx, []storage.URLRecord{storage.URLRecord{Original: "http://a.example.com", Short: "aaa", UserID: "user-1"}}))
+	stats, err := service.GetStats(ctx, StatsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.URLs)
+	assert.Zero(t, stats.CacheAge)
+	assert.WithinDuration(t, time.Now(), stats.ComputedAt, time.Minute)
+
+	require.NoError(t, service.SetStatsCache(time.Hour))
+	stats, err = service.GetStats(ctx, StatsOptions{})
+	require.NoError(t, err)
+	computedAt := stats.ComputedAt
+
+	// Cached totals are returned until they are refreshed
+	require.NoError(t, mockStorage.WriteAll(ctx, []storage.URLRecord{storage.URLRecord{Original: "http://b.example.com", Short: "bbb", UserID: "user-2"}}))
+	stats, err = service.GetStats(ctx, StatsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.URLs)
+	assert.Equal(t, computedAt, stats.ComputedAt)
+
+	_, err = service.refreshStats(ctx)
+	require.NoError(t, err)
+	stats, err = service.GetStats(ctx, StatsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.URLs)
+	assert.Equal(t, 2, stats.Users)
+
+	// Totals older than twice the refresh interval are stale
+	service.stats.Store(&statsSnapshot{urls: 7, computedAt: time.Now().Add(-90 * time.Minute)})
+	stats, err = service.GetStats(ctx, StatsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 7, stats.URLs)
+	assert.Equal(t, 90*60, stats.CacheAge)
+	service.stats.Store(&statsSnapshot{urls: 7, computedAt: time.Now().Add(-3 * time.Hour)})
+	stats, err = service.GetStats(ctx, StatsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.URLs)
+
+	assert.Error(t, service.SetStatsCache(time.Hour), "the refresh job is registered once")
+}
@@ -0,0 +1,97 @@
+// Package service provides tags of short URLs, which their owner attaches at
+// creation or later to filter the listing of their URLs by.
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+)
+
+const (
+	// maxTags is the number of tags a short URL may have.
+	maxTags = 10
+	// maxTagLen is the length of the longest tag, in characters.
+	maxTagLen = 32
+)
+
+// ErrInvalidTags is returned when tags are too many, too long or contain
+// characters other than letters, digits, '-', '_' and '.'.
+var ErrInvalidTags = errors.New("at most 10 tags of up to 32 letters, digits, '-', '_' or '.' are allowed")
+
+// NormalizeTag returns the tag trimmed and in lower case, the form tags are
+// stored and matched in.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags returns the tags normalized by NormalizeTag, in their order
+// and without repeated or empty ones, or ErrInvalidTags if one of them is
+// invalid or there are more than maxTags.
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	res := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if !validTag(tag) {
+			return nil, ErrInvalidTags
+		}
+		seen[tag] = true
+		res = append(res, tag)
+	}
+	if len(res) > maxTags {
+		return nil, ErrInvalidTags
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res, nil
+}
+
+// validTag reports whether the normalized tag is short enough and made of
+// allowed characters only.
+func validTag(tag string) bool {
+	n := 0
+	for _, c := range tag {
+		n++
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '-' && c != '_' && c != '.' {
+			return false
+		}
+	}
+	return n <= maxTagLen
+}
+
+// SetURLTags replaces the tags of the short URL of the user; an empty list
+// removes them. Tags are normalized by NormalizeTag and repeated ones are
+// dropped. It returns the stored tags, or ErrInvalidTags if the tags are not
+// allowed, storage.ErrNotFound if the user does not own the short URL and
+// storage.ErrDeleted if it was deleted.
+func (s *URLService) SetURLTags(ctx context.Context, short string, userID string, tags []string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "URLService.SetURLTags", tracing.KindInternal)
+	defer span.End()
+	short = s.resolver.Normalize(short)
+
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repository.SetTags(ctx, short, userID, tags)
+	span.RecordError(err)
+	if err != nil {
+		return nil, err
+	}
+	audit.AddTargets(ctx, short)
+	return tags, nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := normalizeTags([]string{" Work ", "docs", "work", "", "v1.2_beta-3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"work", "docs", "v1.2_beta-3"}, tags)
+
+	tags, err = normalizeTags([]string{" ", ""})
+	require.NoError(t, err)
+	assert.Nil(t, tags)
+
+	_, err = normalizeTags([]string{"two words"})
+	assert.ErrorIs(t, err, ErrInvalidTags)
+	_, err = normalizeTags([]string{strings.Repeat("x", maxTagLen+1)})
+	assert.ErrorIs(t, err, ErrInvalidTags)
+	_, err = normalizeTags(strings.Fields("a b c d e f g h i j k"))
+	assert.ErrorIs(t, err, ErrInvalidTags)
+
+	// Tags are limited in characters, not bytes
+	tags, err = normalizeTags([]string{strings.Repeat("ж", maxTagLen)})
+	require.NoError(t, err)
+	assert.Len(t, tags, 1)
+}
+
+func TestURLService_Tags(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	tagged, err := service.CreateURLRecordWithOptions(ctx, "https://example.com/a", "owner", CreateOptions{Tags: []string{"Work"}})
+	require.NoError(t, err)
+	plain, err := service.CreateURLRecord(ctx, "https://example.com/b", "owner")
+	require.NoError(t, err)
+	_, err = service.CreateURLRecordWithOptions(ctx, "https://example.com/c", "owner", CreateOptions{Tags: []string{"not valid"}})
+	assert.ErrorIs(t, err, ErrInvalidTags)
+
+	// The listing is filtered by the tag, whatever its case
+	urls, err := service.GetURLByUserID(ctx, "owner", storage.ListOptions{Tag: "WORK"})
+	require.NoError(t, err)
+	assert.Equal(t, []models.ByIDRequest{{OriginalURL: "https://example.com/a", ShortURL: "http://baseurl/" + tagged.Short, Tags: []string{"work"}}}, *urls)
+
+	stored, err := service.SetURLTags(ctx, plain.Short, "owner", []string{"docs", "Work"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docs", "work"}, stored)
+	urls, err = service.GetURLByUserID(ctx, "owner", storage.ListOptions{Tag: "work", Sort: storage.SortByOriginal})
+	require.NoError(t, err)
+	assert.Len(t, *urls, 2)
+
+	// Only the owner sees and changes the tags
+	m, err := service.GetURLMetadata(ctx, plain.Short, "owner")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docs", "work"}, m.Tags)
+	m, err = service.GetURLMetadata(ctx, plain.Short, "other")
+	require.NoError(t, err)
+	assert.Empty(t, m.Tags)
+	_, err = service.SetURLTags(ctx, plain.Short, "other", nil)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// An empty list removes the tags
+	stored, err = service.SetURLTags(ctx, plain.Short, "owner", nil)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+	urls, err = service.GetURLByUserID(ctx, "owner", storage.ListOptions{Tag: "docs"})
+	require.NoError(t, err)
+	assert.Empty(t, *urls)
+}
@@ -0,0 +1,142 @@
+// Package service provides tenants: the domains the service is reachable on,
+// each with its own base URL of short URLs, redirect status code and branding
+// of the pages shown instead of a redirect.
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultRedirectCode is the status code of redirects when none is configured.
+const DefaultRedirectCode = http.StatusTemporaryRedirect
+
+// Branding customizes the pages shown instead of a redirect.
+type Branding struct {
+	Name    string // Name shown in the page header
+	LogoURL string // URL of a logo shown in the page header
+	Color   string // CSS accent color of the pages
+}
+
+// Tenant is a domain the service is reachable on.
+type Tenant struct {
+	Host         string   // Host name requests to the tenant are sent to, empty for the default tenant
+	BaseURL      string   // Base URL of the short URLs of the tenant
+	RedirectCode int      // Status code of redirects to the original URLs
+	Branding     Branding // Branding of the pages shown instead of a redirect
+}
+
+// ShortURL returns the full short URL of the short URL identifier.
+func (t Tenant) ShortURL(short string) string {
+	return t.BaseURL + "/" + short
+}
+
+// Tenants resolves the tenant of a request from its host.
+type Tenants struct {
+	fallback Tenant            // Tenant of requests to unknown hosts
+	byHost   map[string]Tenant // Tenants by normalized host
+}
+
+// NewTenants returns the tenants of the given hosts, in front of fallback,
+// the tenant of requests to any other host. Tenants without a base URL get
+// one made of the scheme of the fallback base URL and their host; tenants
+// without a redirect code or branding share the ones of fallback.
+func NewTenants(fallback Tenant, tenants []Tenant) (*Tenants, error) {
+	if fallback.RedirectCode == 0 {
+		fallback.RedirectCode = DefaultRedirectCode
+	}
+	if err := checkRedirectCode(fallback.RedirectCode); err != nil {
+		return nil, err
+	}
+	fallback.Host = ""
+	fallback.BaseURL = strings.TrimSuffix(fallback.BaseURL, "/")
+
+	scheme := "http"
+	if u, err := url.Parse(fallback.BaseURL); err == nil && u.Scheme != "" {
+		scheme = u.Scheme
+	}
+
+	t := &Tenants{fallback: fallback, byHost: make(map[string]Tenant, len(tenants))}
+	for _, tenant := range tenants {
+		tenant.Host = normalizeHost(tenant.Host)
+		if tenant.Host == "" {
+			return nil, fmt.Errorf("tenant without a host")
+		}
+
+		if tenant.BaseURL == "" {
+			tenant.BaseURL = scheme + "://" + tenant.Host
+		}
+		tenant.BaseURL = strings.TrimSuffix(tenant.BaseURL, "/")
+		if u, err := url.Parse(tenant.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("tenant %s: invalid base URL %q", tenant.Host, tenant.BaseURL)
+		}
+
+		if tenant.RedirectCode == 0 {
+			tenant.RedirectCode = fallback.RedirectCode
+		}
+		if err := checkRedirectCode(tenant.RedirectCode); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Host, err)
+		}
+
+		if tenant.Branding == (Branding{}) {
+			tenant.Branding = fallback.Branding
+		}
+		t.byHost[tenant.Host] = tenant
+	}
+	return t, nil
+}
+
+// Resolve returns the tenant of requests to host, which may include a port.
+// Hosts are matched with their port first, then without it.
+func (t *Tenants) Resolve(host string) Tenant {
+	host = normalizeHost(host)
+	if tenant, ok := t.byHost[host]; ok {
+		return tenant
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		if tenant, ok := t.byHost[h]; ok {
+			return tenant
+		}
+	}
+	return t.fallback
+}
+
+// checkRedirectCode returns an error unless code is a redirect status code.
+func checkRedirectCode(code int) error {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return nil
+	}
+	return fmt.Errorf("invalid redirect code %d: want 301, 302, 303, 307 or 308", code)
+}
+
+// normalizeHost lower-cases host and strips a trailing dot.
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// hostKey is the context key of the request host.
+type hostKey struct{}
+
+// WithHost returns a copy of ctx carrying the host the request was sent to,
+// which the tenant of the request is resolved from.
+func WithHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, hostKey{}, host)
+}
+
+// SetTenants replaces the tenants of the service.
+// It is safe to call while the service handles requests.
+func (s *URLService) SetTenants(t *Tenants) {
+	s.tenants.Store(t)
+}
+
+// Tenant returns the tenant of the request of ctx, resolved from the host set
+// by WithHost; requests without a host get the default tenant.
+func (s *URLService) Tenant(ctx context.Context) Tenant {
+	host, _ := ctx.Value(hostKey{}).(string)
+	return s.tenants.Load().Resolve(host)
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestNewTenants(t *testing.T) {
+	brand := Branding{Name: "Shortener"}
+	tenants, err := NewTenants(Tenant{BaseURL: "https://short.example/", Branding: brand}, []Tenant{
+		{Host: "Go.Example.com.", RedirectCode: http.StatusMovedPermanently, Branding: Branding{Name: "Example", Color: "#0a7"}},
+		{Host: "links.example.org:8443", BaseURL: "https://links.example.org:8443/l"},
+	})
+	require.NoError(t, err)
+
+	fallback := tenants.Resolve("unknown.example")
+	assert.Equal(t, Tenant{BaseURL: "https://short.example", RedirectCode: DefaultRedirectCode, Branding: brand}, fallback)
+	assert.Equal(t, fallback, tenants.Resolve(""))
+
+	// Hosts match case-insensitively, with or without a port
+	goTenant := tenants.Resolve("go.example.com:443")
+	assert.Equal(t, "https://go.example.com", goTenant.BaseURL)
+	assert.Equal(t, http.StatusMovedPermanently, goTenant.RedirectCode)
+	assert.Equal(t, "Example", goTenant.Branding.Name)
+	assert.Equal(t, "https://go.example.com/abc", goTenant.ShortURL("abc"))
+
+	links := tenants.Resolve("LINKS.example.org:8443")
+	assert.Equal(t, "https://links.example.org:8443/l/abc", links.ShortURL("abc"))
+	assert.Equal(t, DefaultRedirectCode, links.RedirectCode)
+	assert.Equal(t, brand, links.Branding)
+	assert.Equal(t, fallback, tenants.Resolve("links.example.org"))
+}
+
+func TestNewTenants_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		fallback Tenant
+		tenants  []Tenant
+	}{
+		"fallback redirect code": {fallback: Tenant{RedirectCode: http.StatusOK}},
+		"tenant redirect code":   {tenants: []Tenant{{Host: "go.example.com", RedirectCode: http.StatusNotModified}}},
+		"tenant without host":    {tenants: []Tenant{{BaseURL: "https://go.example.com"}}},
+		"tenant base URL":        {tenants: []Tenant{{Host: "go.example.com", BaseURL: "go.example.com"}}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewTenants(tt.fallback, tt.tenants)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestURLService_Tenants(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(context.Background(), mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	// Without tenants every request gets the base URL of the service
+	assert.Equal(t, Tenant{BaseURL: "http://baseurl", RedirectCode: DefaultRedirectCode}, service.Tenant(WithHost(context.Background(), "go.example.com")))
+
+	tenants, err := NewTenants(Tenant{BaseURL: "http://baseurl"}, []Tenant{{Host: "go.example.com", BaseURL: "https://go.example.com"}})
+	require.NoError(t, err)
+	service.SetTenants(tenants)
+
+	ctx := WithHost(context.Background(), "go.example.com")
+	batch, err := service.CreateURLRecords(ctx, []models.BatchRequest{{CorrelationID: "a", OriginalURL: "https://example.com/page"}}, "user-id")
+	require.NoError(t, err)
+	require.Len(t, *batch, 1)
+	assert.Regexp(t, `^https://go\.example\.com/\w+$`, (*batch)[0].ShortURL)
+
+	urls, err := service.GetURLByUserID(context.Background(), "user-id", storage.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, *urls, 1)
+	assert.Regexp(t, `^http://baseurl/\w+$`, (*urls)[0].ShortURL)
+}
@@ -0,0 +1,42 @@
+// Package service provides checking of newly created URLs against threat
+// intelligence sources, such as Google Safe Browsing.
+package service
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+// threatScannerJobName is the name the threat scanner is registered under.
+const threatScannerJobName = "threat_scanner"
+
+// SetThreatScanner registers sc with the background jobs and makes the service
+// queue every created URL to it. URLs the scanner finds to be malicious are
+// flagged and no longer redirected to. It must be called before the service
+// starts handling requests.
+func (s *URLService) SetThreatScanner(sc *worker.ThreatScanner) error {
+	if err := s.jobs.Register(threatScannerJobName, sc); err != nil {
+		return err
+	}
+	s.scanner = sc
+	return nil
+}
+
+// scanThreats queues the created records to the threat scanner. Scanning is
+// best effort: records that cannot be queued are logged and stay active.
+func (s *URLService) scanThreats(ctx context.Context, records ...storage.URLRecord) {
+	if s.scanner == nil {
+		return
+	}
+	for _, r := range records {
+		if err := s.scanner.Submit(r); err != nil {
+			logger.FromContext(ctx, s.logger).Warn("cannot queue URL for threat check",
+				zap.Error(err), zap.String("short_url", r.Short))
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+// threatFeed is a worker.ThreatChecker over a fixed map of URLs to threats.
+type threatFeed map[string]string
+
+func (f threatFeed) Check(_ context.Context, url string) (string, error) {
+	return f[url], nil
+}
+
+func TestURLService_ThreatScanner(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+
+	service, shutdown := NewURL(context.Background(), mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+	scanner := worker.NewThreatScanner(zap.NewNop(), threatFeed{"https://evil.example": "MALWARE"}, mockStorage, worker.ScanOptions{})
+	require.NoError(t, service.SetThreatScanner(scanner))
+
+	bad, err := service.CreateURLRecord(context.Background(), "https://evil.example", "user-id")
+	require.NoError(t, err)
+	_, err = service.CreateURLRecords(context.Background(), []models.BatchRequest{
+		{CorrelationID: "a", OriginalURL: "https://good.example"},
+	}, "user-id")
+	require.NoError(t, err)
+
+	// Both records are checked in the background
+	require.Eventually(t, func() bool { return scanner.Metrics().Runs == 2 }, time.Second, time.Millisecond)
+	shutdown()
+
+	r, err := service.GetURLByShort(context.Background(), bad.Short)
+	require.NoError(t, err)
+	assert.Equal(t, "MALWARE", r.Flagged)
+
+	r, err = service.GetURLByShort(context.Background(), mockResolver.LongToShort("https://good.example"))
+	require.NoError(t, err)
+	assert.Empty(t, r.Flagged)
+}
@@ -5,70 +5,170 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
 )
 
+// Alphabets of the short links generated by URLResolver, set by ResolverOptions.Alphabet.
+const (
+	// AlphabetBase62 is made of digits and lowercase and uppercase letters.
+	AlphabetBase62 = "base62"
+	// AlphabetHumanSafe leaves out the characters that are easily confused
+	// with others when printed, such as 0/O/o and 1/l/I, for links printed on
+	// posters or receipts.
+	AlphabetHumanSafe = "human-safe"
+)
+
+// Elements of the alphabets, by alphabet and case sensitivity.
+const (
+	// base62Elements are the elements of AlphabetBase62 (0-9, a-z, A-Z).
+	base62Elements = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// base36Elements are the elements of case-insensitive AlphabetBase62 (0-9, a-z).
+	base36Elements = "0123456789abcdefghijklmnopqrstuvwxyz"
+	// humanSafeElements are the elements of AlphabetHumanSafe.
+	humanSafeElements = "23456789abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+	// humanSafeLowerElements are the elements of case-insensitive
+	// AlphabetHumanSafe, which also leaves out i, read as I or l once uppercased.
+	humanSafeLowerElements = "23456789abcdefghjkmnpqrstuvwxyz"
+)
+
+// ResolverOptions configures the short links generated by a URLResolver.
+type ResolverOptions struct {
+	// Alphabet is the alphabet of the short links, AlphabetBase62 when empty.
+	Alphabet string
+
+	// CaseInsensitive generates short links from digits and lowercase letters
+	// only, and resolves short links regardless of their case, for links that
+	// are read aloud or typed by humans. Short links with uppercase letters
+	// stored before it was set can no longer be resolved.
+	CaseInsensitive bool
+}
+
 // URLResolver is a service that handles URL shortening and resolution.
 // It generates short links using a hashing mechanism and stores them in a storage backend.
 type URLResolver struct {
 	storage           Storage // Storage backend for URL resolution.
 	numCharsShortLink int     // The desired length of the shortened URL.
-	elements          string  // Base62 encoding elements (0-9, a-z, A-Z).
+	elements          string  // Encoding elements, Base62 (0-9, a-z, A-Z) by default.
+	width             int     // Number of elements of an encoded SHA-256 hash.
+	caseInsensitive   bool    // Whether short links are resolved regardless of their case.
 }
 
 // NewURLResolver creates a new URLResolver instance with the given number of characters for the short link.
-// It initializes the resolver with the provided storage backend.
+// It initializes the resolver with the provided storage backend. The number
+// of characters must be between 1 and 43, the length of a Base62 encoded SHA-256 hash.
 func NewURLResolver(numChars int, storage Storage) (*URLResolver, error) {
+	return NewURLResolverWithOptions(numChars, storage, ResolverOptions{})
+}
+
+// NewURLResolverWithOptions is like NewURLResolver but generates the short
+// links according to opts. The number of characters must not exceed the
+// length of a SHA-256 hash encoded in the alphabet of opts.
+func NewURLResolverWithOptions(numChars int, storage Storage, opts ResolverOptions) (*URLResolver, error) {
+	var elements string
+	switch opts.Alphabet {
+	case "", AlphabetBase62:
+		elements = base62Elements
+		if opts.CaseInsensitive {
+			elements = base36Elements
+		}
+	case AlphabetHumanSafe:
+		elements = humanSafeElements
+		if opts.CaseInsensitive {
+			elements = humanSafeLowerElements
+		}
+	default:
+		return nil, fmt.Errorf("unknown short link alphabet %q", opts.Alphabet)
+	}
+
+	width := encodedWidth(len(elements))
+	if numChars < 1 || numChars > width {
+		return nil, fmt.Errorf("short link length must be between 1 and %d, got %d", width, numChars)
+	}
 	return &URLResolver{
 		storage:           storage,
 		numCharsShortLink: numChars,
-		elements:          "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		elements:          elements,
+		width:             width,
+		caseInsensitive:   opts.CaseInsensitive,
 	}, nil
 }
 
-// hashToShort generates a short URL by hashing the original URL with SHA-256 and then encoding it in Base62.
-// It truncates the result to the specified length of the short URL.
+// encodedWidth returns the number of digits of the largest SHA-256 hash in the given base.
+func encodedWidth(base int) int {
+	largest := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), sha256.Size*8), big.NewInt(1))
+	b := big.NewInt(int64(base))
+
+	width := 0
+	for largest.Sign() > 0 {
+		largest.Quo(largest, b)
+		width++
+	}
+	return width
+}
+
+// Normalize returns the form short links are stored in: with case-insensitive
+// short links, the lowercase form of short; otherwise short itself.
+func (u *URLResolver) Normalize(short string) string {
+	if u != nil && u.caseInsensitive {
+		return strings.ToLower(short)
+	}
+	return short
+}
+
+// hashToShort generates a short URL by hashing the original URL with SHA-256 and then encoding it in the alphabet.
+// It keeps the least significant digits, as many as the length of the short URL.
 func (u *URLResolver) hashToShort(url string) string {
 	// Hash the URL using SHA-256
 	hash := sha256.Sum256([]byte(url))
 	hexHash := hex.EncodeToString(hash[:])
 
-	// Base62 encode the hash
-	base62 := u.base16ToBase62(hexHash)
+	// Encode the hash in the alphabet
+	encoded := u.encode(hexHash)
 
-	// Truncate to the desired length
-	shortURL := base62[:u.numCharsShortLink]
+	// Keep the hash modulo base^length; the leading digits of the padded hash
+	// only take a few values, as 2^256 is not a power of the base
+	shortURL := encoded[len(encoded)-u.numCharsShortLink:]
 	return shortURL
 }
 
-// base16ToBase62 converts a hexadecimal string to a Base62 encoded string.
-func (u *URLResolver) base16ToBase62(hexString string) string {
-	var value uint64
-	for _, char := range hexString {
-		if char >= '0' && char <= '9' {
-			value = value*16 + uint64(char-'0')
-		} else if char >= 'a' && char <= 'f' {
-			value = value*16 + uint64(char-'a'+10)
-		}
+// encode converts a hexadecimal string to a string encoded in the alphabet.
+// The whole value is converted, so every bit of the hash contributes to the
+// digits, and the result is left-padded with zeros to the number of digits of
+// a SHA-256 hash, so that taking its last digits never runs short.
+func (u *URLResolver) encode(hexString string) string {
+	value, ok := new(big.Int).SetString(hexString, 16)
+	if !ok {
+		return strings.Repeat(u.elements[:1], u.width)
 	}
 
-	// Convert to Base62
-	var sb []rune
-	for value > 0 {
-		sb = append([]rune{rune(u.elements[value%62])}, sb...)
-		value /= 62
+	base := big.NewInt(int64(len(u.elements)))
+	digit := new(big.Int)
+	digits := make([]byte, 0, u.width)
+	for value.Sign() > 0 {
+		value.DivMod(value, base, digit)
+		digits = append(digits, u.elements[digit.Int64()])
+	}
+	for len(digits) < u.width {
+		digits = append(digits, u.elements[0])
 	}
 
-	return string(sb)
+	// The digits were produced least significant first
+	for i, j := 0, len(digits)-1; i < j; i, j = i+1, j-1 {
+		digits[i], digits[j] = digits[j], digits[i]
+	}
+	return string(digits)
 }
 
-// LongToShort converts a long URL to a shortened URL by hashing it and encoding the hash in Base62.
+// LongToShort converts a long URL to a shortened URL by hashing it and encoding the hash in the alphabet.
 func (u *URLResolver) LongToShort(url string) string {
 	return u.hashToShort(url)
 }
 
 // ShortToLong resolves a shortened URL to its original form by querying the storage backend.
 func (u *URLResolver) ShortToLong(ctx context.Context, short string) (string, error) {
-	r, err := u.storage.FindByShort(ctx, short)
+	r, err := u.storage.FindByShort(ctx, u.Normalize(short))
 
 	return r.Original, err
 }
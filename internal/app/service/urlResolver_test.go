@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLResolver_Encode(t *testing.T) {
+	u, err := NewURLResolver(8, nil)
+	require.NoError(t, err)
+
+	// Values beyond 64 bits are converted in full and padded to a fixed width
+	assert.Equal(t, strings.Repeat("0", 41)+"10", u.encode("3e"))
+	assert.Equal(t, strings.Repeat("0", 43), u.encode("0"))
+	assert.Equal(t, strings.Repeat("0", 21)+"7N42dgm5tFLK9N8MT7fHC7", u.encode(strings.Repeat("f", 32)))
+	max := u.encode(strings.Repeat("f", 64))
+	assert.Len(t, max, 43)
+	assert.NotEqual(t, "0", max[:1])
+}
+
+func TestURLResolver_LongToShort(t *testing.T) {
+	s, _ := storage.CreateMemoryStorage()
+
+	_, err := NewURLResolver(0, s)
+	assert.Error(t, err)
+	_, err = NewURLResolver(44, s)
+	assert.Error(t, err)
+
+	u, err := NewURLResolver(43, s)
+	require.NoError(t, err)
+	short := u.LongToShort("https://example.com")
+	assert.Len(t, short, 43)
+	assert.Equal(t, short, u.LongToShort("https://example.com"))
+
+	// URLs whose hashes end alike still get different short URLs, which the
+	// conversion into 64 bits used to collapse
+	u, err = NewURLResolver(8, s)
+	require.NoError(t, err)
+	last8 := func(hexHash string) string {
+		encoded := u.encode(hexHash)
+		return encoded[len(encoded)-8:]
+	}
+	assert.NotEqual(t, last8("1"+strings.Repeat("0", 63)), last8("2"+strings.Repeat("0", 63)))
+}
+
+// firstChars returns the distinct first characters of the short URLs of n URLs.
+func firstChars(u *URLResolver, n int) map[byte]bool {
+	seen := make(map[byte]bool)
+	for i := 0; i < n; i++ {
+		seen[u.LongToShort(fmt.Sprintf("https://example.com/%d", i))[0]] = true
+	}
+	return seen
+}
+
+func TestURLResolver_CaseInsensitive(t *testing.T) {
+	s, _ := storage.CreateMemoryStorage()
+
+	u, err := NewURLResolverWithOptions(50, s, ResolverOptions{CaseInsensitive: true})
+	require.NoError(t, err)
+	_, err = NewURLResolverWithOptions(51, s, ResolverOptions{CaseInsensitive: true})
+	assert.Error(t, err)
+
+	// Short links are made of digits and lowercase letters only
+	short := u.LongToShort("https://example.com")
+	assert.Len(t, short, 50)
+	assert.Equal(t, strings.ToLower(short), short)
+	assert.Equal(t, "abc123", u.Normalize("AbC123"))
+
+	// Every character can start a short link, not only the few leading digits
+	// of a padded hash
+	u, err = NewURLResolverWithOptions(8, s, ResolverOptions{CaseInsensitive: true})
+	require.NoError(t, err)
+	assert.Len(t, firstChars(u, 2000), len(base36Elements))
+
+	// The default resolver keeps the case
+	u, err = NewURLResolver(8, s)
+	require.NoError(t, err)
+	assert.Equal(t, "AbC123", u.Normalize("AbC123"))
+}
+
+func TestURLResolver_HumanSafeAlphabet(t *testing.T) {
+	s, _ := storage.CreateMemoryStorage()
+
+	_, err := NewURLResolverWithOptions(8, s, ResolverOptions{Alphabet: "emoji"})
+	assert.Error(t, err)
+
+	tests := []struct {
+		name          string
+		opts          ResolverOptions
+		wantElements  string
+		confusables   string
+		wantMaxLength int
+	}{
+		{name: "mixed case", opts: ResolverOptions{Alphabet: AlphabetHumanSafe}, wantElements: humanSafeElements, confusables: "0O1lIo", wantMaxLength: 45},
+		{name: "case insensitive", opts: ResolverOptions{Alphabet: AlphabetHumanSafe, CaseInsensitive: true}, wantElements: humanSafeLowerElements, confusables: "0o1liOLI", wantMaxLength: 52},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := NewURLResolverWithOptions(tt.wantMaxLength, s, tt.opts)
+			require.NoError(t, err)
+			_, err = NewURLResolverWithOptions(tt.wantMaxLength+1, s, tt.opts)
+			assert.Error(t, err)
+
+			assert.Equal(t, tt.wantElements, u.elements)
+			assert.False(t, strings.ContainsAny(u.elements, tt.confusables))
+			for i := 0; i < 100; i++ {
+				short := u.LongToShort("https://example.com/" + strings.Repeat("x", i))
+				assert.False(t, strings.ContainsAny(short, tt.confusables), short)
+			}
+
+			// Every element can start a short link of any length
+			u, err = NewURLResolverWithOptions(8, s, tt.opts)
+			require.NoError(t, err)
+			assert.Len(t, firstChars(u, 3000), len(tt.wantElements))
+		})
+	}
+}
@@ -6,15 +6,31 @@ package service
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
 	"github.com/atinyakov/go-url-shortener/internal/models"
 	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
 	"github.com/atinyakov/go-url-shortener/internal/worker"
 )
 
+// workerStopTimeout bounds how long shutdown waits for the background jobs,
+// such as the delete worker flushing the queued records.
+const workerStopTimeout = 10 * time.Second
+
+// deleteJobName is the name the delete worker is registered under.
+const deleteJobName = "delete"
+
+// clickJobName is the name the click flusher is registered under.
+const clickJobName = "clicks"
+
 // URLService is responsible for providing the main URL-related services,
 // including URL creation, deletion, and retrieval. It interacts with the
 // underlying storage and resolver, and uses a worker for background tasks.
@@ -25,45 +41,131 @@ type URLService struct {
 	resolver *URLResolver
 	// logger is used for logging operations within the service.
 	logger *zap.Logger
-	// baseURL is the base URL for constructing the full short URL.
-	baseURL string
-	// ch is a channel used to send URL records for deletion to a worker.
-	ch chan<- storage.URLRecord
+	// tenants resolves the base URL of short URLs and the redirect code of a request.
+	tenants atomic.Pointer[Tenants]
+	// deleter is the worker deleting URL records in the background.
+	deleter *worker.DeleteTaskWorker
+	// jobs runs the delete worker and other background jobs.
+	jobs *worker.Runtime
+	// quota is the maximum number of active URLs per user, 0 means unlimited.
+	quota atomic.Int64
+	// events publishes URL lifecycle events, nil when the event bus is disabled.
+	events EventPublisher
+	// eventPrefix is prepended to the event type to form the subject.
+	eventPrefix string
+	// clicks counts the clicks of short URLs on redirects.
+	clicks ClickStorage
+	// clickFlusher buffers the clicks of redirects and writes them to clicks in batches.
+	clickFlusher *worker.ClickFlusher
+	// campaigns stores the campaigns URLs are grouped in.
+	campaigns CampaignStorage
+	// linkTTL is how long created URLs stay valid, 0 means forever.
+	linkTTL time.Duration
+	// blocklist lists the domains URLs may not point to, nil disables the check.
+	blocklist *Blocklist
+	// allowlist lists the only domains URLs may point to, nil or empty allows any.
+	allowlist *Allowlist
+	// scanner checks created URLs for threats in the background, nil disables the check.
+	scanner *worker.ThreatScanner
+	// titles fetches the titles of URLs created without one in the background, nil disables fetching.
+	titles *worker.TitleFetcher
+	// statsRefresh is how often the cached totals of GetStats are recomputed, 0 disables the cache.
+	statsRefresh time.Duration
+	// stats holds the cached totals of GetStats, nil until they are first computed.
+	stats atomic.Pointer[statsSnapshot]
+	// lookups collapses concurrent storage lookups of the same short URL into one.
+	lookups singleflight.Group
+	// strictBatches rejects a whole batch if one of its URLs cannot be created.
+	strictBatches bool
+	// clickStreams delivers the clicks of short URLs to their subscribed owners.
+	clickStreams clickHub
+	// created and redirects count the URLs created and the redirects of the last minute.
+	created, redirects rateCounter
+	// abuse bans the users and client IPs creating URLs too fast, disabled until SetAbuseLimits.
+	abuse abuseGuard
+	// passwords refuses the password checks of client IPs sending too many wrong passwords.
+	passwords passwordGuard
 }
 
 // NewURL creates a new instance of URLService with the given repository, resolver,
 // logger, and base URL. It initializes the worker for background deletion tasks.
 func NewURL(ctx context.Context, repo Storage, resolver *URLResolver, logger *zap.Logger, baseURL string) (*URLService, func()) {
+	return NewURLWithOptions(ctx, repo, resolver, logger, baseURL, worker.Options{})
+}
+
+// NewURLWithOptions is like NewURL but configures the batching of the
+// background delete worker with deleteOpts.
+func NewURLWithOptions(ctx context.Context, repo Storage, resolver *URLResolver, logger *zap.Logger, baseURL string, deleteOpts worker.Options) (*URLService, func()) {
 	// Initialize the delete worker
-	worker := worker.NewDeleteRecordWorker(logger, repo)
-	in := worker.GetInChannel()
+	deleter := worker.NewDeleteRecordWorkerWithOptions(logger, repo, deleteOpts)
+	jobs := worker.NewRuntime(logger)
+	if err := jobs.Register(deleteJobName, deleter); err != nil {
+		// Cannot happen with a fresh runtime
+		panic(err)
+	}
 
 	// Create the URLService
 	service := &URLService{
 		repository: repo,
 		resolver:   resolver,
-		baseURL:    baseURL,
-		ch:         in,
+		deleter:    deleter,
+		jobs:       jobs,
 		logger:     logger,
+		clicks:     storage.NewMemoryClickStorage(),
+		campaigns:  storage.NewMemoryCampaignStorage(),
 	}
+	service.clickFlusher = worker.NewClickFlusher(logger, worker.ClickRepoFunc(func(ctx context.Context, clicks []storage.BucketClicks) error {
+		return service.clicks.RecordClicks(ctx, clicks)
+	}), worker.DefaultClickFlushInterval)
+	if err := jobs.Register(clickJobName, service.clickFlusher); err != nil {
+		panic(err)
+	}
+	service.tenants.Store(&Tenants{fallback: Tenant{BaseURL: baseURL, RedirectCode: DefaultRedirectCode}})
 
-	// context for FlushRecords
-	workerCtx, cancel := context.WithCancel(ctx)
-
-	// Start the worker in the background
-	go worker.FlushRecords(workerCtx)
+	// Start the jobs in the background. They outlive ctx, so requests still in
+	// flight during shutdown can schedule deletions, and are stopped by shutdown.
+	jobs.Start(context.WithoutCancel(ctx))
 
-	// shutdown function: closes channel and cancels context
+	// shutdown function: stops the jobs, which drains the delete queue and
+	// waits for the final flush
 	shutdown := func() {
-		logger.Info("Shutting down background delete worker")
-		close(in)                   // closing input channel triggers graceful exit
-		cancel()                    // cancel context in case it's still waiting
-		time.Sleep(4 * time.Second) // optional: wait for final flush
+		logger.Info("Shutting down background jobs")
+		stopCtx, cancel := context.WithTimeout(context.Background(), workerStopTimeout)
+		defer cancel()
+		if err := jobs.Stop(stopCtx); err != nil {
+			logger.Error("Background jobs did not finish in time", zap.Error(err))
+		}
 	}
 
 	return service, shutdown
 }
 
+// ErrWorkerStopped is returned by CheckWorker when the delete worker is not running.
+var ErrWorkerStopped = errors.New("delete worker is not running")
+
+// CheckWorker returns ErrWorkerStopped if the background delete worker is not running.
+func (s *URLService) CheckWorker(ctx context.Context) error {
+	if !s.jobs.Running(deleteJobName) {
+		return ErrWorkerStopped
+	}
+	return nil
+}
+
+// Jobs returns the runtime of the service's background jobs, so that further
+// jobs can be registered to run and stop along with the delete worker.
+func (s *URLService) Jobs() *worker.Runtime {
+	return s.jobs
+}
+
+// CheckStorage returns an error if the storage is unreachable. Storages that
+// cannot be pinged, such as the in-memory and file storages, are always available.
+func (s *URLService) CheckStorage(ctx context.Context) error {
+	if err := s.repository.PingContext(ctx); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+		return err
+	}
+	return nil
+}
+
 // PingContext checks the health of the storage connection.
 func (s *URLService) PingContext(ctx context.Context) error {
 	// Ping the repository to ensure it is accessible
@@ -72,68 +174,297 @@ func (s *URLService) PingContext(ctx context.Context) error {
 
 // CreateURLRecord creates a new URL record in the storage, generating a short URL
 // from the provided long URL and associating it with the specified user ID.
+// It returns a BlockedDomainError if the URL points to a blocked domain, a
+// DomainNotAllowedError if it does not match the allowlist, a BannedError if
+// the user or the client is banned for creating URLs too fast and a QuotaError
+// if the user already owns as many URLs as the quota allows.
 func (s *URLService) CreateURLRecord(ctx context.Context, long string, userID string) (*storage.URLRecord, error) {
+	return s.CreateScheduledURLRecord(ctx, long, userID, nil, nil)
+}
+
+// CreateScheduledURLRecord is like CreateURLRecord, but the short URL only
+// redirects from notBefore until notAfter; nil bounds do not restrict it. It
+// returns ErrInvalidWindow if notAfter is not after notBefore.
+func (s *URLService) CreateScheduledURLRecord(ctx context.Context, long string, userID string, notBefore, notAfter *time.Time) (*storage.URLRecord, error) {
+	return s.CreateURLRecordWithOptions(ctx, long, userID, CreateOptions{NotBefore: notBefore, NotAfter: notAfter})
+}
+
+// CreateOptions are the optional settings of a URL record being created.
+type CreateOptions struct {
+	NotBefore   *time.Time // When the short URL starts redirecting, nil if right away
+	NotAfter    *time.Time // When the short URL stops redirecting, nil if never
+	Tags        []string   // Tags attached to the short URL, normalized by NormalizeTag
+	Title       string     // Title of the short URL, empty to fetch the title of its page
+	Description string     // Notes on the short URL
+}
+
+// CreateURLRecordWithOptions is like CreateURLRecord, with the activation
+// window, tags, title and description of opts. It returns ErrInvalidWindow if
+// the window is empty, ErrInvalidTags if the tags are not allowed and
+// ErrInvalidDetails if the title or description is too long.
+func (s *URLService) CreateURLRecordWithOptions(ctx context.Context, long string, userID string, opts CreateOptions) (*storage.URLRecord, error) {
+	ctx, span := tracing.Start(ctx, "URLService.CreateURLRecord", tracing.KindInternal)
+	defer span.End()
+
+	if err := checkWindow(opts.NotBefore, opts.NotAfter); err != nil {
+		return nil, err
+	}
+	tags, err := normalizeTags(opts.Tags)
+	if err != nil {
+		return nil, err
+	}
+	title, description, err := normalizeDetails(opts.Title, opts.Description)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBlocklist(ctx, long); err != nil {
+		return nil, err
+	}
+	if err := s.checkAllowlist(ctx, long); err != nil {
+		return nil, err
+	}
+
+	// Reject the request if the user or the client is banned, or the user has used up the quota
+	if err := s.checkAbuse(ctx, userID, 1); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(ctx, userID, 1); err != nil {
+		return nil, err
+	}
+
 	// Generate a short URL using the resolver
 	shortURL := s.resolver.LongToShort(long)
 
 	// Store the URL record in the repository
-	return s.repository.Write(ctx, storage.URLRecord{Original: long, Short: shortURL, UserID: userID})
+	createdAt := time.Now().UTC()
+	record := storage.URLRecord{Original: long, Short: shortURL, UserID: userID, ExpiresAt: s.expiresAt(), NotBefore: opts.NotBefore, NotAfter: opts.NotAfter, CreatedAt: &createdAt, Tags: tags, Title: title, Description: description}
+	r, err := s.repository.Write(ctx, record)
+	span.RecordError(err)
+	if err == nil {
+		s.created.add(time.Now(), 1)
+		audit.AddTargets(ctx, shortURL)
+		s.publish(ctx, EventCreated, record)
+		s.scanThreats(ctx, record)
+		s.fetchTitles(ctx, record)
+	}
+	return r, err
 }
 
 // DeleteURLRecords sends URL records to the worker's channel for deletion.
 // This will be processed asynchronously by the worker.
 func (s *URLService) DeleteURLRecords(ctx context.Context, rs []storage.URLRecord) {
-	// Log the deletion action and send each URL record to the worker for deletion
-	s.logger.Info("Sending to a delete channel")
-	for _, record := range rs {
-		s.ch <- record
+	ctx, span := tracing.Start(ctx, "URLService.DeleteURLRecords", tracing.KindInternal)
+	defer span.End()
+
+	// Log the deletion action and send the URL records to the worker for deletion
+	logger.FromContext(ctx, s.logger).Info("Sending to a delete channel", zap.Int("count", len(rs)))
+	records := make([]storage.URLRecord, len(rs))
+	for i, record := range rs {
+		record.Short = s.resolver.Normalize(record.Short)
+		records[i] = record
+	}
+	n, err := s.deleter.SubmitAll(ctx, records)
+	if err != nil {
+		logger.FromContext(ctx, s.logger).Error("Cannot schedule records for deletion", zap.Error(err), zap.Int("count", len(records)-n))
+	}
+	for _, record := range records[:n] {
+		s.publish(ctx, EventDeleted, record)
 	}
 }
 
 // CreateURLRecords processes a batch of URL creation requests. It generates short URLs
 // for the provided long URLs, stores them in the repository, and returns the batch response
-// with the corresponding short URLs.
+// with the corresponding short URLs and the status of each of them, see
+// createEach. With strict batches, set by SetStrictBatches, the whole batch
+// is rejected instead with a BlockedDomainError or DomainNotAllowedError if
+// one of the URLs may not be shortened, with ErrInvalidWindow if the
+// activation window of one of them is empty, with ErrInvalidTags if its tags
+// are not allowed, with ErrInvalidDetails if its title or description is too
+// long, and with storage.ErrConflict if one of them exists. Either way it is
+// rejected with a BannedError if the user or the client is banned for creating
+// URLs too fast, and with a QuotaError if it does not fit into the quota of
+// the user. A URL repeated in the batch is created once, with the activation
+// window, tags, title and description of its first occurrence, and all its
+// occurrences get the same short URL.
 func (s *URLService) CreateURLRecords(ctx context.Context, rs []models.BatchRequest, userID string) (*[]models.BatchResponse, error) {
+	ctx, span := tracing.Start(ctx, "URLService.CreateURLRecords", tracing.KindInternal)
+	defer span.End()
+
+	if !s.strictBatches {
+		return s.createEach(ctx, rs, userID)
+	}
+
 	var resultNew []models.BatchResponse
 
 	if len(rs) != 0 {
-		// Prepare the list of URL records to be created
-		records := make([]storage.URLRecord, 0)
+		longs := make([]string, len(rs))
+		tags := make([][]string, len(rs))
+		titles, descriptions := make([]string, len(rs)), make([]string, len(rs))
+		for i, url := range rs {
+			if err := checkWindow(url.NotBefore, url.NotAfter); err != nil {
+				return &resultNew, err
+			}
+			var err error
+			if tags[i], err = normalizeTags(url.Tags); err != nil {
+				return &resultNew, err
+			}
+			if titles[i], descriptions[i], err = normalizeDetails(url.Title, url.Description); err != nil {
+				return &resultNew, err
+			}
+			longs[i] = url.OriginalURL
+		}
+		if err := s.checkBlocklist(ctx, longs...); err != nil {
+			return &resultNew, err
+		}
+		if err := s.checkAllowlist(ctx, longs...); err != nil {
+			return &resultNew, err
+		}
+
+		// Reject the batch if the creator is banned or its distinct URLs do not fit into the quota
+		firsts := firstOccurrences(rs)
+		distinct := 0
+		for i, first := range firsts {
+			if first == i {
+				distinct++
+			}
+		}
+		if err := s.checkAbuse(ctx, userID, distinct); err != nil {
+			return &resultNew, err
+		}
+		if err := s.checkQuota(ctx, userID, distinct); err != nil {
+			return &resultNew, err
+		}
+
+		// Prepare the list of URL records to be created, one per distinct URL
+		records := make([]storage.URLRecord, 0, distinct)
+		recordOf := make([]int, len(rs))
 
 		// Generate short URLs for each request
-		for _, url := range rs {
+		expiresAt := s.expiresAt()
+		createdAt := time.Now().UTC()
+		for i, url := range rs {
+			if firsts[i] != i {
+				recordOf[i] = recordOf[firsts[i]]
+				continue
+			}
+			recordOf[i] = len(records)
 			short := s.resolver.LongToShort(url.OriginalURL)
-			records = append(records, storage.URLRecord{Original: url.OriginalURL, ID: url.CorrelationID, Short: short, UserID: userID})
+			records = append(records, storage.URLRecord{
+				Original:    url.OriginalURL,
+				ID:          url.CorrelationID,
+				Short:       short,
+				UserID:      userID,
+				ExpiresAt:   expiresAt,
+				NotBefore:   url.NotBefore,
+				NotAfter:    url.NotAfter,
+				CreatedAt:   &createdAt,
+				Tags:        tags[i],
+				Title:       titles[i],
+				Description: descriptions[i],
+			})
 		}
 
 		// Write all records to the repository
 		err := s.repository.WriteAll(ctx, records)
 		if err != nil {
+			span.RecordError(err)
 			return &resultNew, err
 		}
 
-		// Build the response with the short URLs
+		// Build the response with the short URLs of the tenant
+		tenant := s.Tenant(ctx)
+		for i, url := range rs {
+			nr := records[recordOf[i]]
+			resultNew = append(resultNew, models.BatchResponse{CorrelationID: url.CorrelationID, ShortURL: tenant.ShortURL(nr.Short), Status: models.BatchStatusCreated})
+		}
+		s.created.add(time.Now(), len(records))
 		for _, nr := range records {
-			resultNew = append(resultNew, models.BatchResponse{CorrelationID: nr.ID, ShortURL: s.baseURL + "/" + nr.Short})
+			audit.AddTargets(ctx, nr.Short)
+			s.publish(ctx, EventCreated, nr)
 		}
+		s.scanThreats(ctx, records...)
+		s.fetchTitles(ctx, records...)
 	}
 
 	return &resultNew, nil
 }
 
-// GetURLByShort retrieves the original URL by the given short URL.
+// ForceDeleteURLRecords looks up the owners of the given short URLs and sends the
+// records to the worker's channel for deletion. Unknown short URLs are ignored.
+func (s *URLService) ForceDeleteURLRecords(ctx context.Context, shorts []string) error {
+	ctx, span := tracing.Start(ctx, "URLService.ForceDeleteURLRecords", tracing.KindInternal)
+	defer span.End()
+
+	records := make([]storage.URLRecord, 0, len(shorts))
+	for _, short := range shorts {
+		// Resolve the owner, since records are deleted on behalf of their user
+		r, err := s.repository.FindByShort(ctx, s.resolver.Normalize(short))
+		if err != nil || r == nil {
+			continue
+		}
+		records = append(records, storage.URLRecord{Short: r.Short, UserID: r.UserID})
+		audit.AddTargets(ctx, r.Short)
+	}
+
+	s.DeleteURLRecords(ctx, records)
+	return nil
+}
+
+// GetURLByShort retrieves the original URL by the given short URL. It is used
+// to resolve redirects, which are counted separately by RecordRedirect once
+// the request is let through. It returns ErrNotYetActive for URLs whose
+// activation window has not started; URLs whose window has ended are returned
+// as deleted. It returns storage.ErrNotFound for unknown short URLs.
 func (s *URLService) GetURLByShort(ctx context.Context, short string) (*storage.URLRecord, error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetURLByShort", tracing.KindInternal)
+	defer span.End()
+
 	// Find and return the URL record based on the short URL
-	return s.repository.FindByShort(ctx, short)
+	r, err := s.findByShort(ctx, s.resolver.Normalize(short))
+	if err == nil && r != nil && r.NotYetActive(time.Now()) {
+		return nil, ErrNotYetActive
+	}
+	hideExpired(r)
+	return r, err
+}
+
+// findByShort looks up the record of the short URL in the storage. Concurrent
+// lookups of the same short URL, such as the redirects of a viral link, share
+// a single storage query; each caller gets its own copy of the record and
+// stops waiting when its own ctx is done.
+func (s *URLService) findByShort(ctx context.Context, short string) (*storage.URLRecord, error) {
+	// The query outlives the caller that started it, as others may be waiting on it
+	queryCtx := context.WithoutCancel(ctx)
+	ch := s.lookups.DoChan(short, func() (interface{}, error) {
+		return s.repository.FindByShort(queryCtx, short)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		r, _ := res.Val.(*storage.URLRecord)
+		if r == nil {
+			return nil, res.Err
+		}
+		found := *r
+		return &found, res.Err
+	}
 }
 
-// GetURLByUserID retrieves all URL records associated with the specified user ID.
-func (s *URLService) GetURLByUserID(ctx context.Context, id string) (*[]models.ByIDRequest, error) {
+// GetURLByUserID retrieves the URL records associated with the specified user ID,
+// filtered, ordered and paginated according to opts.
+func (s *URLService) GetURLByUserID(ctx context.Context, id string, opts storage.ListOptions) (*[]models.ByIDRequest, error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetURLByUserID", tracing.KindInternal)
+	defer span.End()
+	opts.Tag = NormalizeTag(opts.Tag)
+
 	var resultNew []models.ByIDRequest
 
 	// Retrieve the URL records from the repository based on the user ID
-	urls, err := s.repository.FindByUserID(ctx, id)
+	urls, err := s.repository.FindByUserID(ctx, id, opts)
 	if err != nil {
+		span.RecordError(err)
 		return &resultNew, err
 	}
 
@@ -142,9 +473,18 @@ func (s *URLService) GetURLByUserID(ctx context.Context, id string) (*[]models.B
 		return &resultNew, err
 	}
 
-	// Build the response with the full URLs (including base URL)
+	// Build the response with the full URLs (including the base URL of the tenant)
+	tenant := s.Tenant(ctx)
 	for _, url := range *urls {
-		resultNew = append(resultNew, models.ByIDRequest{ShortURL: s.baseURL + "/" + url.Short, OriginalURL: url.Original})
+		resultNew = append(resultNew, models.ByIDRequest{
+			ShortURL:    tenant.ShortURL(url.Short),
+			OriginalURL: url.Original,
+			Tags:        url.Tags,
+			Title:       url.Title,
+			Description: url.Description,
+			Pinned:      url.Pinned,
+			CampaignID:  url.Campaign,
+		})
 	}
 
 	return &resultNew, nil
@@ -2,7 +2,11 @@ package service
 
 import (
 	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -30,7 +34,7 @@ func TestURLService_CreateURLRecord(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Equal(t, "http://example.com", result.Original)
-	assert.Equal(t, "h1ZwLLGa", result.Short)
+	assert.Equal(t, "aaTD2lCY", result.Short)
 }
 
 func TestURLService_CreateURLRecords(t *testing.T) {
@@ -59,7 +63,7 @@ func TestURLService_CreateURLRecords(t *testing.T) {
 	assert.Equal(t, "123", responses[0].CorrelationID)
 
 	// Also check if it was stored
-	stored, err := mockStorage.FindByUserID(ctx, userID)
+	stored, err := mockStorage.FindByUserID(ctx, userID, storage.ListOptions{})
 	assert.NoError(t, err)
 	assert.Len(t, *stored, 1)
 	assert.Equal(t, "http://example.com", (*stored)[0].Original)
@@ -89,6 +93,70 @@ func TestURLService_GetURLByShort(t *testing.T) {
 	assert.Equal(t, "short-url", result.Short)
 }
 
+// slowStorage counts the lookups by short URL and holds them until release is closed.
+type slowStorage struct {
+	*storage.MemoryStorage
+	lookups atomic.Int32
+	release chan struct{}
+}
+
+func (s *slowStorage) FindByShort(ctx context.Context, short string) (*storage.URLRecord, error) {
+	s.lookups.Add(1)
+	<-s.release
+	return s.MemoryStorage.FindByShort(ctx, short)
+}
+
+func TestURLService_GetURLByShort_CollapsesConcurrentLookups(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+	_, err := mem.Write(context.Background(), storage.URLRecord{Original: "http://example.com", Short: "viral", UserID: "user-id"})
+	require.NoError(t, err)
+	slow := &slowStorage{MemoryStorage: mem, release: make(chan struct{})}
+	resolver, _ := NewURLResolver(8, slow)
+	service, _ := NewURL(context.Background(), slow, resolver, zap.NewNop(), "http://baseurl")
+
+	const callers = 50
+	results := make(chan *storage.URLRecord, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := service.GetURLByShort(context.Background(), "viral")
+			assert.NoError(t, err)
+			results <- r
+		}()
+	}
+
+	// A caller giving up does not fail the shared lookup
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = service.GetURLByShort(ctx, "viral")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	require.Eventually(t, func() bool { return slow.lookups.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(slow.release)
+	wg.Wait()
+	close(results)
+
+	assert.Equal(t, int32(1), slow.lookups.Load())
+	var first *storage.URLRecord
+	for r := range results {
+		require.NotNil(t, r)
+		assert.Equal(t, "http://example.com", r.Original)
+		if first == nil {
+			first = r
+			continue
+		}
+		assert.NotSame(t, first, r, "callers get their own copy of the record")
+	}
+
+	// Later lookups query the storage again
+	_, err = service.GetURLByShort(context.Background(), "viral")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), slow.lookups.Load())
+}
+
 func TestURLService_GetURLByUserID(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -106,7 +174,7 @@ func TestURLService_GetURLByUserID(t *testing.T) {
 
 	service, _ := NewURL(context.Background(), mockStorage, mockResolver, mockLogger, "http://baseurl")
 
-	result, err := service.GetURLByUserID(context.Background(), "user-id")
+	result, err := service.GetURLByUserID(context.Background(), "user-id", storage.ListOptions{})
 
 	// Assertions
 	assert.NoError(t, err)
@@ -131,3 +199,87 @@ func TestURLService_PingContext(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "unsupported")
 }
+
+func TestURLService_ForceDeleteURLRecords(t *testing.T) {
+	fileStorage, err := storage.NewFileStorage(t.TempDir()+"/records.json", zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, fileStorage.WriteAll(context.Background(), []storage.URLRecord{
+		{Original: "http://a.example.com", Short: "aaa", UserID: "owner-a"},
+		{Original: "http://b.example.com", Short: "bbb", UserID: "owner-b"},
+	}))
+
+	resolver, _ := NewURLResolver(8, fileStorage)
+	service, shutdown := NewURL(context.Background(), fileStorage, resolver, zap.NewNop(), "http://baseurl")
+
+	err = service.ForceDeleteURLRecords(context.Background(), []string{"aaa", "unknown", "bbb"})
+	require.NoError(t, err)
+
+	// Stopping the worker flushes the queued deletions
+	shutdown()
+
+	// File storage marks deleted records, whoever owns them
+	records, err := fileStorage.Read(context.Background())
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	for _, r := range records {
+		assert.True(t, r.IsDeleted, r.Short)
+		assert.NotNil(t, r.DeletedAt, r.Short)
+	}
+}
+
+func TestURLService_GetStats(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+
+	require.NoError(t, mockStorage.WriteAll(context.Background(), []storage.URLRecord{
+		{Original: "http://a.example.com", Short: "aaa", UserID: "user-1"},
+		{Original: "http://b.example.com", Short: "bbb", UserID: "user-1"},
+		{Original: "http://c.example.com", Short: "ccc", UserID: "user-2"},
+	}))
+
+	service, _ := NewURL(context.Background(), mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	stats, err := service.GetStats(context.Background(), StatsOptions{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.URLs)
+	assert.Equal(t, 2, stats.Users)
+}
+
+func TestURLService_HealthChecks(t *testing.T) {
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+
+	service, shutdown := NewURL(context.Background(), mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	// The in-memory storage cannot be pinged but is always available
+	require.NoError(t, service.CheckStorage(context.Background()))
+
+	require.Eventually(t, func() bool {
+		return service.CheckWorker(context.Background()) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	shutdown()
+	require.ErrorIs(t, service.CheckWorker(context.Background()), ErrWorkerStopped)
+}
+
+func TestURLService_CaseInsensitiveShortURLs(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	resolver, err := NewURLResolverWithOptions(8, mockStorage, ResolverOptions{CaseInsensitive: true})
+	require.NoError(t, err)
+	service, _ := NewURL(ctx, mockStorage, resolver, zap.NewNop(), "http://baseurl")
+
+	created, err := service.CreateURLRecord(ctx, "https://example.com", "user-id")
+	require.NoError(t, err)
+	assert.Equal(t, strings.ToLower(created.Short), created.Short)
+
+	// Short URLs typed in another case resolve to the same record
+	r, err := service.GetURLByShort(ctx, strings.ToUpper(created.Short))
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", r.Original)
+
+	m, err := service.GetURLMetadata(ctx, strings.ToUpper(created.Short), "user-id")
+	require.NoError(t, err)
+	assert.Equal(t, "http://baseurl/"+created.Short, m.ShortURL)
+}
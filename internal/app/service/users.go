@@ -0,0 +1,71 @@
+// Package service provides the user accounts subsystem. Accounts are created
+// lazily: when a token is minted for a new user ID, or on first access by a
+// user whose token predates accounts.
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// MaxDisplayNameLength is the maximum length of a display name in characters.
+const MaxDisplayNameLength = 64
+
+// ErrInvalidDisplayName is returned for display names that are too long or contain control characters.
+var ErrInvalidDisplayName = errors.New("invalid display name")
+
+// Users manages user accounts.
+type Users struct {
+	storage UserStorage // Storage backend for user accounts.
+}
+
+// NewUsers creates a new Users instance with the given storage backend.
+func NewUsers(s UserStorage) *Users {
+	return &Users{
+		storage: s,
+	}
+}
+
+// Register creates the account of a newly issued user ID.
+func (u *Users) Register(ctx context.Context, id string) error {
+	return u.storage.CreateUser(ctx, storage.User{ID: id, CreatedAt: time.Now().UTC()})
+}
+
+// Get returns the account of a user. Users whose tokens were issued before
+// accounts existed are registered on first access.
+func (u *Users) Get(ctx context.Context, id string) (*storage.User, error) {
+	if user, err := u.storage.FindUser(ctx, id); err == nil {
+		return user, nil
+	}
+
+	if err := u.Register(ctx, id); err != nil {
+		return nil, err
+	}
+	return u.storage.FindUser(ctx, id)
+}
+
+// SetDisplayName validates and stores the display name of a user.
+// An empty name clears it.
+func (u *Users) SetDisplayName(ctx context.Context, id string, name string) (*storage.User, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > MaxDisplayNameLength || strings.ContainsFunc(name, isControl) {
+		return nil, ErrInvalidDisplayName
+	}
+
+	if err := u.storage.UpdateDisplayName(ctx, id, name); err != nil {
+		return nil, err
+	}
+	audit.AddTargets(ctx, id)
+	return u.storage.FindUser(ctx, id)
+}
+
+// isControl reports whether r is a control character.
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
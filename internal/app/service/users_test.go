@@ -0,0 +1,44 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestUsers(t *testing.T) {
+	users := service.NewUsers(storage.NewMemoryUserStorage())
+	ctx := context.Background()
+
+	require.NoError(t, users.Register(ctx, "user-1"))
+
+	user, err := users.Get(ctx, "user-1")
+	require.NoError(t, err)
+	require.Equal(t, "user-1", user.ID)
+	require.False(t, user.CreatedAt.IsZero())
+
+	user, err = users.SetDisplayName(ctx, "user-1", "  Alice  ")
+	require.NoError(t, err)
+	require.Equal(t, "Alice", user.DisplayName)
+
+	_, err = users.SetDisplayName(ctx, "user-1", strings.Repeat("a", service.MaxDisplayNameLength+1))
+	require.ErrorIs(t, err, service.ErrInvalidDisplayName)
+
+	_, err = users.SetDisplayName(ctx, "user-1", "bad\nname")
+	require.ErrorIs(t, err, service.ErrInvalidDisplayName)
+}
+
+func TestUsersGetRegistersUnknownUser(t *testing.T) {
+	users := service.NewUsers(storage.NewMemoryUserStorage())
+
+	// Users whose tokens predate accounts are registered on first access
+	user, err := users.Get(context.Background(), "legacy-user")
+	require.NoError(t, err)
+	require.Equal(t, "legacy-user", user.ID)
+	require.False(t, user.CreatedAt.IsZero())
+}
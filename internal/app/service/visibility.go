@@ -0,0 +1,52 @@
+// Package service provides the visibility of short URLs: the metadata and
+// stats of public short URLs can be shared with anyone knowing them, those of
+// private ones are shown to their owner only.
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+)
+
+// ErrInvalidVisibility is returned when a visibility is neither
+// models.VisibilityPublic nor models.VisibilityPrivate.
+var ErrInvalidVisibility = errors.New(`visibility must be "public" or "private"`)
+
+// SetURLVisibility makes the metadata and stats of the short URL of the user
+// public or private. It returns ErrInvalidVisibility for unknown visibilities,
+// storage.ErrNotFound if the user does not own the short URL and
+// storage.ErrDeleted if it was deleted.
+func (s *URLService) SetURLVisibility(ctx context.Context, short string, userID string, visibility string) error {
+	ctx, span := tracing.Start(ctx, "URLService.SetURLVisibility", tracing.KindInternal)
+	defer span.End()
+	short = s.resolver.Normalize(short)
+
+	var private bool
+	switch visibility {
+	case models.VisibilityPublic:
+	case models.VisibilityPrivate:
+		private = true
+	default:
+		return ErrInvalidVisibility
+	}
+
+	err := s.repository.SetPrivate(ctx, short, userID, private)
+	span.RecordError(err)
+	if err == nil {
+		audit.AddTargets(ctx, short)
+	}
+	return err
+}
+
+// visibility returns the visibility of the record.
+func visibility(r *storage.URLRecord) string {
+	if r.Private {
+		return models.VisibilityPrivate
+	}
+	return models.VisibilityPublic
+}
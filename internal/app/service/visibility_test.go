@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/models"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestURLService_SetURLVisibility(t *testing.T) {
+	ctx := context.Background()
+	mockStorage, _ := storage.CreateMemoryStorage()
+	mockResolver, _ := NewURLResolver(8, mockStorage)
+	service, _ := NewURL(ctx, mockStorage, mockResolver, zap.NewNop(), "http://baseurl")
+
+	r, err := service.CreateURLRecord(ctx, "https://example.com/a", "owner")
+	require.NoError(t, err)
+
+	// Short URLs are public by default
+	m, err := service.GetURLMetadata(ctx, r.Short, "")
+	require.NoError(t, err)
+	assert.Empty(t, m.Visibility)
+	m, err = service.GetURLMetadata(ctx, r.Short, "owner")
+	require.NoError(t, err)
+	assert.Equal(t, models.VisibilityPublic, m.Visibility)
+
+	assert.ErrorIs(t, service.SetURLVisibility(ctx, r.Short, "owner", "hidden"), ErrInvalidVisibility)
+	assert.ErrorIs(t, service.SetURLVisibility(ctx, r.Short, "other", models.VisibilityPrivate), storage.ErrNotFound)
+	require.NoError(t, service.SetURLVisibility(ctx, r.Short, "owner", models.VisibilityPrivate))
+
+	// Private short URLs are described to their owner only
+	_, err = service.GetURLMetadata(ctx, r.Short, "")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = service.GetURLMetadata(ctx, r.Short, "other")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	m, err = service.GetURLMetadata(ctx, r.Short, "owner")
+	require.NoError(t, err)
+	assert.Equal(t, models.VisibilityPrivate, m.Visibility)
+
+	require.NoError(t, service.SetURLVisibility(ctx, r.Short, "owner", models.VisibilityPublic))
+	_, err = service.GetURLMetadata(ctx, r.Short, "other")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,24 @@
+// Package webui serves a small single-page web UI for shortening links,
+// browsing the links of the current user and viewing the statistics of the
+// service. The page is embedded into the binary and talks to the JSON API,
+// authenticated by the JWT cookie the server issues to every browser.
+package webui
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// page is the single page of the UI, with its styles and script inlined.
+//
+//go:embed index.html
+var page []byte
+
+// Serve writes the UI page.
+func Serve(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// The page keeps no state of its own, but must pick up a new build
+	res.Header().Set("Cache-Control", "no-cache")
+	res.WriteHeader(http.StatusOK)
+	_, _ = res.Write(page)
+}
@@ -0,0 +1,20 @@
+package webui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Serve(rec, httptest.NewRequest(http.MethodGet, "/ui", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	for _, endpoint := range []string{"/api/v1/shorten", "/api/v1/user/urls", "/api/internal/stats"} {
+		assert.Contains(t, rec.Body.String(), endpoint)
+	}
+}
@@ -0,0 +1,50 @@
+// Package audit provides a request-scoped trail of the resources a mutating
+// request touched. The audit middleware starts a trail for every mutating
+// request; the service layer adds the affected resources to it, and the
+// middleware writes the trail to the audit store once the request completes.
+package audit
+
+import (
+	"context"
+	"sync"
+)
+
+// trail collects the targets of one request. Targets may be added from
+// goroutines spawned by the request, so access is guarded by a mutex.
+type trail struct {
+	mu      sync.Mutex // Guards targets
+	targets []string   // Resources affected by the request
+}
+
+// trailKey is the context key under which the trail is stored.
+type trailKey struct{}
+
+// NewContext returns a copy of ctx carrying an empty trail.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, trailKey{}, &trail{})
+}
+
+// AddTargets records resources affected by the request. It is a no-op if
+// ctx carries no trail, e.g. outside of HTTP requests.
+func AddTargets(ctx context.Context, targets ...string) {
+	t, ok := ctx.Value(trailKey{}).(*trail)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	t.targets = append(t.targets, targets...)
+	t.mu.Unlock()
+}
+
+// TargetsFromContext returns a copy of the resources recorded in ctx.
+func TargetsFromContext(ctx context.Context) []string {
+	t, ok := ctx.Value(trailKey{}).(*trail)
+	if !ok {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.targets...)
+}
@@ -0,0 +1,32 @@
+package audit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+)
+
+func TestTrail(t *testing.T) {
+	ctx := audit.NewContext(context.Background())
+
+	audit.AddTargets(ctx, "abc")
+	audit.AddTargets(ctx, "def", "ghi")
+
+	targets := audit.TargetsFromContext(ctx)
+	assert.Equal(t, []string{"abc", "def", "ghi"}, targets)
+
+	// The returned slice is a copy
+	targets[0] = "changed"
+	assert.Equal(t, "abc", audit.TargetsFromContext(ctx)[0])
+}
+
+func TestTrailMissing(t *testing.T) {
+	ctx := context.Background()
+
+	audit.AddTargets(ctx, "abc")
+
+	assert.Nil(t, audit.TargetsFromContext(ctx))
+}
@@ -1,95 +1,633 @@
 // Package config provides functionality for managing configuration options
-// for the application using command-line flags and environment variables.
+// for the application using command-line flags, a configuration file and
+// environment variables.
 package config
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"log"
-	"os"
-	"strconv"
+	"slices"
+	"strings"
+	"time"
 )
 
 // Options holds the configuration values for the application.
 type Options struct {
 	// Port defines the server's listening address (ip:port).
-	Port string
+	Port string `json:"server_address"`
 
 	// ResultHostname is the base URL used for result links.
-	ResultHostname string
+	ResultHostname string `json:"base_url"`
 
 	// FilePath is the path to the storage file for persistent data.
-	FilePath string
+	FilePath string `json:"file_storage_path"`
 
 	// DatabaseDSN holds the database connection string for the application.
-	DatabaseDSN string
+	DatabaseDSN string `json:"database_dsn"`
+
+	// DatabaseReplicaDSN holds the connection string of a read-only replica of
+	// the database. Lookups, listings and statistics are read from it, falling
+	// back to the primary database while it is unavailable; empty disables it.
+	DatabaseReplicaDSN string `json:"database_replica_dsn"`
 
 	// EnablePprof indicates whether to enable pprof for performance profiling.
-	EnablePprof bool
+	// It can be switched at runtime by reloading the configuration.
+	EnablePprof bool `json:"enable_pprof"`
+
+	// PprofAddr is the address of the dedicated pprof listener; the listener is
+	// not opened when empty. It is opened the first time pprof is enabled.
+	PprofAddr string `json:"pprof_addr"`
+
+	// PprofOnRouter also serves pprof under /debug/pprof/ on the main server,
+	// to clients from TrustedSubnets only.
+	PprofOnRouter bool `json:"pprof_on_router"`
+
+	// ProfileDir is the directory profiles captured through the internal API
+	// are stored in; they are sent back in the response when empty.
+	ProfileDir string `json:"profile_dir"`
+
+	// TrustedSubnets lists the IPv4 and IPv6 subnets, in CIDR notation, that
+	// internal endpoints are available to. They are closed when it is empty.
+	TrustedSubnets StringList `json:"trusted_subnets"`
+
+	// TrustedProxies lists the subnets, in CIDR notation, of the reverse proxies
+	// in front of the server. The client IP of requests they forward is taken
+	// from X-Forwarded-For or X-Real-IP; these headers are ignored otherwise.
+	TrustedProxies StringList `json:"trusted_proxies"`
+
+	// IPAnonymization is how client IPs are anonymized before they are logged
+	// or written to the audit log: "truncate" zeroes their host part, "hash"
+	// replaces them with a keyed hash. They are kept as they are when empty.
+	IPAnonymization string `json:"ip_anonymization"`
+
+	// IPHashKey is the key client IPs are hashed with. A random key is generated
+	// at startup when it is empty, so hashes are not comparable across restarts.
+	IPHashKey string `json:"ip_hash_key"`
 
 	// EnableHTTPS indicates whether to enable https.
-	EnableHTTPS bool
+	EnableHTTPS bool `json:"enable_https"`
+
+	// UnixSocket is the path of a unix domain socket the server listens on
+	// instead of the TCP address, for reverse proxies on the same host.
+	UnixSocket string `json:"unix_socket"`
+
+	// SystemdActivation makes the server accept connections on a socket passed
+	// by systemd socket activation instead of opening its own.
+	SystemdActivation bool `json:"systemd_activation"`
+
+	// SystemdSocketName selects the passed socket by its FileDescriptorName;
+	// the first passed socket is used when empty.
+	SystemdSocketName string `json:"systemd_socket_name"`
+
+	// EnableH2C serves HTTP/2 over cleartext connections next to HTTP/1.1, for
+	// deployments behind proxies that speak HTTP/2 to their backends. HTTPS
+	// negotiates HTTP/2 regardless. HTTP/3 is not served: it needs quic-go and
+	// a UDP listener next to the TLS one, and is tracked as a follow-up.
+	EnableH2C bool `json:"enable_h2c"`
+
+	// TLSCertFile and TLSKeyFile are the PEM-encoded certificate chain and
+	// private key HTTPS is served with. Certificates are obtained with ACME
+	// (autocert) when both are empty.
+	TLSCertFile string `json:"tls_cert_file"`
+	TLSKeyFile  string `json:"tls_key_file"`
+
+	// AutocertDomains lists the host names autocert obtains certificates for.
+	AutocertDomains StringList `json:"autocert_domains"`
+
+	// AutocertCacheDir is the directory obtained certificates are kept in, so
+	// that they survive a restart.
+	AutocertCacheDir string `json:"autocert_cache_dir"`
+
+	// ACMEDirectoryURL is the directory of the ACME CA certificates are
+	// requested from; Let's Encrypt is used when empty.
+	ACMEDirectoryURL string `json:"acme_directory_url"`
+
+	// Config is the path to the Config file, in JSON, YAML or TOML format.
+	Config string `json:"-"`
+
+	// IssueAdminToken is a user ID to print an admin JWT for, after which the
+	// program exits instead of starting the server.
+	IssueAdminToken string `json:"-"`
+
+	// CORSAllowedOrigins lists origins allowed to make cross-origin requests.
+	// CORS is disabled when the list is empty; "*" allows any origin.
+	CORSAllowedOrigins StringList `json:"cors_allowed_origins"`
+
+	// CORSAllowedMethods lists HTTP methods allowed in cross-origin requests.
+	CORSAllowedMethods StringList `json:"cors_allowed_methods"`
+
+	// CORSAllowedHeaders lists request headers allowed in cross-origin requests.
+	CORSAllowedHeaders StringList `json:"cors_allowed_headers"`
+
+	// CORSAllowCredentials indicates whether cookies may be sent with cross-origin
+	// requests. It cannot be combined with the "*" origin.
+	CORSAllowCredentials bool `json:"cors_allow_credentials"`
+
+	// RequestTimeout bounds requests to routes without a dedicated timeout
+	// (redirects, ping, deletion).
+	RequestTimeout Duration `json:"request_timeout"`
+
+	// ShortenTimeout bounds single URL shortening requests.
+	ShortenTimeout Duration `json:"shorten_timeout"`
+
+	// BatchTimeout bounds batch URL shortening requests.
+	BatchTimeout Duration `json:"batch_timeout"`
+
+	// IdempotencyKeyTTL is how long the responses of shortening requests sent
+	// with an Idempotency-Key header are replayed for; 0 ignores the header.
+	IdempotencyKeyTTL Duration `json:"idempotency_key_ttl"`
+
+	// UserURLsTimeout bounds requests listing the URLs of a user.
+	UserURLsTimeout Duration `json:"user_urls_timeout"`
+
+	// MaxURLsPerUser limits how many active URLs a user may own; 0 means unlimited.
+	MaxURLsPerUser int `json:"max_urls_per_user"`
+
+	// RateLimit is the number of requests a client IP may send to the API and
+	// to plain text shortening in every RateLimitWindow; 0 means unlimited.
+	RateLimit int `json:"rate_limit"`
+
+	// RateLimitWindow is the time window of RateLimit. Both can be changed at
+	// runtime by reloading the configuration.
+	RateLimitWindow Duration `json:"rate_limit_window"`
+
+	// AbuseURLsPerMinute is the number of URLs a user or a client IP may create
+	// in a minute before being banned from creating URLs; 0 disables the bans.
+	AbuseURLsPerMinute int `json:"abuse_urls_per_minute"`
+
+	// AbuseBanDuration is how long users and client IPs going past
+	// AbuseURLsPerMinute are banned.
+	AbuseBanDuration Duration `json:"abuse_ban_duration"`
+
+	// JWTSecret is the key used to sign JWT tokens. A random key is generated
+	// at startup when it is empty, so tokens do not survive a restart.
+	JWTSecret string `json:"jwt_secret"`
+
+	// JWTPreviousSecrets lists keys that are still accepted when verifying
+	// tokens, so the signing key can be rotated without logging users out.
+	JWTPreviousSecrets StringList `json:"jwt_previous_secrets"`
+
+	// JWTKeyFile is the path to a PEM-encoded RSA or Ed25519 private key.
+	// When set, tokens are signed with RS256 or EdDSA instead of JWTSecret.
+	JWTKeyFile string `json:"jwt_key_file"`
+
+	// JWTPublicKeyFiles lists PEM-encoded public keys of previous key pairs
+	// that are still accepted when verifying tokens.
+	JWTPublicKeyFiles StringList `json:"jwt_public_key_files"`
+
+	// EventBusURL is the broker that URL creation, deletion and click events are
+	// published to, for example "nats://localhost:4222". Events are disabled when empty.
+	EventBusURL string `json:"event_bus_url"`
+
+	// EventSubjectPrefix is prepended to the event type to form the subject,
+	// for example "shortener.urls.created".
+	EventSubjectPrefix string `json:"event_subject_prefix"`
+
+	// OTLPEndpoint is the OTLP/HTTP collector that traces are exported to, for
+	// example "http://localhost:4318". Tracing is disabled when empty.
+	OTLPEndpoint string `json:"otlp_endpoint"`
+
+	// TracingServiceName is the service name reported with exported traces.
+	TracingServiceName string `json:"tracing_service_name"`
+
+	// LogLevel is the minimum level of logged entries, such as "debug" or "warn".
+	LogLevel string `json:"log_level"`
+
+	// LogFormat is the encoding of log entries, "json" or "console".
+	LogFormat string `json:"log_format"`
+
+	// LogSampling limits repeated log entries with the same message.
+	LogSampling bool `json:"log_sampling"`
+
+	// LogOutputPaths lists the files or "stdout"/"stderr" logs are written to.
+	LogOutputPaths StringList `json:"log_output_paths"`
+
+	// LogFile is a log file written in addition to LogOutputPaths and rotated
+	// once it grows past LogMaxSizeMB megabytes; empty disables it.
+	LogFile string `json:"log_file"`
+
+	// LogMaxSizeMB is the size in megabytes after which LogFile is rotated; 0 never rotates it.
+	LogMaxSizeMB int `json:"log_max_size_mb"`
+
+	// LogMaxAge is how long rotated log files are kept; 0 keeps them regardless of age.
+	LogMaxAge Duration `json:"log_max_age"`
+
+	// LogMaxBackups is the number of rotated log files kept; 0 keeps all of them.
+	LogMaxBackups int `json:"log_max_backups"`
+
+	// SentryDSN is the DSN of a Sentry-compatible project that logged errors and
+	// recovered panics are reported to. Error reporting is disabled when empty.
+	SentryDSN string `json:"sentry_dsn"`
+
+	// SentryEnvironment is the environment reported with errors, for example "production".
+	SentryEnvironment string `json:"sentry_environment"`
+
+	// SlowQueryThreshold is the duration above which database operations are
+	// logged as slow; 0 disables slow query logging.
+	SlowQueryThreshold Duration `json:"slow_query_threshold"`
+
+	// DatabaseMaxRetries is how many times a database call failing with a
+	// transient error is retried; 0 disables retries.
+	DatabaseMaxRetries int `json:"database_max_retries"`
+
+	// DatabaseRetryBackoff is the delay before the first retry of a database
+	// call, doubled for each next one.
+	DatabaseRetryBackoff Duration `json:"database_retry_backoff"`
+
+	// DatabaseBreakerThreshold is the number of consecutive failed database
+	// calls after which calls fail fast; 0 disables the circuit breaker.
+	DatabaseBreakerThreshold int `json:"database_breaker_threshold"`
+
+	// DatabaseBreakerCooldown is how long database calls fail fast before one
+	// is let through to check whether the database recovered.
+	DatabaseBreakerCooldown Duration `json:"database_breaker_cooldown"`
+
+	// DeleteBatchSize is the number of queued deletions after which the delete
+	// worker flushes a batch before the flush interval elapses.
+	DeleteBatchSize int `json:"delete_batch_size"`
+
+	// DeleteFlushInterval is how often the delete worker flushes queued deletions.
+	DeleteFlushInterval Duration `json:"delete_flush_interval"`
+
+	// DeleteWorkers is the number of goroutines processing queued deletions.
+	DeleteWorkers int `json:"delete_workers"`
+
+	// DeleteMaxRetries is how many times a failed deletion batch is retried; 0 disables retries.
+	DeleteMaxRetries int `json:"delete_max_retries"`
+
+	// DeleteRetryBackoff is the delay before the first retry, doubled for each next one.
+	DeleteRetryBackoff Duration `json:"delete_retry_backoff"`
+
+	// DeleteDeadLetterPath is the file batches that still fail after all
+	// retries are appended to. They are only logged when empty.
+	DeleteDeadLetterPath string `json:"delete_dead_letter_path"`
+
+	// DeleteJournalPath is the file queued deletions are persisted to until they
+	// are applied. It defaults to the storage file with a ".deletes" suffix for
+	// the file backend; the database backend uses the pending_deletes table.
+	DeleteJournalPath string `json:"delete_journal_path"`
+
+	// ClickFlushInterval is how often the clicks counted on redirects are
+	// written to the click store, aggregated by short URL.
+	ClickFlushInterval Duration `json:"click_flush_interval"`
+
+	// BlockedDomains lists destination domains that may not be shortened; an
+	// entry also blocks the subdomains of the domain.
+	BlockedDomains StringList `json:"blocked_domains"`
+
+	// BlocklistFile is a file of further blocked domains, one per line. It is
+	// read again when the configuration is reloaded.
+	BlocklistFile string `json:"blocklist_file"`
+
+	// AllowedDomains restricts shortening to URLs whose host matches one of
+	// the patterns: "example.com" matches that host, "*.example.com" its
+	// subdomains. Any domain may be shortened when it is empty.
+	AllowedDomains StringList `json:"allowed_domains"`
+
+	// ThreatFeedFile is a local threat feed of malicious domains and URLs,
+	// one per line, optionally followed by the threat type. Created short URLs
+	// pointing to them are disabled in the background.
+	ThreatFeedFile string `json:"threat_feed_file"`
+
+	// SafeBrowsingAPIKey enables checking created short URLs with the Google
+	// Safe Browsing Lookup API; flagged URLs are disabled in the background.
+	SafeBrowsingAPIKey string `json:"safe_browsing_api_key"`
+
+	// FetchTitles makes short URLs created without a title get the title of
+	// their page, fetched in the background. Pages on private addresses are
+	// not fetched.
+	FetchTitles bool `json:"fetch_titles"`
 
-	// Config is the path to the Config file.
-	Config string
+	// TemplateDir is a directory of HTML templates overriding the built-in pages
+	// shown instead of a redirect: not_found.html, gone.html, flagged.html and
+	// password.html. Further *.html files can hold shared templates.
+	TemplateDir string `json:"template_dir"`
+
+	// RedirectCode is the status code of redirects to the original URLs:
+	// 301, 302, 303, 307 or 308.
+	RedirectCode int `json:"redirect_code"`
+
+	// BrandName, BrandLogoURL and BrandColor brand the pages shown instead of
+	// a redirect with a name, a logo and a CSS accent color.
+	BrandName    string `json:"brand_name"`
+	BrandLogoURL string `json:"brand_logo_url"`
+	BrandColor   string `json:"brand_color"`
+
+	// Tenants configures further domains the service is reachable on, keyed by
+	// host name. Requests to other hosts use base_url, redirect_code and the
+	// brand options above. In environment variables it is a JSON object.
+	Tenants map[string]TenantOptions `json:"tenants"`
+
+	// LinkTTL is how long created short URLs stay valid; 0 keeps them forever.
+	LinkTTL Duration `json:"link_ttl"`
+
+	// StrictBatches rejects a whole batch shortening request if one of its
+	// URLs is invalid or exists, instead of returning a status for each URL.
+	StrictBatches bool `json:"strict_batches"`
+
+	// CaseInsensitiveShortURLs generates short URLs from digits and lowercase
+	// letters only and resolves them regardless of case, for links that are read
+	// aloud or typed. Short URLs with uppercase letters created without it can
+	// no longer be resolved once it is set.
+	CaseInsensitiveShortURLs bool `json:"case_insensitive_short_urls"`
+
+	// ShortURLAlphabet is the alphabet of generated short URLs: "base62", or
+	// "human-safe", which leaves out characters easily confused when printed,
+	// such as 0/O and 1/l/I.
+	ShortURLAlphabet string `json:"short_url_alphabet"`
+
+	// ShortURLLength is the number of characters of generated short URLs.
+	ShortURLLength int `json:"short_url_length"`
+
+	// SweepInterval is how often expired short URLs are looked for and deleted.
+	SweepInterval Duration `json:"sweep_interval"`
+
+	// SweepBatchSize is the number of expired short URLs deleted at once.
+	SweepBatchSize int `json:"sweep_batch_size"`
+
+	// StatsRefresh is how often the totals returned by the stats endpoint are
+	// recomputed in the background; 0 counts them on every request.
+	StatsRefresh Duration `json:"stats_refresh"`
+
+	// LinkCacheSize is the number of URL records cached in front of the
+	// storage for redirects; 0 disables the cache.
+	LinkCacheSize int `json:"link_cache_size"`
+	// LinkCacheTTL is how long a cached URL record is used at most.
+	LinkCacheTTL Duration `json:"link_cache_ttl"`
+}
+
+// TenantOptions holds the configuration of a tenant. Empty options fall back
+// to the ones of the default tenant, except for the base URL, which defaults
+// to the host of the tenant.
+type TenantOptions struct {
+	// BaseURL is the base URL used for result links of the tenant.
+	BaseURL string `json:"base_url"`
+
+	// RedirectCode is the status code of redirects of the tenant.
+	RedirectCode int `json:"redirect_code"`
+
+	// BrandName, BrandLogoURL and BrandColor brand the pages of the tenant.
+	BrandName    string `json:"brand_name"`
+	BrandLogoURL string `json:"brand_logo_url"`
+	BrandColor   string `json:"brand_color"`
+}
+
+// Duration is a time.Duration that is written in configuration files, flags
+// and environment variables in time.ParseDuration format, for example "3s".
+type Duration struct {
+	time.Duration
+}
+
+// String returns the duration in time.ParseDuration format.
+func (d *Duration) String() string {
+	return d.Duration.String()
+}
+
+// Set parses v with time.ParseDuration.
+func (d *Duration) Set(v string) error {
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// UnmarshalJSON reads the duration from a JSON string such as "3s".
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return d.Set(v)
+}
+
+// MarshalJSON writes the duration as a JSON string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Duration.String())
+}
+
+// StringList is a list of strings that can be set from a comma-separated
+// command-line flag or environment variable.
+type StringList []string
+
+// String returns the list joined with commas.
+func (l *StringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set replaces the list with the comma-separated values of v.
+func (l *StringList) Set(v string) error {
+	*l = splitList(v)
+	return nil
+}
+
+// splitList splits a comma-separated string, trimming spaces and dropping empty items.
+func splitList(v string) []string {
+	res := make([]string, 0)
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
 }
 
 // options holds the current configuration values.
 var options = &Options{}
 
+// parsed holds the values set by command-line flags and their defaults, before
+// the configuration file and environment variables are applied.
+var parsed Options
+
 // init initializes command-line flags and sets default values.
 func init() {
 	flag.StringVar(&options.Port, "a", "localhost:8080", "run on ip:port server")
 	flag.StringVar(&options.ResultHostname, "b", "http://localhost:8080", "result base url")
 	flag.StringVar(&options.FilePath, "f", "", "path to storage file")
 	flag.StringVar(&options.DatabaseDSN, "d", "", "db address")
+	flag.StringVar(&options.DatabaseReplicaDSN, "database-replica-dsn", "", "address of a read-only db replica to read from, empty to read from the primary db")
 	flag.BoolVar(&options.EnablePprof, "p", false, "enable pprof")
+	flag.StringVar(&options.PprofAddr, "pprof-addr", "localhost:6060", "address of the pprof listener, empty to serve pprof on the main router only")
+	flag.BoolVar(&options.PprofOnRouter, "pprof-on-router", false, "serve pprof on the main router to the trusted subnet")
+	flag.StringVar(&options.ProfileDir, "profile-dir", "", "directory to store profiles captured through the internal API, empty to send them back")
+	flag.Var(&options.TrustedProxies, "trusted-proxies", "comma-separated subnets (CIDR) of reverse proxies whose X-Forwarded-For is trusted")
+	flag.StringVar(&options.IPAnonymization, "ip-anonymization", "", "anonymize logged client IPs (truncate or hash)")
+	flag.StringVar(&options.IPHashKey, "ip-hash-key", "", "key client IPs are hashed with")
+	flag.Var(&options.TrustedSubnets, "t", "comma-separated trusted subnets (CIDR) allowed to use internal endpoints")
 	flag.BoolVar(&options.EnableHTTPS, "s", false, "enable https")
-	flag.StringVar(&options.Config, "config", "config.json", "path to config file")
-	flag.StringVar(&options.Config, "c", "config.json", "path to config file (shorthand)")
+	flag.StringVar(&options.UnixSocket, "unix-socket", "", "unix domain socket to listen on instead of the TCP address")
+	flag.BoolVar(&options.SystemdActivation, "systemd-activation", false, "listen on a socket passed by systemd socket activation")
+	flag.StringVar(&options.SystemdSocketName, "systemd-socket-name", "", "FileDescriptorName of the systemd socket to listen on")
+	flag.BoolVar(&options.EnableH2C, "h2c", false, "serve HTTP/2 over cleartext connections")
+	flag.StringVar(&options.TLSCertFile, "tls-cert-file", "", "PEM certificate chain to serve https with instead of autocert")
+	flag.StringVar(&options.TLSKeyFile, "tls-key-file", "", "PEM private key of the https certificate")
+	flag.Var(&options.AutocertDomains, "autocert-domains", "comma-separated host names to obtain certificates for with autocert")
+	flag.StringVar(&options.AutocertCacheDir, "autocert-cache-dir", "cache-dir", "directory to keep autocert certificates in")
+	flag.StringVar(&options.ACMEDirectoryURL, "acme-directory-url", "", "directory URL of the ACME CA, Let's Encrypt if empty")
+	flag.StringVar(&options.Config, "config", "", "path to config file (JSON, YAML or TOML)")
+	flag.StringVar(&options.Config, "c", "", "path to config file (shorthand)")
+	flag.StringVar(&options.IssueAdminToken, "issue-admin-token", "", "print an admin JWT for the given user ID and exit")
+
+	options.CORSAllowedMethods = StringList{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	options.CORSAllowedHeaders = StringList{"Content-Type", "Content-Encoding", "If-None-Match", "Authorization", "Idempotency-Key", "X-Link-Password"}
+	flag.Var(&options.CORSAllowedOrigins, "cors-origins", "comma-separated origins allowed for CORS")
+	flag.Var(&options.CORSAllowedMethods, "cors-methods", "comma-separated methods allowed for CORS")
+	flag.Var(&options.CORSAllowedHeaders, "cors-headers", "comma-separated headers allowed for CORS")
+	flag.BoolVar(&options.CORSAllowCredentials, "cors-credentials", false, "allow credentials in CORS requests")
+
+	options.RequestTimeout = Duration{3 * time.Second}
+	options.ShortenTimeout = Duration{3 * time.Second}
+	options.BatchTimeout = Duration{10 * time.Second}
+	options.UserURLsTimeout = Duration{3 * time.Second}
+	flag.Var(&options.RequestTimeout, "request-timeout", "default request timeout")
+	flag.Var(&options.ShortenTimeout, "shorten-timeout", "timeout of URL shortening requests")
+	flag.Var(&options.BatchTimeout, "batch-timeout", "timeout of batch URL shortening requests")
+	options.IdempotencyKeyTTL = Duration{24 * time.Hour}
+	flag.Var(&options.IdempotencyKeyTTL, "idempotency-key-ttl", "how long responses of shortening requests with an Idempotency-Key header are replayed, 0 to ignore the header")
+	flag.Var(&options.UserURLsTimeout, "user-urls-timeout", "timeout of user URL listing requests")
+
+	flag.IntVar(&options.MaxURLsPerUser, "max-urls-per-user", 0, "maximum number of active URLs per user, 0 for unlimited")
+	flag.IntVar(&options.RateLimit, "rate-limit", 0, "maximum number of API requests per client IP in every rate limit window, 0 for unlimited")
+	options.RateLimitWindow = Duration{time.Minute}
+	flag.Var(&options.RateLimitWindow, "rate-limit-window", "time window of the rate limit")
+	flag.IntVar(&options.AbuseURLsPerMinute, "abuse-urls-per-minute", 0, "number of URLs per minute a user or client IP may create before being banned, 0 to disable bans")
+	options.AbuseBanDuration = Duration{time.Hour}
+	flag.Var(&options.AbuseBanDuration, "abuse-ban-duration", "how long users and client IPs creating URLs too fast are banned")
+
+	flag.StringVar(&options.JWTSecret, "jwt-secret", "", "key used to sign JWT tokens")
+	flag.Var(&options.JWTPreviousSecrets, "jwt-previous-secrets", "comma-separated previous JWT keys still accepted for verification")
+	flag.StringVar(&options.JWTKeyFile, "jwt-key-file", "", "path to PEM private key (RSA or Ed25519) used to sign JWT tokens")
+	flag.Var(&options.JWTPublicKeyFiles, "jwt-public-key-files", "comma-separated paths to PEM public keys still accepted for verification")
+
+	flag.StringVar(&options.EventBusURL, "event-bus-url", "", "broker URL to publish URL events to (nats://host:port)")
+	flag.StringVar(&options.EventSubjectPrefix, "event-subject-prefix", "shortener.urls", "prefix of the subjects URL events are published to")
+
+	flag.StringVar(&options.OTLPEndpoint, "otlp-endpoint", "", "OTLP/HTTP collector to export traces to (http://host:4318)")
+	flag.StringVar(&options.TracingServiceName, "tracing-service-name", "go-url-shortener", "service name reported with exported traces")
+
+	options.LogOutputPaths = StringList{"stderr"}
+	flag.StringVar(&options.LogLevel, "log-level", "info", "minimum log level (debug, info, warn, error)")
+	flag.StringVar(&options.LogFormat, "log-format", "json", "log encoding (json or console)")
+	flag.BoolVar(&options.LogSampling, "log-sampling", true, "sample repeated log entries")
+	flag.Var(&options.LogOutputPaths, "log-output-paths", "comma-separated files or stdout/stderr to write logs to")
+	flag.StringVar(&options.LogFile, "log-file", "", "rotated log file to write logs to in addition to the output paths")
+	flag.IntVar(&options.LogMaxSizeMB, "log-max-size-mb", 100, "size in megabytes after which the log file is rotated, 0 to disable")
+	flag.Var(&options.LogMaxAge, "log-max-age", "how long rotated log files are kept, 0 to keep them regardless of age")
+	flag.IntVar(&options.LogMaxBackups, "log-max-backups", 5, "number of rotated log files kept, 0 to keep all")
+
+	flag.StringVar(&options.SentryDSN, "sentry-dsn", "", "DSN of a Sentry-compatible project to report errors to")
+	flag.StringVar(&options.SentryEnvironment, "sentry-environment", "", "environment reported with errors")
+
+	options.SlowQueryThreshold = Duration{200 * time.Millisecond}
+	flag.Var(&options.SlowQueryThreshold, "slow-query-threshold", "duration above which database operations are logged, 0 to disable")
+
+	flag.IntVar(&options.DatabaseMaxRetries, "database-max-retries", 2, "number of retries of database calls failing with transient errors, 0 to disable")
+	options.DatabaseRetryBackoff = Duration{50 * time.Millisecond}
+	flag.Var(&options.DatabaseRetryBackoff, "database-retry-backoff", "delay before the first retry of a database call, doubled for each next one")
+	flag.IntVar(&options.DatabaseBreakerThreshold, "database-breaker-threshold", 5, "number of consecutive failed database calls after which calls fail fast, 0 to disable")
+	options.DatabaseBreakerCooldown = Duration{10 * time.Second}
+	flag.Var(&options.DatabaseBreakerCooldown, "database-breaker-cooldown", "how long database calls fail fast before the database is tried again")
+
+	options.DeleteFlushInterval = Duration{10 * time.Second}
+	flag.IntVar(&options.DeleteBatchSize, "delete-batch-size", 25, "number of queued deletions that triggers an early flush")
+	flag.Var(&options.DeleteFlushInterval, "delete-flush-interval", "how often queued deletions are flushed")
+	flag.IntVar(&options.DeleteWorkers, "delete-workers", 1, "number of goroutines processing queued deletions")
+
+	options.DeleteRetryBackoff = Duration{100 * time.Millisecond}
+	flag.IntVar(&options.DeleteMaxRetries, "delete-max-retries", 3, "number of retries of a failed deletion batch, 0 to disable")
+	flag.Var(&options.DeleteRetryBackoff, "delete-retry-backoff", "delay before the first retry of a failed deletion batch")
+	flag.StringVar(&options.DeleteDeadLetterPath, "delete-dead-letter-path", "", "file to store deletion batches that keep failing")
+	flag.StringVar(&options.DeleteJournalPath, "delete-journal-path", "", "file to persist queued deletions to (file storage only)")
+
+	options.ClickFlushInterval = Duration{5 * time.Second}
+	flag.Var(&options.ClickFlushInterval, "click-flush-interval", "how often buffered click counts are written to the click store")
+
+	flag.Var(&options.BlockedDomains, "blocked-domains", "comma-separated destination domains that may not be shortened")
+	flag.Var(&options.AllowedDomains, "allowed-domains", "comma-separated domain patterns (example.com, *.example.com) shortening is restricted to")
+	flag.StringVar(&options.BlocklistFile, "blocklist-file", "", "file of destination domains that may not be shortened, one per line")
+	flag.StringVar(&options.ThreatFeedFile, "threat-feed-file", "", "file of malicious domains and URLs created short URLs are checked against")
+	flag.StringVar(&options.SafeBrowsingAPIKey, "safe-browsing-api-key", "", "Google Safe Browsing API key created short URLs are checked with")
+	flag.BoolVar(&options.FetchTitles, "fetch-titles", false, "fetch the page titles of short URLs created without a title")
+
+	flag.StringVar(&options.TemplateDir, "template-dir", "", "directory of HTML templates overriding the pages of unknown, deleted and protected short URLs")
+
+	flag.IntVar(&options.RedirectCode, "redirect-code", 307, "status code of redirects (301, 302, 303, 307 or 308)")
+	flag.StringVar(&options.BrandName, "brand-name", "", "name shown in the header of the pages shown instead of a redirect")
+	flag.StringVar(&options.BrandLogoURL, "brand-logo-url", "", "logo shown in the header of the pages shown instead of a redirect")
+	flag.StringVar(&options.BrandColor, "brand-color", "", "CSS accent color of the pages shown instead of a redirect")
+
+	options.SweepInterval = Duration{time.Minute}
+	flag.Var(&options.LinkTTL, "link-ttl", "how long created short URLs stay valid, 0 to keep them forever")
+	flag.BoolVar(&options.StrictBatches, "strict-batches", false, "reject a whole batch if one of its URLs is invalid or exists")
+	flag.BoolVar(&options.CaseInsensitiveShortURLs, "case-insensitive-short-urls", false, "generate lowercase short URLs and resolve short URLs regardless of case")
+	flag.StringVar(&options.ShortURLAlphabet, "short-url-alphabet", "base62", "alphabet of generated short URLs (base62 or human-safe)")
+	flag.IntVar(&options.ShortURLLength, "short-url-length", 8, "number of characters of generated short URLs")
+	flag.Var(&options.SweepInterval, "sweep-interval", "how often expired short URLs are deleted")
+	flag.IntVar(&options.SweepBatchSize, "sweep-batch-size", 100, "number of expired short URLs deleted at once")
+
+	options.StatsRefresh = Duration{time.Minute}
+	flag.Var(&options.StatsRefresh, "stats-refresh", "how often the totals of the stats endpoint are recomputed, 0 to count them on every request")
+
+	flag.IntVar(&options.LinkCacheSize, "link-cache-size", 0, "number of URL records cached for redirects, 0 to disable the cache")
+	options.LinkCacheTTL = Duration{time.Minute}
+	flag.Var(&options.LinkCacheTTL, "link-cache-ttl", "how long a cached URL record is used at most")
 }
 
-// Parse parses the command-line flags and environment variables to set
-// configuration values. It returns a pointer to the Options struct containing
-// the parsed configuration values.
+// Parse parses the command-line flags, the configuration file and environment
+// variables to set configuration values. It returns a pointer to the Options
+// struct containing the parsed configuration values.
+//
+// Later sources take precedence: flags (and their defaults) are overridden by
+// the keys present in the configuration file given with -c/-config or
+// SHORTENER_CONFIG, which are in turn overridden by environment variables. The
+// file format is chosen by its extension: .yaml or .yml for YAML, .toml for
+// TOML and JSON otherwise. All formats use the same snake_case keys.
+//
+// Every option with a file key can be set with the environment variable named
+// after the key, upper-cased and prefixed with EnvPrefix, such as
+// SHORTENER_DATABASE_DSN. The variables read by earlier versions, such as
+// SERVER_ADDRESS, are still accepted.
 func Parse() *Options {
 	flag.Parse()
+	parsed = *options
 
-	// Override flags with environment variables if set
-	if configPath := os.Getenv("CONFIG"); configPath != "" {
-		options.Config = configPath
+	if err := applyFile(options); err != nil {
+		log.Fatalf("error while reading config file: %v", err)
 	}
-
-	if options.Config != "" {
-		data, err := os.ReadFile(options.Config)
-		if err != nil {
-			log.Fatalf("error while reading config file: %v", err)
-		}
-		if err := json.Unmarshal(data, options); err != nil {
-			log.Fatalf("error while parsing config file: %v", err)
-		}
+	if err := applyEnv(options); err != nil {
+		log.Fatal(err)
 	}
-
-	if serverAddress := os.Getenv("SERVER_ADDRESS"); serverAddress != "" {
-		options.Port = serverAddress
+	if err := validate(options); err != nil {
+		log.Fatal(err)
 	}
+	return options
+}
 
-	if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
-		options.ResultHostname = baseURL
-	}
+// ErrCORSWildcardCredentials is returned for CORS configurations allowing
+// credentials from any origin, which would let every site read the responses
+// to requests made with the cookies of its visitors.
+var ErrCORSWildcardCredentials = errors.New(`cors_allow_credentials cannot be combined with the "*" origin`)
 
-	if storagePath := os.Getenv("FILE_STORAGE_PATH"); storagePath != "" {
-		options.FilePath = storagePath
+// validate checks that the options are consistent.
+func validate(o *Options) error {
+	if o.CORSAllowCredentials && slices.Contains(o.CORSAllowedOrigins, "*") {
+		return ErrCORSWildcardCredentials
 	}
+	return nil
+}
 
-	if enableHTTPS := os.Getenv("ENABLE_HTTPS"); enableHTTPS != "" {
-		httpMode, err := strconv.ParseBool(enableHTTPS)
-		if err != nil {
-			options.EnableHTTPS = false
-		}
-
-		options.EnableHTTPS = httpMode
+// applyFile overrides o with the configuration file named by SHORTENER_CONFIG,
+// CONFIG or o.Config, if any.
+func applyFile(o *Options) error {
+	if _, configPath, ok := lookupEnv("config"); ok {
+		o.Config = configPath
 	}
 
-	return options
+	if o.Config == "" {
+		return nil
+	}
+	return loadFile(o.Config, o)
 }
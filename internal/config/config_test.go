@@ -0,0 +1,24 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, validate(&Options{CORSAllowedOrigins: StringList{"*"}}))
+	assert.NoError(t, validate(&Options{CORSAllowedOrigins: StringList{"https://app.example.com"}, CORSAllowCredentials: true}))
+
+	// Any site could read the responses to requests sent with the cookies of its visitors
+	err := validate(&Options{CORSAllowedOrigins: StringList{"https://app.example.com", "*"}, CORSAllowCredentials: true})
+	assert.ErrorIs(t, err, ErrCORSWildcardCredentials)
+}
+
+func TestCORSDefaults(t *testing.T) {
+	// Preflights of the routes taking PUT and the headers of the API succeed by default
+	assert.Contains(t, options.CORSAllowedMethods, "PUT")
+	for _, h := range []string{"Authorization", "Idempotency-Key", "X-Link-Password"} {
+		assert.Contains(t, options.CORSAllowedHeaders, h)
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is prepended to the upper-cased configuration file key of an
+// option to form its environment variable, for example SHORTENER_DATABASE_DSN
+// for database_dsn.
+const EnvPrefix = "SHORTENER_"
+
+// legacyEnv maps configuration file keys to the environment variables read
+// before EnvPrefix was introduced. They are still accepted; the prefixed
+// variable wins when both are set.
+var legacyEnv = map[string]string{
+	"config":                  "CONFIG",
+	"server_address":          "SERVER_ADDRESS",
+	"base_url":                "BASE_URL",
+	"file_storage_path":       "FILE_STORAGE_PATH",
+	"enable_https":            "ENABLE_HTTPS",
+	"cors_allowed_origins":    "CORS_ALLOWED_ORIGINS",
+	"cors_allowed_methods":    "CORS_ALLOWED_METHODS",
+	"cors_allowed_headers":    "CORS_ALLOWED_HEADERS",
+	"cors_allow_credentials":  "CORS_ALLOW_CREDENTIALS",
+	"request_timeout":         "REQUEST_TIMEOUT",
+	"shorten_timeout":         "SHORTEN_TIMEOUT",
+	"batch_timeout":           "BATCH_TIMEOUT",
+	"user_urls_timeout":       "USER_URLS_TIMEOUT",
+	"max_urls_per_user":       "MAX_URLS_PER_USER",
+	"jwt_secret":              "JWT_SECRET",
+	"jwt_previous_secrets":    "JWT_PREVIOUS_SECRETS",
+	"jwt_key_file":            "JWT_KEY_FILE",
+	"jwt_public_key_files":    "JWT_PUBLIC_KEY_FILES",
+	"event_bus_url":           "EVENT_BUS_URL",
+	"event_subject_prefix":    "EVENT_SUBJECT_PREFIX",
+	"otlp_endpoint":           "OTEL_EXPORTER_OTLP_ENDPOINT",
+	"tracing_service_name":    "OTEL_SERVICE_NAME",
+	"log_level":               "LOG_LEVEL",
+	"log_format":              "LOG_FORMAT",
+	"log_sampling":            "LOG_SAMPLING",
+	"log_output_paths":        "LOG_OUTPUT_PATHS",
+	"sentry_dsn":              "SENTRY_DSN",
+	"sentry_environment":      "SENTRY_ENVIRONMENT",
+	"slow_query_threshold":    "SLOW_QUERY_THRESHOLD",
+	"delete_batch_size":       "DELETE_BATCH_SIZE",
+	"delete_flush_interval":   "DELETE_FLUSH_INTERVAL",
+	"delete_workers":          "DELETE_WORKERS",
+	"delete_max_retries":      "DELETE_MAX_RETRIES",
+	"delete_retry_backoff":    "DELETE_RETRY_BACKOFF",
+	"delete_dead_letter_path": "DELETE_DEAD_LETTER_PATH",
+	"delete_journal_path":     "DELETE_JOURNAL_PATH",
+	"link_ttl":                "LINK_TTL",
+	"sweep_interval":          "SWEEP_INTERVAL",
+	"sweep_batch_size":        "SWEEP_BATCH_SIZE",
+}
+
+// positiveEnv lists the integer options that must be greater than zero; the
+// other integer options must not be negative.
+var positiveEnv = map[string]bool{
+	"delete_batch_size": true,
+	"delete_workers":    true,
+	"sweep_batch_size":  true,
+	"short_url_length":  true,
+}
+
+// EnvName returns the environment variable of the option with the given
+// configuration file key.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(key)
+}
+
+// lookupEnv returns the first non-empty environment variable setting the
+// option with the given key, trying the prefixed name before the legacy one.
+func lookupEnv(key string) (name, value string, ok bool) {
+	names := []string{EnvName(key)}
+	if legacy, found := legacyEnv[key]; found {
+		names = append(names, legacy)
+	}
+
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return name, value, true
+		}
+	}
+	return "", "", false
+}
+
+// applyEnv overrides every option of o that has a configuration file key with
+// its environment variable, if set.
+func applyEnv(o *Options) error {
+	v := reflect.ValueOf(o).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("json")
+		if key == "" || key == "-" {
+			continue
+		}
+
+		name, value, ok := lookupEnv(key)
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(i), key, value); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", name, value, err)
+		}
+	}
+	return nil
+}
+
+// setField parses value into the option field with the given key.
+func setField(f reflect.Value, key, value string) error {
+	// Durations and lists parse themselves as they do for flags
+	if fv, ok := f.Addr().Interface().(flag.Value); ok {
+		return fv.Set(value)
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if n < 0 || (n == 0 && positiveEnv[key]) {
+			return errors.New("out of range")
+		}
+		f.SetInt(int64(n))
+	case reflect.Map:
+		// Maps, such as the tenants, are JSON objects as in the configuration file
+		m := reflect.New(f.Type())
+		if err := json.Unmarshal([]byte(value), m.Interface()); err != nil {
+			return err
+		}
+		f.Set(m.Elem())
+	default:
+		return fmt.Errorf("unsupported option type %s", f.Type())
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("SHORTENER_DATABASE_DSN", "postgres://localhost/db")
+	t.Setenv("SHORTENER_ENABLE_PPROF", "true")
+	t.Setenv("SHORTENER_CORS_ALLOWED_ORIGINS", "https://a.example.com, https://b.example.com")
+	t.Setenv("SHORTENER_LINK_TTL", "24h")
+	t.Setenv("SHORTENER_DELETE_WORKERS", "4")
+	t.Setenv("SHORTENER_TENANTS", `{"go.example.com": {"base_url": "https://go.example.com", "redirect_code": 301}}`)
+
+	// Legacy names are aliases, the prefixed name wins when both are set
+	t.Setenv("SERVER_ADDRESS", "localhost:9090")
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("SHORTENER_LOG_LEVEL", "warn")
+	t.Setenv("OTEL_SERVICE_NAME", "shortener")
+
+	opts := Options{ResultHostname: "http://localhost:8080"}
+	require.NoError(t, applyEnv(&opts))
+
+	assert.Equal(t, "postgres://localhost/db", opts.DatabaseDSN)
+	assert.True(t, opts.EnablePprof)
+	assert.Equal(t, StringList{"https://a.example.com", "https://b.example.com"}, opts.CORSAllowedOrigins)
+	assert.Equal(t, Duration{24 * time.Hour}, opts.LinkTTL)
+	assert.Equal(t, 4, opts.DeleteWorkers)
+	assert.Equal(t, map[string]TenantOptions{"go.example.com": {BaseURL: "https://go.example.com", RedirectCode: 301}}, opts.Tenants)
+	assert.Equal(t, "localhost:9090", opts.Port)
+	assert.Equal(t, "warn", opts.LogLevel)
+	assert.Equal(t, "shortener", opts.TracingServiceName)
+	assert.Equal(t, "http://localhost:8080", opts.ResultHostname)
+}
+
+func TestApplyEnv_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"SHORTENER_ENABLE_HTTPS":       "maybe",
+		"SHORTENER_REQUEST_TIMEOUT":    "soon",
+		"SHORTENER_MAX_URLS_PER_USER":  "-1",
+		"DELETE_BATCH_SIZE":            "0",
+		"SHORTENER_DELETE_MAX_RETRIES": "many",
+		"SHORTENER_TENANTS":            "go.example.com",
+	}
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			err := applyEnv(&Options{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), name)
+		})
+	}
+}
+
+func TestLegacyEnv_KnownKeys(t *testing.T) {
+	// Every legacy name must belong to an option that is still read
+	keys := map[string]bool{"config": true}
+	typ := reflect.TypeOf(Options{})
+	for i := 0; i < typ.NumField(); i++ {
+		keys[typ.Field(i).Tag.Get("json")] = true
+	}
+	for key := range legacyEnv {
+		assert.True(t, keys[key], key)
+	}
+}
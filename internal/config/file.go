@@ -0,0 +1,46 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile reads the configuration file at path into dst. Only the keys
+// present in the file are changed. YAML and TOML documents are converted to
+// JSON first, so that every format shares the json tags and types of Options.
+func loadFile(path string, dst *Options) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var doc map[string]any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parse YAML: %w", err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return fmt.Errorf("convert YAML: %w", err)
+		}
+	case ".toml":
+		var doc map[string]any
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parse TOML: %w", err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return fmt.Errorf("convert TOML: %w", err)
+		}
+	}
+
+	if err := json.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
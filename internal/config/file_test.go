@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	files := map[string]string{
+		"config.json": `{
+			"server_address": "localhost:9090",
+			"database_dsn": "postgres://localhost/db",
+			"request_timeout": "3s",
+			"cors_allowed_origins": ["https://a.example.com", "https://b.example.com"],
+			"max_urls_per_user": 10,
+			"tenants": {"go.example.com": {"redirect_code": 301}}
+		}`,
+		"config.yaml": `
+server_address: localhost:9090
+database_dsn: postgres://localhost/db
+request_timeout: 3s
+cors_allowed_origins:
+  - https://a.example.com
+  - https://b.example.com
+max_urls_per_user: 10
+tenants:
+  go.example.com:
+    redirect_code: 301
+`,
+		"config.toml": `
+server_address = "localhost:9090"
+database_dsn = "postgres://localhost/db"
+request_timeout = "3s"
+cors_allowed_origins = ["https://a.example.com", "https://b.example.com"]
+max_urls_per_user = 10
+
+[tenants."go.example.com"]
+redirect_code = 301
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			// Keys missing from the file keep their values
+			opts := Options{ResultHostname: "http://localhost:8080", LogLevel: "info"}
+			require.NoError(t, loadFile(writeConfig(t, name, content), &opts))
+
+			assert.Equal(t, "localhost:9090", opts.Port)
+			assert.Equal(t, "postgres://localhost/db", opts.DatabaseDSN)
+			assert.Equal(t, Duration{3 * time.Second}, opts.RequestTimeout)
+			assert.Equal(t, StringList{"https://a.example.com", "https://b.example.com"}, opts.CORSAllowedOrigins)
+			assert.Equal(t, 10, opts.MaxURLsPerUser)
+			assert.Equal(t, map[string]TenantOptions{"go.example.com": {RedirectCode: 301}}, opts.Tenants)
+			assert.Equal(t, "http://localhost:8080", opts.ResultHostname)
+			assert.Equal(t, "info", opts.LogLevel)
+		})
+	}
+}
+
+func TestLoadFile_YMLExtension(t *testing.T) {
+	var opts Options
+	require.NoError(t, loadFile(writeConfig(t, "config.YML", "base_url: http://short.example.com\n"), &opts))
+	assert.Equal(t, "http://short.example.com", opts.ResultHostname)
+}
+
+func TestLoadFile_Errors(t *testing.T) {
+	tests := map[string]string{
+		"config.json": `{"server_address": `,
+		"config.yaml": "server_address: [unclosed\n",
+		"config.toml": "server_address = \n",
+		"bad.yaml":    "request_timeout: soon\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			var opts Options
+			assert.Error(t, loadFile(writeConfig(t, name, content), &opts))
+		})
+	}
+
+	var opts Options
+	assert.Error(t, loadFile(filepath.Join(t.TempDir(), "missing.yaml"), &opts))
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+)
+
+// Reloader re-reads the configuration file at runtime and notifies
+// subscribers when options that can be changed without a restart change.
+//
+// Only the options copied by reloadable are applied on reload; changes to the
+// others, such as the listen address or the storage backend, are ignored until
+// the server restarts.
+type Reloader struct {
+	mu          sync.Mutex      // Serializes reloads and guards the fields below
+	current     Options         // Options in effect
+	subscribers []func(Options) // Called with the new options after a change
+	listeners   []func(Options) // Called with the options after every reload
+}
+
+// NewReloader returns a Reloader starting from the options returned by Parse.
+func NewReloader(current *Options) *Reloader {
+	return &Reloader{current: *current}
+}
+
+// reloadable copies the options that can be changed at runtime from src to dst.
+func reloadable(dst, src *Options) {
+	dst.LogLevel = src.LogLevel
+	dst.EnablePprof = src.EnablePprof
+	dst.TrustedSubnets = src.TrustedSubnets
+	dst.TrustedProxies = src.TrustedProxies
+	dst.BlockedDomains = src.BlockedDomains
+	dst.BlocklistFile = src.BlocklistFile
+	dst.AllowedDomains = src.AllowedDomains
+	dst.MaxURLsPerUser = src.MaxURLsPerUser
+	dst.AbuseURLsPerMinute = src.AbuseURLsPerMinute
+	dst.AbuseBanDuration = src.AbuseBanDuration
+	dst.SlowQueryThreshold = src.SlowQueryThreshold
+	dst.RateLimit = src.RateLimit
+	dst.RateLimitWindow = src.RateLimitWindow
+}
+
+// Subscribe registers fn to be called with the new options after every reload
+// that changed them. Subscribers are called in registration order, one reload
+// at a time.
+func (r *Reloader) Subscribe(fn func(Options)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// OnReload registers fn to be called with the options in effect after every
+// successful reload, even if they did not change, for subscribers that read
+// further files named by the options, such as the blocklist file.
+func (r *Reloader) OnReload(fn func(Options)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.listeners = append(r.listeners, fn)
+}
+
+// Current returns the options in effect.
+func (r *Reloader) Current() Options {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.current
+}
+
+// Reload re-applies the configuration file and environment variables on top of
+// the flags, notifies the subscribers if a reloadable option changed and then
+// the OnReload listeners. It reports whether the options changed. On error the options in effect are kept.
+func (r *Reloader) Reload() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next := parsed
+	if err := applyFile(&next); err != nil {
+		return false, err
+	}
+	if err := applyEnv(&next); err != nil {
+		return false, err
+	}
+
+	updated := r.current
+	reloadable(&updated, &next)
+	changed := !reflect.DeepEqual(updated, r.current)
+
+	r.current = updated
+	if changed {
+		for _, fn := range r.subscribers {
+			fn(updated)
+		}
+	}
+	for _, fn := range r.listeners {
+		fn(updated)
+	}
+	return changed, nil
+}
+
+// Watch reloads the options every time the process receives SIGHUP, until ctx
+// is done. The result of every reload is passed to onReload.
+func (r *Reloader) Watch(ctx context.Context, onReload func(changed bool, err error)) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			onReload(r.Reload())
+		}
+	}
+}
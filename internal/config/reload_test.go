@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+server_address: localhost:9090
+log_level: info
+`)
+
+	saved := parsed
+	t.Cleanup(func() { parsed = saved })
+	parsed = Options{Port: "localhost:8080", LogLevel: "info", Config: path}
+
+	current := parsed
+	require.NoError(t, loadFile(path, &current))
+	r := NewReloader(&current)
+
+	var got []Options
+	r.Subscribe(func(o Options) { got = append(got, o) })
+	reloads := 0
+	r.OnReload(func(o Options) { reloads++ })
+
+	// Nothing changed
+	changed, err := r.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, got)
+	assert.Equal(t, 1, reloads)
+
+	// Reloadable options are applied, the others are kept until a restart
+	require.NoError(t, os.WriteFile(path, []byte(`
+server_address: localhost:7070
+log_level: debug
+max_urls_per_user: 5
+abuse_urls_per_minute: 300
+slow_query_threshold: 1s
+rate_limit: 100
+rate_limit_window: 30s
+`), 0600))
+	changed, err = r.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	require.Len(t, got, 1)
+	assert.Equal(t, "debug", got[0].LogLevel)
+	assert.Equal(t, 5, got[0].MaxURLsPerUser)
+	assert.Equal(t, 300, got[0].AbuseURLsPerMinute)
+	assert.Equal(t, Duration{time.Second}, got[0].SlowQueryThreshold)
+	assert.Equal(t, 100, got[0].RateLimit)
+	assert.Equal(t, Duration{30 * time.Second}, got[0].RateLimitWindow)
+	assert.Equal(t, "localhost:9090", got[0].Port)
+	assert.Equal(t, got[0], r.Current())
+
+	// A broken file keeps the options in effect
+	require.NoError(t, os.WriteFile(path, []byte("log_level: [\n"), 0600))
+	_, err = r.Reload()
+	assert.Error(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, 2, reloads)
+	assert.Equal(t, "debug", r.Current().LogLevel)
+}
+
+func TestReloader_ReloadEnvOverridesFile(t *testing.T) {
+	path := writeConfig(t, "config.json", `{"log_level": "debug"}`)
+	t.Setenv("LOG_LEVEL", "error")
+
+	saved := parsed
+	t.Cleanup(func() { parsed = saved })
+	parsed = Options{LogLevel: "info", Config: path}
+
+	r := NewReloader(&Options{LogLevel: "info", Config: path})
+	changed, err := r.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "error", r.Current().LogLevel)
+}
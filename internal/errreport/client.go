@@ -0,0 +1,207 @@
+// Package errreport sends error reports to Sentry or a Sentry-compatible
+// service (such as GlitchTip) through the store API. Reports are queued and
+// delivered from a background goroutine, so reporting never blocks callers.
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// queueSize bounds the number of undelivered events; further events are dropped.
+	queueSize = 256
+	// sendTimeout bounds the delivery of a single event.
+	sendTimeout = 5 * time.Second
+	// clientName identifies the reporter in the authentication header.
+	clientName = "go-url-shortener/1.0"
+)
+
+// Exception describes the error an event was reported for.
+type Exception struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Event is an error report in the Sentry event format.
+type Event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger,omitempty"`
+	Platform    string            `json:"platform"`
+	Message     string            `json:"message"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Exception   []Exception       `json:"exception,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]any    `json:"extra,omitempty"`
+}
+
+// Options configures a Client.
+type Options struct {
+	// DSN is the project DSN, "https://<public key>@<host>/<project id>".
+	DSN string
+
+	// Environment is reported with every event, for example "production".
+	Environment string
+
+	// Release is reported with every event, usually the build version.
+	Release string
+}
+
+// Client delivers events to the store endpoint of a project.
+type Client struct {
+	endpoint    string
+	auth        string
+	environment string
+	release     string
+	http        *http.Client
+
+	queue     chan Event
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// New parses the DSN and starts the delivery goroutine.
+func New(o Options) (*Client, error) {
+	u, err := url.Parse(o.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID := strings.Trim(u.Path, "/")
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User == nil || u.User.Username() == "" || projectID == "" {
+		return nil, fmt.Errorf("errreport: invalid DSN")
+	}
+
+	// A path prefix before the project ID is kept, as for self-hosted setups
+	prefix, project := "", projectID
+	if i := strings.LastIndex(projectID, "/"); i >= 0 {
+		prefix, project = "/"+projectID[:i], projectID[i+1:]
+	}
+
+	auth := "Sentry sentry_version=7, sentry_client=" + clientName + ", sentry_key=" + u.User.Username()
+	if secret, ok := u.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+
+	c := &Client{
+		endpoint:    u.Scheme + "://" + u.Host + prefix + "/api/" + project + "/store/",
+		auth:        auth,
+		environment: o.Environment,
+		release:     o.Release,
+		http:        &http.Client{Timeout: sendTimeout},
+		queue:       make(chan Event, queueSize),
+	}
+
+	c.wg.Add(1)
+	go c.loop()
+	return c, nil
+}
+
+// Capture queues e for delivery, filling in the ID, time, platform,
+// environment and release. It reports false if the queue is full.
+func (c *Client) Capture(e Event) bool {
+	c.fill(&e)
+
+	select {
+	case c.queue <- e:
+		return true
+	default:
+		return false
+	}
+}
+
+// CaptureSync delivers e immediately, for reports made right before the
+// process exits.
+func (c *Client) CaptureSync(e Event) error {
+	c.fill(&e)
+	return c.send(e)
+}
+
+// fill sets the fields of e that were left empty to the client defaults.
+func (c *Client) fill(e *Event) {
+	if e.EventID == "" {
+		var id [16]byte
+		_, _ = rand.Read(id[:])
+		e.EventID = hex.EncodeToString(id[:])
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+	if e.Platform == "" {
+		e.Platform = "go"
+	}
+	if e.Environment == "" {
+		e.Environment = c.environment
+	}
+	if e.Release == "" {
+		e.Release = c.release
+	}
+}
+
+// loop delivers queued events until the queue is closed.
+func (c *Client) loop() {
+	defer c.wg.Done()
+	for e := range c.queue {
+		_ = c.send(e)
+	}
+}
+
+// send posts a single event to the store endpoint.
+func (c *Client) send(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", c.auth)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("errreport: server responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Close stops accepting events and waits until the queued ones are delivered
+// or ctx is done. Capture must not be called after Close.
+func (c *Client) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() { close(c.queue) })
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
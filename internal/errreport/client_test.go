@@ -0,0 +1,80 @@
+package errreport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSentry records the events posted to its store endpoint.
+func fakeSentry(t *testing.T) (*httptest.Server, chan Event, chan *http.Request) {
+	t.Helper()
+
+	events := make(chan Event, 8)
+	requests := make(chan *http.Request, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		requests <- r
+		events <- e
+	}))
+	t.Cleanup(srv.Close)
+	return srv, events, requests
+}
+
+func TestNewInvalidDSN(t *testing.T) {
+	for _, dsn := range []string{
+		"",
+		"not a dsn",
+		"ftp://key@example.com/1",
+		"https://example.com/1",
+		"https://key@example.com/",
+	} {
+		_, err := New(Options{DSN: dsn})
+		assert.Error(t, err, dsn)
+	}
+}
+
+func TestClientCapture(t *testing.T) {
+	srv, events, requests := fakeSentry(t)
+	dsn := strings.Replace(srv.URL, "://", "://public:secret@", 1) + "/sentry/42"
+
+	c, err := New(Options{DSN: dsn, Environment: "test", Release: "v1.2.3"})
+	require.NoError(t, err)
+
+	require.True(t, c.Capture(Event{Level: "error", Message: "boom"}))
+	require.NoError(t, c.Close(context.Background()))
+
+	r := <-requests
+	assert.Equal(t, "/sentry/api/42/store/", r.URL.Path)
+	assert.Contains(t, r.Header.Get("X-Sentry-Auth"), "sentry_key=public")
+	assert.Contains(t, r.Header.Get("X-Sentry-Auth"), "sentry_secret=secret")
+
+	e := <-events
+	assert.Equal(t, "boom", e.Message)
+	assert.Equal(t, "error", e.Level)
+	assert.Equal(t, "go", e.Platform)
+	assert.Equal(t, "test", e.Environment)
+	assert.Equal(t, "v1.2.3", e.Release)
+	assert.Len(t, e.EventID, 32)
+	assert.False(t, e.Timestamp.IsZero())
+}
+
+func TestClientCaptureSyncError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c, err := New(Options{DSN: strings.Replace(srv.URL, "://", "://public@", 1) + "/1"})
+	require.NoError(t, err)
+	defer c.Close(context.Background())
+
+	assert.Error(t, c.CaptureSync(Event{Message: "boom"}))
+}
@@ -0,0 +1,124 @@
+// Package errreport provides a zap core that reports log entries at error
+// level and above, so every error logged by handlers, the service layer and
+// background workers is reported together with its fields and request ID.
+package errreport
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// core is a zapcore.Core forwarding entries at error level and above to a Client.
+type core struct {
+	client *Client
+	fields []zapcore.Field
+}
+
+// NewCore returns a zap core reporting entries at error level and above to c.
+// It is meant to be combined with the regular core using zapcore.NewTee.
+func NewCore(c *Client) zapcore.Core {
+	return &core{client: c}
+}
+
+// WrapLogger returns l additionally reporting its errors to c.
+func WrapLogger(l *zap.Logger, c *Client) *zap.Logger {
+	return l.WithOptions(zap.WrapCore(func(existing zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(existing, NewCore(c))
+	}))
+}
+
+// Enabled implements zapcore.LevelEnabler.
+func (c *core) Enabled(l zapcore.Level) bool {
+	return l >= zapcore.ErrorLevel
+}
+
+// With returns a core carrying the additional fields, such as the request ID.
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	merged := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	merged = append(merged, c.fields...)
+	merged = append(merged, fields...)
+	return &core{client: c.client, fields: merged}
+}
+
+// Check adds the core to entries it reports.
+func (c *core) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+// Write converts the entry into an event. The request_id field becomes a tag
+// for searching, the error field becomes the exception and the remaining
+// fields are sent as extra data.
+func (c *core) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	event := Event{
+		Timestamp: e.Time.UTC(),
+		Level:     level(e.Level),
+		Logger:    e.LoggerName,
+		Message:   e.Message,
+		Tags:      map[string]string{},
+		Extra:     map[string]any{},
+	}
+
+	for k, v := range enc.Fields {
+		switch k {
+		case "request_id":
+			if id, ok := v.(string); ok {
+				event.Tags[k] = id
+				continue
+			}
+		case "error":
+			if msg, ok := v.(string); ok {
+				event.Exception = []Exception{{Type: "error", Value: msg}}
+				continue
+			}
+		}
+		event.Extra[k] = v
+	}
+
+	if e.Caller.Defined {
+		event.Extra["caller"] = e.Caller.TrimmedPath()
+	}
+	if e.Stack != "" {
+		event.Extra["stack"] = e.Stack
+	}
+
+	// Panic and fatal entries are followed by the end of the goroutine or process
+	if e.Level > zapcore.ErrorLevel {
+		_ = c.client.CaptureSync(event)
+		return nil
+	}
+
+	c.client.Capture(event)
+	return nil
+}
+
+// Sync implements zapcore.Core; delivery is flushed by Client.Close.
+func (c *core) Sync() error {
+	return nil
+}
+
+// level maps zap levels to Sentry levels.
+func level(l zapcore.Level) string {
+	switch l {
+	case zapcore.ErrorLevel:
+		return "error"
+	case zapcore.WarnLevel:
+		return "warning"
+	case zapcore.InfoLevel:
+		return "info"
+	case zapcore.DebugLevel:
+		return "debug"
+	default:
+		return "fatal"
+	}
+}
@@ -0,0 +1,38 @@
+package errreport
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+)
+
+func TestWrapLogger(t *testing.T) {
+	srv, events, _ := fakeSentry(t)
+
+	c, err := New(Options{DSN: strings.Replace(srv.URL, "://", "://public@", 1) + "/1"})
+	require.NoError(t, err)
+
+	l := WrapLogger(zap.NewNop(), c)
+	ctx := logger.WithRequestID(context.Background(), "req-1")
+
+	// Only entries at error level and above are reported
+	l.Info("ignored")
+	l.Warn("ignored")
+	logger.FromContext(ctx, l).Error("Cannot delete records", zap.Error(errors.New("db down")), zap.Int("count", 3))
+	require.NoError(t, c.Close(context.Background()))
+
+	e := <-events
+	assert.Equal(t, "Cannot delete records", e.Message)
+	assert.Equal(t, "error", e.Level)
+	assert.Equal(t, map[string]string{"request_id": "req-1"}, e.Tags)
+	assert.Equal(t, []Exception{{Type: "error", Value: "db down"}}, e.Exception)
+	assert.Equal(t, float64(3), e.Extra["count"])
+	assert.Empty(t, events)
+}
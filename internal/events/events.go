@@ -0,0 +1,37 @@
+// Package events provides message broker clients used to publish URL
+// lifecycle events.
+package events
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// Publisher publishes messages to broker subjects and releases the connection on Close.
+type Publisher interface {
+	// Publish sends payload to the subject.
+	Publish(ctx context.Context, subject string, payload []byte) error
+
+	// Close releases the connection to the broker.
+	Close() error
+}
+
+// New connects to the broker selected by the scheme of rawURL. Only NATS
+// ("nats://host:port") is supported so far; Kafka needs a client library that
+// is not vendored yet.
+func New(rawURL string) (Publisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "nats":
+		return NewNATSPublisher(rawURL)
+	case "kafka":
+		return nil, fmt.Errorf("events: kafka brokers are not supported yet")
+	default:
+		return nil, fmt.Errorf("events: unsupported broker scheme %q", u.Scheme)
+	}
+}
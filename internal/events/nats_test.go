@@ -0,0 +1,124 @@
+package events_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/events"
+)
+
+type message struct {
+	subject string
+	payload string
+}
+
+// fakeNATS accepts connections and reports every published message. The
+// connect channel receives the CONNECT options of each client.
+func fakeNATS(t *testing.T, reply string) (addr string, connects chan string, messages chan message) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	connects = make(chan string, 4)
+	messages = make(chan message, 16)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				fmt.Fprint(conn, "INFO {\"server_id\":\"test\"}\r\n")
+
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fields := strings.Fields(line)
+					if len(fields) == 0 {
+						continue
+					}
+					switch fields[0] {
+					case "CONNECT":
+						connects <- strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+					case "PING":
+						fmt.Fprint(conn, reply)
+					case "PUB":
+						n, _ := strconv.Atoi(fields[2])
+						buf := make([]byte, n+2)
+						if _, err := io.ReadFull(r, buf); err != nil {
+							return
+						}
+						messages <- message{subject: fields[1], payload: string(buf[:n])}
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().String(), connects, messages
+}
+
+func TestNATSPublisher(t *testing.T) {
+	addr, connects, messages := fakeNATS(t, "PONG\r\n")
+
+	p, err := events.NewNATSPublisher("nats://user:secret@" + addr)
+	require.NoError(t, err)
+	defer p.Close()
+
+	connect := <-connects
+	assert.Contains(t, connect, `"user":"user"`)
+	assert.Contains(t, connect, `"pass":"secret"`)
+	assert.Contains(t, connect, `"verbose":false`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, p.Publish(ctx, "shortener.urls.created", []byte(`{"type":"created"}`)))
+
+	select {
+	case m := <-messages:
+		assert.Equal(t, message{subject: "shortener.urls.created", payload: `{"type":"created"}`}, m)
+	case <-time.After(time.Second):
+		t.Fatal("message was not delivered")
+	}
+
+	assert.Error(t, p.Publish(ctx, "invalid subject", nil))
+
+	require.NoError(t, p.Close())
+	assert.ErrorIs(t, p.Publish(ctx, "shortener.urls.created", nil), events.ErrClosed)
+}
+
+func TestNATSPublisherAuthorizationError(t *testing.T) {
+	addr, _, _ := fakeNATS(t, "-ERR 'Authorization Violation'\r\n")
+
+	_, err := events.NewNATSPublisher("nats://" + addr)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Authorization Violation")
+}
+
+func TestNew(t *testing.T) {
+	_, err := events.New("kafka://localhost:9092")
+	assert.Error(t, err)
+
+	_, err = events.New("amqp://localhost")
+	assert.Error(t, err)
+
+	_, err = events.New("nats://")
+	assert.Error(t, err)
+}
@@ -0,0 +1,107 @@
+// Package listener opens the network listeners servers accept connections on:
+// a TCP address, a unix domain socket or a socket passed by systemd socket
+// activation.
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// firstActivationFD is the first file descriptor systemd passes sockets at.
+var firstActivationFD = 3
+
+// ErrNoActivation is returned when systemd socket activation is requested but
+// the process was not started with sockets passed by systemd.
+var ErrNoActivation = errors.New("no sockets passed by systemd")
+
+// Options selects the listener. Systemd socket activation takes precedence
+// over the unix socket, which takes precedence over the TCP address.
+type Options struct {
+	// Addr is the TCP address (ip:port) to listen on.
+	Addr string
+
+	// UnixSocket is the path of a unix domain socket to listen on. A socket
+	// left at the path by a previous run is replaced.
+	UnixSocket string
+
+	// SystemdActivation uses a socket passed by systemd socket activation.
+	SystemdActivation bool
+
+	// SocketName selects the passed socket by its FileDescriptorName; the
+	// first passed socket is used when empty.
+	SocketName string
+}
+
+// Listen opens the listener selected by o.
+func Listen(o Options) (net.Listener, error) {
+	switch {
+	case o.SystemdActivation:
+		return activated(o.SocketName)
+	case o.UnixSocket != "":
+		return listenUnix(o.UnixSocket)
+	default:
+		return net.Listen("tcp", o.Addr)
+	}
+}
+
+// listenUnix listens on the unix domain socket at path, removing a stale one.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Let the reverse proxy, usually running in the same group, connect
+	if err := os.Chmod(path, 0660); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// activated returns the socket passed by systemd with the given name, or the
+// first one if name is empty, as described in sd_listen_fds(3).
+func activated(name string) (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, ErrNoActivation
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, ErrNoActivation
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	for i := 0; i < n; i++ {
+		fdName := ""
+		if i < len(names) {
+			fdName = names[i]
+		}
+		if name != "" && fdName != name {
+			continue
+		}
+
+		file := os.NewFile(uintptr(firstActivationFD+i), fdName)
+		l, err := net.FileListener(file)
+		// FileListener duplicates the descriptor
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("socket %d: %w", i, err)
+		}
+		return l, nil
+	}
+	return nil, fmt.Errorf("%w: no socket named %q", ErrNoActivation, name)
+}
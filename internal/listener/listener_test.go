@@ -0,0 +1,106 @@
+package listener
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListen_TCP(t *testing.T) {
+	l, err := Listen(Options{Addr: "127.0.0.1:0"})
+	require.NoError(t, err)
+	defer l.Close()
+
+	assert.Equal(t, "tcp", l.Addr().Network())
+}
+
+func TestListen_UnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shortener.sock")
+
+	// A socket left by a previous run is replaced
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	l, err := Listen(Options{Addr: "127.0.0.1:0", UnixSocket: path})
+	require.NoError(t, err)
+	defer l.Close()
+
+	assert.Equal(t, "unix", l.Addr().Network())
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	conn.Close()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+}
+
+func TestListen_UnixSocketNotASocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(path, []byte("keep"), 0600))
+
+	_, err := Listen(Options{UnixSocket: path})
+	assert.Error(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "keep", string(data))
+}
+
+func TestListen_SystemdActivation(t *testing.T) {
+	t.Run("not activated", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", "")
+		t.Setenv("LISTEN_FDS", "")
+		_, err := Listen(Options{SystemdActivation: true})
+		assert.ErrorIs(t, err, ErrNoActivation)
+	})
+
+	t.Run("passed to another process", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+		t.Setenv("LISTEN_FDS", "1")
+		_, err := Listen(Options{SystemdActivation: true})
+		assert.ErrorIs(t, err, ErrNoActivation)
+	})
+
+	t.Run("named socket", func(t *testing.T) {
+		passed, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer passed.Close()
+		file, err := passed.(*net.TCPListener).File()
+		require.NoError(t, err)
+		// The descriptor handed over is closed by Listen, like the ones from systemd
+		fd, err := syscall.Dup(int(file.Fd()))
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		saved := firstActivationFD
+		t.Cleanup(func() { firstActivationFD = saved })
+		firstActivationFD = fd
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		t.Setenv("LISTEN_FDS", "1")
+		t.Setenv("LISTEN_FDNAMES", "http")
+
+		l, err := Listen(Options{SystemdActivation: true, SocketName: "http"})
+		require.NoError(t, err)
+		defer l.Close()
+		assert.Equal(t, passed.Addr().String(), l.Addr().String())
+
+	})
+
+	t.Run("unknown name", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		t.Setenv("LISTEN_FDS", "1")
+		t.Setenv("LISTEN_FDNAMES", "http")
+
+		_, err := Listen(Options{SystemdActivation: true, SocketName: "grpc"})
+		assert.ErrorIs(t, err, ErrNoActivation)
+	})
+}
@@ -0,0 +1,32 @@
+// Package logger provides helpers for carrying a request ID through contexts
+// so that log entries written by different layers for one request can be correlated.
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// requestIDKey is the context key under which the request ID is stored.
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the given request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or an empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext returns l annotated with the request ID stored in ctx.
+// If ctx carries no request ID, l is returned unchanged.
+func FromContext(ctx context.Context, l *zap.Logger) *zap.Logger {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return l.With(zap.String("request_id", id))
+	}
+	return l
+}
@@ -2,13 +2,52 @@
 package logger
 
 import (
+	"fmt"
+	"time"
+
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Log encodings supported by Options.Format.
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
 )
 
+// Options configures the logger built by InitWithOptions.
+type Options struct {
+	// Level is the minimum level of logged entries, such as "debug" or "warn".
+	Level string
+
+	// Format is the encoding of log entries, FormatJSON or FormatConsole.
+	Format string
+
+	// Sampling limits repeated entries with the same message to the first 100
+	// per second and every 100th after that, bounding the cost of log storms.
+	Sampling bool
+
+	// OutputPaths lists the files or "stdout"/"stderr" entries are written to.
+	OutputPaths []string
+
+	// File is a log file entries are written to in addition to OutputPaths,
+	// rotated according to Rotation. Empty disables it.
+	File string
+
+	// Rotation configures the rotation of File.
+	Rotation Rotation
+}
+
 // Logger is a wrapper around the Zap logger to handle logging functionality.
 type Logger struct {
 	// Log is the underlying Zap logger instance.
 	Log *zap.Logger
+
+	// level is the minimum level of the logger built by InitWithOptions.
+	level zap.AtomicLevel
+
+	// file is the rotated log file, nil when Options.File is empty.
+	file *RotatingFile
 }
 
 // New creates and returns a new Logger instance with a no-op logger.
@@ -23,25 +62,106 @@ func New() *Logger {
 // The logger is then created based on a production configuration with the specified level.
 // If an error occurs during initialization, it is returned.
 func (l *Logger) Init(level string) error {
-	// Parse the level string into a zap.AtomicLevel
-	lvl, err := zap.ParseAtomicLevel(level)
-	if err != nil {
-		return err
-	}
+	return l.InitWithOptions(Options{Level: level, Format: FormatJSON, Sampling: true})
+}
 
+// InitWithOptions initializes the Logger instance from the production
+// configuration adjusted by o. Empty fields keep the production defaults.
+func (l *Logger) InitWithOptions(o Options) error {
 	// Create a new logger configuration with production settings
 	cfg := zap.NewProductionConfig()
 
-	// Set the log level
-	cfg.Level = lvl
+	if o.Level != "" {
+		// Parse the level string into a zap.AtomicLevel
+		lvl, err := zap.ParseAtomicLevel(o.Level)
+		if err != nil {
+			return err
+		}
+		cfg.Level = lvl
+	}
+
+	switch o.Format {
+	case "", FormatJSON:
+	case FormatConsole:
+		cfg.Encoding = FormatConsole
+		cfg.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+	default:
+		return fmt.Errorf("unknown log format %q", o.Format)
+	}
+
+	if !o.Sampling {
+		cfg.Sampling = nil
+	}
+
+	if len(o.OutputPaths) > 0 {
+		cfg.OutputPaths = o.OutputPaths
+	}
+
+	var opts []zap.Option
+	var file *RotatingFile
+	if o.File != "" {
+		var err error
+		if file, err = OpenRotatingFile(o.File, o.Rotation); err != nil {
+			return err
+		}
+		opts = append(opts, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(c, fileCore(cfg, file))
+		}))
+	}
 
 	// Build the logger using the configuration
-	zl, err := cfg.Build()
+	zl, err := cfg.Build(opts...)
 	if err != nil {
+		if file != nil {
+			file.Close()
+		}
 		return err
 	}
 
 	// Set the logger instance
 	l.Log = zl
+	l.level = cfg.Level
+	l.file = file
+	return nil
+}
+
+// fileCore returns the core writing the entries of cfg to file, with the
+// encoding and sampling of cfg.
+func fileCore(cfg zap.Config, file *RotatingFile) zapcore.Core {
+	enc := zapcore.NewJSONEncoder(cfg.EncoderConfig)
+	if cfg.Encoding == FormatConsole {
+		enc = zapcore.NewConsoleEncoder(cfg.EncoderConfig)
+	}
+	core := zapcore.NewCore(enc, file, cfg.Level)
+	if cfg.Sampling != nil {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, cfg.Sampling.Initial, cfg.Sampling.Thereafter)
+	}
+	return core
+}
+
+// Close flushes the logger and closes its log file, if any. Entries logged
+// afterwards are not written to the file.
+func (l *Logger) Close() error {
+	err := l.Log.Sync()
+	if l.file != nil {
+		if cerr := l.file.Close(); cerr != nil {
+			return cerr
+		}
+	}
+	return err
+}
+
+// SetLevel changes the minimum level of the logger built by InitWithOptions
+// without rebuilding it, so that loggers derived from it follow the change.
+func (l *Logger) SetLevel(level string) error {
+	lvl, err := zap.ParseAtomicLevel(level)
+	if err != nil {
+		return err
+	}
+	if l.level == (zap.AtomicLevel{}) {
+		return fmt.Errorf("logger is not initialized")
+	}
+
+	l.level.SetLevel(lvl.Level())
 	return nil
 }
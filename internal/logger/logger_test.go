@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitWithOptions(t *testing.T) {
+	t.Run("json to a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+
+		l := New()
+		require.NoError(t, l.InitWithOptions(Options{Level: "warn", Format: FormatJSON, OutputPaths: []string{path}}))
+		l.Log.Info("hidden")
+		l.Log.Warn("shown")
+		require.NoError(t, l.Log.Sync())
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "hidden")
+		assert.Contains(t, string(data), `"msg":"shown"`)
+	})
+
+	t.Run("console without sampling", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+
+		l := New()
+		require.NoError(t, l.InitWithOptions(Options{Level: "info", Format: FormatConsole, OutputPaths: []string{path}}))
+		for i := 0; i < 150; i++ {
+			l.Log.Info("repeated")
+		}
+		require.NoError(t, l.Log.Sync())
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), `"msg"`)
+		assert.Equal(t, 150, strings.Count(string(data), "repeated"))
+	})
+
+	t.Run("rotated file", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "logs", "app.log")
+
+		l := New()
+		require.NoError(t, l.InitWithOptions(Options{Level: "info", OutputPaths: []string{filepath.Join(dir, "out.log")}, File: path, Rotation: Rotation{MaxSizeMB: 1}}))
+		l.Log.Info("to the file")
+		require.NoError(t, l.Close())
+		l.Log.Info("after close")
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"msg":"to the file"`)
+		assert.NotContains(t, string(data), "after close")
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		assert.Error(t, New().InitWithOptions(Options{Level: "loud"}))
+		assert.Error(t, New().InitWithOptions(Options{Format: "xml"}))
+		assert.Error(t, New().Init("loud"))
+	})
+}
+
+func TestSetLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	l := New()
+	require.NoError(t, l.InitWithOptions(Options{Level: "warn", OutputPaths: []string{path}}))
+	derived := l.Log.With()
+	l.Log.Info("before")
+
+	require.NoError(t, l.SetLevel("info"))
+	derived.Info("after")
+	require.NoError(t, l.Log.Sync())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "before")
+	assert.Contains(t, string(data), "after")
+
+	assert.Error(t, l.SetLevel("loud"))
+	assert.Error(t, New().SetLevel("info"))
+}
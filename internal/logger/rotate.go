@@ -0,0 +1,190 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the layout of the time in the names of rotated files.
+// It sorts lexically in time order.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// Rotation configures when a log file is rotated and how many rotated files
+// are kept.
+type Rotation struct {
+	// MaxSizeMB is the size in megabytes after which the file is rotated; 0
+	// never rotates it.
+	MaxSizeMB int
+
+	// MaxAge is how long rotated files are kept; 0 keeps them regardless of age.
+	MaxAge time.Duration
+
+	// MaxBackups is the number of rotated files kept; 0 keeps all of them.
+	MaxBackups int
+}
+
+// RotatingFile is a log file that is renamed aside, with the time of the
+// rotation added to its name, once it grows past the maximum size, and
+// replaced by a new file. Rotated files past the retention are removed. It is
+// safe for concurrent use.
+type RotatingFile struct {
+	path     string
+	rotation Rotation
+	now      func() time.Time
+
+	mu   sync.Mutex // Guards file and size
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens or creates the log file at path, appending to it.
+func OpenRotatingFile(path string, r Rotation) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, rotation: r, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the log file and reads its current size.
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends p to the log file, rotating it first if p would make it grow
+// past the maximum size. An entry larger than the maximum size is written to
+// a file of its own.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	maxSize := int64(f.rotation.MaxSizeMB) * 1024 * 1024
+	if maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Sync commits the log file to stable storage.
+func (f *RotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+// Close closes the log file; later writes fail.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// rotate renames the log file aside, opens a new one and removes the rotated
+// files past the retention. It must be called with mu held.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	ext := filepath.Ext(f.path)
+	backup := strings.TrimSuffix(f.path, ext) + "-" + f.now().UTC().Format(backupTimeFormat) + ext
+	if err := os.Rename(f.path, backup); err != nil {
+		// Keep writing to the current file rather than losing entries
+		if oerr := f.open(); oerr != nil {
+			return fmt.Errorf("cannot rotate log file: %w", errors.Join(err, oerr))
+		}
+		return nil
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	// The new file is in place, failing to prune only keeps more backups
+	_ = f.prune()
+	return nil
+}
+
+// prune removes the rotated files beyond MaxBackups or older than MaxAge.
+func (f *RotatingFile) prune() error {
+	if f.rotation.MaxBackups <= 0 && f.rotation.MaxAge <= 0 {
+		return nil
+	}
+
+	ext := filepath.Ext(f.path)
+	prefix := filepath.Base(strings.TrimSuffix(f.path, ext)) + "-"
+	dir := filepath.Dir(f.path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	type backup struct {
+		name string
+		at   time.Time
+	}
+	var backups []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		at, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{name: name, at: at})
+	}
+	// Newest first
+	sort.Slice(backups, func(i, j int) bool { return backups[i].at.After(backups[j].at) })
+
+	var errs []error
+	cutoff := f.now().Add(-f.rotation.MaxAge)
+	for i, b := range backups {
+		tooMany := f.rotation.MaxBackups > 0 && i >= f.rotation.MaxBackups
+		tooOld := f.rotation.MaxAge > 0 && b.at.Before(cutoff)
+		if tooMany || tooOld {
+			if err := os.Remove(filepath.Join(dir, b.name)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logFiles returns the names of the files in dir, sorted.
+func logFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := OpenRotatingFile(path, Rotation{MaxSizeMB: 1, MaxBackups: 2})
+	require.NoError(t, err)
+	defer f.Close()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	entry := make([]byte, 600*1024)
+	for i := 0; i < 4; i++ {
+		_, err := f.Write(entry)
+		require.NoError(t, err)
+		now = now.Add(time.Minute)
+	}
+
+	// Each entry after the first rotates the file, the oldest backup is removed
+	assert.Equal(t, []string{
+		"app-2024-05-01T12-02-00.000.log",
+		"app-2024-05-01T12-03-00.000.log",
+		"app.log",
+	}, logFiles(t, dir))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(entry)), info.Size())
+
+	require.NoError(t, f.Close())
+	_, err = f.Write(entry)
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestRotatingFile_MaxAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app-2024-04-01T00-00-00.000.log"), []byte("old\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.log"), []byte("kept\n"), 0o644))
+	require.NoError(t, os.WriteFile(path, make([]byte, 1024*1024), 0o644))
+
+	f, err := OpenRotatingFile(path, Rotation{MaxSizeMB: 1, MaxAge: 24 * time.Hour})
+	require.NoError(t, err)
+	defer f.Close()
+	f.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	// The file is already full, so the first entry rotates it
+	_, err = f.Write([]byte("entry\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app-2024-05-01T12-00-00.000.log", "app.log", "other.log"}, logFiles(t, dir))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "entry\n", string(data))
+}
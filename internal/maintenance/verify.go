@@ -0,0 +1,78 @@
+// Package maintenance provides operational checks of the URL storage, such as
+// verifying the integrity of the stored records, used by the admin command.
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// Source is the storage whose records are verified.
+type Source interface {
+	Read(context.Context) ([]storage.URLRecord, error)
+}
+
+// Problem describes a record that breaks an integrity rule.
+type Problem struct {
+	Short  string // Short URL of the record
+	Reason string // Rule the record breaks
+}
+
+// Report summarizes a verification.
+type Report struct {
+	Records  int       // Records read from the storage
+	Deleted  int       // Records marked as deleted
+	Problems []Problem // Records breaking integrity rules
+}
+
+// Verify reads every record of src and checks that short URLs are unique and
+// not empty, that original URLs are absolute and shortened only once among
+// the records that are not deleted, and that activation windows are not empty.
+func Verify(ctx context.Context, src Source) (Report, error) {
+	records, err := src.Read(ctx)
+	if err != nil {
+		return Report{}, fmt.Errorf("read records: %w", err)
+	}
+
+	report := Report{Records: len(records)}
+	problem := func(short, format string, args ...any) {
+		report.Problems = append(report.Problems, Problem{Short: short, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	shorts := make(map[string]bool, len(records))
+	originals := make(map[string]string, len(records))
+	for _, r := range records {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if r.IsDeleted {
+			report.Deleted++
+		}
+
+		if r.Short == "" {
+			problem(r.Short, "empty short URL for %s", r.Original)
+		} else if shorts[r.Short] {
+			problem(r.Short, "duplicate short URL")
+		}
+		shorts[r.Short] = true
+
+		if u, err := url.Parse(r.Original); err != nil || u.Scheme == "" || u.Host == "" {
+			problem(r.Short, "invalid original URL %q", r.Original)
+		}
+		if !r.IsDeleted {
+			if other, ok := originals[r.Original]; ok {
+				problem(r.Short, "original URL %s is also shortened as %s", r.Original, other)
+			} else {
+				originals[r.Original] = r.Short
+			}
+		}
+
+		if r.NotBefore != nil && r.NotAfter != nil && !r.NotAfter.After(*r.NotBefore) {
+			problem(r.Short, "activation window ends before it starts")
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,70 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// staticSource is a Source returning fixed records.
+type staticSource struct {
+	records []storage.URLRecord
+	err     error
+}
+
+func (s staticSource) Read(context.Context) ([]storage.URLRecord, error) {
+	return s.records, s.err
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+
+	report, err := Verify(context.Background(), staticSource{records: []storage.URLRecord{
+		{Short: "a", Original: "https://example.com/a"},
+		{Short: "b", Original: "https://example.com/b", IsDeleted: true},
+		{Short: "c", Original: "https://example.com/b"},
+		{Short: "a", Original: "https://example.com/other"},
+		{Short: "", Original: "https://example.com/empty"},
+		{Short: "d", Original: "example.com/relative"},
+		{Short: "e", Original: "https://example.com/a"},
+		{Short: "f", Original: "https://example.com/f", NotBefore: &now, NotAfter: &earlier},
+	}})
+	require.NoError(t, err)
+
+	assert.Equal(t, 8, report.Records)
+	assert.Equal(t, 1, report.Deleted)
+	assert.Equal(t, []Problem{
+		{Short: "a", Reason: "duplicate short URL"},
+		{Short: "", Reason: "empty short URL for https://example.com/empty"},
+		{Short: "d", Reason: `invalid original URL "example.com/relative"`},
+		{Short: "e", Reason: "original URL https://example.com/a is also shortened as a"},
+		{Short: "f", Reason: "activation window ends before it starts"},
+	}, report.Problems)
+}
+
+func TestVerify_Consistent(t *testing.T) {
+	s, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "urls.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer s.Close()
+	_, err = s.Write(context.Background(), storage.URLRecord{Short: "a", Original: "https://example.com/a"})
+	require.NoError(t, err)
+
+	report, err := Verify(context.Background(), s)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Records)
+	assert.Empty(t, report.Problems)
+}
+
+func TestVerify_ReadError(t *testing.T) {
+	_, err := Verify(context.Background(), staticSource{err: errors.New("db down")})
+	assert.ErrorContains(t, err, "db down")
+}
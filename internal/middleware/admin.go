@@ -0,0 +1,18 @@
+// Package middleware provides HTTP middleware restricting routes to administrators.
+package middleware
+
+import "net/http"
+
+// RequireAdmin is an HTTP middleware that lets through only requests whose JWT
+// carries the admin claim. It must run after WithJWT; other requests are
+// rejected with 403 Forbidden.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if admin, ok := r.Context().Value(AdminKey).(bool); !ok || !admin {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
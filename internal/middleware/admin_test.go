@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		admin      any
+		wantStatus int
+	}{
+		{name: "admin", admin: true, wantStatus: http.StatusOK},
+		{name: "regular user", admin: false, wantStatus: http.StatusForbidden},
+		{name: "no role", admin: nil, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/stats", nil)
+			if tt.admin != nil {
+				req = req.WithContext(context.WithValue(req.Context(), AdminKey, tt.admin))
+			}
+			rec := httptest.NewRecorder()
+
+			RequireAdmin(handler).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
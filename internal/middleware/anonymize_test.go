@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIPAnonymizer(t *testing.T) {
+	none, err := NewIPAnonymizer(AnonymizeNone, nil)
+	require.NoError(t, err)
+	assert.Nil(t, none)
+
+	truncate, err := NewIPAnonymizer(AnonymizeTruncate, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.0", truncate("203.0.113.7"))
+	assert.Equal(t, "2001:db8:1::", truncate("2001:db8:1:2::7"))
+	assert.Equal(t, "@", truncate("@"))
+
+	hash, err := NewIPAnonymizer(AnonymizeHash, []byte("key"))
+	require.NoError(t, err)
+	assert.Len(t, hash("203.0.113.7"), 16)
+	assert.Equal(t, hash("203.0.113.7"), hash("203.0.113.7"))
+	assert.NotEqual(t, hash("203.0.113.7"), hash("203.0.113.8"))
+	assert.NotContains(t, hash("203.0.113.7"), "203")
+
+	other, err := NewIPAnonymizer(AnonymizeHash, []byte("other key"))
+	require.NoError(t, err)
+	assert.NotEqual(t, hash("203.0.113.7"), other("203.0.113.7"))
+
+	_, err = NewIPAnonymizer(AnonymizeHash, nil)
+	assert.Error(t, err)
+	_, err = NewIPAnonymizer("scramble", nil)
+	assert.Error(t, err)
+}
+
+func TestWithIPAnonymization(t *testing.T) {
+	trusted, err := NewTrustedSubnets([]string{"203.0.113.0/24"})
+	require.NoError(t, err)
+	truncate, err := NewIPAnonymizer(AnonymizeTruncate, nil)
+	require.NoError(t, err)
+
+	var recorded, real string
+	handler := WithIPAnonymization(truncate)(WithSubnet(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorded, real = recordedIP(r), remoteIP(r)
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+	req.RemoteAddr = "203.0.113.7:5000"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "203.0.113.0", recorded)
+	assert.Equal(t, "203.0.113.7", real)
+
+	// Without an anonymizer the real address is recorded
+	handler = WithIPAnonymization(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorded = recordedIP(r)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "203.0.113.7", recorded)
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+)
+
+func TestWithAPIKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		resolve    bool
+		resolveErr error
+		wantStatus int
+		wantUserID any
+	}{
+		{name: "no header", wantStatus: http.StatusOK, wantUserID: nil},
+		{name: "other scheme", header: "Bearer token", wantStatus: http.StatusOK, wantUserID: nil},
+		{name: "valid key", header: "ApiKey secret", resolve: true, wantStatus: http.StatusOK, wantUserID: "user-1"},
+		{name: "scheme is case-insensitive", header: "apikey secret", resolve: true, wantStatus: http.StatusOK, wantUserID: "user-1"},
+		{name: "unknown key", header: "ApiKey secret", resolve: true, resolveErr: service.ErrInvalidAPIKey, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockKeys := mocks.NewMockAPIKeyIface(ctrl)
+			if tt.resolve {
+				userID := "user-1"
+				if tt.resolveErr != nil {
+					userID = ""
+				}
+				mockKeys.EXPECT().Resolve(gomock.Any(), "secret").Return(userID, tt.resolveErr)
+			}
+
+			var gotUserID any
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserID = r.Context().Value(UserIDKey)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			WithAPIKey(mockKeys)(handler).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantUserID, gotUserID)
+		})
+	}
+}
+
+func TestWithJWTSkipsAuthenticatedRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// No calls to the auth service are expected.
+	mockAuth := mocks.NewMockAuthIface(ctrl)
+	mockKeys := mocks.NewMockAPIKeyIface(ctrl)
+	mockKeys.EXPECT().Resolve(gomock.Any(), "secret").Return("user-1", nil)
+
+	var gotUserID string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUserID = r.Context().Value(UserIDKey).(string)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "ApiKey secret")
+	rec := httptest.NewRecorder()
+
+	WithAPIKey(mockKeys)(WithJWT(mockAuth)(handler)).ServeHTTP(rec, req)
+
+	assert.Equal(t, "user-1", gotUserID)
+	assert.Empty(t, rec.Result().Cookies())
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockKeys := mocks.NewMockAPIKeyIface(ctrl)
+	mockKeys.EXPECT().Resolve(gomock.Any(), "secret").Return("user-1", nil)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	guarded := WithAPIKey(mockKeys)(RequireAPIKey(handler))
+
+	// Requests authenticated by API key are let through.
+	req := httptest.NewRequest(http.MethodGet, "/api/shorten", nil)
+	req.Header.Set("Authorization", "ApiKey secret")
+	rec := httptest.NewRecorder()
+	guarded.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Requests authenticated by cookie only are rejected.
+	req = InjectUserID(httptest.NewRequest(http.MethodGet, "/api/shorten", nil), "user-1")
+	rec = httptest.NewRecorder()
+	guarded.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, APIKeyScheme, rec.Header().Get("WWW-Authenticate"))
+}
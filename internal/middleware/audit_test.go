@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/atinyakov/go-url-shortener/internal/audit"
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/mocks"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestWithAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAudit := mocks.NewMockAuditIface(ctrl)
+
+	var got storage.AuditEvent
+	mockAudit.EXPECT().
+		Record(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context, e storage.AuditEvent) { got = e })
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), UserIDKey, "user-1")
+			ctx = logger.WithRequestID(ctx, "req-1")
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Use(WithAudit(mockAudit))
+	r.Delete("/api/v1/user/urls", func(w http.ResponseWriter, r *http.Request) {
+		audit.AddTargets(r.Context(), "abc", "def")
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/user/urls", nil)
+	req.RemoteAddr = "192.0.2.1:12345"
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "user-1", got.UserID)
+	assert.Equal(t, "DELETE /api/v1/user/urls", got.Action)
+	assert.Equal(t, []string{"abc", "def"}, got.Targets)
+	assert.Equal(t, http.StatusAccepted, got.Status)
+	assert.Equal(t, "192.0.2.1", got.RemoteAddr)
+	assert.Equal(t, "req-1", got.RequestID)
+	assert.False(t, got.Time.IsZero())
+}
+
+func TestWithAuditSkipsReads(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// No events are expected.
+	mockAudit := mocks.NewMockAuditIface(ctrl)
+
+	handler := WithAudit(mockAudit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/api/v1/user/urls", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+}
+
+func TestWithReadAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAudit := mocks.NewMockAuditIface(ctrl)
+
+	var got storage.AuditEvent
+	mockAudit.EXPECT().
+		Record(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context, e storage.AuditEvent) { got = e })
+
+	// Writes are left to WithAudit, so only the GET request is recorded
+	r := chi.NewRouter()
+	r.With(WithReadAudit(mockAudit)).Get("/api/shorten", func(w http.ResponseWriter, r *http.Request) {
+		audit.AddTargets(r.Context(), "abc")
+		w.WriteHeader(http.StatusCreated)
+	})
+	r.With(WithReadAudit(mockAudit)).Post("/api/shorten", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, "/api/shorten?url=https://example.com", nil))
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+	assert.Equal(t, "GET /api/shorten", got.Action)
+	assert.Equal(t, []string{"abc"}, got.Targets)
+	assert.Equal(t, http.StatusCreated, got.Status)
+}
@@ -0,0 +1,81 @@
+// Package middleware provides an HTTP middleware resolving the IP address of
+// the client behind trusted reverse proxies.
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIPKey is the key used to store the resolved client IP in the context.
+const ClientIPKey ContextKey = "clientIP"
+
+// WithClientIP is an HTTP middleware that resolves the IP address of the
+// client for trusted subnet checks, auditing and logging. When the direct
+// peer is one of the trusted proxies, the client is taken from the
+// X-Forwarded-For header, skipping the trusted proxies it passed through, or
+// from X-Real-IP. Headers sent by other peers are ignored, so clients cannot
+// spoof their address.
+func WithClientIP(proxies *TrustedSubnets) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := peerIP(r)
+			if proxies.Contains(net.ParseIP(ip)) {
+				if forwarded := forwardedIP(r, proxies); forwarded != "" {
+					ip = forwarded
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), ClientIPKey, ip)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// forwardedIP returns the client address forwarded by trusted proxies, or an
+// empty string if the headers name none.
+func forwardedIP(r *http.Request, proxies *TrustedSubnets) string {
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if ip := net.ParseIP(strings.TrimSpace(hop)); ip != nil {
+				hops = append(hops, ip.String())
+			}
+		}
+	}
+
+	// Every proxy appends its peer, so the rightmost untrusted hop is the client
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !proxies.Contains(net.ParseIP(hops[i])) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		return hops[0]
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// peerIP returns the IP address of the direct peer without the port.
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// remoteIP returns the IP address of the client, as resolved by WithClientIP,
+// or of the direct peer if the middleware did not run.
+func remoteIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(ClientIPKey).(string); ok {
+		return ip
+	}
+	return peerIP(r)
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithClientIP(t *testing.T) {
+	proxies, err := NewTrustedSubnets([]string{"10.0.0.0/8", "fd00::/8"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{
+			name:       "spoofed header from untrusted peer",
+			remoteAddr: "203.0.113.7:5000",
+			headers:    map[string]string{"X-Forwarded-For": "10.1.1.1", "X-Real-IP": "10.1.1.1"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "forwarded by trusted proxy",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.4"},
+			want:       "198.51.100.4",
+		},
+		{
+			name:       "client prepended a fake hop",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string]string{"X-Forwarded-For": "10.9.9.9, 198.51.100.4, 10.0.0.3"},
+			want:       "198.51.100.4",
+		},
+		{
+			name:       "only trusted hops",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.5, 10.0.0.3"},
+			want:       "10.0.0.5",
+		},
+		{
+			name:       "real ip header",
+			remoteAddr: "[fd00::2]:5000",
+			headers:    map[string]string{"X-Real-IP": "2001:db8::1"},
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "invalid headers",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string]string{"X-Forwarded-For": "unknown", "X-Real-IP": "nobody"},
+			want:       "10.0.0.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = remoteIP(r)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			WithClientIP(proxies)(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWithClientIP_TrustedSubnet(t *testing.T) {
+	proxies, err := NewTrustedSubnets([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	trusted, err := NewTrustedSubnets([]string{"192.168.0.0/16"})
+	require.NoError(t, err)
+
+	handler := WithClientIP(proxies)(WithSubnet(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	// The proxy itself is not trusted, the client it forwards is
+	req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+	req.RemoteAddr = "10.0.0.2:5000"
+	req.Header.Set("X-Forwarded-For", "192.168.1.1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Without a trusted proxy the header is not believed
+	req.RemoteAddr = "203.0.113.7:5000"
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -0,0 +1,87 @@
+// Package middleware provides an HTTP middleware implementing Cross-Origin
+// Resource Sharing, so browser frontends served from other origins can call the API.
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSOptions configures which cross-origin requests are allowed by WithCORS.
+type CORSOptions struct {
+	AllowedOrigins   []string // Origins allowed to call the API, "*" allows any origin
+	AllowedMethods   []string // Methods allowed in cross-origin requests
+	AllowedHeaders   []string // Request headers allowed in cross-origin requests
+	AllowCredentials bool     // Whether browsers may send cookies with cross-origin requests
+}
+
+// corsMaxAge is how long, in seconds, browsers may cache preflight responses.
+const corsMaxAge = 600
+
+// WithCORS is an HTTP middleware that adds CORS headers to responses for
+// allowed origins and answers preflight requests without calling the next handler.
+// Requests from origins that are not allowed are passed through without CORS headers,
+// so the browser rejects them. Credentials are only allowed for listed origins,
+// never for the "*" origin.
+func WithCORS(opts CORSOptions) func(next http.Handler) http.Handler {
+	methods := strings.Join(opts.AllowedMethods, ", ")
+	headers := strings.Join(opts.AllowedHeaders, ", ")
+	wildcard := containsString(opts.AllowedOrigins, "*")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+
+			if origin == "" || !originAllowed(opts.AllowedOrigins, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Browsers never send credentials to the wildcard origin, and the
+			// configuration rejects allowing both, so it is not worked around.
+			if wildcard {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				if opts.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+
+			// Answer preflight requests directly.
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// originAllowed reports whether origin matches one of the allowed origins.
+func originAllowed(allowed []string, origin string) bool {
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(a, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString reports whether values contains v.
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
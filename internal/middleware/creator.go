@@ -0,0 +1,18 @@
+// Package middleware provides an HTTP middleware that passes the client IP of
+// a request down to the service, which bans the clients creating URLs too fast.
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+)
+
+// WithCreatorIP is an HTTP middleware that stores the client IP of the
+// request, as resolved by WithClientIP, in its context, so that the service
+// tracks the URLs created from that IP along with the ones of the user.
+func WithCreatorIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(service.WithClientIP(r.Context(), remoteIP(r))))
+	})
+}
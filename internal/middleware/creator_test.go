@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestWithCreatorIP(t *testing.T) {
+	s, err := storage.CreateMemoryStorage()
+	require.NoError(t, err)
+	resolver, err := service.NewURLResolver(8, s)
+	require.NoError(t, err)
+	sv, shutdown := service.NewURL(context.Background(), s, resolver, zap.NewNop(), "http://localhost:8080")
+	defer shutdown()
+	sv.SetAbuseLimits(1, time.Hour)
+
+	handler := WithCreatorIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := sv.CreateURLRecord(r.Context(), r.URL.Query().Get("url"), r.URL.Query().Get("user")); err != nil {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	serve := func(remoteAddr, url, user string) int {
+		req := httptest.NewRequest(http.MethodGet, "/?url="+url+"&user="+user, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, serve("192.0.2.1:1234", "https://a.example.com", "u1"))
+
+	// Another user from the same IP goes past the threshold of the IP
+	assert.Equal(t, http.StatusTooManyRequests, serve("192.0.2.1:5678", "https://b.example.com", "u2"))
+	bans := sv.Bans(context.Background())
+	require.Len(t, bans, 1)
+	assert.Equal(t, service.BanKindIP, bans[0].Kind)
+	assert.Equal(t, "192.0.2.1", bans[0].Subject)
+
+	// Other IPs are not banned
+	assert.Equal(t, http.StatusOK, serve("192.0.2.2:1234", "https://c.example.com", "u3"))
+}
@@ -31,15 +31,17 @@ func (w GzipResponseWriter) Write(b []byte) (int, error) {
 
 // WithGZIPGet is an HTTP middleware that compresses the response body using GZIP
 // when the client supports GZIP compression and the content type is not plain text.
-// It is intended for GET requests.
+// It is intended for GET requests. Event streams are not compressed, since
+// their events must reach the client as they are written.
 func WithGZIPGet(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Check if the client supports GZIP compression and the content is not plain text
 		acceptsEncoding := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
 		isPlainText := strings.Contains(r.Header.Get("Content-Type"), "text/plain")
+		isEventStream := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
 
 		// If GZIP is supported and content type is not plain text, compress the response
-		if acceptsEncoding && !isPlainText {
+		if acceptsEncoding && !isPlainText && !isEventStream {
 			w.Header().Set("Content-Encoding", "gzip")
 
 			// Get a GZIP writer from the pool
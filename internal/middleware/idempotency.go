@@ -0,0 +1,223 @@
+// Package middleware provides an HTTP middleware making requests idempotent
+// by an Idempotency-Key header: a client retrying a request after a network
+// failure gets the response of the first attempt replayed, instead of
+// creating the URLs again or being told they conflict.
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header carrying the idempotency key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses replayed for a repeated key.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+const (
+	// maxIdempotencyKeyLength is the longest idempotency key accepted.
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentResponseSize is the largest response body stored for replay;
+	// requests with larger responses are run again when repeated.
+	maxIdempotentResponseSize = 1 << 20
+	// maxIdempotentRequestSize is the largest request body read to fingerprint
+	// a request, the body limit of the JSON and batch handlers.
+	maxIdempotentRequestSize = 1 << 20
+	// maxIdempotencyKeys bounds the number of stored keys, the oldest being
+	// dropped first.
+	maxIdempotencyKeys = 100000
+)
+
+// IdempotencyStore holds the responses of requests by idempotency key for a
+// TTL. It is safe for concurrent use.
+type IdempotencyStore struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // Maps the scoped key to its element of order
+	order   *list.List               // Entries, oldest first
+}
+
+// idempotencyEntry is a request seen with a key, with its response once it completed.
+type idempotencyEntry struct {
+	key         string
+	fingerprint [sha256.Size]byte
+	expires     time.Time
+	done        bool // Whether the response below is set
+
+	status      int
+	contentType string
+	body        []byte
+}
+
+// NewIdempotencyStore returns a store keeping responses for ttl.
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// begin returns the entry of key if there is one; otherwise it records the
+// request with fingerprint as in progress and returns nil.
+func (s *IdempotencyStore) begin(key string, fingerprint [sha256.Size]byte) *idempotencyEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// All entries have the same TTL, so the expired ones are the oldest
+	now := s.now()
+	for el := s.order.Front(); el != nil; el = s.order.Front() {
+		if e := el.Value.(*idempotencyEntry); now.Before(e.expires) && s.order.Len() < maxIdempotencyKeys {
+			break
+		}
+		s.remove(el)
+	}
+
+	if el, ok := s.entries[key]; ok {
+		e := *el.Value.(*idempotencyEntry)
+		return &e
+	}
+	s.entries[key] = s.order.PushBack(&idempotencyEntry{key: key, fingerprint: fingerprint, expires: now.Add(s.ttl)})
+	return nil
+}
+
+// complete stores the response of the request in progress with key.
+func (s *IdempotencyStore) complete(key string, status int, contentType string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*idempotencyEntry)
+		e.done, e.status, e.contentType, e.body = true, status, contentType, body
+	}
+}
+
+// forget removes the request in progress with key, so that it runs again when repeated.
+func (s *IdempotencyStore) forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+}
+
+// remove removes the element from the store. The caller must hold the lock.
+func (s *IdempotencyStore) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*idempotencyEntry).key)
+}
+
+// recordingResponseWriter is an http.ResponseWriter that keeps a copy of the
+// status and body of the response, up to maxIdempotentResponseSize.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	truncated bool
+}
+
+// WriteHeader records the status and sends it.
+func (w *recordingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records b and sends it.
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.body.Len()+len(b) > maxIdempotentResponseSize {
+		w.truncated = true
+	} else {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// WithIdempotency is an HTTP middleware replaying the response of a request
+// repeated with the same Idempotency-Key header by the same user within the
+// TTL of store. A key reused for a different request, by method, path or
+// body, is rejected with 422 Unprocessable Entity, and one repeated while the
+// first request is still running with 409 Conflict. Responses with a server
+// error are not stored, so that the request runs again when retried; requests
+// without the header are passed through, and bodies over 1MB are rejected with
+// 413 Request Entity Too Large. It must run after the
+// authentication middleware so the user ID is known.
+func WithIdempotency(store *IdempotencyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentRequestSize))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Request body must not be larger than 1MB", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, "cannot read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// Keys are scoped to the user, and fingerprinted with the request
+			userID, _ := r.Context().Value(UserIDKey).(string)
+			scoped := userID + "\x00" + key
+			h := sha256.New()
+			h.Write([]byte(r.Method + " " + r.URL.Path + "\x00"))
+			h.Write(body)
+			var fingerprint [sha256.Size]byte
+			copy(fingerprint[:], h.Sum(nil))
+
+			if e := store.begin(scoped, fingerprint); e != nil {
+				switch {
+				case e.fingerprint != fingerprint:
+					http.Error(w, "Idempotency-Key was used for a different request", http.StatusUnprocessableEntity)
+				case !e.done:
+					http.Error(w, "a request with this Idempotency-Key is in progress", http.StatusConflict)
+				default:
+					if e.contentType != "" {
+						w.Header().Set("Content-Type", e.contentType)
+					}
+					w.Header().Set(IdempotentReplayedHeader, "true")
+					w.WriteHeader(e.status)
+					_, _ = w.Write(e.body)
+				}
+				return
+			}
+
+			rw := &recordingResponseWriter{ResponseWriter: w}
+			defer func() {
+				// Forget the key if the handler panicked, so the recovery middleware answers and retries run again
+				if rw.status == 0 || rw.status >= http.StatusInternalServerError || rw.truncated {
+					store.forget(scoped)
+					return
+				}
+				store.complete(scoped, rw.status, w.Header().Get("Content-Type"), rw.body.Bytes())
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// idempotentRequest returns a request by the user with the idempotency key and body.
+func idempotentRequest(userID, key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	return req.WithContext(context.WithValue(req.Context(), UserIDKey, userID))
+}
+
+func TestWithIdempotency(t *testing.T) {
+	calls := 0
+	status := http.StatusCreated
+	handler := WithIdempotency(NewIdempotencyStore(time.Hour))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"call":%d,"body":%q}`, calls, body)
+	}))
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := serve(idempotentRequest("u1", "k1", "https://example.com"))
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+
+	t.Run("replays the response of a retry", func(t *testing.T) {
+		rec := serve(idempotentRequest("u1", "k1", "https://example.com"))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, first.Body.String(), rec.Body.String())
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "true", rec.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("rejects the key for a different request", func(t *testing.T) {
+		rec := serve(idempotentRequest("u1", "k1", "https://other.example"))
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("scopes keys to the user", func(t *testing.T) {
+		rec := serve(idempotentRequest("u2", "k1", "https://example.com"))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("passes requests without a key through", func(t *testing.T) {
+		serve(idempotentRequest("u1", "", "https://example.com"))
+		serve(idempotentRequest("u1", "", "https://example.com"))
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("runs requests failing with a server error again", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+		assert.Equal(t, http.StatusServiceUnavailable, serve(idempotentRequest("u1", "k2", "x")).Code)
+		status = http.StatusCreated
+		assert.Equal(t, http.StatusCreated, serve(idempotentRequest("u1", "k2", "x")).Code)
+		assert.Equal(t, 6, calls)
+	})
+
+	t.Run("rejects long keys", func(t *testing.T) {
+		rec := serve(idempotentRequest("u1", strings.Repeat("k", 256), "x"))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("rejects large bodies", func(t *testing.T) {
+		rec := serve(idempotentRequest("u1", "k-large", strings.Repeat("x", maxIdempotentRequestSize+1)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
+
+func TestWithIdempotency_InProgress(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := WithIdempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("u1", "k1", "x"))
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest("u1", "k1", "x"))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	close(release)
+	<-done
+}
+
+func TestIdempotencyStore_Expires(t *testing.T) {
+	store := NewIdempotencyStore(time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	var fp [32]byte
+	require.Nil(t, store.begin("k1", fp))
+	store.complete("k1", http.StatusCreated, "", nil)
+	assert.NotNil(t, store.begin("k1", fp))
+
+	now = now.Add(time.Minute)
+	assert.Nil(t, store.begin("k1", fp), "expired keys are run again")
+	assert.Equal(t, 1, store.order.Len())
+}
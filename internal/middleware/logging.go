@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
 )
 
 type (
@@ -33,6 +35,12 @@ func (r *loggingResponseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// Unwrap returns the original http.ResponseWriter, so that http.ResponseController
+// can flush streamed responses through the wrapper.
+func (r *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 // WriteHeader sets the HTTP response status code and captures it for logging.
 func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	// Writing the status code using the original http.ResponseWriter
@@ -41,7 +49,7 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 }
 
 // WithRequestLogging is an HTTP middleware that logs the details of each request.
-// It logs the HTTP method, URL, response status, response size, and request duration.
+// It logs the HTTP method, URL, client IP, response status, response size, and request duration.
 func WithRequestLogging(log *zap.Logger) func(http.Handler) http.Handler {
 	// Returns a middleware function that logs HTTP request details.
 	return func(next http.Handler) http.Handler {
@@ -68,9 +76,10 @@ func WithRequestLogging(log *zap.Logger) func(http.Handler) http.Handler {
 			duration := time.Since(start)
 
 			// Log the request details using the provided logger
-			log.Info("HTTP Request",
+			logger.FromContext(r.Context(), log).Info("HTTP Request",
 				zap.String("method", r.Method),
 				zap.String("url", r.URL.String()),
+				zap.String("remote_ip", recordedIP(r)),
 				zap.Duration("duration", duration),
 				zap.Int("status", responseData.status),
 				zap.Int("size", responseData.size),
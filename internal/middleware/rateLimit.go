@@ -0,0 +1,128 @@
+// Package middleware provides an HTTP middleware limiting the number of
+// requests of each client in a time window, and telling clients their budget
+// in the standard rate limit headers so they can throttle themselves.
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Rate limit response headers.
+const (
+	// RateLimitLimitHeader is the number of requests allowed in a window.
+	RateLimitLimitHeader = "X-RateLimit-Limit"
+	// RateLimitRemainingHeader is the number of requests left in the current window.
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	// RateLimitResetHeader is the number of seconds until the current window ends.
+	RateLimitResetHeader = "X-RateLimit-Reset"
+)
+
+// RateLimiter counts the requests of each client in fixed windows starting
+// with their first request. It is safe for concurrent use, and its limits can
+// be changed at runtime with Set.
+type RateLimiter struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	limit     int                    // Requests allowed in a window; 0 disables the limit
+	window    time.Duration          // Length of the windows
+	clients   map[string]*rateWindow // Current window of each client
+	lastSweep time.Time              // When ended windows were last removed
+}
+
+// rateWindow is the window of a client and the requests counted in it.
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// NewRateLimiter returns a limiter allowing limit requests per client in every
+// window. A limit or window of 0 lets every request through.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	l := &RateLimiter{now: time.Now}
+	l.Set(limit, window)
+	return l
+}
+
+// Set changes the limits of the limiter. The windows already started are
+// dropped, so every client starts over with the new limit.
+func (l *RateLimiter) Set(limit int, window time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit <= 0 || window <= 0 {
+		limit, window = 0, 0
+	}
+	l.limit = limit
+	l.window = window
+	l.clients = make(map[string]*rateWindow)
+}
+
+// allow counts a request of the client. It returns the limit in effect, 0 if
+// there is none, whether the request is within it, how many requests the
+// client has left in the window and how long until the window ends.
+func (l *RateLimiter) allow(client string) (int, bool, int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit == 0 {
+		return 0, true, 0, 0
+	}
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= l.window {
+		for c, w := range l.clients {
+			if !now.Before(w.start.Add(l.window)) {
+				delete(l.clients, c)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.clients[client]
+	if !ok || !now.Before(w.start.Add(l.window)) {
+		w = &rateWindow{start: now}
+		l.clients[client] = w
+	}
+	reset := w.start.Add(l.window).Sub(now)
+	if w.count >= l.limit {
+		return l.limit, false, 0, reset
+	}
+	w.count++
+	return l.limit, true, l.limit - w.count, reset
+}
+
+// WithRateLimit is an HTTP middleware allowing each client, by IP address as
+// resolved by WithClientIP, the requests of limiter in every window. Every
+// response carries the X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset headers, and Retry-After with the seconds until the window
+// ends; requests over the limit get 429 Too Many Requests. While the limiter
+// has no limit, requests pass without the headers.
+func WithRateLimit(limiter *RateLimiter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit, ok, remaining, reset := limiter.allow(remoteIP(r))
+			if limit == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Whole seconds, rounded up so that clients waiting for the reset find a new window
+			seconds := strconv.Itoa(int(math.Ceil(reset.Seconds())))
+			w.Header().Set(RateLimitLimitHeader, strconv.Itoa(limit))
+			w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(remaining))
+			w.Header().Set(RateLimitResetHeader, seconds)
+			w.Header().Set("Retry-After", seconds)
+
+			if !ok {
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	handler := WithRateLimit(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Allowed responses tell the client its budget
+	rec := serve("192.0.2.1:1234")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(RateLimitLimitHeader))
+	assert.Equal(t, "1", rec.Header().Get(RateLimitRemainingHeader))
+	assert.Equal(t, "60", rec.Header().Get(RateLimitResetHeader))
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	now = now.Add(20*time.Second + 500*time.Millisecond)
+	rec = serve("192.0.2.1:5678")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get(RateLimitRemainingHeader))
+	assert.Equal(t, "40", rec.Header().Get(RateLimitResetHeader))
+
+	// Requests over the limit are rejected with the same headers
+	rec = serve("192.0.2.1:1234")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(RateLimitLimitHeader))
+	assert.Equal(t, "0", rec.Header().Get(RateLimitRemainingHeader))
+	assert.Equal(t, "40", rec.Header().Get(RateLimitResetHeader))
+	assert.Equal(t, "40", rec.Header().Get("Retry-After"))
+
+	// Other clients have a budget of their own
+	assert.Equal(t, http.StatusCreated, serve("192.0.2.2:1234").Code)
+
+	// The budget is back once the window ends
+	now = now.Add(40 * time.Second)
+	rec = serve("192.0.2.1:1234")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get(RateLimitRemainingHeader))
+}
+
+func TestRateLimiter_RemovesEndedWindows(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(1, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	limiter.allow("192.0.2.1")
+	limiter.allow("192.0.2.2")
+	require.Len(t, limiter.clients, 2)
+
+	now = now.Add(time.Minute)
+	limiter.allow("192.0.2.3")
+	assert.Len(t, limiter.clients, 1)
+}
+
+func TestRateLimiter_Set(t *testing.T) {
+	limiter := NewRateLimiter(0, time.Minute)
+	handler := WithRateLimit(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without a limit requests pass without the headers
+	rec := serve()
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get(RateLimitLimitHeader))
+
+	limiter.Set(1, time.Minute)
+	rec = serve()
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get(RateLimitLimitHeader))
+	rec = serve()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	// A new limit starts every client over
+	limiter.Set(2, time.Minute)
+	rec = serve()
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(RateLimitLimitHeader))
+	assert.Equal(t, "1", rec.Header().Get(RateLimitRemainingHeader))
+
+	limiter.Set(0, 0)
+	rec = serve()
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get(RateLimitLimitHeader))
+}
@@ -0,0 +1,44 @@
+// Package middleware provides an HTTP middleware that recovers from panics in
+// downstream handlers, logs them with a stack trace and responds with 500.
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+)
+
+// WithRecovery is an HTTP middleware that catches panics raised by the next handler.
+// The panic value and stack are logged and the client receives 500 Internal Server Error
+// instead of a dropped connection. http.ErrAbortHandler is re-raised, since it is used
+// deliberately to abort a response.
+func WithRecovery(log *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(rec)
+				}
+
+				logger.FromContext(r.Context(), log).Error("Recovered from panic in HTTP handler",
+					zap.Any("panic", rec),
+					zap.String("method", r.Method),
+					zap.String("url", r.URL.String()),
+					zap.ByteString("stack", debug.Stack()),
+				)
+
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Package middleware provides an HTTP middleware that assigns every request an ID
+// used to correlate log entries across the handler, service and storage layers.
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+)
+
+// RequestIDHeader is the header used to accept and return the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limits the length of request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+// WithRequestID is an HTTP middleware that reuses a valid X-Request-ID header
+// sent by the client or generates a new ID. The ID is stored in the request context
+// and echoed in the response header.
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID reports whether a client-supplied ID is safe to log and echo:
+// non-empty, bounded in length and made of printable ASCII characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
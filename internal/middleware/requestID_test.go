@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+)
+
+func TestWithRequestID(t *testing.T) {
+	var gotID string
+	handler := WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = logger.RequestIDFromContext(r.Context())
+	}))
+
+	t.Run("accepts client ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, "client-id-1")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, "client-id-1", gotID)
+		assert.Equal(t, "client-id-1", rec.Header().Get(RequestIDHeader))
+	})
+
+	t.Run("generates ID", func(t *testing.T) {
+		for _, id := range []string{"", "has space", strings.Repeat("a", maxRequestIDLength+1)} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, id)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.NotEmpty(t, gotID)
+			assert.NotEqual(t, id, gotID)
+			assert.Equal(t, gotID, rec.Header().Get(RequestIDHeader))
+		}
+	})
+}
+
+func TestRequestLoggingWithRequestID(t *testing.T) {
+	var logBuf bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&logBuf), zapcore.InfoLevel)
+
+	handler := WithRequestID(WithRequestLogging(zap.New(core))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "correlate-me")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, logBuf.String(), `"request_id":"correlate-me"`)
+}
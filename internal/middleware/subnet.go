@@ -0,0 +1,79 @@
+// Package middleware provides an HTTP middleware restricting routes to clients
+// from trusted subnets, such as the internal network of the deployment.
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// TrustedSubnets is a set of IPv4 and IPv6 subnets that can be replaced while
+// requests are checked against it, for example on a configuration reload.
+// The zero value contains no subnets.
+type TrustedSubnets struct {
+	subnets atomic.Pointer[[]*net.IPNet]
+}
+
+// NewTrustedSubnets parses the subnets given in CIDR notation, such as
+// "10.0.0.0/8" or "fd00::/8".
+func NewTrustedSubnets(cidrs []string) (*TrustedSubnets, error) {
+	t := &TrustedSubnets{}
+	if err := t.Set(cidrs); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Set replaces the subnets with the ones given in CIDR notation. On error the
+// previous subnets are kept.
+func (t *TrustedSubnets) Set(cidrs []string) error {
+	subnets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, subnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return fmt.Errorf("invalid trusted subnet %q: %w", cidr, err)
+		}
+		subnets = append(subnets, subnet)
+	}
+
+	t.subnets.Store(&subnets)
+	return nil
+}
+
+// Contains reports whether ip is in one of the subnets.
+func (t *TrustedSubnets) Contains(ip net.IP) bool {
+	if t == nil || ip == nil {
+		return false
+	}
+	subnets := t.subnets.Load()
+	if subnets == nil {
+		return false
+	}
+
+	for _, subnet := range *subnets {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithSubnet is an HTTP middleware that lets through only requests whose
+// client address is in one of the trusted subnets. If there are none every
+// request is rejected with 403 Forbidden, so internal routes are closed unless
+// configured.
+func WithSubnet(trusted *TrustedSubnets) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !trusted.Contains(net.ParseIP(remoteIP(r))) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSubnet(t *testing.T) {
+	trusted, err := NewTrustedSubnets([]string{"10.0.0.0/8", " 192.168.1.0/24", "fd00::/8"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		trusted    *TrustedSubnets
+		remoteAddr string
+		wantStatus int
+	}{
+		{name: "first subnet", trusted: trusted, remoteAddr: "10.1.2.3:5000", wantStatus: http.StatusOK},
+		{name: "second subnet", trusted: trusted, remoteAddr: "192.168.1.7:5000", wantStatus: http.StatusOK},
+		{name: "ipv6 subnet", trusted: trusted, remoteAddr: "[fd12::1]:5000", wantStatus: http.StatusOK},
+		{name: "outside subnets", trusted: trusted, remoteAddr: "192.168.2.1:5000", wantStatus: http.StatusForbidden},
+		{name: "ipv6 outside subnets", trusted: trusted, remoteAddr: "[2001:db8::1]:5000", wantStatus: http.StatusForbidden},
+		{name: "unix socket peer", trusted: trusted, remoteAddr: "@", wantStatus: http.StatusForbidden},
+		{name: "no subnets", trusted: &TrustedSubnets{}, remoteAddr: "10.1.2.3:5000", wantStatus: http.StatusForbidden},
+		{name: "nil subnets", trusted: nil, remoteAddr: "10.1.2.3:5000", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+
+			WithSubnet(tt.trusted)(handler).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestTrustedSubnets_Set(t *testing.T) {
+	trusted, err := NewTrustedSubnets([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	_, err = NewTrustedSubnets([]string{"10.0.0.0"})
+	assert.Error(t, err)
+
+	// An invalid list keeps the previous subnets
+	assert.Error(t, trusted.Set([]string{"172.16.0.0/12", "bogus"}))
+	assert.True(t, trusted.Contains(net.ParseIP("10.0.0.1")))
+	assert.False(t, trusted.Contains(net.ParseIP("172.16.0.1")))
+
+	require.NoError(t, trusted.Set([]string{"172.16.0.0/12"}))
+	assert.False(t, trusted.Contains(net.ParseIP("10.0.0.1")))
+	assert.True(t, trusted.Contains(net.ParseIP("172.16.0.1")))
+}
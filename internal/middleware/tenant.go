@@ -0,0 +1,19 @@
+// Package middleware provides an HTTP middleware that passes the host a request
+// was sent to down to the service, which resolves the tenant of the request
+// from it.
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+)
+
+// WithTenantHost is an HTTP middleware that stores the Host of the request in
+// its context, so that the service builds short URLs and redirects for the
+// tenant of that host.
+func WithTenantHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(service.WithHost(r.Context(), r.Host)))
+	})
+}
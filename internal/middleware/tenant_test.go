@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestWithTenantHost(t *testing.T) {
+	s, err := storage.CreateMemoryStorage()
+	require.NoError(t, err)
+	resolver, err := service.NewURLResolver(8, s)
+	require.NoError(t, err)
+	sv, shutdown := service.NewURL(context.Background(), s, resolver, zap.NewNop(), "http://localhost:8080")
+	defer shutdown()
+
+	tenants, err := service.NewTenants(service.Tenant{BaseURL: "http://localhost:8080"}, []service.Tenant{{Host: "go.example.com"}})
+	require.NoError(t, err)
+	sv.SetTenants(tenants)
+
+	var baseURL string
+	handler := WithTenantHost(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		baseURL = sv.Tenant(r.Context()).BaseURL
+	}))
+
+	for host, want := range map[string]string{
+		"go.example.com":      "http://go.example.com",
+		"GO.example.com:8080": "http://go.example.com",
+		"localhost:8080":      "http://localhost:8080",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.Host = host
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, want, baseURL, host)
+	}
+}
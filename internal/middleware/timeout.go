@@ -0,0 +1,28 @@
+// Package middleware provides an HTTP middleware that bounds the time a request
+// may spend in downstream handlers and the services they call.
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// WithTimeout is an HTTP middleware that attaches a deadline of d to the request
+// context. Handlers pass the context to the service layer, so storage calls are
+// cancelled once the budget is spent. A non-positive d leaves the context unchanged.
+func WithTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if d <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+// Package middleware provides an HTTP middleware starting a server span for
+// every request, continuing the trace of the caller when it sends one.
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+)
+
+// TraceParentHeader is the W3C trace context header.
+const TraceParentHeader = "traceparent"
+
+// WithTracing is an HTTP middleware that starts a server span for every
+// request, as a child of the W3C traceparent header if the client sent one.
+// The span is named after the matched route once the request is handled, and
+// requests answered with a 5xx status are marked as failed. It must run after
+// WithRequestID so the request ID can be attached to the span.
+func WithTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.ContextWithTraceParent(r.Context(), r.Header.Get(TraceParentHeader))
+		ctx, span := tracing.Start(ctx, "HTTP "+r.Method, tracing.KindServer)
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer span.End()
+
+		lw := &loggingResponseWriter{ResponseWriter: w, responseData: &responseData{}}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(lw, r)
+
+		status := lw.responseData.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		route := routePattern(r)
+		span.SetName(r.Method + " " + route)
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.response.status_code", status)
+		span.SetAttribute("request.id", logger.RequestIDFromContext(ctx))
+		if status >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("%d %s", status, http.StatusText(status)))
+		}
+	})
+}
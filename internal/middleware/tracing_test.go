@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+)
+
+func TestWithTracing(t *testing.T) {
+	tracer := tracing.NewOTLPTracer("http://127.0.0.1:0", "test")
+	tracing.SetTracer(tracer)
+	defer func() {
+		tracing.SetTracer(nil)
+		_ = tracer.Shutdown(context.Background())
+	}()
+
+	var traceParent string
+	r := chi.NewRouter()
+	r.Use(WithTracing)
+	r.Get("/{url}", func(w http.ResponseWriter, r *http.Request) {
+		traceParent = tracing.TraceParent(r.Context())
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.Header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+	// The handler runs inside a new span of the caller's trace
+	assert.Contains(t, traceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-")
+	assert.NotContains(t, traceParent, "00f067aa0ba902b7")
+}
+
+func TestWithTracingDisabled(t *testing.T) {
+	var traceParent string
+	handler := WithTracing(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = tracing.TraceParent(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/abc", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, traceParent)
+}
@@ -17,6 +17,9 @@ type ContextKey string
 // UserIDKey is the key used to store and retrieve the user ID from the context.
 const UserIDKey ContextKey = "userID"
 
+// AdminKey is the key used to store whether the user holds the admin claim.
+const AdminKey ContextKey = "admin"
+
 // InjectUserID adds the user ID to the request context, making it accessible for
 // downstream handlers.
 func InjectUserID(req *http.Request, userID string) *http.Request {
@@ -26,19 +29,36 @@ func InjectUserID(req *http.Request, userID string) *http.Request {
 }
 
 // WithJWT is an HTTP middleware that checks for a valid JWT in the request's cookies.
-// If the JWT is missing or invalid, a new one is generated and sent to the client.
+// If the JWT is missing or fails verification, a new one is generated and sent
+// to the client, so that tokens signed with a retired key start a new session.
 // It also injects the user ID from the JWT claims into the request context.
+// Requests that already carry a user ID in the context are passed through unchanged.
 func WithJWT(auth service.AuthIface) func(next http.Handler) http.Handler {
 	// Returns a handler that processes the JWT and sets the user ID in the context.
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-			// Try to get the "token" cookie from the request.
-			cookie, cErr := r.Cookie("token")
+			// Skip cookie handling if the client is already authenticated, e.g. by an API key.
+			if id, ok := r.Context().Value(UserIDKey).(string); ok && id != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Try to get the "token" cookie from the request and parse its claims.
 			userID := ""
+			admin := false
+			valid := false
+			if cookie, err := r.Cookie("token"); err == nil {
+				if claims, err := auth.ParseClaims(cookie); err == nil {
+					// Set the user ID and role from the claims.
+					userID, admin, valid = claims.UserID, claims.Admin, true
+				}
+			}
 
-			// If there's no token cookie, generate a new JWT token and set it in the response.
-			if cErr != nil {
+			// If there's no token cookie, or it does not verify, e.g. since it was
+			// signed with a key that is gone, generate a new JWT token and set it in
+			// the response, like for a new visitor.
+			if !valid {
 				tokenString, generatedID, err := auth.BuildJWTString()
 				if err != nil {
 					// If an error occurs while generating the JWT, return a server error.
@@ -58,20 +78,9 @@ func WithJWT(auth service.AuthIface) func(next http.Handler) http.Handler {
 				userID = generatedID
 			}
 
-			// If a token cookie is present, parse its claims.
-			if cookie != nil {
-				claims, err := auth.ParseClaims(cookie)
-				if err != nil {
-					// If there is an error parsing the JWT, return a server error.
-					w.WriteHeader(http.StatusInternalServerError)
-					return
-				}
-				// Set the user ID from the claims.
-				userID = claims.UserID
-			}
-
-			// Inject the user ID into the request context.
+			// Inject the user ID and role into the request context.
 			ctx := context.WithValue(r.Context(), UserIDKey, userID)
+			ctx = context.WithValue(ctx, AdminKey, admin)
 
 			// Call the next handler with the updated request context.
 			next.ServeHTTP(w, r.WithContext(ctx))
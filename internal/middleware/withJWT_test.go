@@ -120,7 +120,7 @@ func TestWithJWT(t *testing.T) {
 
 		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
 	})
-	t.Run("token parse error", func(t *testing.T) {
+	t.Run("token parse error – generate new token", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
@@ -130,18 +130,52 @@ func TestWithJWT(t *testing.T) {
 
 		mockAuth.EXPECT().
 			ParseClaims(mockCookie).Return(nil, errors.New("invalid token"))
+		mockAuth.EXPECT().
+			BuildJWTString().
+			Return("mock-token", "generated-user-id", nil)
 
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		req.AddCookie(mockCookie)
 		rec := httptest.NewRecorder()
 
+		var gotUserID string
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			t.Fatal("handler should not be called on error")
+			gotUserID = r.Context().Value(UserIDKey).(string)
+			w.WriteHeader(http.StatusOK)
 		})
 
 		middleware := WithJWT(mockAuth)(handler)
 		middleware.ServeHTTP(rec, req)
 
-		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "generated-user-id", gotUserID)
+		cookies := rec.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, "mock-token", cookies[0].Value)
+	})
+
+	t.Run("admin claim is injected", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockAuth := mocks.NewMockAuthIface(ctrl)
+		mockCookie := &http.Cookie{Name: "token", Value: "admin-token"}
+
+		mockAuth.EXPECT().
+			ParseClaims(mockCookie).
+			Return(&service.Claims{UserID: "admin-id", Admin: true}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(mockCookie)
+		rec := httptest.NewRecorder()
+
+		var gotAdmin bool
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotAdmin = r.Context().Value(AdminKey).(bool)
+		})
+
+		WithJWT(mockAuth)(handler).ServeHTTP(rec, req)
+
+		assert.True(t, gotAdmin)
 	})
 }
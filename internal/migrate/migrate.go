@@ -0,0 +1,146 @@
+// Package migrate copies URL records from one storage backend to another, for
+// example from a file to PostgreSQL, and verifies the copy.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// Source is the storage records are copied from.
+type Source interface {
+	Read(context.Context) ([]storage.URLRecord, error)
+}
+
+// Target is the storage records are copied to.
+type Target interface {
+	Write(context.Context, storage.URLRecord) (*storage.URLRecord, error)
+	DeleteBatch(context.Context, []storage.URLRecord) error
+	FindByShort(context.Context, string) (*storage.URLRecord, error)
+}
+
+// Progress is called after every copied record with the number of records
+// processed so far and the total.
+type Progress func(done, total int)
+
+// Options configures a migration.
+type Options struct {
+	// Verify reads every copied record back from the target and compares it.
+	Verify bool
+	// Progress, if not nil, reports the progress of the copy.
+	Progress Progress
+}
+
+// Report summarizes a migration.
+type Report struct {
+	Total    int // Records read from the source
+	Copied   int // Records written to the target
+	Existing int // Records the target already had
+	Deleted  int // Deleted records whose deletion was applied to the target
+	Verified int // Records read back from the target and matching the source
+}
+
+// Mismatch describes a record that differs between the source and the target.
+type Mismatch struct {
+	Short  string // Short URL of the record
+	Reason string // What differs
+}
+
+// VerifyError is returned when verification finds records that were not copied correctly.
+type VerifyError struct {
+	Mismatches []Mismatch
+}
+
+// Error implements the error interface.
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("verification failed for %d records, first %s: %s",
+		len(e.Mismatches), e.Mismatches[0].Short, e.Mismatches[0].Reason)
+}
+
+// Run copies all records from src to dst. Records a database target already
+// holds are skipped, which makes an interrupted migration safe to run again,
+// and deleted records stay deleted. With opts.Verify every record is read back.
+func Run(ctx context.Context, src Source, dst Target, opts Options) (Report, error) {
+	var report Report
+
+	records, err := src.Read(ctx)
+	if err != nil {
+		return report, fmt.Errorf("read source: %w", err)
+	}
+	report.Total = len(records)
+
+	deleted := make([]storage.URLRecord, 0)
+	for i, r := range records {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		_, err := dst.Write(ctx, r)
+		switch {
+		case err == nil:
+			report.Copied++
+		case errors.Is(err, storage.ErrConflict):
+			report.Existing++
+		default:
+			return report, fmt.Errorf("write %s: %w", r.Short, err)
+		}
+
+		if r.IsDeleted {
+			deleted = append(deleted, r)
+		}
+		if opts.Progress != nil {
+			opts.Progress(i+1, len(records))
+		}
+	}
+
+	if len(deleted) > 0 {
+		if err := dst.DeleteBatch(ctx, deleted); err != nil {
+			return report, fmt.Errorf("delete records: %w", err)
+		}
+		report.Deleted = len(deleted)
+	}
+
+	if !opts.Verify {
+		return report, nil
+	}
+
+	var mismatches []Mismatch
+	for _, r := range records {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		got, err := dst.FindByShort(ctx, r.Short)
+		if reason := compare(r, got, err); reason != "" {
+			mismatches = append(mismatches, Mismatch{Short: r.Short, Reason: reason})
+			continue
+		}
+		report.Verified++
+	}
+	if len(mismatches) > 0 {
+		return report, &VerifyError{Mismatches: mismatches}
+	}
+	return report, nil
+}
+
+// compare returns why the record found in the target differs from the source
+// record, or an empty string if it matches.
+func compare(want storage.URLRecord, got *storage.URLRecord, err error) string {
+	switch {
+	case err != nil && want.IsDeleted:
+		// Backends that drop deleted records no longer find them
+		return ""
+	case err != nil:
+		return err.Error()
+	case got == nil:
+		return "not found"
+	case got.Original != want.Original:
+		return fmt.Sprintf("original URL is %q, want %q", got.Original, want.Original)
+	case got.IsDeleted != want.IsDeleted:
+		return fmt.Sprintf("deleted is %t, want %t", got.IsDeleted, want.IsDeleted)
+	}
+	return ""
+}
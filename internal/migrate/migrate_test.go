@@ -0,0 +1,139 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/migrate"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// conflictTarget wraps a target and reports some records as already present.
+type conflictTarget struct {
+	migrate.Target
+	existing map[string]bool
+}
+
+func (c *conflictTarget) Write(ctx context.Context, r storage.URLRecord) (*storage.URLRecord, error) {
+	if c.existing[r.Short] {
+		return &r, storage.ErrConflict
+	}
+	return c.Target.Write(ctx, r)
+}
+
+// lossyTarget drops every write.
+type lossyTarget struct {
+	migrate.Target
+}
+
+func (lossyTarget) Write(_ context.Context, r storage.URLRecord) (*storage.URLRecord, error) {
+	return &r, nil
+}
+
+// failingTarget fails every write.
+type failingTarget struct {
+	migrate.Target
+}
+
+func (failingTarget) Write(context.Context, storage.URLRecord) (*storage.URLRecord, error) {
+	return nil, errors.New("disk full")
+}
+
+func newSource(t *testing.T, records []storage.URLRecord) *storage.FileStorage {
+	t.Helper()
+
+	fs, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "source.json"), zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { fs.Close() })
+	require.NoError(t, fs.WriteAll(context.Background(), records))
+	return fs
+}
+
+func TestRun(t *testing.T) {
+	src := newSource(t, []storage.URLRecord{
+		{Original: "https://a.example.com", Short: "aaa", UserID: "u1"},
+		{Original: "https://b.example.com", Short: "bbb", UserID: "u1", IsDeleted: true},
+		{Original: "https://c.example.com", Short: "ccc", UserID: "u2"},
+	})
+	dst, _ := storage.CreateMemoryStorage()
+
+	var progress []int
+	report, err := migrate.Run(context.Background(), src, dst, migrate.Options{
+		Verify:   true,
+		Progress: func(done, total int) { progress = append(progress, done, total) },
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, migrate.Report{Total: 3, Copied: 3, Deleted: 1, Verified: 3}, report)
+	assert.Equal(t, []int{1, 3, 2, 3, 3, 3}, progress)
+
+	r, err := dst.FindByShort(context.Background(), "ccc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://c.example.com", r.Original)
+
+	r, err = dst.FindByShort(context.Background(), "bbb")
+	require.NoError(t, err)
+	assert.True(t, r.IsDeleted)
+}
+
+func TestRun_SkipsExisting(t *testing.T) {
+	src := newSource(t, []storage.URLRecord{
+		{Original: "https://a.example.com", Short: "aaa", UserID: "u1"},
+		{Original: "https://b.example.com", Short: "bbb", UserID: "u1"},
+	})
+	mem, _ := storage.CreateMemoryStorage()
+	_, err := mem.Write(context.Background(), storage.URLRecord{Original: "https://a.example.com", Short: "aaa", UserID: "u1"})
+	require.NoError(t, err)
+	dst := &conflictTarget{Target: mem, existing: map[string]bool{"aaa": true}}
+
+	report, err := migrate.Run(context.Background(), src, dst, migrate.Options{Verify: true})
+
+	require.NoError(t, err)
+	assert.Equal(t, migrate.Report{Total: 2, Copied: 1, Existing: 1, Verified: 2}, report)
+}
+
+func TestRun_VerifyFails(t *testing.T) {
+	src := newSource(t, []storage.URLRecord{
+		{Original: "https://a.example.com", Short: "aaa", UserID: "u1"},
+	})
+	mem, _ := storage.CreateMemoryStorage()
+
+	report, err := migrate.Run(context.Background(), src, lossyTarget{Target: mem}, migrate.Options{Verify: true})
+
+	var verifyErr *migrate.VerifyError
+	require.True(t, errors.As(err, &verifyErr))
+	assert.Equal(t, []migrate.Mismatch{{Short: "aaa", Reason: "not found"}}, verifyErr.Mismatches)
+	assert.Equal(t, "verification failed for 1 records, first aaa: not found", err.Error())
+	assert.Equal(t, migrate.Report{Total: 1, Copied: 1}, report)
+}
+
+func TestRun_WriteError(t *testing.T) {
+	src := newSource(t, []storage.URLRecord{
+		{Original: "https://a.example.com", Short: "aaa", UserID: "u1"},
+	})
+	mem, _ := storage.CreateMemoryStorage()
+
+	_, err := migrate.Run(context.Background(), src, failingTarget{Target: mem}, migrate.Options{})
+	assert.EqualError(t, err, "write aaa: disk full")
+}
+
+func TestRun_MemoryTargetConflict(t *testing.T) {
+	src := newSource(t, []storage.URLRecord{
+		{Original: "https://a.example.com", Short: "aaa", UserID: "u1"},
+		{Original: "https://b.example.com", Short: "bbb", UserID: "u1"},
+	})
+	mem, _ := storage.CreateMemoryStorage()
+	_, err := mem.Write(context.Background(), storage.URLRecord{Original: "https://a.example.com", Short: "aaa", UserID: "u1"})
+	require.NoError(t, err)
+
+	// Memory storage reports duplicates as conflicts, like the database
+	report, err := migrate.Run(context.Background(), src, mem, migrate.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, migrate.Report{Total: 2, Copied: 1, Existing: 1}, report)
+}
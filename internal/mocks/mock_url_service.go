@@ -12,9 +12,11 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 
+	service "github.com/atinyakov/go-url-shortener/internal/app/service"
 	models "github.com/atinyakov/go-url-shortener/internal/models"
 	storage "github.com/atinyakov/go-url-shortener/internal/storage"
 )
@@ -43,6 +45,21 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// CountByUserID mocks base method.
+func (m *MockStorage) CountByUserID(arg0 context.Context, arg1 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountByUserID", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountByUserID indicates an expected call of CountByUserID.
+func (mr *MockStorageMockRecorder) CountByUserID(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountByUserID", reflect.TypeOf((*MockStorage)(nil).CountByUserID), arg0, arg1)
+}
+
 // DeleteBatch mocks base method.
 func (m *MockStorage) DeleteBatch(arg0 context.Context, arg1 []storage.URLRecord) error {
 	m.ctrl.T.Helper()
@@ -88,18 +105,78 @@ func (mr *MockStorageMockRecorder) FindByShort(arg0, arg1 any) *gomock.Call {
 }
 
 // FindByUserID mocks base method.
-func (m *MockStorage) FindByUserID(arg0 context.Context, arg1 string) (*[]storage.URLRecord, error) {
+func (m *MockStorage) FindByUserID(arg0 context.Context, arg1 string, arg2 storage.ListOptions) (*[]storage.URLRecord, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindByUserID", arg0, arg1)
+	ret := m.ctrl.Call(m, "FindByUserID", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*[]storage.URLRecord)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindByUserID indicates an expected call of FindByUserID.
-func (mr *MockStorageMockRecorder) FindByUserID(arg0, arg1 any) *gomock.Call {
+func (mr *MockStorageMockRecorder) FindByUserID(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUserID", reflect.TypeOf((*MockStorage)(nil).FindByUserID), arg0, arg1, arg2)
+}
+
+// FindExpired mocks base method.
+func (m *MockStorage) FindExpired(arg0 context.Context, arg1 time.Time, arg2 int) ([]storage.URLRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindExpired", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]storage.URLRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindExpired indicates an expected call of FindExpired.
+func (mr *MockStorageMockRecorder) FindExpired(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindExpired", reflect.TypeOf((*MockStorage)(nil).FindExpired), arg0, arg1, arg2)
+}
+
+// FlagURL mocks base method.
+func (m *MockStorage) FlagURL(ctx context.Context, short, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FlagURL", ctx, short, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FlagURL indicates an expected call of FlagURL.
+func (mr *MockStorageMockRecorder) FlagURL(ctx, short, reason any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FlagURL", reflect.TypeOf((*MockStorage)(nil).FlagURL), ctx, short, reason)
+}
+
+// GetDailyStats mocks base method.
+func (m *MockStorage) GetDailyStats(ctx context.Context, from, to time.Time) ([]storage.DailyStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDailyStats", ctx, from, to)
+	ret0, _ := ret[0].([]storage.DailyStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDailyStats indicates an expected call of GetDailyStats.
+func (mr *MockStorageMockRecorder) GetDailyStats(ctx, from, to any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDailyStats", reflect.TypeOf((*MockStorage)(nil).GetDailyStats), ctx, from, to)
+}
+
+// GetStats mocks base method.
+func (m *MockStorage) GetStats(arg0 context.Context) (int, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStats", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetStats indicates an expected call of GetStats.
+func (mr *MockStorageMockRecorder) GetStats(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUserID", reflect.TypeOf((*MockStorage)(nil).FindByUserID), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockStorage)(nil).GetStats), arg0)
 }
 
 // PingContext mocks base method.
@@ -131,6 +208,104 @@ func (mr *MockStorageMockRecorder) Read(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Read", reflect.TypeOf((*MockStorage)(nil).Read), arg0)
 }
 
+// SetCampaign mocks base method.
+func (m *MockStorage) SetCampaign(ctx context.Context, short, userID, campaignID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCampaign", ctx, short, userID, campaignID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetCampaign indicates an expected call of SetCampaign.
+func (mr *MockStorageMockRecorder) SetCampaign(ctx, short, userID, campaignID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCampaign", reflect.TypeOf((*MockStorage)(nil).SetCampaign), ctx, short, userID, campaignID)
+}
+
+// SetDefaultTitle mocks base method.
+func (m *MockStorage) SetDefaultTitle(ctx context.Context, short, title string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDefaultTitle", ctx, short, title)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDefaultTitle indicates an expected call of SetDefaultTitle.
+func (mr *MockStorageMockRecorder) SetDefaultTitle(ctx, short, title any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDefaultTitle", reflect.TypeOf((*MockStorage)(nil).SetDefaultTitle), ctx, short, title)
+}
+
+// SetDetails mocks base method.
+func (m *MockStorage) SetDetails(ctx context.Context, short, userID, title, description string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDetails", ctx, short, userID, title, description)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDetails indicates an expected call of SetDetails.
+func (mr *MockStorageMockRecorder) SetDetails(ctx, short, userID, title, description any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDetails", reflect.TypeOf((*MockStorage)(nil).SetDetails), ctx, short, userID, title, description)
+}
+
+// SetPassword mocks base method.
+func (m *MockStorage) SetPassword(ctx context.Context, short, userID, hash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPassword", ctx, short, userID, hash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPassword indicates an expected call of SetPassword.
+func (mr *MockStorageMockRecorder) SetPassword(ctx, short, userID, hash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPassword", reflect.TypeOf((*MockStorage)(nil).SetPassword), ctx, short, userID, hash)
+}
+
+// SetPinned mocks base method.
+func (m *MockStorage) SetPinned(ctx context.Context, short, userID string, pinned bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPinned", ctx, short, userID, pinned)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPinned indicates an expected call of SetPinned.
+func (mr *MockStorageMockRecorder) SetPinned(ctx, short, userID, pinned any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPinned", reflect.TypeOf((*MockStorage)(nil).SetPinned), ctx, short, userID, pinned)
+}
+
+// SetPrivate mocks base method.
+func (m *MockStorage) SetPrivate(ctx context.Context, short, userID string, private bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPrivate", ctx, short, userID, private)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPrivate indicates an expected call of SetPrivate.
+func (mr *MockStorageMockRecorder) SetPrivate(ctx, short, userID, private any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPrivate", reflect.TypeOf((*MockStorage)(nil).SetPrivate), ctx, short, userID, private)
+}
+
+// SetTags mocks base method.
+func (m *MockStorage) SetTags(ctx context.Context, short, userID string, tags []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTags", ctx, short, userID, tags)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTags indicates an expected call of SetTags.
+func (mr *MockStorageMockRecorder) SetTags(ctx, short, userID, tags any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTags", reflect.TypeOf((*MockStorage)(nil).SetTags), ctx, short, userID, tags)
+}
+
 // Write mocks base method.
 func (m *MockStorage) Write(arg0 context.Context, arg1 storage.URLRecord) (*storage.URLRecord, error) {
 	m.ctrl.T.Helper()
@@ -184,6 +359,65 @@ func (m *MockURLServiceIface) EXPECT() *MockURLServiceIfaceMockRecorder {
 	return m.recorder
 }
 
+// Bans mocks base method.
+func (m *MockURLServiceIface) Bans(ctx context.Context) []models.Ban {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Bans", ctx)
+	ret0, _ := ret[0].([]models.Ban)
+	return ret0
+}
+
+// Bans indicates an expected call of Bans.
+func (mr *MockURLServiceIfaceMockRecorder) Bans(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Bans", reflect.TypeOf((*MockURLServiceIface)(nil).Bans), ctx)
+}
+
+// CampaignStats mocks base method.
+func (m *MockURLServiceIface) CampaignStats(ctx context.Context, userID, campaignID string, window time.Duration) (*models.CampaignStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CampaignStats", ctx, userID, campaignID, window)
+	ret0, _ := ret[0].(*models.CampaignStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CampaignStats indicates an expected call of CampaignStats.
+func (mr *MockURLServiceIfaceMockRecorder) CampaignStats(ctx, userID, campaignID, window any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CampaignStats", reflect.TypeOf((*MockURLServiceIface)(nil).CampaignStats), ctx, userID, campaignID, window)
+}
+
+// CreateCampaign mocks base method.
+func (m *MockURLServiceIface) CreateCampaign(ctx context.Context, userID, name string) (*storage.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCampaign", ctx, userID, name)
+	ret0, _ := ret[0].(*storage.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCampaign indicates an expected call of CreateCampaign.
+func (mr *MockURLServiceIfaceMockRecorder) CreateCampaign(ctx, userID, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCampaign", reflect.TypeOf((*MockURLServiceIface)(nil).CreateCampaign), ctx, userID, name)
+}
+
+// CreateScheduledURLRecord mocks base method.
+func (m *MockURLServiceIface) CreateScheduledURLRecord(ctx context.Context, long, userID string, notBefore, notAfter *time.Time) (*storage.URLRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateScheduledURLRecord", ctx, long, userID, notBefore, notAfter)
+	ret0, _ := ret[0].(*storage.URLRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateScheduledURLRecord indicates an expected call of CreateScheduledURLRecord.
+func (mr *MockURLServiceIfaceMockRecorder) CreateScheduledURLRecord(ctx, long, userID, notBefore, notAfter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateScheduledURLRecord", reflect.TypeOf((*MockURLServiceIface)(nil).CreateScheduledURLRecord), ctx, long, userID, notBefore, notAfter)
+}
+
 // CreateURLRecord mocks base method.
 func (m *MockURLServiceIface) CreateURLRecord(ctx context.Context, long, userID string) (*storage.URLRecord, error) {
 	m.ctrl.T.Helper()
@@ -199,6 +433,21 @@ func (mr *MockURLServiceIfaceMockRecorder) CreateURLRecord(ctx, long, userID any
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateURLRecord", reflect.TypeOf((*MockURLServiceIface)(nil).CreateURLRecord), ctx, long, userID)
 }
 
+// CreateURLRecordWithOptions mocks base method.
+func (m *MockURLServiceIface) CreateURLRecordWithOptions(ctx context.Context, long, userID string, opts service.CreateOptions) (*storage.URLRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateURLRecordWithOptions", ctx, long, userID, opts)
+	ret0, _ := ret[0].(*storage.URLRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateURLRecordWithOptions indicates an expected call of CreateURLRecordWithOptions.
+func (mr *MockURLServiceIfaceMockRecorder) CreateURLRecordWithOptions(ctx, long, userID, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateURLRecordWithOptions", reflect.TypeOf((*MockURLServiceIface)(nil).CreateURLRecordWithOptions), ctx, long, userID, opts)
+}
+
 // CreateURLRecords mocks base method.
 func (m *MockURLServiceIface) CreateURLRecords(ctx context.Context, rs []models.BatchRequest, userID string) (*[]models.BatchResponse, error) {
 	m.ctrl.T.Helper()
@@ -226,6 +475,65 @@ func (mr *MockURLServiceIfaceMockRecorder) DeleteURLRecords(ctx, rs any) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteURLRecords", reflect.TypeOf((*MockURLServiceIface)(nil).DeleteURLRecords), ctx, rs)
 }
 
+// ExpandURLs mocks base method.
+func (m *MockURLServiceIface) ExpandURLs(ctx context.Context, shorts []string) ([]models.ExpandBatchResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpandURLs", ctx, shorts)
+	ret0, _ := ret[0].([]models.ExpandBatchResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpandURLs indicates an expected call of ExpandURLs.
+func (mr *MockURLServiceIfaceMockRecorder) ExpandURLs(ctx, shorts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpandURLs", reflect.TypeOf((*MockURLServiceIface)(nil).ExpandURLs), ctx, shorts)
+}
+
+// ForceDeleteURLRecords mocks base method.
+func (m *MockURLServiceIface) ForceDeleteURLRecords(ctx context.Context, shorts []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForceDeleteURLRecords", ctx, shorts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForceDeleteURLRecords indicates an expected call of ForceDeleteURLRecords.
+func (mr *MockURLServiceIfaceMockRecorder) ForceDeleteURLRecords(ctx, shorts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForceDeleteURLRecords", reflect.TypeOf((*MockURLServiceIface)(nil).ForceDeleteURLRecords), ctx, shorts)
+}
+
+// GetCampaigns mocks base method.
+func (m *MockURLServiceIface) GetCampaigns(ctx context.Context, userID string) ([]storage.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCampaigns", ctx, userID)
+	ret0, _ := ret[0].([]storage.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCampaigns indicates an expected call of GetCampaigns.
+func (mr *MockURLServiceIfaceMockRecorder) GetCampaigns(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCampaigns", reflect.TypeOf((*MockURLServiceIface)(nil).GetCampaigns), ctx, userID)
+}
+
+// GetStats mocks base method.
+func (m *MockURLServiceIface) GetStats(ctx context.Context, opts service.StatsOptions) (*models.Stats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStats", ctx, opts)
+	ret0, _ := ret[0].(*models.Stats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStats indicates an expected call of GetStats.
+func (mr *MockURLServiceIfaceMockRecorder) GetStats(ctx, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockURLServiceIface)(nil).GetStats), ctx, opts)
+}
+
 // GetURLByShort mocks base method.
 func (m *MockURLServiceIface) GetURLByShort(ctx context.Context, short string) (*storage.URLRecord, error) {
 	m.ctrl.T.Helper()
@@ -242,18 +550,76 @@ func (mr *MockURLServiceIfaceMockRecorder) GetURLByShort(ctx, short any) *gomock
 }
 
 // GetURLByUserID mocks base method.
-func (m *MockURLServiceIface) GetURLByUserID(ctx context.Context, id string) (*[]models.ByIDRequest, error) {
+func (m *MockURLServiceIface) GetURLByUserID(ctx context.Context, id string, opts storage.ListOptions) (*[]models.ByIDRequest, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetURLByUserID", ctx, id)
+	ret := m.ctrl.Call(m, "GetURLByUserID", ctx, id, opts)
 	ret0, _ := ret[0].(*[]models.ByIDRequest)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetURLByUserID indicates an expected call of GetURLByUserID.
-func (mr *MockURLServiceIfaceMockRecorder) GetURLByUserID(ctx, id any) *gomock.Call {
+func (mr *MockURLServiceIfaceMockRecorder) GetURLByUserID(ctx, id, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLByUserID", reflect.TypeOf((*MockURLServiceIface)(nil).GetURLByUserID), ctx, id, opts)
+}
+
+// GetURLMetadata mocks base method.
+func (m *MockURLServiceIface) GetURLMetadata(ctx context.Context, short, userID string) (*models.URLMetadata, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetURLMetadata", ctx, short, userID)
+	ret0, _ := ret[0].(*models.URLMetadata)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetURLMetadata indicates an expected call of GetURLMetadata.
+func (mr *MockURLServiceIfaceMockRecorder) GetURLMetadata(ctx, short, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLMetadata", reflect.TypeOf((*MockURLServiceIface)(nil).GetURLMetadata), ctx, short, userID)
+}
+
+// GetURLStats mocks base method.
+func (m *MockURLServiceIface) GetURLStats(ctx context.Context, short, userID string, window time.Duration) (*models.URLStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetURLStats", ctx, short, userID, window)
+	ret0, _ := ret[0].(*models.URLStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetURLStats indicates an expected call of GetURLStats.
+func (mr *MockURLServiceIfaceMockRecorder) GetURLStats(ctx, short, userID, window any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLStats", reflect.TypeOf((*MockURLServiceIface)(nil).GetURLStats), ctx, short, userID, window)
+}
+
+// LiftBan mocks base method.
+func (m *MockURLServiceIface) LiftBan(ctx context.Context, kind, subject string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LiftBan", ctx, kind, subject)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LiftBan indicates an expected call of LiftBan.
+func (mr *MockURLServiceIfaceMockRecorder) LiftBan(ctx, kind, subject any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLByUserID", reflect.TypeOf((*MockURLServiceIface)(nil).GetURLByUserID), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LiftBan", reflect.TypeOf((*MockURLServiceIface)(nil).LiftBan), ctx, kind, subject)
+}
+
+// LiveStats mocks base method.
+func (m *MockURLServiceIface) LiveStats(ctx context.Context) models.LiveStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LiveStats", ctx)
+	ret0, _ := ret[0].(models.LiveStats)
+	return ret0
+}
+
+// LiveStats indicates an expected call of LiveStats.
+func (mr *MockURLServiceIfaceMockRecorder) LiveStats(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LiveStats", reflect.TypeOf((*MockURLServiceIface)(nil).LiveStats), ctx)
 }
 
 // PingContext mocks base method.
@@ -269,3 +635,718 @@ func (mr *MockURLServiceIfaceMockRecorder) PingContext(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PingContext", reflect.TypeOf((*MockURLServiceIface)(nil).PingContext), ctx)
 }
+
+// RecordRedirect mocks base method.
+func (m *MockURLServiceIface) RecordRedirect(ctx context.Context, r *storage.URLRecord) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RecordRedirect", ctx, r)
+}
+
+// RecordRedirect indicates an expected call of RecordRedirect.
+func (mr *MockURLServiceIfaceMockRecorder) RecordRedirect(ctx, r any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordRedirect", reflect.TypeOf((*MockURLServiceIface)(nil).RecordRedirect), ctx, r)
+}
+
+// SetURLCampaign mocks base method.
+func (m *MockURLServiceIface) SetURLCampaign(ctx context.Context, short, userID, campaignID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetURLCampaign", ctx, short, userID, campaignID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetURLCampaign indicates an expected call of SetURLCampaign.
+func (mr *MockURLServiceIfaceMockRecorder) SetURLCampaign(ctx, short, userID, campaignID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetURLCampaign", reflect.TypeOf((*MockURLServiceIface)(nil).SetURLCampaign), ctx, short, userID, campaignID)
+}
+
+// SetURLDetails mocks base method.
+func (m *MockURLServiceIface) SetURLDetails(ctx context.Context, short, userID, title, description string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetURLDetails", ctx, short, userID, title, description)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetURLDetails indicates an expected call of SetURLDetails.
+func (mr *MockURLServiceIfaceMockRecorder) SetURLDetails(ctx, short, userID, title, description any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetURLDetails", reflect.TypeOf((*MockURLServiceIface)(nil).SetURLDetails), ctx, short, userID, title, description)
+}
+
+// SetURLPassword mocks base method.
+func (m *MockURLServiceIface) SetURLPassword(ctx context.Context, short, userID, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetURLPassword", ctx, short, userID, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetURLPassword indicates an expected call of SetURLPassword.
+func (mr *MockURLServiceIfaceMockRecorder) SetURLPassword(ctx, short, userID, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetURLPassword", reflect.TypeOf((*MockURLServiceIface)(nil).SetURLPassword), ctx, short, userID, password)
+}
+
+// SetURLPinned mocks base method.
+func (m *MockURLServiceIface) SetURLPinned(ctx context.Context, short, userID string, pinned bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetURLPinned", ctx, short, userID, pinned)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetURLPinned indicates an expected call of SetURLPinned.
+func (mr *MockURLServiceIfaceMockRecorder) SetURLPinned(ctx, short, userID, pinned any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetURLPinned", reflect.TypeOf((*MockURLServiceIface)(nil).SetURLPinned), ctx, short, userID, pinned)
+}
+
+// SetURLTags mocks base method.
+func (m *MockURLServiceIface) SetURLTags(ctx context.Context, short, userID string, tags []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetURLTags", ctx, short, userID, tags)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetURLTags indicates an expected call of SetURLTags.
+func (mr *MockURLServiceIfaceMockRecorder) SetURLTags(ctx, short, userID, tags any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetURLTags", reflect.TypeOf((*MockURLServiceIface)(nil).SetURLTags), ctx, short, userID, tags)
+}
+
+// SetURLVisibility mocks base method.
+func (m *MockURLServiceIface) SetURLVisibility(ctx context.Context, short, userID, visibility string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetURLVisibility", ctx, short, userID, visibility)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetURLVisibility indicates an expected call of SetURLVisibility.
+func (mr *MockURLServiceIfaceMockRecorder) SetURLVisibility(ctx, short, userID, visibility any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetURLVisibility", reflect.TypeOf((*MockURLServiceIface)(nil).SetURLVisibility), ctx, short, userID, visibility)
+}
+
+// StreamClicks mocks base method.
+func (m *MockURLServiceIface) StreamClicks(ctx context.Context, short, userID string) (<-chan models.ClickEvent, func(), error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamClicks", ctx, short, userID)
+	ret0, _ := ret[0].(<-chan models.ClickEvent)
+	ret1, _ := ret[1].(func())
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// StreamClicks indicates an expected call of StreamClicks.
+func (mr *MockURLServiceIfaceMockRecorder) StreamClicks(ctx, short, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamClicks", reflect.TypeOf((*MockURLServiceIface)(nil).StreamClicks), ctx, short, userID)
+}
+
+// Tenant mocks base method.
+func (m *MockURLServiceIface) Tenant(ctx context.Context) service.Tenant {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Tenant", ctx)
+	ret0, _ := ret[0].(service.Tenant)
+	return ret0
+}
+
+// Tenant indicates an expected call of Tenant.
+func (mr *MockURLServiceIfaceMockRecorder) Tenant(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Tenant", reflect.TypeOf((*MockURLServiceIface)(nil).Tenant), ctx)
+}
+
+// TopURLs mocks base method.
+func (m *MockURLServiceIface) TopURLs(ctx context.Context, window time.Duration, limit int) ([]models.TopURL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TopURLs", ctx, window, limit)
+	ret0, _ := ret[0].([]models.TopURL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TopURLs indicates an expected call of TopURLs.
+func (mr *MockURLServiceIfaceMockRecorder) TopURLs(ctx, window, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TopURLs", reflect.TypeOf((*MockURLServiceIface)(nil).TopURLs), ctx, window, limit)
+}
+
+// UnlockURL mocks base method.
+func (m *MockURLServiceIface) UnlockURL(ctx context.Context, r *storage.URLRecord, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockURL", ctx, r, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlockURL indicates an expected call of UnlockURL.
+func (mr *MockURLServiceIfaceMockRecorder) UnlockURL(ctx, r, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockURL", reflect.TypeOf((*MockURLServiceIface)(nil).UnlockURL), ctx, r, password)
+}
+
+// MockUserStorage is a mock of UserStorage interface.
+type MockUserStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockUserStorageMockRecorder is the mock recorder for MockUserStorage.
+type MockUserStorageMockRecorder struct {
+	mock *MockUserStorage
+}
+
+// NewMockUserStorage creates a new mock instance.
+func NewMockUserStorage(ctrl *gomock.Controller) *MockUserStorage {
+	mock := &MockUserStorage{ctrl: ctrl}
+	mock.recorder = &MockUserStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserStorage) EXPECT() *MockUserStorageMockRecorder {
+	return m.recorder
+}
+
+// CreateUser mocks base method.
+func (m *MockUserStorage) CreateUser(ctx context.Context, u storage.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, u)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockUserStorageMockRecorder) CreateUser(ctx, u any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUserStorage)(nil).CreateUser), ctx, u)
+}
+
+// FindUser mocks base method.
+func (m *MockUserStorage) FindUser(ctx context.Context, id string) (*storage.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindUser", ctx, id)
+	ret0, _ := ret[0].(*storage.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindUser indicates an expected call of FindUser.
+func (mr *MockUserStorageMockRecorder) FindUser(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUser", reflect.TypeOf((*MockUserStorage)(nil).FindUser), ctx, id)
+}
+
+// UpdateDisplayName mocks base method.
+func (m *MockUserStorage) UpdateDisplayName(ctx context.Context, id, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDisplayName", ctx, id, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDisplayName indicates an expected call of UpdateDisplayName.
+func (mr *MockUserStorageMockRecorder) UpdateDisplayName(ctx, id, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDisplayName", reflect.TypeOf((*MockUserStorage)(nil).UpdateDisplayName), ctx, id, name)
+}
+
+// MockUsersIface is a mock of UsersIface interface.
+type MockUsersIface struct {
+	ctrl     *gomock.Controller
+	recorder *MockUsersIfaceMockRecorder
+	isgomock struct{}
+}
+
+// MockUsersIfaceMockRecorder is the mock recorder for MockUsersIface.
+type MockUsersIfaceMockRecorder struct {
+	mock *MockUsersIface
+}
+
+// NewMockUsersIface creates a new mock instance.
+func NewMockUsersIface(ctrl *gomock.Controller) *MockUsersIface {
+	mock := &MockUsersIface{ctrl: ctrl}
+	mock.recorder = &MockUsersIfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUsersIface) EXPECT() *MockUsersIfaceMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockUsersIface) Get(ctx context.Context, id string) (*storage.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, id)
+	ret0, _ := ret[0].(*storage.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockUsersIfaceMockRecorder) Get(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockUsersIface)(nil).Get), ctx, id)
+}
+
+// Register mocks base method.
+func (m *MockUsersIface) Register(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Register", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Register indicates an expected call of Register.
+func (mr *MockUsersIfaceMockRecorder) Register(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockUsersIface)(nil).Register), ctx, id)
+}
+
+// SetDisplayName mocks base method.
+func (m *MockUsersIface) SetDisplayName(ctx context.Context, id, name string) (*storage.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDisplayName", ctx, id, name)
+	ret0, _ := ret[0].(*storage.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetDisplayName indicates an expected call of SetDisplayName.
+func (mr *MockUsersIfaceMockRecorder) SetDisplayName(ctx, id, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDisplayName", reflect.TypeOf((*MockUsersIface)(nil).SetDisplayName), ctx, id, name)
+}
+
+// MockAuditStorage is a mock of AuditStorage interface.
+type MockAuditStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockAuditStorageMockRecorder is the mock recorder for MockAuditStorage.
+type MockAuditStorageMockRecorder struct {
+	mock *MockAuditStorage
+}
+
+// NewMockAuditStorage creates a new mock instance.
+func NewMockAuditStorage(ctrl *gomock.Controller) *MockAuditStorage {
+	mock := &MockAuditStorage{ctrl: ctrl}
+	mock.recorder = &MockAuditStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditStorage) EXPECT() *MockAuditStorageMockRecorder {
+	return m.recorder
+}
+
+// AppendAudit mocks base method.
+func (m *MockAuditStorage) AppendAudit(ctx context.Context, e storage.AuditEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendAudit", ctx, e)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AppendAudit indicates an expected call of AppendAudit.
+func (mr *MockAuditStorageMockRecorder) AppendAudit(ctx, e any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendAudit", reflect.TypeOf((*MockAuditStorage)(nil).AppendAudit), ctx, e)
+}
+
+// FindAudit mocks base method.
+func (m *MockAuditStorage) FindAudit(ctx context.Context, f storage.AuditFilter) ([]storage.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAudit", ctx, f)
+	ret0, _ := ret[0].([]storage.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAudit indicates an expected call of FindAudit.
+func (mr *MockAuditStorageMockRecorder) FindAudit(ctx, f any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAudit", reflect.TypeOf((*MockAuditStorage)(nil).FindAudit), ctx, f)
+}
+
+// MockAuditIface is a mock of AuditIface interface.
+type MockAuditIface struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditIfaceMockRecorder
+	isgomock struct{}
+}
+
+// MockAuditIfaceMockRecorder is the mock recorder for MockAuditIface.
+type MockAuditIfaceMockRecorder struct {
+	mock *MockAuditIface
+}
+
+// NewMockAuditIface creates a new mock instance.
+func NewMockAuditIface(ctrl *gomock.Controller) *MockAuditIface {
+	mock := &MockAuditIface{ctrl: ctrl}
+	mock.recorder = &MockAuditIfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditIface) EXPECT() *MockAuditIfaceMockRecorder {
+	return m.recorder
+}
+
+// Query mocks base method.
+func (m *MockAuditIface) Query(ctx context.Context, f storage.AuditFilter) ([]storage.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Query", ctx, f)
+	ret0, _ := ret[0].([]storage.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Query indicates an expected call of Query.
+func (mr *MockAuditIfaceMockRecorder) Query(ctx, f any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockAuditIface)(nil).Query), ctx, f)
+}
+
+// Record mocks base method.
+func (m *MockAuditIface) Record(ctx context.Context, e storage.AuditEvent) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Record", ctx, e)
+}
+
+// Record indicates an expected call of Record.
+func (mr *MockAuditIfaceMockRecorder) Record(ctx, e any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Record", reflect.TypeOf((*MockAuditIface)(nil).Record), ctx, e)
+}
+
+// MockAPIKeyStorage is a mock of APIKeyStorage interface.
+type MockAPIKeyStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockAPIKeyStorageMockRecorder is the mock recorder for MockAPIKeyStorage.
+type MockAPIKeyStorageMockRecorder struct {
+	mock *MockAPIKeyStorage
+}
+
+// NewMockAPIKeyStorage creates a new mock instance.
+func NewMockAPIKeyStorage(ctrl *gomock.Controller) *MockAPIKeyStorage {
+	mock := &MockAPIKeyStorage{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeyStorage) EXPECT() *MockAPIKeyStorageMockRecorder {
+	return m.recorder
+}
+
+// FindUserByAPIKey mocks base method.
+func (m *MockAPIKeyStorage) FindUserByAPIKey(ctx context.Context, hash string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindUserByAPIKey", ctx, hash)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindUserByAPIKey indicates an expected call of FindUserByAPIKey.
+func (mr *MockAPIKeyStorageMockRecorder) FindUserByAPIKey(ctx, hash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUserByAPIKey", reflect.TypeOf((*MockAPIKeyStorage)(nil).FindUserByAPIKey), ctx, hash)
+}
+
+// SaveAPIKey mocks base method.
+func (m *MockAPIKeyStorage) SaveAPIKey(ctx context.Context, hash, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAPIKey", ctx, hash, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAPIKey indicates an expected call of SaveAPIKey.
+func (mr *MockAPIKeyStorageMockRecorder) SaveAPIKey(ctx, hash, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAPIKey", reflect.TypeOf((*MockAPIKeyStorage)(nil).SaveAPIKey), ctx, hash, userID)
+}
+
+// MockAPIKeyIface is a mock of APIKeyIface interface.
+type MockAPIKeyIface struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyIfaceMockRecorder
+	isgomock struct{}
+}
+
+// MockAPIKeyIfaceMockRecorder is the mock recorder for MockAPIKeyIface.
+type MockAPIKeyIfaceMockRecorder struct {
+	mock *MockAPIKeyIface
+}
+
+// NewMockAPIKeyIface creates a new mock instance.
+func NewMockAPIKeyIface(ctrl *gomock.Controller) *MockAPIKeyIface {
+	mock := &MockAPIKeyIface{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyIfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeyIface) EXPECT() *MockAPIKeyIfaceMockRecorder {
+	return m.recorder
+}
+
+// Issue mocks base method.
+func (m *MockAPIKeyIface) Issue(ctx context.Context, userID string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Issue", ctx, userID)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Issue indicates an expected call of Issue.
+func (mr *MockAPIKeyIfaceMockRecorder) Issue(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Issue", reflect.TypeOf((*MockAPIKeyIface)(nil).Issue), ctx, userID)
+}
+
+// Resolve mocks base method.
+func (m *MockAPIKeyIface) Resolve(ctx context.Context, key string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Resolve", ctx, key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Resolve indicates an expected call of Resolve.
+func (mr *MockAPIKeyIfaceMockRecorder) Resolve(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resolve", reflect.TypeOf((*MockAPIKeyIface)(nil).Resolve), ctx, key)
+}
+
+// MockClickStorage is a mock of ClickStorage interface.
+type MockClickStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockClickStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockClickStorageMockRecorder is the mock recorder for MockClickStorage.
+type MockClickStorageMockRecorder struct {
+	mock *MockClickStorage
+}
+
+// NewMockClickStorage creates a new mock instance.
+func NewMockClickStorage(ctrl *gomock.Controller) *MockClickStorage {
+	mock := &MockClickStorage{ctrl: ctrl}
+	mock.recorder = &MockClickStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClickStorage) EXPECT() *MockClickStorageMockRecorder {
+	return m.recorder
+}
+
+// ClicksOf mocks base method.
+func (m *MockClickStorage) ClicksOf(ctx context.Context, shorts []string, since time.Time) ([]storage.ClickCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClicksOf", ctx, shorts, since)
+	ret0, _ := ret[0].([]storage.ClickCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClicksOf indicates an expected call of ClicksOf.
+func (mr *MockClickStorageMockRecorder) ClicksOf(ctx, shorts, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClicksOf", reflect.TypeOf((*MockClickStorage)(nil).ClicksOf), ctx, shorts, since)
+}
+
+// RecordClicks mocks base method.
+func (m *MockClickStorage) RecordClicks(ctx context.Context, clicks []storage.BucketClicks) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordClicks", ctx, clicks)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordClicks indicates an expected call of RecordClicks.
+func (mr *MockClickStorageMockRecorder) RecordClicks(ctx, clicks any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordClicks", reflect.TypeOf((*MockClickStorage)(nil).RecordClicks), ctx, clicks)
+}
+
+// TopClicked mocks base method.
+func (m *MockClickStorage) TopClicked(ctx context.Context, since time.Time, limit int) ([]storage.ClickCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TopClicked", ctx, since, limit)
+	ret0, _ := ret[0].([]storage.ClickCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TopClicked indicates an expected call of TopClicked.
+func (mr *MockClickStorageMockRecorder) TopClicked(ctx, since, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TopClicked", reflect.TypeOf((*MockClickStorage)(nil).TopClicked), ctx, since, limit)
+}
+
+// MockCampaignStorage is a mock of CampaignStorage interface.
+type MockCampaignStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockCampaignStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockCampaignStorageMockRecorder is the mock recorder for MockCampaignStorage.
+type MockCampaignStorageMockRecorder struct {
+	mock *MockCampaignStorage
+}
+
+// NewMockCampaignStorage creates a new mock instance.
+func NewMockCampaignStorage(ctrl *gomock.Controller) *MockCampaignStorage {
+	mock := &MockCampaignStorage{ctrl: ctrl}
+	mock.recorder = &MockCampaignStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCampaignStorage) EXPECT() *MockCampaignStorageMockRecorder {
+	return m.recorder
+}
+
+// CreateCampaign mocks base method.
+func (m *MockCampaignStorage) CreateCampaign(ctx context.Context, c storage.Campaign) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCampaign", ctx, c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateCampaign indicates an expected call of CreateCampaign.
+func (mr *MockCampaignStorageMockRecorder) CreateCampaign(ctx, c any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCampaign", reflect.TypeOf((*MockCampaignStorage)(nil).CreateCampaign), ctx, c)
+}
+
+// FindCampaign mocks base method.
+func (m *MockCampaignStorage) FindCampaign(ctx context.Context, id string) (*storage.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindCampaign", ctx, id)
+	ret0, _ := ret[0].(*storage.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindCampaign indicates an expected call of FindCampaign.
+func (mr *MockCampaignStorageMockRecorder) FindCampaign(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindCampaign", reflect.TypeOf((*MockCampaignStorage)(nil).FindCampaign), ctx, id)
+}
+
+// FindCampaignsByUserID mocks base method.
+func (m *MockCampaignStorage) FindCampaignsByUserID(ctx context.Context, userID string) ([]storage.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindCampaignsByUserID", ctx, userID)
+	ret0, _ := ret[0].([]storage.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindCampaignsByUserID indicates an expected call of FindCampaignsByUserID.
+func (mr *MockCampaignStorageMockRecorder) FindCampaignsByUserID(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindCampaignsByUserID", reflect.TypeOf((*MockCampaignStorage)(nil).FindCampaignsByUserID), ctx, userID)
+}
+
+// MockEventPublisher is a mock of EventPublisher interface.
+type MockEventPublisher struct {
+	ctrl     *gomock.Controller
+	recorder *MockEventPublisherMockRecorder
+	isgomock struct{}
+}
+
+// MockEventPublisherMockRecorder is the mock recorder for MockEventPublisher.
+type MockEventPublisherMockRecorder struct {
+	mock *MockEventPublisher
+}
+
+// NewMockEventPublisher creates a new mock instance.
+func NewMockEventPublisher(ctrl *gomock.Controller) *MockEventPublisher {
+	mock := &MockEventPublisher{ctrl: ctrl}
+	mock.recorder = &MockEventPublisherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEventPublisher) EXPECT() *MockEventPublisherMockRecorder {
+	return m.recorder
+}
+
+// Publish mocks base method.
+func (m *MockEventPublisher) Publish(ctx context.Context, subject string, payload []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", ctx, subject, payload)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockEventPublisherMockRecorder) Publish(ctx, subject, payload any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockEventPublisher)(nil).Publish), ctx, subject, payload)
+}
+
+// MockHealthIface is a mock of HealthIface interface.
+type MockHealthIface struct {
+	ctrl     *gomock.Controller
+	recorder *MockHealthIfaceMockRecorder
+	isgomock struct{}
+}
+
+// MockHealthIfaceMockRecorder is the mock recorder for MockHealthIface.
+type MockHealthIfaceMockRecorder struct {
+	mock *MockHealthIface
+}
+
+// NewMockHealthIface creates a new mock instance.
+func NewMockHealthIface(ctrl *gomock.Controller) *MockHealthIface {
+	mock := &MockHealthIface{ctrl: ctrl}
+	mock.recorder = &MockHealthIfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHealthIface) EXPECT() *MockHealthIfaceMockRecorder {
+	return m.recorder
+}
+
+// Ready mocks base method.
+func (m *MockHealthIface) Ready(ctx context.Context) (*models.HealthResponse, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ready", ctx)
+	ret0, _ := ret[0].(*models.HealthResponse)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Ready indicates an expected call of Ready.
+func (mr *MockHealthIfaceMockRecorder) Ready(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ready", reflect.TypeOf((*MockHealthIface)(nil).Ready), ctx)
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// URLEvent is published to the event bus when a short URL is created, deleted or followed.
+type URLEvent struct {
+	// Type is one of "created", "deleted" or "clicked".
+	Type string `json:"type"`
+
+	// ShortURL is the short identifier of the URL.
+	ShortURL string `json:"short_url"`
+
+	// OriginalURL is the original URL; it is empty for deletions.
+	OriginalURL string `json:"original_url,omitempty"`
+
+	// UserID is the owner of the URL.
+	UserID string `json:"user_id,omitempty"`
+
+	// Time is when the event happened.
+	Time time.Time `json:"time"`
+}
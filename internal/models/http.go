@@ -2,10 +2,27 @@
 // for communication between the client and the URL shortener service.
 package models
 
+import "time"
+
 // Request represents a request to shorten a URL.
 type Request struct {
 	// URL is the original URL to be shortened.
 	URL string `json:"url"`
+
+	// NotBefore, if set, is when the short URL starts redirecting.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+
+	// NotAfter, if set, is when the short URL stops redirecting.
+	NotAfter *time.Time `json:"not_after,omitempty"`
+
+	// Tags, if set, are attached to the short URL to filter the URLs of the user by.
+	Tags []string `json:"tags,omitempty"`
+
+	// Title, if set, names the short URL; otherwise the title of its page may be fetched.
+	Title string `json:"title,omitempty"`
+
+	// Description, if set, holds notes on the short URL.
+	Description string `json:"description,omitempty"`
 }
 
 // Response represents the response containing the shortened URL.
@@ -23,6 +40,21 @@ type BatchRequest struct {
 
 	// OriginalURL is the URL to be shortened.
 	OriginalURL string `json:"original_url"`
+
+	// NotBefore, if set, is when the short URL starts redirecting.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+
+	// NotAfter, if set, is when the short URL stops redirecting.
+	NotAfter *time.Time `json:"not_after,omitempty"`
+
+	// Tags, if set, are attached to the short URL to filter the URLs of the user by.
+	Tags []string `json:"tags,omitempty"`
+
+	// Title, if set, names the short URL; otherwise the title of its page may be fetched.
+	Title string `json:"title,omitempty"`
+
+	// Description, if set, holds notes on the short URL.
+	Description string `json:"description,omitempty"`
 }
 
 // BatchResponse represents the response for a single URL in a batch
@@ -31,10 +63,36 @@ type BatchResponse struct {
 	// CorrelationID matches the one sent in the corresponding BatchRequest.
 	CorrelationID string `json:"correlation_id"`
 
-	// ShortURL is the shortened version of the OriginalURL.
+	// ShortURL is the shortened version of the OriginalURL, empty if it is invalid.
 	ShortURL string `json:"short_url"`
+
+	// Status is the outcome of the URL: BatchStatusCreated, BatchStatusExists
+	// or BatchStatusInvalid.
+	Status string `json:"status"`
+
+	// Error describes why an invalid URL was not shortened, empty otherwise.
+	Error string `json:"error,omitempty"`
 }
 
+// Statuses of the URLs of a batch shortening response.
+const (
+	// BatchStatusCreated is the status of a URL whose short URL was created.
+	BatchStatusCreated = "created"
+	// BatchStatusExists is the status of a URL that was already shortened;
+	// the response holds its existing short URL.
+	BatchStatusExists = "exists"
+	// BatchStatusInvalid is the status of a URL that may not be shortened.
+	BatchStatusInvalid = "invalid"
+)
+
+// Visibilities of the metadata and stats of a short URL.
+const (
+	// VisibilityPublic lets anyone knowing the short URL see its metadata and stats.
+	VisibilityPublic = "public"
+	// VisibilityPrivate shows the metadata and stats of the short URL to its owner only.
+	VisibilityPrivate = "private"
+)
+
 // ByIDRequest is used for operations involving both the original and
 // shortened URLs, such as deletion or lookup by ID.
 type ByIDRequest struct {
@@ -43,4 +101,398 @@ type ByIDRequest struct {
 
 	// ShortURL is the shortened version of the original URL.
 	ShortURL string `json:"short_url"`
+
+	// Tags are the tags attached to the short URL, omitted if it has none.
+	Tags []string `json:"tags,omitempty"`
+
+	// Title is the title of the short URL, omitted if it has none.
+	Title string `json:"title,omitempty"`
+
+	// Description holds the notes on the short URL, omitted if it has none.
+	Description string `json:"description,omitempty"`
+
+	// Pinned reports whether the user pinned the short URL to the top of
+	// their listing.
+	Pinned bool `json:"pinned,omitempty"`
+
+	// CampaignID is the campaign the short URL belongs to, omitted if none.
+	CampaignID string `json:"campaign_id,omitempty"`
+}
+
+// QuotaExceededResponse is returned when a request would exceed the URL quota of the user.
+type QuotaExceededResponse struct {
+	// Error describes the failure.
+	Error string `json:"error"`
+
+	// Limit is the maximum number of active URLs a user may own.
+	Limit int `json:"limit"`
+
+	// Used is the number of active URLs the user already owns.
+	Used int `json:"used"`
+
+	// Requested is the number of URLs the request tried to create.
+	Requested int `json:"requested"`
+}
+
+// CreationBannedResponse is returned when the user or the client is banned
+// for creating URLs too fast.
+type CreationBannedResponse struct {
+	// Error describes the failure.
+	Error string `json:"error"`
+
+	// Kind is what is banned, "user" or "ip".
+	Kind string `json:"kind"`
+
+	// Until is when the ban ends.
+	Until time.Time `json:"until"`
+}
+
+// Ban is a temporary ban from creating URLs, imposed on a user or a client IP
+// creating them too fast.
+type Ban struct {
+	// Kind is what is banned, "user" or "ip".
+	Kind string `json:"kind"`
+
+	// Subject is the banned user ID or client IP.
+	Subject string `json:"subject"`
+
+	// Since is when the ban was imposed.
+	Since time.Time `json:"since"`
+
+	// Until is when the ban ends.
+	Until time.Time `json:"until"`
+}
+
+// RejectedURLResponse is returned when a URL may not be shortened, for example
+// because its domain is blocked.
+type RejectedURLResponse struct {
+	// Error describes the failure.
+	Error string `json:"error"`
+
+	// URL is the rejected URL.
+	URL string `json:"url"`
+
+	// Domain is the domain the URL was rejected for.
+	Domain string `json:"domain"`
+}
+
+// LinkErrorResponse is returned instead of a redirect to clients preferring
+// JSON, for example when the short URL is unknown or has been deleted.
+type LinkErrorResponse struct {
+	// Error describes why the short URL does not redirect.
+	Error string `json:"error"`
+
+	// ShortURL is the requested short URL identifier.
+	ShortURL string `json:"short_url"`
+
+	// Threat is what the original URL was flagged for, if it was.
+	Threat string `json:"threat,omitempty"`
+}
+
+// URLMetadata describes a short URL without following it.
+type URLMetadata struct {
+	// ShortURL is the full short URL.
+	ShortURL string `json:"short_url"`
+
+	// OriginalURL is the URL the short URL redirects to. It is omitted for
+	// password protected URLs, unless they are requested by their owner.
+	OriginalURL string `json:"original_url,omitempty"`
+
+	// CreatedAt is when the short URL was created, omitted for URLs predating it.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+
+	// ExpiresAt is when the short URL expires, omitted if it never does.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// NotBefore is when the short URL starts redirecting, omitted if right away.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+
+	// NotAfter is when the short URL stops redirecting, omitted if never.
+	NotAfter *time.Time `json:"not_after,omitempty"`
+
+	// IsDeleted reports whether the short URL was deleted or has expired.
+	IsDeleted bool `json:"is_deleted"`
+
+	// Threat is what the original URL was flagged for, if it was.
+	Threat string `json:"threat,omitempty"`
+
+	// PasswordProtected reports whether the short URL requires a password.
+	PasswordProtected bool `json:"password_protected"`
+
+	// Owned reports whether the short URL belongs to the current user.
+	Owned bool `json:"owned"`
+
+	// Tags are the tags attached to the short URL, only shown to its owner.
+	Tags []string `json:"tags,omitempty"`
+
+	// Pinned reports whether the owner pinned the short URL, only shown to them.
+	Pinned bool `json:"pinned,omitempty"`
+
+	// CampaignID is the campaign the short URL belongs to, only shown to its owner.
+	CampaignID string `json:"campaign_id,omitempty"`
+
+	// Visibility is VisibilityPublic or VisibilityPrivate, only shown to the owner.
+	Visibility string `json:"visibility,omitempty"`
+
+	// Title is the title of the short URL, omitted if it has none or for
+	// password protected URLs of other users.
+	Title string `json:"title,omitempty"`
+
+	// Description holds the notes on the short URL, omitted like Title.
+	Description string `json:"description,omitempty"`
+}
+
+// ExpandBatchResponse is the result of expanding one short URL of a batch.
+type ExpandBatchResponse struct {
+	// ShortURL is the requested short URL identifier.
+	ShortURL string `json:"short_url"`
+
+	// OriginalURL is the URL the short URL redirects to, empty if it does not redirect.
+	OriginalURL string `json:"original_url,omitempty"`
+
+	// Error describes why the short URL does not redirect, empty if it does.
+	Error string `json:"error,omitempty"`
+}
+
+// UserResponse represents the account of a user.
+type UserResponse struct {
+	// ID is the user ID.
+	ID string `json:"id"`
+
+	// DisplayName is the optional human-readable name of the user.
+	DisplayName string `json:"display_name"`
+
+	// CreatedAt is when the account was created.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UpdateUserRequest represents a request to change the account of the current user.
+type UpdateUserRequest struct {
+	// DisplayName is the new display name; an empty string clears it.
+	DisplayName string `json:"display_name"`
+}
+
+// SetPasswordRequest represents a request to protect a short URL with a password.
+type SetPasswordRequest struct {
+	// Password is required to follow the short URL; an empty string removes the protection.
+	Password string `json:"password"`
+}
+
+// SetDetailsRequest represents a request to set the title and description of a short URL.
+type SetDetailsRequest struct {
+	// Title names the short URL; an empty string removes it.
+	Title string `json:"title"`
+
+	// Description holds notes on the short URL; an empty string removes them.
+	Description string `json:"description"`
+}
+
+// SetTagsRequest represents a request to replace the tags of a short URL.
+type SetTagsRequest struct {
+	// Tags replace the tags of the short URL; an empty list removes them.
+	Tags []string `json:"tags"`
+}
+
+// AuditEvent represents an entry of the audit log of mutating operations.
+type AuditEvent struct {
+	// ID is the sequence number of the event.
+	ID int64 `json:"id"`
+
+	// Time is when the operation completed.
+	Time time.Time `json:"time"`
+
+	// UserID is the user who performed the operation.
+	UserID string `json:"user_id"`
+
+	// Action is the method and route of the request.
+	Action string `json:"action"`
+
+	// Targets lists the resources affected by the operation.
+	Targets []string `json:"targets"`
+
+	// Status is the HTTP status of the response.
+	Status int `json:"status"`
+
+	// RemoteAddr is the IP address the request came from.
+	RemoteAddr string `json:"remote_addr"`
+
+	// RequestID correlates the event with request logs.
+	RequestID string `json:"request_id"`
+}
+
+// Stats represents global statistics of the service.
+type Stats struct {
+	// URLs is the number of shortened URLs.
+	URLs int `json:"urls"`
+
+	// Users is the number of users owning shortened URLs.
+	Users int `json:"users"`
+
+	// ComputedAt is when URLs and Users were counted.
+	ComputedAt time.Time `json:"computed_at"`
+
+	// CacheAge is how many seconds ago URLs and Users were counted, 0 unless
+	// they come from the stats cache.
+	CacheAge int `json:"cache_age_seconds"`
+
+	// Daily is the number of URLs created and deleted on each day of the
+	// requested range, omitted if no range was requested.
+	Daily []DailyStats `json:"daily,omitempty"`
+
+	// Jobs are the counters of the background jobs of the instance answering,
+	// such as the delete worker, by job name.
+	Jobs map[string]JobMetrics `json:"jobs,omitempty"`
+}
+
+// JobMetrics holds the cumulative counters of a background job since the
+// instance started.
+type JobMetrics struct {
+	Runs         uint64 `json:"runs"`          // Successful runs, such as deleted batches
+	Retries      uint64 `json:"retries"`       // Retried calls
+	Failures     uint64 `json:"failures"`      // Runs that failed after all retries
+	DeadLettered uint64 `json:"dead_lettered"` // Failed runs whose input was stored in a dead-letter store
+}
+
+// LiveStats holds the rolling totals of the live stats stream.
+type LiveStats struct {
+	// Time is when the totals were taken.
+	Time time.Time `json:"time"`
+
+	// CreatedPerMinute is the number of URLs created in the last minute.
+	CreatedPerMinute int `json:"created_per_minute"`
+
+	// RedirectsPerMinute is the number of redirects in the last minute.
+	RedirectsPerMinute int `json:"redirects_per_minute"`
+
+	// DeleteQueueDepth is the number of deletions waiting for the delete worker.
+	DeleteQueueDepth int `json:"delete_queue_depth"`
+}
+
+// DailyStats represents the changes of the shortened URLs on a day.
+type DailyStats struct {
+	// Date is the day, in UTC, formatted as YYYY-MM-DD.
+	Date string `json:"date"`
+
+	// Created is the number of URLs created on the day.
+	Created int `json:"created"`
+
+	// Deleted is the number of URLs deleted on the day.
+	Deleted int `json:"deleted"`
+}
+
+// TopURL is a short URL ranked by its clicks in a time window.
+type TopURL struct {
+	// ShortURL is the full short URL.
+	ShortURL string `json:"short_url"`
+
+	// OriginalURL is the URL the short URL redirects to.
+	OriginalURL string `json:"original_url"`
+
+	// Clicks is the number of redirects in the time window.
+	Clicks int `json:"clicks"`
+}
+
+// CreateCampaignRequest represents a request to create a campaign.
+type CreateCampaignRequest struct {
+	// Name of the campaign, unique among the campaigns of the user.
+	Name string `json:"name"`
+}
+
+// CampaignResponse represents a campaign of the current user.
+type CampaignResponse struct {
+	// ID identifies the campaign in the other campaign requests.
+	ID string `json:"id"`
+
+	// Name is the name of the campaign.
+	Name string `json:"name"`
+
+	// CreatedAt is when the campaign was created.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SetCampaignRequest represents a request to move a short URL to a campaign.
+type SetCampaignRequest struct {
+	// CampaignID is the campaign of the short URL; an empty string removes it from its campaign.
+	CampaignID string `json:"campaign_id"`
+}
+
+// CampaignStats holds the clicks of the short URLs of a campaign in a time window.
+type CampaignStats struct {
+	// ID is the campaign ID.
+	ID string `json:"id"`
+
+	// Name is the name of the campaign.
+	Name string `json:"name"`
+
+	// URLs is the number of short URLs in the campaign.
+	URLs int `json:"urls"`
+
+	// Clicks is the number of redirects of all the short URLs of the campaign.
+	Clicks int `json:"clicks"`
+
+	// Top lists the clicked short URLs of the campaign, most clicked first.
+	Top []TopURL `json:"top"`
+}
+
+// SetVisibilityRequest represents a request to change the visibility of a short URL.
+type SetVisibilityRequest struct {
+	// Visibility is VisibilityPublic or VisibilityPrivate.
+	Visibility string `json:"visibility"`
+}
+
+// URLStats holds the clicks of a short URL in a time window.
+type URLStats struct {
+	// ShortURL is the full short URL.
+	ShortURL string `json:"short_url"`
+
+	// Clicks is the number of redirects in the time window.
+	Clicks int `json:"clicks"`
+
+	// Since is the start of the time window.
+	Since time.Time `json:"since"`
+}
+
+// ClickEvent is a click of a short URL, as sent by its click stream.
+type ClickEvent struct {
+	// ShortURL is the full short URL that was clicked.
+	ShortURL string `json:"short_url"`
+
+	// Time is when the short URL was clicked.
+	Time time.Time `json:"time"`
+}
+
+// APIKeyResponse represents the response to an API key issuing request.
+type APIKeyResponse struct {
+	// Key is the issued API key. It is shown only once.
+	Key string `json:"key"`
+}
+
+// HealthResponse represents the status of the service and of its dependencies.
+type HealthResponse struct {
+	// Status is "ok" when the service and all dependencies are healthy, "fail" otherwise.
+	Status string `json:"status"`
+
+	// Checks maps dependency names to their status.
+	Checks map[string]DependencyStatus `json:"checks,omitempty"`
+}
+
+// DependencyStatus represents the status of a single dependency.
+type DependencyStatus struct {
+	// Status is "ok" or "fail".
+	Status string `json:"status"`
+
+	// Error describes why the dependency is unhealthy.
+	Error string `json:"error,omitempty"`
+}
+
+// StoredProfile describes a profile captured on demand and stored in the profile directory.
+type StoredProfile struct {
+	// Type is the type of the profile, such as "cpu" or "heap".
+	Type string `json:"type"`
+
+	// Path is the file the profile is stored in.
+	Path string `json:"path"`
+
+	// Bytes is the size of the profile.
+	Bytes int `json:"bytes"`
 }
@@ -0,0 +1,132 @@
+// Package preview fetches the titles of web pages, used as the default titles
+// of short URLs.
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	// fetchTimeout bounds fetching a page when the context has no deadline.
+	fetchTimeout = 5 * time.Second
+	// maxPageBytes is how much of a page is read looking for its title.
+	maxPageBytes = 256 << 10
+	// MaxTitleLen is the length of the longest title returned, in characters.
+	MaxTitleLen = 200
+)
+
+// ErrPrivateAddress is returned when a page resolves to a loopback, private or
+// link-local address, which are not fetched so that short URLs cannot be used
+// to probe the internal network.
+var ErrPrivateAddress = errors.New("page resolves to a private address")
+
+// Fetcher fetches pages over HTTP and extracts their titles.
+type Fetcher struct {
+	client *http.Client
+}
+
+// NewFetcher returns a Fetcher that only connects to public addresses.
+func NewFetcher() *Fetcher {
+	dialer := &net.Dialer{Timeout: fetchTimeout, Control: denyPrivate}
+	// No proxy, which would make the dialer only see the address of the proxy
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: fetchTimeout,
+	}
+	return &Fetcher{client: &http.Client{Timeout: fetchTimeout, Transport: transport}}
+}
+
+// denyPrivate rejects connections to addresses that are not publicly routable.
+// It runs after name resolution, so that names resolving to private addresses
+// are rejected too.
+func denyPrivate(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// Title returns the title of the HTML page at url, with whitespace collapsed
+// and cut to MaxTitleLen characters, or an empty string if the page is not
+// HTML or has no title.
+func (f *Fetcher) Title(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("User-Agent", "go-url-shortener/1.0 (+title preview)")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching page: unexpected status %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "text/html") && !strings.HasPrefix(ct, "application/xhtml+xml") {
+		return "", nil
+	}
+	return ParseTitle(io.LimitReader(resp.Body, maxPageBytes))
+}
+
+// ParseTitle returns the text of the first title element of the HTML page,
+// with whitespace collapsed and cut to MaxTitleLen characters. It stops
+// reading at the end of the head, and returns an empty string if there is no
+// title before.
+func ParseTitle(r io.Reader) (string, error) {
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if errors.Is(z.Err(), io.EOF) {
+				return "", nil
+			}
+			return "", z.Err()
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				if z.Next() != html.TextToken {
+					return "", nil
+				}
+				return cleanTitle(string(z.Text())), nil
+			case atom.Body:
+				return "", nil
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); atom.Lookup(name) == atom.Head {
+				return "", nil
+			}
+		}
+	}
+}
+
+// cleanTitle collapses the whitespace of the title and cuts it to MaxTitleLen
+// characters.
+func cleanTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	if r := []rune(title); len(r) > MaxTitleLen {
+		title = strings.TrimSpace(string(r[:MaxTitleLen]))
+	}
+	return title
+}
@@ -0,0 +1,65 @@
+package preview
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want string
+	}{
+		{name: "title", page: `<html><head><title>Example Domain</title></head></html>`, want: "Example Domain"},
+		{name: "whitespace and entities", page: "<title>\n  Fish &amp;\tChips  </title>", want: "Fish & Chips"},
+		{name: "no title", page: `<html><head></head><body><title>Late</title></body></html>`, want: ""},
+		{name: "empty title", page: `<title></title>`, want: ""},
+		{name: "long title", page: "<title>" + strings.Repeat("a", MaxTitleLen+10) + "</title>", want: strings.Repeat("a", MaxTitleLen)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, err := ParseTitle(strings.NewReader(tt.page))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, title)
+		})
+	}
+}
+
+func TestFetcher_Title(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<!doctype html><title>A page</title>`))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(`<title>not a page</title>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	f := &Fetcher{client: srv.Client()}
+
+	title, err := f.Title(context.Background(), srv.URL+"/page")
+	require.NoError(t, err)
+	assert.Equal(t, "A page", title)
+
+	title, err = f.Title(context.Background(), srv.URL+"/image")
+	require.NoError(t, err)
+	assert.Empty(t, title)
+
+	_, err = f.Title(context.Background(), srv.URL+"/missing")
+	assert.Error(t, err)
+
+	// The test server listens on a loopback address
+	_, err = NewFetcher().Title(context.Background(), srv.URL+"/page")
+	assert.ErrorIs(t, err, ErrPrivateAddress)
+}
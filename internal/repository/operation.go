@@ -0,0 +1,85 @@
+// Package repository provides measurement of database operations: every
+// operation is traced, and operations slower than the configured threshold
+// are logged with their name, duration and row count.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/tracing"
+)
+
+// DefaultSlowQueryThreshold is the duration above which operations are logged
+// when no threshold is configured.
+const DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+// operation is a database operation in progress.
+type operation struct {
+	r     *URLRepository
+	ctx   context.Context
+	name  string
+	query string
+	start time.Time
+	span  *tracing.Span
+	rows  int
+	err   error
+}
+
+// SetSlowQueryThreshold sets the duration above which operations are logged
+// as slow; 0 disables slow query logging. It is safe to call while the
+// repository is used.
+func (r *URLRepository) SetSlowQueryThreshold(d time.Duration) {
+	r.slowQueryThreshold.Store(int64(d))
+}
+
+// startOperation starts measuring the repository method name, which runs a
+// database operation such as "SELECT url_records". The operation must be
+// finished with End.
+func (r *URLRepository) startOperation(ctx context.Context, name string, query string) (context.Context, *operation) {
+	ctx, span := tracing.Start(ctx, "URLRepository."+name, tracing.KindClient)
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.operation", query)
+
+	return ctx, &operation{r: r, ctx: ctx, name: name, query: query, start: time.Now(), span: span}
+}
+
+// SetRows records the number of rows read or written by the operation.
+func (o *operation) SetRows(n int) {
+	o.rows = n
+}
+
+// RecordError marks the operation as failed with err; a nil error is ignored.
+func (o *operation) RecordError(err error) {
+	if err != nil {
+		o.err = err
+		o.span.RecordError(err)
+	}
+}
+
+// End finishes the operation, logging it if it exceeded the slow query threshold.
+func (o *operation) End() {
+	elapsed := time.Since(o.start)
+	o.span.SetAttribute("db.rows", o.rows)
+	o.span.End()
+
+	threshold := time.Duration(o.r.slowQueryThreshold.Load())
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("operation", o.name),
+		zap.String("query", o.query),
+		zap.Duration("duration", elapsed),
+		zap.Duration("threshold", threshold),
+		zap.Int("rows", o.rows),
+	}
+	if o.err != nil {
+		fields = append(fields, zap.Error(o.err))
+	}
+	logger.FromContext(o.ctx, o.r.logger).Warn("slow query", fields...)
+}
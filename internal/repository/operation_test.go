@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestSlowQueryLogging(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	repo := CreateURLRepository(db, zap.New(core))
+	repo.SetSlowQueryThreshold(10 * time.Millisecond)
+
+	// A fast query is not logged
+	mock.ExpectQuery(`SELECT id, original_url, short_url, user_id, tags, title, description, pinned, campaign_id FROM url_records WHERE user_id = \$1`).
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "tags", "title", "description", "pinned", "campaign_id"}).
+			AddRow("1", "https://example.com", "abc", "user-1", "[]", "", "", false, ""))
+
+	_, err = repo.FindByUserID(context.Background(), "user-1", storage.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, logs.Len())
+
+	// A slow one is logged with its name, duration and row count
+	mock.ExpectQuery(`SELECT id, original_url, short_url, user_id, tags, title, description, pinned, campaign_id FROM url_records WHERE user_id = \$1`).
+		WithArgs("user-1").
+		WillDelayFor(20 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "tags", "title", "description", "pinned", "campaign_id"}).
+			AddRow("1", "https://example.com", "abc", "user-1", "[]", "", "", false, "").
+			AddRow("2", "https://example.org", "def", "user-1", "[]", "", "", false, ""))
+
+	_, err = repo.FindByUserID(context.Background(), "user-1", storage.ListOptions{})
+	require.NoError(t, err)
+
+	entries := logs.FilterMessage("slow query").All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "FindByUserID", fields["operation"])
+	assert.Equal(t, "SELECT url_records", fields["query"])
+	assert.Equal(t, int64(2), fields["rows"])
+	assert.GreaterOrEqual(t, fields["duration"], 20*time.Millisecond)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSlowQueryLoggingDisabled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	repo := CreateURLRepository(db, zap.New(core))
+	repo.SetSlowQueryThreshold(0)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM url_records WHERE user_id = \$1`).
+		WithArgs("user-1").
+		WillDelayFor(20 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	_, err = repo.CountByUserID(context.Background(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, logs.Len())
+}
@@ -0,0 +1,67 @@
+// Package repository provides reads from a read-only replica of the database:
+// lookups, listings and statistics are served by the replica while writes go
+// to the primary database. Reads fall back to the primary while the replica is
+// unavailable, and for records not found on the replica, which may not have
+// replicated them yet.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// DefaultReplicaCooldown is how long reads skip a replica after it failed
+// before it is tried again.
+const DefaultReplicaCooldown = 5 * time.Second
+
+// OpenReplica opens a connection to the read-only replica at dsn. Unlike
+// InitDB it does not create the schema, which the replica gets from the
+// primary, and an unreachable replica is only logged: reads fall back to the
+// primary until it is reachable.
+func OpenReplica(dsn string, logger *zap.Logger) *sql.DB {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	if err := db.Ping(); err != nil {
+		logger.Warn("database replica unreachable, reading from the primary", zap.Error(err))
+	}
+	return db
+}
+
+// SetReplica sets the read-only replica serving the reads of the repository.
+// It must be called before the repository is used.
+func (r *URLRepository) SetReplica(db *sql.DB) {
+	r.replica = &statements{db: db}
+	r.replicaBreaker = NewBreaker(1, DefaultReplicaCooldown)
+}
+
+// readValue runs the read fn on the replica of r, if it has one that is not
+// skipped after a failure, and on the primary with the retries and the
+// circuit breaker of resilient otherwise. Reads that fail on the replica with
+// a transient error or storage.ErrNotFound are run again on the primary.
+func readValue[T any](ctx context.Context, r *URLRepository, name string, fn func(context.Context, *statements) (T, error)) (T, error) {
+	if r.replica != nil && r.replicaBreaker.Allow() == nil {
+		v, err := fn(ctx, r.replica)
+		r.replicaBreaker.Record(isTransient(err))
+		if err == nil || (!isTransient(err) && !errors.Is(err, storage.ErrNotFound)) {
+			return v, err
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			logger.FromContext(ctx, r.logger).Warn("database replica unavailable, reading from the primary",
+				zap.String("operation", name), zap.Error(err))
+		}
+	}
+
+	return resilientValue(ctx, r, name, func(ctx context.Context) (T, error) {
+		return fn(ctx, r.stmts)
+	})
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+// findByShortPattern matches the query of FindByShort.
+const findByShortPattern = `SELECT id, original_url, short_url, user_id, is_deleted, expires_at, flagged_reason, password_hash, not_before, not_after, created_at, tags, title, description, pinned, campaign_id, private FROM url_records WHERE short_url = \$1;`
+
+func findByShortRows(short string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "is_deleted", "expires_at", "flagged_reason", "password_hash", "not_before", "not_after", "created_at", "tags", "title", "description", "pinned", "campaign_id", "private"}).
+		AddRow("id-1", "https://example.com", short, "user-1", false, nil, "", "", nil, nil, nil, "[]", "", "", false, "", false)
+}
+
+// setupReplica returns a mock of the primary and of the replica of repo.
+func setupReplica(t *testing.T) (sqlmock.Sqlmock, sqlmock.Sqlmock, *URLRepository) {
+	_, primary, repo := setupMockDB(t)
+	repo.SetResilience(worker.RetryPolicy{}, NewBreaker(5, time.Minute))
+	db, replica, err := sqlmock.New()
+	require.NoError(t, err)
+	repo.SetReplica(db)
+	return primary, replica, repo
+}
+
+func TestReplica_ReadsFromReplica(t *testing.T) {
+	primary, replica, repo := setupReplica(t)
+
+	replica.ExpectPrepare(findByShortPattern).ExpectQuery().WithArgs("abc").WillReturnRows(findByShortRows("abc"))
+	r, err := repo.FindByShort(context.Background(), "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", r.Original)
+
+	replica.ExpectQuery(`SELECT COUNT\(\*\), COUNT\(DISTINCT user_id\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"urls", "users"}).AddRow(4, 2))
+	urls, users, err := repo.GetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 4, urls)
+	assert.Equal(t, 2, users)
+
+	// Writes go to the primary
+	primary.ExpectExec(`UPDATE url_records SET flagged_reason`).WithArgs("malware", "abc").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, repo.FlagURL(context.Background(), "abc", "malware"))
+
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replica.ExpectationsWereMet())
+}
+
+func TestReplica_FallsBackToPrimary(t *testing.T) {
+	primary, replica, repo := setupReplica(t)
+	now := time.Now()
+	repo.replicaBreaker.now = func() time.Time { return now }
+
+	// Records not replicated yet are read from the primary
+	replica.ExpectPrepare(findByShortPattern).ExpectQuery().WithArgs("new").WillReturnError(sql.ErrNoRows)
+	primary.ExpectPrepare(findByShortPattern).ExpectQuery().WithArgs("new").WillReturnRows(findByShortRows("new"))
+	r, err := repo.FindByShort(context.Background(), "new")
+	require.NoError(t, err)
+	assert.Equal(t, "new", r.Short)
+
+	// So are reads failing on the replica, which is then skipped for a while
+	replica.ExpectQuery(`SELECT COUNT\(\*\), COUNT\(DISTINCT user_id\)`).WillReturnError(&pgconn.PgError{Code: "08006"})
+	primary.ExpectQuery(`SELECT COUNT\(\*\), COUNT\(DISTINCT user_id\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"urls", "users"}).AddRow(1, 1))
+	urls, _, err := repo.GetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, urls)
+
+	primary.ExpectQuery(findByShortPattern).WithArgs("abc").WillReturnRows(findByShortRows("abc"))
+	_, err = repo.FindByShort(context.Background(), "abc")
+	require.NoError(t, err)
+	assert.NoError(t, replica.ExpectationsWereMet())
+
+	// Once the cooldown elapsed, the replica is tried again
+	now = now.Add(DefaultReplicaCooldown)
+	replica.ExpectQuery(findByShortPattern).WithArgs("abc").WillReturnRows(findByShortRows("abc"))
+	_, err = repo.FindByShort(context.Background(), "abc")
+	require.NoError(t, err)
+
+	// Other errors are returned as they are
+	replica.ExpectQuery(`SELECT COUNT\(\*\), COUNT\(DISTINCT user_id\)`).WillReturnError(&pgconn.PgError{Code: "42601"})
+	_, _, err = repo.GetStats(context.Background())
+	var pgErr *pgconn.PgError
+	require.ErrorAs(t, err, &pgErr)
+	assert.NotErrorIs(t, err, storage.ErrNotFound)
+
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replica.ExpectationsWereMet())
+}
@@ -6,23 +6,26 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"go.uber.org/zap"
 
+	"github.com/atinyakov/go-url-shortener/internal/logger"
 	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
 )
 
-// ErrConflict is returned when a unique constraint conflict occurs
-// during insertion of a URL (duplicate original or short URL).
-var ErrConflict = errors.New("data conflict")
-
 // InitDB initializes a PostgreSQL database connection and ensures that
-// the required `url_records` table and indexes exist.
+// the required `url_records`, `api_keys`, `users`, `pending_deletes`, `audit_log`
+// and `clicks` tables and indexes exist.
 // Panics via logger.Fatal if any step fails.
 func InitDB(ps string, logger *zap.Logger) *sql.DB {
 	db, err := sql.Open("pgx", ps)
@@ -52,6 +55,140 @@ func InitDB(ps string, logger *zap.Logger) *sql.DB {
 		logger.Fatal(err.Error())
 	}
 
+	// Covers the count of active records and users of GetStats with an index-only scan
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS url_records_active_user ON url_records (user_id) WHERE NOT is_deleted")
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	// Expiration, threat flags, passwords, activation windows, creation and deletion times, tags, titles, descriptions, pins, campaigns and visibility were added after the table, so existing databases get the columns too
+	for _, stmt := range []string{
+		"ALTER TABLE url_records ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ",
+		"CREATE INDEX IF NOT EXISTS url_records_expires_at ON url_records (expires_at) WHERE expires_at IS NOT NULL AND NOT is_deleted",
+		"ALTER TABLE url_records ADD COLUMN IF NOT EXISTS flagged_reason TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE url_records ADD COLUMN IF NOT EXISTS password_hash TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE url_records ADD COLUMN IF NOT EXISTS not_before TIMESTAMPTZ",
+		"ALTER TABLE url_records ADD COLUMN IF NOT EXISTS not_after TIMESTAMPTZ",
+		"ALTER TABLE url_records ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ",
+		"ALTER TABLE url_records ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ",
+		"ALTER TABLE url_records ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT '[]'",
+		"CREATE INDEX IF NOT EXISTS url_records_tags ON url_records USING gin (tags)",
+		"ALTER TABLE url_records ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE url_records ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE url_records ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT false",
+		"ALTER TABLE url_records ADD COLUMN IF NOT EXISTS campaign_id TEXT NOT NULL DEFAULT ''",
+		"CREATE INDEX IF NOT EXISTS url_records_campaign ON url_records (campaign_id) WHERE campaign_id <> ''",
+		"ALTER TABLE url_records ADD COLUMN IF NOT EXISTS private BOOLEAN NOT NULL DEFAULT false",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			logger.Fatal(err.Error())
+		}
+	}
+
+	// Searches of original URLs, titles and descriptions use trigram indexes; creating the extension needs
+	// privileges the database user may lack, in which case searches scan the records of the user
+	if _, err = db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm"); err != nil {
+		logger.Warn("pg_trgm is unavailable, searches are not indexed", zap.Error(err))
+	} else {
+		for _, column := range []string{"original_url", "title", "description"} {
+			if _, err = db.Exec("CREATE INDEX IF NOT EXISTS url_records_" + column + "_trgm ON url_records USING gin (" + column + " gin_trgm_ops)"); err != nil {
+				logger.Fatal(err.Error())
+			}
+		}
+	}
+
+	createAPIKeys := `
+		CREATE TABLE IF NOT EXISTS api_keys (
+		key_hash TEXT PRIMARY KEY,
+		user_id UUID NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now());`
+
+	_, err = db.Exec(createAPIKeys)
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	createUsers := `
+		CREATE TABLE IF NOT EXISTS users (
+		id UUID PRIMARY KEY,
+		display_name TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now());`
+
+	_, err = db.Exec(createUsers)
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	// Register users known only from their records, created before the users table existed
+	_, err = db.Exec(`INSERT INTO users (id) SELECT DISTINCT user_id FROM url_records
+		WHERE user_id IS NOT NULL ON CONFLICT (id) DO NOTHING;`)
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	createPendingDeletes := `
+		CREATE TABLE IF NOT EXISTS pending_deletes (
+		short_url TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		queued_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (short_url, user_id));`
+
+	_, err = db.Exec(createPendingDeletes)
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	createAuditLog := `
+		CREATE TABLE IF NOT EXISTS audit_log (
+		id BIGSERIAL PRIMARY KEY,
+		occurred_at TIMESTAMPTZ NOT NULL,
+		user_id TEXT NOT NULL,
+		action TEXT NOT NULL,
+		targets JSONB NOT NULL,
+		status INTEGER NOT NULL,
+		remote_addr TEXT NOT NULL,
+		request_id TEXT NOT NULL);`
+
+	// The audit log is append-only: updates and deletes are silently discarded
+	for _, stmt := range []string{
+		createAuditLog,
+		"CREATE INDEX IF NOT EXISTS audit_log_user ON audit_log (user_id, occurred_at)",
+		"CREATE OR REPLACE RULE audit_log_no_update AS ON UPDATE TO audit_log DO INSTEAD NOTHING",
+		"CREATE OR REPLACE RULE audit_log_no_delete AS ON DELETE TO audit_log DO INSTEAD NOTHING",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			logger.Fatal(err.Error())
+		}
+	}
+
+	// Campaign names are unique per user
+	createCampaigns := `
+		CREATE TABLE IF NOT EXISTS campaigns (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		UNIQUE (user_id, name));`
+
+	_, err = db.Exec(createCampaigns)
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	// Clicks are counted per short URL and storage.ClickBucket
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS clicks (
+		short_url TEXT NOT NULL,
+		bucket TIMESTAMPTZ NOT NULL,
+		clicks BIGINT NOT NULL,
+		PRIMARY KEY (short_url, bucket));`,
+		"CREATE INDEX IF NOT EXISTS clicks_bucket ON clicks (bucket)",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			logger.Fatal(err.Error())
+		}
+	}
+
 	return db
 }
 
@@ -59,85 +196,166 @@ func InitDB(ps string, logger *zap.Logger) *sql.DB {
 type URLRepository struct {
 	db     *sql.DB
 	logger *zap.Logger
+	stmts  *statements
+
+	// retry retries calls failing with transient errors.
+	retry worker.RetryPolicy
+	// breaker fails calls fast while the database is down, nil to never fail fast.
+	breaker *Breaker
+
+	// replica serves reads if set; replicaBreaker skips it while it is down.
+	replica        *statements
+	replicaBreaker *Breaker
+
+	// slowQueryThreshold is the duration above which operations are logged, 0 disables logging.
+	slowQueryThreshold atomic.Int64
 }
 
 // CreateURLRepository returns a new instance of URLRepository with the provided database and logger.
+// The statements of its hot paths are prepared on first use and reused; Close releases them.
+// Transient errors are retried and a circuit breaker fails calls fast while the
+// database is down, with the defaults until SetResilience is called.
 func CreateURLRepository(db *sql.DB, l *zap.Logger) *URLRepository {
-	return &URLRepository{
+	r := &URLRepository{
 		db:     db,
 		logger: l,
+		stmts:  &statements{db: db},
 	}
+	r.SetResilience(worker.RetryPolicy{MaxRetries: DefaultMaxRetries, Backoff: DefaultRetryBackoff}, NewBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown))
+	r.slowQueryThreshold.Store(int64(DefaultSlowQueryThreshold))
+	return r
 }
 
 // Write inserts a new URLRecord into the database.
-// If the original URL already exists, it returns the existing record and ErrConflict.
+// If the original URL already exists, it returns the stored record, with its
+// short URL rather than the one of v, and storage.ErrConflict.
 func (r *URLRepository) Write(ctx context.Context, v storage.URLRecord) (*storage.URLRecord, error) {
+	return resilientValue(ctx, r, "Write", func(ctx context.Context) (*storage.URLRecord, error) {
+		return r.write(ctx, v)
+	})
+}
+
+// write is a single attempt of Write.
+func (r *URLRepository) write(ctx context.Context, v storage.URLRecord) (*storage.URLRecord, error) {
+	ctx, op := r.startOperation(ctx, "Write", "INSERT url_records")
+	defer op.End()
+
+	tags, err := marshalTags(v.Tags)
+	if err != nil {
+		return nil, err
+	}
+
 	var existing = v
+	var inserted bool
 
-	err := r.db.QueryRowContext(ctx,
-		`INSERT INTO url_records(original_url, short_url, id, user_id) 
-		 VALUES ($1, $2, COALESCE(NULLIF($3, '')::UUID, gen_random_uuid()), $4)
-		 ON CONFLICT (original_url) DO NOTHING 
-		 RETURNING original_url, short_url, id, user_id;`,
-		v.Original, v.Short, v.ID, v.UserID,
-	).Scan(&existing.Original, &existing.Short, &existing.ID, &existing.UserID)
+	// The inserted row, or the stored one on conflict, is returned by the same query
+	err = r.stmts.queryRow(ctx,
+		`WITH ins AS (
+			INSERT INTO url_records(original_url, short_url, id, user_id, expires_at, not_before, not_after, created_at, tags, title, description) 
+			VALUES ($1, $2, COALESCE(NULLIF($3, '')::UUID, gen_random_uuid()), $4, $5, $6, $7, COALESCE($8, now()), $9, $10, $11)
+			ON CONFLICT (original_url) DO NOTHING 
+			RETURNING original_url, short_url, id, user_id
+		 )
+		 SELECT original_url, short_url, id::TEXT, COALESCE(user_id::TEXT, ''), TRUE FROM ins
+		 UNION ALL
+		 SELECT original_url, short_url, id::TEXT, COALESCE(user_id::TEXT, ''), FALSE FROM url_records
+		 WHERE original_url = $1 AND NOT EXISTS (SELECT 1 FROM ins);`,
+		v.Original, v.Short, v.ID, v.UserID, v.ExpiresAt, v.NotBefore, v.NotAfter, v.CreatedAt, tags, v.Title, v.Description,
+	).Scan(&existing.Original, &existing.Short, &existing.ID, &existing.UserID, &inserted)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		// The conflicting row was committed after the query took its snapshot,
+		// so it is only visible to a new query
+		err = r.db.QueryRowContext(ctx,
+			`SELECT original_url, short_url, id::TEXT, COALESCE(user_id::TEXT, '') FROM url_records WHERE original_url = $1;`,
+			v.Original,
+		).Scan(&existing.Original, &existing.Short, &existing.ID, &existing.UserID)
+	}
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return &existing, ErrConflict
-		}
-		r.logger.Error("Write error=, while INSERT", zap.String("error", err.Error()))
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("Write error=, while INSERT", zap.String("error", err.Error()))
 		return nil, err
 	}
+	if !inserted {
+		return &existing, storage.ErrConflict
+	}
 
-	r.logger.Info("Insert successful!")
+	op.SetRows(1)
+	logger.FromContext(ctx, r.logger).Info("Insert successful!")
 	return &existing, nil
 }
 
 // WriteAll inserts multiple URLRecords within a single transaction.
-// Returns ErrConflict if any record violates a unique constraint.
+// Returns storage.ErrConflict if any record violates a unique constraint,
+// such as an original URL stored before; no record is inserted then.
 func (r *URLRepository) WriteAll(ctx context.Context, rs []storage.URLRecord) error {
-	tx, err := r.db.Begin()
+	return r.resilient(ctx, "WriteAll", func(ctx context.Context) error {
+		return r.writeAll(ctx, rs)
+	})
+}
+
+// writeAll is a single attempt of WriteAll.
+func (r *URLRepository) writeAll(ctx context.Context, rs []storage.URLRecord) error {
+	ctx, op := r.startOperation(ctx, "WriteAll", "INSERT url_records")
+	defer op.End()
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 
 	defer func() {
 		err := tx.Rollback()
-		if err != nil {
-			r.logger.Error("ROLLBACK error=", zap.String("error", err.Error()))
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logger.FromContext(ctx, r.logger).Error("ROLLBACK error=", zap.String("error", err.Error()))
 		}
 	}()
 
-	stmt, err := tx.Prepare(`
-		INSERT INTO url_records(original_url, short_url, id, user_id) 
-		VALUES ($1, $2, $3, $4) 
-		ON CONFLICT (original_url) DO NOTHING 
-		RETURNING original_url, short_url, id, user_id;
+	// Without ON CONFLICT, a URL stored before fails the whole batch with a
+	// unique violation instead of being skipped as if it had been inserted
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO url_records(original_url, short_url, id, user_id, expires_at, not_before, not_after, created_at, tags, title, description) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, now()), $9, $10, $11);
 	`)
 	if err != nil {
 		return err
 	}
+	defer stmt.Close()
 
 	for _, v := range rs {
-		defer stmt.Close()
-		_, err = stmt.ExecContext(ctx, v.Original, v.Short, v.ID, v.UserID)
+		tags, err := marshalTags(v.Tags)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.ExecContext(ctx, v.Original, v.Short, v.ID, v.UserID, v.ExpiresAt, v.NotBefore, v.NotAfter, v.CreatedAt, tags, v.Title, v.Description)
 
 		if err != nil {
 			var pgErr *pgconn.PgError
 			if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-				return ErrConflict
+				return storage.ErrConflict
 			}
 			return err
 		}
 	}
 
+	op.SetRows(len(rs))
 	return tx.Commit()
 }
 
-// Read retrieves all records from the url_records table.
+// Read retrieves all records from the url_records table, including deleted ones.
 func (r *URLRepository) Read(ctx context.Context) ([]storage.URLRecord, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT * FROM url_records;")
+	return readValue(ctx, r, "Read", func(ctx context.Context, st *statements) ([]storage.URLRecord, error) {
+		return r.read(ctx, st)
+	})
+}
+
+// read is a single attempt of Read on the database of st.
+func (r *URLRepository) read(ctx context.Context, st *statements) ([]storage.URLRecord, error) {
+	ctx, op := r.startOperation(ctx, "Read", "SELECT url_records")
+	defer op.End()
+
+	rows, err := st.db.QueryContext(ctx, `SELECT id, original_url, short_url, COALESCE(user_id::TEXT, ''), is_deleted, expires_at
+		FROM url_records;`)
 	if err != nil {
 		return nil, err
 	}
@@ -147,10 +365,14 @@ func (r *URLRepository) Read(ctx context.Context) ([]storage.URLRecord, error) {
 
 	for rows.Next() {
 		var r storage.URLRecord
-		err = rows.Scan(&r.ID, &r.Original, &r.Short, &r.UserID)
+		var isDeleted sql.NullBool
+		var expiresAt sql.NullTime
+		err = rows.Scan(&r.ID, &r.Original, &r.Short, &r.UserID, &isDeleted, &expiresAt)
 		if err != nil {
 			return nil, err
 		}
+		r.IsDeleted = isDeleted.Bool
+		r.ExpiresAt = nullTimePtr(expiresAt)
 		records = append(records, r)
 	}
 
@@ -158,34 +380,361 @@ func (r *URLRepository) Read(ctx context.Context) ([]storage.URLRecord, error) {
 		return nil, err
 	}
 
+	op.SetRows(len(records))
 	return records, nil
 }
 
 // FindByShort retrieves a URLRecord by its short URL.
+// It returns storage.ErrNotFound if there is no such short URL.
 func (r *URLRepository) FindByShort(ctx context.Context, s string) (*storage.URLRecord, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT id, original_url, short_url, user_id, is_deleted 
+	return readValue(ctx, r, "FindByShort", func(ctx context.Context, st *statements) (*storage.URLRecord, error) {
+		return r.findByShort(ctx, st, s)
+	})
+}
+
+// findByShort is a single attempt of FindByShort on the database of st.
+func (r *URLRepository) findByShort(ctx context.Context, st *statements, s string) (*storage.URLRecord, error) {
+	ctx, op := r.startOperation(ctx, "FindByShort", "SELECT url_records")
+	defer op.End()
+
+	row := st.queryRow(ctx, `SELECT id, original_url, short_url, user_id, is_deleted, expires_at, flagged_reason, password_hash, not_before, not_after, created_at, tags, title, description, pinned, campaign_id, private 
 	FROM url_records WHERE short_url = $1;`, s)
 
-	var id, originalURL, shortURL, userID string
-	var IsDeleted bool
+	var id, originalURL, shortURL, userID, flagged, passwordHash, tags, title, description, campaign string
+	var tagList []string
+	var IsDeleted, pinned, private bool
+	var expiresAt, notBefore, notAfter, createdAt sql.NullTime
 
-	err := row.Scan(&id, &originalURL, &shortURL, &userID, &IsDeleted)
+	err := row.Scan(&id, &originalURL, &shortURL, &userID, &IsDeleted, &expiresAt, &flagged, &passwordHash, &notBefore, &notAfter, &createdAt, &tags, &title, &description, &pinned, &campaign, &private)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err == nil {
+		err = json.Unmarshal([]byte(tags), &tagList)
+	}
 	if err != nil {
-		r.logger.Error("FindByShort err=", zap.String("error", err.Error()))
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("FindByShort err=", zap.String("error", err.Error()))
 		return nil, err
 	}
+	op.SetRows(1)
 
 	return &storage.URLRecord{
-		ID:        id,
-		Original:  originalURL,
-		Short:     shortURL,
-		UserID:    userID,
-		IsDeleted: IsDeleted,
+		ID:           id,
+		Original:     originalURL,
+		Short:        shortURL,
+		UserID:       userID,
+		IsDeleted:    IsDeleted,
+		ExpiresAt:    nullTimePtr(expiresAt),
+		Flagged:      flagged,
+		PasswordHash: passwordHash,
+		NotBefore:    nullTimePtr(notBefore),
+		NotAfter:     nullTimePtr(notAfter),
+		CreatedAt:    nullTimePtr(createdAt),
+		Tags:         tagList,
+		Title:        title,
+		Description:  description,
+		Pinned:       pinned,
+		Campaign:     campaign,
+		Private:      private,
 	}, nil
 }
 
-// DeleteBatch marks a list of URLRecords as deleted by setting is_deleted = TRUE.
+// FlagURL records the threat the original URL of the short URL was flagged for.
+// It returns storage.ErrNotFound if there is no such short URL.
+func (r *URLRepository) FlagURL(ctx context.Context, short string, reason string) error {
+	return r.resilient(ctx, "FlagURL", func(ctx context.Context) error {
+		return r.flagURL(ctx, short, reason)
+	})
+}
+
+// flagURL is a single attempt of FlagURL.
+func (r *URLRepository) flagURL(ctx context.Context, short string, reason string) error {
+	ctx, op := r.startOperation(ctx, "FlagURL", "UPDATE url_records")
+	defer op.End()
+
+	res, err := r.db.ExecContext(ctx, "UPDATE url_records SET flagged_reason = $1 WHERE short_url = $2;", reason, short)
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("FlagURL error=", zap.String("error", err.Error()))
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return storage.ErrNotFound
+	}
+	op.SetRows(int(rows))
+	return nil
+}
+
+// SetPassword sets the password hash of the user's short URL; an empty hash
+// removes the protection. It returns storage.ErrNotFound if the user does not
+// own the short URL and storage.ErrDeleted if it was deleted.
+func (r *URLRepository) SetPassword(ctx context.Context, short string, userID string, hash string) error {
+	return r.resilient(ctx, "SetPassword", func(ctx context.Context) error {
+		return r.setPassword(ctx, short, userID, hash)
+	})
+}
+
+// setPassword is a single attempt of SetPassword.
+func (r *URLRepository) setPassword(ctx context.Context, short string, userID string, hash string) error {
+	ctx, op := r.startOperation(ctx, "SetPassword", "UPDATE url_records")
+	defer op.End()
+
+	// Deleted records are matched but left unchanged, so they can be told apart from missing ones
+	var deleted bool
+	err := r.db.QueryRowContext(ctx, `UPDATE url_records SET password_hash = CASE WHEN is_deleted THEN password_hash ELSE $1 END
+		WHERE short_url = $2 AND user_id = $3 RETURNING is_deleted;`, hash, short, userID).Scan(&deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("SetPassword error=", zap.String("error", err.Error()))
+		return err
+	}
+	if deleted {
+		return storage.ErrDeleted
+	}
+	op.SetRows(1)
+	return nil
+}
+
+// SetTags replaces the tags of the user's short URL. It returns
+// storage.ErrNotFound if the user does not own the short URL and
+// storage.ErrDeleted if it was deleted.
+func (r *URLRepository) SetTags(ctx context.Context, short string, userID string, tags []string) error {
+	return r.resilient(ctx, "SetTags", func(ctx context.Context) error {
+		return r.setTags(ctx, short, userID, tags)
+	})
+}
+
+// setTags is a single attempt of SetTags.
+func (r *URLRepository) setTags(ctx context.Context, short string, userID string, tags []string) error {
+	ctx, op := r.startOperation(ctx, "SetTags", "UPDATE url_records")
+	defer op.End()
+
+	value, err := marshalTags(tags)
+	if err != nil {
+		return err
+	}
+
+	// Deleted records are matched but left unchanged, so they can be told apart from missing ones
+	var deleted bool
+	err = r.db.QueryRowContext(ctx, `UPDATE url_records SET tags = CASE WHEN is_deleted THEN tags ELSE $1::JSONB END
+		WHERE short_url = $2 AND user_id = $3 RETURNING is_deleted;`, value, short, userID).Scan(&deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("SetTags error=", zap.String("error", err.Error()))
+		return err
+	}
+	if deleted {
+		return storage.ErrDeleted
+	}
+	op.SetRows(1)
+	return nil
+}
+
+// SetPinned pins the user's short URL to the top of their listing or unpins
+// it. It returns storage.ErrNotFound if the user does not own the short URL
+// and storage.ErrDeleted if it was deleted.
+func (r *URLRepository) SetPinned(ctx context.Context, short string, userID string, pinned bool) error {
+	return r.resilient(ctx, "SetPinned", func(ctx context.Context) error {
+		return r.setPinned(ctx, short, userID, pinned)
+	})
+}
+
+// setPinned is a single attempt of SetPinned.
+func (r *URLRepository) setPinned(ctx context.Context, short string, userID string, pinned bool) error {
+	ctx, op := r.startOperation(ctx, "SetPinned", "UPDATE url_records")
+	defer op.End()
+
+	// Deleted records are matched but left unchanged, so they can be told apart from missing ones
+	var deleted bool
+	err := r.db.QueryRowContext(ctx, `UPDATE url_records SET pinned = CASE WHEN is_deleted THEN pinned ELSE $1 END
+		WHERE short_url = $2 AND user_id = $3 RETURNING is_deleted;`, pinned, short, userID).Scan(&deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("SetPinned error=", zap.String("error", err.Error()))
+		return err
+	}
+	if deleted {
+		return storage.ErrDeleted
+	}
+	op.SetRows(1)
+	return nil
+}
+
+// SetPrivate makes the metadata and stats of the user's short URL visible to
+// its owner only, or to anyone. It returns storage.ErrNotFound if the user
+// does not own the short URL and storage.ErrDeleted if it was deleted.
+func (r *URLRepository) SetPrivate(ctx context.Context, short string, userID string, private bool) error {
+	return r.resilient(ctx, "SetPrivate", func(ctx context.Context) error {
+		return r.setPrivate(ctx, short, userID, private)
+	})
+}
+
+// setPrivate is a single attempt of SetPrivate.
+func (r *URLRepository) setPrivate(ctx context.Context, short string, userID string, private bool) error {
+	ctx, op := r.startOperation(ctx, "SetPrivate", "UPDATE url_records")
+	defer op.End()
+
+	// Deleted records are matched but left unchanged, so they can be told apart from missing ones
+	var deleted bool
+	err := r.db.QueryRowContext(ctx, `UPDATE url_records SET private = CASE WHEN is_deleted THEN private ELSE $1 END
+		WHERE short_url = $2 AND user_id = $3 RETURNING is_deleted;`, private, short, userID).Scan(&deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("SetPrivate error=", zap.String("error", err.Error()))
+		return err
+	}
+	if deleted {
+		return storage.ErrDeleted
+	}
+	op.SetRows(1)
+	return nil
+}
+
+// SetCampaign moves the user's short URL to the campaign with the given ID;
+// an empty ID removes it from its campaign. It returns storage.ErrNotFound if
+// the user does not own the short URL and storage.ErrDeleted if it was deleted.
+func (r *URLRepository) SetCampaign(ctx context.Context, short string, userID string, campaignID string) error {
+	return r.resilient(ctx, "SetCampaign", func(ctx context.Context) error {
+		return r.setCampaign(ctx, short, userID, campaignID)
+	})
+}
+
+// setCampaign is a single attempt of SetCampaign.
+func (r *URLRepository) setCampaign(ctx context.Context, short string, userID string, campaignID string) error {
+	ctx, op := r.startOperation(ctx, "SetCampaign", "UPDATE url_records")
+	defer op.End()
+
+	// Deleted records are matched but left unchanged, so they can be told apart from missing ones
+	var deleted bool
+	err := r.db.QueryRowContext(ctx, `UPDATE url_records SET campaign_id = CASE WHEN is_deleted THEN campaign_id ELSE $1 END
+		WHERE short_url = $2 AND user_id = $3 RETURNING is_deleted;`, campaignID, short, userID).Scan(&deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("SetCampaign error=", zap.String("error", err.Error()))
+		return err
+	}
+	if deleted {
+		return storage.ErrDeleted
+	}
+	op.SetRows(1)
+	return nil
+}
+
+// SetDetails sets the title and description of the user's short URL. It
+// returns storage.ErrNotFound if the user does not own the short URL and
+// storage.ErrDeleted if it was deleted.
+func (r *URLRepository) SetDetails(ctx context.Context, short string, userID string, title string, description string) error {
+	return r.resilient(ctx, "SetDetails", func(ctx context.Context) error {
+		return r.setDetails(ctx, short, userID, title, description)
+	})
+}
+
+// setDetails is a single attempt of SetDetails.
+func (r *URLRepository) setDetails(ctx context.Context, short string, userID string, title string, description string) error {
+	ctx, op := r.startOperation(ctx, "SetDetails", "UPDATE url_records")
+	defer op.End()
+
+	// Deleted records are matched but left unchanged, so they can be told apart from missing ones
+	var deleted bool
+	err := r.db.QueryRowContext(ctx, `UPDATE url_records SET title = CASE WHEN is_deleted THEN title ELSE $1 END,
+		description = CASE WHEN is_deleted THEN description ELSE $2 END
+		WHERE short_url = $3 AND user_id = $4 RETURNING is_deleted;`, title, description, short, userID).Scan(&deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("SetDetails error=", zap.String("error", err.Error()))
+		return err
+	}
+	if deleted {
+		return storage.ErrDeleted
+	}
+	op.SetRows(1)
+	return nil
+}
+
+// SetDefaultTitle sets the title of the short URL unless it has one or was
+// deleted. It returns storage.ErrNotFound if there is no such short URL.
+func (r *URLRepository) SetDefaultTitle(ctx context.Context, short string, title string) error {
+	return r.resilient(ctx, "SetDefaultTitle", func(ctx context.Context) error {
+		return r.setDefaultTitle(ctx, short, title)
+	})
+}
+
+// setDefaultTitle is a single attempt of SetDefaultTitle.
+func (r *URLRepository) setDefaultTitle(ctx context.Context, short string, title string) error {
+	ctx, op := r.startOperation(ctx, "SetDefaultTitle", "UPDATE url_records")
+	defer op.End()
+
+	// Titled and deleted records are matched but left unchanged, so they can be told apart from missing ones
+	var matched int
+	err := r.db.QueryRowContext(ctx, `UPDATE url_records SET title = CASE WHEN title = '' AND NOT is_deleted THEN $1 ELSE title END
+		WHERE short_url = $2 RETURNING 1;`, title, short).Scan(&matched)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("SetDefaultTitle error=", zap.String("error", err.Error()))
+		return err
+	}
+	op.SetRows(matched)
+	return nil
+}
+
+// marshalTags encodes the tags as the JSON array stored in the tags column;
+// no tags are stored as an empty array.
+func marshalTags(tags []string) (string, error) {
+	if len(tags) == 0 {
+		return "[]", nil
+	}
+	b, err := json.Marshal(tags)
+	return string(b), err
+}
+
+// nullTimePtr returns a pointer to the time, nil if it is NULL.
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// DeleteBatch marks a list of URLRecords as deleted by setting is_deleted = TRUE,
+// recording when they were first deleted.
 func (r *URLRepository) DeleteBatch(ctx context.Context, rs []storage.URLRecord) error {
+	return r.resilient(ctx, "DeleteBatch", func(ctx context.Context) error {
+		return r.deleteBatch(ctx, rs)
+	})
+}
+
+// deleteBatch is a single attempt of DeleteBatch.
+func (r *URLRepository) deleteBatch(ctx context.Context, rs []storage.URLRecord) error {
+	ctx, op := r.startOperation(ctx, "DeleteBatch", "UPDATE url_records")
+	defer op.End()
+
 	tx, err := r.db.Begin()
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
@@ -194,13 +743,13 @@ func (r *URLRepository) DeleteBatch(ctx context.Context, rs []storage.URLRecord)
 	defer func() {
 		err := tx.Rollback()
 		if err != nil {
-			r.logger.Error("ROLLBACK error=", zap.String("error", err.Error()))
+			logger.FromContext(ctx, r.logger).Error("ROLLBACK error=", zap.String("error", err.Error()))
 		}
 	}()
 
 	stmt, err := tx.Prepare(`
 		UPDATE url_records 
-		SET is_deleted = TRUE 
+		SET is_deleted = TRUE, deleted_at = COALESCE(deleted_at, now()) 
 		WHERE short_url = $1 AND user_id = $2
 	`)
 	if err != nil {
@@ -211,16 +760,146 @@ func (r *URLRepository) DeleteBatch(ctx context.Context, rs []storage.URLRecord)
 	for _, v := range rs {
 		_, err = stmt.ExecContext(ctx, v.Short, v.UserID)
 		if err != nil {
+			op.RecordError(err)
 			return err
 		}
 	}
 
+	op.SetRows(len(rs))
 	return tx.Commit()
 }
 
+// FindExpired returns up to limit records that are not deleted and expired at
+// or before now, oldest expiration first; limit 0 returns all of them.
+func (r *URLRepository) FindExpired(ctx context.Context, now time.Time, limit int) ([]storage.URLRecord, error) {
+	return resilientValue(ctx, r, "FindExpired", func(ctx context.Context) ([]storage.URLRecord, error) {
+		return r.findExpired(ctx, now, limit)
+	})
+}
+
+// findExpired is a single attempt of FindExpired.
+func (r *URLRepository) findExpired(ctx context.Context, now time.Time, limit int) ([]storage.URLRecord, error) {
+	ctx, op := r.startOperation(ctx, "FindExpired", "SELECT url_records")
+	defer op.End()
+
+	query := `SELECT id, original_url, short_url, user_id, expires_at FROM url_records
+		WHERE expires_at IS NOT NULL AND expires_at <= $1 AND NOT is_deleted
+		ORDER BY expires_at`
+	args := []any{now}
+	if limit > 0 {
+		query += " LIMIT $2"
+		args = append(args, limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query+";", args...)
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("FindExpired error=", zap.String("error", err.Error()))
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]storage.URLRecord, 0)
+	for rows.Next() {
+		var rec storage.URLRecord
+		var expiresAt time.Time
+		if err := rows.Scan(&rec.ID, &rec.Original, &rec.Short, &rec.UserID, &expiresAt); err != nil {
+			return nil, err
+		}
+		rec.ExpiresAt = &expiresAt
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	op.SetRows(len(records))
+	return records, nil
+}
+
+// execBatch runs the statement once per record, with the record's short URL
+// and user ID as arguments, within a single transaction.
+func (r *URLRepository) execBatch(ctx context.Context, op *operation, query string, rs []storage.URLRecord) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logger.FromContext(ctx, r.logger).Error("ROLLBACK error=", zap.String("error", err.Error()))
+		}
+	}()
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, v := range rs {
+		if _, err = stmt.ExecContext(ctx, v.Short, v.UserID); err != nil {
+			op.RecordError(err)
+			return err
+		}
+	}
+
+	op.SetRows(len(rs))
+	return tx.Commit()
+}
+
+// AppendPending records deletions that were accepted but not yet applied.
+func (r *URLRepository) AppendPending(ctx context.Context, rs []storage.URLRecord) error {
+	ctx, op := r.startOperation(ctx, "AppendPending", "INSERT pending_deletes")
+	defer op.End()
+
+	return r.execBatch(ctx, op, `INSERT INTO pending_deletes (short_url, user_id) VALUES ($1, $2)
+		ON CONFLICT (short_url, user_id) DO NOTHING`, rs)
+}
+
+// RemovePending removes deletions that were applied from pending_deletes.
+func (r *URLRepository) RemovePending(ctx context.Context, rs []storage.URLRecord) error {
+	ctx, op := r.startOperation(ctx, "RemovePending", "DELETE pending_deletes")
+	defer op.End()
+
+	return r.execBatch(ctx, op, "DELETE FROM pending_deletes WHERE short_url = $1 AND user_id = $2", rs)
+}
+
+// ReadPending returns the deletions that were accepted but not applied, oldest first.
+func (r *URLRepository) ReadPending(ctx context.Context) ([]storage.URLRecord, error) {
+	ctx, op := r.startOperation(ctx, "ReadPending", "SELECT pending_deletes")
+	defer op.End()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT short_url, user_id FROM pending_deletes ORDER BY queued_at;")
+	if err != nil {
+		op.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]storage.URLRecord, 0)
+	for rows.Next() {
+		var rec storage.URLRecord
+		if err := rows.Scan(&rec.Short, &rec.UserID); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	op.SetRows(len(records))
+	return records, nil
+}
+
 // FindByLong fetches a URLRecord using its long/original URL.
 // NOTE: This method currently uses short_url in WHERE clause, which seems incorrect.
 func (r *URLRepository) FindByLong(ctx context.Context, long string) (*storage.URLRecord, error) {
+	ctx, op := r.startOperation(ctx, "FindByLong", "SELECT url_records")
+	defer op.End()
+
 	row := r.db.QueryRowContext(ctx, `SELECT id, original_url, short_url, user_id, is_deleted 
 	FROM url_records WHERE short_url = $1;`, long)
 
@@ -228,8 +907,11 @@ func (r *URLRepository) FindByLong(ctx context.Context, long string) (*storage.U
 	var IsDeleted bool
 
 	err := row.Scan(&id, &originalURL, &shortURL, &userID, &IsDeleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
 	if err != nil {
-		r.logger.Error("FindByLong err=", zap.String("error", err.Error()))
+		logger.FromContext(ctx, r.logger).Error("FindByLong err=", zap.String("error", err.Error()))
 		return nil, err
 	}
 
@@ -243,15 +925,30 @@ func (r *URLRepository) FindByLong(ctx context.Context, long string) (*storage.U
 }
 
 // FindByID retrieves a URLRecord by its unique ID.
+// It returns storage.ErrNotFound if there is no record with the ID.
 func (r *URLRepository) FindByID(ctx context.Context, s string) (storage.URLRecord, error) {
-	row := r.db.QueryRowContext(ctx, "SELECT * FROM url_records WHERE id = $1;", s)
+	return resilientValue(ctx, r, "FindByID", func(ctx context.Context) (storage.URLRecord, error) {
+		return r.findByID(ctx, s)
+	})
+}
+
+// findByID is a single attempt of FindByID.
+func (r *URLRepository) findByID(ctx context.Context, s string) (storage.URLRecord, error) {
+	ctx, op := r.startOperation(ctx, "FindByID", "SELECT url_records")
+	defer op.End()
+
+	row := r.db.QueryRowContext(ctx, "SELECT id, original_url, short_url, user_id FROM url_records WHERE id = $1;", s)
 
 	var id, original, short, userID string
 
 	err := row.Scan(&id, &original, &short, &userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.URLRecord{}, storage.ErrNotFound
+	}
 	if err != nil {
-		r.logger.Error("FindByID error=", zap.String("error", err.Error()))
-		return storage.URLRecord{}, nil
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("FindByID error=", zap.String("error", err.Error()))
+		return storage.URLRecord{}, err
 	}
 
 	return storage.URLRecord{
@@ -262,36 +959,650 @@ func (r *URLRepository) FindByID(ctx context.Context, s string) (storage.URLReco
 	}, nil
 }
 
-// FindByUserID retrieves all URLRecords created by a specific user.
-func (r *URLRepository) FindByUserID(ctx context.Context, userID string) (*[]storage.URLRecord, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT id, original_url, short_url, user_id FROM url_records WHERE user_id = $1;", userID)
+// likeEscaper escapes LIKE wildcards so user input is matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// buildFindByUserIDQuery composes the listing query for a user, pushing
+// filtering, ordering and pagination from opts down to the database.
+// Sort fields are validated by the caller, so they are interpolated as column names.
+func buildFindByUserIDQuery(userID string, opts storage.ListOptions) (string, []any) {
+	var sb strings.Builder
+	args := []any{userID}
+
+	sb.WriteString("SELECT id, original_url, short_url, user_id, tags, title, description, pinned, campaign_id FROM url_records WHERE user_id = $1")
+
+	// Every search term must occur in the original URL, the title or the
+	// description; the trigram indexes serve these patterns
+	for _, term := range opts.Terms() {
+		args = append(args, likeEscaper.Replace(term))
+		n := len(args)
+		fmt.Fprintf(&sb, " AND (original_url ILIKE '%%' || $%d || '%%' OR title ILIKE '%%' || $%d || '%%' OR description ILIKE '%%' || $%d || '%%')", n, n, n)
+	}
+
+	// The GIN index on tags serves the containment test
+	if opts.Tag != "" {
+		args = append(args, opts.Tag)
+		fmt.Fprintf(&sb, " AND tags ? $%d", len(args))
+	}
+
+	if opts.Campaign != "" {
+		args = append(args, opts.Campaign)
+		fmt.Fprintf(&sb, " AND campaign_id = $%d", len(args))
+	}
+
+	// The order always ends with a unique column, so that pages neither
+	// overlap nor skip rows: both sort fields are unique, and listings without
+	// one are in creation order like in the other backends
+	var order []string
+	if opts.PinnedFirst {
+		order = append(order, "pinned DESC")
+	}
+	if field, desc := opts.SortField(); field != "" {
+		if desc {
+			field += " DESC"
+		}
+		order = append(order, field)
+	} else {
+		order = append(order, "created_at", "short_url")
+	}
+	sb.WriteString(" ORDER BY " + strings.Join(order, ", "))
+
+	if opts.Limit > 0 {
+		args = append(args, opts.Limit)
+		fmt.Fprintf(&sb, " LIMIT $%d", len(args))
+	}
+
+	if opts.Offset > 0 {
+		args = append(args, opts.Offset)
+		fmt.Fprintf(&sb, " OFFSET $%d", len(args))
+	}
+
+	sb.WriteString(";")
+	return sb.String(), args
+}
+
+// FindByUserID retrieves the URLRecords created by a specific user,
+// filtered, ordered and paginated according to opts.
+func (r *URLRepository) FindByUserID(ctx context.Context, userID string, opts storage.ListOptions) (*[]storage.URLRecord, error) {
+	return readValue(ctx, r, "FindByUserID", func(ctx context.Context, st *statements) (*[]storage.URLRecord, error) {
+		return r.findByUserID(ctx, st, userID, opts)
+	})
+}
+
+// findByUserID is a single attempt of FindByUserID on the database of st.
+func (r *URLRepository) findByUserID(ctx context.Context, st *statements, userID string, opts storage.ListOptions) (*[]storage.URLRecord, error) {
+	ctx, op := r.startOperation(ctx, "FindByUserID", "SELECT url_records")
+	defer op.End()
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	query, args := buildFindByUserIDQuery(userID, opts)
+	rows, err := st.query(ctx, query, args...)
 	if err != nil {
-		r.logger.Error(fmt.Sprintf("FindByUserID error=%s", err.Error()))
-		return &[]storage.URLRecord{}, nil
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error(fmt.Sprintf("FindByUserID error=%s", err.Error()))
+		return nil, err
 	}
 	defer rows.Close()
 
 	res := make([]storage.URLRecord, 0)
 
 	for rows.Next() {
-		var id, original, short, userID string
+		var id, original, short, userID, tags, title, description, campaign string
+		var tagList []string
+		var pinned bool
 
-		err := rows.Scan(&id, &original, &short, &userID)
+		err := rows.Scan(&id, &original, &short, &userID, &tags, &title, &description, &pinned, &campaign)
+		if err == nil {
+			err = json.Unmarshal([]byte(tags), &tagList)
+		}
 		if err != nil {
-			r.logger.Error(fmt.Sprintf("FindByUserID error=%s", err.Error()))
+			op.RecordError(err)
+			logger.FromContext(ctx, r.logger).Error(fmt.Sprintf("FindByUserID error=%s", err.Error()))
 			return nil, nil
 		}
 
-		res = append(res, storage.URLRecord{ID: id, Original: original, Short: short, UserID: userID})
+		res = append(res, storage.URLRecord{ID: id, Original: original, Short: short, UserID: userID, Tags: tagList, Title: title, Description: description, Pinned: pinned, Campaign: campaign})
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
+	op.SetRows(len(res))
 	return &res, nil
 }
 
+// CountByUserID returns the number of URL records of the user that are not marked as deleted.
+func (r *URLRepository) CountByUserID(ctx context.Context, userID string) (int, error) {
+	return resilientValue(ctx, r, "CountByUserID", func(ctx context.Context) (int, error) {
+		return r.countByUserID(ctx, userID)
+	})
+}
+
+// countByUserID is a single attempt of CountByUserID.
+func (r *URLRepository) countByUserID(ctx context.Context, userID string) (int, error) {
+	ctx, op := r.startOperation(ctx, "CountByUserID", "SELECT url_records")
+	defer op.End()
+
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM url_records WHERE user_id = $1 AND NOT is_deleted;", userID).Scan(&count)
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("CountByUserID error=", zap.String("error", err.Error()))
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetStats returns the number of active URL records and of distinct users
+// owning them, counted in a single query so that no rows leave the database.
+func (r *URLRepository) GetStats(ctx context.Context) (int, int, error) {
+	var users int
+	urls, err := readValue(ctx, r, "GetStats", func(ctx context.Context, st *statements) (int, error) {
+		urls, u, err := r.getStats(ctx, st)
+		users = u
+		return urls, err
+	})
+	return urls, users, err
+}
+
+// getStats is a single attempt of GetStats on the database of st.
+func (r *URLRepository) getStats(ctx context.Context, st *statements) (int, int, error) {
+	ctx, op := r.startOperation(ctx, "GetStats", "SELECT url_records")
+	defer op.End()
+
+	var urls, users int
+	if err := st.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COUNT(DISTINCT user_id) FROM url_records WHERE NOT is_deleted;",
+	).Scan(&urls, &users); err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("GetStats error=", zap.String("error", err.Error()))
+		return 0, 0, err
+	}
+
+	return urls, users, nil
+}
+
+// GetDailyStats returns the number of records created and deleted on each day
+// from from until to, in UTC, oldest first. Days without changes are omitted.
+func (r *URLRepository) GetDailyStats(ctx context.Context, from, to time.Time) ([]storage.DailyStats, error) {
+	return resilientValue(ctx, r, "GetDailyStats", func(ctx context.Context) ([]storage.DailyStats, error) {
+		return r.getDailyStats(ctx, from, to)
+	})
+}
+
+// getDailyStats is a single attempt of GetDailyStats.
+func (r *URLRepository) getDailyStats(ctx context.Context, from, to time.Time) ([]storage.DailyStats, error) {
+	ctx, op := r.startOperation(ctx, "GetDailyStats", "SELECT url_records")
+	defer op.End()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT day, SUM(created), SUM(deleted) FROM (
+		SELECT date_trunc('day', created_at AT TIME ZONE 'UTC') AS day, 1 AS created, 0 AS deleted
+		FROM url_records WHERE created_at >= $1 AND created_at < $2
+		UNION ALL
+		SELECT date_trunc('day', deleted_at AT TIME ZONE 'UTC'), 0, 1
+		FROM url_records WHERE deleted_at >= $1 AND deleted_at < $2
+	) changes GROUP BY day ORDER BY day;`, from, to)
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("GetDailyStats error=", zap.String("error", err.Error()))
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]storage.DailyStats, 0)
+	for rows.Next() {
+		var d storage.DailyStats
+		if err := rows.Scan(&d.Day, &d.Created, &d.Deleted); err != nil {
+			return nil, err
+		}
+		d.Day = time.Date(d.Day.Year(), d.Day.Month(), d.Day.Day(), 0, 0, 0, 0, time.UTC)
+		res = append(res, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	op.SetRows(len(res))
+	return res, nil
+}
+
+// PurgeDeleted permanently removes the deleted URL records and returns how
+// many were removed. Their short URLs can be issued again afterwards.
+func (r *URLRepository) PurgeDeleted(ctx context.Context) (int64, error) {
+	ctx, op := r.startOperation(ctx, "PurgeDeleted", "DELETE url_records")
+	defer op.End()
+
+	res, err := r.db.ExecContext(ctx, "DELETE FROM url_records WHERE is_deleted;")
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("PurgeDeleted error=", zap.String("error", err.Error()))
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	op.SetRows(int(n))
+	return n, nil
+}
+
+// Reindex rebuilds the indexes of the tables created by InitDB, for example
+// after a purge left them bloated.
+func (r *URLRepository) Reindex(ctx context.Context) error {
+	ctx, op := r.startOperation(ctx, "Reindex", "REINDEX")
+	defer op.End()
+
+	for _, table := range schemaTables {
+		if _, err := r.db.ExecContext(ctx, "REINDEX TABLE "+table+";"); err != nil {
+			op.RecordError(err)
+			return fmt.Errorf("reindex %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// Analyze recomputes the planner statistics of the tables created by InitDB.
+func (r *URLRepository) Analyze(ctx context.Context) error {
+	ctx, op := r.startOperation(ctx, "Analyze", "ANALYZE")
+	defer op.End()
+
+	for _, table := range schemaTables {
+		if _, err := r.db.ExecContext(ctx, "ANALYZE "+table+";"); err != nil {
+			op.RecordError(err)
+			return fmt.Errorf("analyze %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// SaveAPIKey stores the hash of an API key issued to the given user.
+// It returns storage.ErrConflict if the hash is already stored.
+func (r *URLRepository) SaveAPIKey(ctx context.Context, hash string, userID string) error {
+	ctx, op := r.startOperation(ctx, "SaveAPIKey", "INSERT api_keys")
+	defer op.End()
+
+	_, err := r.db.ExecContext(ctx, "INSERT INTO api_keys(key_hash, user_id) VALUES ($1, $2);", hash, userID)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return storage.ErrConflict
+	}
+	if err != nil {
+		logger.FromContext(ctx, r.logger).Error("SaveAPIKey error=", zap.String("error", err.Error()))
+	}
+	return err
+}
+
+// FindUserByAPIKey returns the ID of the user owning the API key with the given hash.
+// It returns storage.ErrNotFound if the hash is unknown.
+func (r *URLRepository) FindUserByAPIKey(ctx context.Context, hash string) (string, error) {
+	ctx, op := r.startOperation(ctx, "FindUserByAPIKey", "SELECT api_keys")
+	defer op.End()
+
+	var userID string
+	err := r.db.QueryRowContext(ctx, "SELECT user_id FROM api_keys WHERE key_hash = $1;", hash).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", storage.ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// CreateUser stores a new user. Creating a user that already exists is a no-op.
+func (r *URLRepository) CreateUser(ctx context.Context, u storage.User) error {
+	ctx, op := r.startOperation(ctx, "CreateUser", "INSERT users")
+	defer op.End()
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO users (id, display_name, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING;`, u.ID, u.DisplayName, u.CreatedAt)
+	if err != nil {
+		logger.FromContext(ctx, r.logger).Error("CreateUser error=", zap.String("error", err.Error()))
+	}
+	return err
+}
+
+// FindUser retrieves a user by ID.
+// It returns storage.ErrNotFound if there is no such user.
+func (r *URLRepository) FindUser(ctx context.Context, id string) (*storage.User, error) {
+	ctx, op := r.startOperation(ctx, "FindUser", "SELECT users")
+	defer op.End()
+
+	var u storage.User
+	err := r.db.QueryRowContext(ctx, "SELECT id, display_name, created_at FROM users WHERE id = $1;", id).
+		Scan(&u.ID, &u.DisplayName, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// UpdateDisplayName sets the display name of a user, creating the user if it does not exist yet.
+func (r *URLRepository) UpdateDisplayName(ctx context.Context, id string, name string) error {
+	ctx, op := r.startOperation(ctx, "UpdateDisplayName", "UPSERT users")
+	defer op.End()
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO users (id, display_name) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET display_name = EXCLUDED.display_name;`, id, name)
+	if err != nil {
+		logger.FromContext(ctx, r.logger).Error("UpdateDisplayName error=", zap.String("error", err.Error()))
+	}
+	return err
+}
+
+// AppendAudit appends an event to the audit log.
+func (r *URLRepository) AppendAudit(ctx context.Context, e storage.AuditEvent) error {
+	ctx, op := r.startOperation(ctx, "AppendAudit", "INSERT audit_log")
+	defer op.End()
+
+	targets, err := json.Marshal(e.Targets)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `INSERT INTO audit_log
+		(occurred_at, user_id, action, targets, status, remote_addr, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);`,
+		e.Time, e.UserID, e.Action, string(targets), e.Status, e.RemoteAddr, e.RequestID)
+	if err != nil {
+		logger.FromContext(ctx, r.logger).Error("AppendAudit error=", zap.String("error", err.Error()))
+	}
+	return err
+}
+
+// buildFindAuditQuery builds the query selecting audit events matching the filter, newest first.
+func buildFindAuditQuery(f storage.AuditFilter) (string, []any) {
+	var b strings.Builder
+	args := make([]any, 0, 4)
+
+	b.WriteString(`SELECT id, occurred_at, user_id, action, targets, status, remote_addr, request_id
+		FROM audit_log WHERE TRUE`)
+
+	if f.UserID != "" {
+		args = append(args, f.UserID)
+		fmt.Fprintf(&b, " AND user_id = $%d", len(args))
+	}
+	if !f.Since.IsZero() {
+		args = append(args, f.Since)
+		fmt.Fprintf(&b, " AND occurred_at >= $%d", len(args))
+	}
+	if !f.Until.IsZero() {
+		args = append(args, f.Until)
+		fmt.Fprintf(&b, " AND occurred_at < $%d", len(args))
+	}
+
+	b.WriteString(" ORDER BY id DESC")
+
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		fmt.Fprintf(&b, " LIMIT $%d", len(args))
+	}
+
+	b.WriteString(";")
+	return b.String(), args
+}
+
+// FindAudit returns the audit events matching the filter, newest first.
+func (r *URLRepository) FindAudit(ctx context.Context, f storage.AuditFilter) ([]storage.AuditEvent, error) {
+	ctx, op := r.startOperation(ctx, "FindAudit", "SELECT audit_log")
+	defer op.End()
+
+	query, args := buildFindAuditQuery(f)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.FromContext(ctx, r.logger).Error("FindAudit error=", zap.String("error", err.Error()))
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]storage.AuditEvent, 0)
+	for rows.Next() {
+		var e storage.AuditEvent
+		var targets string
+		if err := rows.Scan(&e.ID, &e.Time, &e.UserID, &e.Action, &targets, &e.Status, &e.RemoteAddr, &e.RequestID); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(targets), &e.Targets); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	op.SetRows(len(events))
+	return events, nil
+}
+
+// RecordClick counts a click of the short URL in the bucket of the given time.
+func (r *URLRepository) RecordClick(ctx context.Context, short string, at time.Time) error {
+	ctx, op := r.startOperation(ctx, "RecordClick", "INSERT clicks")
+	defer op.End()
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO clicks (short_url, bucket, clicks) VALUES ($1, $2, 1)
+		ON CONFLICT (short_url, bucket) DO UPDATE SET clicks = clicks.clicks + 1;`,
+		short, at.UTC().Truncate(storage.ClickBucket))
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("RecordClick error=", zap.String("error", err.Error()))
+		return err
+	}
+	op.SetRows(1)
+	return nil
+}
+
+// RecordClicks adds the clicks to the counts of their short URLs and buckets in
+// a single statement. The clicks must not repeat a short URL and bucket.
+func (r *URLRepository) RecordClicks(ctx context.Context, clicks []storage.BucketClicks) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	ctx, op := r.startOperation(ctx, "RecordClicks", "INSERT clicks")
+	defer op.End()
+
+	var query strings.Builder
+	query.WriteString("INSERT INTO clicks (short_url, bucket, clicks) VALUES ")
+	args := make([]any, 0, 3*len(clicks))
+	for i, c := range clicks {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		fmt.Fprintf(&query, "($%d, $%d, $%d)", 3*i+1, 3*i+2, 3*i+3)
+		args = append(args, c.Short, c.Bucket.UTC().Truncate(storage.ClickBucket), c.Clicks)
+	}
+	query.WriteString("\n\t\tON CONFLICT (short_url, bucket) DO UPDATE SET clicks = clicks.clicks + EXCLUDED.clicks;")
+
+	_, err := r.db.ExecContext(ctx, query.String(), args...)
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("RecordClicks error=", zap.String("error", err.Error()))
+		return err
+	}
+	op.SetRows(len(clicks))
+	return nil
+}
+
+// TopClicked returns up to limit short URLs with the most clicks since the
+// given time, rounded down to its bucket, most clicked first; limit 0 means no limit.
+func (r *URLRepository) TopClicked(ctx context.Context, since time.Time, limit int) ([]storage.ClickCount, error) {
+	ctx, op := r.startOperation(ctx, "TopClicked", "SELECT clicks")
+	defer op.End()
+
+	query := `SELECT short_url, SUM(clicks) FROM clicks WHERE bucket >= $1
+		GROUP BY short_url ORDER BY SUM(clicks) DESC, short_url`
+	args := []any{since.UTC().Truncate(storage.ClickBucket)}
+	if limit > 0 {
+		query += " LIMIT $2"
+		args = append(args, limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query+";", args...)
+	if err != nil {
+		op.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]storage.ClickCount, 0)
+	for rows.Next() {
+		var c storage.ClickCount
+		if err := rows.Scan(&c.Short, &c.Clicks); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	op.SetRows(len(res))
+	return res, nil
+}
+
+// ClicksOf returns the clicks of the given short URLs since the given time,
+// rounded down to its bucket, most clicked first. Short URLs without clicks
+// are left out.
+func (r *URLRepository) ClicksOf(ctx context.Context, shorts []string, since time.Time) ([]storage.ClickCount, error) {
+	if len(shorts) == 0 {
+		return []storage.ClickCount{}, nil
+	}
+
+	ctx, op := r.startOperation(ctx, "ClicksOf", "SELECT clicks")
+	defer op.End()
+
+	var query strings.Builder
+	query.WriteString("SELECT short_url, SUM(clicks) FROM clicks WHERE bucket >= $1 AND short_url IN (")
+	args := make([]any, 0, len(shorts)+1)
+	args = append(args, since.UTC().Truncate(storage.ClickBucket))
+	for i, short := range shorts {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		fmt.Fprintf(&query, "$%d", i+2)
+		args = append(args, short)
+	}
+	query.WriteString(")\n\t\tGROUP BY short_url ORDER BY SUM(clicks) DESC, short_url;")
+
+	rows, err := r.db.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		op.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]storage.ClickCount, 0, len(shorts))
+	for rows.Next() {
+		var c storage.ClickCount
+		if err := rows.Scan(&c.Short, &c.Clicks); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	op.SetRows(len(res))
+	return res, nil
+}
+
+// CreateCampaign stores a new campaign. It returns storage.ErrConflict if the
+// ID is already stored or the user has a campaign with the same name.
+func (r *URLRepository) CreateCampaign(ctx context.Context, c storage.Campaign) error {
+	ctx, op := r.startOperation(ctx, "CreateCampaign", "INSERT campaigns")
+	defer op.End()
+
+	_, err := r.db.ExecContext(ctx, "INSERT INTO campaigns (id, user_id, name, created_at) VALUES ($1, $2, $3, $4);",
+		c.ID, c.UserID, c.Name, c.CreatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return storage.ErrConflict
+	}
+	if err != nil {
+		op.RecordError(err)
+		logger.FromContext(ctx, r.logger).Error("CreateCampaign error=", zap.String("error", err.Error()))
+		return err
+	}
+	op.SetRows(1)
+	return nil
+}
+
+// FindCampaign retrieves a campaign by ID.
+// It returns storage.ErrNotFound if there is no such campaign.
+func (r *URLRepository) FindCampaign(ctx context.Context, id string) (*storage.Campaign, error) {
+	ctx, op := r.startOperation(ctx, "FindCampaign", "SELECT campaigns")
+	defer op.End()
+
+	var c storage.Campaign
+	err := r.db.QueryRowContext(ctx, "SELECT id, user_id, name, created_at FROM campaigns WHERE id = $1;", id).
+		Scan(&c.ID, &c.UserID, &c.Name, &c.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		op.RecordError(err)
+		return nil, err
+	}
+	return &c, nil
+}
+
+// FindCampaignsByUserID returns the campaigns of the user, oldest first.
+func (r *URLRepository) FindCampaignsByUserID(ctx context.Context, userID string) ([]storage.Campaign, error) {
+	ctx, op := r.startOperation(ctx, "FindCampaignsByUserID", "SELECT campaigns")
+	defer op.End()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, user_id, name, created_at FROM campaigns WHERE user_id = $1 ORDER BY created_at, name;", userID)
+	if err != nil {
+		op.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]storage.Campaign, 0)
+	for rows.Next() {
+		var c storage.Campaign
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	op.SetRows(len(res))
+	return res, nil
+}
+
+// schemaTables lists the tables created by InitDB.
+var schemaTables = []string{"url_records", "api_keys", "users", "pending_deletes", "audit_log", "campaigns", "clicks"}
+
+// SchemaReady returns an error if any table created by InitDB is missing.
+func (r *URLRepository) SchemaReady(ctx context.Context) error {
+	for _, table := range schemaTables {
+		var exists bool
+		if err := r.db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL;", table).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("table %s does not exist", table)
+		}
+	}
+	return nil
+}
+
 // PingContext checks the health of the database connection using the given context.
 func (r *URLRepository) PingContext(c context.Context) error {
 	return r.db.PingContext(c)
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// benchDSNEnv names the environment variable with the database the
+// benchmarks run against; without it they run against a mock database,
+// which only measures the client side.
+const benchDSNEnv = "SHORTENER_BENCH_DATABASE_DSN"
+
+// findByShortQuery is the query of FindByShort, run directly by the
+// unprepared benchmarks.
+const findByShortQuery = `SELECT id, original_url, short_url, user_id, is_deleted, expires_at, flagged_reason, password_hash, not_before, not_after, created_at 
+	FROM url_records WHERE short_url = $1;`
+
+// benchRepository returns a repository holding the record of short, the
+// mock of its database if there is no benchmark database, and a cleanup function.
+func benchRepository(b *testing.B, short string) (*URLRepository, sqlmock.Sqlmock, func()) {
+	if dsn := os.Getenv(benchDSNEnv); dsn != "" {
+		db := InitDB(dsn, zap.NewNop())
+		repo := CreateURLRepository(db, zap.NewNop())
+		repo.SetSlowQueryThreshold(0)
+		if _, err := db.Exec("DELETE FROM url_records WHERE short_url = $1;", short); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := repo.Write(context.Background(), storage.URLRecord{Original: "https://example.com/" + short, Short: short}); err != nil {
+			b.Fatal(err)
+		}
+		return repo, nil, func() {
+			_, _ = db.Exec("DELETE FROM url_records WHERE short_url = $1;", short)
+			_ = repo.Close()
+			_ = db.Close()
+		}
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		b.Fatal(err)
+	}
+	repo := CreateURLRepository(db, zap.NewNop())
+	repo.SetSlowQueryThreshold(0)
+	return repo, mock, func() { _ = db.Close() }
+}
+
+// expectLookups sets up n lookups of short on mock, through a prepared
+// statement if prepared is set; mock is nil for a real database.
+func expectLookups(mock sqlmock.Sqlmock, short string, n int, prepared bool) {
+	if mock == nil {
+		return
+	}
+	pattern := `FROM url_records WHERE short_url = \$1;`
+	expect := func() *sqlmock.ExpectedQuery { return mock.ExpectQuery(pattern) }
+	if prepared {
+		expect = mock.ExpectPrepare(pattern).ExpectQuery
+	}
+	for i := 0; i < n; i++ {
+		expect().WithArgs(short).WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "is_deleted", "expires_at", "flagged_reason", "password_hash", "not_before", "not_after", "created_at", "tags", "title", "description", "pinned", "campaign_id", "private"}).
+			AddRow("id-1", "https://example.com/"+short, short, "", false, nil, "", "", nil, nil, nil, "[]", "", "", false, "", false))
+	}
+}
+
+// BenchmarkFindByShort measures the lookup of every redirect, through the
+// prepared statement of FindByShort and, for comparison, with the same query
+// sent to the database each time.
+func BenchmarkFindByShort(b *testing.B) {
+	const short = "bench-find-by-short"
+	ctx := context.Background()
+
+	b.Run("prepared", func(b *testing.B) {
+		repo, mock, cleanup := benchRepository(b, short)
+		defer cleanup()
+		expectLookups(mock, short, b.N, true)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.FindByShort(ctx, short); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("unprepared", func(b *testing.B) {
+		repo, mock, cleanup := benchRepository(b, short)
+		defer cleanup()
+		expectLookups(mock, short, b.N, false)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var id, original, s, userID, flagged, passwordHash sql.NullString
+			var deleted sql.NullBool
+			var expiresAt, notBefore, notAfter, createdAt sql.NullTime
+			err := repo.db.QueryRowContext(ctx, findByShortQuery, short).
+				Scan(&id, &original, &s, &userID, &deleted, &expiresAt, &flagged, &passwordHash, &notBefore, &notAfter, &createdAt)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -3,9 +3,13 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 
@@ -30,10 +34,11 @@ func TestWrite(t *testing.T) {
 		UserID:   "user-id-123",
 	}
 
-	mock.ExpectQuery(`INSERT INTO url_records`).
-		WithArgs(record.Original, record.Short, "", record.UserID).
-		WillReturnRows(sqlmock.NewRows([]string{"original_url", "short_url", "id", "user_id"}).
-			AddRow(record.Original, record.Short, "generated-uuid", record.UserID))
+	stmt := mock.ExpectPrepare(`INSERT INTO url_records`)
+	stmt.ExpectQuery().
+		WithArgs(record.Original, record.Short, "", record.UserID, nil, nil, nil, nil, "[]", "", "").
+		WillReturnRows(sqlmock.NewRows([]string{"original_url", "short_url", "id", "user_id", "inserted"}).
+			AddRow(record.Original, record.Short, "generated-uuid", record.UserID, true))
 
 	result, err := repo.Write(context.Background(), record)
 
@@ -41,18 +46,91 @@ func TestWrite(t *testing.T) {
 	assert.NotNil(t, result)
 	assert.Equal(t, record.Original, result.Original)
 	assert.Equal(t, record.Short, result.Short)
+	assert.Equal(t, "generated-uuid", result.ID)
+
+	// On conflict the stored record is returned, not the one written
+	stmt.ExpectQuery().
+		WithArgs(record.Original, "xyz789", "", "user-id-456", nil, nil, nil, nil, "[]", "", "").
+		WillReturnRows(sqlmock.NewRows([]string{"original_url", "short_url", "id", "user_id", "inserted"}).
+			AddRow(record.Original, record.Short, "generated-uuid", record.UserID, false))
+
+	result, err = repo.Write(context.Background(), storage.URLRecord{Original: record.Original, Short: "xyz789", UserID: "user-id-456"})
+
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	assert.NotNil(t, result)
+	assert.Equal(t, record.Short, result.Short)
+	assert.Equal(t, record.UserID, result.UserID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWrite_ConflictCommittedConcurrently(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	// The row conflicting with the insert is not in the snapshot of its query
+	mock.ExpectPrepare(`INSERT INTO url_records`).ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"original_url", "short_url", "id", "user_id", "inserted"}))
+	mock.ExpectQuery(`SELECT original_url, short_url, id::TEXT, COALESCE\(user_id::TEXT, ''\) FROM url_records WHERE original_url = \$1;`).
+		WithArgs("https://example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"original_url", "short_url", "id", "user_id"}).
+			AddRow("https://example.com", "stored", "id-1", ""))
+
+	result, err := repo.Write(context.Background(), storage.URLRecord{Original: "https://example.com", Short: "new"})
+
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	assert.NotNil(t, result)
+	assert.Equal(t, "stored", result.Short)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWriteAll(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	records := []storage.URLRecord{
+		{Original: "https://example.com/a", Short: "short-a", UserID: "user-id-123"},
+		{Original: "https://example.com/b", Short: "short-b", UserID: "user-id-123"},
+	}
+
+	mock.ExpectBegin()
+	stmt := mock.ExpectPrepare(`INSERT INTO url_records`)
+	stmt.ExpectExec().
+		WithArgs("https://example.com/a", "short-a", "", "user-id-123", nil, nil, nil, nil, "[]", "", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	stmt.ExpectExec().
+		WithArgs("https://example.com/b", "short-b", "", "user-id-123", nil, nil, nil, nil, "[]", "", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
+	assert.NoError(t, repo.WriteAll(context.Background(), records))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWriteAll_Conflict(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	// A URL stored before fails the batch rather than being skipped
+	mock.ExpectBegin()
+	stmt := mock.ExpectPrepare(`INSERT INTO url_records`)
+	stmt.ExpectExec().
+		WithArgs("https://example.com/a", "short-a", "", "user-id-123", nil, nil, nil, nil, "[]", "", "").
+		WillReturnError(&pgconn.PgError{Code: "23505"})
+	mock.ExpectRollback()
+
+	err := repo.WriteAll(context.Background(), []storage.URLRecord{{Original: "https://example.com/a", Short: "short-a", UserID: "user-id-123"}})
+
+	assert.ErrorIs(t, err, storage.ErrConflict)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestRead(t *testing.T) {
 	_, mock, repo := setupMockDB(t)
 
-	expectedRows := sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id"}).
-		AddRow("id-1", "https://example.com", "abc123", "user-id-1").
-		AddRow("id-2", "https://example2.com", "abc456", "user-id-2")
+	expiresAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	expectedRows := sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "is_deleted", "expires_at"}).
+		AddRow("id-1", "https://example.com", "abc123", "user-id-1", false, nil).
+		AddRow("id-2", "https://example2.com", "abc456", "user-id-2", true, expiresAt)
 
-	mock.ExpectQuery(`SELECT \* FROM url_records;`).
+	mock.ExpectQuery(`SELECT id, original_url, short_url, COALESCE\(user_id::TEXT, ''\), is_deleted, expires_at\s+FROM url_records;`).
 		WillReturnRows(expectedRows)
 
 	result, err := repo.Read(context.Background())
@@ -60,12 +138,17 @@ func TestRead(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, result, 2)
 	assert.Equal(t, "https://example.com", result[0].Original)
+	assert.Nil(t, result[0].ExpiresAt)
+	assert.True(t, result[1].IsDeleted)
+	assert.Equal(t, &expiresAt, result[1].ExpiresAt)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestFindByShort(t *testing.T) {
 	_, mock, repo := setupMockDB(t)
+	notBefore := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
 
 	short := "abc123"
 	expectedRecord := storage.URLRecord{
@@ -76,10 +159,11 @@ func TestFindByShort(t *testing.T) {
 		IsDeleted: false,
 	}
 
-	mock.ExpectQuery(`SELECT id, original_url, short_url, user_id, is_deleted FROM url_records WHERE short_url = \$1;`).
+	stmt := mock.ExpectPrepare(`SELECT id, original_url, short_url, user_id, is_deleted, expires_at, flagged_reason, password_hash, not_before, not_after, created_at, tags, title, description, pinned, campaign_id, private FROM url_records WHERE short_url = \$1;`)
+	stmt.ExpectQuery().
 		WithArgs(short).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "is_deleted"}).
-			AddRow(expectedRecord.ID, expectedRecord.Original, expectedRecord.Short, expectedRecord.UserID, expectedRecord.IsDeleted))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "is_deleted", "expires_at", "flagged_reason", "password_hash", "not_before", "not_after", "created_at", "tags", "title", "description", "pinned", "campaign_id", "private"}).
+			AddRow(expectedRecord.ID, expectedRecord.Original, expectedRecord.Short, expectedRecord.UserID, expectedRecord.IsDeleted, nil, "", "", notBefore, nil, createdAt, `["work"]`, "Example Domain", "Landing page", true, "launch", true))
 
 	result, err := repo.FindByShort(context.Background(), short)
 
@@ -87,9 +171,202 @@ func TestFindByShort(t *testing.T) {
 	assert.NotNil(t, result)
 	assert.Equal(t, expectedRecord.Original, result.Original)
 	assert.Equal(t, expectedRecord.Short, result.Short)
+	assert.Nil(t, result.ExpiresAt)
+	assert.True(t, notBefore.Equal(*result.NotBefore))
+	assert.Nil(t, result.NotAfter)
+	assert.True(t, createdAt.Equal(*result.CreatedAt))
+	assert.Equal(t, []string{"work"}, result.Tags)
+	assert.Equal(t, "Example Domain", result.Title)
+	assert.Equal(t, "Landing page", result.Description)
+	assert.True(t, result.Pinned)
+	assert.Equal(t, "launch", result.Campaign)
+	assert.True(t, result.Private)
+
+	// The statement is prepared once
+	stmt.ExpectQuery().
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+	_, err = repo.FindByShort(context.Background(), "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFlagURL(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	mock.ExpectExec(`UPDATE url_records SET flagged_reason = \$1 WHERE short_url = \$2;`).
+		WithArgs("MALWARE", "abc123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE url_records SET flagged_reason = \$1 WHERE short_url = \$2;`).
+		WithArgs("MALWARE", "missing").
+		WillReturnResult(sqlmock.NewResult(0, 0))
 
+	assert.NoError(t, repo.FlagURL(context.Background(), "abc123", "MALWARE"))
+	assert.ErrorIs(t, repo.FlagURL(context.Background(), "missing", "MALWARE"), storage.ErrNotFound)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestSetPassword(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	query := `UPDATE url_records SET password_hash = CASE WHEN is_deleted THEN password_hash ELSE \$1 END\s+WHERE short_url = \$2 AND user_id = \$3 RETURNING is_deleted;`
+	mock.ExpectQuery(query).
+		WithArgs("hash", "abc123", "user-id-1").
+		WillReturnRows(sqlmock.NewRows([]string{"is_deleted"}).AddRow(false))
+	mock.ExpectQuery(query).
+		WithArgs("hash", "abc123", "other-user").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(query).
+		WithArgs("hash", "deleted", "user-id-1").
+		WillReturnRows(sqlmock.NewRows([]string{"is_deleted"}).AddRow(true))
+
+	assert.NoError(t, repo.SetPassword(context.Background(), "abc123", "user-id-1", "hash"))
+	assert.ErrorIs(t, repo.SetPassword(context.Background(), "abc123", "other-user", "hash"), storage.ErrNotFound)
+	assert.ErrorIs(t, repo.SetPassword(context.Background(), "deleted", "user-id-1", "hash"), storage.ErrDeleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetTags(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	query := `UPDATE url_records SET tags = CASE WHEN is_deleted THEN tags ELSE \$1::JSONB END\s+WHERE short_url = \$2 AND user_id = \$3 RETURNING is_deleted;`
+	mock.ExpectQuery(query).
+		WithArgs(`["work","docs"]`, "abc123", "user-id-1").
+		WillReturnRows(sqlmock.NewRows([]string{"is_deleted"}).AddRow(false))
+	mock.ExpectQuery(query).
+		WithArgs("[]", "abc123", "other-user").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(query).
+		WithArgs("[]", "deleted", "user-id-1").
+		WillReturnRows(sqlmock.NewRows([]string{"is_deleted"}).AddRow(true))
+
+	assert.NoError(t, repo.SetTags(context.Background(), "abc123", "user-id-1", []string{"work", "docs"}))
+	assert.ErrorIs(t, repo.SetTags(context.Background(), "abc123", "other-user", nil), storage.ErrNotFound)
+	assert.ErrorIs(t, repo.SetTags(context.Background(), "deleted", "user-id-1", nil), storage.ErrDeleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetDetails(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	query := `UPDATE url_records SET title = CASE WHEN is_deleted THEN title ELSE \$1 END,\s+description = CASE WHEN is_deleted THEN description ELSE \$2 END\s+WHERE short_url = \$3 AND user_id = \$4 RETURNING is_deleted;`
+	mock.ExpectQuery(query).
+		WithArgs("Title", "Notes", "abc123", "user-id-1").
+		WillReturnRows(sqlmock.NewRows([]string{"is_deleted"}).AddRow(false))
+	mock.ExpectQuery(query).
+		WithArgs("Title", "Notes", "abc123", "other-user").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(query).
+		WithArgs("Title", "Notes", "deleted", "user-id-1").
+		WillReturnRows(sqlmock.NewRows([]string{"is_deleted"}).AddRow(true))
+
+	assert.NoError(t, repo.SetDetails(context.Background(), "abc123", "user-id-1", "Title", "Notes"))
+	assert.ErrorIs(t, repo.SetDetails(context.Background(), "abc123", "other-user", "Title", "Notes"), storage.ErrNotFound)
+	assert.ErrorIs(t, repo.SetDetails(context.Background(), "deleted", "user-id-1", "Title", "Notes"), storage.ErrDeleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetPinned(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	query := `UPDATE url_records SET pinned = CASE WHEN is_deleted THEN pinned ELSE \$1 END\s+WHERE short_url = \$2 AND user_id = \$3 RETURNING is_deleted;`
+	mock.ExpectQuery(query).
+		WithArgs(true, "abc123", "user-id-1").
+		WillReturnRows(sqlmock.NewRows([]string{"is_deleted"}).AddRow(false))
+	mock.ExpectQuery(query).
+		WithArgs(false, "abc123", "other-user").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(query).
+		WithArgs(true, "deleted", "user-id-1").
+		WillReturnRows(sqlmock.NewRows([]string{"is_deleted"}).AddRow(true))
+
+	assert.NoError(t, repo.SetPinned(context.Background(), "abc123", "user-id-1", true))
+	assert.ErrorIs(t, repo.SetPinned(context.Background(), "abc123", "other-user", false), storage.ErrNotFound)
+	assert.ErrorIs(t, repo.SetPinned(context.Background(), "deleted", "user-id-1", true), storage.ErrDeleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetPrivate(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	query := `UPDATE url_records SET private = CASE WHEN is_deleted THEN private ELSE \$1 END\s+WHERE short_url = \$2 AND user_id = \$3 RETURNING is_deleted;`
+	mock.ExpectQuery(query).
+		WithArgs(true, "abc123", "user-id-1").
+		WillReturnRows(sqlmock.NewRows([]string{"is_deleted"}).AddRow(false))
+	mock.ExpectQuery(query).
+		WithArgs(false, "abc123", "other-user").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(query).
+		WithArgs(true, "deleted", "user-id-1").
+		WillReturnRows(sqlmock.NewRows([]string{"is_deleted"}).AddRow(true))
+
+	assert.NoError(t, repo.SetPrivate(context.Background(), "abc123", "user-id-1", true))
+	assert.ErrorIs(t, repo.SetPrivate(context.Background(), "abc123", "other-user", false), storage.ErrNotFound)
+	assert.ErrorIs(t, repo.SetPrivate(context.Background(), "deleted", "user-id-1", true), storage.ErrDeleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetCampaign(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	query := `UPDATE url_records SET campaign_id = CASE WHEN is_deleted THEN campaign_id ELSE \$1 END\s+WHERE short_url = \$2 AND user_id = \$3 RETURNING is_deleted;`
+	mock.ExpectQuery(query).
+		WithArgs("c1", "abc123", "user-id-1").
+		WillReturnRows(sqlmock.NewRows([]string{"is_deleted"}).AddRow(false))
+	mock.ExpectQuery(query).
+		WithArgs("", "abc123", "other-user").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(query).
+		WithArgs("c1", "deleted", "user-id-1").
+		WillReturnRows(sqlmock.NewRows([]string{"is_deleted"}).AddRow(true))
+
+	assert.NoError(t, repo.SetCampaign(context.Background(), "abc123", "user-id-1", "c1"))
+	assert.ErrorIs(t, repo.SetCampaign(context.Background(), "abc123", "other-user", ""), storage.ErrNotFound)
+	assert.ErrorIs(t, repo.SetCampaign(context.Background(), "deleted", "user-id-1", "c1"), storage.ErrDeleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetDefaultTitle(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	query := `UPDATE url_records SET title = CASE WHEN title = '' AND NOT is_deleted THEN \$1 ELSE title END\s+WHERE short_url = \$2 RETURNING 1;`
+	mock.ExpectQuery(query).
+		WithArgs("Example Domain", "abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	mock.ExpectQuery(query).
+		WithArgs("Example Domain", "missing").
+		WillReturnError(sql.ErrNoRows)
+
+	assert.NoError(t, repo.SetDefaultTitle(context.Background(), "abc123", "Example Domain"))
+	assert.ErrorIs(t, repo.SetDefaultTitle(context.Background(), "missing", "Example Domain"), storage.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindExpired(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := now.Add(-time.Hour)
+
+	mock.ExpectQuery(`SELECT id, original_url, short_url, user_id, expires_at FROM url_records\s+WHERE expires_at IS NOT NULL AND expires_at <= \$1 AND NOT is_deleted\s+ORDER BY expires_at LIMIT \$2;`).
+		WithArgs(now, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "expires_at"}).
+			AddRow("id-1", "https://example.com", "abc123", "user-id-1", expiresAt))
+
+	records, err := repo.FindExpired(context.Background(), now, 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.URLRecord{{
+		ID:        "id-1",
+		Original:  "https://example.com",
+		Short:     "abc123",
+		UserID:    "user-id-1",
+		ExpiresAt: &expiresAt,
+	}}, records)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestFindByUserID(t *testing.T) {
 	_, mock, repo := setupMockDB(t)
 
@@ -101,12 +378,12 @@ func TestFindByUserID(t *testing.T) {
 		UserID:   expectedUserID,
 	}
 
-	mock.ExpectQuery(`SELECT id, original_url, short_url, user_id FROM url_records WHERE user_id = \$1;`).
+	mock.ExpectPrepare(`SELECT id, original_url, short_url, user_id, tags, title, description, pinned, campaign_id FROM url_records WHERE user_id = \$1 ORDER BY created_at, short_url;`).ExpectQuery().
 		WithArgs(expectedUserID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id"}).
-			AddRow(expectedRecord.ID, expectedRecord.Original, expectedRecord.Short, expectedRecord.UserID))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "tags", "title", "description", "pinned", "campaign_id"}).
+			AddRow(expectedRecord.ID, expectedRecord.Original, expectedRecord.Short, expectedRecord.UserID, "[]", "", "", false, ""))
 
-	result, err := repo.FindByUserID(context.Background(), expectedUserID)
+	result, err := repo.FindByUserID(context.Background(), expectedUserID, storage.ListOptions{})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
@@ -118,6 +395,103 @@ func TestFindByUserID(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestFindByUserIDWithOptions(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	userID := "user-id-1"
+	opts := storage.ListOptions{Limit: 10, Offset: 20, Sort: "-short_url", Query: "50%_off  Shoes"}
+
+	mock.ExpectPrepare(`SELECT id, original_url, short_url, user_id, tags, title, description, pinned, campaign_id FROM url_records WHERE user_id = \$1 AND \(original_url ILIKE '%' \|\| \$2 \|\| '%' OR title ILIKE '%' \|\| \$2 \|\| '%' OR description ILIKE '%' \|\| \$2 \|\| '%'\) AND \(original_url ILIKE '%' \|\| \$3 \|\| '%' OR title ILIKE '%' \|\| \$3 \|\| '%' OR description ILIKE '%' \|\| \$3 \|\| '%'\) ORDER BY short_url DESC LIMIT \$4 OFFSET \$5;`).ExpectQuery().
+		WithArgs(userID, `50\%\_off`, "shoes", 10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "tags", "title", "description", "pinned", "campaign_id"}).
+			AddRow("id-1", "https://example.com/50%_off", "abc123", userID, "[]", "", "", false, ""))
+
+	result, err := repo.FindByUserID(context.Background(), userID, opts)
+
+	assert.NoError(t, err)
+	assert.Len(t, *result, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindByUserIDWithTag(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	userID := "user-id-1"
+	mock.ExpectPrepare(`SELECT id, original_url, short_url, user_id, tags, title, description, pinned, campaign_id FROM url_records WHERE user_id = \$1 AND tags \? \$2 ORDER BY created_at, short_url LIMIT \$3;`).ExpectQuery().
+		WithArgs(userID, "work", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "tags", "title", "description", "pinned", "campaign_id"}).
+			AddRow("id-1", "https://example.com", "abc123", userID, `["docs","work"]`, "Docs", "", false, ""))
+
+	result, err := repo.FindByUserID(context.Background(), userID, storage.ListOptions{Limit: 5, Tag: "work"})
+
+	assert.NoError(t, err)
+	assert.Len(t, *result, 1)
+	assert.Equal(t, []string{"docs", "work"}, (*result)[0].Tags)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindByUserIDPinnedFirst(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	userID := "user-id-1"
+	mock.ExpectPrepare(`SELECT id, original_url, short_url, user_id, tags, title, description, pinned, campaign_id FROM url_records WHERE user_id = \$1 ORDER BY pinned DESC, original_url DESC;`).ExpectQuery().
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "tags", "title", "description", "pinned", "campaign_id"}).
+			AddRow("id-1", "https://example.com", "abc123", userID, "[]", "", "", true, "").
+			AddRow("id-2", "https://example.org", "def456", userID, "[]", "", "", false, ""))
+
+	result, err := repo.FindByUserID(context.Background(), userID, storage.ListOptions{PinnedFirst: true, Sort: "-original_url"})
+
+	assert.NoError(t, err)
+	assert.Len(t, *result, 2)
+	assert.True(t, (*result)[0].Pinned)
+	assert.False(t, (*result)[1].Pinned)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBuildFindByUserIDQuery_StableOrder(t *testing.T) {
+	// Every order ends with a unique column, so pages of the same listing never overlap
+	tests := []struct {
+		opts storage.ListOptions
+		want string
+	}{
+		{opts: storage.ListOptions{Limit: 10, Offset: 10}, want: " ORDER BY created_at, short_url LIMIT $2 OFFSET $3;"},
+		{opts: storage.ListOptions{Limit: 10, PinnedFirst: true}, want: " ORDER BY pinned DESC, created_at, short_url LIMIT $2;"},
+		{opts: storage.ListOptions{Limit: 10, PinnedFirst: true, Sort: "short_url"}, want: " ORDER BY pinned DESC, short_url LIMIT $2;"},
+		{opts: storage.ListOptions{Limit: 10, Sort: "-original_url"}, want: " ORDER BY original_url DESC LIMIT $2;"},
+	}
+	for _, tt := range tests {
+		query, _ := buildFindByUserIDQuery("user-id-1", tt.opts)
+		assert.True(t, strings.HasSuffix(query, tt.want), query)
+	}
+}
+
+func TestFindByUserIDWithCampaign(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	userID := "user-id-1"
+	mock.ExpectPrepare(`SELECT id, original_url, short_url, user_id, tags, title, description, pinned, campaign_id FROM url_records WHERE user_id = \$1 AND campaign_id = \$2 ORDER BY created_at, short_url;`).ExpectQuery().
+		WithArgs(userID, "c1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "tags", "title", "description", "pinned", "campaign_id"}).
+			AddRow("id-1", "https://example.com", "abc123", userID, "[]", "", "", false, "c1"))
+
+	result, err := repo.FindByUserID(context.Background(), userID, storage.ListOptions{Campaign: "c1"})
+
+	assert.NoError(t, err)
+	assert.Len(t, *result, 1)
+	assert.Equal(t, "c1", (*result)[0].Campaign)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindByUserIDInvalidSort(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	_, err := repo.FindByUserID(context.Background(), "user-id-1", storage.ListOptions{Sort: "id; DROP TABLE url_records"})
+
+	assert.ErrorIs(t, err, storage.ErrInvalidListOptions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestFindByID(t *testing.T) {
 	_, mock, repo := setupMockDB(t)
 
@@ -129,7 +503,7 @@ func TestFindByID(t *testing.T) {
 		UserID:   "user-id-1",
 	}
 
-	mock.ExpectQuery("SELECT \\* FROM url_records WHERE id = \\$1;").
+	mock.ExpectQuery("SELECT id, original_url, short_url, user_id FROM url_records WHERE id = \\$1;").
 		WithArgs(id).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id"}).
 			AddRow(expected.ID, expected.Original, expected.Short, expected.UserID))
@@ -173,7 +547,7 @@ func TestDeleteBatch(t *testing.T) {
 
 	mock.ExpectBegin()
 
-	stmt := mock.ExpectPrepare("UPDATE url_records SET is_deleted = TRUE WHERE short_url = \\$1 AND user_id = \\$2")
+	stmt := mock.ExpectPrepare("UPDATE url_records SET is_deleted = TRUE, deleted_at = COALESCE\\(deleted_at, now\\(\\)\\) WHERE short_url = \\$1 AND user_id = \\$2")
 	stmt.ExpectExec().WithArgs("short1", "user1").WillReturnResult(sqlmock.NewResult(1, 1))
 	stmt.ExpectExec().WithArgs("short2", "user1").WillReturnResult(sqlmock.NewResult(1, 1))
 
@@ -183,3 +557,405 @@ func TestDeleteBatch(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestAppendPending(t *testing.T) {
+	db, mock, repo := setupMockDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	stmt := mock.ExpectPrepare(`INSERT INTO pending_deletes \(short_url, user_id\) VALUES \(\$1, \$2\)`)
+	stmt.ExpectExec().WithArgs("short1", "user1").WillReturnResult(sqlmock.NewResult(1, 1))
+	stmt.ExpectExec().WithArgs("short2", "user1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := repo.AppendPending(context.Background(), []storage.URLRecord{
+		{Short: "short1", UserID: "user1"},
+		{Short: "short2", UserID: "user1"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemovePending(t *testing.T) {
+	db, mock, repo := setupMockDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	stmt := mock.ExpectPrepare(`DELETE FROM pending_deletes WHERE short_url = \$1 AND user_id = \$2`)
+	stmt.ExpectExec().WithArgs("short1", "user1").WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	err := repo.RemovePending(context.Background(), []storage.URLRecord{{Short: "short1", UserID: "user1"}})
+	assert.EqualError(t, err, "connection reset")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReadPending(t *testing.T) {
+	db, mock, repo := setupMockDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT short_url, user_id FROM pending_deletes ORDER BY queued_at`).
+		WillReturnRows(sqlmock.NewRows([]string{"short_url", "user_id"}).
+			AddRow("short1", "user1").
+			AddRow("short2", "user2"))
+
+	records, err := repo.ReadPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.URLRecord{
+		{Short: "short1", UserID: "user1"},
+		{Short: "short2", UserID: "user2"},
+	}, records)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveAPIKey(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	mock.ExpectExec(`INSERT INTO api_keys`).
+		WithArgs("hash", "user-id-123").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := repo.SaveAPIKey(context.Background(), "hash", "user-id-123")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindUserByAPIKey(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	mock.ExpectQuery(`SELECT user_id FROM api_keys WHERE key_hash = \$1`).
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("user-id-123"))
+
+	userID, err := repo.FindUserByAPIKey(context.Background(), "hash")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "user-id-123", userID)
+
+	mock.ExpectQuery(`SELECT user_id FROM api_keys WHERE key_hash = \$1`).
+		WithArgs("unknown").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.FindUserByAPIKey(context.Background(), "unknown")
+
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStats(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\), COUNT\(DISTINCT user_id\) FROM url_records WHERE NOT is_deleted`).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "count"}).AddRow(3, 2))
+
+	urls, users, err := repo.GetStats(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, urls)
+	assert.Equal(t, 2, users)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDailyStats(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	mock.ExpectQuery(`SELECT day, SUM\(created\), SUM\(deleted\) FROM \(.+created_at >= \$1 AND created_at < \$2.+deleted_at >= \$1 AND deleted_at < \$2.+GROUP BY day ORDER BY day;`).
+		WithArgs(from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"day", "created", "deleted"}).
+			AddRow(from, 3, 0).
+			AddRow(from.AddDate(0, 0, 2), 1, 2))
+
+	daily, err := repo.GetDailyStats(context.Background(), from, to)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.DailyStats{
+		{Day: from, Created: 3},
+		{Day: from.AddDate(0, 0, 2), Created: 1, Deleted: 2},
+	}, daily)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	mock.ExpectExec(`DELETE FROM url_records WHERE is_deleted`).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	n, err := repo.PurgeDeleted(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReindex(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	for _, table := range schemaTables {
+		mock.ExpectExec(`REINDEX TABLE ` + table).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	assert.NoError(t, repo.Reindex(context.Background()))
+
+	mock.ExpectExec(`REINDEX TABLE url_records`).WillReturnError(errors.New("permission denied"))
+	assert.ErrorContains(t, repo.Reindex(context.Background()), "reindex url_records")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnalyze(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	for _, table := range schemaTables {
+		mock.ExpectExec(`ANALYZE ` + table).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	assert.NoError(t, repo.Analyze(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountByUserID(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM url_records WHERE user_id = \$1 AND NOT is_deleted`).
+		WithArgs("user-id-123").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+
+	count, err := repo.CountByUserID(context.Background(), "user-id-123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateUser(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	createdAt := time.Now().UTC()
+	mock.ExpectExec(`INSERT INTO users \(id, display_name, created_at\)`).
+		WithArgs("user-id-123", "", createdAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := repo.CreateUser(context.Background(), storage.User{ID: "user-id-123", CreatedAt: createdAt})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindUser(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	createdAt := time.Now().UTC()
+	mock.ExpectQuery(`SELECT id, display_name, created_at FROM users WHERE id = \$1`).
+		WithArgs("user-id-123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "display_name", "created_at"}).AddRow("user-id-123", "Alice", createdAt))
+
+	user, err := repo.FindUser(context.Background(), "user-id-123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &storage.User{ID: "user-id-123", DisplayName: "Alice", CreatedAt: createdAt}, user)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateDisplayName(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	mock.ExpectExec(`INSERT INTO users \(id, display_name\) VALUES \(\$1, \$2\)\s+ON CONFLICT \(id\) DO UPDATE`).
+		WithArgs("user-id-123", "Alice").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := repo.UpdateDisplayName(context.Background(), "user-id-123", "Alice")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAppendAudit(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	now := time.Now().UTC()
+	mock.ExpectExec(`INSERT INTO audit_log`).
+		WithArgs(now, "user-id-123", "POST /api/v1/shorten", `["abc"]`, 201, "192.0.2.1", "req-1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := repo.AppendAudit(context.Background(), storage.AuditEvent{
+		Time:       now,
+		UserID:     "user-id-123",
+		Action:     "POST /api/v1/shorten",
+		Targets:    []string{"abc"},
+		Status:     201,
+		RemoteAddr: "192.0.2.1",
+		RequestID:  "req-1",
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBuildFindAuditQuery(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	query, args := buildFindAuditQuery(storage.AuditFilter{})
+	assert.NotContains(t, query, "LIMIT")
+	assert.Contains(t, query, "ORDER BY id DESC")
+	assert.Empty(t, args)
+
+	query, args = buildFindAuditQuery(storage.AuditFilter{UserID: "u1", Since: since, Until: since.Add(time.Hour), Limit: 10})
+	assert.Contains(t, query, "AND user_id = $1 AND occurred_at >= $2 AND occurred_at < $3 ORDER BY id DESC LIMIT $4;")
+	assert.Equal(t, []any{"u1", since, since.Add(time.Hour), 10}, args)
+}
+
+func TestFindAudit(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	now := time.Now().UTC()
+	mock.ExpectQuery(`SELECT id, occurred_at, user_id, action, targets, status, remote_addr, request_id\s+FROM audit_log`).
+		WithArgs("user-id-123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "occurred_at", "user_id", "action", "targets", "status", "remote_addr", "request_id"}).
+			AddRow(int64(7), now, "user-id-123", "DELETE /api/v1/user/urls", `["abc","def"]`, 202, "192.0.2.1", "req-1"))
+
+	events, err := repo.FindAudit(context.Background(), storage.AuditFilter{UserID: "user-id-123"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.AuditEvent{{
+		ID:         7,
+		Time:       now,
+		UserID:     "user-id-123",
+		Action:     "DELETE /api/v1/user/urls",
+		Targets:    []string{"abc", "def"},
+		Status:     202,
+		RemoteAddr: "192.0.2.1",
+		RequestID:  "req-1",
+	}}, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSchemaReady(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	for _, table := range schemaTables {
+		mock.ExpectQuery(`SELECT to_regclass\(\$1\) IS NOT NULL`).
+			WithArgs(table).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(table != "clicks"))
+	}
+
+	err := repo.SchemaReady(context.Background())
+	assert.EqualError(t, err, "table clicks does not exist")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordClick(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	at := time.Date(2024, 5, 1, 12, 34, 56, 0, time.UTC)
+	mock.ExpectExec(`INSERT INTO clicks \(short_url, bucket, clicks\) VALUES \(\$1, \$2, 1\)\s+ON CONFLICT \(short_url, bucket\) DO UPDATE SET clicks = clicks.clicks \+ 1;`).
+		WithArgs("abc123", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.RecordClick(context.Background(), "abc123", at))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordClicks(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	bucket := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectExec(`INSERT INTO clicks \(short_url, bucket, clicks\) VALUES \(\$1, \$2, \$3\), \(\$4, \$5, \$6\)\s+ON CONFLICT \(short_url, bucket\) DO UPDATE SET clicks = clicks.clicks \+ EXCLUDED.clicks;`).
+		WithArgs("abc", bucket, 3, "def", bucket, 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	assert.NoError(t, repo.RecordClicks(context.Background(), []storage.BucketClicks{
+		{Short: "abc", Bucket: bucket.Add(30 * time.Minute), Clicks: 3},
+		{Short: "def", Bucket: bucket, Clicks: 1},
+	}))
+
+	// Nothing is written without clicks
+	assert.NoError(t, repo.RecordClicks(context.Background(), nil))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTopClicked(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	since := time.Date(2024, 5, 1, 12, 34, 56, 0, time.UTC)
+	mock.ExpectQuery(`SELECT short_url, SUM\(clicks\) FROM clicks WHERE bucket >= \$1\s+GROUP BY short_url ORDER BY SUM\(clicks\) DESC, short_url LIMIT \$2;`).
+		WithArgs(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), 2).
+		WillReturnRows(sqlmock.NewRows([]string{"short_url", "sum"}).AddRow("abc", 7).AddRow("def", 3))
+
+	top, err := repo.TopClicked(context.Background(), since, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.ClickCount{{Short: "abc", Clicks: 7}, {Short: "def", Clicks: 3}}, top)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClicksOf(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	since := time.Date(2024, 5, 1, 12, 34, 56, 0, time.UTC)
+	mock.ExpectQuery(`SELECT short_url, SUM\(clicks\) FROM clicks WHERE bucket >= \$1 AND short_url IN \(\$2, \$3\)\s+GROUP BY short_url ORDER BY SUM\(clicks\) DESC, short_url;`).
+		WithArgs(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), "abc", "def").
+		WillReturnRows(sqlmock.NewRows([]string{"short_url", "sum"}).AddRow("def", 4))
+
+	counts, err := repo.ClicksOf(context.Background(), []string{"abc", "def"}, since)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.ClickCount{{Short: "def", Clicks: 4}}, counts)
+
+	// No short URLs need no query
+	counts, err = repo.ClicksOf(context.Background(), nil, since)
+	assert.NoError(t, err)
+	assert.Empty(t, counts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateCampaign(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	c := storage.Campaign{ID: "c1", UserID: "user-id-1", Name: "Launch", CreatedAt: time.Now().UTC()}
+	mock.ExpectExec(`INSERT INTO campaigns \(id, user_id, name, created_at\) VALUES \(\$1, \$2, \$3, \$4\);`).
+		WithArgs(c.ID, c.UserID, c.Name, c.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO campaigns`).
+		WithArgs(c.ID, c.UserID, c.Name, c.CreatedAt).
+		WillReturnError(&pgconn.PgError{Code: "23505"})
+
+	assert.NoError(t, repo.CreateCampaign(context.Background(), c))
+	assert.ErrorIs(t, repo.CreateCampaign(context.Background(), c), storage.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindCampaign(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	createdAt := time.Now().UTC()
+	mock.ExpectQuery(`SELECT id, user_id, name, created_at FROM campaigns WHERE id = \$1;`).
+		WithArgs("c1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "created_at"}).AddRow("c1", "user-id-1", "Launch", createdAt))
+	mock.ExpectQuery(`SELECT id, user_id, name, created_at FROM campaigns WHERE id = \$1;`).
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+
+	c, err := repo.FindCampaign(context.Background(), "c1")
+	assert.NoError(t, err)
+	assert.Equal(t, &storage.Campaign{ID: "c1", UserID: "user-id-1", Name: "Launch", CreatedAt: createdAt}, c)
+
+	_, err = repo.FindCampaign(context.Background(), "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindCampaignsByUserID(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	createdAt := time.Now().UTC()
+	mock.ExpectQuery(`SELECT id, user_id, name, created_at FROM campaigns WHERE user_id = \$1 ORDER BY created_at, name;`).
+		WithArgs("user-id-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "created_at"}).
+			AddRow("c1", "user-id-1", "Launch", createdAt).
+			AddRow("c2", "user-id-1", "Spring sale", createdAt))
+
+	campaigns, err := repo.FindCampaignsByUserID(context.Background(), "user-id-1")
+	assert.NoError(t, err)
+	assert.Len(t, campaigns, 2)
+	assert.Equal(t, "Spring sale", campaigns[1].Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,169 @@
+// Package repository provides retries and a circuit breaker for database
+// calls: calls failing with transient errors, such as serialization failures
+// and connection resets, are retried, and once the database keeps failing,
+// calls fail fast with a storage.UnavailableError until it recovers, instead
+// of piling up behind timeouts.
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+// Default retry and circuit breaker settings of a repository.
+const (
+	DefaultMaxRetries       = 2
+	DefaultRetryBackoff     = 50 * time.Millisecond
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 10 * time.Second
+)
+
+// Breaker is a circuit breaker: after threshold consecutive calls fail with
+// transient errors it opens, failing calls fast for the cooldown. Then a
+// single call is let through as a probe; the breaker closes if it succeeds
+// and opens again if it fails. It is safe for concurrent use.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int       // Consecutive failed calls
+	openUntil time.Time // End of the cooldown, once open
+	probing   bool      // Whether a probe is in flight
+}
+
+// NewBreaker returns a breaker opening after threshold consecutive failures
+// for cooldown; a threshold of 0 or less never opens.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Allow returns a storage.UnavailableError if the breaker is open; otherwise
+// the call may proceed and its outcome must be passed to Record.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return nil
+	}
+	if wait := b.openUntil.Sub(b.now()); wait > 0 {
+		return &storage.UnavailableError{RetryAfter: wait}
+	}
+	if b.probing {
+		return &storage.UnavailableError{RetryAfter: b.cooldown}
+	}
+	b.probing = true
+	return nil
+}
+
+// Record records the outcome of a call let through by Allow.
+func (b *Breaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// Open reports whether the breaker currently fails calls fast.
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.threshold > 0 && b.failures >= b.threshold && b.now().Before(b.openUntil)
+}
+
+// isTransient reports whether err is a failure that may not happen again,
+// such as a serialization failure, deadlock or lost connection, as opposed to
+// an error of the query or a missing record.
+func isTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgerrcode.SerializationFailure, pgerrcode.DeadlockDetected,
+			pgerrcode.AdminShutdown, pgerrcode.CrashShutdown, pgerrcode.CannotConnectNow,
+			pgerrcode.TooManyConnections:
+			return true
+		}
+		// Class 08 are connection exceptions
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &netErr) || pgconn.SafeToRetry(err)
+}
+
+// SetResilience sets how calls failing with transient errors are retried and
+// the breaker failing calls fast while the database is down; a nil breaker
+// never fails fast. It must be called before the repository is used.
+func (r *URLRepository) SetResilience(retry worker.RetryPolicy, breaker *Breaker) {
+	retry.Retryable = isTransient
+	r.retry = retry
+	r.breaker = breaker
+}
+
+// Breaker returns the circuit breaker of the repository, nil if it has none.
+func (r *URLRepository) Breaker() *Breaker {
+	return r.breaker
+}
+
+// resilient runs fn with the retries and the circuit breaker of r. Transient
+// errors are retried, each attempt being a separate operation of fn, and count
+// as failures of the breaker once retries are exhausted; other errors, such
+// as storage.ErrNotFound, do not.
+func (r *URLRepository) resilient(ctx context.Context, name string, fn func(context.Context) error) error {
+	if r.breaker != nil {
+		if err := r.breaker.Allow(); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.retry.Do(ctx, fn, func(attempt int, err error, backoff time.Duration) {
+		logger.FromContext(ctx, r.logger).Warn("retrying database call",
+			zap.String("operation", name), zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(err))
+	})
+
+	if r.breaker != nil {
+		r.breaker.Record(isTransient(err))
+	}
+	return err
+}
+
+// resilientValue is like resilient for calls returning a value.
+func resilientValue[T any](ctx context.Context, r *URLRepository, name string, fn func(context.Context) (T, error)) (T, error) {
+	var v T
+	err := r.resilient(ctx, name, func(ctx context.Context) error {
+		var err error
+		v, err = fn(ctx)
+		return err
+	})
+	return v, err
+}
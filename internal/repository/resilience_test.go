@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"serialization failure": {&pgconn.PgError{Code: "40001"}, true},
+		"deadlock":              {&pgconn.PgError{Code: "40P01"}, true},
+		"connection failure":    {fmt.Errorf("query: %w", &pgconn.PgError{Code: "08006"}), true},
+		"admin shutdown":        {&pgconn.PgError{Code: "57P01"}, true},
+		"bad connection":        {driver.ErrBadConn, true},
+		"unique violation":      {&pgconn.PgError{Code: "23505"}, false},
+		"not found":             {storage.ErrNotFound, false},
+		"deadline":              {context.DeadlineExceeded, false},
+		"other":                 {errors.New("syntax error"), false},
+		"nil":                   {nil, false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isTransient(tt.err))
+		})
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	b := NewBreaker(2, 10*time.Second)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	// Failures below the threshold, or broken by a success, keep it closed
+	require.NoError(t, b.Allow())
+	b.Record(true)
+	require.NoError(t, b.Allow())
+	b.Record(false)
+	require.NoError(t, b.Allow())
+	b.Record(true)
+	assert.False(t, b.Open())
+
+	// Reaching it opens the breaker for the cooldown
+	require.NoError(t, b.Allow())
+	b.Record(true)
+	assert.True(t, b.Open())
+	now = now.Add(4 * time.Second)
+	var ue *storage.UnavailableError
+	require.ErrorAs(t, b.Allow(), &ue)
+	assert.Equal(t, 6*time.Second, ue.RetryAfter)
+	assert.ErrorIs(t, b.Allow(), storage.ErrUnavailable)
+
+	// After it, a single probe is let through
+	now = now.Add(6 * time.Second)
+	require.NoError(t, b.Allow())
+	assert.ErrorIs(t, b.Allow(), storage.ErrUnavailable)
+
+	// A failed probe opens it again, a successful one closes it
+	b.Record(true)
+	assert.True(t, b.Open())
+	now = now.Add(10 * time.Second)
+	require.NoError(t, b.Allow())
+	b.Record(false)
+	assert.False(t, b.Open())
+	require.NoError(t, b.Allow())
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := NewBreaker(0, time.Second)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, b.Allow())
+		b.Record(true)
+	}
+	assert.False(t, b.Open())
+}
+
+func TestRetriesTransientErrors(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+	repo.SetResilience(worker.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}, NewBreaker(5, time.Minute))
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM url_records WHERE user_id = \$1`).WithArgs("user-1").
+		WillReturnError(&pgconn.PgError{Code: "40001"})
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM url_records WHERE user_id = \$1`).WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	count, err := repo.CountByUserID(context.Background(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// Other errors are not retried
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM url_records WHERE user_id = \$1`).WithArgs("user-1").
+		WillReturnError(errors.New("syntax error"))
+	_, err = repo.CountByUserID(context.Background(), "user-1")
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBreakerFailsFast(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+	repo.SetResilience(worker.RetryPolicy{}, NewBreaker(2, time.Minute))
+
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(`SELECT COUNT\(\*\), COUNT\(DISTINCT user_id\)`).WillReturnError(&pgconn.PgError{Code: "08006"})
+		_, _, err := repo.GetStats(context.Background())
+		var pgErr *pgconn.PgError
+		assert.ErrorAs(t, err, &pgErr)
+	}
+
+	// The database is not queried any more while the breaker is open
+	_, _, err := repo.GetStats(context.Background())
+	var ue *storage.UnavailableError
+	require.ErrorAs(t, err, &ue)
+	assert.Greater(t, ue.RetryAfter, 59*time.Second)
+	_, err = repo.FindByShort(context.Background(), "abc")
+	assert.ErrorIs(t, err, storage.ErrUnavailable)
+	assert.True(t, repo.Breaker().Open())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,94 @@
+// Package repository provides reuse of prepared statements: the queries of
+// the hot paths, such as the lookup of every redirect, are prepared once per
+// repository and then only executed, so the database does not parse and plan
+// them on every call.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// maxPreparedStatements bounds the number of distinct queries prepared by a
+// repository. Listing queries vary with their options, so once the bound is
+// reached further queries run without being prepared.
+const maxPreparedStatements = 64
+
+// statements caches the prepared statements of a database by query.
+type statements struct {
+	db      *sql.DB
+	mu      sync.Mutex
+	byQuery map[string]*sql.Stmt
+}
+
+// prepare returns the prepared statement of query, preparing it on first use.
+// It returns nil if the statement cannot be prepared, for example because the
+// bound of prepared statements was reached.
+func (s *statements) prepare(ctx context.Context, query string) *sql.Stmt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stmt, ok := s.byQuery[query]; ok {
+		return stmt
+	}
+	if len(s.byQuery) >= maxPreparedStatements {
+		return nil
+	}
+
+	// Preparing happens once, so it is not cut short by the deadline of one request
+	stmt, err := s.db.PrepareContext(context.WithoutCancel(ctx), query)
+	if err != nil {
+		return nil
+	}
+	if s.byQuery == nil {
+		s.byQuery = make(map[string]*sql.Stmt)
+	}
+	s.byQuery[query] = stmt
+	return stmt
+}
+
+// close closes the prepared statements.
+func (s *statements) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for query, stmt := range s.byQuery {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.byQuery, query)
+	}
+	return firstErr
+}
+
+// queryRow runs query with args as a prepared statement, falling back to
+// running it directly if it cannot be prepared; errors are reported by the
+// Scan of the returned row, as with sql.DB.QueryRowContext.
+func (s *statements) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	if stmt := s.prepare(ctx, query); stmt != nil {
+		return stmt.QueryRowContext(ctx, args...)
+	}
+	return s.db.QueryRowContext(ctx, query, args...)
+}
+
+// query is like queryRow for queries returning many rows.
+func (s *statements) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if stmt := s.prepare(ctx, query); stmt != nil {
+		return stmt.QueryContext(ctx, args...)
+	}
+	return s.db.QueryContext(ctx, query, args...)
+}
+
+// Close closes the prepared statements of the repository, on the primary and
+// on the replica. The databases are left open, since they are owned by the
+// caller of CreateURLRepository and SetReplica.
+func (r *URLRepository) Close() error {
+	err := r.stmts.close()
+	if r.replica != nil {
+		err = errors.Join(err, r.replica.close())
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestStatementsPreparedOnce(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	stmt := mock.ExpectPrepare(`SELECT (.+) FROM url_records WHERE short_url = \$1;`)
+	for _, short := range []string{"a", "b", "c"} {
+		stmt.ExpectQuery().WithArgs(short).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "is_deleted", "expires_at", "flagged_reason", "password_hash", "not_before", "not_after", "created_at", "tags", "title", "description", "pinned", "campaign_id", "private"}).
+				AddRow("id-"+short, "https://example.com/"+short, short, "user-1", false, nil, "", "", nil, nil, nil, "[]", "", "", false, "", false))
+	}
+	stmt.WillBeClosed()
+
+	for _, short := range []string{"a", "b", "c"} {
+		r, err := repo.FindByShort(context.Background(), short)
+		require.NoError(t, err)
+		assert.Equal(t, short, r.Short)
+	}
+
+	require.NoError(t, repo.Close())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatementsFallBackWhenPrepareFails(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+	query := `SELECT id, original_url, short_url, user_id, tags, title, description, pinned, campaign_id FROM url_records WHERE user_id = \$1 ORDER BY created_at, short_url;`
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "original_url", "short_url", "user_id", "tags", "title", "description", "pinned", "campaign_id"}).AddRow("1", "https://example.com", "abc", "user-1", "[]", "", "", false, "")
+	}
+
+	// The query still runs without being prepared
+	mock.ExpectPrepare(query).WillReturnError(errors.New("connection reset"))
+	mock.ExpectQuery(query).WithArgs("user-1").WillReturnRows(rows())
+	res, err := repo.FindByUserID(context.Background(), "user-1", storage.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, *res, 1)
+
+	// Preparing it is tried again next time
+	mock.ExpectPrepare(query).ExpectQuery().WithArgs("user-1").WillReturnRows(rows())
+	res, err = repo.FindByUserID(context.Background(), "user-1", storage.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, *res, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatementsBounded(t *testing.T) {
+	_, mock, repo := setupMockDB(t)
+
+	for i := 0; i < maxPreparedStatements; i++ {
+		mock.ExpectPrepare(fmt.Sprintf("SELECT %d", i))
+	}
+	for i := 0; i < maxPreparedStatements; i++ {
+		require.NotNil(t, repo.stmts.prepare(context.Background(), fmt.Sprintf("SELECT %d", i)))
+	}
+
+	// Further queries are not prepared, while the prepared ones are still reused
+	assert.Nil(t, repo.stmts.prepare(context.Background(), "SELECT 1000"))
+	assert.NotNil(t, repo.stmts.prepare(context.Background(), "SELECT 0"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,47 @@
+// Package storage provides an in-memory store of API key hashes, used when
+// the service runs without a database.
+package storage
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryAPIKeyStorage keeps API key hashes in memory, mapping each hash to the
+// ID of the user it was issued to. It is concurrency-safe via sync.RWMutex.
+type MemoryAPIKeyStorage struct {
+	keys map[string]string // Maps API key hash to user ID
+	mu   sync.RWMutex      // Guards access to the map
+}
+
+// NewMemoryAPIKeyStorage initializes and returns a new MemoryAPIKeyStorage instance.
+func NewMemoryAPIKeyStorage() *MemoryAPIKeyStorage {
+	return &MemoryAPIKeyStorage{
+		keys: make(map[string]string),
+	}
+}
+
+// SaveAPIKey stores the API key hash for the given user ID.
+// Returns ErrConflict if the hash is already stored.
+func (m *MemoryAPIKeyStorage) SaveAPIKey(ctx context.Context, hash string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.keys[hash]; exists {
+		return ErrConflict
+	}
+	m.keys[hash] = userID
+	return nil
+}
+
+// FindUserByAPIKey returns the user ID the API key hash was issued to.
+// Returns ErrNotFound if the hash is unknown.
+func (m *MemoryAPIKeyStorage) FindUserByAPIKey(ctx context.Context, hash string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if userID, exists := m.keys[hash]; exists {
+		return userID, nil
+	}
+	return "", ErrNotFound
+}
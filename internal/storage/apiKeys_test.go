@@ -0,0 +1,30 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestMemoryAPIKeyStorage(t *testing.T) {
+	keys := storage.NewMemoryAPIKeyStorage()
+	ctx := context.Background()
+
+	// Save
+	assert.NoError(t, keys.SaveAPIKey(ctx, "hash1", "user1"))
+
+	// Save same hash again - should fail
+	assert.ErrorIs(t, keys.SaveAPIKey(ctx, "hash1", "user2"), storage.ErrConflict)
+
+	// Find by hash
+	userID, err := keys.FindUserByAPIKey(ctx, "hash1")
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+
+	// Find non-existing hash
+	_, err = keys.FindUserByAPIKey(ctx, "unknown")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
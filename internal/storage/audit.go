@@ -0,0 +1,57 @@
+// Package storage provides an in-memory append-only audit log, used when the
+// service runs without a database.
+package storage
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryAuditStorage keeps audit events in memory in the order they were appended.
+// It is concurrency-safe via sync.RWMutex.
+type MemoryAuditStorage struct {
+	events []AuditEvent // Appended events, oldest first
+	mu     sync.RWMutex // Guards access to the slice
+}
+
+// NewMemoryAuditStorage initializes and returns a new MemoryAuditStorage instance.
+func NewMemoryAuditStorage() *MemoryAuditStorage {
+	return &MemoryAuditStorage{}
+}
+
+// AppendAudit appends an event to the log and assigns it the next sequence number.
+func (m *MemoryAuditStorage) AppendAudit(ctx context.Context, e AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e.ID = int64(len(m.events) + 1)
+	e.Targets = append([]string(nil), e.Targets...)
+	m.events = append(m.events, e)
+	return nil
+}
+
+// FindAudit returns the events matching the filter, newest first.
+func (m *MemoryAuditStorage) FindAudit(ctx context.Context, f AuditFilter) ([]AuditEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := make([]AuditEvent, 0)
+	for i := len(m.events) - 1; i >= 0; i-- {
+		e := m.events[i]
+		if f.UserID != "" && e.UserID != f.UserID {
+			continue
+		}
+		if !f.Since.IsZero() && e.Time.Before(f.Since) {
+			continue
+		}
+		if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+			continue
+		}
+
+		res = append(res, e)
+		if f.Limit > 0 && len(res) == f.Limit {
+			break
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,50 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestMemoryAuditStorage(t *testing.T) {
+	log := storage.NewMemoryAuditStorage()
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, userID := range []string{"u1", "u2", "u1"} {
+		err := log.AppendAudit(ctx, storage.AuditEvent{
+			Time:    start.Add(time.Duration(i) * time.Hour),
+			UserID:  userID,
+			Action:  "POST /api/v1/shorten",
+			Targets: []string{"short"},
+		})
+		assert.NoError(t, err)
+	}
+
+	// All events, newest first
+	events, err := log.FindAudit(ctx, storage.AuditFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+	assert.Equal(t, []int64{3, 2, 1}, []int64{events[0].ID, events[1].ID, events[2].ID})
+
+	// By user
+	events, err = log.FindAudit(ctx, storage.AuditFilter{UserID: "u1"})
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	// By time range
+	events, err = log.FindAudit(ctx, storage.AuditFilter{Since: start.Add(time.Hour), Until: start.Add(2 * time.Hour)})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "u2", events[0].UserID)
+
+	// Limited
+	events, err = log.FindAudit(ctx, storage.AuditFilter{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, int64(3), events[0].ID)
+}
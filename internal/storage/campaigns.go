@@ -0,0 +1,70 @@
+// Package storage provides an in-memory store of campaigns, used when the
+// service runs without a database.
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryCampaignStorage keeps campaigns in memory, keyed by campaign ID.
+// It is concurrency-safe via sync.RWMutex.
+type MemoryCampaignStorage struct {
+	campaigns map[string]Campaign // Maps campaign ID to the campaign
+	mu        sync.RWMutex        // Guards access to the map
+}
+
+// NewMemoryCampaignStorage initializes and returns a new MemoryCampaignStorage instance.
+func NewMemoryCampaignStorage() *MemoryCampaignStorage {
+	return &MemoryCampaignStorage{
+		campaigns: make(map[string]Campaign),
+	}
+}
+
+// CreateCampaign stores a new campaign. Returns ErrConflict if the ID is
+// already stored or the user has a campaign with the same name.
+func (m *MemoryCampaignStorage) CreateCampaign(ctx context.Context, c Campaign) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, existing := range m.campaigns {
+		if id == c.ID || (existing.UserID == c.UserID && existing.Name == c.Name) {
+			return ErrConflict
+		}
+	}
+	m.campaigns[c.ID] = c
+	return nil
+}
+
+// FindCampaign retrieves a campaign by ID. Returns ErrNotFound if there is no
+// such campaign.
+func (m *MemoryCampaignStorage) FindCampaign(ctx context.Context, id string) (*Campaign, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if c, exists := m.campaigns[id]; exists {
+		return &c, nil
+	}
+	return nil, ErrNotFound
+}
+
+// FindCampaignsByUserID returns the campaigns of the user, oldest first.
+func (m *MemoryCampaignStorage) FindCampaignsByUserID(ctx context.Context, userID string) ([]Campaign, error) {
+	m.mu.RLock()
+	res := make([]Campaign, 0)
+	for _, c := range m.campaigns {
+		if c.UserID == userID {
+			res = append(res, c)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].CreatedAt.Equal(res[j].CreatedAt) {
+			return res[i].CreatedAt.Before(res[j].CreatedAt)
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
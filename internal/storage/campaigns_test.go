@@ -0,0 +1,42 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestMemoryCampaignStorage(t *testing.T) {
+	campaigns := storage.NewMemoryCampaignStorage()
+	ctx := context.Background()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	spring := storage.Campaign{ID: "c2", UserID: "u1", Name: "Spring sale", CreatedAt: createdAt.Add(time.Hour)}
+	launch := storage.Campaign{ID: "c1", UserID: "u1", Name: "Launch", CreatedAt: createdAt}
+	require.NoError(t, campaigns.CreateCampaign(ctx, spring))
+	require.NoError(t, campaigns.CreateCampaign(ctx, launch))
+	require.NoError(t, campaigns.CreateCampaign(ctx, storage.Campaign{ID: "c3", UserID: "u2", Name: "Launch", CreatedAt: createdAt}))
+
+	// Names are unique per user, IDs across users
+	assert.ErrorIs(t, campaigns.CreateCampaign(ctx, storage.Campaign{ID: "c4", UserID: "u1", Name: "Launch"}), storage.ErrConflict)
+	assert.ErrorIs(t, campaigns.CreateCampaign(ctx, storage.Campaign{ID: "c1", UserID: "u3", Name: "Other"}), storage.ErrConflict)
+
+	found, err := campaigns.FindCampaign(ctx, "c1")
+	require.NoError(t, err)
+	assert.Equal(t, launch, *found)
+	_, err = campaigns.FindCampaign(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	list, err := campaigns.FindCampaignsByUserID(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, []storage.Campaign{launch, spring}, list)
+
+	list, err = campaigns.FindCampaignsByUserID(ctx, "nobody")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
@@ -0,0 +1,138 @@
+// Package storage provides an in-memory store of click counts, used when the
+// service runs without a database.
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ClickBucket is the granularity clicks are counted with; time windows of
+// click queries are rounded down to it.
+const ClickBucket = time.Hour
+
+// ClickRetention is how long click counts are kept.
+const ClickRetention = 31 * 24 * time.Hour
+
+// MemoryClickStorage counts the clicks of short URLs in memory, per ClickBucket.
+// It is concurrency-safe via sync.Mutex.
+type MemoryClickStorage struct {
+	counts map[string]map[time.Time]int // Click counts by short URL and start of their bucket
+	latest time.Time                    // Newest bucket, old buckets are pruned when it changes
+	mu     sync.Mutex                   // Guards access to the map
+}
+
+// NewMemoryClickStorage initializes and returns a new MemoryClickStorage instance.
+func NewMemoryClickStorage() *MemoryClickStorage {
+	return &MemoryClickStorage{counts: make(map[string]map[time.Time]int)}
+}
+
+// RecordClick counts a click of the short URL at the given time.
+func (m *MemoryClickStorage) RecordClick(ctx context.Context, short string, at time.Time) error {
+	return m.RecordClicks(ctx, []BucketClicks{{Short: short, Bucket: at.UTC().Truncate(ClickBucket), Clicks: 1}})
+}
+
+// RecordClicks adds the clicks to the counts of their short URLs and buckets.
+func (m *MemoryClickStorage) RecordClicks(ctx context.Context, clicks []BucketClicks) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range clicks {
+		bucket := c.Bucket.UTC().Truncate(ClickBucket)
+		if bucket.After(m.latest) {
+			m.latest = bucket
+			m.prune(bucket.Add(-ClickRetention))
+		}
+
+		buckets, ok := m.counts[c.Short]
+		if !ok {
+			buckets = make(map[time.Time]int)
+			m.counts[c.Short] = buckets
+		}
+		buckets[bucket] += c.Clicks
+	}
+	return nil
+}
+
+// TopClicked returns up to limit short URLs with the most clicks since the
+// given time, most clicked first; limit 0 means no limit.
+func (m *MemoryClickStorage) TopClicked(ctx context.Context, since time.Time, limit int) ([]ClickCount, error) {
+	since = since.UTC().Truncate(ClickBucket)
+
+	m.mu.Lock()
+	res := make([]ClickCount, 0)
+	for short, buckets := range m.counts {
+		c := ClickCount{Short: short}
+		for bucket, n := range buckets {
+			if !bucket.Before(since) {
+				c.Clicks += n
+			}
+		}
+		if c.Clicks > 0 {
+			res = append(res, c)
+		}
+	}
+	m.mu.Unlock()
+
+	sortClickCounts(res)
+	if limit > 0 && limit < len(res) {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// ClicksOf returns the clicks of the given short URLs since the given time,
+// most clicked first. Short URLs without clicks are left out.
+func (m *MemoryClickStorage) ClicksOf(ctx context.Context, shorts []string, since time.Time) ([]ClickCount, error) {
+	since = since.UTC().Truncate(ClickBucket)
+
+	m.mu.Lock()
+	res := make([]ClickCount, 0, len(shorts))
+	seen := make(map[string]bool, len(shorts))
+	for _, short := range shorts {
+		if seen[short] {
+			continue
+		}
+		seen[short] = true
+
+		c := ClickCount{Short: short}
+		for bucket, n := range m.counts[short] {
+			if !bucket.Before(since) {
+				c.Clicks += n
+			}
+		}
+		if c.Clicks > 0 {
+			res = append(res, c)
+		}
+	}
+	m.mu.Unlock()
+
+	sortClickCounts(res)
+	return res, nil
+}
+
+// prune drops the buckets that started before the given time.
+func (m *MemoryClickStorage) prune(before time.Time) {
+	for short, buckets := range m.counts {
+		for bucket := range buckets {
+			if bucket.Before(before) {
+				delete(buckets, bucket)
+			}
+		}
+		if len(buckets) == 0 {
+			delete(m.counts, short)
+		}
+	}
+}
+
+// sortClickCounts orders counts by descending clicks, then by short URL.
+func sortClickCounts(counts []ClickCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Clicks != counts[j].Clicks {
+			return counts[i].Clicks > counts[j].Clicks
+		}
+		return counts[i].Short < counts[j].Short
+	})
+}
@@ -0,0 +1,82 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestMemoryClickStorage(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryClickStorage()
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	require.NoError(t, s.RecordClick(ctx, "old", now.Add(-2*time.Hour)))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.RecordClick(ctx, "b", now))
+	}
+	require.NoError(t, s.RecordClick(ctx, "a", now))
+	require.NoError(t, s.RecordClick(ctx, "c", now.Add(-10*time.Minute)))
+
+	// Windows start at the beginning of their bucket
+	top, err := s.TopClicked(ctx, now.Add(-15*time.Minute), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.ClickCount{{Short: "b", Clicks: 3}, {Short: "a", Clicks: 1}, {Short: "c", Clicks: 1}}, top)
+
+	top, err = s.TopClicked(ctx, now.Add(-3*time.Hour), 2)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.ClickCount{{Short: "b", Clicks: 3}, {Short: "a", Clicks: 1}}, top)
+
+	// Clicks older than the retention are dropped
+	require.NoError(t, s.RecordClick(ctx, "a", now.Add(storage.ClickRetention+time.Hour)))
+	top, err = s.TopClicked(ctx, now.Add(-3*time.Hour), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.ClickCount{{Short: "a", Clicks: 1}}, top)
+}
+
+func TestMemoryClickStorage_ClicksOf(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryClickStorage()
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	require.NoError(t, s.RecordClicks(ctx, []storage.BucketClicks{
+		{Short: "a", Bucket: now, Clicks: 2},
+		{Short: "b", Bucket: now, Clicks: 5},
+		{Short: "b", Bucket: now.Add(-3 * time.Hour), Clicks: 1},
+		{Short: "other", Bucket: now, Clicks: 9},
+	}))
+
+	// Repeated and unclicked short URLs are counted once or left out
+	counts, err := s.ClicksOf(ctx, []string{"a", "b", "a", "none"}, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []storage.ClickCount{{Short: "b", Clicks: 5}, {Short: "a", Clicks: 2}}, counts)
+
+	counts, err = s.ClicksOf(ctx, []string{"b"}, now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []storage.ClickCount{{Short: "b", Clicks: 6}}, counts)
+}
+
+func TestMemoryClickStorage_RecordClicks(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryClickStorage()
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	require.NoError(t, s.RecordClick(ctx, "a", now))
+	require.NoError(t, s.RecordClicks(ctx, []storage.BucketClicks{
+		{Short: "a", Bucket: now.Truncate(storage.ClickBucket), Clicks: 2},
+		{Short: "b", Bucket: now.Add(-time.Hour).Truncate(storage.ClickBucket), Clicks: 5},
+	}))
+
+	top, err := s.TopClicked(ctx, now, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.ClickCount{{Short: "a", Clicks: 3}}, top)
+
+	top, err = s.TopClicked(ctx, now.Add(-time.Hour), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.ClickCount{{Short: "b", Clicks: 5}, {Short: "a", Clicks: 3}}, top)
+}
@@ -0,0 +1,108 @@
+// Package storage provides stores for batches of deletions that could not be
+// applied, so that they can be inspected and replayed later.
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetterBatch is a batch of records the delete worker gave up on.
+type DeadLetterBatch struct {
+	Time     time.Time   `json:"time"`
+	Error    string      `json:"error"`
+	Attempts int         `json:"attempts"`
+	Records  []URLRecord `json:"records"`
+}
+
+// MemoryDeadLetterStorage keeps dead-lettered batches in memory.
+// It is concurrency-safe via sync.RWMutex.
+type MemoryDeadLetterStorage struct {
+	batches []DeadLetterBatch // Stored batches, oldest first
+	mu      sync.RWMutex      // Guards access to the slice
+}
+
+// NewMemoryDeadLetterStorage initializes and returns a new MemoryDeadLetterStorage instance.
+func NewMemoryDeadLetterStorage() *MemoryDeadLetterStorage {
+	return &MemoryDeadLetterStorage{}
+}
+
+// PutDeadLetter stores a copy of the batch.
+func (m *MemoryDeadLetterStorage) PutDeadLetter(ctx context.Context, b DeadLetterBatch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b.Records = append([]URLRecord(nil), b.Records...)
+	m.batches = append(m.batches, b)
+	return nil
+}
+
+// ReadDeadLetters returns all stored batches, oldest first.
+func (m *MemoryDeadLetterStorage) ReadDeadLetters(ctx context.Context) ([]DeadLetterBatch, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]DeadLetterBatch(nil), m.batches...), nil
+}
+
+// FileDeadLetterStorage appends dead-lettered batches to a file, one JSON
+// object per line.
+type FileDeadLetterStorage struct {
+	path string     // Path of the file
+	mu   sync.Mutex // Serializes appends
+}
+
+// NewFileDeadLetterStorage returns a FileDeadLetterStorage writing to path.
+// The file is created on the first stored batch.
+func NewFileDeadLetterStorage(path string) *FileDeadLetterStorage {
+	return &FileDeadLetterStorage{path: path}
+}
+
+// PutDeadLetter appends the batch to the file and syncs it to disk.
+func (f *FileDeadLetterStorage) PutDeadLetter(ctx context.Context, b DeadLetterBatch) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0660)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(b); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// ReadDeadLetters returns all batches stored in the file, oldest first.
+// A missing file holds no batches.
+func (f *FileDeadLetterStorage) ReadDeadLetters(ctx context.Context) ([]DeadLetterBatch, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var batches []DeadLetterBatch
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var b DeadLetterBatch
+		if err := json.Unmarshal(scanner.Bytes(), &b); err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+	}
+	return batches, scanner.Err()
+}
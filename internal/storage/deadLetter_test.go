@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryDeadLetterStorage(t *testing.T) {
+	s := NewMemoryDeadLetterStorage()
+	ctx := context.Background()
+
+	records := []URLRecord{{Short: "abc", UserID: "user"}}
+	require.NoError(t, s.PutDeadLetter(ctx, DeadLetterBatch{Error: "boom", Attempts: 2, Records: records}))
+
+	// The stored batch does not alias the caller's slice
+	records[0].Short = "changed"
+
+	batches, err := s.ReadDeadLetters(ctx)
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	assert.Equal(t, "boom", batches[0].Error)
+	assert.Equal(t, "abc", batches[0].Records[0].Short)
+}
+
+func TestFileDeadLetterStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	s := NewFileDeadLetterStorage(path)
+	ctx := context.Background()
+
+	batches, err := s.ReadDeadLetters(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, batches)
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, s.PutDeadLetter(ctx, DeadLetterBatch{Time: now, Error: "first", Attempts: 4, Records: []URLRecord{{Short: "abc", UserID: "u1"}}}))
+	require.NoError(t, s.PutDeadLetter(ctx, DeadLetterBatch{Time: now, Error: "second", Attempts: 1, Records: []URLRecord{{Short: "def", UserID: "u2"}}}))
+
+	// Batches survive reopening the file
+	batches, err = NewFileDeadLetterStorage(path).ReadDeadLetters(ctx)
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+	assert.Equal(t, DeadLetterBatch{Time: now, Error: "first", Attempts: 4, Records: []URLRecord{{Short: "abc", UserID: "u1"}}}, batches[0])
+	assert.Equal(t, "second", batches[1].Error)
+}
+
+func TestFileDeadLetterStorage_InvalidLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("not json\n"), 0600))
+
+	_, err := NewFileDeadLetterStorage(path).ReadDeadLetters(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,200 @@
+// Package storage provides a file journal of deletions that were accepted but
+// not yet applied, so that they survive a crash or restart.
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+)
+
+// journalEntry is a line of the delete journal.
+type journalEntry struct {
+	Op     string `json:"op"` // "add" when queued, "done" when applied
+	Short  string `json:"short_url"`
+	UserID string `json:"user_id"`
+}
+
+// pendingKey identifies a pending deletion.
+type pendingKey struct {
+	short  string
+	userID string
+}
+
+// journalCompactEntries is the number of lines past which the delete journal
+// is compacted once most of them are for applied deletions.
+const journalCompactEntries = 1000
+
+// FileDeleteJournal records pending deletions in an append-only file, one
+// JSON object per line. The file is compacted to the pending deletions on
+// open, whenever all of them are applied and once it grows past
+// journalCompactEntries lines that are mostly stale. It is concurrency-safe
+// via sync.Mutex.
+type FileDeleteJournal struct {
+	path    string             // Location of the journal
+	file    *os.File           // Journal opened for appending
+	pending map[pendingKey]int // Pending deletions and their queue position
+	seq     int                // Position assigned to the next added deletion
+	entries int                // Lines in the file
+	mu      sync.Mutex         // Guards the fields above
+}
+
+// NewFileDeleteJournal opens or creates the journal at path, loads the
+// pending deletions and compacts the file so that it holds only those.
+func NewFileDeleteJournal(path string) (*FileDeleteJournal, error) {
+	j := &FileDeleteJournal{path: path, pending: make(map[pendingKey]int)}
+
+	if err := j.load(path); err != nil {
+		return nil, err
+	}
+	if err := j.compact(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// compact rewrites the journal with only the pending entries, then swaps it in
+// and reopens it for appending.
+func (j *FileDeleteJournal) compact() error {
+	tmp := j.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	keys := j.pendingKeys()
+	for _, k := range keys {
+		if err := encoder.Encode(journalEntry{Op: "add", Short: k.short, UserID: k.userID}); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+
+	file, err = os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0660)
+	if err != nil {
+		return err
+	}
+	if j.file != nil {
+		j.file.Close()
+	}
+	j.file = file
+	j.entries = len(keys)
+	return nil
+}
+
+// load replays the journal at path. A missing file holds no deletions.
+func (j *FileDeleteJournal) load(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		j.entries++
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A torn last line left by a crash is skipped
+			continue
+		}
+		j.apply(e)
+	}
+	return scanner.Err()
+}
+
+// apply updates the pending set with a journal entry.
+func (j *FileDeleteJournal) apply(e journalEntry) {
+	k := pendingKey{short: e.Short, userID: e.UserID}
+	switch e.Op {
+	case "add":
+		if _, ok := j.pending[k]; !ok {
+			j.pending[k] = j.seq
+			j.seq++
+		}
+	case "done":
+		delete(j.pending, k)
+	}
+}
+
+// pendingKeys returns the pending deletions in the order they were added.
+func (j *FileDeleteJournal) pendingKeys() []pendingKey {
+	keys := make([]pendingKey, 0, len(j.pending))
+	for k := range j.pending {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(a, b int) bool { return j.pending[keys[a]] < j.pending[keys[b]] })
+	return keys
+}
+
+// write appends entries for the records to the journal and syncs it to disk.
+func (j *FileDeleteJournal) write(op string, rs []URLRecord) error {
+	encoder := json.NewEncoder(j.file)
+	for _, r := range rs {
+		e := journalEntry{Op: op, Short: r.Short, UserID: r.UserID}
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+		j.apply(e)
+		j.entries++
+	}
+	return j.file.Sync()
+}
+
+// AppendPending records that the deletions were accepted.
+func (j *FileDeleteJournal) AppendPending(ctx context.Context, rs []URLRecord) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.write("add", rs)
+}
+
+// RemovePending records that the deletions were applied, compacting the
+// journal if it no longer holds pending deletions or grew mostly stale.
+func (j *FileDeleteJournal) RemovePending(ctx context.Context, rs []URLRecord) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.write("done", rs); err != nil {
+		return err
+	}
+	if len(j.pending) == 0 || (j.entries >= journalCompactEntries && j.entries >= 2*len(j.pending)) {
+		return j.compact()
+	}
+	return nil
+}
+
+// ReadPending returns the deletions that were accepted but not applied,
+// oldest first.
+func (j *FileDeleteJournal) ReadPending(ctx context.Context) ([]URLRecord, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	keys := j.pendingKeys()
+	rs := make([]URLRecord, 0, len(keys))
+	for _, k := range keys {
+		rs = append(rs, URLRecord{Short: k.short, UserID: k.userID})
+	}
+	return rs, nil
+}
+
+// Close closes the journal file.
+func (j *FileDeleteJournal) Close() error {
+	return j.file.Close()
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileDeleteJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json.deletes")
+	ctx := context.Background()
+
+	j, err := NewFileDeleteJournal(path)
+	require.NoError(t, err)
+
+	pending, err := j.ReadPending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	require.NoError(t, j.AppendPending(ctx, []URLRecord{{Short: "aaa", UserID: "u1"}, {Short: "bbb", UserID: "u1"}}))
+	require.NoError(t, j.AppendPending(ctx, []URLRecord{{Short: "ccc", UserID: "u2"}}))
+	require.NoError(t, j.RemovePending(ctx, []URLRecord{{Short: "bbb", UserID: "u1"}}))
+
+	pending, err = j.ReadPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []URLRecord{{Short: "aaa", UserID: "u1"}, {Short: "ccc", UserID: "u2"}}, pending)
+	require.NoError(t, j.Close())
+
+	// Reopening replays and compacts the journal
+	j, err = NewFileDeleteJournal(path)
+	require.NoError(t, err)
+	defer j.Close()
+
+	pending, err = j.ReadPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []URLRecord{{Short: "aaa", UserID: "u1"}, {Short: "ccc", UserID: "u2"}}, pending)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"op\":\"add\",\"short_url\":\"aaa\",\"user_id\":\"u1\"}\n{\"op\":\"add\",\"short_url\":\"ccc\",\"user_id\":\"u2\"}\n", string(data))
+}
+
+func TestFileDeleteJournal_TornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json.deletes")
+	require.NoError(t, os.WriteFile(path, []byte("{\"op\":\"add\",\"short_url\":\"aaa\",\"user_id\":\"u1\"}\n{\"op\":\"ad"), 0600))
+
+	j, err := NewFileDeleteJournal(path)
+	require.NoError(t, err)
+	defer j.Close()
+
+	pending, err := j.ReadPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []URLRecord{{Short: "aaa", UserID: "u1"}}, pending)
+}
+
+func TestFileDeleteJournal_CompactsAfterFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json.deletes")
+	ctx := context.Background()
+
+	j, err := NewFileDeleteJournal(path)
+	require.NoError(t, err)
+	defer j.Close()
+
+	// Applying every pending deletion empties the file
+	require.NoError(t, j.AppendPending(ctx, []URLRecord{{Short: "aaa", UserID: "u1"}, {Short: "bbb", UserID: "u1"}}))
+	require.NoError(t, j.RemovePending(ctx, []URLRecord{{Short: "aaa", UserID: "u1"}, {Short: "bbb", UserID: "u1"}}))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+
+	// A journal that never drains is compacted once it is mostly stale
+	require.NoError(t, j.AppendPending(ctx, []URLRecord{{Short: "keep", UserID: "u1"}}))
+	for i := 0; i < journalCompactEntries/2; i++ {
+		r := []URLRecord{{Short: "tmp", UserID: "u1"}}
+		require.NoError(t, j.AppendPending(ctx, r))
+		require.NoError(t, j.RemovePending(ctx, r))
+	}
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Less(t, len(data), 1000)
+
+	// Appends after a compaction go to the new file
+	require.NoError(t, j.AppendPending(ctx, []URLRecord{{Short: "ccc", UserID: "u2"}}))
+	require.NoError(t, j.Close())
+	j, err = NewFileDeleteJournal(path)
+	require.NoError(t, err)
+	defer j.Close()
+	pending, err := j.ReadPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []URLRecord{{Short: "keep", UserID: "u1"}, {Short: "ccc", UserID: "u2"}}, pending)
+}
@@ -11,13 +11,18 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/logger"
 )
 
 // FileStorage provides a file-based implementation of persistent storage
-// for URL records. Each record is stored as a JSON-encoded line.
+// for URL records. Each record is stored as a JSON-encoded line. Like the
+// database, it marks deleted records instead of removing them.
 type FileStorage struct {
 	file   *os.File     // Underlying file used for storage
 	mu     sync.RWMutex // Mutex to protect concurrent access
@@ -44,29 +49,74 @@ func NewFileStorage(p string, logger *zap.Logger) (*FileStorage, error) {
 }
 
 // Write appends a single URLRecord to the file in JSON format.
+// If the original URL is already stored, it returns the existing record and
+// ErrConflict, like the unique constraint of the database; a short URL that is
+// already taken returns ErrConflict alone.
 func (fs *FileStorage) Write(ctx context.Context, value URLRecord) (*URLRecord, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	encoder := json.NewEncoder(fs.file)
-	return &value, encoder.Encode(value)
+	records, err := fs.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if r.Original == value.Original {
+			return &r, ErrConflict
+		}
+		if r.Short == value.Short {
+			return nil, ErrConflict
+		}
+	}
+
+	if err := fs.append(ctx, []URLRecord{value}); err != nil {
+		return nil, err
+	}
+	return &value, nil
 }
 
-// WriteAll overwrites the file with the provided slice of URLRecords,
-// replacing all existing data.
+// WriteAll appends multiple URLRecords to the file. If any of them conflicts
+// with a stored record or another record of the batch, none is written and
+// ErrConflict is returned.
 func (fs *FileStorage) WriteAll(ctx context.Context, records []URLRecord) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if err := fs.file.Truncate(0); err != nil {
-		return fmt.Errorf("failed to truncate file: %w", err)
+	stored, err := fs.Read(ctx)
+	if err != nil {
+		return err
 	}
-	if _, err := fs.file.Seek(0, io.SeekStart); err != nil {
-		return fmt.Errorf("failed to seek to beginning of file: %w", err)
+	originals := make(map[string]struct{}, len(stored)+len(records))
+	shorts := make(map[string]struct{}, len(stored)+len(records))
+	for _, r := range stored {
+		originals[r.Original] = struct{}{}
+		shorts[r.Short] = struct{}{}
+	}
+	for _, r := range records {
+		_, dupOriginal := originals[r.Original]
+		_, dupShort := shorts[r.Short]
+		if dupOriginal || dupShort {
+			return ErrConflict
+		}
+		originals[r.Original] = struct{}{}
+		shorts[r.Short] = struct{}{}
 	}
 
-	writer := bufio.NewWriter(fs.file)
+	return fs.append(ctx, records)
+}
 
+// append writes records at the end of the file. The caller must hold the lock.
+// It returns ctx.Err() without writing if ctx is done, but once started the
+// records are written in full, so a cancelled batch is never half-written.
+func (fs *FileStorage) append(ctx context.Context, records []URLRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := fs.file.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("failed to seek to end of file: %w", err)
+	}
+
+	writer := bufio.NewWriter(fs.file)
 	for _, r := range records {
 		if err := json.NewEncoder(writer).Encode(r); err != nil {
 			return fmt.Errorf("failed to write record: %w", err)
@@ -76,12 +126,34 @@ func (fs *FileStorage) WriteAll(ctx context.Context, records []URLRecord) error
 	if err := writer.Flush(); err != nil {
 		return fmt.Errorf("failed to flush buffered writer: %w", err)
 	}
-
 	return nil
 }
 
+// rewrite overwrites the file with the provided slice of URLRecords,
+// replacing all existing data. The caller must hold the lock. Like append, it
+// returns ctx.Err() without touching the file if ctx is done.
+func (fs *FileStorage) rewrite(ctx context.Context, records []URLRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := fs.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate file: %w", err)
+	}
+	if _, err := fs.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek to beginning of file: %w", err)
+	}
+
+	return fs.append(ctx, records)
+}
+
 // Read parses all records from the file and returns them as a slice.
+// It stops with ctx.Err() as soon as ctx is done, so that request timeouts
+// cut off scans of large files.
 func (fs *FileStorage) Read(ctx context.Context) ([]URLRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	_, err := fs.file.Seek(0, io.SeekStart)
 	if err != nil {
 		return nil, err
@@ -90,6 +162,9 @@ func (fs *FileStorage) Read(ctx context.Context) ([]URLRecord, error) {
 	var records []URLRecord
 	scanner := bufio.NewScanner(fs.file)
 	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		line := scanner.Text()
 		var record URLRecord
 		if err := json.Unmarshal([]byte(line), &record); err != nil {
@@ -106,11 +181,12 @@ func (fs *FileStorage) Read(ctx context.Context) ([]URLRecord, error) {
 }
 
 // FindByShort searches for a URLRecord by its short URL value.
+// Returns ErrNotFound if the short URL is not found.
 func (fs *FileStorage) FindByShort(ctx context.Context, s string) (*URLRecord, error) {
-	fs.logger.Info("Got short:", zap.String("shortUrl", s))
+	logger.FromContext(ctx, fs.logger).Info("Got short:", zap.String("shortUrl", s))
 	records, err := fs.Read(ctx)
 	if err != nil {
-		fs.logger.Error("FindByShort error=", zap.String("error", err.Error()))
+		logger.FromContext(ctx, fs.logger).Error("FindByShort error=", zap.String("error", err.Error()))
 		return nil, err
 	}
 
@@ -120,10 +196,11 @@ func (fs *FileStorage) FindByShort(ctx context.Context, s string) (*URLRecord, e
 		}
 	}
 
-	return nil, errors.New("not found")
+	return nil, ErrNotFound
 }
 
 // FindByID looks up a URLRecord by its unique ID field.
+// Returns ErrNotFound if there is no record with the ID.
 func (fs *FileStorage) FindByID(ctx context.Context, id string) (URLRecord, error) {
 	records, err := fs.Read(ctx)
 	if err != nil {
@@ -136,11 +213,12 @@ func (fs *FileStorage) FindByID(ctx context.Context, id string) (URLRecord, erro
 		}
 	}
 
-	return URLRecord{}, nil
+	return URLRecord{}, ErrNotFound
 }
 
-// FindByUserID retrieves all records associated with a given user ID.
-func (fs *FileStorage) FindByUserID(ctx context.Context, userID string) (*[]URLRecord, error) {
+// FindByUserID retrieves the records associated with a given user ID,
+// filtered, ordered and paginated according to opts.
+func (fs *FileStorage) FindByUserID(ctx context.Context, userID string, opts ListOptions) (*[]URLRecord, error) {
 	records, err := fs.Read(ctx)
 	res := make([]URLRecord, 0)
 
@@ -154,30 +232,335 @@ func (fs *FileStorage) FindByUserID(ctx context.Context, userID string) (*[]URLR
 		}
 	}
 
+	res = opts.Apply(res)
 	return &res, nil
 }
 
-// DeleteBatch removes all records from the file that match the short URLs
-// of the provided slice of URLRecords.
+// DeleteBatch rewrites the file with the records matching the short URLs of
+// the provided slice of URLRecords marked as deleted, keeping the time of the
+// first deletion. Records are only deleted for the user owning them; others
+// are left unchanged.
 func (fs *FileStorage) DeleteBatch(ctx context.Context, rs []URLRecord) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
 	records, err := fs.Read(ctx)
 	if err != nil {
 		return err
 	}
 
-	toDelete := make(map[string]struct{}, len(rs))
+	owners := make(map[string]string, len(rs))
 	for _, url := range rs {
-		toDelete[url.Short] = struct{}{}
+		owners[url.Short] = url.UserID
+	}
+
+	now := time.Now().UTC()
+	changed := false
+	for i, r := range records {
+		if owner, found := owners[r.Short]; !found || owner != r.UserID || r.IsDeleted {
+			continue
+		}
+		records[i].IsDeleted = true
+		if r.DeletedAt == nil {
+			records[i].DeletedAt = &now
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	return fs.rewrite(ctx, records)
+}
+
+// FlagURL rewrites the file with the record of the short URL flagged with the
+// threat its original URL was found to be. Returns ErrNotFound if the short URL is not found.
+func (fs *FileStorage) FlagURL(ctx context.Context, short string, reason string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	records, err := fs.Read(ctx)
+	if err != nil {
+		return err
 	}
 
-	newRecords := make([]URLRecord, 0, len(records))
+	found := false
+	for i := range records {
+		if records[i].Short == short {
+			records[i].Flagged = reason
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return fs.rewrite(ctx, records)
+}
+
+// SetPassword rewrites the file with the password hash of the user's short URL
+// set; an empty hash removes the protection. Returns ErrNotFound if the user
+// does not own the short URL and ErrDeleted if it was deleted.
+func (fs *FileStorage) SetPassword(ctx context.Context, short string, userID string, hash string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	records, err := fs.Read(ctx)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i := range records {
+		if records[i].Short == short && records[i].UserID == userID {
+			if records[i].IsDeleted {
+				return ErrDeleted
+			}
+			records[i].PasswordHash = hash
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return fs.rewrite(ctx, records)
+}
+
+// SetTags rewrites the file with the tags of the user's short URL replaced.
+// Returns ErrNotFound if the user does not own the short URL and ErrDeleted if
+// it was deleted.
+func (fs *FileStorage) SetTags(ctx context.Context, short string, userID string, tags []string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	records, err := fs.Read(ctx)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i := range records {
+		if records[i].Short == short && records[i].UserID == userID {
+			if records[i].IsDeleted {
+				return ErrDeleted
+			}
+			records[i].Tags = slices.Clone(tags)
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return fs.rewrite(ctx, records)
+}
+
+// SetPinned rewrites the file with the user's short URL pinned or unpinned.
+// Returns ErrNotFound if the user does not own the short URL and ErrDeleted if
+// it was deleted.
+func (fs *FileStorage) SetPinned(ctx context.Context, short string, userID string, pinned bool) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	records, err := fs.Read(ctx)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i := range records {
+		if records[i].Short == short && records[i].UserID == userID {
+			if records[i].IsDeleted {
+				return ErrDeleted
+			}
+			records[i].Pinned = pinned
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return fs.rewrite(ctx, records)
+}
+
+// SetPrivate rewrites the file with the metadata and stats of the user's short
+// URL visible to its owner only, or to anyone. Returns ErrNotFound if the user
+// does not own the short URL and ErrDeleted if it was deleted.
+func (fs *FileStorage) SetPrivate(ctx context.Context, short string, userID string, private bool) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	records, err := fs.Read(ctx)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i := range records {
+		if records[i].Short == short && records[i].UserID == userID {
+			if records[i].IsDeleted {
+				return ErrDeleted
+			}
+			records[i].Private = private
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return fs.rewrite(ctx, records)
+}
+
+// SetCampaign rewrites the file with the user's short URL moved to the
+// campaign with the given ID; an empty ID removes it from its campaign.
+// Returns ErrNotFound if the user does not own the short URL and ErrDeleted if
+// it was deleted.
+func (fs *FileStorage) SetCampaign(ctx context.Context, short string, userID string, campaignID string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	records, err := fs.Read(ctx)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i := range records {
+		if records[i].Short == short && records[i].UserID == userID {
+			if records[i].IsDeleted {
+				return ErrDeleted
+			}
+			records[i].Campaign = campaignID
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return fs.rewrite(ctx, records)
+}
+
+// SetDetails rewrites the file with the title and description of the user's
+// short URL set. Returns ErrNotFound if the user does not own the short URL
+// and ErrDeleted if it was deleted.
+func (fs *FileStorage) SetDetails(ctx context.Context, short string, userID string, title string, description string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	records, err := fs.Read(ctx)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i := range records {
+		if records[i].Short == short && records[i].UserID == userID {
+			if records[i].IsDeleted {
+				return ErrDeleted
+			}
+			records[i].Title, records[i].Description = title, description
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return fs.rewrite(ctx, records)
+}
+
+// SetDefaultTitle rewrites the file with the title of the short URL set,
+// unless it has one or was deleted. Returns ErrNotFound if there is no such
+// short URL.
+func (fs *FileStorage) SetDefaultTitle(ctx context.Context, short string, title string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	records, err := fs.Read(ctx)
+	if err != nil {
+		return err
+	}
+
+	found, changed := false, false
+	for i := range records {
+		if records[i].Short == short {
+			found = true
+			if records[i].Title == "" && !records[i].IsDeleted {
+				records[i].Title = title
+				changed = true
+			}
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+	if !changed {
+		return nil
+	}
+
+	return fs.rewrite(ctx, records)
+}
+
+// FindExpired returns up to limit records that are not deleted and expired at
+// or before now; limit 0 returns all of them.
+func (fs *FileStorage) FindExpired(ctx context.Context, now time.Time, limit int) ([]URLRecord, error) {
+	records, err := fs.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]URLRecord, 0)
+	for _, r := range records {
+		if r.IsDeleted || !r.Expired(now) {
+			continue
+		}
+		res = append(res, r)
+		if limit > 0 && len(res) == limit {
+			break
+		}
+	}
+	return res, nil
+}
+
+// CountByUserID returns the number of active records in the file owned by the user.
+func (fs *FileStorage) CountByUserID(ctx context.Context, userID string) (int, error) {
+	records, err := fs.Read(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
 	for _, r := range records {
-		if _, found := toDelete[r.Short]; !found {
-			newRecords = append(newRecords, r)
+		if r.UserID == userID && !r.IsDeleted {
+			count++
 		}
 	}
+	return count, nil
+}
+
+// GetStats returns the number of active records in the file and of distinct users owning them.
+func (fs *FileStorage) GetStats(ctx context.Context) (int, int, error) {
+	records, err := fs.Read(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return activeStats(records)
+}
+
+// GetDailyStats returns the number of records created and deleted on each day
+// from from until to, in UTC, oldest first. Days without changes are omitted.
+func (fs *FileStorage) GetDailyStats(ctx context.Context, from, to time.Time) ([]DailyStats, error) {
+	records, err := fs.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	return fs.WriteAll(ctx, newRecords)
+	return dailyStats(records, from, to), nil
 }
 
 // Close closes the underlying file handle used by FileStorage.
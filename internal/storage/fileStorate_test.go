@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -63,6 +64,40 @@ func TestWriteAll(t *testing.T) {
 		t.Fatalf("expected %d records, got %d", len(records), len(writtenRecords))
 	}
 
+	// Batches are appended, unless one of their records conflicts
+	require.NoError(t, fs.WriteAll(context.Background(), []URLRecord{
+		{ID: "3", Short: "short3", UserID: "user1", Original: "http://example.com/3"},
+	}))
+	assert.ErrorIs(t, fs.WriteAll(context.Background(), []URLRecord{
+		{ID: "4", Short: "short4", UserID: "user1", Original: "http://example.com/4"},
+		{ID: "5", Short: "short5", UserID: "user1", Original: "http://example.com/1"},
+	}), ErrConflict)
+
+	writtenRecords, err = fs.Read(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, writtenRecords, 3)
+}
+
+func TestWrite_Conflict(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "write_conflict.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	record := URLRecord{Short: "abc", Original: "https://example.com", UserID: "user-1"}
+	_, err = fs.Write(context.Background(), record)
+	require.NoError(t, err)
+
+	// The same original returns the existing record, as the database does
+	existing, err := fs.Write(context.Background(), URLRecord{Short: "def", Original: "https://example.com", UserID: "user-2"})
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, &record, existing)
+
+	_, err = fs.Write(context.Background(), URLRecord{Short: "abc", Original: "https://other.example.com", UserID: "user-2"})
+	assert.ErrorIs(t, err, ErrConflict)
+
+	records, err := fs.Read(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []URLRecord{record}, records)
 }
 
 func TestFindByShort(t *testing.T) {
@@ -118,6 +153,11 @@ func TestFindByID(t *testing.T) {
 	result, err := fs.FindByID(context.Background(), "id-1")
 	require.NoError(t, err)
 	assert.Equal(t, "https://example1.com", result.Original)
+
+	_, err = fs.FindByID(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = fs.FindByShort(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
 }
 
 func TestFindByUserID(t *testing.T) {
@@ -144,7 +184,7 @@ func TestFindByUserID(t *testing.T) {
 	require.NoError(t, err)
 
 	// Find records by user ID
-	result, err := fs.FindByUserID(context.Background(), "user-id-1")
+	result, err := fs.FindByUserID(context.Background(), "user-id-1", ListOptions{})
 	require.NoError(t, err)
 	assert.Len(t, *result, 2)
 }
@@ -172,18 +212,197 @@ func TestDeleteBatch(t *testing.T) {
 	err = fs.WriteAll(context.Background(), records)
 	require.NoError(t, err)
 
-	// Delete batch
+	// Delete batch, with a record the user does not own
 	err = fs.DeleteBatch(context.Background(), []URLRecord{
-		{Short: "abc123"},
-		{Short: "ghi789"},
+		{Short: "abc123", UserID: "user-id-1"},
+		{Short: "def456", UserID: "user-id-1"},
+		{Short: "ghi789", UserID: "user-id-1"},
 	})
 	require.NoError(t, err)
 
-	// Read back the records
-	remainingRecords, err := fs.Read(context.Background())
+	// Read back the records, which are marked instead of removed
+	records, err = fs.Read(context.Background())
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	for _, r := range records {
+		assert.Equal(t, r.UserID == "user-id-1", r.IsDeleted, r.Short)
+		assert.Equal(t, r.IsDeleted, r.DeletedAt != nil, r.Short)
+	}
+
+	// Deleted records stay resolvable, and cannot be protected any more
+	r, err := fs.FindByShort(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.True(t, r.IsDeleted)
+	assert.ErrorIs(t, fs.SetPassword(context.Background(), "abc123", "user-id-1", "hash"), ErrDeleted)
+
+	count, err := fs.CountByUserID(context.Background(), "user-id-1")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	urls, users, err := fs.GetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, urls)
+	assert.Equal(t, 1, users)
+}
+
+func TestFindExpired(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "find_expired.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	require.NoError(t, fs.WriteAll(context.Background(), []URLRecord{
+		{Original: "https://example1.com", Short: "abc123", UserID: "user-id-1", ExpiresAt: &past},
+		{Original: "https://example2.com", Short: "def456", UserID: "user-id-2", ExpiresAt: &future},
+		{Original: "https://example3.com", Short: "ghi789", UserID: "user-id-1"},
+	}))
+
+	expired, err := fs.FindExpired(context.Background(), now, 10)
 	require.NoError(t, err)
-	assert.Len(t, remainingRecords, 1)
-	assert.Equal(t, "https://example2.com", remainingRecords[0].Original)
+	require.Len(t, expired, 1)
+	assert.Equal(t, "abc123", expired[0].Short)
+	assert.True(t, past.Equal(*expired[0].ExpiresAt))
+}
+
+func TestFlagURL(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "flag_url.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	require.NoError(t, fs.WriteAll(context.Background(), []URLRecord{
+		{Original: "https://example1.com", Short: "abc123", UserID: "user-id-1"},
+		{Original: "https://example2.com", Short: "def456", UserID: "user-id-2"},
+	}))
+
+	require.NoError(t, fs.FlagURL(context.Background(), "def456", "SOCIAL_ENGINEERING"))
+	r, err := fs.FindByShort(context.Background(), "def456")
+	require.NoError(t, err)
+	assert.Equal(t, "SOCIAL_ENGINEERING", r.Flagged)
+
+	r, err = fs.FindByShort(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Empty(t, r.Flagged)
+
+	assert.ErrorIs(t, fs.FlagURL(context.Background(), "missing", "MALWARE"), ErrNotFound)
+}
+
+func TestSetPassword(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "set_password.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	require.NoError(t, fs.WriteAll(context.Background(), []URLRecord{
+		{Original: "https://example1.com", Short: "abc123", UserID: "user-id-1"},
+	}))
+
+	require.NoError(t, fs.SetPassword(context.Background(), "abc123", "user-id-1", "hash"))
+	r, err := fs.FindByShort(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "hash", r.PasswordHash)
+
+	assert.ErrorIs(t, fs.SetPassword(context.Background(), "abc123", "user-id-2", ""), ErrNotFound)
+}
+
+func TestSetTags(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "set_tags.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	require.NoError(t, fs.WriteAll(context.Background(), []URLRecord{
+		{Original: "https://example1.com", Short: "abc123", UserID: "user-id-1"},
+		{Original: "https://example2.com", Short: "def456", UserID: "user-id-1", IsDeleted: true},
+	}))
+
+	require.NoError(t, fs.SetTags(context.Background(), "abc123", "user-id-1", []string{"work"}))
+	r, err := fs.FindByShort(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"work"}, r.Tags)
+
+	assert.ErrorIs(t, fs.SetTags(context.Background(), "abc123", "user-id-2", nil), ErrNotFound)
+	assert.ErrorIs(t, fs.SetTags(context.Background(), "def456", "user-id-1", nil), ErrDeleted)
+}
+
+func TestSetPinned(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "set_pinned.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	require.NoError(t, fs.WriteAll(context.Background(), []URLRecord{
+		{Original: "https://example1.com", Short: "abc123", UserID: "user-id-1"},
+		{Original: "https://example2.com", Short: "def456", UserID: "user-id-1", IsDeleted: true},
+	}))
+
+	require.NoError(t, fs.SetPinned(context.Background(), "abc123", "user-id-1", true))
+	records, err := fs.FindByUserID(context.Background(), "user-id-1", ListOptions{PinnedFirst: true})
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", (*records)[0].Short)
+	assert.True(t, (*records)[0].Pinned)
+
+	assert.ErrorIs(t, fs.SetPinned(context.Background(), "abc123", "user-id-2", false), ErrNotFound)
+	assert.ErrorIs(t, fs.SetPinned(context.Background(), "def456", "user-id-1", true), ErrDeleted)
+}
+
+func TestSetPrivate(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "set_private.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	require.NoError(t, fs.WriteAll(context.Background(), []URLRecord{
+		{Original: "https://example1.com", Short: "abc123", UserID: "user-id-1"},
+		{Original: "https://example2.com", Short: "def456", UserID: "user-id-1", IsDeleted: true},
+	}))
+
+	require.NoError(t, fs.SetPrivate(context.Background(), "abc123", "user-id-1", true))
+	r, err := fs.FindByShort(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.True(t, r.Private)
+
+	assert.ErrorIs(t, fs.SetPrivate(context.Background(), "abc123", "user-id-2", false), ErrNotFound)
+	assert.ErrorIs(t, fs.SetPrivate(context.Background(), "def456", "user-id-1", true), ErrDeleted)
+}
+
+func TestSetCampaign(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "set_campaign.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	require.NoError(t, fs.WriteAll(context.Background(), []URLRecord{
+		{Original: "https://example1.com", Short: "abc123", UserID: "user-id-1"},
+		{Original: "https://example2.com", Short: "def456", UserID: "user-id-1", IsDeleted: true},
+	}))
+
+	require.NoError(t, fs.SetCampaign(context.Background(), "abc123", "user-id-1", "launch"))
+	r, err := fs.FindByShort(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "launch", r.Campaign)
+
+	assert.ErrorIs(t, fs.SetCampaign(context.Background(), "abc123", "user-id-2", ""), ErrNotFound)
+	assert.ErrorIs(t, fs.SetCampaign(context.Background(), "def456", "user-id-1", "launch"), ErrDeleted)
+}
+
+func TestSetDetails(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "set_details.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	require.NoError(t, fs.WriteAll(context.Background(), []URLRecord{
+		{Original: "https://example1.com", Short: "abc123", UserID: "user-id-1"},
+		{Original: "https://example2.com", Short: "def456", UserID: "user-id-1", Title: "Mine"},
+	}))
+
+	require.NoError(t, fs.SetDefaultTitle(context.Background(), "abc123", "Fetched"))
+	require.NoError(t, fs.SetDefaultTitle(context.Background(), "def456", "Fetched"))
+	require.NoError(t, fs.SetDetails(context.Background(), "abc123", "user-id-1", "Fetched", "Notes"))
+	r, err := fs.FindByShort(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "Fetched", r.Title)
+	assert.Equal(t, "Notes", r.Description)
+	r, err = fs.FindByShort(context.Background(), "def456")
+	require.NoError(t, err)
+	assert.Equal(t, "Mine", r.Title)
+
+	assert.ErrorIs(t, fs.SetDetails(context.Background(), "abc123", "user-id-2", "", ""), ErrNotFound)
+	assert.ErrorIs(t, fs.SetDefaultTitle(context.Background(), "missing", "Fetched"), ErrNotFound)
 }
 
 func TestClose(t *testing.T) {
@@ -221,3 +440,82 @@ func TestPingContext(t *testing.T) {
 	err = fs.PingContext(context.Background())
 	assert.Error(t, err)
 }
+
+func TestGetStats(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "stats_test.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	require.NoError(t, fs.WriteAll(context.Background(), []URLRecord{
+		{Short: "s1", Original: "https://1.com", UserID: "u1"},
+		{Short: "s2", Original: "https://2.com", UserID: "u1"},
+		{Short: "s3", Original: "https://3.com", UserID: "u2"},
+	}))
+
+	urls, users, err := fs.GetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, urls)
+	assert.Equal(t, 2, users)
+}
+
+func TestGetDailyStats(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "daily_test.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	today := Day(time.Now())
+	yesterday := today.AddDate(0, 0, -1)
+	require.NoError(t, fs.WriteAll(context.Background(), []URLRecord{
+		{Short: "s1", Original: "https://1.com", UserID: "u1", CreatedAt: &yesterday},
+		{Short: "s2", Original: "https://2.com", UserID: "u1", CreatedAt: &yesterday},
+		{Short: "s3", Original: "https://3.com", UserID: "u2"},
+	}))
+	require.NoError(t, fs.DeleteBatch(context.Background(), []URLRecord{{Short: "s2", UserID: "u1"}}))
+
+	daily, err := fs.GetDailyStats(context.Background(), yesterday, today.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, []DailyStats{
+		{Day: yesterday, Created: 2},
+		{Day: today, Deleted: 1},
+	}, daily)
+}
+
+func TestCountByUserID(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "count_test.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	require.NoError(t, fs.WriteAll(context.Background(), []URLRecord{
+		{Short: "s1", Original: "https://1.com", UserID: "u1"},
+		{Short: "s2", Original: "https://2.com", UserID: "u2"},
+	}))
+
+	count, err := fs.CountByUserID(context.Background(), "u1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestFileStorage_ContextCancelled(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "cancelled.json"), zap.NewNop())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	require.NoError(t, fs.WriteAll(context.Background(), []URLRecord{
+		{Short: "abc", Original: "https://a.example.com", UserID: "user-1"},
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = fs.Read(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = fs.FindByShort(ctx, "abc")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, fs.WriteAll(ctx, []URLRecord{{Short: "def", Original: "https://b.example.com"}}), context.Canceled)
+	assert.ErrorIs(t, fs.DeleteBatch(ctx, []URLRecord{{Short: "abc"}}), context.Canceled)
+
+	// Nothing was changed by the cancelled calls
+	records, err := fs.Read(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
@@ -0,0 +1,100 @@
+// Package storage provides helpers shared by the storage backends for
+// paginating, filtering and ordering URL record listings.
+package storage
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidListOptions is returned when ListOptions contain an unknown sort
+// field or negative pagination values.
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+// Supported sort fields for ListOptions.Sort.
+const (
+	SortByOriginal = "original_url"
+	SortByShort    = "short_url"
+)
+
+// SortField returns the field name and direction encoded in o.Sort.
+// An empty field means no explicit ordering was requested.
+func (o ListOptions) SortField() (field string, desc bool) {
+	return strings.TrimPrefix(o.Sort, "-"), strings.HasPrefix(o.Sort, "-")
+}
+
+// Terms returns the lower-cased search terms of o.Query, all of which a
+// record must contain to match.
+func (o ListOptions) Terms() []string {
+	return strings.Fields(strings.ToLower(o.Query))
+}
+
+// Validate checks that the options are well-formed.
+func (o ListOptions) Validate() error {
+	if o.Limit < 0 || o.Offset < 0 {
+		return ErrInvalidListOptions
+	}
+
+	switch field, _ := o.SortField(); field {
+	case "", SortByOriginal, SortByShort:
+		return nil
+	default:
+		return ErrInvalidListOptions
+	}
+}
+
+// Apply filters, orders and paginates records in memory according to o.
+// It is used by the backends that cannot push these operations down to a query engine.
+// The input slice is not modified.
+func (o ListOptions) Apply(records []URLRecord) []URLRecord {
+	res := make([]URLRecord, 0, len(records))
+
+	terms := o.Terms()
+	for _, r := range records {
+		if matchesTerms(r, terms) && (o.Tag == "" || r.HasTag(o.Tag)) && (o.Campaign == "" || r.Campaign == o.Campaign) {
+			res = append(res, r)
+		}
+	}
+
+	if field, desc := o.SortField(); field != "" {
+		key := func(r URLRecord) string {
+			if field == SortByShort {
+				return r.Short
+			}
+			return r.Original
+		}
+		sort.SliceStable(res, func(i, j int) bool {
+			if desc {
+				return key(res[i]) > key(res[j])
+			}
+			return key(res[i]) < key(res[j])
+		})
+	}
+	if o.PinnedFirst {
+		sort.SliceStable(res, func(i, j int) bool { return res[i].Pinned && !res[j].Pinned })
+	}
+
+	if o.Offset >= len(res) {
+		return res[:0]
+	}
+	res = res[o.Offset:]
+
+	if o.Limit > 0 && o.Limit < len(res) {
+		res = res[:o.Limit]
+	}
+
+	return res
+}
+
+// matchesTerms reports whether all terms occur in the original URL, the title
+// or the description of r, each term in any of them.
+func matchesTerms(r URLRecord, terms []string) bool {
+	original, title, description := strings.ToLower(r.Original), strings.ToLower(r.Title), strings.ToLower(r.Description)
+	for _, term := range terms {
+		if !strings.Contains(original, term) && !strings.Contains(title, term) && !strings.Contains(description, term) {
+			return false
+		}
+	}
+	return true
+}
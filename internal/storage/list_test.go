@@ -0,0 +1,58 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestListOptions_Apply(t *testing.T) {
+	records := []storage.URLRecord{
+		{Original: "https://b.com", Short: "s2", Tags: []string{"work"}, Campaign: "launch"},
+		{Original: "https://a.com/docs", Short: "s3", Tags: []string{"docs", "work"}},
+		{Original: "https://c.org", Short: "s1", Pinned: true, Title: "Quarterly Report", Description: "Numbers for the board"},
+	}
+
+	tests := []struct {
+		name string
+		opts storage.ListOptions
+		want []string
+	}{
+		{name: "zero value keeps order", opts: storage.ListOptions{}, want: []string{"s2", "s3", "s1"}},
+		{name: "sort by original", opts: storage.ListOptions{Sort: "original_url"}, want: []string{"s3", "s2", "s1"}},
+		{name: "sort by short desc", opts: storage.ListOptions{Sort: "-short_url"}, want: []string{"s3", "s2", "s1"}},
+		{name: "limit and offset", opts: storage.ListOptions{Sort: "short_url", Limit: 1, Offset: 1}, want: []string{"s2"}},
+		{name: "offset past end", opts: storage.ListOptions{Offset: 10}, want: []string{}},
+		{name: "query filter", opts: storage.ListOptions{Query: ".COM"}, want: []string{"s2", "s3"}},
+		{name: "all query terms must match", opts: storage.ListOptions{Query: " a.com  DOCS "}, want: []string{"s3"}},
+		{name: "query matches titles", opts: storage.ListOptions{Query: "report"}, want: []string{"s1"}},
+		{name: "query matches descriptions", opts: storage.ListOptions{Query: "BOARD"}, want: []string{"s1"}},
+		{name: "query terms may match different fields", opts: storage.ListOptions{Query: "c.org quarterly board"}, want: []string{"s1"}},
+		{name: "blank query matches all", opts: storage.ListOptions{Query: "  "}, want: []string{"s2", "s3", "s1"}},
+		{name: "tag filter", opts: storage.ListOptions{Tag: "work"}, want: []string{"s2", "s3"}},
+		{name: "tag and query filters", opts: storage.ListOptions{Tag: "work", Query: "docs"}, want: []string{"s3"}},
+		{name: "campaign filter", opts: storage.ListOptions{Campaign: "launch"}, want: []string{"s2"}},
+		{name: "pinned first", opts: storage.ListOptions{PinnedFirst: true}, want: []string{"s1", "s2", "s3"}},
+		{name: "pinned first then sort", opts: storage.ListOptions{PinnedFirst: true, Sort: "original_url"}, want: []string{"s1", "s3", "s2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, r := range tt.opts.Apply(records) {
+				got = append(got, r.Short)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.Equal(t, "s2", records[0].Short, "input slice must not be reordered")
+}
+
+func TestListOptions_Validate(t *testing.T) {
+	assert.NoError(t, storage.ListOptions{Sort: "-original_url", Limit: 10}.Validate())
+	assert.ErrorIs(t, storage.ListOptions{Sort: "user_id"}.Validate(), storage.ErrInvalidListOptions)
+	assert.ErrorIs(t, storage.ListOptions{Limit: -1}.Validate(), storage.ErrInvalidListOptions)
+}
@@ -8,106 +8,446 @@ package storage
 import (
 	"context"
 	"errors"
+	"hash/maphash"
+	"slices"
 	"sync"
+	"time"
+
+	"github.com/google/uuid"
 )
 
+// memoryShards is the number of shards of the records of a MemoryStorage,
+// a power of two so that a shard is picked by masking the hash of a short URL.
+const memoryShards = 32
+
 // MemoryStorage provides an in-memory store for URL records.
-// It maps short URLs to original URLs and maintains per-user URL records.
-// This implementation is concurrency-safe via sync.RWMutex.
+// It keeps every record once, keyed by its short URL, with indexes of the
+// records of each user and of the original URLs and IDs, so that all fields
+// round-trip whichever way a record is looked up. Like the database, it marks
+// deleted records instead of removing them.
+// The records are split into shards, each with its own sync.RWMutex, so that
+// lookups by short URL, which every redirect makes, only contend with changes
+// to records of the same shard. The indexes are guarded by another RWMutex,
+// which writes hold for the whole write so that conflicts are detected
+// atomically; it is always taken before a shard lock. Writes and scans of all
+// records return ctx.Err() if ctx is done before they change anything.
 type MemoryStorage struct {
-	stol  map[string]string      // Maps short URL to original URL
-	idtol map[string][]URLRecord // Maps user ID to their list of URLRecords
-	mu    sync.RWMutex           // Guards access to the maps
+	shards     [memoryShards]memoryShard
+	seed       maphash.Seed        // Seed of the hash picking the shard of a short URL
+	byUser     map[string][]string // Maps user ID to the short URLs of their records, oldest first
+	byOriginal map[string]string   // Maps original URL to its short URL
+	byID       map[string]string   // Maps record ID to its short URL
+	mu         sync.RWMutex        // Guards access to the indexes
+}
+
+// memoryShard holds the records of the short URLs hashing to it.
+type memoryShard struct {
+	records map[string]*URLRecord // Maps short URL to its record
+	mu      sync.RWMutex          // Guards access to records and their fields
 }
 
 // CreateMemoryStorage initializes and returns a new MemoryStorage instance.
 func CreateMemoryStorage() (*MemoryStorage, error) {
-	return &MemoryStorage{
-		stol:  make(map[string]string),
-		idtol: make(map[string][]URLRecord),
-		mu:    sync.RWMutex{},
-	}, nil
+	m := &MemoryStorage{
+		seed:       maphash.MakeSeed(),
+		byUser:     make(map[string][]string),
+		byOriginal: make(map[string]string),
+		byID:       make(map[string]string),
+	}
+	for i := range m.shards {
+		m.shards[i].records = make(map[string]*URLRecord)
+	}
+	return m, nil
+}
+
+// shard returns the shard of the short URL.
+func (m *MemoryStorage) shard(short string) *memoryShard {
+	return &m.shards[maphash.String(m.seed, short)&(memoryShards-1)]
+}
+
+// get returns a copy of the record of the short URL, if there is one.
+func (m *MemoryStorage) get(short string) (URLRecord, bool) {
+	sh := m.shard(short)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	r, exists := sh.records[short]
+	if !exists {
+		return URLRecord{}, false
+	}
+	return *r, true
+}
+
+// update calls fn with the record of the short URL under the lock of its
+// shard, returning ErrNotFound if there is none and the error of fn otherwise.
+func (m *MemoryStorage) update(short string, fn func(r *URLRecord) error) error {
+	sh := m.shard(short)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	r, exists := sh.records[short]
+	if !exists {
+		return ErrNotFound
+	}
+	return fn(r)
 }
 
-// Read returns all URL records in storage.
-// In the in-memory implementation, this always returns an empty slice.
+// Read returns all URL records in storage, in no particular order.
 func (m *MemoryStorage) Read(ctx context.Context) ([]URLRecord, error) {
-	return make([]URLRecord, 0), nil
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.all(), nil
 }
 
-// Write adds a new URLRecord to the memory storage.
-// If the short URL already exists for the user, an error is returned.
+// Write adds a new URLRecord to the memory storage, with a random ID if it
+// has none, as the database does. If the original URL is already stored, it
+// returns the existing record and ErrConflict, like the unique constraint of
+// the database; a short URL that is already taken returns ErrConflict alone.
 func (m *MemoryStorage) Write(ctx context.Context, record URLRecord) (*URLRecord, error) {
-	long := record.Original
-	short := record.Short
-
-	existingURLs := m.idtol[record.UserID]
-	if len(existingURLs) > 0 {
-		for _, url := range existingURLs {
-			if url.Short == record.Short {
-				return nil, errors.New("already exists")
-			}
-		}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
 	m.mu.Lock()
-	m.idtol[record.UserID] = append(m.idtol[record.UserID], record)
-	m.stol[short] = long
-	m.mu.Unlock()
+	defer m.mu.Unlock()
 
-	return &record, nil
+	if existing, err := m.conflict(record); err != nil {
+		return existing, err
+	}
+	return m.add(record), nil
 }
 
-// WriteAll writes multiple URLRecords in a batch.
-// It stops and returns an error if any individual record fails to write.
+// WriteAll writes multiple URLRecords in a batch. If any of them conflicts
+// with a stored record or another record of the batch, none is written and
+// ErrConflict is returned; if ctx is done before the batch is checked, none
+// is written and ctx.Err() is returned.
 func (m *MemoryStorage) WriteAll(ctx context.Context, records []URLRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	originals := make(map[string]struct{}, len(records))
+	shorts := make(map[string]struct{}, len(records))
 	for _, r := range records {
-		_, e := m.Write(ctx, r)
-		if e != nil {
-			return e
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := m.conflict(r); err != nil {
+			return err
+		}
+		_, dupOriginal := originals[r.Original]
+		_, dupShort := shorts[r.Short]
+		if dupOriginal || dupShort {
+			return ErrConflict
 		}
+		originals[r.Original] = struct{}{}
+		shorts[r.Short] = struct{}{}
+	}
+
+	for _, r := range records {
+		m.add(r)
 	}
 	return nil
 }
 
+// conflict returns the stored record of the original URL of r and ErrConflict
+// if there is one, and ErrConflict alone if the short URL of r is taken.
+// The caller must hold the write lock of the indexes.
+func (m *MemoryStorage) conflict(r URLRecord) (*URLRecord, error) {
+	if short, exists := m.byOriginal[r.Original]; exists {
+		existing, _ := m.get(short)
+		return &existing, ErrConflict
+	}
+	if _, exists := m.get(r.Short); exists {
+		return nil, ErrConflict
+	}
+	return nil, nil
+}
+
+// add stores a copy of r, with a random ID if it has none, and returns
+// another copy. The caller must hold the write lock of the indexes.
+func (m *MemoryStorage) add(r URLRecord) *URLRecord {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	stored := r
+	sh := m.shard(r.Short)
+	sh.mu.Lock()
+	sh.records[r.Short] = &stored
+	sh.mu.Unlock()
+
+	m.byUser[r.UserID] = append(m.byUser[r.UserID], r.Short)
+	m.byOriginal[r.Original] = r.Short
+	m.byID[r.ID] = r.Short
+	return &r
+}
+
+// all returns copies of all records. The caller must hold a lock of the
+// indexes, so that no record is added meanwhile.
+func (m *MemoryStorage) all() []URLRecord {
+	res := make([]URLRecord, 0, len(m.byOriginal))
+	for i := range m.shards {
+		sh := &m.shards[i]
+		sh.mu.RLock()
+		for _, r := range sh.records {
+			res = append(res, *r)
+		}
+		sh.mu.RUnlock()
+	}
+	return res
+}
+
 // FindByShort looks up a URLRecord by its short URL.
-// Returns an error if the short URL is not found.
+// Returns ErrNotFound if the short URL is not found.
 func (m *MemoryStorage) FindByShort(ctx context.Context, short string) (*URLRecord, error) {
-	if long, exists := m.stol[short]; exists {
-		return &URLRecord{
-			Short:    short,
-			Original: long,
-		}, nil
+	if r, exists := m.get(short); exists {
+		return &r, nil
 	}
-	return nil, errors.New("not found")
+	return nil, ErrNotFound
+}
+
+// FlagURL records that the original URL of the short URL was flagged as a threat.
+// Returns ErrNotFound if the short URL is not found.
+func (m *MemoryStorage) FlagURL(ctx context.Context, short string, reason string) error {
+	return m.update(short, func(r *URLRecord) error {
+		r.Flagged = reason
+		return nil
+	})
+}
+
+// SetPassword sets the password hash of the user's short URL; an empty hash
+// removes the protection. Returns ErrNotFound if the user does not own the
+// short URL and ErrDeleted if it was deleted.
+func (m *MemoryStorage) SetPassword(ctx context.Context, short string, userID string, hash string) error {
+	return m.update(short, func(r *URLRecord) error {
+		if r.UserID != userID {
+			return ErrNotFound
+		}
+		if r.IsDeleted {
+			return ErrDeleted
+		}
+		r.PasswordHash = hash
+		return nil
+	})
+}
+
+// SetTags replaces the tags of the user's short URL. Returns ErrNotFound if the
+// user does not own the short URL and ErrDeleted if it was deleted.
+func (m *MemoryStorage) SetTags(ctx context.Context, short string, userID string, tags []string) error {
+	return m.update(short, func(r *URLRecord) error {
+		if r.UserID != userID {
+			return ErrNotFound
+		}
+		if r.IsDeleted {
+			return ErrDeleted
+		}
+		r.Tags = slices.Clone(tags)
+		return nil
+	})
 }
 
-// DeleteBatch removes URL records from storage based on the given slice.
-// In this implementation, only the mapping for the first ID is removed from idtol.
+// SetPinned pins the user's short URL to the top of their listing or unpins it.
+// Returns ErrNotFound if the user does not own the short URL and ErrDeleted if
+// it was deleted.
+func (m *MemoryStorage) SetPinned(ctx context.Context, short string, userID string, pinned bool) error {
+	return m.update(short, func(r *URLRecord) error {
+		if r.UserID != userID {
+			return ErrNotFound
+		}
+		if r.IsDeleted {
+			return ErrDeleted
+		}
+		r.Pinned = pinned
+		return nil
+	})
+}
+
+// SetPrivate makes the metadata and stats of the user's short URL visible to
+// its owner only, or to anyone. Returns ErrNotFound if the user does not own
+// the short URL and ErrDeleted if it was deleted.
+func (m *MemoryStorage) SetPrivate(ctx context.Context, short string, userID string, private bool) error {
+	return m.update(short, func(r *URLRecord) error {
+		if r.UserID != userID {
+			return ErrNotFound
+		}
+		if r.IsDeleted {
+			return ErrDeleted
+		}
+		r.Private = private
+		return nil
+	})
+}
+
+// SetCampaign moves the user's short URL to the campaign with the given ID; an
+// empty ID removes it from its campaign. Returns ErrNotFound if the user does
+// not own the short URL and ErrDeleted if it was deleted.
+func (m *MemoryStorage) SetCampaign(ctx context.Context, short string, userID string, campaignID string) error {
+	return m.update(short, func(r *URLRecord) error {
+		if r.UserID != userID {
+			return ErrNotFound
+		}
+		if r.IsDeleted {
+			return ErrDeleted
+		}
+		r.Campaign = campaignID
+		return nil
+	})
+}
+
+// SetDetails sets the title and description of the user's short URL. Returns
+// ErrNotFound if the user does not own the short URL and ErrDeleted if it was
+// deleted.
+func (m *MemoryStorage) SetDetails(ctx context.Context, short string, userID string, title string, description string) error {
+	return m.update(short, func(r *URLRecord) error {
+		if r.UserID != userID {
+			return ErrNotFound
+		}
+		if r.IsDeleted {
+			return ErrDeleted
+		}
+		r.Title, r.Description = title, description
+		return nil
+	})
+}
+
+// SetDefaultTitle sets the title of the short URL unless it has one or was
+// deleted. Returns ErrNotFound if there is no such short URL.
+func (m *MemoryStorage) SetDefaultTitle(ctx context.Context, short string, title string) error {
+	return m.update(short, func(r *URLRecord) error {
+		if r.Title == "" && !r.IsDeleted {
+			r.Title = title
+		}
+		return nil
+	})
+}
+
+// DeleteBatch marks the URL records with the short URLs of the given slice as
+// deleted, keeping the time of the first deletion. Records are only deleted
+// for the user owning them; others are left unchanged.
 func (m *MemoryStorage) DeleteBatch(ctx context.Context, rs []URLRecord) error {
-	delete(m.idtol, rs[0].ID)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
 	for _, r := range rs {
-		delete(m.stol, r.Short)
+		_ = m.update(r.Short, func(stored *URLRecord) error {
+			if stored.UserID != r.UserID {
+				return nil
+			}
+			stored.IsDeleted = true
+			if stored.DeletedAt == nil {
+				stored.DeletedAt = &now
+			}
+			return nil
+		})
 	}
 	return nil
 }
 
+// FindExpired returns up to limit records that are not deleted and expired at
+// or before now; limit 0 returns all of them.
+func (m *MemoryStorage) FindExpired(ctx context.Context, now time.Time, limit int) ([]URLRecord, error) {
+	res := make([]URLRecord, 0)
+	for i := range m.shards {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		sh := &m.shards[i]
+		sh.mu.RLock()
+		for _, r := range sh.records {
+			if r.IsDeleted || !r.Expired(now) {
+				continue
+			}
+			res = append(res, *r)
+			if limit > 0 && len(res) == limit {
+				break
+			}
+		}
+		sh.mu.RUnlock()
+
+		if limit > 0 && len(res) == limit {
+			break
+		}
+	}
+	return res, nil
+}
+
 // PingContext checks the storage connection health.
 // For MemoryStorage, this returns an unsupported error.
 func (m *MemoryStorage) PingContext(c context.Context) error {
 	return errors.ErrUnsupported
 }
 
-// FindByUserID retrieves all URLRecords associated with a specific user ID.
-func (m *MemoryStorage) FindByUserID(ctx context.Context, id string) (*[]URLRecord, error) {
-	if items, exists := m.idtol[id]; exists {
-		return &items, nil
+// FindByUserID retrieves the URLRecords associated with a specific user ID,
+// filtered, ordered and paginated according to opts.
+func (m *MemoryStorage) FindByUserID(ctx context.Context, id string, opts ListOptions) (*[]URLRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	shorts, exists := m.byUser[id]
+	if !exists {
+		return nil, nil
 	}
-	return nil, nil
+	items := make([]URLRecord, 0, len(shorts))
+	for _, short := range shorts {
+		r, _ := m.get(short)
+		items = append(items, r)
+	}
+	res := opts.Apply(items)
+	return &res, nil
+}
+
+// CountByUserID returns the number of active URL records owned by the user.
+func (m *MemoryStorage) CountByUserID(ctx context.Context, id string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	count := 0
+	for _, short := range m.byUser[id] {
+		if r, _ := m.get(short); !r.IsDeleted {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetStats returns the number of active short URLs and of users owning them.
+func (m *MemoryStorage) GetStats(ctx context.Context) (int, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return activeStats(m.all())
+}
+
+// GetDailyStats returns the number of records created and deleted on each day
+// from from until to, in UTC, oldest first. Days without changes are omitted.
+func (m *MemoryStorage) GetDailyStats(ctx context.Context, from, to time.Time) ([]DailyStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	records := m.all()
+	m.mu.RUnlock()
+
+	return dailyStats(records, from, to), nil
 }
 
 // FindByID returns a URLRecord by its ID.
-// This method is not implemented and always returns an error.
+// Returns ErrNotFound if there is no record with the ID.
 func (m *MemoryStorage) FindByID(ctx context.Context, id string) (URLRecord, error) {
-	return URLRecord{}, errors.New("not found")
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if short, exists := m.byID[id]; exists {
+		r, _ := m.get(short)
+		return r, nil
+	}
+	return URLRecord{}, ErrNotFound
 }
@@ -0,0 +1,113 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// benchRecords is the number of records the benchmarks look up.
+const benchRecords = 10000
+
+// benchStore is the part of a storage the benchmarks use.
+type benchStore interface {
+	FindByShort(ctx context.Context, short string) (*storage.URLRecord, error)
+	FlagURL(ctx context.Context, short string, reason string) error
+}
+
+// lockedStore keeps records behind a single RWMutex, as MemoryStorage did
+// before its records were sharded, for comparison.
+type lockedStore struct {
+	records map[string]*storage.URLRecord
+	mu      sync.RWMutex
+}
+
+func (l *lockedStore) FindByShort(ctx context.Context, short string) (*storage.URLRecord, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if r, exists := l.records[short]; exists {
+		res := *r
+		return &res, nil
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (l *lockedStore) FlagURL(ctx context.Context, short string, reason string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r, exists := l.records[short]
+	if !exists {
+		return storage.ErrNotFound
+	}
+	r.Flagged = reason
+	return nil
+}
+
+func benchShorts() []string {
+	shorts := make([]string, benchRecords)
+	for i := range shorts {
+		shorts[i] = fmt.Sprintf("s%d", i)
+	}
+	return shorts
+}
+
+func newBenchStores(b *testing.B, shorts []string) map[string]benchStore {
+	mem, _ := storage.CreateMemoryStorage()
+	locked := &lockedStore{records: make(map[string]*storage.URLRecord, len(shorts))}
+	for i, short := range shorts {
+		r := storage.URLRecord{Short: short, Original: fmt.Sprintf("https://example.com/%d", i), UserID: "u1"}
+		if _, err := mem.Write(context.Background(), r); err != nil {
+			b.Fatal(err)
+		}
+		locked.records[short] = &r
+	}
+	return map[string]benchStore{"sharded": mem, "single-lock": locked}
+}
+
+// benchmarkParallel runs op from 1, 8 and 64 goroutines per CPU against both
+// stores, with i counting the calls of each goroutine.
+func benchmarkParallel(b *testing.B, op func(s benchStore, short string, i int)) {
+	shorts := benchShorts()
+	for name, s := range newBenchStores(b, shorts) {
+		for _, p := range []int{1, 8, 64} {
+			b.Run(fmt.Sprintf("%s/goroutines=%dxCPU", name, p), func(b *testing.B) {
+				var next atomic.Int64
+				b.SetParallelism(p)
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					i := int(next.Add(1)) * 7919
+					for pb.Next() {
+						op(s, shorts[i%len(shorts)], i)
+						i++
+					}
+				})
+			})
+		}
+	}
+}
+
+func BenchmarkMemoryStorage_FindByShort(b *testing.B) {
+	ctx := context.Background()
+	benchmarkParallel(b, func(s benchStore, short string, _ int) {
+		_, _ = s.FindByShort(ctx, short)
+	})
+}
+
+// BenchmarkMemoryStorage_Mixed looks up short URLs while one call in ten
+// changes a record, as flagging and deleting do under redirect load.
+func BenchmarkMemoryStorage_Mixed(b *testing.B) {
+	ctx := context.Background()
+	benchmarkParallel(b, func(s benchStore, short string, i int) {
+		if i%10 == 0 {
+			_ = s.FlagURL(ctx, short, "malware")
+			return
+		}
+		_, _ = s.FindByShort(ctx, short)
+	})
+}
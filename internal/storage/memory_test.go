@@ -3,9 +3,13 @@ package storage_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/atinyakov/go-url-shortener/internal/storage"
 )
@@ -23,10 +27,16 @@ func TestMemoryStorage_WriteAndFind(t *testing.T) {
 	result, err := mem.Write(context.Background(), record)
 	assert.NoError(t, err)
 	assert.Equal(t, record.Original, result.Original)
+	assert.NotEmpty(t, result.ID, "records without an ID get one")
+
+	// Write same original again - returns the existing record
+	existing, err := mem.Write(context.Background(), storage.URLRecord{Original: "https://example.com", Short: "xyz789", UserID: "user2"})
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	assert.Equal(t, result, existing)
 
 	// Write same short again - should fail
-	_, err = mem.Write(context.Background(), record)
-	assert.EqualError(t, err, "already exists")
+	_, err = mem.Write(context.Background(), storage.URLRecord{Original: "https://other.example.com", Short: "abc123", UserID: "user1"})
+	assert.ErrorIs(t, err, storage.ErrConflict)
 
 	// Find by short
 	found, err := mem.FindByShort(context.Background(), "abc123")
@@ -35,7 +45,7 @@ func TestMemoryStorage_WriteAndFind(t *testing.T) {
 
 	// Find non-existing short
 	_, err = mem.FindByShort(context.Background(), "notfound")
-	assert.EqualError(t, err, "not found")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
 }
 
 func TestMemoryStorage_WriteAll(t *testing.T) {
@@ -54,6 +64,21 @@ func TestMemoryStorage_WriteAll(t *testing.T) {
 	found2, _ := mem.FindByShort(context.Background(), "s2")
 	assert.Equal(t, "https://1.com", found1.Original)
 	assert.Equal(t, "https://2.com", found2.Original)
+
+	// A batch with a conflict is not written at all
+	err = mem.WriteAll(context.Background(), []storage.URLRecord{
+		{Original: "https://3.com", Short: "s3", UserID: "u1"},
+		{Original: "https://1.com", Short: "s4", UserID: "u1"},
+	})
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	_, err = mem.FindByShort(context.Background(), "s3")
+	assert.Error(t, err)
+
+	err = mem.WriteAll(context.Background(), []storage.URLRecord{
+		{Original: "https://3.com", Short: "s3", UserID: "u1"},
+		{Original: "https://3.com", Short: "s4", UserID: "u1"},
+	})
+	assert.ErrorIs(t, err, storage.ErrConflict)
 }
 
 func TestMemoryStorage_FindByUserID(t *testing.T) {
@@ -62,11 +87,11 @@ func TestMemoryStorage_FindByUserID(t *testing.T) {
 	mem.Write(context.Background(), storage.URLRecord{Short: "s1", Original: "https://a.com", UserID: "userX"})
 	mem.Write(context.Background(), storage.URLRecord{Short: "s2", Original: "https://b.com", UserID: "userX"})
 
-	records, err := mem.FindByUserID(context.Background(), "userX")
+	records, err := mem.FindByUserID(context.Background(), "userX", storage.ListOptions{})
 	assert.NoError(t, err)
 	assert.Len(t, *records, 2)
 
-	records, err = mem.FindByUserID(context.Background(), "unknown")
+	records, err = mem.FindByUserID(context.Background(), "unknown", storage.ListOptions{})
 	assert.NoError(t, err)
 	assert.Nil(t, records)
 }
@@ -82,11 +107,181 @@ func TestMemoryStorage_DeleteBatch(t *testing.T) {
 	}
 	mem.Write(context.Background(), record)
 
-	err := mem.DeleteBatch(context.Background(), []storage.URLRecord{record})
+	// Only the owner can delete the record
+	err := mem.DeleteBatch(context.Background(), []storage.URLRecord{{Short: "toDel", UserID: "user2"}})
+	assert.NoError(t, err)
+	found, err := mem.FindByShort(context.Background(), "toDel")
+	assert.NoError(t, err)
+	assert.False(t, found.IsDeleted)
+
+	err = mem.DeleteBatch(context.Background(), []storage.URLRecord{record})
+	assert.NoError(t, err)
+
+	// Deleted records are marked, not removed
+	found, err = mem.FindByShort(context.Background(), "toDel")
+	assert.NoError(t, err)
+	assert.True(t, found.IsDeleted)
+	assert.NotNil(t, found.DeletedAt)
+	assert.ErrorIs(t, mem.SetPassword(context.Background(), "toDel", "user1", "hash"), storage.ErrDeleted)
+
+	urls, users, err := mem.GetStats(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, urls)
+	assert.Zero(t, users)
+}
+
+func TestMemoryStorage_FlagURL(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+	_, err := mem.Write(context.Background(), storage.URLRecord{Original: "https://bad.com", Short: "bad", UserID: "user1"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, mem.FlagURL(context.Background(), "bad", "MALWARE"))
+	r, err := mem.FindByShort(context.Background(), "bad")
+	assert.NoError(t, err)
+	assert.Equal(t, "MALWARE", r.Flagged)
+	assert.Equal(t, "https://bad.com", r.Original)
+
+	assert.ErrorIs(t, mem.FlagURL(context.Background(), "missing", "MALWARE"), storage.ErrNotFound)
+}
+
+func TestMemoryStorage_SetPassword(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+	_, err := mem.Write(context.Background(), storage.URLRecord{Original: "https://example.com", Short: "abc", UserID: "user1"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, mem.SetPassword(context.Background(), "abc", "user1", "hash"))
+	r, err := mem.FindByShort(context.Background(), "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "hash", r.PasswordHash)
+
+	// Only the owner can protect the URL
+	assert.ErrorIs(t, mem.SetPassword(context.Background(), "abc", "user2", ""), storage.ErrNotFound)
+	assert.ErrorIs(t, mem.SetPassword(context.Background(), "missing", "user1", "hash"), storage.ErrNotFound)
+}
+
+func TestMemoryStorage_SetTags(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+	_, err := mem.Write(context.Background(), storage.URLRecord{Original: "https://example.com", Short: "abc", UserID: "user1"})
+	assert.NoError(t, err)
+	_, err = mem.Write(context.Background(), storage.URLRecord{Original: "https://example.org", Short: "def", UserID: "user1"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, mem.SetTags(context.Background(), "abc", "user1", []string{"work", "docs"}))
+	r, err := mem.FindByShort(context.Background(), "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"work", "docs"}, r.Tags)
+
+	// The listing is filtered by tag
+	records, err := mem.FindByUserID(context.Background(), "user1", storage.ListOptions{Tag: "docs"})
+	assert.NoError(t, err)
+	assert.Len(t, *records, 1)
+	assert.Equal(t, "abc", (*records)[0].Short)
+
+	// Only the owner can tag the URL
+	assert.ErrorIs(t, mem.SetTags(context.Background(), "abc", "user2", nil), storage.ErrNotFound)
+	assert.ErrorIs(t, mem.SetTags(context.Background(), "missing", "user1", nil), storage.ErrNotFound)
+}
+
+func TestMemoryStorage_SetPinned(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+	_, err := mem.Write(context.Background(), storage.URLRecord{Original: "https://example.com", Short: "abc", UserID: "user1"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, mem.SetPinned(context.Background(), "abc", "user1", true))
+	r, err := mem.FindByShort(context.Background(), "abc")
+	assert.NoError(t, err)
+	assert.True(t, r.Pinned)
+
+	assert.ErrorIs(t, mem.SetPinned(context.Background(), "abc", "user2", false), storage.ErrNotFound)
+	assert.NoError(t, mem.DeleteBatch(context.Background(), []storage.URLRecord{{Short: "abc", UserID: "user1"}}))
+	assert.ErrorIs(t, mem.SetPinned(context.Background(), "abc", "user1", false), storage.ErrDeleted)
+}
+
+func TestMemoryStorage_SetPrivate(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+	_, err := mem.Write(context.Background(), storage.URLRecord{Original: "https://example.com", Short: "abc", UserID: "user1"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, mem.SetPrivate(context.Background(), "abc", "user1", true))
+	r, err := mem.FindByShort(context.Background(), "abc")
+	assert.NoError(t, err)
+	assert.True(t, r.Private)
+
+	assert.ErrorIs(t, mem.SetPrivate(context.Background(), "abc", "user2", false), storage.ErrNotFound)
+	assert.NoError(t, mem.DeleteBatch(context.Background(), []storage.URLRecord{{Short: "abc", UserID: "user1"}}))
+	assert.ErrorIs(t, mem.SetPrivate(context.Background(), "abc", "user1", false), storage.ErrDeleted)
+}
+
+func TestMemoryStorage_SetCampaign(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+	_, err := mem.Write(context.Background(), storage.URLRecord{Original: "https://example.com", Short: "abc", UserID: "user1"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, mem.SetCampaign(context.Background(), "abc", "user1", "launch"))
+	records, err := mem.FindByUserID(context.Background(), "user1", storage.ListOptions{Campaign: "launch"})
+	assert.NoError(t, err)
+	assert.Len(t, *records, 1)
+
+	assert.NoError(t, mem.SetCampaign(context.Background(), "abc", "user1", ""))
+	records, err = mem.FindByUserID(context.Background(), "user1", storage.ListOptions{Campaign: "launch"})
+	assert.NoError(t, err)
+	assert.Empty(t, *records)
+
+	assert.ErrorIs(t, mem.SetCampaign(context.Background(), "abc", "user2", "launch"), storage.ErrNotFound)
+}
+
+func TestMemoryStorage_SetDetails(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+	_, err := mem.Write(context.Background(), storage.URLRecord{Original: "https://example.com", Short: "abc", UserID: "user1"})
+	assert.NoError(t, err)
+
+	// A fetched title does not replace the title of the owner
+	assert.NoError(t, mem.SetDefaultTitle(context.Background(), "abc", "Fetched"))
+	assert.NoError(t, mem.SetDetails(context.Background(), "abc", "user1", "Mine", "Notes"))
+	assert.NoError(t, mem.SetDefaultTitle(context.Background(), "abc", "Fetched again"))
+	r, err := mem.FindByShort(context.Background(), "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "Mine", r.Title)
+	assert.Equal(t, "Notes", r.Description)
+
+	assert.ErrorIs(t, mem.SetDetails(context.Background(), "abc", "user2", "", ""), storage.ErrNotFound)
+	assert.ErrorIs(t, mem.SetDefaultTitle(context.Background(), "missing", "Fetched"), storage.ErrNotFound)
+}
+
+func TestMemoryStorage_FindExpired(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	assert.NoError(t, mem.WriteAll(context.Background(), []storage.URLRecord{
+		{Original: "https://a.com", Short: "a", UserID: "user1", ExpiresAt: &past},
+		{Original: "https://b.com", Short: "b", UserID: "user1", ExpiresAt: &future},
+		{Original: "https://c.com", Short: "c", UserID: "user1"},
+		{Original: "https://d.com", Short: "d", UserID: "user2", ExpiresAt: &now},
+	}))
+
+	expired, err := mem.FindExpired(context.Background(), now, 0)
 	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "d"}, shorts(expired))
 
-	_, err = mem.FindByShort(context.Background(), "toDel")
-	assert.EqualError(t, err, "not found")
+	expired, err = mem.FindExpired(context.Background(), now, 1)
+	assert.NoError(t, err)
+	assert.Len(t, expired, 1)
+
+	// Deleted records are not returned again
+	assert.NoError(t, mem.DeleteBatch(context.Background(), []storage.URLRecord{{Short: "a", UserID: "user1"}}))
+	expired, err = mem.FindExpired(context.Background(), now, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d"}, shorts(expired))
+}
+
+// shorts returns the short URLs of the records.
+func shorts(rs []storage.URLRecord) []string {
+	res := make([]string, 0, len(rs))
+	for _, r := range rs {
+		res = append(res, r.Short)
+	}
+	return res
 }
 
 func TestMemoryStorage_PingContext(t *testing.T) {
@@ -98,7 +293,143 @@ func TestMemoryStorage_PingContext(t *testing.T) {
 
 func TestMemoryStorage_FindByID(t *testing.T) {
 	mem, _ := storage.CreateMemoryStorage()
+	expiresAt := time.Now().Add(time.Hour).UTC()
+	record := storage.URLRecord{ID: "id-1", Original: "https://a.com", Short: "s1", UserID: "u1", ExpiresAt: &expiresAt}
+	_, err := mem.Write(context.Background(), record)
+	assert.NoError(t, err)
+	assert.NoError(t, mem.FlagURL(context.Background(), "s1", "MALWARE"))
+
+	// All fields round-trip, including later updates, whichever way the record is looked up
+	record.Flagged = "MALWARE"
+	found, err := mem.FindByID(context.Background(), "id-1")
+	assert.NoError(t, err)
+	assert.Equal(t, record, found)
+	byUser, err := mem.FindByUserID(context.Background(), "u1", storage.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.URLRecord{record}, *byUser)
+	all, err := mem.Read(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.URLRecord{record}, all)
+
+	assert.NoError(t, mem.DeleteBatch(context.Background(), []storage.URLRecord{{Short: "s1", UserID: "u1"}}))
+	found, err = mem.FindByID(context.Background(), "id-1")
+	assert.NoError(t, err)
+	assert.True(t, found.IsDeleted)
+	count, err := mem.CountByUserID(context.Background(), "u1")
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+
+	_, err = mem.FindByID(context.Background(), "nonexistent")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestMemoryStorage_GetStats(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+
+	err := mem.WriteAll(context.Background(), []storage.URLRecord{
+		{Original: "https://1.com", Short: "s1", UserID: "u1"},
+		{Original: "https://2.com", Short: "s2", UserID: "u1"},
+		{Original: "https://3.com", Short: "s3", UserID: "u2"},
+	})
+	assert.NoError(t, err)
+
+	urls, users, err := mem.GetStats(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, urls)
+	assert.Equal(t, 2, users)
+}
+
+func TestMemoryStorage_GetDailyStats(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+	now := time.Now().UTC()
+	today := storage.Day(now)
+	yesterday := today.AddDate(0, 0, -1)
+	lastWeek := today.AddDate(0, 0, -7)
+
+	err := mem.WriteAll(context.Background(), []storage.URLRecord{
+		{Original: "https://1.com", Short: "s1", UserID: "u1", CreatedAt: &lastWeek},
+		{Original: "https://2.com", Short: "s2", UserID: "u1", CreatedAt: &yesterday},
+		{Original: "https://3.com", Short: "s3", UserID: "u1", CreatedAt: &now},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mem.DeleteBatch(context.Background(), []storage.URLRecord{{Short: "s1", UserID: "u1"}, {Short: "unknown", UserID: "u1"}}))
+
+	daily, err := mem.GetDailyStats(context.Background(), yesterday, today.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.DailyStats{
+		{Day: yesterday, Created: 1},
+		{Day: today, Created: 1, Deleted: 1},
+	}, daily)
+}
+
+func TestMemoryStorage_CountByUserID(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+
+	err := mem.WriteAll(context.Background(), []storage.URLRecord{
+		{Original: "https://1.com", Short: "s1", UserID: "u1"},
+		{Original: "https://2.com", Short: "s2", UserID: "u1"},
+	})
+	assert.NoError(t, err)
 
-	_, err := mem.FindByID(context.Background(), "nonexistent")
-	assert.EqualError(t, err, "not found")
+	count, err := mem.CountByUserID(context.Background(), "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = mem.CountByUserID(context.Background(), "unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestMemoryStorage_ContextCancelled(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := mem.Write(ctx, storage.URLRecord{Original: "https://a.com", Short: "s1", UserID: "u1"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, mem.WriteAll(ctx, []storage.URLRecord{{Original: "https://b.com", Short: "s2", UserID: "u1"}}), context.Canceled)
+	_, err = mem.FindByShort(context.Background(), "s2")
+	assert.ErrorIs(t, err, storage.ErrNotFound, "a cancelled batch is not written")
+
+	require.NoError(t, mem.WriteAll(context.Background(), []storage.URLRecord{{Original: "https://c.com", Short: "s3", UserID: "u1"}}))
+	assert.ErrorIs(t, mem.DeleteBatch(ctx, []storage.URLRecord{{Short: "s3", UserID: "u1"}}), context.Canceled)
+	_, err = mem.FindExpired(ctx, time.Now(), 0)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = mem.FindByShort(context.Background(), "s3")
+	assert.NoError(t, err)
+}
+
+func TestMemoryStorage_Concurrent(t *testing.T) {
+	mem, _ := storage.CreateMemoryStorage()
+	ctx := context.Background()
+
+	const writers, perWriter = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			user := fmt.Sprintf("u%d", w)
+			for i := 0; i < perWriter; i++ {
+				short := fmt.Sprintf("s%d-%d", w, i)
+				_, err := mem.Write(ctx, storage.URLRecord{Short: short, Original: "https://example.com/" + short, UserID: user})
+				assert.NoError(t, err)
+				_, err = mem.FindByShort(ctx, short)
+				assert.NoError(t, err)
+				if i%2 == 0 {
+					assert.NoError(t, mem.DeleteBatch(ctx, []storage.URLRecord{{Short: short, UserID: user}}))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	records, err := mem.Read(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, writers*perWriter)
+	for w := 0; w < writers; w++ {
+		count, err := mem.CountByUserID(ctx, fmt.Sprintf("u%d", w))
+		require.NoError(t, err)
+		assert.Equal(t, perWriter/2, count)
+	}
 }
@@ -3,13 +3,161 @@
 // including the original URL, shortened URL, associated user ID, and a deletion flag.
 package storage
 
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Errors returned by every storage, so that callers can tell the outcome of an
+// operation with errors.Is whatever the storage is.
+var (
+	// ErrNotFound is returned when looking up or updating a record that does
+	// not exist or is not owned by the given user.
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict is returned when writing a record whose original or short
+	// URL is already stored.
+	ErrConflict = errors.New("data conflict")
+
+	// ErrDeleted is returned when updating a record that was deleted.
+	ErrDeleted = errors.New("deleted")
+
+	// ErrUnavailable is returned, wrapped in an UnavailableError, when the
+	// storage is known to be down and calls fail fast instead of timing out.
+	ErrUnavailable = errors.New("storage unavailable")
+)
+
+// UnavailableError is returned while the storage is unavailable, with how long
+// callers should wait before trying again. It matches ErrUnavailable.
+type UnavailableError struct {
+	RetryAfter time.Duration
+}
+
+// Error returns the error message.
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrUnavailable, e.RetryAfter)
+}
+
+// Unwrap returns ErrUnavailable.
+func (e *UnavailableError) Unwrap() error {
+	return ErrUnavailable
+}
+
 // URLRecord represents a record for a shortened URL in the storage system.
 // It contains the original URL, the shortened URL, the user ID who created the record,
-// and a flag indicating whether the record is marked as deleted.
+// a flag indicating whether the record is marked as deleted, when it expires,
+// why it was disabled by the threat check, if it was, the hash of the
+// password protecting it, if any, the window in which it redirects and when it was created and deleted.
 type URLRecord struct {
-	ID        string `json:"uuid"`         // The unique identifier for the URL record
-	Original  string `json:"original_url"` // The original URL before shortening
-	Short     string `json:"short_url"`    // The shortened URL
-	UserID    string `json:"user_id"`      // The ID of the user who created the shortened URL
-	IsDeleted bool   `json:"is_deleted"`   // A flag indicating if the URL record is deleted
+	ID           string     `json:"uuid"`                    // The unique identifier for the URL record
+	Original     string     `json:"original_url"`            // The original URL before shortening
+	Short        string     `json:"short_url"`               // The shortened URL
+	UserID       string     `json:"user_id"`                 // The ID of the user who created the shortened URL
+	IsDeleted    bool       `json:"is_deleted"`              // A flag indicating if the URL record is deleted
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`    // When the record expires, nil if it never does
+	Flagged      string     `json:"flagged,omitempty"`       // Threat the original URL was flagged for, empty if none
+	PasswordHash string     `json:"password_hash,omitempty"` // Bcrypt hash of the password required to follow the short URL, empty if none
+	NotBefore    *time.Time `json:"not_before,omitempty"`    // When the short URL starts redirecting, nil if right away
+	NotAfter     *time.Time `json:"not_after,omitempty"`     // When the short URL stops redirecting, nil if never
+	CreatedAt    *time.Time `json:"created_at,omitempty"`    // When the record was created, nil for records predating the field
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`    // When the record was deleted, nil if it is not
+	Tags         []string   `json:"tags,omitempty"`          // Tags the owner attached to the record, in lower case
+	Title        string     `json:"title,omitempty"`         // Title set by the owner or fetched from the original URL, empty if none
+	Description  string     `json:"description,omitempty"`   // Notes of the owner on the record, empty if none
+	Pinned       bool       `json:"pinned,omitempty"`        // Whether the owner pinned the record to the top of their listing
+	Campaign     string     `json:"campaign_id,omitempty"`   // ID of the Campaign of the owner the record belongs to, empty if none
+	Private      bool       `json:"private,omitempty"`       // Whether the metadata and stats of the record are shown to its owner only
+}
+
+// Expired reports whether the record has an expiration time at or before now.
+func (r URLRecord) Expired(now time.Time) bool {
+	return r.ExpiresAt != nil && !r.ExpiresAt.After(now)
+}
+
+// NotYetActive reports whether the record has an activation time after now.
+func (r URLRecord) NotYetActive(now time.Time) bool {
+	return r.NotBefore != nil && r.NotBefore.After(now)
+}
+
+// NoLongerActive reports whether the record has a deactivation time at or before now.
+func (r URLRecord) NoLongerActive(now time.Time) bool {
+	return r.NotAfter != nil && !r.NotAfter.After(now)
+}
+
+// HasTag reports whether the record has the tag.
+func (r URLRecord) HasTag(tag string) bool {
+	return slices.Contains(r.Tags, tag)
+}
+
+// DailyStats is the number of records created and deleted on a day, in UTC.
+type DailyStats struct {
+	Day     time.Time // Midnight UTC starting the day
+	Created int       // Number of records created on the day
+	Deleted int       // Number of records deleted on the day
+}
+
+// ClickCount is the number of clicks of a short URL in a time window.
+type ClickCount struct {
+	Short  string // The shortened URL
+	Clicks int    // Number of redirects to the original URL
+}
+
+// BucketClicks is the number of clicks of a short URL to add to one ClickBucket.
+type BucketClicks struct {
+	Short  string    // The shortened URL
+	Bucket time.Time // Start of the bucket, in UTC
+	Clicks int       // Number of redirects to the original URL
+}
+
+// User represents a user account. Accounts are created when a user is first
+// issued a token and are referenced by URLRecord.UserID.
+type User struct {
+	ID          string    `json:"id"`           // The unique identifier of the user
+	DisplayName string    `json:"display_name"` // Optional human-readable name
+	CreatedAt   time.Time `json:"created_at"`   // When the user was created
+}
+
+// Campaign is a named group of short URLs of a user, whose clicks are
+// reported together. Records join it through URLRecord.Campaign.
+type Campaign struct {
+	ID        string    `json:"id"`         // The unique identifier of the campaign
+	UserID    string    `json:"user_id"`    // The ID of the user who created the campaign
+	Name      string    `json:"name"`       // Name of the campaign, unique among the campaigns of the user
+	CreatedAt time.Time `json:"created_at"` // When the campaign was created
+}
+
+// AuditEvent is an entry of the append-only audit log of mutating operations.
+type AuditEvent struct {
+	ID         int64     `json:"id"`          // Sequence number assigned by the store
+	Time       time.Time `json:"time"`        // When the request completed
+	UserID     string    `json:"user_id"`     // Who performed the operation
+	Action     string    `json:"action"`      // Method and route of the request, e.g. "POST /api/v1/shorten"
+	Targets    []string  `json:"targets"`     // Resources affected by the operation
+	Status     int       `json:"status"`      // HTTP status of the response
+	RemoteAddr string    `json:"remote_addr"` // IP address the request came from
+	RequestID  string    `json:"request_id"`  // ID correlating the event with request logs
+}
+
+// AuditFilter selects audit events. Zero fields do not restrict the result.
+type AuditFilter struct {
+	UserID string    // Only events performed by this user
+	Since  time.Time // Only events at or after this time
+	Until  time.Time // Only events before this time
+	Limit  int       // Maximum number of events to return, newest first
+}
+
+// ListOptions controls pagination, filtering and ordering of record listings.
+// The zero value returns every matching record in storage order.
+type ListOptions struct {
+	Limit  int    // Maximum number of records to return, 0 means no limit
+	Offset int    // Number of matching records to skip
+	Sort   string // Sort field ("original_url" or "short_url"), a leading "-" means descending
+	Query  string // Case-insensitive search of the original URL, title and description; every whitespace-separated term must occur in one of them
+	Tag    string // Only return the records with this tag, empty returns all of them
+	// PinnedFirst lists the pinned records before the others, each group in Sort order
+	PinnedFirst bool
+	// Campaign only returns the records of this campaign ID, empty returns all of them
+	Campaign string
 }
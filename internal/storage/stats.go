@@ -0,0 +1,62 @@
+// Package storage provides helpers shared by the storage backends that
+// compute statistics in memory.
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// Day returns the midnight UTC starting the day of t.
+func Day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// activeStats returns the number of records that are not deleted and of
+// distinct users owning them.
+func activeStats(records []URLRecord) (int, int, error) {
+	urls := 0
+	users := make(map[string]struct{})
+	for _, r := range records {
+		if r.IsDeleted {
+			continue
+		}
+		urls++
+		users[r.UserID] = struct{}{}
+	}
+	return urls, len(users), nil
+}
+
+// dailyStats counts the records created and deleted from from until to by
+// the day of their creation and deletion times, oldest day first. Days without changes are omitted; from and to are expected at midnight UTC.
+func dailyStats(records []URLRecord, from, to time.Time) []DailyStats {
+	byDay := make(map[time.Time]*DailyStats)
+	get := func(day time.Time) *DailyStats {
+		d, ok := byDay[day]
+		if !ok {
+			d = &DailyStats{Day: day}
+			byDay[day] = d
+		}
+		return d
+	}
+
+	inRange := func(t time.Time) bool {
+		return !t.Before(from) && t.Before(to)
+	}
+	for _, r := range records {
+		if r.CreatedAt != nil && inRange(*r.CreatedAt) {
+			get(Day(*r.CreatedAt)).Created++
+		}
+		if r.IsDeleted && r.DeletedAt != nil && inRange(*r.DeletedAt) {
+			get(Day(*r.DeletedAt)).Deleted++
+		}
+	}
+
+	res := make([]DailyStats, 0, len(byDay))
+	for _, d := range byDay {
+		res = append(res, *d)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Day.Before(res[j].Day) })
+	return res
+}
@@ -0,0 +1,59 @@
+// Package storage provides an in-memory store of user accounts, used when
+// the service runs without a database.
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryUserStorage keeps user accounts in memory, keyed by user ID.
+// It is concurrency-safe via sync.RWMutex.
+type MemoryUserStorage struct {
+	users map[string]User // Maps user ID to the account
+	mu    sync.RWMutex    // Guards access to the map
+}
+
+// NewMemoryUserStorage initializes and returns a new MemoryUserStorage instance.
+func NewMemoryUserStorage() *MemoryUserStorage {
+	return &MemoryUserStorage{
+		users: make(map[string]User),
+	}
+}
+
+// CreateUser stores a new user. Creating a user that already exists is a no-op.
+func (m *MemoryUserStorage) CreateUser(ctx context.Context, u User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.users[u.ID]; !exists {
+		m.users[u.ID] = u
+	}
+	return nil
+}
+
+// FindUser retrieves a user by ID. Returns ErrNotFound if there is no such user.
+func (m *MemoryUserStorage) FindUser(ctx context.Context, id string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if u, exists := m.users[id]; exists {
+		return &u, nil
+	}
+	return nil, ErrNotFound
+}
+
+// UpdateDisplayName sets the display name of a user, creating the user if it does not exist yet.
+func (m *MemoryUserStorage) UpdateDisplayName(ctx context.Context, id string, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, exists := m.users[id]
+	if !exists {
+		u = User{ID: id, CreatedAt: time.Now().UTC()}
+	}
+	u.DisplayName = name
+	m.users[id] = u
+	return nil
+}
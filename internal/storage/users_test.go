@@ -0,0 +1,45 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+func TestMemoryUserStorage(t *testing.T) {
+	users := storage.NewMemoryUserStorage()
+	ctx := context.Background()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Create
+	assert.NoError(t, users.CreateUser(ctx, storage.User{ID: "u1", CreatedAt: createdAt}))
+
+	// Creating again keeps the original account
+	assert.NoError(t, users.CreateUser(ctx, storage.User{ID: "u1", CreatedAt: time.Now()}))
+
+	found, err := users.FindUser(ctx, "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, storage.User{ID: "u1", CreatedAt: createdAt}, *found)
+
+	// Update display name
+	assert.NoError(t, users.UpdateDisplayName(ctx, "u1", "Alice"))
+	found, err = users.FindUser(ctx, "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice", found.DisplayName)
+	assert.Equal(t, createdAt, found.CreatedAt)
+
+	// Updating an unknown user creates it
+	assert.NoError(t, users.UpdateDisplayName(ctx, "u2", "Bob"))
+	found, err = users.FindUser(ctx, "u2")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bob", found.DisplayName)
+	assert.False(t, found.CreatedAt.IsZero())
+
+	// Find non-existing user
+	_, err = users.FindUser(ctx, "unknown")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
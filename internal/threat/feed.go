@@ -0,0 +1,98 @@
+package threat
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Feed is a Checker over a local threat feed. Entries are either domains,
+// matching the domain and all its subdomains, or URLs, matching URLs that
+// start with them.
+type Feed struct {
+	domains map[string]string // Threat by domain
+	urls    map[string]string // Threat by URL prefix
+}
+
+// LoadFeed reads a threat feed file, see ParseFeed for the format.
+func LoadFeed(path string) (*Feed, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseFeed(file)
+}
+
+// ParseFeed reads a threat feed with one entry per line: a domain or an
+// absolute URL, optionally followed by the threat type, which defaults to
+// MALWARE. Empty lines and lines starting with # are skipped.
+func ParseFeed(r io.Reader) (*Feed, error) {
+	f := &Feed{domains: make(map[string]string), urls: make(map[string]string)}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("threat feed line %d: want an entry and an optional threat type", n)
+		}
+		threat := Malware
+		if len(fields) == 2 {
+			threat = strings.ToUpper(fields[1])
+		}
+
+		if strings.Contains(fields[0], "://") {
+			f.urls[fields[0]] = threat
+		} else {
+			f.domains[normalizeDomain(fields[0])] = threat
+		}
+	}
+	return f, scanner.Err()
+}
+
+// Len returns the number of entries in the feed.
+func (f *Feed) Len() int {
+	return len(f.domains) + len(f.urls)
+}
+
+// Check implements Checker.
+func (f *Feed) Check(ctx context.Context, rawURL string) (string, error) {
+	for prefix, threat := range f.urls {
+		if strings.HasPrefix(rawURL, prefix) {
+			return threat, nil
+		}
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	// Try the host, then every parent domain
+	for d := normalizeDomain(u.Hostname()); d != ""; {
+		if threat, ok := f.domains[d]; ok {
+			return threat, nil
+		}
+		i := strings.IndexByte(d, '.')
+		if i < 0 {
+			break
+		}
+		d = d[i+1:]
+	}
+	return "", nil
+}
+
+// normalizeDomain lower-cases d and strips a trailing dot.
+func normalizeDomain(d string) string {
+	return strings.TrimSuffix(strings.ToLower(d), ".")
+}
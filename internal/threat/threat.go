@@ -0,0 +1,44 @@
+// Package threat provides checks of URLs against threat intelligence sources,
+// such as Google Safe Browsing or a local feed of malicious domains, used to
+// disable short URLs pointing to malware or phishing pages.
+package threat
+
+import (
+	"context"
+	"errors"
+)
+
+// Threat types reported by the checkers, following the Safe Browsing names.
+const (
+	Malware           = "MALWARE"
+	SocialEngineering = "SOCIAL_ENGINEERING"
+	UnwantedSoftware  = "UNWANTED_SOFTWARE"
+)
+
+// Checker looks up a URL in a threat intelligence source.
+type Checker interface {
+	// Check returns the threat the URL is known for, or an empty string if it
+	// is not known to be malicious.
+	Check(ctx context.Context, url string) (string, error)
+}
+
+// Chain is a Checker asking every checker in turn and returning the first
+// threat found. A failing checker does not prevent the others from finding a
+// threat; its error is only returned if none does.
+type Chain []Checker
+
+// Check implements Checker.
+func (c Chain) Check(ctx context.Context, url string) (string, error) {
+	var errs []error
+	for _, checker := range c {
+		threat, err := checker.Check(ctx, url)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if threat != "" {
+			return threat, nil
+		}
+	}
+	return "", errors.Join(errs...)
+}
@@ -0,0 +1,112 @@
+package threat_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/threat"
+)
+
+func TestFeed(t *testing.T) {
+	feed, err := threat.ParseFeed(strings.NewReader(`
+# known bad
+evil.com
+Phish.example. social_engineering
+https://files.example.org/payload UNWANTED_SOFTWARE
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 3, feed.Len())
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://evil.com/x", threat.Malware},
+		{"https://cdn.evil.com", threat.Malware},
+		{"https://notevil.com", ""},
+		{"http://PHISH.example/login", threat.SocialEngineering},
+		{"https://files.example.org/payload.exe", threat.UnwantedSoftware},
+		{"https://files.example.org/other", ""},
+	}
+	for _, tt := range tests {
+		got, err := feed.Check(context.Background(), tt.url)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, tt.url)
+	}
+}
+
+func TestParseFeed_Invalid(t *testing.T) {
+	_, err := threat.ParseFeed(strings.NewReader("evil.com MALWARE extra"))
+	assert.Error(t, err)
+}
+
+func TestSafeBrowsing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.URL.Query().Get("key"))
+
+		var body struct {
+			ThreatInfo struct {
+				ThreatEntries []struct {
+					URL string `json:"url"`
+				} `json:"threatEntries"`
+			} `json:"threatInfo"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body.ThreatInfo.ThreatEntries[0].URL == "https://evil.com" {
+			w.Write([]byte(`{"matches":[{"threatType":"SOCIAL_ENGINEERING"}]}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	sb := threat.NewSafeBrowsing("secret", srv.URL)
+
+	got, err := sb.Check(context.Background(), "https://evil.com")
+	require.NoError(t, err)
+	assert.Equal(t, threat.SocialEngineering, got)
+
+	got, err = sb.Check(context.Background(), "https://example.com")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestSafeBrowsing_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "API key not valid", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	_, err := threat.NewSafeBrowsing("bad", srv.URL).Check(context.Background(), "https://example.com")
+	assert.ErrorContains(t, err, "API key not valid")
+}
+
+// staticChecker is a Checker returning fixed results.
+type staticChecker struct {
+	threat string
+	err    error
+}
+
+func (c staticChecker) Check(context.Context, string) (string, error) {
+	return c.threat, c.err
+}
+
+func TestChain(t *testing.T) {
+	unavailable := errors.New("unavailable")
+
+	got, err := threat.Chain{staticChecker{err: unavailable}, staticChecker{threat: threat.Malware}}.Check(context.Background(), "u")
+	require.NoError(t, err)
+	assert.Equal(t, threat.Malware, got)
+
+	got, err = threat.Chain{staticChecker{}, staticChecker{err: unavailable}}.Check(context.Background(), "u")
+	assert.ErrorIs(t, err, unavailable)
+	assert.Empty(t, got)
+}
@@ -0,0 +1,229 @@
+// Package tracing provides the tracer batching finished spans and exporting
+// them to an OpenTelemetry collector over OTLP/HTTP with JSON encoding.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// flushInterval is how often queued spans are exported.
+	flushInterval = 5 * time.Second
+	// batchSize is the number of queued spans that triggers an early export.
+	batchSize = 512
+	// maxQueueSize bounds memory use while the collector is unavailable; newer spans are dropped.
+	maxQueueSize = 4096
+	// exportTimeout bounds a single export request.
+	exportTimeout = 10 * time.Second
+)
+
+// Tracer collects finished spans and exports them in batches from a
+// background goroutine, so instrumented code never waits for the collector.
+type Tracer struct {
+	endpoint string
+	service  string
+	client   *http.Client
+
+	mu    sync.Mutex
+	queue []SpanData
+
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewOTLPTracer creates a tracer exporting spans of the named service to the
+// OTLP/HTTP collector at endpoint, for example "http://localhost:4318".
+// Spans are posted to the standard /v1/traces path.
+func NewOTLPTracer(endpoint string, service string) *Tracer {
+	t := &Tracer{
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service:  service,
+		client:   &http.Client{Timeout: exportTimeout},
+		flush:    make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.loop()
+	return t
+}
+
+// enqueue adds a finished span to the export queue.
+func (t *Tracer) enqueue(s SpanData) {
+	t.mu.Lock()
+	if len(t.queue) < maxQueueSize {
+		t.queue = append(t.queue, s)
+	}
+	full := len(t.queue) >= batchSize
+	t.mu.Unlock()
+
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// loop exports queued spans periodically and when a batch is full.
+func (t *Tracer) loop() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.flush:
+		case <-t.stop:
+			return
+		}
+		_ = t.export(context.Background())
+	}
+}
+
+// export sends every queued span to the collector.
+func (t *Tracer) export(ctx context.Context) error {
+	t.mu.Lock()
+	spans := t.queue
+	t.queue = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(t.encode(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("tracing: collector responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Shutdown stops the background export and sends the remaining spans.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	select {
+	case <-t.stop:
+	default:
+		close(t.stop)
+	}
+
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return t.export(ctx)
+}
+
+// OTLP JSON payload, trimmed to the fields the tracer fills in.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              Kind            `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// OTLP status codes.
+const (
+	statusOK    = 1
+	statusError = 2
+)
+
+// encode converts spans into an OTLP export request.
+func (t *Tracer) encode(spans []SpanData) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.SpanID[:]),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Status:            otlpStatus{Code: statusOK},
+		}
+		if s.ParentID != (SpanID{}) {
+			span.ParentSpanID = hex.EncodeToString(s.ParentID[:])
+		}
+		for _, a := range s.Attributes {
+			span.Attributes = append(span.Attributes, otlpAttribute{Key: a.Key, Value: otlpValue{StringValue: a.Value}})
+		}
+		if s.Err != "" {
+			span.Status = otlpStatus{Code: statusError, Message: s.Err}
+		}
+		out = append(out, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			{Key: "service.name", Value: otlpValue{StringValue: t.service}},
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/atinyakov/go-url-shortener"},
+			Spans: out,
+		}},
+	}}}
+}
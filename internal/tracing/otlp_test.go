@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTLPTracerExportsOnShutdown(t *testing.T) {
+	received := make(chan otlpRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var req otlpRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		received <- req
+	}))
+	defer collector.Close()
+
+	tr := NewOTLPTracer(collector.URL+"/", "test-service")
+	SetTracer(tr)
+	defer SetTracer(nil)
+
+	ctx, parent := Start(context.Background(), "GET /{url}", KindServer)
+	_, child := Start(ctx, "URLService.GetURLByShort", KindInternal)
+	child.RecordError(errors.New("not found"))
+	child.End()
+	parent.End()
+
+	require.NoError(t, tr.Shutdown(context.Background()))
+
+	req := <-received
+	require.Len(t, req.ResourceSpans, 1)
+	assert.Equal(t, "service.name", req.ResourceSpans[0].Resource.Attributes[0].Key)
+	assert.Equal(t, "test-service", req.ResourceSpans[0].Resource.Attributes[0].Value.StringValue)
+
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+	assert.Equal(t, "URLService.GetURLByShort", spans[0].Name)
+	assert.Equal(t, otlpStatus{Code: statusError, Message: "not found"}, spans[0].Status)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, spans[1].TraceID, spans[0].TraceID)
+	assert.Len(t, spans[0].TraceID, 32)
+	assert.Equal(t, KindServer, spans[1].Kind)
+	assert.Empty(t, spans[1].ParentSpanID)
+	assert.Equal(t, otlpStatus{Code: statusOK}, spans[1].Status)
+}
+
+func TestOTLPTracerCollectorError(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	tr := NewOTLPTracer(collector.URL, "test-service")
+	tr.enqueue(SpanData{Name: "op"})
+
+	assert.Error(t, tr.Shutdown(context.Background()))
+}
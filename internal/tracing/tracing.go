@@ -0,0 +1,187 @@
+// Package tracing provides lightweight distributed tracing: spans carried in
+// the request context, W3C trace context propagation and export to an
+// OpenTelemetry collector over OTLP/HTTP. Tracing is disabled until a tracer
+// is installed with SetTracer, in which case starting spans costs nothing.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kind describes the relationship of a span to its parent, using the OTLP values.
+type Kind int
+
+// Span kinds.
+const (
+	KindInternal Kind = 1 // An operation inside the application
+	KindServer   Kind = 2 // Handling of an incoming request
+	KindClient   Kind = 3 // An outgoing call, such as a database query
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// Attribute is a key-value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// SpanData is a finished span, as handed to the exporter.
+type SpanData struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	ParentID   SpanID
+	Name       string
+	Kind       Kind
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	Err        string
+}
+
+// Span is an operation in progress. A nil *Span is valid and does nothing, so
+// instrumented code does not have to check whether tracing is enabled.
+type Span struct {
+	tracer *Tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// spanContext identifies the parent of new spans.
+type spanContext struct {
+	traceID TraceID
+	spanID  SpanID
+}
+
+// contextKey is the type of the context key holding the current span.
+type contextKey struct{}
+
+// current is the installed tracer, nil when tracing is disabled.
+var current atomic.Pointer[Tracer]
+
+// SetTracer installs t as the tracer used by Start; nil disables tracing.
+func SetTracer(t *Tracer) {
+	current.Store(t)
+}
+
+// Start starts a span as a child of the span or remote parent in ctx and
+// returns a context carrying the new span. The span must be ended with End.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	t := current.Load()
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{tracer: t, data: SpanData{Name: name, Kind: kind, Start: time.Now()}}
+	if parent, ok := ctx.Value(contextKey{}).(spanContext); ok {
+		s.data.TraceID = parent.traceID
+		s.data.ParentID = parent.spanID
+	} else {
+		_, _ = rand.Read(s.data.TraceID[:])
+	}
+	_, _ = rand.Read(s.data.SpanID[:])
+
+	return context.WithValue(ctx, contextKey{}, spanContext{traceID: s.data.TraceID, spanID: s.data.SpanID}), s
+}
+
+// SetName replaces the name of the span, for names only known once the operation ran.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Name = name
+	s.mu.Unlock()
+}
+
+// SetAttribute adds a key-value pair describing the span.
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Attributes = append(s.data.Attributes, Attribute{Key: key, Value: fmt.Sprint(value)})
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed with err; a nil error is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Err = err.Error()
+	s.mu.Unlock()
+}
+
+// TraceID returns the hex-encoded ID of the trace the span belongs to.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.data.TraceID[:])
+}
+
+// End finishes the span and queues it for export. Calls after the first are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.enqueue(data)
+}
+
+// ContextWithTraceParent returns ctx with the remote parent described by a W3C
+// traceparent header ("00-<trace-id>-<parent-id>-<flags>"), so spans started
+// from it join the trace of the caller. Invalid headers are ignored.
+func ContextWithTraceParent(ctx context.Context, header string) context.Context {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return ctx
+	}
+
+	var sc spanContext
+	if n, err := hex.Decode(sc.traceID[:], []byte(parts[1])); err != nil || n != len(sc.traceID) || len(parts[1]) != 2*len(sc.traceID) {
+		return ctx
+	}
+	if n, err := hex.Decode(sc.spanID[:], []byte(parts[2])); err != nil || n != len(sc.spanID) || len(parts[2]) != 2*len(sc.spanID) {
+		return ctx
+	}
+	if sc.traceID == (TraceID{}) || sc.spanID == (SpanID{}) {
+		return ctx
+	}
+
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// TraceParent returns the W3C traceparent header value for the span in ctx,
+// or an empty string if there is none.
+func TraceParent(ctx context.Context) string {
+	sc, ok := ctx.Value(contextKey{}).(spanContext)
+	if !ok {
+		return ""
+	}
+	return "00-" + hex.EncodeToString(sc.traceID[:]) + "-" + hex.EncodeToString(sc.spanID[:]) + "-01"
+}
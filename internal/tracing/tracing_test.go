@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTracer installs a tracer that never exports, so tests can inspect the queue.
+func recordingTracer(t *testing.T) *Tracer {
+	t.Helper()
+
+	tr := &Tracer{flush: make(chan struct{}, 1)}
+	SetTracer(tr)
+	t.Cleanup(func() { SetTracer(nil) })
+	return tr
+}
+
+func TestStartDisabled(t *testing.T) {
+	ctx := context.Background()
+	got, span := Start(ctx, "op", KindInternal)
+
+	assert.Nil(t, span)
+	assert.Equal(t, ctx, got)
+
+	// A nil span is safe to use
+	span.SetName("other")
+	span.SetAttribute("key", "value")
+	span.RecordError(errors.New("boom"))
+	span.End()
+	assert.Empty(t, span.TraceID())
+}
+
+func TestStartChildSpans(t *testing.T) {
+	tr := recordingTracer(t)
+
+	ctx, parent := Start(context.Background(), "parent", KindServer)
+	_, child := Start(ctx, "child", KindClient)
+	child.SetAttribute("db.operation", "SELECT url_records")
+	child.RecordError(errors.New("boom"))
+	child.End()
+	child.End()
+	parent.End()
+
+	require.Len(t, tr.queue, 2)
+	c, p := tr.queue[0], tr.queue[1]
+
+	assert.Equal(t, p.TraceID, c.TraceID)
+	assert.Equal(t, p.SpanID, c.ParentID)
+	assert.Equal(t, SpanID{}, p.ParentID)
+	assert.Equal(t, "child", c.Name)
+	assert.Equal(t, KindClient, c.Kind)
+	assert.Equal(t, []Attribute{{Key: "db.operation", Value: "SELECT url_records"}}, c.Attributes)
+	assert.Equal(t, "boom", c.Err)
+	assert.False(t, c.End.Before(c.Start))
+}
+
+func TestTraceParent(t *testing.T) {
+	recordingTracer(t)
+
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := ContextWithTraceParent(context.Background(), header)
+	assert.Equal(t, header, TraceParent(ctx))
+
+	ctx, span := Start(ctx, "op", KindServer)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID())
+	assert.Equal(t, "00f067aa0ba902b7", hex.EncodeToString(span.data.ParentID[:]))
+	assert.Contains(t, TraceParent(ctx), "00-4bf92f3577b34da6a3ce929d0e0e4736-")
+
+	for _, invalid := range []string{
+		"",
+		"garbage",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6-00f067aa0ba902b7-01",
+		"00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		assert.Empty(t, TraceParent(ContextWithTraceParent(context.Background(), invalid)), invalid)
+	}
+}
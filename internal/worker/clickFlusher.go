@@ -0,0 +1,225 @@
+package worker
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// Default click flushing settings.
+const (
+	DefaultClickFlushInterval = 5 * time.Second
+	// DefaultClickBatchSize is the number of distinct short URLs and buckets
+	// after which the buffered clicks are flushed early.
+	DefaultClickBatchSize = 1000
+)
+
+// ClickRepo stores aggregated click counts.
+type ClickRepo interface {
+	RecordClicks(context.Context, []storage.BucketClicks) error
+}
+
+// ClickRepoFunc adapts a function to the ClickRepo interface.
+type ClickRepoFunc func(context.Context, []storage.BucketClicks) error
+
+// RecordClicks calls f.
+func (f ClickRepoFunc) RecordClicks(ctx context.Context, clicks []storage.BucketClicks) error {
+	return f(ctx, clicks)
+}
+
+// clickKey identifies the clicks of a short URL in one bucket.
+type clickKey struct {
+	short  string
+	bucket time.Time
+}
+
+// ClickFlusher buffers the clicks of short URLs in memory and writes them to
+// the repository aggregated by short URL and bucket, every flush interval or
+// once DefaultClickBatchSize keys are buffered, so that redirects never wait
+// for the storage. Clicks of a failed flush are kept for the next one. It
+// implements Job.
+type ClickFlusher struct {
+	logger   *zap.Logger
+	repo     ClickRepo
+	interval atomic.Int64 // Flush interval in nanoseconds
+	counters counters     // Runs count flushes
+
+	mu      sync.Mutex       // Guards pending
+	pending map[clickKey]int // Buffered clicks not flushed yet
+	flushMu sync.Mutex       // Serializes flushes
+
+	full     chan struct{} // Signals that the batch size was reached
+	stop     chan struct{} // Closed by Stop
+	stopOnce sync.Once
+	done     chan struct{} // Closed when Run returns
+	doneOnce sync.Once
+}
+
+// NewClickFlusher creates a ClickFlusher writing to repo every interval. A
+// non-positive interval falls back to DefaultClickFlushInterval.
+func NewClickFlusher(logger *zap.Logger, repo ClickRepo, interval time.Duration) *ClickFlusher {
+	f := &ClickFlusher{
+		logger:  logger,
+		repo:    repo,
+		pending: make(map[clickKey]int),
+		full:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	f.SetInterval(interval)
+	return f
+}
+
+// SetInterval changes the flush interval, from the next flush on. A
+// non-positive interval falls back to DefaultClickFlushInterval.
+func (f *ClickFlusher) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultClickFlushInterval
+	}
+	f.interval.Store(int64(interval))
+}
+
+// Add buffers a click of the short URL at the given time.
+func (f *ClickFlusher) Add(short string, at time.Time) {
+	key := clickKey{short: short, bucket: at.UTC().Truncate(storage.ClickBucket)}
+
+	f.mu.Lock()
+	f.pending[key]++
+	full := len(f.pending) >= DefaultClickBatchSize
+	f.mu.Unlock()
+
+	if full {
+		select {
+		case f.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Pending returns the number of buffered clicks.
+func (f *ClickFlusher) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, clicks := range f.pending {
+		n += clicks
+	}
+	return n
+}
+
+// Flush writes the buffered clicks to the repository. If the write fails, the
+// clicks are buffered again and the error is returned.
+func (f *ClickFlusher) Flush(ctx context.Context) error {
+	f.flushMu.Lock()
+	defer f.flushMu.Unlock()
+
+	f.mu.Lock()
+	pending := f.pending
+	f.pending = make(map[clickKey]int, len(pending))
+	f.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	clicks := make([]storage.BucketClicks, 0, len(pending))
+	for key, n := range pending {
+		clicks = append(clicks, storage.BucketClicks{Short: key.short, Bucket: key.bucket, Clicks: n})
+	}
+	// A stable order keeps concurrent upserts of instances from deadlocking
+	sort.Slice(clicks, func(i, j int) bool {
+		if clicks[i].Short != clicks[j].Short {
+			return clicks[i].Short < clicks[j].Short
+		}
+		return clicks[i].Bucket.Before(clicks[j].Bucket)
+	})
+
+	for len(clicks) > 0 {
+		n := min(len(clicks), DefaultClickBatchSize)
+		if err := f.repo.RecordClicks(ctx, clicks[:n]); err != nil {
+			f.counters.failures.Add(1)
+			f.requeue(clicks)
+			return err
+		}
+		clicks = clicks[n:]
+	}
+	f.counters.runs.Add(1)
+	return nil
+}
+
+// requeue buffers the clicks of a failed flush again.
+func (f *ClickFlusher) requeue(clicks []storage.BucketClicks) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, c := range clicks {
+		f.pending[clickKey{short: c.Short, bucket: c.Bucket}] += c.Clicks
+	}
+}
+
+// Metrics returns a snapshot of the flusher counters.
+func (f *ClickFlusher) Metrics() Metrics {
+	return f.counters.snapshot()
+}
+
+// Run flushes the buffered clicks every interval, and early once the batch
+// size is reached, until Stop is called or ctx is done. It flushes the
+// remaining clicks before returning.
+func (f *ClickFlusher) Run(ctx context.Context) {
+	defer f.doneOnce.Do(func() { close(f.done) })
+
+	timer := time.NewTimer(time.Duration(f.interval.Load()))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.flush()
+			return
+		case <-f.stop:
+			f.flush()
+			return
+		case <-f.full:
+		case <-timer.C:
+		}
+		f.flush()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(time.Duration(f.interval.Load()))
+	}
+}
+
+// flush calls Flush with a bounded context and logs failures.
+func (f *ClickFlusher) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if err := f.Flush(ctx); err != nil {
+		f.logger.Error("Cannot flush clicks", zap.Error(err), zap.Int("pending", f.Pending()))
+	}
+}
+
+// Stop makes Run flush the buffered clicks and return, and waits for it or
+// for ctx to be done.
+func (f *ClickFlusher) Stop(ctx context.Context) error {
+	f.stopOnce.Do(func() { close(f.stop) })
+
+	select {
+	case <-f.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
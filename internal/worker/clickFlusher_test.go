@@ -0,0 +1,106 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+// clickRepo records the batches of clicks it is given, failing while err is set.
+type clickRepo struct {
+	mu      sync.Mutex
+	err     error
+	batches [][]storage.BucketClicks
+}
+
+func (r *clickRepo) RecordClicks(_ context.Context, clicks []storage.BucketClicks) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	r.batches = append(r.batches, append([]storage.BucketClicks(nil), clicks...))
+	return nil
+}
+
+func (r *clickRepo) recorded() [][]storage.BucketClicks {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.batches
+}
+
+func TestClickFlusher_Flush(t *testing.T) {
+	repo := &clickRepo{}
+	f := worker.NewClickFlusher(zap.NewNop(), repo, time.Hour)
+
+	now := time.Date(2024, 5, 1, 12, 34, 0, 0, time.UTC)
+	bucket := now.Truncate(storage.ClickBucket)
+	f.Add("b", now)
+	f.Add("a", now)
+	f.Add("b", now.Add(time.Minute))
+	f.Add("b", now.Add(-time.Hour))
+	assert.Equal(t, 4, f.Pending())
+	assert.Empty(t, repo.recorded(), "clicks are buffered until flushed")
+
+	// Clicks are aggregated by short URL and bucket
+	require.NoError(t, f.Flush(context.Background()))
+	assert.Equal(t, [][]storage.BucketClicks{{
+		{Short: "a", Bucket: bucket, Clicks: 1},
+		{Short: "b", Bucket: bucket.Add(-time.Hour), Clicks: 1},
+		{Short: "b", Bucket: bucket, Clicks: 2},
+	}}, repo.recorded())
+	assert.Zero(t, f.Pending())
+
+	// Nothing is written without clicks
+	require.NoError(t, f.Flush(context.Background()))
+	assert.Len(t, repo.recorded(), 1)
+	assert.Equal(t, uint64(1), f.Metrics().Runs)
+}
+
+func TestClickFlusher_FailedFlushKeepsClicks(t *testing.T) {
+	repo := &clickRepo{err: errors.New("boom")}
+	f := worker.NewClickFlusher(zap.NewNop(), repo, time.Hour)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f.Add("a", now)
+	require.Error(t, f.Flush(context.Background()))
+	assert.Equal(t, uint64(1), f.Metrics().Failures)
+
+	// Clicks of the failed flush are added to the next one
+	f.Add("a", now)
+	repo.mu.Lock()
+	repo.err = nil
+	repo.mu.Unlock()
+	require.NoError(t, f.Flush(context.Background()))
+	assert.Equal(t, [][]storage.BucketClicks{{{Short: "a", Bucket: now, Clicks: 2}}}, repo.recorded())
+}
+
+func TestClickFlusher_Run(t *testing.T) {
+	repo := &clickRepo{}
+	f := worker.NewClickFlusher(zap.NewNop(), repo, 5*time.Millisecond)
+	go f.Run(context.Background())
+
+	f.Add("a", time.Now())
+	require.Eventually(t, func() bool { return len(repo.recorded()) == 1 }, time.Second, time.Millisecond)
+
+	// Stop flushes the remaining clicks
+	f.SetInterval(time.Hour)
+	time.Sleep(10 * time.Millisecond) // Let the short timer that is running fire
+	f.Add("b", time.Now())
+	require.NoError(t, f.Stop(context.Background()))
+	batches := repo.recorded()
+	require.NotEmpty(t, batches)
+	last := batches[len(batches)-1]
+	assert.Equal(t, "b", last[len(last)-1].Short)
+	assert.Zero(t, f.Pending())
+}
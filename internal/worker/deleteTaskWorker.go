@@ -1,9 +1,12 @@
-// Package worker provides background workers for asynchronous processing tasks,
-// such as batched deletion of URL records.
+// Package worker provides a runtime for background jobs and the jobs run by
+// the service, such as batched deletion of URL records.
 package worker
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -17,27 +20,118 @@ type Repo interface {
 	DeleteBatch(context.Context, []storage.URLRecord) error
 }
 
+// DeadLetter stores batches that could not be deleted after all retries.
+type DeadLetter interface {
+	PutDeadLetter(context.Context, storage.DeadLetterBatch) error
+}
+
+// Journal persists deletions that were accepted but not yet applied, so that
+// they are applied after a crash or restart.
+type Journal interface {
+	AppendPending(context.Context, []storage.URLRecord) error
+	RemovePending(context.Context, []storage.URLRecord) error
+	ReadPending(context.Context) ([]storage.URLRecord, error)
+}
+
+// Default batching and retry settings used when Options leaves them unset.
+const (
+	DefaultBatchSize     = 25
+	DefaultFlushInterval = 10 * time.Second
+	DefaultWorkers       = 1
+	DefaultMaxRetries    = 3
+	DefaultRetryBackoff  = 100 * time.Millisecond
+)
+
+// Options configures how a DeleteTaskWorker batches deletions.
+type Options struct {
+	// BatchSize is the number of buffered records after which a batch is flushed
+	// early, before the flush interval elapses.
+	BatchSize int
+	// FlushInterval is how often each worker goroutine flushes its buffer.
+	FlushInterval time.Duration
+	// Workers is the number of goroutines receiving and flushing records.
+	Workers int
+	// MaxRetries is how many times a failed batch is retried; negative disables retries.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled for each next one.
+	RetryBackoff time.Duration
+	// DeadLetter receives batches that still fail after all retries. When nil
+	// they are only logged.
+	DeadLetter DeadLetter
+	// Journal persists submitted records until their batch is processed and
+	// replays them when FlushRecords starts. Records are kept in memory only when nil.
+	Journal Journal
+}
+
+// withDefaults returns a copy of o with unset or invalid fields replaced by defaults.
+func (o Options) withDefaults() Options {
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultBatchSize
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = DefaultFlushInterval
+	}
+	if o.Workers <= 0 {
+		o.Workers = DefaultWorkers
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = DefaultMaxRetries
+	} else if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = DefaultRetryBackoff
+	}
+	return o
+}
+
+// ErrStopped is returned when submitting records to a worker that is stopping or stopped.
+var ErrStopped = errors.New("delete worker is stopped")
+
 // DeleteTaskWorker is a background worker responsible for collecting and
 // deleting URL records in batches. It accepts records through a channel and
-// periodically flushes them to the storage.
+// periodically flushes them to the storage. It implements Job.
 type DeleteTaskWorker struct {
 	in     chan storage.URLRecord // Channel for incoming URL records to be deleted
 	logger *zap.Logger            // Structured logger for debugging and error reporting
 	repo   Repo                   // Storage layer interface for deletion
+	opts   Options                // Batching settings
+
+	running  atomic.Bool  // Whether FlushRecords is processing records
+	counters counters     // Runs count deleted batches
+	queued   atomic.Int64 // Records received but not deleted yet
+
+	mu       sync.RWMutex  // Guards closing in against concurrent Submit calls
+	stopping bool          // Whether Stop was called; set under mu
+	done     chan struct{} // Closed when FlushRecords returns
+	doneOnce sync.Once     // Closes done once
 }
 
-// NewDeleteRecordWorker creates and returns a new DeleteTaskWorker.
+// NewDeleteRecordWorker creates and returns a new DeleteTaskWorker with the default options.
 // It initializes the input channel and sets the logger and storage repository.
 func NewDeleteRecordWorker(logger *zap.Logger, repo Repo) *DeleteTaskWorker {
+	return NewDeleteRecordWorkerWithOptions(logger, repo, Options{})
+}
+
+// NewDeleteRecordWorkerWithOptions creates a DeleteTaskWorker with the given
+// batching options. Zero fields fall back to the defaults.
+func NewDeleteRecordWorkerWithOptions(logger *zap.Logger, repo Repo, opts Options) *DeleteTaskWorker {
 	ch := make(chan storage.URLRecord)
 
 	return &DeleteTaskWorker{
 		in:     ch,
 		logger: logger,
 		repo:   repo,
+		opts:   opts.withDefaults(),
+		done:   make(chan struct{}),
 	}
 }
 
+// Options returns the batching options the worker runs with.
+func (s *DeleteTaskWorker) Options() Options {
+	return s.opts
+}
+
 // GetInChannel returns a write-only channel for sending records to be deleted.
 // Callers can push records to this channel to schedule them for deletion.
 func (s *DeleteTaskWorker) GetInChannel() chan<- storage.URLRecord {
@@ -45,12 +139,130 @@ func (s *DeleteTaskWorker) GetInChannel() chan<- storage.URLRecord {
 	return s.in
 }
 
-// FlushRecords starts an infinite loop that receives records from the input
-// channel and flushes them to the storage in batches. Records are sent either
-// when the buffer reaches 25 items or every 10 seconds.
+// Submit schedules a record for deletion. It returns ErrStopped once Stop has
+// been called or FlushRecords has returned, and ctx.Err() if ctx is done first.
+// With a journal the record is persisted before it is queued.
+func (s *DeleteTaskWorker) Submit(ctx context.Context, r storage.URLRecord) error {
+	_, err := s.SubmitAll(ctx, []storage.URLRecord{r})
+	return err
+}
+
+// SubmitAll schedules the records for deletion, like Submit, and returns how
+// many of them were queued, in order, before an error. With a journal all the
+// records are persisted in a single write before the first is queued.
+func (s *DeleteTaskWorker) SubmitAll(ctx context.Context, rs []storage.URLRecord) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.stopping {
+		return 0, ErrStopped
+	}
+	if len(rs) == 0 {
+		return 0, nil
+	}
+
+	if s.opts.Journal != nil {
+		if err := s.opts.Journal.AppendPending(ctx, rs); err != nil {
+			return 0, err
+		}
+	}
+
+	for i, r := range rs {
+		var err error
+		select {
+		case s.in <- r:
+			continue
+		case <-s.done:
+			err = ErrStopped
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		// The remaining records were not accepted, so they must not be replayed either
+		if s.opts.Journal != nil {
+			if jErr := s.opts.Journal.RemovePending(context.WithoutCancel(ctx), rs[i:]); jErr != nil {
+				s.logger.Error("Cannot remove records from the delete journal", zap.Error(jErr))
+			}
+		}
+		return i, err
+	}
+	return len(rs), nil
+}
+
+// Stop stops accepting records, lets FlushRecords drain the queue and flush
+// the remaining batch, and waits until it returns or ctx is done. It must not
+// be combined with closing the channel returned by GetInChannel.
+func (s *DeleteTaskWorker) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.stopping {
+		s.stopping = true
+		close(s.in)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Metrics returns a snapshot of the worker counters.
+func (s *DeleteTaskWorker) Metrics() Metrics {
+	return s.counters.snapshot()
+}
+
+// Queued returns the number of records received by the worker goroutines
+// that are waiting for their batch to be deleted.
+func (s *DeleteTaskWorker) Queued() int {
+	return int(s.queued.Load())
+}
+
+// Run implements Job by calling FlushRecords.
+func (s *DeleteTaskWorker) Run(ctx context.Context) {
+	s.FlushRecords(ctx)
+}
+
+// Running reports whether FlushRecords is processing records.
+func (s *DeleteTaskWorker) Running() bool {
+	return s.running.Load()
+}
+
+// FlushRecords first processes the deletions left in the journal, if any, then
+// starts the configured number of worker goroutines, which
+// receive records from the input channel and flush them to the storage in
+// batches, and blocks until all of them return. Each goroutine flushes its
+// buffer when it exceeds BatchSize records or every FlushInterval. They return
+// after flushing their final batch once the channel is closed by Stop, or once
+// ctx is cancelled, in which case records already waiting to be sent are taken too.
 func (s *DeleteTaskWorker) FlushRecords(ctx context.Context) {
-	s.logger.Info("Flushing records init")
-	ticker := time.NewTicker(10 * time.Second)
+	s.running.Store(true)
+	defer s.doneOnce.Do(func() { close(s.done) })
+	defer s.running.Store(false)
+
+	s.logger.Info("Flushing records init",
+		zap.Int("workers", s.opts.Workers),
+		zap.Int("batch_size", s.opts.BatchSize),
+		zap.Duration("flush_interval", s.opts.FlushInterval),
+	)
+
+	s.replayJournal()
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.flushLoop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// flushLoop is the body of a single worker goroutine started by FlushRecords.
+func (s *DeleteTaskWorker) flushLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.FlushInterval)
 	defer ticker.Stop()
 
 	var messages []storage.URLRecord
@@ -61,12 +273,8 @@ func (s *DeleteTaskWorker) FlushRecords(ctx context.Context) {
 			return
 		}
 		s.logger.Info("Flushing delete records", zap.Int("count", len(messages)))
-		batchCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
-
-		if err := s.repo.DeleteBatch(batchCtx, messages); err != nil {
-			s.logger.Error("Cannot delete records", zap.Error(err))
-		}
+		s.deleteBatch(messages)
+		s.queued.Add(-int64(len(messages)))
 		messages = messages[:0]
 	}
 
@@ -74,6 +282,20 @@ func (s *DeleteTaskWorker) FlushRecords(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			s.logger.Info("FlushRecords context cancelled, flushing final batch")
+			// Take the records of senders that are already waiting
+			for drained := false; !drained; {
+				select {
+				case msg, ok := <-s.in:
+					if !ok {
+						drained = true
+						break
+					}
+					messages = append(messages, msg)
+					s.queued.Add(1)
+				default:
+					drained = true
+				}
+			}
 			sendMessages()
 			return
 
@@ -85,7 +307,8 @@ func (s *DeleteTaskWorker) FlushRecords(ctx context.Context) {
 			}
 			s.logger.Info("Got record to delete", zap.Any("msg", msg))
 			messages = append(messages, msg)
-			if len(messages) > 25 {
+			s.queued.Add(1)
+			if len(messages) > s.opts.BatchSize {
 				sendMessages()
 			}
 
@@ -94,3 +317,86 @@ func (s *DeleteTaskWorker) FlushRecords(ctx context.Context) {
 		}
 	}
 }
+
+// replayJournal processes the deletions left in the journal by a previous run.
+func (s *DeleteTaskWorker) replayJournal() {
+	if s.opts.Journal == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	records, err := s.opts.Journal.ReadPending(ctx)
+	cancel()
+	if err != nil {
+		s.logger.Error("Cannot read the delete journal", zap.Error(err))
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	s.logger.Info("Replaying pending deletions", zap.Int("count", len(records)))
+	for len(records) > 0 {
+		n := min(len(records), s.opts.BatchSize)
+		s.deleteBatch(records[:n])
+		records = records[n:]
+	}
+}
+
+// deleteBatch processes the records and removes them from the journal.
+func (s *DeleteTaskWorker) deleteBatch(records []storage.URLRecord) {
+	s.applyBatch(records)
+
+	if s.opts.Journal == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := s.opts.Journal.RemovePending(ctx, records); err != nil {
+		// The records are deleted again after a restart, which is harmless
+		s.logger.Error("Cannot remove records from the delete journal", zap.Error(err))
+	}
+}
+
+// applyBatch deletes the records, retrying failures with exponential backoff.
+// Batches that still fail are handed to the dead-letter store.
+func (s *DeleteTaskWorker) applyBatch(records []storage.URLRecord) {
+	retry := RetryPolicy{MaxRetries: s.opts.MaxRetries, Backoff: s.opts.RetryBackoff}
+
+	// Retries are not cut short on shutdown, the final batch deserves them too
+	attempts, err := retry.Do(context.Background(), func(ctx context.Context) error {
+		batchCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+		return s.repo.DeleteBatch(batchCtx, records)
+	}, func(attempt int, err error, backoff time.Duration) {
+		s.counters.retries.Add(1)
+		s.logger.Warn("Cannot delete records, retrying",
+			zap.Error(err), zap.Int("attempt", attempt), zap.Duration("backoff", backoff))
+	})
+	if err == nil {
+		s.counters.runs.Add(1)
+		return
+	}
+
+	s.counters.failures.Add(1)
+	s.logger.Error("Cannot delete records", zap.Error(err), zap.Int("attempts", attempts), zap.Int("count", len(records)))
+
+	if s.opts.DeadLetter == nil {
+		s.logger.Error("Dropping records that could not be deleted", zap.Any("records", records))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if dlErr := s.opts.DeadLetter.PutDeadLetter(ctx, storage.DeadLetterBatch{
+		Time:     time.Now(),
+		Error:    err.Error(),
+		Attempts: attempts,
+		Records:  records,
+	}); dlErr != nil {
+		s.logger.Error("Cannot store records in the dead-letter store",
+			zap.Error(dlErr), zap.Any("records", records))
+		return
+	}
+	s.counters.deadLettered.Add(1)
+}
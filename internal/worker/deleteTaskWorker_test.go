@@ -3,6 +3,7 @@ package worker_test
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,20 +15,34 @@ import (
 )
 
 type MockRepo struct {
-	Calls  [][]storage.URLRecord
-	FailOn int
-	CallNo int
+	mu       sync.Mutex
+	Calls    [][]storage.URLRecord
+	FailOn   int
+	CallNo   int
+	FailOver int // Calls with CallNo <= FailOver fail as well
 }
 
 func (m *MockRepo) DeleteBatch(_ context.Context, records []storage.URLRecord) error {
-	m.Calls = append(m.Calls, records)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Calls = append(m.Calls, append([]storage.URLRecord(nil), records...))
 	m.CallNo++
-	if m.CallNo == m.FailOn {
+	if m.CallNo == m.FailOn || m.CallNo <= m.FailOver {
 		return errors.New("forced failure")
 	}
 	return nil
 }
 
+// deleted returns the number of batches and records passed to DeleteBatch so far.
+func (m *MockRepo) deleted() (batches, records int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.Calls {
+		records += len(c)
+	}
+	return len(m.Calls), records
+}
+
 func testLogger() *zap.Logger {
 	cfg := zap.NewDevelopmentConfig()
 	cfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
@@ -88,7 +103,8 @@ func TestFlushRecords_ErrorClearsBuffer(t *testing.T) {
 	repo := &MockRepo{FailOn: 1}
 	logger := testLogger()
 
-	worker := worker.NewDeleteRecordWorker(logger, repo)
+	// Without retries the failed batch is dropped
+	worker := worker.NewDeleteRecordWorkerWithOptions(logger, repo, worker.Options{MaxRetries: -1})
 	in := worker.GetInChannel()
 
 	go worker.FlushRecords(context.Background())
@@ -106,3 +122,318 @@ func TestFlushRecords_ErrorClearsBuffer(t *testing.T) {
 		require.LessOrEqual(t, len(repo.Calls[1]), 25)
 	}
 }
+
+func TestStop_DrainsQueue(t *testing.T) {
+	repo := &MockRepo{}
+	w := worker.NewDeleteRecordWorker(zap.NewNop(), repo)
+
+	go w.FlushRecords(context.Background())
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, w.Submit(context.Background(), storage.URLRecord{Short: "abc", UserID: "user"}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, w.Stop(ctx))
+
+	// The pending batch is flushed before Stop returns
+	require.Len(t, repo.Calls, 1)
+	require.Len(t, repo.Calls[0], 10)
+	require.False(t, w.Running())
+
+	require.ErrorIs(t, w.Submit(context.Background(), storage.URLRecord{Short: "def", UserID: "user"}), worker.ErrStopped)
+	require.NoError(t, w.Stop(ctx))
+}
+
+func TestStop_Timeout(t *testing.T) {
+	w := worker.NewDeleteRecordWorker(zap.NewNop(), &MockRepo{})
+
+	// FlushRecords never ran, so Stop gives up when ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, w.Stop(ctx), context.DeadlineExceeded)
+}
+
+func TestSubmit_AfterContextCancelled(t *testing.T) {
+	repo := &MockRepo{}
+	w := worker.NewDeleteRecordWorker(zap.NewNop(), repo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.FlushRecords(ctx)
+		close(done)
+	}()
+
+	require.NoError(t, w.Submit(context.Background(), storage.URLRecord{Short: "abc", UserID: "user"}))
+	cancel()
+	<-done
+
+	require.Len(t, repo.Calls, 1)
+	require.ErrorIs(t, w.Submit(context.Background(), storage.URLRecord{Short: "def", UserID: "user"}), worker.ErrStopped)
+}
+
+func TestNewDeleteRecordWorkerWithOptions_Defaults(t *testing.T) {
+	w := worker.NewDeleteRecordWorkerWithOptions(zap.NewNop(), &MockRepo{}, worker.Options{BatchSize: -1, Workers: 3})
+
+	require.Equal(t, worker.Options{
+		BatchSize:     worker.DefaultBatchSize,
+		FlushInterval: worker.DefaultFlushInterval,
+		Workers:       3,
+		MaxRetries:    worker.DefaultMaxRetries,
+		RetryBackoff:  worker.DefaultRetryBackoff,
+	}, w.Options())
+}
+
+func TestFlushRecords_ConfiguredBatchSize(t *testing.T) {
+	repo := &MockRepo{}
+	w := worker.NewDeleteRecordWorkerWithOptions(zap.NewNop(), repo, worker.Options{BatchSize: 3, FlushInterval: time.Hour})
+
+	go w.FlushRecords(context.Background())
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, w.Submit(context.Background(), storage.URLRecord{Short: "abc", UserID: "user"}))
+	}
+
+	require.Eventually(t, func() bool {
+		batches, _ := repo.deleted()
+		return batches == 1
+	}, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, w.Stop(ctx))
+
+	require.Len(t, repo.Calls, 1)
+	require.Len(t, repo.Calls[0], 4)
+}
+
+func TestFlushRecords_ConfiguredInterval(t *testing.T) {
+	repo := &MockRepo{}
+	w := worker.NewDeleteRecordWorkerWithOptions(zap.NewNop(), repo, worker.Options{FlushInterval: 20 * time.Millisecond})
+
+	go w.FlushRecords(context.Background())
+	defer w.Stop(context.Background())
+
+	require.NoError(t, w.Submit(context.Background(), storage.URLRecord{Short: "abc", UserID: "user"}))
+	require.NoError(t, w.Submit(context.Background(), storage.URLRecord{Short: "def", UserID: "user"}))
+
+	require.Eventually(t, func() bool {
+		_, records := repo.deleted()
+		return records == 2
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestFlushRecords_MultipleWorkers(t *testing.T) {
+	repo := &MockRepo{}
+	w := worker.NewDeleteRecordWorkerWithOptions(zap.NewNop(), repo, worker.Options{BatchSize: 5, Workers: 4})
+
+	go w.FlushRecords(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				require.NoError(t, w.Submit(context.Background(), storage.URLRecord{Short: "abc", UserID: "user"}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, w.Stop(ctx))
+
+	// Every worker flushes its own buffer on Stop, so nothing is lost
+	_, records := repo.deleted()
+	require.Equal(t, 200, records)
+	require.False(t, w.Running())
+}
+
+func TestFlushRecords_RetriesFailedBatch(t *testing.T) {
+	repo := &MockRepo{FailOver: 2}
+	deadLetter := storage.NewMemoryDeadLetterStorage()
+	w := worker.NewDeleteRecordWorkerWithOptions(zap.NewNop(), repo, worker.Options{
+		RetryBackoff: time.Millisecond,
+		DeadLetter:   deadLetter,
+	})
+
+	go w.FlushRecords(context.Background())
+	require.NoError(t, w.Submit(context.Background(), storage.URLRecord{Short: "abc", UserID: "user"}))
+	require.NoError(t, w.Stop(context.Background()))
+
+	// Two failures, then the third attempt succeeds
+	require.Len(t, repo.Calls, 3)
+	for _, c := range repo.Calls {
+		require.Equal(t, []storage.URLRecord{{Short: "abc", UserID: "user"}}, c)
+	}
+	require.Equal(t, worker.Metrics{Runs: 1, Retries: 2}, w.Metrics())
+
+	batches, err := deadLetter.ReadDeadLetters(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, batches)
+}
+
+func TestFlushRecords_DeadLettersFailedBatch(t *testing.T) {
+	repo := &MockRepo{FailOver: 100}
+	deadLetter := storage.NewMemoryDeadLetterStorage()
+	w := worker.NewDeleteRecordWorkerWithOptions(zap.NewNop(), repo, worker.Options{
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+		DeadLetter:   deadLetter,
+	})
+
+	go w.FlushRecords(context.Background())
+	require.NoError(t, w.Submit(context.Background(), storage.URLRecord{Short: "abc", UserID: "user"}))
+	require.NoError(t, w.Submit(context.Background(), storage.URLRecord{Short: "def", UserID: "user"}))
+	require.NoError(t, w.Stop(context.Background()))
+
+	require.Len(t, repo.Calls, 3)
+	require.Equal(t, worker.Metrics{Retries: 2, Failures: 1, DeadLettered: 1}, w.Metrics())
+
+	batches, err := deadLetter.ReadDeadLetters(context.Background())
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	require.Equal(t, "forced failure", batches[0].Error)
+	require.Equal(t, 3, batches[0].Attempts)
+	require.Equal(t, []storage.URLRecord{
+		{Short: "abc", UserID: "user"},
+		{Short: "def", UserID: "user"},
+	}, batches[0].Records)
+}
+
+func TestFlushRecords_ReplaysJournal(t *testing.T) {
+	journal, err := storage.NewFileDeleteJournal(t.TempDir() + "/deletes")
+	require.NoError(t, err)
+	defer journal.Close()
+
+	// Records accepted by a previous run that crashed before flushing them
+	require.NoError(t, journal.AppendPending(context.Background(), []storage.URLRecord{
+		{Short: "aaa", UserID: "user"},
+		{Short: "bbb", UserID: "user"},
+		{Short: "ccc", UserID: "user"},
+	}))
+
+	repo := &MockRepo{}
+	w := worker.NewDeleteRecordWorkerWithOptions(zap.NewNop(), repo, worker.Options{BatchSize: 2, Journal: journal})
+
+	go w.FlushRecords(context.Background())
+
+	// The journal is replayed in batches before new records are received
+	require.Eventually(t, func() bool {
+		pending, err := journal.ReadPending(context.Background())
+		return err == nil && len(pending) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, w.Submit(context.Background(), storage.URLRecord{Short: "ddd", UserID: "user"}))
+
+	pending, err := journal.ReadPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []storage.URLRecord{{Short: "ddd", UserID: "user"}}, pending)
+
+	require.NoError(t, w.Stop(context.Background()))
+
+	require.Equal(t, [][]storage.URLRecord{
+		{{Short: "aaa", UserID: "user"}, {Short: "bbb", UserID: "user"}},
+		{{Short: "ccc", UserID: "user"}},
+		{{Short: "ddd", UserID: "user"}},
+	}, repo.Calls)
+
+	pending, err = journal.ReadPending(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
+
+func TestSubmit_RejectedRecordLeavesJournal(t *testing.T) {
+	journal, err := storage.NewFileDeleteJournal(t.TempDir() + "/deletes")
+	require.NoError(t, err)
+	defer journal.Close()
+
+	w := worker.NewDeleteRecordWorkerWithOptions(zap.NewNop(), &MockRepo{}, worker.Options{Journal: journal})
+
+	// FlushRecords never runs, so the record is not accepted
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, w.Submit(ctx, storage.URLRecord{Short: "aaa", UserID: "user"}), context.DeadlineExceeded)
+
+	pending, err := journal.ReadPending(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
+
+func TestQueued(t *testing.T) {
+	repo := &MockRepo{}
+	w := worker.NewDeleteRecordWorkerWithOptions(zap.NewNop(), repo, worker.Options{FlushInterval: time.Hour, BatchSize: 100})
+
+	go w.FlushRecords(context.Background())
+	require.NoError(t, w.Submit(context.Background(), storage.URLRecord{Short: "abc", UserID: "user"}))
+	require.NoError(t, w.Submit(context.Background(), storage.URLRecord{Short: "def", UserID: "user"}))
+	require.Eventually(t, func() bool { return w.Queued() == 2 }, 5*time.Second, time.Millisecond)
+
+	// Deleted records leave the queue
+	require.NoError(t, w.Stop(context.Background()))
+	require.Zero(t, w.Queued())
+}
+
+// countingJournal is a journal counting its appends.
+type countingJournal struct {
+	worker.Journal
+	appends int
+}
+
+func (j *countingJournal) AppendPending(ctx context.Context, rs []storage.URLRecord) error {
+	j.appends++
+	return j.Journal.AppendPending(ctx, rs)
+}
+
+func TestSubmitAll_JournalsOnce(t *testing.T) {
+	file, err := storage.NewFileDeleteJournal(t.TempDir() + "/deletes")
+	require.NoError(t, err)
+	defer file.Close()
+	journal := &countingJournal{Journal: file}
+
+	repo := &MockRepo{}
+	w := worker.NewDeleteRecordWorkerWithOptions(zap.NewNop(), repo, worker.Options{Journal: journal})
+	go w.FlushRecords(context.Background())
+
+	records := []storage.URLRecord{
+		{Short: "aaa", UserID: "user"},
+		{Short: "bbb", UserID: "user"},
+		{Short: "ccc", UserID: "user"},
+	}
+	n, err := w.SubmitAll(context.Background(), records)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, 1, journal.appends)
+
+	// The replay of the journal on start may take the records too, which is harmless
+	require.NoError(t, w.Stop(context.Background()))
+	require.NotEmpty(t, repo.Calls)
+	require.Equal(t, records, repo.Calls[len(repo.Calls)-1])
+
+	_, err = w.SubmitAll(context.Background(), records)
+	require.ErrorIs(t, err, worker.ErrStopped)
+	require.Equal(t, 1, journal.appends)
+}
+
+func TestSubmitAll_RejectedRecordsLeaveJournal(t *testing.T) {
+	journal, err := storage.NewFileDeleteJournal(t.TempDir() + "/deletes")
+	require.NoError(t, err)
+	defer journal.Close()
+
+	w := worker.NewDeleteRecordWorkerWithOptions(zap.NewNop(), &MockRepo{}, worker.Options{Journal: journal})
+
+	// FlushRecords never runs, so none of the records is accepted
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	n, err := w.SubmitAll(ctx, []storage.URLRecord{{Short: "aaa", UserID: "user"}, {Short: "bbb", UserID: "user"}})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Zero(t, n)
+
+	pending, err := journal.ReadPending(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
@@ -0,0 +1,29 @@
+package worker
+
+import "sync/atomic"
+
+// Metrics are cumulative counters of a job.
+type Metrics struct {
+	Runs         uint64 // Successful runs, such as deleted batches
+	Retries      uint64 // Retried calls
+	Failures     uint64 // Runs that failed after all retries
+	DeadLettered uint64 // Failed runs whose input was stored in a dead-letter store
+}
+
+// counters are the concurrency-safe counters behind Metrics.
+type counters struct {
+	runs         atomic.Uint64
+	retries      atomic.Uint64
+	failures     atomic.Uint64
+	deadLettered atomic.Uint64
+}
+
+// snapshot returns the current values of the counters.
+func (c *counters) snapshot() Metrics {
+	return Metrics{
+		Runs:         c.runs.Load(),
+		Retries:      c.retries.Load(),
+		Failures:     c.failures.Load(),
+		DeadLettered: c.deadLettered.Load(),
+	}
+}
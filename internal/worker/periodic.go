@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// PeriodicJob is a Job that calls a function at a fixed interval, retrying
+// failed calls according to its RetryPolicy.
+type PeriodicJob struct {
+	name     string
+	interval time.Duration
+	retry    RetryPolicy
+	fn       func(context.Context) error
+	logger   *zap.Logger
+	counters counters
+
+	stop     chan struct{} // Closed by Stop
+	stopOnce sync.Once
+	done     chan struct{} // Closed when Run returns
+	doneOnce sync.Once
+}
+
+// NewPeriodicJob creates a job calling fn every interval. The name is used in logs.
+func NewPeriodicJob(logger *zap.Logger, name string, interval time.Duration, retry RetryPolicy, fn func(context.Context) error) *PeriodicJob {
+	return &PeriodicJob{
+		name:     name,
+		interval: interval,
+		retry:    retry,
+		fn:       fn,
+		logger:   logger,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Run calls the function every interval until Stop is called or ctx is done.
+// A call in progress is cancelled through its context when the job stops.
+func (j *PeriodicJob) Run(ctx context.Context) {
+	defer j.doneOnce.Do(func() { close(j.done) })
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-j.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.runOnce(ctx)
+		}
+	}
+}
+
+// runOnce calls the function once, with retries, and updates the counters.
+func (j *PeriodicJob) runOnce(ctx context.Context) {
+	attempts, err := j.retry.Do(ctx, j.fn, func(attempt int, err error, backoff time.Duration) {
+		j.counters.retries.Add(1)
+		j.logger.Warn("Job failed, retrying", zap.String("job", j.name),
+			zap.Error(err), zap.Int("attempt", attempt), zap.Duration("backoff", backoff))
+	})
+	if err != nil {
+		j.counters.failures.Add(1)
+		j.logger.Error("Job failed", zap.String("job", j.name), zap.Error(err), zap.Int("attempts", attempts))
+		return
+	}
+	j.counters.runs.Add(1)
+}
+
+// Stop stops the job and waits until Run returns or ctx is done.
+func (j *PeriodicJob) Stop(ctx context.Context) error {
+	j.stopOnce.Do(func() { close(j.stop) })
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Metrics returns a snapshot of the job counters.
+func (j *PeriodicJob) Metrics() Metrics {
+	return j.counters.snapshot()
+}
@@ -0,0 +1,53 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+func TestPeriodicJob(t *testing.T) {
+	var calls atomic.Int32
+	job := worker.NewPeriodicJob(zap.NewNop(), "test", 5*time.Millisecond, worker.RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond},
+		func(context.Context) error {
+			// Every other call fails once and succeeds on retry
+			if calls.Add(1)%2 == 1 {
+				return errors.New("boom")
+			}
+			return nil
+		})
+
+	go job.Run(context.Background())
+
+	require.Eventually(t, func() bool { return job.Metrics().Runs >= 2 }, time.Second, time.Millisecond)
+	require.NoError(t, job.Stop(context.Background()))
+
+	// Stop may cut a run short while it waits to retry
+	m := job.Metrics()
+	require.GreaterOrEqual(t, m.Retries, m.Runs)
+	require.LessOrEqual(t, m.Failures, uint64(1))
+}
+
+func TestPeriodicJob_StopCancelsRun(t *testing.T) {
+	started := make(chan struct{})
+	job := worker.NewPeriodicJob(zap.NewNop(), "test", time.Millisecond, worker.RetryPolicy{}, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	go job.Run(context.Background())
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, job.Stop(ctx))
+	require.Equal(t, uint64(1), job.Metrics().Failures)
+}
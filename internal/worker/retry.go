@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultMaxBackoff caps the exponentially growing delay between retries when
+// RetryPolicy leaves MaxBackoff unset.
+const DefaultMaxBackoff = 5 * time.Second
+
+// RetryPolicy describes how a failing job run is retried.
+type RetryPolicy struct {
+	// MaxRetries is how many times a failed call is retried; 0 disables retries.
+	MaxRetries int
+	// Backoff is the delay before the first retry, doubled for each next one.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+	// Retryable reports whether a failed call is retried; nil retries every error.
+	Retryable func(error) bool
+}
+
+// Do calls fn until it succeeds or the retries are exhausted, sleeping with
+// exponential backoff in between. onRetry, if not nil, is called before each
+// retry with the failed attempt number, its error and the delay. Do gives up
+// early when ctx is done or the error is not retryable. It returns the number of attempts and the last error.
+func (p RetryPolicy) Do(ctx context.Context, fn func(context.Context) error, onRetry func(attempt int, err error, backoff time.Duration)) (int, error) {
+	backoff := p.Backoff
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt > p.MaxRetries || (p.Retryable != nil && !p.Retryable(err)) {
+			return attempt, err
+		}
+
+		if onRetry != nil {
+			onRetry(attempt, err, backoff)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
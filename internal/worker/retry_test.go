@@ -0,0 +1,94 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+func TestRetryPolicy_Do(t *testing.T) {
+	failures := errors.New("boom")
+
+	tests := []struct {
+		name         string
+		policy       worker.RetryPolicy
+		failFirst    int
+		wantAttempts int
+		wantErr      error
+		wantBackoffs []time.Duration
+	}{
+		{
+			name:         "succeeds at once",
+			policy:       worker.RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond},
+			wantAttempts: 1,
+		},
+		{
+			name:         "succeeds after retries",
+			policy:       worker.RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond},
+			failFirst:    2,
+			wantAttempts: 3,
+			wantBackoffs: []time.Duration{time.Millisecond, 2 * time.Millisecond},
+		},
+		{
+			name:         "gives up with capped backoff",
+			policy:       worker.RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond, MaxBackoff: 3 * time.Millisecond},
+			failFirst:    10,
+			wantAttempts: 4,
+			wantErr:      failures,
+			wantBackoffs: []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond},
+		},
+		{
+			name:         "permanent error",
+			policy:       worker.RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond, Retryable: func(err error) bool { return !errors.Is(err, failures) }},
+			failFirst:    10,
+			wantAttempts: 1,
+			wantErr:      failures,
+		},
+		{
+			name:         "no retries",
+			policy:       worker.RetryPolicy{},
+			failFirst:    1,
+			wantAttempts: 1,
+			wantErr:      failures,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			var backoffs []time.Duration
+
+			attempts, err := tt.policy.Do(context.Background(), func(context.Context) error {
+				calls++
+				if calls <= tt.failFirst {
+					return failures
+				}
+				return nil
+			}, func(attempt int, err error, backoff time.Duration) {
+				require.Equal(t, len(backoffs)+1, attempt)
+				backoffs = append(backoffs, backoff)
+			})
+
+			require.Equal(t, tt.wantAttempts, attempts)
+			require.Equal(t, tt.wantAttempts, calls)
+			require.Equal(t, tt.wantErr, err)
+			require.Equal(t, tt.wantBackoffs, backoffs)
+		})
+	}
+}
+
+func TestRetryPolicy_DoStopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	policy := worker.RetryPolicy{MaxRetries: 5, Backoff: time.Hour}
+	attempts, err := policy.Do(ctx, func(context.Context) error { return errors.New("boom") }, nil)
+
+	require.Equal(t, 1, attempts)
+	require.EqualError(t, err, "boom")
+}
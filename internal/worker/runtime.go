@@ -0,0 +1,158 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Job is a background job run by a Runtime. Run blocks until the job is
+// stopped, either by Stop or by cancelling ctx. Stop makes Run finish its
+// pending work and return, and waits for it or for ctx to be done.
+type Job interface {
+	Run(ctx context.Context)
+	Stop(ctx context.Context) error
+}
+
+// MetricsProvider is implemented by jobs that report Metrics.
+type MetricsProvider interface {
+	Metrics() Metrics
+}
+
+// ErrDuplicateJob is returned when registering a job under a name that is taken.
+var ErrDuplicateJob = errors.New("job is already registered")
+
+// ErrRuntimeStopped is returned when registering a job with a stopped Runtime.
+var ErrRuntimeStopped = errors.New("job runtime is stopped")
+
+// entry is a job registered with a Runtime.
+type entry struct {
+	name    string
+	job     Job
+	started bool
+	done    chan struct{} // Closed when Run returns
+}
+
+// Runtime runs named background jobs and stops them together on shutdown.
+// Jobs registered after Start are started right away.
+type Runtime struct {
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	jobs    []*entry        // Registered jobs, in registration order
+	ctx     context.Context // Context jobs run with; nil until Start
+	stopped bool
+}
+
+// NewRuntime creates a Runtime with no jobs.
+func NewRuntime(logger *zap.Logger) *Runtime {
+	return &Runtime{logger: logger}
+}
+
+// Register adds a job under a unique name, starting it if the runtime runs.
+func (r *Runtime) Register(name string, job Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return ErrRuntimeStopped
+	}
+	for _, e := range r.jobs {
+		if e.name == name {
+			return fmt.Errorf("%w: %s", ErrDuplicateJob, name)
+		}
+	}
+
+	e := &entry{name: name, job: job, done: make(chan struct{})}
+	r.jobs = append(r.jobs, e)
+	if r.ctx != nil {
+		r.start(e)
+	}
+	return nil
+}
+
+// Start runs all registered jobs with ctx. Calling it again has no effect.
+func (r *Runtime) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ctx != nil || r.stopped {
+		return
+	}
+	r.ctx = ctx
+	for _, e := range r.jobs {
+		r.start(e)
+	}
+}
+
+// start runs the job in its own goroutine; r.mu must be held.
+func (r *Runtime) start(e *entry) {
+	e.started = true
+	r.logger.Info("Starting job", zap.String("job", e.name))
+	go func() {
+		defer close(e.done)
+		e.job.Run(r.ctx)
+	}()
+}
+
+// Stop stops the started jobs in reverse registration order, so that jobs can
+// rely on the ones registered before them, and waits for each of them until
+// ctx is done. It returns the errors of the jobs that did not stop in time.
+func (r *Runtime) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	r.stopped = true
+	jobs := append([]*entry(nil), r.jobs...)
+	r.mu.Unlock()
+
+	var errs []error
+	for i := len(jobs) - 1; i >= 0; i-- {
+		e := jobs[i]
+		if !e.started {
+			continue
+		}
+		r.logger.Info("Stopping job", zap.String("job", e.name))
+		if err := e.job.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Running reports whether the job registered under name is running.
+func (r *Runtime) Running(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range r.jobs {
+		if e.name != name {
+			continue
+		}
+		if !e.started {
+			return false
+		}
+		select {
+		case <-e.done:
+			return false
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// Metrics returns the metrics of the registered jobs that report them, by name.
+func (r *Runtime) Metrics() map[string]Metrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make(map[string]Metrics)
+	for _, e := range r.jobs {
+		if p, ok := e.job.(MetricsProvider); ok {
+			res[e.name] = p.Metrics()
+		}
+	}
+	return res
+}
@@ -0,0 +1,109 @@
+package worker_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+// fakeJob records when it is run and stopped.
+type fakeJob struct {
+	name    string
+	log     *[]string
+	mu      *sync.Mutex
+	stop    chan struct{}
+	blocked bool // Ignore Stop
+}
+
+func newFakeJob(name string, log *[]string, mu *sync.Mutex) *fakeJob {
+	return &fakeJob{name: name, log: log, mu: mu, stop: make(chan struct{})}
+}
+
+func (j *fakeJob) record(event string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	*j.log = append(*j.log, event+" "+j.name)
+}
+
+func (j *fakeJob) Run(ctx context.Context) {
+	j.record("run")
+	select {
+	case <-j.stop:
+	case <-ctx.Done():
+	}
+}
+
+func (j *fakeJob) Stop(ctx context.Context) error {
+	j.record("stop")
+	if j.blocked {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	close(j.stop)
+	return nil
+}
+
+func (j *fakeJob) Metrics() worker.Metrics {
+	return worker.Metrics{Runs: 7}
+}
+
+func TestRuntime(t *testing.T) {
+	var (
+		log []string
+		mu  sync.Mutex
+	)
+	r := worker.NewRuntime(zap.NewNop())
+
+	first := newFakeJob("first", &log, &mu)
+	require.NoError(t, r.Register("first", first))
+	require.ErrorIs(t, r.Register("first", first), worker.ErrDuplicateJob)
+	require.False(t, r.Running("first"))
+
+	r.Start(context.Background())
+	require.Eventually(t, func() bool { return r.Running("first") }, time.Second, time.Millisecond)
+
+	// Jobs registered after Start are started right away
+	second := newFakeJob("second", &log, &mu)
+	require.NoError(t, r.Register("second", second))
+	require.Eventually(t, func() bool { return r.Running("second") }, time.Second, time.Millisecond)
+	require.False(t, r.Running("unknown"))
+
+	require.Equal(t, map[string]worker.Metrics{"first": {Runs: 7}, "second": {Runs: 7}}, r.Metrics())
+
+	require.NoError(t, r.Stop(context.Background()))
+	require.Eventually(t, func() bool { return !r.Running("first") && !r.Running("second") }, time.Second, time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"run first", "run second", "stop second", "stop first"}, log)
+
+	require.ErrorIs(t, r.Register("third", newFakeJob("third", &log, &mu)), worker.ErrRuntimeStopped)
+}
+
+func TestRuntime_StopTimeout(t *testing.T) {
+	var (
+		log []string
+		mu  sync.Mutex
+	)
+	r := worker.NewRuntime(zap.NewNop())
+
+	stuck := newFakeJob("stuck", &log, &mu)
+	stuck.blocked = true
+	require.NoError(t, r.Register("stuck", stuck))
+	// Jobs that stop in time do not add errors
+	require.NoError(t, r.Register("idle", newFakeJob("idle", &log, &mu)))
+
+	r.Start(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := r.Stop(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "stuck")
+}
@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// Default expiration sweeper settings used when SweepOptions leaves them unset.
+const (
+	DefaultSweepInterval  = time.Minute
+	DefaultSweepBatchSize = 100
+)
+
+// ExpiredStore finds expired records and deletes them.
+type ExpiredStore interface {
+	FindExpired(context.Context, time.Time, int) ([]storage.URLRecord, error)
+	DeleteBatch(context.Context, []storage.URLRecord) error
+}
+
+// SweepOptions configures the expiration sweeper.
+type SweepOptions struct {
+	// Interval is how often expired records are looked for.
+	Interval time.Duration
+	// BatchSize is the number of expired records deleted at once.
+	BatchSize int
+	// Retry is applied to sweeps that fail.
+	Retry RetryPolicy
+}
+
+// NewExpirationSweeper returns a job that deletes records past their
+// expiration time every interval. A sweep deletes batches until no expired
+// records are left.
+func NewExpirationSweeper(logger *zap.Logger, store ExpiredStore, opts SweepOptions) *PeriodicJob {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultSweepInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultSweepBatchSize
+	}
+
+	sweep := func(ctx context.Context) error {
+		now := time.Now()
+		for ctx.Err() == nil {
+			records, err := store.FindExpired(ctx, now, opts.BatchSize)
+			if err != nil {
+				return err
+			}
+			if len(records) == 0 {
+				return nil
+			}
+
+			logger.Info("Deleting expired records", zap.Int("count", len(records)))
+			if err := store.DeleteBatch(ctx, records); err != nil {
+				return err
+			}
+			if len(records) < opts.BatchSize {
+				return nil
+			}
+		}
+		return ctx.Err()
+	}
+
+	return NewPeriodicJob(logger, "expiration_sweeper", opts.Interval, opts.Retry, sweep)
+}
@@ -0,0 +1,73 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+// expiredStore is an ExpiredStore over a fixed list of expired records.
+type expiredStore struct {
+	mu      sync.Mutex
+	expired []storage.URLRecord
+	batches [][]storage.URLRecord
+	findErr error
+}
+
+func (s *expiredStore) FindExpired(_ context.Context, _ time.Time, limit int) ([]storage.URLRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.findErr != nil {
+		return nil, s.findErr
+	}
+	n := min(limit, len(s.expired))
+	return append([]storage.URLRecord(nil), s.expired[:n]...), nil
+}
+
+func (s *expiredStore) DeleteBatch(_ context.Context, rs []storage.URLRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, rs)
+	s.expired = s.expired[len(rs):]
+	return nil
+}
+
+func TestExpirationSweeper(t *testing.T) {
+	store := &expiredStore{}
+	for _, short := range []string{"a", "b", "c", "d", "e"} {
+		store.expired = append(store.expired, storage.URLRecord{Short: short, UserID: "user"})
+	}
+
+	job := worker.NewExpirationSweeper(zap.NewNop(), store, worker.SweepOptions{Interval: 5 * time.Millisecond, BatchSize: 2})
+	go job.Run(context.Background())
+
+	// A single sweep deletes everything in batches
+	require.Eventually(t, func() bool { return job.Metrics().Runs >= 1 }, time.Second, time.Millisecond)
+	require.NoError(t, job.Stop(context.Background()))
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.Empty(t, store.expired)
+	require.Len(t, store.batches, 3)
+	require.Len(t, store.batches[0], 2)
+	require.Len(t, store.batches[2], 1)
+}
+
+func TestExpirationSweeper_Error(t *testing.T) {
+	store := &expiredStore{findErr: errors.New("db down")}
+
+	job := worker.NewExpirationSweeper(zap.NewNop(), store, worker.SweepOptions{Interval: 5 * time.Millisecond})
+	go job.Run(context.Background())
+
+	require.Eventually(t, func() bool { return job.Metrics().Failures >= 1 }, time.Second, time.Millisecond)
+	require.NoError(t, job.Stop(context.Background()))
+	require.Zero(t, job.Metrics().Runs)
+}
@@ -0,0 +1,164 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// Default threat scanner settings used when ScanOptions leaves them unset.
+const (
+	DefaultScanQueueSize = 1000
+	DefaultScanTimeout   = 5 * time.Second
+)
+
+// ErrScanQueueFull is returned when submitting a record to a scanner whose queue is full.
+var ErrScanQueueFull = errors.New("threat scan queue is full")
+
+// ThreatChecker looks up URLs in a threat intelligence source, such as Google
+// Safe Browsing or a local threat feed.
+type ThreatChecker interface {
+	// Check returns the threat the URL is known for, such as "MALWARE", or an
+	// empty string if it is not known to be malicious.
+	Check(ctx context.Context, url string) (string, error)
+}
+
+// ThreatStore disables the records whose URL was found to be a threat.
+type ThreatStore interface {
+	FlagURL(ctx context.Context, short string, reason string) error
+}
+
+// ScanOptions configures the threat scanner.
+type ScanOptions struct {
+	// QueueSize is the number of created records waiting to be checked; further
+	// records are not checked until the queue has room again.
+	QueueSize int
+	// Timeout bounds checking and flagging a single record.
+	Timeout time.Duration
+}
+
+// ThreatScanner is a background job checking the original URLs of created
+// records with a ThreatChecker and flagging the records of URLs found to be
+// malicious, so that they are no longer redirected to. It implements Job.
+type ThreatScanner struct {
+	in      chan storage.URLRecord // Records waiting to be checked
+	checker ThreatChecker
+	store   ThreatStore
+	opts    ScanOptions
+	logger  *zap.Logger
+
+	counters counters // Runs count checked records, failures the checks that failed
+
+	mu       sync.RWMutex  // Guards closing in against concurrent Submit calls
+	stopping bool          // Whether Stop was called; set under mu
+	done     chan struct{} // Closed when Run returns
+	doneOnce sync.Once
+}
+
+// NewThreatScanner creates a scanner checking records with checker and
+// flagging them in store. Zero options fall back to the defaults.
+func NewThreatScanner(logger *zap.Logger, checker ThreatChecker, store ThreatStore, opts ScanOptions) *ThreatScanner {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultScanQueueSize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultScanTimeout
+	}
+
+	return &ThreatScanner{
+		in:      make(chan storage.URLRecord, opts.QueueSize),
+		checker: checker,
+		store:   store,
+		opts:    opts,
+		logger:  logger,
+		done:    make(chan struct{}),
+	}
+}
+
+// Submit queues a record to be checked without blocking. It returns ErrStopped
+// once Stop has been called and ErrScanQueueFull if the queue has no room.
+func (s *ThreatScanner) Submit(r storage.URLRecord) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.stopping {
+		return ErrStopped
+	}
+
+	select {
+	case s.in <- r:
+		return nil
+	default:
+		return ErrScanQueueFull
+	}
+}
+
+// Run checks the queued records one at a time until Stop is called, after
+// checking the records still queued, or until ctx is done.
+func (s *ThreatScanner) Run(ctx context.Context) {
+	defer s.doneOnce.Do(func() { close(s.done) })
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r, ok := <-s.in:
+			if !ok {
+				return
+			}
+			s.scan(ctx, r)
+		}
+	}
+}
+
+// scan checks a single record and flags it if its URL is a threat.
+func (s *ThreatScanner) scan(ctx context.Context, r storage.URLRecord) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	threat, err := s.checker.Check(ctx, r.Original)
+	if err != nil {
+		s.counters.failures.Add(1)
+		s.logger.Warn("Cannot check URL for threats", zap.Error(err), zap.String("short", r.Short))
+		return
+	}
+	s.counters.runs.Add(1)
+	if threat == "" {
+		return
+	}
+
+	s.logger.Warn("Disabling URL flagged as a threat",
+		zap.String("short", r.Short), zap.String("url", r.Original), zap.String("threat", threat))
+	if err := s.store.FlagURL(ctx, r.Short, threat); err != nil {
+		s.counters.failures.Add(1)
+		s.logger.Error("Cannot flag URL", zap.Error(err), zap.String("short", r.Short))
+	}
+}
+
+// Stop stops accepting records, lets Run check the queued ones and waits until
+// it returns or ctx is done.
+func (s *ThreatScanner) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.stopping {
+		s.stopping = true
+		close(s.in)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Metrics returns a snapshot of the scanner counters.
+func (s *ThreatScanner) Metrics() Metrics {
+	return s.counters.snapshot()
+}
@@ -0,0 +1,73 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+// threatFeed is a ThreatChecker over a fixed map of URLs to threats.
+type threatFeed struct {
+	threats map[string]string
+	err     error
+}
+
+func (f threatFeed) Check(_ context.Context, url string) (string, error) {
+	return f.threats[url], f.err
+}
+
+// flagStore is a ThreatStore recording the flagged records.
+type flagStore struct {
+	mu      sync.Mutex
+	flagged map[string]string
+}
+
+func (s *flagStore) FlagURL(_ context.Context, short string, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flagged[short] = reason
+	return nil
+}
+
+func TestThreatScanner(t *testing.T) {
+	store := &flagStore{flagged: make(map[string]string)}
+	feed := threatFeed{threats: map[string]string{"https://evil.com": "MALWARE"}}
+	scanner := worker.NewThreatScanner(zap.NewNop(), feed, store, worker.ScanOptions{})
+
+	require.NoError(t, scanner.Submit(storage.URLRecord{Short: "bad", Original: "https://evil.com"}))
+	require.NoError(t, scanner.Submit(storage.URLRecord{Short: "good", Original: "https://example.com"}))
+
+	// Stop checks the records that are still queued
+	go scanner.Run(context.Background())
+	require.NoError(t, scanner.Stop(context.Background()))
+
+	require.Equal(t, map[string]string{"bad": "MALWARE"}, store.flagged)
+	require.Equal(t, uint64(2), scanner.Metrics().Runs)
+	require.ErrorIs(t, scanner.Submit(storage.URLRecord{Short: "late"}), worker.ErrStopped)
+}
+
+func TestThreatScanner_QueueFull(t *testing.T) {
+	scanner := worker.NewThreatScanner(zap.NewNop(), threatFeed{}, &flagStore{}, worker.ScanOptions{QueueSize: 1})
+
+	require.NoError(t, scanner.Submit(storage.URLRecord{Short: "a"}))
+	require.ErrorIs(t, scanner.Submit(storage.URLRecord{Short: "b"}), worker.ErrScanQueueFull)
+}
+
+func TestThreatScanner_CheckFails(t *testing.T) {
+	store := &flagStore{flagged: make(map[string]string)}
+	scanner := worker.NewThreatScanner(zap.NewNop(), threatFeed{err: errors.New("unavailable")}, store, worker.ScanOptions{})
+
+	require.NoError(t, scanner.Submit(storage.URLRecord{Short: "a", Original: "https://example.com"}))
+	go scanner.Run(context.Background())
+	require.NoError(t, scanner.Stop(context.Background()))
+
+	require.Empty(t, store.flagged)
+	require.Equal(t, uint64(1), scanner.Metrics().Failures)
+}
@@ -0,0 +1,162 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+)
+
+// Default title fetcher settings used when TitleOptions leaves them unset.
+const (
+	DefaultTitleQueueSize = 1000
+	DefaultTitleTimeout   = 5 * time.Second
+)
+
+// ErrTitleQueueFull is returned when submitting a record to a title fetcher whose queue is full.
+var ErrTitleQueueFull = errors.New("title fetch queue is full")
+
+// TitleSource looks up the titles of web pages.
+type TitleSource interface {
+	// Title returns the title of the page at url, or an empty string if it has none.
+	Title(ctx context.Context, url string) (string, error)
+}
+
+// TitleStore sets the titles of records that have none.
+type TitleStore interface {
+	SetDefaultTitle(ctx context.Context, short string, title string) error
+}
+
+// TitleOptions configures the title fetcher.
+type TitleOptions struct {
+	// QueueSize is the number of created records waiting for their title;
+	// further records get no title until the queue has room again.
+	QueueSize int
+	// Timeout bounds fetching and storing the title of a single record.
+	Timeout time.Duration
+}
+
+// TitleFetcher is a background job fetching the titles of the original URLs
+// of created records from a TitleSource and storing them as the titles of the
+// records that have none yet. It implements Job.
+type TitleFetcher struct {
+	in     chan storage.URLRecord // Records waiting for their title
+	source TitleSource
+	store  TitleStore
+	opts   TitleOptions
+	logger *zap.Logger
+
+	counters counters // Runs count fetched titles, failures the fetches that failed
+
+	mu       sync.RWMutex  // Guards closing in against concurrent Submit calls
+	stopping bool          // Whether Stop was called; set under mu
+	done     chan struct{} // Closed when Run returns
+	doneOnce sync.Once
+}
+
+// NewTitleFetcher creates a fetcher looking up titles with source and storing
+// them in store. Zero options fall back to the defaults.
+func NewTitleFetcher(logger *zap.Logger, source TitleSource, store TitleStore, opts TitleOptions) *TitleFetcher {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultTitleQueueSize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTitleTimeout
+	}
+
+	return &TitleFetcher{
+		in:     make(chan storage.URLRecord, opts.QueueSize),
+		source: source,
+		store:  store,
+		opts:   opts,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+}
+
+// Submit queues a record to get its title without blocking. It returns
+// ErrStopped once Stop has been called and ErrTitleQueueFull if the queue has
+// no room.
+func (f *TitleFetcher) Submit(r storage.URLRecord) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.stopping {
+		return ErrStopped
+	}
+
+	select {
+	case f.in <- r:
+		return nil
+	default:
+		return ErrTitleQueueFull
+	}
+}
+
+// Run fetches the titles of the queued records one at a time until Stop is
+// called, after fetching those of the records still queued, or until ctx is
+// done.
+func (f *TitleFetcher) Run(ctx context.Context) {
+	defer f.doneOnce.Do(func() { close(f.done) })
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r, ok := <-f.in:
+			if !ok {
+				return
+			}
+			f.fetch(ctx, r)
+		}
+	}
+}
+
+// fetch looks up the title of a single record and stores it.
+func (f *TitleFetcher) fetch(ctx context.Context, r storage.URLRecord) {
+	ctx, cancel := context.WithTimeout(ctx, f.opts.Timeout)
+	defer cancel()
+
+	title, err := f.source.Title(ctx, r.Original)
+	if err != nil {
+		f.counters.failures.Add(1)
+		f.logger.Debug("Cannot fetch page title", zap.Error(err), zap.String("short", r.Short))
+		return
+	}
+	f.counters.runs.Add(1)
+	if title == "" {
+		return
+	}
+
+	if err := f.store.SetDefaultTitle(ctx, r.Short, title); err != nil {
+		f.counters.failures.Add(1)
+		f.logger.Error("Cannot store page title", zap.Error(err), zap.String("short", r.Short))
+	}
+}
+
+// Stop stops accepting records, lets Run fetch the titles of the queued ones
+// and waits until it returns or ctx is done.
+func (f *TitleFetcher) Stop(ctx context.Context) error {
+	f.mu.Lock()
+	if !f.stopping {
+		f.stopping = true
+		close(f.in)
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Metrics returns a snapshot of the fetcher counters.
+func (f *TitleFetcher) Metrics() Metrics {
+	return f.counters.snapshot()
+}
@@ -0,0 +1,73 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/internal/worker"
+)
+
+// pageTitles is a TitleSource over a fixed map of URLs to titles.
+type pageTitles struct {
+	titles map[string]string
+	err    error
+}
+
+func (p pageTitles) Title(_ context.Context, url string) (string, error) {
+	return p.titles[url], p.err
+}
+
+// titleStore is a TitleStore recording the stored titles.
+type titleStore struct {
+	mu     sync.Mutex
+	titles map[string]string
+}
+
+func (s *titleStore) SetDefaultTitle(_ context.Context, short string, title string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.titles[short] = title
+	return nil
+}
+
+func TestTitleFetcher(t *testing.T) {
+	store := &titleStore{titles: make(map[string]string)}
+	source := pageTitles{titles: map[string]string{"https://example.com": "Example Domain"}}
+	fetcher := worker.NewTitleFetcher(zap.NewNop(), source, store, worker.TitleOptions{})
+
+	require.NoError(t, fetcher.Submit(storage.URLRecord{Short: "titled", Original: "https://example.com"}))
+	require.NoError(t, fetcher.Submit(storage.URLRecord{Short: "untitled", Original: "https://example.org"}))
+
+	// Stop fetches the titles of the records that are still queued
+	go fetcher.Run(context.Background())
+	require.NoError(t, fetcher.Stop(context.Background()))
+
+	require.Equal(t, map[string]string{"titled": "Example Domain"}, store.titles)
+	require.Equal(t, uint64(2), fetcher.Metrics().Runs)
+	require.ErrorIs(t, fetcher.Submit(storage.URLRecord{Short: "late"}), worker.ErrStopped)
+}
+
+func TestTitleFetcher_QueueFull(t *testing.T) {
+	fetcher := worker.NewTitleFetcher(zap.NewNop(), pageTitles{}, &titleStore{}, worker.TitleOptions{QueueSize: 1})
+
+	require.NoError(t, fetcher.Submit(storage.URLRecord{Short: "a"}))
+	require.ErrorIs(t, fetcher.Submit(storage.URLRecord{Short: "b"}), worker.ErrTitleQueueFull)
+}
+
+func TestTitleFetcher_FetchFails(t *testing.T) {
+	store := &titleStore{titles: make(map[string]string)}
+	fetcher := worker.NewTitleFetcher(zap.NewNop(), pageTitles{err: errors.New("unreachable")}, store, worker.TitleOptions{})
+
+	require.NoError(t, fetcher.Submit(storage.URLRecord{Short: "a", Original: "https://example.com"}))
+	go fetcher.Run(context.Background())
+	require.NoError(t, fetcher.Stop(context.Background()))
+
+	require.Empty(t, store.titles)
+	require.Equal(t, uint64(1), fetcher.Metrics().Failures)
+}
@@ -0,0 +1,389 @@
+// Package client provides a typed Go client of the URL shortener JSON API, so
+// integrators do not have to hand-roll HTTP calls. It authenticates with an
+// API key or with the JWT cookie issued by the server, retries requests that
+// failed with transient errors and compresses requests and responses with gzip.
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TokenCookie is the name of the cookie carrying the JWT of the user.
+const TokenCookie = "token"
+
+// defaultRetryBackoff is the delay before the first retry when none is configured.
+const defaultRetryBackoff = 100 * time.Millisecond
+
+// Errors matched by the *Error of a failed request with errors.Is.
+var (
+	ErrConflict = errors.New("url already shortened") // The original URL was shortened before
+	ErrNotFound = errors.New("not found")             // The short URL does not exist or is not active yet
+	ErrGone     = errors.New("gone")                  // The short URL was deleted, expired or disabled
+)
+
+// Error is returned for requests the server answered with an error status.
+type Error struct {
+	StatusCode int    // HTTP status code of the response
+	Message    string // Error message of the response body
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("shortener: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("shortener: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is reports whether the status code of the error corresponds to target, one
+// of ErrConflict, ErrNotFound and ErrGone.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrGone:
+		return e.StatusCode == http.StatusGone
+	}
+	return false
+}
+
+// Options configures a Client.
+type Options struct {
+	// HTTPClient sends the requests; a zero http.Client is used when nil. The
+	// client is copied, so that its cookie jar and redirect policy can be set.
+	HTTPClient *http.Client
+
+	// APIKey authenticates requests with the "Authorization: ApiKey" header.
+	APIKey string
+
+	// Token is the JWT cookie of a user to act as. Without it and an API key,
+	// the server issues a new user on the first request, whose token is kept
+	// for the following ones and returned by Client.Token.
+	Token string
+
+	// MaxRetries is how many times a request failing with a network error or
+	// a 429, 502, 503 or 504 status is retried; 0 disables retries.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry, doubled for each next one.
+	RetryBackoff time.Duration
+
+	// Gzip compresses request bodies. Responses are always accepted gzipped.
+	Gzip bool
+}
+
+// BatchRequest is a URL to shorten with Client.ShortenBatch.
+type BatchRequest struct {
+	CorrelationID string `json:"correlation_id"` // Identifies the URL in the response
+	OriginalURL   string `json:"original_url"`   // URL to shorten
+}
+
+// BatchResponse is a URL shortened with Client.ShortenBatch.
+type BatchResponse struct {
+	CorrelationID string `json:"correlation_id"` // Correlation ID of the request
+	ShortURL      string `json:"short_url"`      // Full short URL
+}
+
+// ExpandResult is a short URL resolved with Client.ExpandBatch.
+type ExpandResult struct {
+	ShortURL    string `json:"short_url"`              // Short URL identifier
+	OriginalURL string `json:"original_url,omitempty"` // URL it redirects to, empty if it does not redirect
+	Error       string `json:"error,omitempty"`        // Why it does not redirect, empty if it does
+}
+
+// URL is a short URL of the user.
+type URL struct {
+	ShortURL    string `json:"short_url"`    // Full short URL
+	OriginalURL string `json:"original_url"` // URL it redirects to
+}
+
+// Client calls the JSON API of a URL shortener server. It is safe for
+// concurrent use.
+type Client struct {
+	baseURL *url.URL
+	http    *http.Client
+	opts    Options
+}
+
+// New returns a client of the server at baseURL, for example "http://localhost:8080".
+func New(baseURL string, opts Options) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+
+	httpClient := http.Client{}
+	if opts.HTTPClient != nil {
+		httpClient = *opts.HTTPClient
+	}
+	if httpClient.Jar == nil {
+		// Cannot fail without options
+		httpClient.Jar, _ = cookiejar.New(nil)
+	}
+	// Redirects are the result of Expand, they are not followed
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	if opts.Token != "" {
+		httpClient.Jar.SetCookies(base, []*http.Cookie{{Name: TokenCookie, Value: opts.Token, Path: "/"}})
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = defaultRetryBackoff
+	}
+
+	return &Client{baseURL: base, http: &httpClient, opts: opts}, nil
+}
+
+// Token returns the JWT cookie the client authenticates with, which the server
+// issues on the first request of a client without a token or API key.
+func (c *Client) Token() string {
+	for _, cookie := range c.http.Jar.Cookies(c.baseURL) {
+		if cookie.Name == TokenCookie {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// Shorten shortens a URL and returns its full short URL. If the URL was
+// shortened before, the existing short URL is returned with an error matching
+// ErrConflict.
+func (c *Client) Shorten(ctx context.Context, originalURL string) (string, error) {
+	var res struct {
+		Result string `json:"result"`
+	}
+	err := c.do(ctx, http.MethodPost, "/api/v1/shorten", map[string]string{"url": originalURL}, &res, http.StatusCreated)
+	return res.Result, err
+}
+
+// ShortenBatch shortens several URLs at once. The whole batch is rejected if
+// one of the URLs cannot be shortened.
+func (c *Client) ShortenBatch(ctx context.Context, rs []BatchRequest) ([]BatchResponse, error) {
+	var res []BatchResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/shorten/batch", rs, &res, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Expand returns the original URL of a short URL, given as the full short URL
+// or its identifier, without following the redirect.
+func (c *Client) Expand(ctx context.Context, short string) (string, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/"+url.PathEscape(shortID(short)), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return "", responseError(resp)
+	}
+	return resp.Header.Get("Location"), nil
+}
+
+// ExpandBatch returns the original URLs of several short URLs, given as full
+// short URLs or their identifiers, in one request. Short URLs that do not
+// redirect get an Error instead of an OriginalURL.
+func (c *Client) ExpandBatch(ctx context.Context, shorts ...string) ([]ExpandResult, error) {
+	ids := make([]string, len(shorts))
+	for i, short := range shorts {
+		ids[i] = shortID(short)
+	}
+
+	var res []ExpandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/expand/batch", ids, &res, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ListMyURLs returns the short URLs of the user the client authenticates as.
+func (c *Client) ListMyURLs(ctx context.Context) ([]URL, error) {
+	var res []URL
+	if err := c.do(ctx, http.MethodGet, "/api/v1/user/urls", nil, &res, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Delete deletes short URLs of the user, given as full short URLs or their
+// identifiers. The server deletes them in the background.
+func (c *Client) Delete(ctx context.Context, shorts ...string) error {
+	ids := make([]string, len(shorts))
+	for i, short := range shorts {
+		ids[i] = shortID(short)
+	}
+	return c.do(ctx, http.MethodDelete, "/api/v1/user/urls", ids, nil, http.StatusAccepted)
+}
+
+// do sends a request with body encoded as JSON, unless it is nil, and decodes
+// the response into res, unless it is nil or the response has no content.
+// Statuses other than want and 204 No Content are returned as an *Error; a 409
+// Conflict response is decoded into res nevertheless, as it carries the
+// existing short URL.
+func (c *Client) do(ctx context.Context, method, path string, body any, res any, want int) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.send(ctx, method, path, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case want, http.StatusConflict:
+		if res == nil {
+			break
+		}
+		r, err := decodedBody(resp)
+		if err != nil {
+			return err
+		}
+		if err := json.NewDecoder(r).Decode(res); err != nil {
+			return fmt.Errorf("shortener: decode response: %w", err)
+		}
+	case http.StatusNoContent:
+	default:
+		return responseError(resp)
+	}
+
+	if resp.StatusCode == http.StatusConflict {
+		return &Error{StatusCode: resp.StatusCode, Message: ErrConflict.Error()}
+	}
+	return nil
+}
+
+// send sends a request, retrying it on network errors and transient statuses.
+func (c *Client) send(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	backoff := c.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		req, err := c.newRequest(ctx, method, path, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.http.Do(req)
+		if attempt == c.opts.MaxRetries || !retryable(ctx, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// newRequest builds a request of the API with the authentication and encoding headers.
+func (c *Client) newRequest(ctx context.Context, method, path string, payload []byte) (*http.Request, error) {
+	var body io.Reader
+	if payload != nil {
+		if c.opts.Gzip {
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			if _, err := gz.Write(payload); err != nil {
+				return nil, err
+			}
+			if err := gz.Close(); err != nil {
+				return nil, err
+			}
+			payload = buf.Bytes()
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+		if c.opts.Gzip {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+	}
+	if c.opts.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+c.opts.APIKey)
+	}
+	return req, nil
+}
+
+// retryable reports whether a request that got resp or err may be retried.
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// decodedBody returns the body of resp, decompressed if the server gzipped it.
+func decodedBody(resp *http.Response) (io.Reader, error) {
+	if !strings.Contains(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp.Body, nil
+	}
+	return gzip.NewReader(resp.Body)
+}
+
+// responseError returns the *Error of a failed response, with the message of
+// its JSON or plain text body.
+func responseError(resp *http.Response) error {
+	e := &Error{StatusCode: resp.StatusCode}
+
+	r, err := decodedBody(resp)
+	if err != nil {
+		return e
+	}
+	data, _ := io.ReadAll(io.LimitReader(r, 1024))
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		e.Message = body.Error
+	} else {
+		e.Message = strings.TrimSpace(string(data))
+	}
+	return e
+}
+
+// shortID returns the identifier of a short URL given as a full URL or an identifier.
+func shortID(short string) string {
+	if i := strings.LastIndexByte(short, '/'); i >= 0 {
+		return short[i+1:]
+	}
+	return short
+}
@@ -0,0 +1,163 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/atinyakov/go-url-shortener/internal/app/server"
+	"github.com/atinyakov/go-url-shortener/internal/app/service"
+	"github.com/atinyakov/go-url-shortener/internal/config"
+	"github.com/atinyakov/go-url-shortener/internal/storage"
+	"github.com/atinyakov/go-url-shortener/pkg/client"
+)
+
+const testSecret = "test-secret-that-is-at-least-32-bytes"
+
+// newTestServer starts a shortener server with in-memory storage and gzip enabled.
+func newTestServer(t *testing.T) *httptest.Server {
+	s, _ := storage.CreateMemoryStorage()
+	resolver, _ := service.NewURLResolver(8, s)
+	srv := httptest.NewUnstartedServer(nil)
+	baseURL := "http://" + srv.Listener.Addr().String()
+
+	sv, shutdown := service.NewURL(context.Background(), s, resolver, zap.NewNop(), baseURL)
+	t.Cleanup(shutdown)
+
+	users := service.NewUsers(storage.NewMemoryUserStorage())
+	auth, err := service.NewAuth(sv, testSecret)
+	require.NoError(t, err)
+	auth.SetUsers(users)
+
+	cfg := &config.Options{ResultHostname: baseURL}
+	srv.Config.Handler = server.Init(cfg, zap.NewNop(), true, sv, auth, service.NewAPIKeys(storage.NewMemoryAPIKeyStorage()), users, service.NewAudit(storage.NewMemoryAuditStorage(), zap.NewNop()), service.NewHealth(), nil, nil, nil, nil)
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	c, err := client.New(srv.URL, client.Options{Gzip: true})
+	require.NoError(t, err)
+
+	short, err := c.Shorten(ctx, "https://example.com/a")
+	require.NoError(t, err)
+	assert.Regexp(t, `^`+srv.URL+`/\w+$`, short)
+
+	// The server issued a user whose token is kept
+	token := c.Token()
+	require.NotEmpty(t, token)
+
+	batch, err := c.ShortenBatch(ctx, []client.BatchRequest{{CorrelationID: "b", OriginalURL: "https://example.com/b"}})
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	assert.Equal(t, "b", batch[0].CorrelationID)
+
+	original, err := c.Expand(ctx, short)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/a", original)
+
+	expanded, err := c.ExpandBatch(ctx, short, "unknown")
+	require.NoError(t, err)
+	require.Len(t, expanded, 2)
+	assert.Equal(t, "https://example.com/a", expanded[0].OriginalURL)
+	assert.Equal(t, "unknown", expanded[1].ShortURL)
+	assert.NotEmpty(t, expanded[1].Error)
+
+	_, err = c.Expand(ctx, "unknown")
+	assert.ErrorIs(t, err, client.ErrNotFound)
+
+	// Another client acting as the same user sees its URLs
+	same, err := client.New(srv.URL, client.Options{Token: token})
+	require.NoError(t, err)
+	urls, err := same.ListMyURLs(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []client.URL{
+		{ShortURL: short, OriginalURL: "https://example.com/a"},
+		{ShortURL: batch[0].ShortURL, OriginalURL: "https://example.com/b"},
+	}, urls)
+
+	// A new user has none
+	other, err := client.New(srv.URL, client.Options{})
+	require.NoError(t, err)
+	urls, err = other.ListMyURLs(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+
+	// Deletions are accepted and applied in the background
+	require.NoError(t, c.Delete(ctx, short))
+}
+
+func TestClient_Conflict(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"result":"http://short/abc"}`))
+	}))
+	defer srv.Close()
+
+	c, err := client.New(srv.URL, client.Options{})
+	require.NoError(t, err)
+	short, err := c.Shorten(context.Background(), "https://example.com")
+	assert.ErrorIs(t, err, client.ErrConflict)
+	assert.Equal(t, "http://short/abc", short)
+}
+
+func TestClient_APIKey(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	c, err := client.New(srv.URL, client.Options{APIKey: "unknown"})
+	require.NoError(t, err)
+
+	_, err = c.Shorten(ctx, "https://example.com")
+	var e *client.Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, http.StatusUnauthorized, e.StatusCode)
+	assert.Equal(t, "Invalid API key", e.Message)
+}
+
+func TestClient_Retries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"result":"http://short/abc"}`))
+	}))
+	defer srv.Close()
+
+	c, err := client.New(srv.URL, client.Options{MaxRetries: 2, RetryBackoff: time.Millisecond})
+	require.NoError(t, err)
+	short, err := c.Shorten(context.Background(), "https://example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "http://short/abc", short)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// Without retries the first failure is returned
+	calls.Store(0)
+	c, err = client.New(srv.URL, client.Options{})
+	require.NoError(t, err)
+	_, err = c.Shorten(context.Background(), "https://example.com")
+	var e *client.Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, http.StatusServiceUnavailable, e.StatusCode)
+	assert.Equal(t, "try again", e.Message)
+}
+
+func TestNew_InvalidBaseURL(t *testing.T) {
+	_, err := client.New("localhost:8080", client.Options{})
+	assert.Error(t, err)
+}